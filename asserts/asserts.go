@@ -69,6 +69,8 @@ var (
 	SystemUserType      = &AssertionType{"system-user", []string{"brand-id", "email"}, assembleSystemUser, 0}
 	ValidationType      = &AssertionType{"validation", []string{"series", "snap-id", "approved-snap-id", "approved-snap-revision"}, assembleValidation, 0}
 	StoreType           = &AssertionType{"store", []string{"store"}, assembleStore, 0}
+	RevocationType      = &AssertionType{"revocation", []string{"account-id"}, assembleRevocation, 0}
+	ValidationSetType   = &AssertionType{"validation-set", []string{"series", "account-id", "name", "sequence"}, assembleValidationSet, 0}
 
 // ...
 )
@@ -94,6 +96,8 @@ var typeRegistry = map[string]*AssertionType{
 	ValidationType.Name:      ValidationType,
 	RepairType.Name:          RepairType,
 	StoreType.Name:           StoreType,
+	RevocationType.Name:      RevocationType,
+	ValidationSetType.Name:   ValidationSetType,
 	// no authority
 	DeviceSessionRequestType.Name: DeviceSessionRequestType,
 	SerialRequestType.Name:        SerialRequestType,
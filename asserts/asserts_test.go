@@ -55,6 +55,7 @@ func (as *assertsSuite) TestTypeNames(c *C) {
 		"device-session-request",
 		"model",
 		"repair",
+		"revocation",
 		"serial",
 		"serial-request",
 		"snap-build",
@@ -68,6 +69,7 @@ func (as *assertsSuite) TestTypeNames(c *C) {
 		"test-only-no-authority",
 		"test-only-no-authority-pk",
 		"validation",
+		"validation-set",
 	})
 }
 
@@ -889,6 +891,8 @@ func (as *assertsSuite) TestWithAuthority(c *C) {
 		"system-user",
 		"validation",
 		"repair",
+		"revocation",
+		"validation-set",
 	}
 	c.Check(withAuthority, HasLen, asserts.NumAssertionType-3) // excluding device-session-request, serial-request, account-key-request
 	for _, name := range withAuthority {
@@ -49,6 +49,21 @@ func IsNotFound(err error) bool {
 	return ok
 }
 
+// RevokedError indicates that an assertion has been revoked, or that
+// it is signed with a revoked key, by a revocation assertion.
+type RevokedError struct {
+	Ref *Ref
+	// KeyID is set if the signing key of the assertion was revoked.
+	KeyID string
+}
+
+func (e *RevokedError) Error() string {
+	if e.KeyID != "" {
+		return fmt.Sprintf("%v is signed with revoked public key %q", e.Ref, e.KeyID)
+	}
+	return fmt.Sprintf("%v has been revoked", e.Ref)
+}
+
 // A Backstore stores assertions. It can store and retrieve assertions
 // by type under unique primary key headers (whose names are available
 // from assertType.PrimaryKey). Plus it supports searching by headers.
@@ -161,7 +176,8 @@ type RODatabase interface {
 	IsTrustedAccount(accountID string) bool
 	// Find an assertion based on arbitrary headers.
 	// Provided headers must contain the primary key for the assertion type.
-	// It returns a NotFoundError if the assertion cannot be found.
+	// It returns a NotFoundError if the assertion cannot be found or
+	// has been revoked.
 	Find(assertionType *AssertionType, headers map[string]string) (Assertion, error)
 	// FindPredefined finds an assertion in the predefined sets
 	// (trusted or not) based on arbitrary headers.  Provided
@@ -175,12 +191,17 @@ type RODatabase interface {
 	// NotFoundError if the assertion cannot be found.
 	FindTrusted(assertionType *AssertionType, headers map[string]string) (Assertion, error)
 	// FindMany finds assertions based on arbitrary headers.
+	// Revoked assertions are skipped.
 	// It returns a NotFoundError if no assertion can be found.
 	FindMany(assertionType *AssertionType, headers map[string]string) ([]Assertion, error)
 	// FindManyPredefined finds assertions in the predefined sets
 	// (trusted or not) based on arbitrary headers.  It returns a
 	// NotFoundError if no assertion can be found.
 	FindManyPredefined(assertionType *AssertionType, headers map[string]string) ([]Assertion, error)
+	// FindManyRevoked finds assertions based on arbitrary headers
+	// that have been revoked or are signed with a revoked key.
+	// It returns a NotFoundError if no such assertion can be found.
+	FindManyRevoked(assertionType *AssertionType, headers map[string]string) ([]Assertion, error)
 	// Check tests whether the assertion is properly signed and consistent with all the stored knowledge.
 	Check(assert Assertion) error
 }
@@ -459,18 +480,85 @@ func find(backstores []Backstore, assertionType *AssertionType, headers map[stri
 	return assert, nil
 }
 
+// checkRevoked returns a RevokedError if the assertion or its signing
+// key have been revoked by a revocation assertion from its authority
+// or, for account-keys, from the account owning the key.
+func checkRevoked(assert Assertion, roDB RODatabase) error {
+	typ := assert.Type()
+	if typ.flags&noAuthority != 0 {
+		return nil
+	}
+	authorityID := assert.AuthorityID()
+	accountIDs := []string{authorityID}
+	if accKey, ok := assert.(*AccountKey); ok && accKey.AccountID() != authorityID {
+		accountIDs = append(accountIDs, accKey.AccountID())
+	}
+	for _, accountID := range accountIDs {
+		a, err := roDB.Find(RevocationType, map[string]string{
+			"account-id": accountID,
+		})
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rev := a.(*Revocation)
+		if accountID == authorityID && rev.IsKeyRevoked(assert.SignKeyID()) {
+			return &RevokedError{Ref: assert.Ref(), KeyID: assert.SignKeyID()}
+		}
+		// revocations themselves cannot be revoked
+		if typ != RevocationType && rev.IsRevoked(assert) {
+			return &RevokedError{Ref: assert.Ref()}
+		}
+	}
+	return nil
+}
+
+func (db *Database) revoked(assert Assertion) error {
+	if assert.Type() == RevocationType {
+		// a stored revocation was checked against its
+		// predecessors already and looking it up must not recurse
+		return nil
+	}
+	return checkRevoked(assert, db)
+}
+
+// findNotRevoked finds an assertion like find, predefined assertions
+// take precedence and are never considered revoked, assertions from
+// the general backstore that are revoked are treated as not found.
+func (db *Database) findNotRevoked(assertionType *AssertionType, headers map[string]string, maxFormat int) (Assertion, error) {
+	a, err := find([]Backstore{db.trusted, db.predefined}, assertionType, headers, maxFormat)
+	if !IsNotFound(err) {
+		return a, err
+	}
+	a, err = find([]Backstore{db.bs}, assertionType, headers, maxFormat)
+	if err != nil {
+		return nil, err
+	}
+	err = db.revoked(a)
+	if _, ok := err.(*RevokedError); ok {
+		return nil, &NotFoundError{Type: assertionType, Headers: headers}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
 // Find an assertion based on arbitrary headers.
 // Provided headers must contain the primary key for the assertion type.
-// It returns a NotFoundError if the assertion cannot be found.
+// It returns a NotFoundError if the assertion cannot be found or has
+// been revoked.
 func (db *Database) Find(assertionType *AssertionType, headers map[string]string) (Assertion, error) {
-	return find(db.backstores, assertionType, headers, -1)
+	return db.findNotRevoked(assertionType, headers, -1)
 }
 
 // FindMaxFormat finds an assertion like Find but such that its
 // format is <= maxFormat by passing maxFormat along to the backend.
 // It returns a NotFoundError if such an assertion cannot be found.
 func (db *Database) FindMaxFormat(assertionType *AssertionType, headers map[string]string, maxFormat int) (Assertion, error) {
-	return find(db.backstores, assertionType, headers, maxFormat)
+	return db.findNotRevoked(assertionType, headers, maxFormat)
 }
 
 // FindPredefined finds an assertion in the predefined sets (trusted
@@ -488,24 +576,55 @@ func (db *Database) FindTrusted(assertionType *AssertionType, headers map[string
 	return find([]Backstore{db.trusted}, assertionType, headers, -1)
 }
 
-func (db *Database) findMany(backstores []Backstore, assertionType *AssertionType, headers map[string]string) ([]Assertion, error) {
+// revokedFilter selects which assertions from the general backstore
+// findMany returns based on their revocation status.
+type revokedFilter int
+
+const (
+	excludeRevoked revokedFilter = iota
+	onlyRevoked
+)
+
+func (db *Database) findMany(backstores []Backstore, assertionType *AssertionType, headers map[string]string, filter revokedFilter) ([]Assertion, error) {
 	err := checkAssertType(assertionType)
 	if err != nil {
 		return nil, err
 	}
 	res := []Assertion{}
 
+	var revokedErr error
 	foundCb := func(assert Assertion) {
 		res = append(res, assert)
 	}
+	filteredCb := func(assert Assertion) {
+		err := db.revoked(assert)
+		_, isRevoked := err.(*RevokedError)
+		if err != nil && !isRevoked {
+			revokedErr = err
+			return
+		}
+		if isRevoked == (filter == onlyRevoked) {
+			res = append(res, assert)
+		}
+	}
 
 	// TODO: Find variant taking this
 	maxFormat := assertionType.MaxSupportedFormat()
 	for _, bs := range backstores {
-		err = bs.Search(assertionType, headers, foundCb, maxFormat)
+		cb := foundCb
+		if bs == db.bs {
+			cb = filteredCb
+		} else if filter == onlyRevoked {
+			// predefined assertions are never revoked
+			continue
+		}
+		err = bs.Search(assertionType, headers, cb, maxFormat)
 		if err != nil {
 			return nil, err
 		}
+		if revokedErr != nil {
+			return nil, revokedErr
+		}
 	}
 
 	if len(res) == 0 {
@@ -515,16 +634,24 @@ func (db *Database) findMany(backstores []Backstore, assertionType *AssertionTyp
 }
 
 // FindMany finds assertions based on arbitrary headers.
+// Revoked assertions are skipped.
 // It returns a NotFoundError if no assertion can be found.
 func (db *Database) FindMany(assertionType *AssertionType, headers map[string]string) ([]Assertion, error) {
-	return db.findMany(db.backstores, assertionType, headers)
+	return db.findMany(db.backstores, assertionType, headers, excludeRevoked)
 }
 
 // FindManyPrefined finds assertions in the predefined sets (trusted
 // or not) based on arbitrary headers.  It returns a NotFoundError if
 // no assertion can be found.
 func (db *Database) FindManyPredefined(assertionType *AssertionType, headers map[string]string) ([]Assertion, error) {
-	return db.findMany([]Backstore{db.trusted, db.predefined}, assertionType, headers)
+	return db.findMany([]Backstore{db.trusted, db.predefined}, assertionType, headers, excludeRevoked)
+}
+
+// FindManyRevoked finds assertions based on arbitrary headers that
+// have been revoked or are signed with a revoked key.
+// It returns a NotFoundError if no such assertion can be found.
+func (db *Database) FindManyRevoked(assertionType *AssertionType, headers map[string]string) ([]Assertion, error) {
+	return db.findMany(db.backstores, assertionType, headers, onlyRevoked)
 }
 
 // assertion checkers
@@ -595,6 +722,16 @@ func CheckTimestampVsSigningKeyValidity(assert Assertion, signingKey *AccountKey
 	return nil
 }
 
+// CheckNotRevoked checks that neither the assertion nor its signing
+// key have been revoked.
+func CheckNotRevoked(assert Assertion, signingKey *AccountKey, roDB RODatabase, checkTime time.Time) error {
+	if signingKey == nil {
+		// no-authority assertions cannot be revoked
+		return nil
+	}
+	return checkRevoked(assert, roDB)
+}
+
 // XXX: keeping these in this form until we know better
 
 // A consistencyChecker performs further checks based on the full
@@ -619,5 +756,6 @@ var DefaultCheckers = []Checker{
 	CheckSigningKeyIsNotExpired,
 	CheckSignature,
 	CheckTimestampVsSigningKeyValidity,
+	CheckNotRevoked,
 	CheckCrossConsistency,
 }
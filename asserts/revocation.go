@@ -0,0 +1,283 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"fmt"
+	"time"
+)
+
+// RevokedAssertion references an assertion revoked by a revocation
+// assertion. All revisions of it up to and including Revision are
+// revoked, or all of them if Revision is -1.
+type RevokedAssertion struct {
+	Ref      *Ref
+	Revision int
+}
+
+func (ra *RevokedAssertion) matches(assert Assertion) bool {
+	if assert.Type() != ra.Ref.Type {
+		return false
+	}
+	if ra.Revision != -1 && assert.Revision() > ra.Revision {
+		return false
+	}
+	return assert.Ref().Unique() == ra.Ref.Unique()
+}
+
+// Revocation holds a revocation assertion, which lists the account-keys
+// and the assertions of an account that must no longer be trusted,
+// for example because of a leaked signing key. Revocations are issued by
+// a trusted authority, the account itself can only revoke the key it
+// signs the revocation with.
+type Revocation struct {
+	assertionBase
+	revokedKeys       []string
+	revokedAssertions []*RevokedAssertion
+	timestamp         time.Time
+}
+
+// AccountID returns the identifier of the account whose keys and
+// assertions are revoked.
+func (r *Revocation) AccountID() string {
+	return r.HeaderString("account-id")
+}
+
+// RevokedKeys returns the ids of the revoked account-keys.
+func (r *Revocation) RevokedKeys() []string {
+	return r.revokedKeys
+}
+
+// RevokedAssertions returns references to the revoked assertions.
+func (r *Revocation) RevokedAssertions() []*RevokedAssertion {
+	return r.revokedAssertions
+}
+
+// Timestamp returns the time when the revocation was issued.
+func (r *Revocation) Timestamp() time.Time {
+	return r.timestamp
+}
+
+// IsKeyRevoked returns whether the account-key with the given id has
+// been revoked.
+func (r *Revocation) IsKeyRevoked(keyID string) bool {
+	for _, k := range r.revokedKeys {
+		if k == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRevoked returns whether the given assertion has been revoked
+// either directly or, for account-keys, by revoking its key id.
+func (r *Revocation) IsRevoked(assert Assertion) bool {
+	if accKey, ok := assert.(*AccountKey); ok && accKey.AccountID() == r.AccountID() {
+		if r.IsKeyRevoked(accKey.PublicKeyID()) {
+			return true
+		}
+	}
+	for _, ra := range r.revokedAssertions {
+		if ra.matches(assert) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prerequisites returns references to this revocation's prerequisite assertions.
+func (r *Revocation) Prerequisites() []*Ref {
+	return []*Ref{
+		{Type: AccountType, PrimaryKey: []string{r.AccountID()}},
+	}
+}
+
+// Implement further consistency checks.
+func (r *Revocation) checkConsistency(db RODatabase, acck *AccountKey) error {
+	accountID := r.AccountID()
+	trusted := db.IsTrustedAccount(r.AuthorityID())
+	if r.AuthorityID() != accountID && !trusted {
+		return fmt.Errorf("revocation assertion for %q is not signed by the account itself or a directly trusted authority: %s", accountID, r.AuthorityID())
+	}
+	_, err := db.Find(AccountType, map[string]string{
+		"account-id": accountID,
+	})
+	if IsNotFound(err) {
+		return fmt.Errorf("revocation assertion for %q does not have a matching account assertion", accountID)
+	}
+	if err != nil {
+		return err
+	}
+	for _, keyID := range r.revokedKeys {
+		_, err := db.FindTrusted(AccountKeyType, map[string]string{
+			"public-key-sha3-384": keyID,
+		})
+		if err == nil {
+			return fmt.Errorf("revocation assertion for %q cannot revoke trusted public key %q", accountID, keyID)
+		}
+		if !IsNotFound(err) {
+			return err
+		}
+	}
+	var prev *Revocation
+	a, err := db.Find(RevocationType, map[string]string{
+		"account-id": accountID,
+	})
+	if err == nil {
+		prev = a.(*Revocation)
+	} else if !IsNotFound(err) {
+		return err
+	}
+	if trusted {
+		// a trusted authority can revoke anything, and also
+		// withdraw its previous revocations
+		return nil
+	}
+	// a leaked key must not be able to revoke the other keys and the
+	// assertions of the account, so a self-signed revocation can only
+	// add its own signing key to what was revoked before, and cannot
+	// withdraw anything
+	for _, keyID := range r.revokedKeys {
+		if keyID != r.SignKeyID() && (prev == nil || !prev.IsKeyRevoked(keyID)) {
+			return fmt.Errorf("self-signed revocation assertion for %q can only revoke its own signing key, not %q", accountID, keyID)
+		}
+	}
+	for _, ra := range r.revokedAssertions {
+		if prev == nil || !prev.coversRevoked(ra) {
+			return fmt.Errorf("self-signed revocation assertion for %q cannot revoke %v", accountID, ra.Ref)
+		}
+	}
+	if prev == nil {
+		return nil
+	}
+	for _, keyID := range prev.revokedKeys {
+		if !r.IsKeyRevoked(keyID) {
+			return fmt.Errorf("revocation assertion for %q cannot drop previously revoked public key %q", accountID, keyID)
+		}
+	}
+	for _, prevRA := range prev.revokedAssertions {
+		if !r.coversRevoked(prevRA) {
+			return fmt.Errorf("revocation assertion for %q cannot drop or narrow previously revoked %v", accountID, prevRA.Ref)
+		}
+	}
+	return nil
+}
+
+func (r *Revocation) coversRevoked(prevRA *RevokedAssertion) bool {
+	for _, ra := range r.revokedAssertions {
+		if ra.Ref.Type != prevRA.Ref.Type || ra.Ref.Unique() != prevRA.Ref.Unique() {
+			continue
+		}
+		if ra.Revision == -1 || (prevRA.Revision != -1 && ra.Revision >= prevRA.Revision) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanity
+var _ consistencyChecker = (*Revocation)(nil)
+
+// lookupType is set in init to break the initialisation loop through
+// typeRegistry
+var lookupType func(name string) *AssertionType
+
+func init() {
+	lookupType = Type
+}
+
+func checkRevokedAssertions(headers map[string]interface{}) ([]*RevokedAssertion, error) {
+	const name = "revoked-assertions"
+	value, ok := headers[name]
+	if !ok {
+		return nil, nil
+	}
+	lst, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%q header must be a list of maps", name)
+	}
+	res := make([]*RevokedAssertion, 0, len(lst))
+	for _, v := range lst {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%q header must be a list of maps", name)
+		}
+		const what = "header of revoked assertion"
+		typeName, err := checkNotEmptyStringWhat(m, "type", what)
+		if err != nil {
+			return nil, err
+		}
+		assertType := lookupType(typeName)
+		if assertType == nil {
+			return nil, fmt.Errorf("unknown revoked assertion type: %q", typeName)
+		}
+		if assertType.Name == "revocation" || assertType.flags&noAuthority != 0 {
+			return nil, fmt.Errorf("cannot revoke %q assertions", typeName)
+		}
+		primaryKey := make([]string, len(assertType.PrimaryKey))
+		for i, k := range assertType.PrimaryKey {
+			primaryKey[i], err = checkNotEmptyStringWhat(m, k, what)
+			if err != nil {
+				return nil, err
+			}
+		}
+		revision, err := checkIntWithDefault(m, "revision", -1)
+		if err != nil {
+			return nil, err
+		}
+		if revision < -1 {
+			return nil, fmt.Errorf("%q %s cannot be negative", "revision", what)
+		}
+		res = append(res, &RevokedAssertion{
+			Ref:      &Ref{Type: assertType, PrimaryKey: primaryKey},
+			Revision: revision,
+		})
+	}
+	return res, nil
+}
+
+func assembleRevocation(assert assertionBase) (Assertion, error) {
+	_, err := checkNotEmptyString(assert.headers, "account-id")
+	if err != nil {
+		return nil, err
+	}
+
+	revokedKeys, err := checkStringListMatches(assert.headers, "revoked-keys", base64HashLike)
+	if err != nil {
+		return nil, err
+	}
+
+	revokedAssertions, err := checkRevokedAssertions(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Revocation{
+		assertionBase:     assert,
+		revokedKeys:       revokedKeys,
+		revokedAssertions: revokedAssertions,
+		timestamp:         timestamp,
+	}, nil
+}
@@ -0,0 +1,497 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+)
+
+var _ = Suite(&revocationSuite{})
+
+type revocationSuite struct {
+	ts           time.Time
+	tsLine       string
+	validExample string
+}
+
+func (s *revocationSuite) SetUpSuite(c *C) {
+	s.ts = time.Now().Truncate(time.Second).UTC()
+	s.tsLine = "timestamp: " + s.ts.Format(time.RFC3339) + "\n"
+	s.validExample = "type: revocation\n" +
+		"authority-id: acme\n" +
+		"account-id: acme\n" +
+		"revoked-keys:\n" +
+		"  - Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij\n" +
+		"revoked-assertions:\n" +
+		"  -\n" +
+		"    type: model\n" +
+		"    series: 16\n" +
+		"    brand-id: acme\n" +
+		"    model: frobinator\n" +
+		"    revision: 2\n" +
+		"  -\n" +
+		"    type: snap-build\n" +
+		"    snap-sha3-384: QlqR0uAWEAWF5Nwnzj5kqmmwFslYPu1IL16MKtLKhwhv0kpBv5wKZ_axf_nf_2cL\n" +
+		s.tsLine +
+		"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij\n" +
+		"\n" +
+		"AXNpZw=="
+}
+
+func (s *revocationSuite) TestDecodeOK(c *C) {
+	a, err := asserts.Decode([]byte(s.validExample))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.RevocationType)
+	rev := a.(*asserts.Revocation)
+
+	c.Check(rev.AccountID(), Equals, "acme")
+	c.Check(rev.Timestamp().Equal(s.ts), Equals, true)
+	c.Check(rev.RevokedKeys(), DeepEquals, []string{"Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij"})
+	c.Check(rev.IsKeyRevoked("Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij"), Equals, true)
+	c.Check(rev.IsKeyRevoked("other"), Equals, false)
+	c.Check(rev.RevokedAssertions(), DeepEquals, []*asserts.RevokedAssertion{
+		{Ref: &asserts.Ref{Type: asserts.ModelType, PrimaryKey: []string{"16", "acme", "frobinator"}}, Revision: 2},
+		{Ref: &asserts.Ref{Type: asserts.SnapBuildType, PrimaryKey: []string{"QlqR0uAWEAWF5Nwnzj5kqmmwFslYPu1IL16MKtLKhwhv0kpBv5wKZ_axf_nf_2cL"}}, Revision: -1},
+	})
+}
+
+func (s *revocationSuite) TestPrerequisites(c *C) {
+	a, err := asserts.Decode([]byte(s.validExample))
+	c.Assert(err, IsNil)
+	c.Check(a.Prerequisites(), DeepEquals, []*asserts.Ref{
+		{Type: asserts.AccountType, PrimaryKey: []string{"acme"}},
+	})
+}
+
+const otherSHA3_384 = "Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij"
+
+const revocationErrPrefix = "assertion revocation: "
+
+func (s *revocationSuite) TestDecodeInvalid(c *C) {
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"account-id: acme\n", "", `"account-id" header is mandatory`},
+		{"account-id: acme\n", "account-id: \n", `"account-id" header should not be empty`},
+		{"  - Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij\nrevoked-assertions", "  - foo/bar\nrevoked-assertions", `"revoked-keys" header contains an invalid element: "foo/bar"`},
+		{"revoked-keys:\n  - Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij\n", "revoked-keys: foo\n", `"revoked-keys" header must be a list of strings`},
+		{"  -\n    type: model\n", "  - model\n  -\n    type: model\n", `"revoked-assertions" header must be a list of maps`},
+		{"    type: model\n", "", `"type" header of revoked assertion is mandatory`},
+		{"    type: model\n", "    type: foo\n", `unknown revoked assertion type: "foo"`},
+		{"    type: model\n", "    type: revocation\n", `cannot revoke "revocation" assertions`},
+		{"    type: model\n", "    type: serial-request\n", `cannot revoke "serial-request" assertions`},
+		{"    model: frobinator\n", "", `"model" header of revoked assertion is mandatory`},
+		{"    revision: 2\n", "    revision: x\n", `"revision" header is not an integer: x`},
+		{"    revision: 2\n", "    revision: -2\n", `"revision" header of revoked assertion cannot be negative`},
+		{s.tsLine, "", `"timestamp" header is mandatory`},
+		{s.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(s.validExample, test.original, test.invalid, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, revocationErrPrefix+test.expectedErr)
+	}
+}
+
+type revocationFixture struct {
+	storeDB *assertstest.StoreStack
+	db      *asserts.Database
+	devDB   *assertstest.SigningDB
+	newKey  *asserts.AccountKey
+}
+
+// makeRevocationFixture sets up the devel1 account with two keys,
+// the default one from setup3rdPartySigning and a second one that is
+// used to sign revocations.
+func makeRevocationFixture(c *C) *revocationFixture {
+	storeDB, db := makeStoreAndCheckDB(c)
+	devDB := setup3rdPartySigning(c, "devel1", storeDB, db)
+
+	acct, err := db.Find(asserts.AccountType, map[string]string{"account-id": "devel1"})
+	c.Assert(err, IsNil)
+	newKey := assertstest.NewAccountKey(storeDB, acct.(*asserts.Account), map[string]interface{}{
+		"name": "new",
+	}, testPrivKey0.PublicKey(), "")
+	err = db.Add(newKey)
+	c.Assert(err, IsNil)
+	err = devDB.ImportKey(testPrivKey0)
+	c.Assert(err, IsNil)
+
+	return &revocationFixture{
+		storeDB: storeDB,
+		db:      db,
+		devDB:   devDB,
+		newKey:  newKey,
+	}
+}
+
+func (f *revocationFixture) snapBuild(c *C, sha3_384 string) asserts.Assertion {
+	snapBuild, err := f.devDB.Sign(asserts.SnapBuildType, map[string]interface{}{
+		"snap-sha3-384": sha3_384,
+		"snap-id":       "snap-id-1",
+		"grade":         "devel",
+		"snap-size":     "1025",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return snapBuild
+}
+
+func (f *revocationFixture) revoke(c *C, revision string, extra map[string]interface{}) (asserts.Assertion, error) {
+	headers := map[string]interface{}{
+		"account-id": "devel1",
+		"revision":   revision,
+		"timestamp":  time.Now().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	return f.storeDB.Sign(asserts.RevocationType, headers, nil, "")
+}
+
+// selfRevoke signs a revocation by devel1 itself, with its new key.
+func (f *revocationFixture) selfRevoke(c *C, revision string, extra map[string]interface{}) (asserts.Assertion, error) {
+	headers := map[string]interface{}{
+		"account-id": "devel1",
+		"revision":   revision,
+		"timestamp":  time.Now().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	return f.devDB.Sign(asserts.RevocationType, headers, nil, f.newKey.PublicKeyID())
+}
+
+func (s *revocationSuite) TestCheckAuthority(c *C) {
+	storeDB, db := makeStoreAndCheckDB(c)
+	devel1 := assertstest.NewAccount(storeDB, "devel1", map[string]interface{}{
+		"account-id": "devel1",
+	}, "")
+	err := db.Add(devel1)
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"account-id": "devel1",
+		"timestamp":  time.Now().Format(time.RFC3339),
+	}
+
+	// revocation signed by some other account fails
+	otherDB := setup3rdPartySigning(c, "other", storeDB, db)
+	rev, err := otherDB.Sign(asserts.RevocationType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = db.Check(rev)
+	c.Check(err, ErrorMatches, `revocation assertion for "devel1" is not signed by the account itself or a directly trusted authority: other`)
+
+	// but succeeds when signed by a trusted authority
+	rev, err = storeDB.Sign(asserts.RevocationType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = db.Check(rev)
+	c.Check(err, IsNil)
+}
+
+func (s *revocationSuite) TestCheckAccount(c *C) {
+	storeDB, db := makeStoreAndCheckDB(c)
+
+	rev, err := storeDB.Sign(asserts.RevocationType, map[string]interface{}{
+		"account-id": "devel1",
+		"timestamp":  time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	err = db.Check(rev)
+	c.Check(err, ErrorMatches, `revocation assertion for "devel1" does not have a matching account assertion`)
+}
+
+func (s *revocationSuite) TestCannotRevokeTrustedKey(c *C) {
+	storeDB, db := makeStoreAndCheckDB(c)
+
+	rootKeyID := storeDB.TrustedKey.PublicKeyID()
+	rev, err := storeDB.Sign(asserts.RevocationType, map[string]interface{}{
+		"account-id":   "canonical",
+		"revoked-keys": []interface{}{rootKeyID},
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	err = db.Check(rev)
+	c.Check(err, ErrorMatches, `revocation assertion for "canonical" cannot revoke trusted public key ".*"`)
+}
+
+func (s *revocationSuite) TestRevokedKey(c *C) {
+	f := makeRevocationFixture(c)
+	oldKeyID := testPrivKey2.PublicKey().ID()
+
+	snapBuild := f.snapBuild(c, blobSHA3_384)
+	err := f.db.Add(snapBuild)
+	c.Assert(err, IsNil)
+
+	rev, err := f.revoke(c, "0", map[string]interface{}{
+		"revoked-keys": []interface{}{oldKeyID},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Assert(err, IsNil)
+
+	// both the key and what it signed are gone
+	_, err = f.db.Find(asserts.AccountKeyType, map[string]string{
+		"public-key-sha3-384": oldKeyID,
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+	_, err = f.db.Find(asserts.SnapBuildType, map[string]string{
+		"snap-sha3-384": blobSHA3_384,
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+	_, err = f.db.FindMany(asserts.SnapBuildType, nil)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	// but the other key is still there
+	keys, err := f.db.FindMany(asserts.AccountKeyType, map[string]string{
+		"account-id": "devel1",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 1)
+	c.Check(keys[0].(*asserts.AccountKey).PublicKeyID(), Equals, f.newKey.PublicKeyID())
+
+	revoked, err := f.db.FindManyRevoked(asserts.SnapBuildType, nil)
+	c.Assert(err, IsNil)
+	c.Check(revoked, DeepEquals, []asserts.Assertion{snapBuild})
+	revoked, err = f.db.FindManyRevoked(asserts.AccountKeyType, nil)
+	c.Assert(err, IsNil)
+	c.Assert(revoked, HasLen, 1)
+	c.Check(revoked[0].(*asserts.AccountKey).PublicKeyID(), Equals, oldKeyID)
+
+	// new assertions signed with the revoked key are rejected
+	other := f.snapBuild(c, otherSHA3_384)
+	err = f.db.Check(other)
+	c.Check(err, FitsTypeOf, &asserts.RevokedError{})
+	c.Check(err, ErrorMatches, `snap-build \(`+otherSHA3_384+`\) is signed with revoked public key ".*"`)
+
+	// as are revocations
+	rev, err = f.devDB.Sign(asserts.RevocationType, map[string]interface{}{
+		"account-id":   "devel1",
+		"revision":     "1",
+		"revoked-keys": []interface{}{oldKeyID},
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	err = f.db.Check(rev)
+	c.Check(err, ErrorMatches, `revocation \(devel1\) is signed with revoked public key ".*"`)
+}
+
+func (s *revocationSuite) TestRevokedAssertion(c *C) {
+	f := makeRevocationFixture(c)
+
+	snapBuild := f.snapBuild(c, blobSHA3_384)
+	err := f.db.Add(snapBuild)
+	c.Assert(err, IsNil)
+	otherSnapBuild := f.snapBuild(c, otherSHA3_384)
+	err = f.db.Add(otherSnapBuild)
+	c.Assert(err, IsNil)
+
+	rev, err := f.revoke(c, "0", map[string]interface{}{
+		"revoked-assertions": []interface{}{
+			map[string]interface{}{
+				"type":          "snap-build",
+				"snap-sha3-384": blobSHA3_384,
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Assert(err, IsNil)
+
+	_, err = f.db.Find(asserts.SnapBuildType, map[string]string{
+		"snap-sha3-384": blobSHA3_384,
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	builds, err := f.db.FindMany(asserts.SnapBuildType, nil)
+	c.Assert(err, IsNil)
+	c.Check(builds, DeepEquals, []asserts.Assertion{otherSnapBuild})
+
+	revoked, err := f.db.FindManyRevoked(asserts.SnapBuildType, nil)
+	c.Assert(err, IsNil)
+	c.Check(revoked, DeepEquals, []asserts.Assertion{snapBuild})
+
+	// the key itself was not revoked
+	_, err = f.db.FindManyRevoked(asserts.AccountKeyType, nil)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	// the revocation itself is visible
+	_, err = f.db.Find(asserts.RevocationType, map[string]string{
+		"account-id": "devel1",
+	})
+	c.Check(err, IsNil)
+}
+
+func (s *revocationSuite) TestRevokedAssertionUpToRevision(c *C) {
+	f := makeRevocationFixture(c)
+
+	snapBuild := f.snapBuild(c, blobSHA3_384)
+
+	rev, err := f.revoke(c, "0", map[string]interface{}{
+		"revoked-assertions": []interface{}{
+			map[string]interface{}{
+				"type":          "snap-build",
+				"snap-sha3-384": blobSHA3_384,
+				"revision":      "0",
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Assert(err, IsNil)
+
+	err = f.db.Add(snapBuild)
+	c.Check(err, ErrorMatches, `snap-build \(.*\) has been revoked`)
+
+	// a later revision is fine
+	snapBuild1, err := f.devDB.Sign(asserts.SnapBuildType, map[string]interface{}{
+		"snap-sha3-384": blobSHA3_384,
+		"snap-id":       "snap-id-1",
+		"grade":         "stable",
+		"snap-size":     "1025",
+		"revision":      "1",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	err = f.db.Add(snapBuild1)
+	c.Assert(err, IsNil)
+	a, err := f.db.Find(asserts.SnapBuildType, map[string]string{
+		"snap-sha3-384": blobSHA3_384,
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 1)
+}
+
+func (s *revocationSuite) TestSelfSignedRevocation(c *C) {
+	f := makeRevocationFixture(c)
+	oldKeyID := testPrivKey2.PublicKey().ID()
+
+	// a leaked key cannot be used to revoke the other keys
+	rev, err := f.selfRevoke(c, "0", map[string]interface{}{
+		"revoked-keys": []interface{}{oldKeyID},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Check(rev)
+	c.Check(err, ErrorMatches, `self-signed revocation assertion for "devel1" can only revoke its own signing key, not ".*"`)
+
+	// nor assertions
+	rev, err = f.selfRevoke(c, "0", map[string]interface{}{
+		"revoked-assertions": []interface{}{
+			map[string]interface{}{
+				"type":          "snap-build",
+				"snap-sha3-384": blobSHA3_384,
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Check(rev)
+	c.Check(err, ErrorMatches, `self-signed revocation assertion for "devel1" cannot revoke snap-build \(.*\)`)
+
+	// but it can revoke itself
+	rev, err = f.selfRevoke(c, "0", map[string]interface{}{
+		"revoked-keys": []interface{}{f.newKey.PublicKeyID()},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Assert(err, IsNil)
+	_, err = f.db.Find(asserts.AccountKeyType, map[string]string{
+		"public-key-sha3-384": f.newKey.PublicKeyID(),
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+}
+
+func (s *revocationSuite) TestSelfSignedCannotWithdrawRevocations(c *C) {
+	f := makeRevocationFixture(c)
+	oldKeyID := testPrivKey2.PublicKey().ID()
+
+	revokedBuild := map[string]interface{}{
+		"type":          "snap-build",
+		"snap-sha3-384": blobSHA3_384,
+		"revision":      "3",
+	}
+	rev, err := f.revoke(c, "0", map[string]interface{}{
+		"revoked-keys":       []interface{}{oldKeyID},
+		"revoked-assertions": []interface{}{revokedBuild},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Assert(err, IsNil)
+
+	rev, err = f.selfRevoke(c, "1", map[string]interface{}{
+		"revoked-assertions": []interface{}{revokedBuild},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Check(rev)
+	c.Check(err, ErrorMatches, `revocation assertion for "devel1" cannot drop previously revoked public key ".*"`)
+
+	rev, err = f.selfRevoke(c, "1", map[string]interface{}{
+		"revoked-keys": []interface{}{oldKeyID},
+		"revoked-assertions": []interface{}{
+			map[string]interface{}{
+				"type":          "snap-build",
+				"snap-sha3-384": blobSHA3_384,
+				"revision":      "2",
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Check(rev)
+	c.Check(err, ErrorMatches, `revocation assertion for "devel1" cannot drop or narrow previously revoked snap-build \(.*\)`)
+
+	// keeping what was revoked before while revoking its own key is fine
+	rev, err = f.selfRevoke(c, "1", map[string]interface{}{
+		"revoked-keys":       []interface{}{oldKeyID, f.newKey.PublicKeyID()},
+		"revoked-assertions": []interface{}{revokedBuild},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Check(err, IsNil)
+}
+
+func (s *revocationSuite) TestTrustedAuthorityCanWithdrawRevocations(c *C) {
+	f := makeRevocationFixture(c)
+	oldKeyID := testPrivKey2.PublicKey().ID()
+
+	rev, err := f.revoke(c, "0", map[string]interface{}{
+		"revoked-keys": []interface{}{oldKeyID},
+	})
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Assert(err, IsNil)
+	_, err = f.db.Find(asserts.AccountKeyType, map[string]string{
+		"public-key-sha3-384": oldKeyID,
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	// e.g. after revoking the key by mistake
+	rev, err = f.revoke(c, "1", nil)
+	c.Assert(err, IsNil)
+	err = f.db.Add(rev)
+	c.Assert(err, IsNil)
+	_, err = f.db.Find(asserts.AccountKeyType, map[string]string{
+		"public-key-sha3-384": oldKeyID,
+	})
+	c.Check(err, IsNil)
+}
@@ -0,0 +1,291 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Presence represents the presence constraint of a snap in a validation set.
+type Presence string
+
+const (
+	// PresenceRequired means the snap must be installed.
+	PresenceRequired Presence = "required"
+	// PresenceOptional means the snap may be installed.
+	PresenceOptional Presence = "optional"
+	// PresenceInvalid means the snap must not be installed.
+	PresenceInvalid Presence = "invalid"
+)
+
+// ValidationSetSnap holds the details about a snap constrained by a
+// validation-set assertion.
+type ValidationSetSnap struct {
+	Name   string
+	SnapID string
+
+	Presence Presence
+
+	// Revision is the revision the snap must be at, if any.
+	Revision int
+	// MinRevision is the revision the snap must be at least at, if any.
+	MinRevision int
+}
+
+// ValidationSet holds a validation-set assertion, which declares a set
+// of snaps, possibly at given revisions, that must, may or must not be
+// installed together on a device.
+type ValidationSet struct {
+	assertionBase
+
+	seq       int
+	snaps     []*ValidationSetSnap
+	timestamp time.Time
+}
+
+// Series returns the series for which the snaps in the set are declared.
+func (vs *ValidationSet) Series() string {
+	return vs.HeaderString("series")
+}
+
+// AccountID returns the identifier of the account that issued the set.
+func (vs *ValidationSet) AccountID() string {
+	return vs.HeaderString("account-id")
+}
+
+// Name returns the name of the set.
+func (vs *ValidationSet) Name() string {
+	return vs.HeaderString("name")
+}
+
+// Sequence returns the sequence number of this iteration of the set.
+func (vs *ValidationSet) Sequence() int {
+	return vs.seq
+}
+
+// Snaps returns the snaps constrained by the set.
+func (vs *ValidationSet) Snaps() []*ValidationSetSnap {
+	return vs.snaps
+}
+
+// Snap returns the constraints on the snap with the given name, or nil
+// if the set does not mention it.
+func (vs *ValidationSet) Snap(name string) *ValidationSetSnap {
+	for _, sn := range vs.snaps {
+		if sn.Name == name {
+			return sn
+		}
+	}
+	return nil
+}
+
+// Timestamp returns the time when the validation-set was issued.
+func (vs *ValidationSet) Timestamp() time.Time {
+	return vs.timestamp
+}
+
+// Implement further consistency checks.
+func (vs *ValidationSet) checkConsistency(db RODatabase, acck *AccountKey) error {
+	_, err := db.Find(AccountType, map[string]string{
+		"account-id": vs.AccountID(),
+	})
+	if IsNotFound(err) {
+		return fmt.Errorf("validation-set assertion for %q does not have a matching account assertion", vs.AccountID())
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// sanity
+var _ consistencyChecker = (*ValidationSet)(nil)
+
+// Prerequisites returns references to this validation-set's prerequisite assertions.
+func (vs *ValidationSet) Prerequisites() []*Ref {
+	return []*Ref{
+		{Type: AccountType, PrimaryKey: []string{vs.AccountID()}},
+	}
+}
+
+var (
+	validValidationSetName = regexp.MustCompile("^[a-z0-9](?:-?[a-z0-9])*$")
+	validSnapName          = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+	validSequence          = regexp.MustCompile("^[1-9][0-9]*$")
+)
+
+func checkOptionalRevision(m map[string]interface{}, name, what string) (int, error) {
+	value, ok := m[name]
+	if !ok {
+		return 0, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("%q %s must be a string", name, what)
+	}
+	rev, err := strconv.Atoi(s)
+	if err != nil || rev <= 0 {
+		return 0, fmt.Errorf("%q %s must be a positive integer: %v", name, what, s)
+	}
+	return rev, nil
+}
+
+func checkValidationSetSnap(snap map[string]interface{}) (*ValidationSetSnap, error) {
+	name, err := checkNotEmptyStringWhat(snap, "name", "of snap")
+	if err != nil {
+		return nil, err
+	}
+	if !validSnapName.MatchString(name) {
+		return nil, fmt.Errorf("invalid snap name %q", name)
+	}
+	what := fmt.Sprintf("of snap %q", name)
+
+	snapID, err := checkOptionalStringWhat(snap, "id", what)
+	if err != nil {
+		return nil, err
+	}
+	if snapID != "" && !validSnapID.MatchString(snapID) {
+		return nil, fmt.Errorf("invalid snap-id %q %s", snapID, what)
+	}
+
+	presence := PresenceRequired
+	p, err := checkOptionalStringWhat(snap, "presence", what)
+	if err != nil {
+		return nil, err
+	}
+	switch Presence(p) {
+	case "":
+	case PresenceRequired, PresenceOptional, PresenceInvalid:
+		presence = Presence(p)
+	default:
+		return nil, fmt.Errorf(`"presence" %s must be one of required|optional|invalid`, what)
+	}
+
+	revision, err := checkOptionalRevision(snap, "revision", what)
+	if err != nil {
+		return nil, err
+	}
+	minRevision, err := checkOptionalRevision(snap, "min-revision", what)
+	if err != nil {
+		return nil, err
+	}
+	if revision != 0 && minRevision != 0 {
+		return nil, fmt.Errorf(`cannot specify both "revision" and "min-revision" %s`, what)
+	}
+	if presence == PresenceInvalid && (revision != 0 || minRevision != 0) {
+		return nil, fmt.Errorf("cannot specify revision %s that is invalid", what)
+	}
+
+	return &ValidationSetSnap{
+		Name:        name,
+		SnapID:      snapID,
+		Presence:    presence,
+		Revision:    revision,
+		MinRevision: minRevision,
+	}, nil
+}
+
+func checkValidationSetSnaps(headers map[string]interface{}) ([]*ValidationSetSnap, error) {
+	value, ok := headers["snaps"]
+	if !ok {
+		return nil, fmt.Errorf(`"snaps" header is mandatory`)
+	}
+	lst, ok := value.([]interface{})
+	if !ok || len(lst) == 0 {
+		return nil, fmt.Errorf(`"snaps" header must be a non-empty list of maps`)
+	}
+	snaps := make([]*ValidationSetSnap, 0, len(lst))
+	seen := make(map[string]bool, len(lst))
+	for _, v := range lst {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`"snaps" header must be a non-empty list of maps`)
+		}
+		sn, err := checkValidationSetSnap(m)
+		if err != nil {
+			return nil, err
+		}
+		if seen[sn.Name] {
+			return nil, fmt.Errorf("cannot list the same snap %q multiple times", sn.Name)
+		}
+		seen[sn.Name] = true
+		snaps = append(snaps, sn)
+	}
+	return snaps, nil
+}
+
+func assembleValidationSet(assert assertionBase) (Assertion, error) {
+	accountID, err := checkNotEmptyString(assert.headers, "account-id")
+	if err != nil {
+		return nil, err
+	}
+	if accountID != assert.AuthorityID() {
+		return nil, fmt.Errorf("authority-id and account-id must match, validation-set assertions are expected to be signed by the issuer account: %q != %q", assert.AuthorityID(), accountID)
+	}
+
+	_, err = checkStringMatches(assert.headers, "name", validValidationSetName)
+	if err != nil {
+		return nil, err
+	}
+
+	seqStr, err := checkStringMatches(assert.headers, "sequence", validSequence)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := strconv.Atoi(seqStr)
+	if err != nil {
+		// given it matched it can likely only be too large
+		return nil, fmt.Errorf("sequence too large: %s", seqStr)
+	}
+
+	snaps, err := checkValidationSetSnaps(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidationSet{
+		assertionBase: assert,
+		seq:           seq,
+		snaps:         snaps,
+		timestamp:     timestamp,
+	}, nil
+}
+
+// ParseValidationSetKey parses a "<account-id>/<name>" key for a
+// validation set.
+func ParseValidationSetKey(key string) (accountID, name string, err error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid validation set key %q, expected <account-id>/<name>", key)
+	}
+	if !validValidationSetName.MatchString(parts[1]) {
+		return "", "", fmt.Errorf("invalid validation set name %q", parts[1])
+	}
+	return parts[0], parts[1], nil
+}
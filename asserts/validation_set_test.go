@@ -0,0 +1,167 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+)
+
+var _ = Suite(&validationSetSuite{})
+
+type validationSetSuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+func (s *validationSetSuite) SetUpSuite(c *C) {
+	s.ts = time.Now().Truncate(time.Second).UTC()
+	s.tsLine = "timestamp: " + s.ts.Format(time.RFC3339) + "\n"
+}
+
+const validationSetExample = `type: validation-set
+authority-id: brand-id1
+series: 16
+account-id: brand-id1
+name: base-set
+sequence: 2
+snaps:
+  -
+    name: foo
+    id: fooidfooidfooidfooidfooidfooid12
+    revision: 5
+  -
+    name: bar
+    presence: optional
+    min-revision: 3
+  -
+    name: baz
+    presence: invalid
+TSLINE` +
+	"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+	"\n\n" +
+	"AXNpZw=="
+
+func (s *validationSetSuite) TestDecodeOK(c *C) {
+	encoded := strings.Replace(validationSetExample, "TSLINE", s.tsLine, 1)
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.ValidationSetType)
+	vs := a.(*asserts.ValidationSet)
+	c.Check(vs.AuthorityID(), Equals, "brand-id1")
+	c.Check(vs.Timestamp(), Equals, s.ts)
+	c.Check(vs.Series(), Equals, "16")
+	c.Check(vs.AccountID(), Equals, "brand-id1")
+	c.Check(vs.Name(), Equals, "base-set")
+	c.Check(vs.Sequence(), Equals, 2)
+	c.Check(vs.Snaps(), DeepEquals, []*asserts.ValidationSetSnap{
+		{Name: "foo", SnapID: "fooidfooidfooidfooidfooidfooid12", Presence: asserts.PresenceRequired, Revision: 5},
+		{Name: "bar", Presence: asserts.PresenceOptional, MinRevision: 3},
+		{Name: "baz", Presence: asserts.PresenceInvalid},
+	})
+	c.Check(vs.Snap("bar"), Equals, vs.Snaps()[1])
+	c.Check(vs.Snap("other"), IsNil)
+	c.Check(vs.Prerequisites(), DeepEquals, []*asserts.Ref{
+		{Type: asserts.AccountType, PrimaryKey: []string{"brand-id1"}},
+	})
+}
+
+const validationSetErrPrefix = "assertion validation-set: "
+
+func (s *validationSetSuite) TestDecodeInvalid(c *C) {
+	encoded := strings.Replace(validationSetExample, "TSLINE", s.tsLine, 1)
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"account-id: brand-id1\n", "", `"account-id" header is mandatory`},
+		{"account-id: brand-id1\n", "account-id: other\n", `authority-id and account-id must match, validation-set assertions are expected to be signed by the issuer account: "brand-id1" != "other"`},
+		{"name: base-set\n", "", `"name" header is mandatory`},
+		{"name: base-set\n", "name: Base\n", `"name" header contains invalid characters: "Base"`},
+		{"sequence: 2\n", "", `"sequence" header is mandatory`},
+		{"sequence: 2\n", "sequence: 0\n", `"sequence" header contains invalid characters: "0"`},
+		{"sequence: 2\n", "sequence: 99999999999999999999\n", `sequence too large: 99999999999999999999`},
+		{"snaps:\n", "snaps: foo\nx:\n", `"snaps" header must be a non-empty list of maps`},
+		{"  -\n    name: foo\n", "  - foo\n  -\n    name: foo\n", `"snaps" header must be a non-empty list of maps`},
+		{"    name: foo\n", "", `"name" of snap is mandatory`},
+		{"    name: foo\n", "    name: Foo\n", `invalid snap name "Foo"`},
+		{"    name: bar\n", "    name: foo\n", `cannot list the same snap "foo" multiple times`},
+		{"    id: fooidfooidfooidfooidfooidfooid12\n", "    id: foo\n", `invalid snap-id "foo" of snap "foo"`},
+		{"    presence: optional\n", "    presence: maybe\n", `"presence" of snap "bar" must be one of required\|optional\|invalid`},
+		{"    revision: 5\n", "    revision: x\n", `"revision" of snap "foo" must be a positive integer: x`},
+		{"    revision: 5\n", "    revision: 0\n", `"revision" of snap "foo" must be a positive integer: 0`},
+		{"    min-revision: 3\n", "    min-revision: -1\n", `"min-revision" of snap "bar" must be a positive integer: -1`},
+		{"    revision: 5\n", "    revision: 5\n    min-revision: 3\n", `cannot specify both "revision" and "min-revision" of snap "foo"`},
+		{"    presence: invalid\n", "    presence: invalid\n    revision: 1\n", `cannot specify revision of snap "baz" that is invalid`},
+		{s.tsLine, "", `"timestamp" header is mandatory`},
+		{s.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, validationSetErrPrefix+test.expectedErr)
+	}
+}
+
+func (s *validationSetSuite) TestCheckAccount(c *C) {
+	storeDB, db := makeStoreAndCheckDB(c)
+	brandDB := setup3rdPartySigning(c, "brand1", storeDB, db)
+
+	headers := map[string]interface{}{
+		"series":     "16",
+		"account-id": "brand1",
+		"name":       "base-set",
+		"sequence":   "1",
+		"snaps": []interface{}{
+			map[string]interface{}{"name": "foo"},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	vs, err := brandDB.Sign(asserts.ValidationSetType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = db.Check(vs)
+	c.Check(err, IsNil)
+
+	// another account without an account assertion
+	otherDB := assertstest.NewSigningDB("other", testPrivKey2)
+	headers["account-id"] = "other"
+	vs, err = otherDB.Sign(asserts.ValidationSetType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = db.Check(vs)
+	c.Check(err, NotNil)
+}
+
+func (s *validationSetSuite) TestParseValidationSetKey(c *C) {
+	accountID, name, err := asserts.ParseValidationSetKey("brand1/base-set")
+	c.Assert(err, IsNil)
+	c.Check(accountID, Equals, "brand1")
+	c.Check(name, Equals, "base-set")
+
+	for _, key := range []string{"", "brand1", "brand1/", "/base-set", "a/b/c"} {
+		_, _, err = asserts.ParseValidationSetKey(key)
+		c.Check(err, ErrorMatches, `invalid validation set key .*, expected <account-id>/<name>`, Commentf(key))
+	}
+	_, _, err = asserts.ParseValidationSetKey("brand1/Base")
+	c.Check(err, ErrorMatches, `invalid validation set name "Base"`)
+}
@@ -29,6 +29,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/snapcore/snapd/snap"
 )
 
 // AppInfo describes a single snap application.
@@ -39,6 +41,17 @@ type AppInfo struct {
 	Daemon      string `json:"daemon,omitempty"`
 	Enabled     bool   `json:"enabled,omitempty"`
 	Active      bool   `json:"active,omitempty"`
+
+	Timer     *AppTimer            `json:"timer,omitempty"`
+	Resources *snap.ResourceLimits `json:"resources,omitempty"`
+}
+
+// AppTimer describes the timer activating a snap service.
+type AppTimer struct {
+	Schedule string    `json:"schedule"`
+	Enabled  bool      `json:"enabled,omitempty"`
+	Active   bool      `json:"active,omitempty"`
+	Next     time.Time `json:"next,omitempty"`
 }
 
 // IsService returns true if the application is a background daemon.
@@ -57,6 +57,17 @@ func (client *Client) AssertionTypes() ([]string, error) {
 
 // Known queries assertions with type assertTypeName and matching assertion headers.
 func (client *Client) Known(assertTypeName string, headers map[string]string) ([]asserts.Assertion, error) {
+	return client.known(assertTypeName, headers, false)
+}
+
+// KnownRevoked queries assertions with type assertTypeName and
+// matching assertion headers that have been revoked or are signed
+// with a revoked key.
+func (client *Client) KnownRevoked(assertTypeName string, headers map[string]string) ([]asserts.Assertion, error) {
+	return client.known(assertTypeName, headers, true)
+}
+
+func (client *Client) known(assertTypeName string, headers map[string]string, revoked bool) ([]asserts.Assertion, error) {
 	path := fmt.Sprintf("/v2/assertions/%s", assertTypeName)
 	q := url.Values{}
 
@@ -65,6 +76,9 @@ func (client *Client) Known(assertTypeName string, headers map[string]string) ([
 			q.Set(k, v)
 		}
 	}
+	if revoked {
+		q.Set("revoked", "true")
+	}
 
 	response, err := client.raw("GET", path, q, nil, nil)
 	if err != nil {
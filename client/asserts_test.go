@@ -79,6 +79,19 @@ func (cs *clientSuite) TestClientAssertsCallsEndpointWithFilter(c *C) {
 	})
 }
 
+func (cs *clientSuite) TestClientAssertsRevokedCallsEndpoint(c *C) {
+	_, _ = cs.cli.KnownRevoked("account-key", map[string]string{
+		"account-id": "acc-id1",
+	})
+	u, err := url.ParseRequestURI(cs.req.URL.String())
+	c.Assert(err, IsNil)
+	c.Check(u.Path, Equals, "/v2/assertions/account-key")
+	c.Check(u.Query(), DeepEquals, url.Values{
+		"account-id": []string{"acc-id1"},
+		"revoked":    []string{"true"},
+	})
+}
+
 func (cs *clientSuite) TestClientAssertsHttpError(c *C) {
 	cs.err = errors.New("fail")
 	_, err := cs.cli.Known("snap-build", nil)
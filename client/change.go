@@ -63,10 +63,19 @@ type Task struct {
 	Log      []string     `json:"log,omitempty"`
 	Progress TaskProgress `json:"progress"`
 
+	// StatusChanges is only set for archived changes.
+	StatusChanges []TaskStatusChange `json:"status-changes,omitempty"`
+
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
 	ReadyTime time.Time `json:"ready-time,omitempty"`
 }
 
+// A TaskStatusChange records when a task was set to a given status.
+type TaskStatusChange struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
 type TaskProgress struct {
 	Label string `json:"label"`
 	Done  int    `json:"done"`
@@ -120,6 +129,8 @@ func (c ChangeSelector) String() string {
 		return "ready"
 	case ChangesAll:
 		return "all"
+	case ChangesArchived:
+		return "archived"
 	}
 
 	panic(fmt.Sprintf("unknown ChangeSelector %d", c))
@@ -128,11 +139,15 @@ func (c ChangeSelector) String() string {
 const (
 	ChangesInProgress ChangeSelector = 1 << iota
 	ChangesReady
+	// ChangesArchived selects the changes that were pruned from the
+	// system state and archived
+	ChangesArchived
 	ChangesAll = ChangesReady | ChangesInProgress
 )
 
 type ChangesOptions struct {
-	SnapName string // if empty, no filtering by name is done
+	SnapName string    // if empty, no filtering by name is done
+	Since    time.Time // if zero, no filtering by spawn time is done
 	Selector ChangeSelector
 }
 
@@ -145,6 +160,9 @@ func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
 		if opts.SnapName != "" {
 			query.Set("for", opts.SnapName)
 		}
+		if !opts.Since.IsZero() {
+			query.Set("since", opts.Since.Format(time.RFC3339))
+		}
 	}
 
 	var chgds []changeAndData
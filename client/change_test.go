@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"io/ioutil"
+	"net/url"
 	"time"
 )
 
@@ -116,6 +117,7 @@ func (cs *clientSuite) TestClientChangesString(c *check.C) {
 		client.ChangesAll:        "all",
 		client.ChangesReady:      "ready",
 		client.ChangesInProgress: "in-progress",
+		client.ChangesArchived:   "archived",
 	} {
 		c.Check(k.String(), check.Equals, v)
 	}
@@ -160,6 +162,34 @@ func (cs *clientSuite) TestClientChanges(c *check.C) {
 
 }
 
+func (cs *clientSuite) TestClientChangesArchivedSince(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Done",
+  "ready": true,
+  "tasks": [{"kind": "bar", "summary": "...", "status": "Done", "progress": {"done": 0, "total": 0},
+             "status-changes": [{"status": "Doing", "time": "2016-04-21T01:02:03Z"}, {"status": "Done", "time": "2016-04-21T01:02:04Z"}]}]
+}]}`
+
+	chgs, err := cs.cli.Changes(&client.ChangesOptions{
+		Selector: client.ChangesArchived,
+		Since:    time.Date(2016, 4, 21, 0, 0, 0, 0, time.UTC),
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": []string{"archived"},
+		"since":  []string{"2016-04-21T00:00:00Z"},
+	})
+	c.Assert(chgs, check.HasLen, 1)
+	c.Assert(chgs[0].Tasks, check.HasLen, 1)
+	c.Check(chgs[0].Tasks[0].StatusChanges, check.DeepEquals, []client.TaskStatusChange{
+		{Status: "Doing", Time: time.Date(2016, 4, 21, 1, 2, 3, 0, time.UTC)},
+		{Status: "Done", Time: time.Date(2016, 4, 21, 1, 2, 4, 0, time.UTC)},
+	})
+}
+
 func (cs *clientSuite) TestClientChangesData(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": [{
   "id":   "uno",
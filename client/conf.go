@@ -48,3 +48,19 @@ func (client *Client) Conf(snapName string, keys []string) (configuration map[st
 
 	return configuration, nil
 }
+
+// ConfSchema asks for the declarations of a snap's configuration
+// options, either all of them or only those of the given keys.
+func (client *Client) ConfSchema(snapName string, keys []string) (schema map[string]interface{}, err error) {
+	// Prepare query
+	query := url.Values{}
+	query.Set("schema", "true")
+	query.Set("keys", strings.Join(keys, ","))
+
+	_, err = client.doSync("GET", "/v2/snaps/"+snapName+"/conf", query, nil, nil, &schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
@@ -102,3 +102,20 @@ func (cs *clientSuite) TestClientGetConfMultipleKeys(c *check.C) {
 		"test-key2": "test-value2",
 	})
 }
+
+func (cs *clientSuite) TestClientGetConfSchema(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"port": {"type": "integer", "default": 8080}}
+	}`
+	schema, err := cs.cli.ConfSchema("snap-name", []string{"port"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf")
+	c.Check(cs.req.URL.Query().Get("schema"), check.Equals, "true")
+	c.Check(cs.req.URL.Query().Get("keys"), check.Equals, "port")
+	c.Check(schema, check.DeepEquals, map[string]interface{}{
+		"port": map[string]interface{}{"type": "integer", "default": json.Number("8080")},
+	})
+}
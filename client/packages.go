@@ -58,6 +58,7 @@ type Snap struct {
 	Broken           string        `json:"broken,omitempty"`
 	Contact          string        `json:"contact"`
 	License          string        `json:"license,omitempty"`
+	Health           *SnapHealth   `json:"health,omitempty"`
 
 	Prices      map[string]float64 `json:"prices,omitempty"`
 	Screenshots []Screenshot       `json:"screenshots,omitempty"`
@@ -69,6 +70,15 @@ type Snap struct {
 	Tracks []string `json:"tracks,omitempty"`
 }
 
+// SnapHealth is the health last reported by a snap.
+type SnapHealth struct {
+	Revision  snap.Revision `json:"revision"`
+	Timestamp time.Time     `json:"timestamp"`
+	Status    string        `json:"status"`
+	Message   string        `json:"message,omitempty"`
+	Code      string        `json:"code,omitempty"`
+}
+
 func (s *Snap) MarshalJSON() ([]byte, error) {
 	type auxSnap Snap // use auxiliary type so that Go does not call Snap.MarshalJSON()
 	// separate type just for marshalling
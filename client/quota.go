@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// QuotaGroupResult describes a quota group limiting the combined
+// resources of a set of snaps.
+type QuotaGroupResult struct {
+	GroupName string   `json:"group-name"`
+	Parent    string   `json:"parent,omitempty"`
+	Subgroups []string `json:"subgroups,omitempty"`
+	Snaps     []string `json:"snaps,omitempty"`
+	MaxMemory int64    `json:"max-memory,omitempty"`
+	CPUQuota  int      `json:"cpu-quota,omitempty"`
+}
+
+type postQuotaGroupData struct {
+	Action    string   `json:"action"`
+	GroupName string   `json:"group-name"`
+	Parent    string   `json:"parent,omitempty"`
+	Snaps     []string `json:"snaps,omitempty"`
+	MaxMemory int64    `json:"max-memory,omitempty"`
+	CPUQuota  int      `json:"cpu-quota,omitempty"`
+}
+
+func (client *Client) postQuotaGroup(data *postQuotaGroupData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	_, err = client.doSync("POST", "/v2/quotas", nil, headers, bytes.NewReader(b), nil)
+	return err
+}
+
+// EnsureQuota creates the quota group, under the parent group if
+// given, or updates it if it exists: the snaps are added to the group
+// and the non-zero limits replace the current ones.
+func (client *Client) EnsureQuota(groupName string, parent string, snaps []string, maxMemory int64, cpuQuota int) error {
+	if groupName == "" {
+		return fmt.Errorf("cannot create or update quota group without a name")
+	}
+	return client.postQuotaGroup(&postQuotaGroupData{
+		Action:    "ensure",
+		GroupName: groupName,
+		Parent:    parent,
+		Snaps:     snaps,
+		MaxMemory: maxMemory,
+		CPUQuota:  cpuQuota,
+	})
+}
+
+// RemoveQuotaGroup removes the quota group.
+func (client *Client) RemoveQuotaGroup(groupName string) error {
+	if groupName == "" {
+		return fmt.Errorf("cannot remove quota group without a name")
+	}
+	return client.postQuotaGroup(&postQuotaGroupData{
+		Action:    "remove",
+		GroupName: groupName,
+	})
+}
+
+// GetQuotaGroup returns the quota group with the given name.
+func (client *Client) GetQuotaGroup(groupName string) (*QuotaGroupResult, error) {
+	if groupName == "" {
+		return nil, fmt.Errorf("cannot get quota group without a name")
+	}
+	var res *QuotaGroupResult
+	if _, err := client.doSync("GET", "/v2/quotas/"+groupName, nil, nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Quotas returns all the quota groups, sorted by name.
+func (client *Client) Quotas() ([]*QuotaGroupResult, error) {
+	var res []*QuotaGroupResult
+	if _, err := client.doSync("GET", "/v2/quotas", nil, nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestEnsureQuota(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": null
+	}`
+	err := cs.cli.EnsureQuota("foo", "bar", []string{"snap-a", "snap-b"}, 1001, 50)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action":     "ensure",
+		"group-name": "foo",
+		"parent":     "bar",
+		"snaps":      []interface{}{"snap-a", "snap-b"},
+		"max-memory": float64(1001),
+		"cpu-quota":  float64(50),
+	})
+}
+
+func (cs *clientSuite) TestEnsureQuotaNoName(c *check.C) {
+	err := cs.cli.EnsureQuota("", "", nil, 1001, 0)
+	c.Check(err, check.ErrorMatches, `cannot create or update quota group without a name`)
+}
+
+func (cs *clientSuite) TestRemoveQuotaGroup(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": null
+	}`
+	err := cs.cli.RemoveQuotaGroup("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action":     "remove",
+		"group-name": "foo",
+	})
+}
+
+func (cs *clientSuite) TestGetQuotaGroup(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"group-name": "foo", "parent": "bar", "subgroups": ["baz"], "snaps": ["snap-a"], "max-memory": 1000, "cpu-quota": 20}
+	}`
+	grp, err := cs.cli.GetQuotaGroup("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas/foo")
+	c.Check(grp, check.DeepEquals, &client.QuotaGroupResult{
+		GroupName: "foo",
+		Parent:    "bar",
+		Subgroups: []string{"baz"},
+		Snaps:     []string{"snap-a"},
+		MaxMemory: 1000,
+		CPUQuota:  20,
+	})
+}
+
+func (cs *clientSuite) TestQuotas(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{"group-name": "bar", "max-memory": 1000}, {"group-name": "foo", "cpu-quota": 20}]
+	}`
+	groups, err := cs.cli.Quotas()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
+	c.Check(groups, check.DeepEquals, []*client.QuotaGroupResult{
+		{GroupName: "bar", MaxMemory: 1000},
+		{GroupName: "foo", CPUQuota: 20},
+	})
+}
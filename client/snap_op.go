@@ -39,8 +39,24 @@ type SnapOptions struct {
 	Dangerous        bool   `json:"dangerous,omitempty"`
 	IgnoreValidation bool   `json:"ignore-validation,omitempty"`
 	Unaliased        bool   `json:"unaliased,omitempty"`
+	Purge            bool   `json:"purge,omitempty"`
+	Queue            bool   `json:"queue,omitempty"`
+
+	Transaction TransactionType `json:"transaction,omitempty"`
 }
 
+// TransactionType specifies how failures are handled by operations
+// on multiple snaps.
+type TransactionType string
+
+const (
+	// TransactionPerSnap undoes only the snaps the operation failed for.
+	TransactionPerSnap TransactionType = "per-snap"
+	// TransactionAllSnaps undoes the operation on all snaps if it
+	// fails for any of them.
+	TransactionAllSnaps TransactionType = "all-snaps"
+)
+
 func (opts *SnapOptions) writeModeFields(mw *multipart.Writer) error {
 	fields := []struct {
 		f string
@@ -74,6 +90,10 @@ type actionData struct {
 type multiActionData struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
+	Purge  bool     `json:"purge,omitempty"`
+	Queue  bool     `json:"queue,omitempty"`
+
+	Transaction TransactionType `json:"transaction,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -147,12 +167,23 @@ func (client *Client) doSnapAction(actionName string, snapName string, options *
 }
 
 func (client *Client) doMultiSnapAction(actionName string, snaps []string, options *SnapOptions) (changeID string, err error) {
+	var purge, queue bool
+	var transaction TransactionType
 	if options != nil {
-		return "", fmt.Errorf("cannot use options for multi-action") // (yet)
+		// only purge, queue and transaction are supported for multi-action (yet)
+		if *options != (SnapOptions{Purge: options.Purge, Queue: options.Queue, Transaction: options.Transaction}) {
+			return "", fmt.Errorf("cannot use options for multi-action")
+		}
+		purge = options.Purge
+		queue = options.Queue
+		transaction = options.Transaction
 	}
 	action := multiActionData{
-		Action: actionName,
-		Snaps:  snaps,
+		Action:      actionName,
+		Snaps:       snaps,
+		Purge:       purge,
+		Queue:       queue,
+		Transaction: transaction,
 	}
 	data, err := json.Marshal(&action)
 	if err != nil {
@@ -182,7 +213,32 @@ func (client *Client) InstallPath(path string, options *SnapOptions) (changeID s
 
 	pr, pw := io.Pipe()
 	mw := multipart.NewWriter(pw)
-	go sendSnapFile(path, f, pw, mw, &action)
+	go sendSnapFile("snap", path, f, pw, mw, &action)
+
+	headers := map[string]string{
+		"Content-Type": mw.FormDataContentType(),
+	}
+
+	return client.doAsync("POST", "/v2/snaps", nil, headers, pr)
+}
+
+// InstallBundle sideloads the offline bundle with the given path,
+// adding its assertions to the system and installing all its snaps in
+// one background operation, returning its UUID upon success.
+func (client *Client) InstallBundle(path string, options *SnapOptions) (changeID string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open: %q", path)
+	}
+
+	action := actionData{
+		Action:      "install",
+		SnapOptions: options,
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go sendSnapFile("bundle", path, f, pw, mw, &action)
 
 	headers := map[string]string{
 		"Content-Type": mw.FormDataContentType(),
@@ -214,7 +270,7 @@ func (client *Client) Try(path string, options *SnapOptions) (changeID string, e
 	return client.doAsync("POST", "/v2/snaps", nil, headers, buf)
 }
 
-func sendSnapFile(snapPath string, snapFile *os.File, pw *io.PipeWriter, mw *multipart.Writer, action *actionData) {
+func sendSnapFile(field, snapPath string, snapFile *os.File, pw *io.PipeWriter, mw *multipart.Writer, action *actionData) {
 	defer snapFile.Close()
 
 	if action.SnapOptions == nil {
@@ -244,7 +300,7 @@ func sendSnapFile(snapPath string, snapFile *os.File, pw *io.PipeWriter, mw *mul
 		return
 	}
 
-	fw, err := mw.CreateFormFile("snap", filepath.Base(snapPath))
+	fw, err := mw.CreateFormFile(field, filepath.Base(snapPath))
 	if err != nil {
 		pw.CloseWithError(err)
 		return
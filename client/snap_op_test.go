@@ -172,6 +172,97 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientRemoveManyPurge(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RemoveMany([]string{pkgName}, &client.SnapOptions{Purge: true})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action": "remove",
+		"snaps":  []interface{}{pkgName},
+		"purge":  true,
+	})
+}
+
+func (cs *clientSuite) TestClientOpSnapQueue(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	for _, s := range ops {
+		_, err := s.op(cs.cli, pkgName, &client.SnapOptions{Queue: true})
+		c.Assert(err, check.IsNil)
+
+		body, err := ioutil.ReadAll(cs.req.Body)
+		c.Assert(err, check.IsNil, check.Commentf(s.action))
+		jsonBody := make(map[string]interface{})
+		c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil, check.Commentf(s.action))
+		c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+			"action": s.action,
+			"queue":  true,
+		}, check.Commentf(s.action))
+	}
+}
+
+func (cs *clientSuite) TestClientMultiOpSnapQueue(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	for _, s := range multiOps {
+		_, err := s.op(cs.cli, []string{pkgName}, &client.SnapOptions{Queue: true})
+		c.Assert(err, check.IsNil)
+
+		body, err := ioutil.ReadAll(cs.req.Body)
+		c.Assert(err, check.IsNil, check.Commentf(s.action))
+		jsonBody := make(map[string]interface{})
+		c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil, check.Commentf(s.action))
+		c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+			"action": s.action,
+			"snaps":  []interface{}{pkgName},
+			"queue":  true,
+		}, check.Commentf(s.action))
+	}
+}
+
+func (cs *clientSuite) TestClientMultiOpSnapTransaction(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	_, err := cs.cli.RefreshMany([]string{pkgName}, &client.SnapOptions{Transaction: client.TransactionAllSnaps})
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":      "refresh",
+		"snaps":       []interface{}{pkgName},
+		"transaction": "all-snaps",
+	})
+}
+
+func (cs *clientSuite) TestClientMultiOpSnapOptions(c *check.C) {
+	for _, s := range multiOps {
+		_, err := s.op(cs.cli, []string{pkgName}, &client.SnapOptions{Channel: chanName})
+		c.Check(err, check.ErrorMatches, "cannot use options for multi-action", check.Commentf(s.action))
+	}
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.rsp = `{
 		"change": "66b3",
@@ -199,6 +290,35 @@ func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	c.Check(id, check.Equals, "66b3")
 }
 
+func (cs *clientSuite) TestClientOpInstallBundle(c *check.C) {
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+	bodyData := []byte("bundle-data")
+
+	bundle := filepath.Join(c.MkDir(), "offline.bundle")
+	err := ioutil.WriteFile(bundle, bodyData, 0644)
+	c.Assert(err, check.IsNil)
+
+	id, err := cs.cli.InstallBundle(bundle, &client.SnapOptions{DevMode: true})
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"bundle\"; filename=\"offline.bundle\"\r\n.*\r\nbundle-data\r\n.*")
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"action\"\r\n\r\ninstall\r\n.*")
+	c.Assert(string(body), check.Matches, "(?s).*Content-Disposition: form-data; name=\"devmode\"\r\n\r\ntrue\r\n.*")
+	c.Check(string(body), check.Not(check.Matches), "(?s).*name=\"snap-path\".*")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Assert(cs.req.Header.Get("Content-Type"), check.Matches, "multipart/form-data; boundary=.*")
+	c.Check(id, check.Equals, "66b3")
+}
+
 func (cs *clientSuite) TestClientOpInstallDangerous(c *check.C) {
 	cs.rsp = `{
 		"change": "66b3",
@@ -40,6 +40,16 @@ type snapctlOutput struct {
 	Stderr string `json:"stderr"`
 }
 
+// UnsuccessfulError is returned by RunSnapctl when the command ran
+// but wants to exit with a non-zero exit code.
+type UnsuccessfulError struct {
+	ExitCode int
+}
+
+func (e *UnsuccessfulError) Error() string {
+	return fmt.Sprintf("snapctl unsuccessful with exit code: %d", e.ExitCode)
+}
+
 // RunSnapctl requests a snapctl run for the given options.
 func (client *Client) RunSnapctl(options *SnapCtlOptions) (stdout, stderr []byte, err error) {
 	b, err := json.Marshal(options)
@@ -50,8 +60,26 @@ func (client *Client) RunSnapctl(options *SnapCtlOptions) (stdout, stderr []byte
 	var output snapctlOutput
 	_, err = client.doSync("POST", "/v2/snapctl", nil, nil, bytes.NewReader(b), &output)
 	if err != nil {
+		if e, ok := err.(*Error); ok && e.Kind == ErrorKindUnsuccessful {
+			return unsuccessfulSnapctl(e)
+		}
 		return nil, nil, err
 	}
 
 	return []byte(output.Stdout), []byte(output.Stderr), nil
 }
+
+func unsuccessfulSnapctl(e *Error) (stdout, stderr []byte, err error) {
+	var value struct {
+		snapctlOutput
+		ExitCode int `json:"exit-code"`
+	}
+	b, err := json.Marshal(e.Value)
+	if err != nil {
+		return nil, nil, e
+	}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return nil, nil, e
+	}
+	return []byte(value.Stdout), []byte(value.Stderr), &UnsuccessfulError{ExitCode: value.ExitCode}
+}
@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientSnapshotIsValid(c *check.C) {
+	now := time.Now()
+	revision := snap.R(1)
+	sums := map[string]string{"user/foo.tgz": "some long hash"}
+	c.Check((&client.Snapshot{
+		SetID:    42,
+		Time:     now,
+		Snap:     "asnap",
+		Revision: revision,
+		SHA3_384: sums,
+	}).IsValid(), check.Equals, true)
+
+	for desc, snapshot := range map[string]*client.Snapshot{
+		"nil":     nil,
+		"empty":   {},
+		"no id":   { /*SetID: 42,*/ Time: now, Snap: "asnap", Revision: revision, SHA3_384: sums},
+		"no time": {SetID: 42 /*Time: now,*/, Snap: "asnap", Revision: revision, SHA3_384: sums},
+		"no snap": {SetID: 42, Time: now /*Snap: "asnap",*/, Revision: revision, SHA3_384: sums},
+		"no rev":  {SetID: 42, Time: now, Snap: "asnap" /*Revision: revision,*/, SHA3_384: sums},
+		"no sums": {SetID: 42, Time: now, Snap: "asnap", Revision: revision /*SHA3_384: sums*/},
+	} {
+		c.Check(snapshot.IsValid(), check.Equals, false, check.Commentf("%s", desc))
+	}
+}
+
+func (cs *clientSuite) TestClientSnapshotSetTime(c *check.C) {
+	// if set is empty, it doesn't explode (and returns the zero time)
+	c.Check(client.SnapshotSet{}.Time().IsZero(), check.Equals, true)
+	// if not empty, returns the earliest one
+	c.Check(client.SnapshotSet{Snapshots: []*client.Snapshot{
+		{Time: time.Unix(3, 0)},
+		{Time: time.Unix(1, 0)},
+		{Time: time.Unix(2, 0)},
+	}}.Time(), check.DeepEquals, time.Unix(1, 0))
+}
+
+func (cs *clientSuite) TestClientSnapshotSetSize(c *check.C) {
+	// if set is empty, doesn't explode (and returns 0)
+	c.Check(client.SnapshotSet{}.Size(), check.Equals, int64(0))
+	// if not empty, returns the sum
+	c.Check(client.SnapshotSet{Snapshots: []*client.Snapshot{
+		{Size: 1},
+		{Size: 2},
+		{Size: 3},
+	}}.Size(), check.DeepEquals, int64(6))
+}
+
+func (cs *clientSuite) TestClientSnapshotSets(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [{"id": 1}, {"id": 2}]
+	}`
+	sets, err := cs.cli.SnapshotSets(42, []string{"foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.DeepEquals, []client.SnapshotSet{{ID: 1}, {ID: 2}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"set":   []string{"42"},
+		"snaps": []string{"foo,bar"},
+	})
+}
+
+func (cs *clientSuite) TestClientSnapshotMany(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": {"set-id": 42},
+		"change": "chgid"
+	}`
+	setID, changeID, err := cs.cli.SnapshotMany([]string{"foo", "bar"}, []string{"alice"})
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(42))
+	c.Check(changeID, check.Equals, "chgid")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "snapshot",
+		"snaps":  []interface{}{"foo", "bar"},
+		"users":  []interface{}{"alice"},
+	})
+}
+
+func (cs *clientSuite) testClientSnapshotAction(c *check.C, action string, f func(uint64, []string, []string) (string, error)) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": null,
+		"change": "1here"
+	}`
+
+	setID := uint64(42)
+	snaps := []string{"asnap", "bsnap"}
+	users := []string{"auser", "buser"}
+
+	chgID, err := f(setID, snaps, users)
+	c.Assert(err, check.IsNil)
+	c.Check(chgID, check.Equals, "1here")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	expected := map[string]interface{}{
+		"action": action,
+		"set":    42.,
+		"snaps":  []interface{}{"asnap", "bsnap"},
+		"users":  []interface{}{"auser", "buser"},
+	}
+	if action == "forget" {
+		delete(expected, "users")
+	}
+	c.Check(body, check.DeepEquals, expected)
+}
+
+func (cs *clientSuite) TestClientForgetSnapshot(c *check.C) {
+	cs.testClientSnapshotAction(c, "forget", func(setID uint64, snaps, _ []string) (string, error) {
+		return cs.cli.ForgetSnapshots(setID, snaps)
+	})
+}
+
+func (cs *clientSuite) TestClientCheckSnapshots(c *check.C) {
+	cs.testClientSnapshotAction(c, "check", cs.cli.CheckSnapshots)
+}
+
+func (cs *clientSuite) TestClientRestoreSnapshots(c *check.C) {
+	cs.testClientSnapshotAction(c, "restore", cs.cli.RestoreSnapshots)
+}
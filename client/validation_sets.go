@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ValidationSetResult describes a validation set tracked by the
+// system and whether the installed snaps comply with it.
+type ValidationSetResult struct {
+	AccountID string   `json:"account-id"`
+	Name      string   `json:"name"`
+	Mode      string   `json:"mode"`
+	Sequence  int      `json:"sequence"`
+	Pinned    bool     `json:"pinned,omitempty"`
+	Valid     bool     `json:"valid"`
+	Problems  []string `json:"problems,omitempty"`
+}
+
+type postValidationSetData struct {
+	Action   string `json:"action"`
+	Mode     string `json:"mode,omitempty"`
+	Sequence int    `json:"sequence,omitempty"`
+}
+
+func validationSetPath(accountID, name string) string {
+	return fmt.Sprintf("/v2/validation-sets/%s/%s", accountID, name)
+}
+
+func (client *Client) postValidationSet(accountID, name string, data *postValidationSetData, res interface{}) error {
+	if accountID == "" || name == "" {
+		return fmt.Errorf("cannot %s validation set without account-id and name", data.Action)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	_, err = client.doSync("POST", validationSetPath(accountID, name), nil, headers, bytes.NewReader(b), res)
+	return err
+}
+
+// ApplyValidationSet starts tracking the given validation set in the
+// given mode, either "monitor" or "enforce". A sequence of 0 means the
+// latest sequence known to the system.
+func (client *Client) ApplyValidationSet(accountID, name, mode string, sequence int) (*ValidationSetResult, error) {
+	var res *ValidationSetResult
+	err := client.postValidationSet(accountID, name, &postValidationSetData{
+		Action:   "apply",
+		Mode:     mode,
+		Sequence: sequence,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ForgetValidationSet stops tracking the given validation set.
+func (client *Client) ForgetValidationSet(accountID, name string) error {
+	return client.postValidationSet(accountID, name, &postValidationSetData{
+		Action: "forget",
+	}, nil)
+}
+
+// ValidationSet returns the given tracked validation set.
+func (client *Client) ValidationSet(accountID, name string) (*ValidationSetResult, error) {
+	if accountID == "" || name == "" {
+		return nil, fmt.Errorf("cannot get validation set without account-id and name")
+	}
+	var res *ValidationSetResult
+	if _, err := client.doSync("GET", validationSetPath(accountID, name), nil, nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ValidationSets returns all the tracked validation sets, sorted by
+// account-id and name.
+func (client *Client) ValidationSets() ([]*ValidationSetResult, error) {
+	var res []*ValidationSetResult
+	if _, err := client.doSync("GET", "/v2/validation-sets", nil, nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,111 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestApplyValidationSet(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"account-id": "acc", "name": "base", "mode": "enforce", "sequence": 3, "pinned": true, "valid": true}
+	}`
+	res, err := cs.cli.ApplyValidationSet("acc", "base", "enforce", 3)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/acc/base")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action":   "apply",
+		"mode":     "enforce",
+		"sequence": float64(3),
+	})
+	c.Check(res, check.DeepEquals, &client.ValidationSetResult{
+		AccountID: "acc",
+		Name:      "base",
+		Mode:      "enforce",
+		Sequence:  3,
+		Pinned:    true,
+		Valid:     true,
+	})
+}
+
+func (cs *clientSuite) TestApplyValidationSetNoName(c *check.C) {
+	_, err := cs.cli.ApplyValidationSet("acc", "", "monitor", 0)
+	c.Check(err, check.ErrorMatches, `cannot apply validation set without account-id and name`)
+}
+
+func (cs *clientSuite) TestForgetValidationSet(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": null
+	}`
+	err := cs.cli.ForgetValidationSet("acc", "base")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/acc/base")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "forget",
+	})
+}
+
+func (cs *clientSuite) TestValidationSet(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"account-id": "acc", "name": "base", "mode": "monitor", "sequence": 2, "valid": false, "problems": ["snap \"foo\" is required but not installed"]}
+	}`
+	res, err := cs.cli.ValidationSet("acc", "base")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/acc/base")
+	c.Check(res, check.DeepEquals, &client.ValidationSetResult{
+		AccountID: "acc",
+		Name:      "base",
+		Mode:      "monitor",
+		Sequence:  2,
+		Problems:  []string{`snap "foo" is required but not installed`},
+	})
+}
+
+func (cs *clientSuite) TestValidationSets(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{"account-id": "acc", "name": "base", "mode": "monitor", "sequence": 2, "valid": true}]
+	}`
+	res, err := cs.cli.ValidationSets()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets")
+	c.Check(res, check.DeepEquals, []*client.ValidationSetResult{
+		{AccountID: "acc", Name: "base", Mode: "monitor", Sequence: 2, Valid: true},
+	})
+}
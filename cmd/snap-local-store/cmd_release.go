@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/store/localstore"
+)
+
+func init() {
+	const (
+		short = "Release a snap revision to a channel"
+		long  = `
+The release command records in <dir>/index.json that the given
+revision of the snap is served in the given channel.
+`
+	)
+
+	if _, err := parser.AddCommand("release", short, long, &cmdRelease{}); err != nil {
+		panic(err)
+	}
+}
+
+type cmdRelease struct {
+	Dir        string `long:"dir" default:"." description:"Directory with the snaps"`
+	Positional struct {
+		Snap     string `positional-arg-name:"<snap>"`
+		Revision int    `positional-arg-name:"<revision>"`
+		Channel  string `positional-arg-name:"<channel>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *cmdRelease) Execute(args []string) error {
+	snapName := x.Positional.Snap
+	if err := localstore.Release(x.Dir, snapName, x.Positional.Revision, x.Positional.Channel); err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "Released %s revision %d to %s\n", snapName, x.Positional.Revision, x.Positional.Channel)
+	return nil
+}
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/snapcore/snapd/cmd"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/store/localstore"
+)
+
+func init() {
+	const (
+		short = "Run the store server"
+		long  = ""
+	)
+
+	if _, err := parser.AddCommand("run", short, long, &cmdRun{}); err != nil {
+		panic(err)
+	}
+}
+
+type cmdRun struct {
+	Dir                  string `long:"dir" default:"." description:"Directory with the snaps, <dir>/asserts holds their assertions"`
+	Addr                 string `long:"addr" default:"localhost:11028" description:"Address to listen on"`
+	BaseURL              string `long:"base-url" description:"URL devices reach the server on, used for downloads"`
+	RequireDeviceSession bool   `long:"require-device-session" description:"Only serve devices with a verified serial assertion"`
+}
+
+var waitForSignal = func() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	<-ch
+}
+
+func (x *cmdRun) Execute(args []string) error {
+	httputil.SetUserAgentFromVersion(cmd.Version, "snap-local-store")
+
+	srv := localstore.New(x.Dir, &localstore.Options{
+		BaseURL:              x.BaseURL,
+		RequireDeviceSession: x.RequireDeviceSession,
+	})
+	if err := srv.Start(x.Addr); err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "Serving %s on %s\n", x.Dir, srv.URL())
+
+	waitForSignal()
+
+	return srv.Stop()
+}
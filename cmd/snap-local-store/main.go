@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+
+	opts   struct{}
+	parser *flags.Parser = flags.NewParser(&opts, flags.HelpFlag|flags.PassDoubleDash)
+)
+
+const (
+	shortHelp = "Serve snaps from a local directory"
+	longHelp  = `
+snap-local-store serves the snaps and assertions kept in a directory
+to devices that cannot reach the snap store. Devices use it via a
+proxy store assertion, or by setting SNAPPY_FORCE_API_URL to its URL.
+
+The directory holds the .snap files and, under asserts/, their
+assertions as retrieved with "snap download". Which revisions are
+released to which channels, and the sections of the snaps, are kept
+in index.json and can be updated with the release command.
+`
+)
+
+func init() {
+	err := logger.SimpleSetup()
+	if err != nil {
+		fmt.Fprintf(Stderr, "WARNING: failed to activate logging: %v\n", err)
+	}
+}
+
+func main() {
+	if err := parseArgs(os.Args[1:]); err != nil {
+		fmt.Fprintf(Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func parseArgs(args []string) error {
+	parser.ShortDescription = shortHelp
+	parser.LongDescription = longHelp
+
+	_, err := parser.ParseArgs(args)
+	return err
+}
@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	localstore "github.com/snapcore/snapd/cmd/snap-local-store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// Hook up check.v1 into the "go test" runner
+func Test(t *testing.T) { TestingT(t) }
+
+type localStoreSuite struct {
+	testutil.BaseTest
+
+	stdout *bytes.Buffer
+}
+
+var _ = Suite(&localStoreSuite{})
+
+func (s *localStoreSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.stdout = bytes.NewBuffer(nil)
+	oldStdout := localstore.Stdout
+	s.AddCleanup(func() { localstore.Stdout = oldStdout })
+	localstore.Stdout = s.stdout
+}
+
+func (s *localStoreSuite) TestUnknownArg(c *C) {
+	err := localstore.ParseArgs([]string{})
+	c.Check(err, ErrorMatches, "Please specify one command of: release or run")
+}
+
+func (s *localStoreSuite) TestRelease(c *C) {
+	dir := c.MkDir()
+	err := localstore.ParseArgs([]string{"release", "--dir", dir, "foo", "3", "2.0/beta"})
+	c.Assert(err, IsNil)
+	c.Check(s.stdout.String(), Equals, "Released foo revision 3 to 2.0/beta\n")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "index.json"))
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, `(?s).*"foo": \{\s*"channels": \{\s*"2.0/beta": 3\s*\}.*`)
+}
+
+func (s *localStoreSuite) TestReleaseBadChannel(c *C) {
+	err := localstore.ParseArgs([]string{"release", "--dir", c.MkDir(), "foo", "3", "2.0/beta/fix"})
+	c.Check(err, ErrorMatches, `invalid channel "2.0/beta/fix": branches are not supported`)
+}
+
+func (s *localStoreSuite) TestRun(c *C) {
+	dir := c.MkDir()
+	restore := localstore.MockWaitForSignal(func() {
+		resp, err := http.Get("http://localhost:23322/api/v1/snaps/details/foo")
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Check(resp.StatusCode, Equals, 404)
+	})
+	defer restore()
+
+	err := localstore.ParseArgs([]string{"run", "--dir", dir, "--addr", "localhost:23322"})
+	c.Assert(err, IsNil)
+	c.Check(s.stdout.String(), Equals, "Serving "+dir+" on http://127.0.0.1:23322\n")
+}
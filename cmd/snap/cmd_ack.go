@@ -29,6 +29,9 @@ import (
 )
 
 type cmdAck struct {
+	waitMixin
+	Bundle bool `long:"bundle"`
+
 	AckOptions struct {
 		AssertionFile flags.Filename
 	} `positional-args:"true" required:"true"`
@@ -44,12 +47,19 @@ will replace.
 To succeed the assertion must be valid, its signature verified with a known
 public key and the assertion consistent with and its prerequisite in the
 database.
+
+With --bundle the given file is instead an offline bundle, as written by
+'snap download --bundle': all its assertions are added to the system and
+all its snaps are installed, either all of them or none.
 `)
 
 func init() {
 	addCommand("ack", shortAckHelp, longAckHelp, func() flags.Commander {
 		return &cmdAck{}
-	}, nil, []argDesc{{
+	}, waitDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"bundle": i18n.G("Add the assertions of the given offline bundle and install its snaps"),
+	}), []argDesc{{
 		// TRANSLATORS: This needs to be wrapped in <>s.
 		name: i18n.G("<assertion file>"),
 		// TRANSLATORS: This should probably not start with a lowercase letter.
@@ -70,8 +80,33 @@ func (x *cmdAck) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
+	if x.Bundle {
+		return x.installBundle(string(x.AckOptions.AssertionFile))
+	}
 	if err := ackFile(string(x.AckOptions.AssertionFile)); err != nil {
 		return fmt.Errorf("cannot assert: %v", err)
 	}
 	return nil
 }
+
+func (x *cmdAck) installBundle(bundlePath string) error {
+	cli := Client()
+	changeID, err := cli.InstallBundle(bundlePath, nil)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot install bundle: %v"), err)
+	}
+
+	chg, err := x.wait(cli, changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if err := chg.Get("snap-names", &names); err != nil {
+		return fmt.Errorf(i18n.G("cannot extract the snap names from bundle %q: %v"), bundlePath, err)
+	}
+	return showDone(names, "install")
+}
@@ -0,0 +1,94 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapOpSuite) TestAckBundle(c *check.C) {
+	total := 4
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			form := testForm(r, c)
+			defer form.RemoveAll()
+			c.Check(form.Value, check.DeepEquals, map[string][]string{"action": {"install"}})
+			name, filename, body := formFile(form, c)
+			c.Check(name, check.Equals, "bundle")
+			c.Check(filename, check.Equals, "offline.bundle")
+			c.Check(string(body), check.Equals, "bundle-data")
+			w.WriteHeader(202)
+			fmt.Fprint(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprint(w, `{"type": "sync", "result": {"status": "Doing"}}`)
+		case 2:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprint(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"snap-names": ["one","two"]}}}`)
+		case 3:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			fmt.Fprint(w, `{"type": "sync", "result": [{"name": "one", "status": "active", "version": "1.0", "developer": "bar", "revision":42, "channel":"stable"},{"name": "two", "status": "active", "version": "2.0", "developer": "baz", "revision":42, "channel":"stable"}]}`)
+		default:
+			c.Fatalf("expected to get %d requests, now on %d", total, n+1)
+		}
+
+		n++
+	})
+
+	bundlePath := filepath.Join(c.MkDir(), "offline.bundle")
+	err := ioutil.WriteFile(bundlePath, []byte("bundle-data"), 0644)
+	c.Assert(err, check.IsNil)
+
+	rest, err := snap.Parser().ParseArgs([]string{"ack", "--bundle", bundlePath})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*one 1.0 from 'bar' installed`)
+	c.Check(s.Stdout(), check.Matches, `(?sm).*two 2.0 from 'baz' installed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, total)
+}
+
+func (s *SnapOpSuite) TestAckBundleError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprint(w, `{"type": "error", "status-code": 400, "result": {"message": "cannot find signatures with metadata for snap \"one\" in bundle"}}`)
+	})
+
+	bundlePath := filepath.Join(c.MkDir(), "offline.bundle")
+	err := ioutil.WriteFile(bundlePath, []byte("bundle-data"), 0644)
+	c.Assert(err, check.IsNil)
+
+	_, err = snap.Parser().ParseArgs([]string{"ack", "--bundle", bundlePath})
+	c.Check(err, check.ErrorMatches, `cannot install bundle: cannot find signatures with metadata for snap "one" in bundle`)
+}
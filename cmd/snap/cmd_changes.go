@@ -34,11 +34,18 @@ import (
 var shortChangesHelp = i18n.G("List system changes")
 var shortTasksHelp = i18n.G("List a change's tasks")
 var longChangesHelp = i18n.G(`
-The changes command displays a summary of the recent system changes performed.`)
+The changes command displays a summary of the recent system changes performed.
+
+Changes are pruned from the system state some time after they are done. When
+the core changes.archive option is enabled they are archived when pruned, and
+with --all the archived changes are displayed as well.
+`)
 var longTasksHelp = i18n.G(`
 The tasks command displays a summary of tasks associated to an individual change.`)
 
 type cmdChanges struct {
+	All        bool   `long:"all"`
+	Since      string `long:"since"`
 	Positional struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -48,7 +55,11 @@ type cmdTasks struct{ changeIDMixin }
 
 func init() {
 	addCommand("changes", shortChangesHelp, longChangesHelp,
-		func() flags.Commander { return &cmdChanges{} }, nil, nil)
+		func() flags.Commander { return &cmdChanges{} },
+		map[string]string{
+			"all":   i18n.G("Include archived changes"),
+			"since": i18n.G("Only show changes spawned since the given time or date (RFC3339 or YYYY-MM-DD)"),
+		}, nil)
 	addCommand("tasks", shortTasksHelp, longTasksHelp,
 		func() flags.Commander { return &cmdTasks{} },
 		changeIDMixinOptDesc,
@@ -63,6 +74,17 @@ func (s changesByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
 var allDigits = regexp.MustCompile(`^[0-9]+$`).MatchString
 
+func parseSince(since string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(i18n.G("cannot parse --since %q: expected an RFC3339 time or a YYYY-MM-DD date"), since)
+	}
+	return t, nil
+}
+
 func (c *cmdChanges) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
@@ -82,12 +104,27 @@ func (c *cmdChanges) Execute(args []string) error {
 		SnapName: c.Positional.Snap,
 		Selector: client.ChangesAll,
 	}
+	if c.Since != "" {
+		since, err := parseSince(c.Since)
+		if err != nil {
+			return err
+		}
+		opts.Since = since
+	}
 
 	cli := Client()
 	changes, err := cli.Changes(&opts)
 	if err != nil {
 		return err
 	}
+	if c.All {
+		opts.Selector = client.ChangesArchived
+		archived, err := cli.Changes(&opts)
+		if err != nil {
+			return err
+		}
+		changes = append(archived, changes...)
+	}
 
 	if len(changes) == 0 {
 		return fmt.Errorf(i18n.G("no changes found"))
@@ -178,3 +178,60 @@ Doing +2016-04-21T01:02:03Z +2016-04-21T01:02:04Z +some summary \(50.00%\)
 `)
 	c.Check(s.Stderr(), check.Equals, "")
 }
+
+var mockArchivedChangesJSON = `{"type": "sync", "result": [
+  {
+    "id":   "1",
+    "kind": "install-snap",
+    "summary": "Install \"foo\" snap",
+    "status": "Done",
+    "ready": true,
+    "spawn-time": "2016-01-11T01:02:03Z",
+    "ready-time": "2016-01-11T01:02:04Z"
+  }
+]}`
+
+var mockLiveChangesJSON = `{"type": "sync", "result": [
+  {
+    "id":   "5",
+    "kind": "remove-snap",
+    "summary": "Remove \"foo\" snap",
+    "status": "Doing",
+    "ready": false,
+    "spawn-time": "2016-01-21T01:02:03Z"
+  }
+]}`
+
+func (s *SnapSuite) TestChangesAllSince(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/changes")
+		c.Check(r.URL.Query().Get("since"), check.Equals, "2016-01-10T00:00:00Z")
+		switch n {
+		case 0:
+			c.Check(r.URL.Query().Get("select"), check.Equals, "all")
+			fmt.Fprintln(w, mockLiveChangesJSON)
+		case 1:
+			c.Check(r.URL.Query().Get("select"), check.Equals, "archived")
+			fmt.Fprintln(w, mockArchivedChangesJSON)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"changes", "--all", "--since", "2016-01-10T00:00:00Z"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 2)
+	c.Check(s.Stdout(), check.Matches, `(?ms)ID +Status +Spawn +Ready +Summary
+1 +Done +2016-01-11T01:02:03Z +2016-01-11T01:02:04Z +Install "foo" snap
+5 +Doing +2016-01-21T01:02:03Z +- +Remove "foo" snap
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestChangesBadSince(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"changes", "--since", "last week"})
+	c.Assert(err, check.ErrorMatches, `cannot parse --since "last week": expected an RFC3339 time or a YYYY-MM-DD date`)
+}
@@ -20,7 +20,9 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -31,15 +33,18 @@ import (
 	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/bundle"
 )
 
 type cmdDownload struct {
 	channelMixin
 	Revision string `long:"revision"`
+	Bundle   string `long:"bundle"`
 
 	Positional struct {
-		Snap remoteSnapName
+		Snaps []remoteSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"true" required:"true"`
 }
 
@@ -47,6 +52,10 @@ var shortDownloadHelp = i18n.G("Downloads the given snap")
 var longDownloadHelp = i18n.G(`
 The download command downloads the given snap and its supporting assertions
 to the current directory under .snap and .assert file extensions, respectively.
+
+With --bundle the given snaps are instead written, together with all the
+assertions needed to install them, to a single offline bundle file that
+can be installed on a device without store access with 'snap ack --bundle'.
 `)
 
 func init() {
@@ -54,6 +63,7 @@ func init() {
 		return &cmdDownload{}
 	}, channelDescs.also(map[string]string{
 		"revision": i18n.G("Download the given revision of a snap, to which you must have developer access"),
+		"bundle":   i18n.G("Write the snaps and their assertions to the given offline bundle file"),
 	}), []argDesc{{
 		name: "<snap>",
 		// TRANSLATORS: This should probably not start with a lowercase letter.
@@ -61,11 +71,15 @@ func init() {
 	}})
 }
 
-func fetchSnapAssertions(tsto *image.ToolingStore, snapPath string, snapInfo *snap.Info) (string, error) {
-	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+func newDownloadAssertionsDB() (*asserts.Database, error) {
+	return asserts.OpenDatabase(&asserts.DatabaseConfig{
 		Backstore: asserts.NewMemoryBackstore(),
 		Trusted:   sysdb.Trusted(),
 	})
+}
+
+func fetchSnapAssertions(tsto *image.ToolingStore, snapPath string, snapInfo *snap.Info) (string, error) {
+	db, err := newDownloadAssertionsDB()
 	if err != nil {
 		return "", err
 	}
@@ -96,6 +110,13 @@ func (x *cmdDownload) Execute(args []string) error {
 		return ErrExtraArgs
 	}
 
+	if x.Bundle == "" && len(x.Positional.Snaps) > 1 {
+		return fmt.Errorf(i18n.G("cannot download more than one snap without --bundle"))
+	}
+	if x.Revision != "" && len(x.Positional.Snaps) > 1 {
+		return fmt.Errorf(i18n.G("cannot use --revision with more than one snap"))
+	}
+
 	var revision snap.Revision
 	if x.Revision == "" {
 		revision = snap.R(0)
@@ -107,13 +128,17 @@ func (x *cmdDownload) Execute(args []string) error {
 		}
 	}
 
-	snapName := string(x.Positional.Snap)
-
 	tsto, err := image.NewToolingStore()
 	if err != nil {
 		return err
 	}
 
+	if x.Bundle != "" {
+		return x.downloadBundle(tsto, revision)
+	}
+
+	snapName := string(x.Positional.Snaps[0])
+
 	fmt.Fprintf(Stdout, i18n.G("Fetching snap %q\n"), snapName)
 	dlOpts := image.DownloadOptions{
 		TargetDir: "", // cwd
@@ -146,3 +171,76 @@ func (x *cmdDownload) Execute(args []string) error {
 
 	return nil
 }
+
+// downloadBundle downloads the snaps and the full chain of assertions
+// needed to install them into an offline bundle.
+func (x *cmdDownload) downloadBundle(tsto *image.ToolingStore, revision snap.Revision) error {
+	db, err := newDownloadAssertionsDB()
+	if err != nil {
+		return err
+	}
+	var assertBuf bytes.Buffer
+	encoder := asserts.NewEncoder(&assertBuf)
+	save := func(a asserts.Assertion) error {
+		return encoder.Encode(a)
+	}
+	f := tsto.AssertionFetcher(db, save)
+
+	tmpdir, err := ioutil.TempDir("", "snap-download-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	aw, err := osutil.NewAtomicFile(x.Bundle, 0644, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot create bundle file: %v"), err)
+	}
+	// Cancel once Committed is a NOP
+	defer aw.Cancel()
+
+	w := bundle.NewWriter(aw)
+	for _, name := range x.Positional.Snaps {
+		snapName := string(name)
+		fmt.Fprintf(Stdout, i18n.G("Fetching snap %q\n"), snapName)
+		dlOpts := image.DownloadOptions{
+			TargetDir: tmpdir,
+			Channel:   x.Channel,
+		}
+		snapPath, snapInfo, err := tsto.DownloadSnap(snapName, revision, &dlOpts)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(Stdout, i18n.G("Fetching assertions for %q\n"), snapName)
+		if _, err := image.FetchAndCheckSnapAssertions(snapPath, snapInfo, f, db); err != nil {
+			return err
+		}
+
+		s := &bundle.Snap{
+			Name:     snapInfo.Name(),
+			SnapID:   snapInfo.SnapID,
+			Revision: snapInfo.Revision,
+		}
+		if err := w.AddSnap(s, snapPath); err != nil {
+			return err
+		}
+		// free up space as we go
+		os.Remove(snapPath)
+	}
+	if err := w.AddAssertions(assertBuf.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := aw.Commit(); err != nil {
+		return fmt.Errorf(i18n.G("cannot write bundle file: %v"), err)
+	}
+
+	fmt.Fprintf(Stdout, i18n.G(`Install the snaps with:
+   snap ack --bundle %s
+`), x.Bundle)
+
+	return nil
+}
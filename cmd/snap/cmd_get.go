@@ -51,6 +51,11 @@ Nested values may be retrieved via a dotted path:
 
     $ snap get snap-name author.name
     frank
+
+The declarations of the options, as found in the config section of the
+snap's snap.yaml, may be retrieved as a document with --schema:
+
+    $ snap get -d --schema snap-name author.name
 `)
 
 type cmdGet struct {
@@ -62,14 +67,16 @@ type cmdGet struct {
 	Typed    bool `short:"t"`
 	Document bool `short:"d"`
 	List     bool `short:"l"`
+	Schema   bool `long:"schema"`
 }
 
 func init() {
 	addCommand("get", shortGetHelp, longGetHelp, func() flags.Commander { return &cmdGet{} },
 		map[string]string{
-			"d": i18n.G("Always return document, even with single key"),
-			"l": i18n.G("Always return list, even with single key"),
-			"t": i18n.G("Strict typing with nulls and quoted strings"),
+			"d":      i18n.G("Always return document, even with single key"),
+			"l":      i18n.G("Always return list, even with single key"),
+			"t":      i18n.G("Strict typing with nulls and quoted strings"),
+			"schema": i18n.G("Return the declarations of the options instead of their values"),
 		}, []argDesc{
 			{
 				name: "<snap>",
@@ -246,6 +253,17 @@ func (x *cmdGet) Execute(args []string) error {
 	confKeys := x.Positional.Keys
 
 	cli := Client()
+	if x.Schema {
+		if x.Typed || x.List {
+			return fmt.Errorf("cannot use --schema with -t or -l")
+		}
+		schema, err := cli.ConfSchema(snapName, confKeys)
+		if err != nil {
+			return err
+		}
+		return x.outputJson(schema)
+	}
+
 	conf, err := cli.Conf(snapName, confKeys)
 	if err != nil {
 		return err
@@ -144,6 +144,27 @@ func (s *SnapSuite) TestSnapGetNoConfiguration(c *C) {
 	s.runTests(getNoConfigTests, c)
 }
 
+var getSchemaTests = []getCmdArgs{{
+	args:   "get -d --schema snapname",
+	stdout: "{\n\t\"port\": {\n\t\t\"default\": 8080,\n\t\t\"type\": \"integer\"\n\t}\n}\n",
+}, {
+	args:   "get -d --schema snapname port",
+	stdout: "{\n\t\"port\": {\n\t\t\"default\": 8080,\n\t\t\"type\": \"integer\"\n\t}\n}\n",
+}, {
+	args:  "get -l --schema snapname",
+	error: "cannot use --schema with -t or -l",
+}}
+
+func (s *SnapSuite) TestSnapGetSchema(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/snaps/snapname/conf")
+		c.Check(r.URL.Query().Get("schema"), Equals, "true")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {"port":{"type":"integer","default":8080}}}`)
+	})
+	s.runTests(getSchemaTests, c)
+}
+
 func (s *SnapSuite) TestSortByPath(c *C) {
 	values := []snapset.ConfigValue{
 		{Path: "test-key3.b"},
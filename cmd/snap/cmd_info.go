@@ -237,6 +237,22 @@ func maybePrintServices(w io.Writer, snapName string, allApps []client.AppInfo,
 	}
 }
 
+func maybePrintHealth(w io.Writer, health *client.SnapHealth) {
+	if health == nil {
+		return
+	}
+
+	fmt.Fprintln(w, "health:")
+	fmt.Fprintf(w, "  status:\t%s\n", health.Status)
+	if health.Message != "" {
+		fmt.Fprintf(w, "  message:\t%s\n", health.Message)
+	}
+	if health.Code != "" {
+		fmt.Fprintf(w, "  code:\t%s\n", health.Code)
+	}
+	fmt.Fprintf(w, "  checked:\t%s\n", health.Timestamp)
+}
+
 // displayChannels displays channels and tracks in the right order
 func displayChannels(w io.Writer, remote *client.Snap) {
 	// \t\t\t so we get "installed" lined up with "channels"
@@ -330,6 +346,9 @@ func (x *infoCmd) Execute([]string) error {
 		maybePrintID(w, both)
 		maybePrintCommands(w, snapName, both.Apps, termWidth)
 		maybePrintServices(w, snapName, both.Apps, termWidth)
+		if local != nil {
+			maybePrintHealth(w, local.Health)
+		}
 
 		if x.Verbose {
 			fmt.Fprintln(w, "notes:\t")
@@ -311,3 +311,77 @@ refreshed: 0001-01-01 00:00:00 +0000 UTC
 `)
 	c.Check(s.Stderr(), check.Equals, "")
 }
+
+const mockInfoJSONWithHealth = `
+{
+  "type": "sync",
+  "status-code": 200,
+  "status": "OK",
+  "result": {
+      "channel": "stable",
+      "confinement": "strict",
+      "description": "GNU hello prints a friendly greeting. This is part of the snapcraft tour at https://snapcraft.io/",
+      "developer": "canonical",
+      "id": "mVyGrEwiqSi5PugCwyH7WgpoQLemtTd6",
+      "name": "hello",
+      "private": false,
+      "resource": "/v2/snaps/hello",
+      "revision": "1",
+      "status": "active",
+      "summary": "The GNU Hello snap",
+      "type": "app",
+      "version": "2.10",
+      "license": "MIT",
+      "tracking-channel": "beta",
+      "installed-size": 1024,
+      "health": {
+        "revision": "1",
+        "timestamp": "2018-07-01T12:00:00Z",
+        "status": "blocked",
+        "message": "cannot reach the database",
+        "code": "db-down"
+      }
+    }
+}
+`
+
+func (s *SnapSuite) TestInfoWithLocalHealth(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			fmt.Fprintln(w, mockInfoJSON)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/hello")
+			fmt.Fprintln(w, mockInfoJSONWithHealth)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d (%v)", n+1, r)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"info", "hello"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `name:      hello
+summary:   The GNU Hello snap
+publisher: canonical
+license:   MIT
+description: |
+  GNU hello prints a friendly greeting. This is part of the snapcraft tour at
+  https://snapcraft.io/
+snap-id: mVyGrEwiqSi5PugCwyH7WgpoQLemtTd6
+health:
+  status:  blocked
+  message: cannot reach the database
+  code:    db-down
+  checked: 2018-07-01 12:00:00 +0000 UTC
+tracking:  beta
+installed: 2.10 (1) 1kB blocked
+refreshed: 0001-01-01 00:00:00 +0000 UTC
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
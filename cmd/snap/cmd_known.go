@@ -38,7 +38,8 @@ type cmdKnown struct {
 		HeaderFilters  []string       `required:"0"`
 	} `positional-args:"true" required:"true"`
 
-	Remote bool `long:"remote"`
+	Remote  bool `long:"remote"`
+	Revoked bool `long:"revoked"`
 }
 
 var shortKnownHelp = i18n.G("Shows known assertions of the provided type")
@@ -46,12 +47,18 @@ var longKnownHelp = i18n.G(`
 The known command shows known assertions of the provided type.
 If header=value pairs are provided after the assertion type, the assertions
 shown must also have the specified headers matching the provided values.
+
+Assertions that have been revoked, or that are signed with a revoked key,
+are not shown unless --revoked is given, in which case only those are shown.
 `)
 
 func init() {
 	addCommand("known", shortKnownHelp, longKnownHelp, func() flags.Commander {
 		return &cmdKnown{}
-	}, nil, []argDesc{
+	}, map[string]string{
+		"remote":  i18n.G("Query the store for the assertion instead of the system"),
+		"revoked": i18n.G("Show only revoked assertions"),
+	}, []argDesc{
 		{
 			// TRANSLATORS: This needs to be wrapped in <>s.
 			name: i18n.G("<assertion type>"),
@@ -111,9 +118,14 @@ func (x *cmdKnown) Execute(args []string) error {
 
 	var assertions []asserts.Assertion
 	var err error
-	if x.Remote {
+	switch {
+	case x.Remote && x.Revoked:
+		return fmt.Errorf(i18n.G("cannot use --remote and --revoked together"))
+	case x.Remote:
 		assertions, err = downloadAssertion(string(x.KnownOptions.AssertTypeName), headers)
-	} else {
+	case x.Revoked:
+		assertions, err = Client().KnownRevoked(string(x.KnownOptions.AssertTypeName), headers)
+	default:
 		assertions, err = Client().Known(string(x.KnownOptions.AssertTypeName), headers)
 	}
 	if err != nil {
@@ -90,6 +90,38 @@ func (s *SnapSuite) TestKnownRemoteMissingPrimaryKey(c *check.C) {
 	c.Assert(err, check.ErrorMatches, `cannot query remote assertion: must provide primary key: model`)
 }
 
+func (s *SnapSuite) TestKnownRevoked(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/assertions/model")
+			c.Check(r.URL.Query(), check.DeepEquals, url.Values{
+				"model":   []string{"pi99"},
+				"revoked": []string{"true"},
+			})
+			w.Header().Set("X-Ubuntu-Assertions-Count", "1")
+			fmt.Fprint(w, mockModelAssertion)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"known", "--revoked", "model", "model=pi99"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, mockModelAssertion)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestKnownRemoteRevoked(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"known", "--remote", "--revoked", "model", "model=pi99"})
+	c.Assert(err, check.ErrorMatches, `cannot use --remote and --revoked together`)
+}
+
 func (s *SnapSuite) TestAssertTypeNameCompletion(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -192,6 +192,8 @@ func (s *SnapSuite) TestListWithNotes(c *check.C) {
 ,{"name": "dm1", "status": "active", "version": "5", "revision":1, "devmode": true, "confinement": "devmode"}
 ,{"name": "dm2", "status": "active", "version": "5", "revision":1, "devmode": true, "confinement": "strict"}
 ,{"name": "cf1", "status": "active", "version": "6", "revision":2, "confinement": "devmode", "jailmode": true}
+,{"name": "hc1", "status": "active", "version": "7", "revision":3, "health": {"status": "waiting", "message": "syncing"}}
+,{"name": "hc2", "status": "active", "version": "7", "revision":3, "health": {"status": "okay"}}
 ]}`)
 		default:
 			c.Fatalf("expected to get 1 requests, now on %d", n+1)
@@ -207,5 +209,7 @@ func (s *SnapSuite) TestListWithNotes(c *check.C) {
 	c.Check(s.Stdout(), check.Matches, `(?ms).*^dm1 +.* +devmode$`)
 	c.Check(s.Stdout(), check.Matches, `(?ms).*^dm2 +.* +devmode$`)
 	c.Check(s.Stdout(), check.Matches, `(?ms).*^cf1 +.* +jailmode$`)
+	c.Check(s.Stdout(), check.Matches, `(?ms).*^hc1 +.* +waiting$`)
+	c.Check(s.Stdout(), check.Matches, `(?ms).*^hc2 +.* +-$`)
 	c.Check(s.Stderr(), check.Equals, "")
 }
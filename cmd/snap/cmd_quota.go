@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
+)
+
+var (
+	shortSetQuotaHelp    = i18n.G("Create or update a quota group")
+	shortQuotaHelp       = i18n.G("Show quota group information")
+	shortQuotasHelp      = i18n.G("Show quota groups")
+	shortRemoveQuotaHelp = i18n.G("Remove a quota group")
+)
+
+var longSetQuotaHelp = i18n.G(`
+The set-quota command creates a quota group limiting the combined memory
+and CPU usage of the services of the given snaps, or updates the limits
+of an existing group and adds the given snaps to it.
+
+A quota group can be placed in a parent group when it is created; the
+limits of a group then apply within the limits of its parent, and the
+limits of the sub-groups of a group cannot add up to more than its own.
+A snap can be in only one quota group.
+
+The services of the snaps are placed in the group the next time they
+are started.
+`)
+
+var longQuotaHelp = i18n.G(`
+The quota command shows the limits, the sub-groups and the snaps of a
+quota group.
+`)
+
+var longQuotasHelp = i18n.G(`
+The quotas command shows all the quota groups.
+`)
+
+var longRemoveQuotaHelp = i18n.G(`
+The remove-quota command removes a quota group, which cannot have
+sub-groups. The services of its snaps are no longer limited by it the
+next time they are started.
+`)
+
+type cmdSetQuota struct {
+	Memory     string `long:"memory"`
+	CPU        string `long:"cpu"`
+	Parent     string `long:"parent"`
+	Positional struct {
+		GroupName string              `positional-arg-name:"<group-name>" required:"true"`
+		Snaps     []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+type cmdQuota struct {
+	Positional struct {
+		GroupName string `positional-arg-name:"<group-name>" required:"true"`
+	} `positional-args:"yes"`
+}
+
+type cmdQuotas struct{}
+
+type cmdRemoveQuota struct {
+	Positional struct {
+		GroupName string `positional-arg-name:"<group-name>" required:"true"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addCommand("set-quota", shortSetQuotaHelp, longSetQuotaHelp, func() flags.Commander { return &cmdSetQuota{} }, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"memory": i18n.G("Memory limit of the group, in bytes or with a K, M or G suffix"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"cpu": i18n.G("CPU quota of the group, as a percentage of the time of a single CPU"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"parent": i18n.G("Parent quota group of a new group"),
+	}, nil)
+	addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} }, nil, nil)
+	addCommand("quotas", shortQuotasHelp, longQuotasHelp, func() flags.Commander { return &cmdQuotas{} }, nil, nil)
+	addCommand("remove-quota", shortRemoveQuotaHelp, longRemoveQuotaHelp, func() flags.Commander { return &cmdRemoveQuota{} }, nil, nil)
+}
+
+func (x *cmdSetQuota) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var memory int64
+	if x.Memory != "" {
+		var err error
+		memory, err = snap.ParseMemoryLimit(x.Memory)
+		if err != nil {
+			return err
+		}
+	}
+	var cpu int
+	if x.CPU != "" {
+		var err error
+		cpu, err = snap.ParseCPUQuota(x.CPU)
+		if err != nil {
+			return err
+		}
+	}
+
+	snaps := installedSnapNames(x.Positional.Snaps)
+	return Client().EnsureQuota(x.Positional.GroupName, x.Parent, snaps, memory, cpu)
+}
+
+func (x *cmdQuota) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	grp, err := Client().GetQuotaGroup(x.Positional.GroupName)
+	if err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "name:\t%s\n", grp.GroupName)
+	if grp.Parent != "" {
+		fmt.Fprintf(w, "parent:\t%s\n", grp.Parent)
+	}
+	fmt.Fprintf(w, "constraints:\n")
+	if grp.MaxMemory != 0 {
+		fmt.Fprintf(w, "  memory:\t%s\n", snap.FormatMemoryLimit(grp.MaxMemory))
+	}
+	if grp.CPUQuota != 0 {
+		fmt.Fprintf(w, "  cpu:\t%d%%\n", grp.CPUQuota)
+	}
+	if len(grp.Subgroups) > 0 {
+		fmt.Fprintf(w, "subgroups:\n")
+		for _, name := range grp.Subgroups {
+			fmt.Fprintf(w, "  - %s\n", name)
+		}
+	}
+	if len(grp.Snaps) > 0 {
+		fmt.Fprintf(w, "snaps:\n")
+		for _, name := range grp.Snaps {
+			fmt.Fprintf(w, "  - %s\n", name)
+		}
+	}
+	return nil
+}
+
+func quotaLimits(grp *client.QuotaGroupResult) string {
+	var limits []string
+	if grp.MaxMemory != 0 {
+		limits = append(limits, "memory="+snap.FormatMemoryLimit(grp.MaxMemory))
+	}
+	if grp.CPUQuota != 0 {
+		limits = append(limits, fmt.Sprintf("cpu=%d%%", grp.CPUQuota))
+	}
+	return strings.Join(limits, ",")
+}
+
+func (x *cmdQuotas) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	groups, err := Client().Quotas()
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No quota groups defined."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Quota\tParent\tLimits\tSnaps"))
+	for _, grp := range groups {
+		parent := grp.Parent
+		if parent == "" {
+			parent = "-"
+		}
+		snaps := "-"
+		if len(grp.Snaps) > 0 {
+			snaps = strings.Join(grp.Snaps, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", grp.GroupName, parent, quotaLimits(grp), snaps)
+	}
+	return nil
+}
+
+func (x *cmdRemoveQuota) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	return Client().RemoveQuotaGroup(x.Positional.GroupName)
+}
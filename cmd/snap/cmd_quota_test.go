@@ -0,0 +1,150 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestSetQuota(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/quotas")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":     "ensure",
+			"group-name": "telemetry",
+			"parent":     "all",
+			"snaps":      []interface{}{"snap-a", "snap-b"},
+			"max-memory": json.Number("268435456"),
+			"cpu-quota":  json.Number("30"),
+		})
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": null}`)
+	})
+
+	rest, err := main.Parser().ParseArgs([]string{"set-quota", "--memory=256M", "--cpu=30%", "--parent=all", "telemetry", "snap-a", "snap-b"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestSetQuotaInvalidLimits(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+
+	_, err := main.Parser().ParseArgs([]string{"set-quota", "--memory=lots", "telemetry"})
+	c.Check(err, check.ErrorMatches, `invalid memory limit "lots"`)
+	_, err = main.Parser().ParseArgs([]string{"set-quota", "--cpu=half", "telemetry"})
+	c.Check(err, check.ErrorMatches, `invalid CPU quota "half"`)
+}
+
+func (s *SnapSuite) TestSetQuotaError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "snap \"snap-a\" cannot be in both quota groups \"other\" and \"telemetry\""}}`)
+	})
+
+	_, err := main.Parser().ParseArgs([]string{"set-quota", "telemetry", "snap-a"})
+	c.Check(err, check.ErrorMatches, `snap "snap-a" cannot be in both quota groups "other" and "telemetry"`)
+}
+
+func (s *SnapSuite) TestQuota(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/quotas/telemetry")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"group-name": "telemetry", "parent": "all", "subgroups": ["sub"], "snaps": ["snap-a", "snap-b"], "max-memory": 268435456, "cpu-quota": 30}}`)
+	})
+
+	rest, err := main.Parser().ParseArgs([]string{"quota", "telemetry"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `name:    telemetry
+parent:  all
+constraints:
+  memory:  256M
+  cpu:     30%
+subgroups:
+  - sub
+snaps:
+  - snap-a
+  - snap-b
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestQuotas(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/quotas")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [
+			{"group-name": "all", "subgroups": ["telemetry"], "max-memory": 1073741824, "cpu-quota": 100},
+			{"group-name": "telemetry", "parent": "all", "snaps": ["snap-a", "snap-b"], "cpu-quota": 30}
+		]}`)
+	})
+
+	rest, err := main.Parser().ParseArgs([]string{"quotas"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `Quota      Parent  Limits              Snaps
+all        -       memory=1G,cpu=100%  -
+telemetry  all     cpu=30%             snap-a,snap-b
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestQuotasNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := main.Parser().ParseArgs([]string{"quotas"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No quota groups defined.\n")
+}
+
+func (s *SnapSuite) TestRemoveQuota(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/quotas")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":     "remove",
+			"group-name": "telemetry",
+		})
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": null}`)
+	})
+
+	_, err := main.Parser().ParseArgs([]string{"remove-quota", "telemetry"})
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 1)
+
+	_, err = main.Parser().ParseArgs([]string{"remove-quota"})
+	c.Check(err, check.ErrorMatches, "the required argument `<group-name>` was not provided")
+}
@@ -22,6 +22,8 @@ package main
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
@@ -81,7 +83,7 @@ func (s *svcStatus) Execute(args []string) error {
 	w := tabWriter()
 	defer w.Flush()
 
-	fmt.Fprintln(w, i18n.G("Snap\tService\tStartup\tCurrent"))
+	fmt.Fprintln(w, i18n.G("Snap\tService\tStartup\tCurrent\tNotes"))
 
 	for _, svc := range services {
 		startup := i18n.G("disabled")
@@ -92,12 +94,35 @@ func (s *svcStatus) Execute(args []string) error {
 		if svc.Active {
 			current = i18n.G("active")
 		}
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", svc.Snap, svc.Name, startup, current)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", svc.Snap, svc.Name, startup, current, serviceNotes(svc))
 	}
 
 	return nil
 }
 
+// serviceNotes returns a short note about how the service is activated
+// and the resource limits it runs with
+func serviceNotes(svc *client.AppInfo) string {
+	var notes []string
+	if svc.Timer != nil {
+		note := i18n.G("timer-activated")
+		if !svc.Timer.Next.IsZero() {
+			// TRANSLATORS: %s is the time the timer will next trigger
+			note += fmt.Sprintf(i18n.G(", next: %s"), svc.Timer.Next.UTC().Format(time.RFC3339))
+		}
+		notes = append(notes, note)
+	}
+	if svc.Resources != nil {
+		if limits := svc.Resources.String(); limits != "" {
+			notes = append(notes, limits)
+		}
+	}
+	if len(notes) == 0 {
+		return "-"
+	}
+	return strings.Join(notes, ", ")
+}
+
 func (s *svcLogs) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
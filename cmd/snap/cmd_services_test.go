@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -171,3 +172,94 @@ func (s *appOpSuite) TestAppOps(c *check.C) {
 		}
 	}
 }
+
+func (s *appOpSuite) TestAppStatus(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.URL.Query(), check.HasLen, 1)
+			c.Check(r.URL.Query().Get("select"), check.Equals, "service")
+			c.Check(r.Method, check.Equals, "GET")
+			w.WriteHeader(200)
+			enc := json.NewEncoder(w)
+			enc.Encode(map[string]interface{}{
+				"type": "sync",
+				"result": []map[string]interface{}{
+					{"snap": "foo", "name": "bar", "daemon": "oneshot",
+						"timer": map[string]interface{}{
+							"schedule": "mon,10:00",
+							"enabled":  true,
+							"active":   true,
+							"next":     "2018-04-23T10:00:00Z",
+						}},
+					{"snap": "foo", "name": "baz", "daemon": "simple",
+						"active": true, "enabled": true},
+				},
+				"status":      "OK",
+				"status-code": 200,
+			})
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"services"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `Snap  Service  Startup   Current   Notes
+foo   bar      disabled  inactive  timer-activated, next: 2018-04-23T10:00:00Z
+foo   baz      enabled   active    -
+`)
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *appOpSuite) TestAppStatusResources(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.Method, check.Equals, "GET")
+			w.WriteHeader(200)
+			enc := json.NewEncoder(w)
+			enc.Encode(map[string]interface{}{
+				"type": "sync",
+				"result": []map[string]interface{}{
+					{"snap": "foo", "name": "bar", "daemon": "oneshot",
+						"timer": map[string]interface{}{
+							"schedule": "mon,10:00",
+						},
+						"resources": map[string]interface{}{
+							"tasks": 16,
+						}},
+					{"snap": "foo", "name": "baz", "daemon": "simple",
+						"active": true, "enabled": true,
+						"resources": map[string]interface{}{
+							"memory":    128 << 20,
+							"cpu-quota": 50,
+						}},
+				},
+				"status":      "OK",
+				"status-code": 200,
+			})
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"services"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `Snap  Service  Startup   Current   Notes
+foo   bar      disabled  inactive  timer-activated, tasks=16
+foo   baz      enabled   active    memory=128M,cpu-quota=50%
+`)
+	c.Check(n, check.Equals, 1)
+}
@@ -62,6 +62,27 @@ var waitDescs = mixinDescs{
 
 var noWait = errors.New("no wait for op")
 
+type queueMixin struct {
+	Queue bool `long:"queue"`
+}
+
+var queueDescs = mixinDescs{
+	"queue": i18n.G("Wait for conflicting changes to finish instead of failing"),
+}
+
+// manyOpts returns the options to use for multi-snap operations,
+// which only support a subset of the single-snap ones.
+func (qmx queueMixin) manyOpts(opts *client.SnapOptions) *client.SnapOptions {
+	if !qmx.Queue {
+		return opts
+	}
+	if opts == nil {
+		opts = &client.SnapOptions{}
+	}
+	opts.Queue = true
+	return opts
+}
+
 func (wmx *waitMixin) wait(cli *client.Client, id string) (*client.Change, error) {
 	if wmx.NoWait {
 		fmt.Fprintf(Stdout, "%s\n", id)
@@ -166,6 +187,10 @@ The remove command removes the named snap from the system.
 By default all the snap revisions are removed, including their data and the common
 data directory. When a --revision option is passed only the specified revision is
 removed.
+
+Unless automatic snapshots are disabled, a snapshot of the data of each snap
+being removed completely is saved before its data is removed (see 'snap help saved').
+The --purge option skips this snapshot.
 `)
 
 var longRefreshHelp = i18n.G(`
@@ -195,8 +220,10 @@ and the snap can easily be enabled again.
 
 type cmdRemove struct {
 	waitMixin
+	queueMixin
 
 	Revision   string `long:"revision"`
+	Purge      bool   `long:"purge"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
@@ -267,7 +294,7 @@ func (x *cmdRemove) removeMany(opts *client.SnapOptions) error {
 }
 
 func (x *cmdRemove) Execute([]string) error {
-	opts := &client.SnapOptions{Revision: x.Revision}
+	opts := &client.SnapOptions{Revision: x.Revision, Purge: x.Purge, Queue: x.Queue}
 	if len(x.Positional.Snaps) == 1 {
 		return x.removeOne(opts)
 	}
@@ -275,7 +302,31 @@ func (x *cmdRemove) Execute([]string) error {
 	if x.Revision != "" {
 		return errors.New(i18n.G("a single snap name is needed to specify the revision"))
 	}
-	return x.removeMany(nil)
+	if x.Purge {
+		return x.removeMany(x.manyOpts(&client.SnapOptions{Purge: true}))
+	}
+	return x.removeMany(x.manyOpts(nil))
+}
+
+type transactionMixin struct {
+	Transaction client.TransactionType `long:"transaction" choice:"per-snap" choice:"all-snaps"`
+}
+
+var transactionDescs = mixinDescs{
+	"transaction": i18n.G("Have one snap failing undo the operation only for it (per-snap), or for all of the given snaps (all-snaps); only for several snaps"),
+}
+
+var errTransactionSingleSnap = errors.New(i18n.G("--transaction cannot be used with a single snap"))
+
+func (tmx transactionMixin) transactionOpts(opts *client.SnapOptions) *client.SnapOptions {
+	if tmx.Transaction == "" {
+		return opts
+	}
+	if opts == nil {
+		opts = &client.SnapOptions{}
+	}
+	opts.Transaction = tmx.Transaction
+	return opts
 }
 
 type channelMixin struct {
@@ -419,6 +470,8 @@ func (mx modeMixin) setModes(opts *client.SnapOptions) {
 
 type cmdInstall struct {
 	waitMixin
+	queueMixin
+	transactionMixin
 
 	channelMixin
 	modeMixin
@@ -568,11 +621,15 @@ func (x *cmdInstall) Execute([]string) error {
 		Revision:  x.Revision,
 		Dangerous: dangerous,
 		Unaliased: x.Unaliased,
+		Queue:     x.Queue,
 	}
 	x.setModes(opts)
 
 	names := remoteSnapNames(x.Positional.Snaps)
 	if len(names) == 1 {
+		if x.Transaction != "" {
+			return errTransactionSingleSnap
+		}
 		return x.installOne(names[0], opts)
 	}
 
@@ -580,11 +637,13 @@ func (x *cmdInstall) Execute([]string) error {
 		return errors.New(i18n.G("a single snap name is needed to specify mode or channel flags"))
 	}
 
-	return x.installMany(names, nil)
+	return x.installMany(names, x.transactionOpts(x.manyOpts(nil)))
 }
 
 type cmdRefresh struct {
 	waitMixin
+	queueMixin
+	transactionMixin
 
 	channelMixin
 	modeMixin
@@ -670,6 +729,9 @@ func (x *cmdRefresh) showRefreshTimes() error {
 	} else {
 		fmt.Fprintf(Stdout, "last: n/a\n")
 	}
+	if sysinfo.Refresh.Hold != "" {
+		fmt.Fprintf(Stdout, "hold: %s\n", sysinfo.Refresh.Hold)
+	}
 	if sysinfo.Refresh.Next != "" {
 		fmt.Fprintf(Stdout, "next: %s\n", sysinfo.Refresh.Next)
 	} else {
@@ -734,11 +796,15 @@ func (x *cmdRefresh) Execute([]string) error {
 
 	names := installedSnapNames(x.Positional.Snaps)
 	if len(names) == 1 {
+		if x.Transaction != "" {
+			return errTransactionSingleSnap
+		}
 		opts := &client.SnapOptions{
 			Amend:            x.Amend,
 			Channel:          x.Channel,
 			IgnoreValidation: x.IgnoreValidation,
 			Revision:         x.Revision,
+			Queue:            x.Queue,
 		}
 		x.setModes(opts)
 		return x.refreshOne(names[0], opts)
@@ -752,7 +818,7 @@ func (x *cmdRefresh) Execute([]string) error {
 		return errors.New(i18n.G("a single snap name must be specified when ignoring validation"))
 	}
 
-	return x.refreshMany(names, nil)
+	return x.refreshMany(names, x.transactionOpts(x.manyOpts(nil)))
 }
 
 type cmdTry struct {
@@ -907,6 +973,7 @@ func (x *cmdDisable) Execute([]string) error {
 
 type cmdRevert struct {
 	waitMixin
+	queueMixin
 
 	modeMixin
 	Revision   string `long:"revision"`
@@ -936,7 +1003,7 @@ func (x *cmdRevert) Execute(args []string) error {
 
 	cli := Client()
 	name := string(x.Positional.Snap)
-	opts := &client.SnapOptions{Revision: x.Revision}
+	opts := &client.SnapOptions{Revision: x.Revision, Queue: x.Queue}
 	x.setModes(opts)
 	changeID, err := cli.Revert(name, opts)
 	if err != nil {
@@ -997,16 +1064,19 @@ func (x cmdSwitch) Execute(args []string) error {
 
 func init() {
 	addCommand("remove", shortRemoveHelp, longRemoveHelp, func() flags.Commander { return &cmdRemove{} },
-		waitDescs.also(map[string]string{"revision": i18n.G("Remove only the given revision")}), nil)
+		waitDescs.also(queueDescs).also(map[string]string{
+			"revision": i18n.G("Remove only the given revision"),
+			"purge":    i18n.G("Remove the snap without saving a snapshot of its data"),
+		}), nil)
 	addCommand("install", shortInstallHelp, longInstallHelp, func() flags.Commander { return &cmdInstall{} },
-		waitDescs.also(channelDescs).also(modeDescs).also(map[string]string{
+		waitDescs.also(queueDescs).also(transactionDescs).also(channelDescs).also(modeDescs).also(map[string]string{
 			"revision":        i18n.G("Install the given revision of a snap, to which you must have developer access"),
 			"dangerous":       i18n.G("Install the given snap file even if there are no pre-acknowledged signatures for it, meaning it was not verified and could be dangerous (--devmode implies this)"),
 			"force-dangerous": i18n.G("Alias for --dangerous (DEPRECATED)"),
 			"unaliased":       i18n.G("Install the given snap without enabling its automatic aliases"),
 		}), nil)
 	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() flags.Commander { return &cmdRefresh{} },
-		waitDescs.also(channelDescs).also(modeDescs).also(map[string]string{
+		waitDescs.also(queueDescs).also(transactionDescs).also(channelDescs).also(modeDescs).also(map[string]string{
 			"amend":             i18n.G("Allow refresh attempt on snap unknown to the store"),
 			"revision":          i18n.G("Refresh to the given revision"),
 			"list":              i18n.G("Show available snaps for refresh but do not perform a refresh"),
@@ -1016,7 +1086,7 @@ func init() {
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
 	addCommand("disable", shortDisableHelp, longDisableHelp, func() flags.Commander { return &cmdDisable{} }, waitDescs, nil)
-	addCommand("revert", shortRevertHelp, longRevertHelp, func() flags.Commander { return &cmdRevert{} }, waitDescs.also(modeDescs).also(map[string]string{
+	addCommand("revert", shortRevertHelp, longRevertHelp, func() flags.Commander { return &cmdRevert{} }, waitDescs.also(queueDescs).also(modeDescs).also(map[string]string{
 		"revision": "Revert to the given revision",
 	}), nil)
 	addCommand("switch", shortSwitchHelp, longSwitchHelp, func() flags.Commander { return &cmdSwitch{} }, nil, nil)
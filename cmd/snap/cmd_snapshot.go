@@ -0,0 +1,350 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	shortSavedHelp   = i18n.G("List currently stored snapshots")
+	shortSaveHelp    = i18n.G("Save a snapshot of the current data")
+	shortForgetHelp  = i18n.G("Delete a snapshot")
+	shortCheckHelp   = i18n.G("Check a snapshot")
+	shortRestoreHelp = i18n.G("Restore a snapshot")
+)
+
+var longSavedHelp = i18n.G(`
+The saved command displays a list of snapshots that have been created
+previously with the 'save' command.
+`)
+var longSaveHelp = i18n.G(`
+The save command saves a snapshot of the specified snaps' data (or of
+all installed snaps' data, if none are given), for the specified users
+(or for all users, if none are given).
+
+A snapshot holds the system-wide data of the snap, the data in each
+user's home directory, and the snap's configuration.
+`)
+var longForgetHelp = i18n.G(`
+The forget command deletes a snapshot. This operation can not be
+undone.
+
+A snapshot contains archives for the user, system and configuration
+data of each snap included in the snapshot.
+
+By default, this command forgets all the data in a snapshot.
+Alternatively, you can specify the data of which snaps to forget.
+`)
+var longCheckHelp = i18n.G(`
+The check-snapshot command verifies the user, system and configuration
+data of the snaps included in the specified snapshot.
+
+The check operation runs the same data integrity verification that is
+performed when a snapshot is restored.
+
+By default, this command checks all the data in a snapshot.
+Alternatively, you can specify the data of which snaps to check, or
+for which users, or a combination of these.
+`)
+var longRestoreHelp = i18n.G(`
+The restore command replaces the current user, system and
+configuration data of included snaps, with the corresponding data from
+the specified snapshot.
+
+By default, this command restores all the data in a snapshot.
+Alternatively, you can specify the data of which snaps to restore, or
+for which users, or a combination of these.
+`)
+
+type savedCmd struct {
+	ID         snapshotID `long:"id"`
+	Positional struct {
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+// snapshotID is a snapshot set id, as given on the command line.
+type snapshotID uint64
+
+func (x *savedCmd) Execute([]string) error {
+	list, err := Client().SnapshotSets(uint64(x.ID), installedSnapNames(x.Positional.Snaps))
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No snapshots found."))
+		return nil
+	}
+	showSnapshotSets(list)
+	return nil
+}
+
+func showSnapshotSets(list []client.SnapshotSet) {
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Set\tSnap\tTime\tVersion\tRev\tSize\tNotes"))
+	for _, sg := range list {
+		for _, sh := range sg.Snapshots {
+			notes := "-"
+			if sh.Broken != "" {
+				notes = "broken"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				sg.ID,
+				sh.Snap,
+				sh.Time.UTC().Format(time.RFC3339),
+				sh.Version,
+				sh.Revision,
+				strutil.SizeToStr(sh.Size),
+				notes,
+			)
+		}
+	}
+}
+
+type saveCmd struct {
+	waitMixin
+	Users      string `long:"users"`
+	Positional struct {
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+func (x *saveCmd) Execute([]string) error {
+	cli := Client()
+	setID, changeID, err := cli.SnapshotMany(installedSnapNames(x.Positional.Snaps), usersList(x.Users))
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	list, err := cli.SnapshotSets(setID, nil)
+	if err != nil {
+		return err
+	}
+	showSnapshotSets(list)
+	return nil
+}
+
+type forgetCmd struct {
+	waitMixin
+	Positional struct {
+		ID    snapshotID          `positional-arg-name:"<id>"`
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *forgetCmd) Execute([]string) error {
+	cli := Client()
+	setID := uint64(x.Positional.ID)
+	snaps := installedSnapNames(x.Positional.Snaps)
+	changeID, err := cli.ForgetSnapshots(setID, snaps)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	if len(snaps) > 0 {
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		fmt.Fprintf(Stdout, i18n.G("Snapshot #%d of snaps %s forgotten.\n"), setID, strutil.Quoted(snaps))
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("Snapshot #%d forgotten.\n"), setID)
+	}
+	return nil
+}
+
+type checkSnapshotCmd struct {
+	waitMixin
+	Users      string `long:"users"`
+	Positional struct {
+		ID    snapshotID          `positional-arg-name:"<id>"`
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *checkSnapshotCmd) Execute([]string) error {
+	cli := Client()
+	setID := uint64(x.Positional.ID)
+	snaps := installedSnapNames(x.Positional.Snaps)
+	users := usersList(x.Users)
+	changeID, err := cli.CheckSnapshots(setID, snaps, users)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	// TODO: also mention the home archives that were actually checked
+	if len(snaps) > 0 {
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		fmt.Fprintf(Stdout, i18n.G("Snapshot #%d of snaps %s verified successfully.\n"), setID, strutil.Quoted(snaps))
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("Snapshot #%d verified successfully.\n"), setID)
+	}
+	return nil
+}
+
+type restoreCmd struct {
+	waitMixin
+	Users      string `long:"users"`
+	Positional struct {
+		ID    snapshotID          `positional-arg-name:"<id>"`
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *restoreCmd) Execute([]string) error {
+	cli := Client()
+	setID := uint64(x.Positional.ID)
+	snaps := installedSnapNames(x.Positional.Snaps)
+	users := usersList(x.Users)
+	changeID, err := cli.RestoreSnapshots(setID, snaps, users)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	// TODO: also mention the home archives that were actually restored
+	if len(snaps) > 0 {
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		fmt.Fprintf(Stdout, i18n.G("Restored snapshot #%d of snaps %s.\n"), setID, strutil.Quoted(snaps))
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("Restored snapshot #%d.\n"), setID)
+	}
+	return nil
+}
+
+// usersList splits a comma-separated list of usernames, as given to
+// the --users option.
+func usersList(users string) []string {
+	var list []string
+	for _, user := range strings.Split(users, ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			list = append(list, user)
+		}
+	}
+	return list
+}
+
+func init() {
+	addCommand("saved",
+		shortSavedHelp,
+		longSavedHelp,
+		func() flags.Commander {
+			return &savedCmd{}
+		},
+		map[string]string{
+			"id": i18n.G("Show only a specific snapshot."),
+		},
+		nil)
+
+	addCommand("save",
+		shortSaveHelp,
+		longSaveHelp,
+		func() flags.Commander {
+			return &saveCmd{}
+		}, waitDescs.also(map[string]string{
+			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+		}), nil)
+
+	addCommand("restore",
+		shortRestoreHelp,
+		longRestoreHelp,
+		func() flags.Commander {
+			return &restoreCmd{}
+		}, waitDescs.also(map[string]string{
+			"users": i18n.G("Restore data of only specific users (comma-separated) (default: all users)"),
+		}), []argDesc{
+			{
+				name: "<id>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Set id of snapshot to restore (see 'snap help saved')"),
+			}, {
+				name: "<snap>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("The snap for which data should be restored"),
+			},
+		})
+
+	addCommand("forget",
+		shortForgetHelp,
+		longForgetHelp,
+		func() flags.Commander {
+			return &forgetCmd{}
+		}, waitDescs, []argDesc{
+			{
+				name: "<id>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Set id of snapshot to delete (see 'snap help saved')"),
+			}, {
+				name: "<snap>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("The snap for which data should be deleted"),
+			},
+		})
+
+	addCommand("check-snapshot",
+		shortCheckHelp,
+		longCheckHelp,
+		func() flags.Commander {
+			return &checkSnapshotCmd{}
+		}, waitDescs.also(map[string]string{
+			"users": i18n.G("Check data of only specific users (comma-separated) (default: all users)"),
+		}), []argDesc{
+			{
+				name: "<id>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Set id of snapshot to verify (see 'snap help saved')"),
+			}, {
+				name: "<snap>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("The snap for which data should be checked"),
+			},
+		})
+}
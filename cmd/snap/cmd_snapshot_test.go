@@ -0,0 +1,209 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+type snapshotSuite struct {
+	BaseSnapSuite
+
+	restoreAll func()
+}
+
+var _ = check.Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *check.C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	restoreClientRetry := client.MockDoRetry(time.Millisecond, 10*time.Millisecond)
+	restorePollTime := snap.MockPollTime(time.Millisecond)
+	s.restoreAll = func() {
+		restoreClientRetry()
+		restorePollTime()
+	}
+}
+
+func (s *snapshotSuite) TearDownTest(c *check.C) {
+	s.restoreAll()
+	s.BaseSnapSuite.TearDownTest(c)
+}
+
+const snapshotSetsJSON = `{"type": "sync", "status-code": 200, "result": [{"id": 1, "snapshots": [
+	{"set": 1, "time": "2018-04-20T10:00:00Z", "snap": "foo", "revision": "7", "version": "1.0", "sha3-384": {"archive.tgz": "abc"}, "size": 2048},
+	{"set": 1, "time": "2018-04-20T10:00:01Z", "snap": "bar", "revision": "3", "version": "2.0", "sha3-384": {}, "broken": "meh"}
+]}]}`
+
+const snapshotSetsTable = `Set  Snap  Time                  Version  Rev  Size  Notes
+1    foo   2018-04-20T10:00:00Z  1.0      7    2kB   -
+1    bar   2018-04-20T10:00:01Z  2.0      3    0B    broken
+`
+
+func (s *snapshotSuite) TestSaved(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snapshots")
+			c.Check(r.URL.Query().Get("set"), check.Equals, "1")
+			c.Check(r.URL.Query().Get("snaps"), check.Equals, "foo,bar")
+			fmt.Fprintln(w, snapshotSetsJSON)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"saved", "--id=1", "foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, snapshotSetsTable)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *snapshotSuite) TestSavedNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"saved"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No snapshots found.\n")
+}
+
+func (s *snapshotSuite) TestSave(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action": "snapshot",
+				"snaps":  []interface{}{"foo", "bar"},
+				"users":  []interface{}{"alice", "bob"},
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42", "result": {"set-id": 1}}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		case 2:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snapshots")
+			c.Check(r.URL.Query().Get("set"), check.Equals, "1")
+			fmt.Fprintln(w, snapshotSetsJSON)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"save", "--users=alice,bob", "foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, snapshotSetsTable)
+	c.Check(n, check.Equals, 3)
+}
+
+func (s *snapshotSuite) TestSaveNoWait(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "snapshot",
+		})
+		w.WriteHeader(202)
+		fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42", "result": {"set-id": 1}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"save", "--no-wait"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "42\n")
+}
+
+func (s *snapshotSuite) testSnapshotAction(c *check.C, args []string, expected map[string]interface{}, summary string) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snapshots")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, expected)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42"}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+	s.ResetStdStreams()
+	rest, err := snap.Parser().ParseArgs(args)
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, summary)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *snapshotSuite) TestForget(c *check.C) {
+	s.testSnapshotAction(c, []string{"forget", "1"},
+		map[string]interface{}{"action": "forget", "set": json.Number("1")},
+		"Snapshot #1 forgotten.\n")
+	s.testSnapshotAction(c, []string{"forget", "1", "foo"},
+		map[string]interface{}{"action": "forget", "set": json.Number("1"), "snaps": []interface{}{"foo"}},
+		"Snapshot #1 of snaps \"foo\" forgotten.\n")
+}
+
+func (s *snapshotSuite) TestCheckSnapshot(c *check.C) {
+	s.testSnapshotAction(c, []string{"check-snapshot", "1"},
+		map[string]interface{}{"action": "check", "set": json.Number("1")},
+		"Snapshot #1 verified successfully.\n")
+	s.testSnapshotAction(c, []string{"check-snapshot", "--users=alice", "1", "foo", "bar"},
+		map[string]interface{}{"action": "check", "set": json.Number("1"), "snaps": []interface{}{"foo", "bar"}, "users": []interface{}{"alice"}},
+		"Snapshot #1 of snaps \"foo\", \"bar\" verified successfully.\n")
+}
+
+func (s *snapshotSuite) TestRestore(c *check.C) {
+	s.testSnapshotAction(c, []string{"restore", "1"},
+		map[string]interface{}{"action": "restore", "set": json.Number("1")},
+		"Restored snapshot #1.\n")
+	s.testSnapshotAction(c, []string{"restore", "--users=alice", "1", "foo"},
+		map[string]interface{}{"action": "restore", "set": json.Number("1"), "snaps": []interface{}{"foo"}, "users": []interface{}{"alice"}},
+		"Restored snapshot #1 of snaps \"foo\".\n")
+}
+
+func (s *snapshotSuite) TestSnapshotActionsNeedID(c *check.C) {
+	for _, cmd := range []string{"forget", "check-snapshot", "restore"} {
+		_, err := snap.Parser().ParseArgs([]string{cmd})
+		c.Check(err, check.ErrorMatches, `the required argument .* not provided`, check.Commentf(cmd))
+	}
+}
@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var shortValidateHelp = i18n.G("Apply or show validation sets")
+var longValidateHelp = i18n.G(`
+The validate command applies, forgets or shows the validation sets
+of the system. A validation set is an assertion listing snaps that
+must, may or must not be installed, optionally at given revisions.
+
+With --monitor the system reports whether the installed snaps comply
+with the validation set; with --enforce it additionally refuses to
+install, refresh or remove snaps in ways that would violate it. The
+validation set is given as <account-id>/<name>, optionally followed by
+=<sequence> to pin a sequence; otherwise the latest sequence known to
+the system is used.
+
+Without options the command shows whether the system complies with the
+given validation set, or lists all the validation sets that apply.
+`)
+
+type cmdValidate struct {
+	Monitor    bool `long:"monitor"`
+	Enforce    bool `long:"enforce"`
+	Forget     bool `long:"forget"`
+	Positional struct {
+		ValidationSet string `positional-arg-name:"<validation-set>"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addCommand("validate", shortValidateHelp, longValidateHelp, func() flags.Commander { return &cmdValidate{} }, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"monitor": i18n.G("Monitor the given validation set"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"enforce": i18n.G("Enforce the given validation set"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"forget": i18n.G("Forget the given validation set"),
+	}, []argDesc{{
+		// TRANSLATORS: This needs to be wrapped in <>s.
+		name: i18n.G("<validation-set>"),
+		// TRANSLATORS: This should probably not start with a lowercase letter.
+		desc: i18n.G("Validation set as <account-id>/<name>[=<sequence>]"),
+	}})
+}
+
+func parseValidationSet(arg string) (accountID, name string, sequence int, err error) {
+	key := arg
+	if i := strings.IndexRune(arg, '='); i >= 0 {
+		key = arg[:i]
+		sequence, err = strconv.Atoi(arg[i+1:])
+		if err != nil || sequence <= 0 {
+			return "", "", 0, fmt.Errorf(i18n.G("invalid sequence in validation set %q"), arg)
+		}
+	}
+	accountID, name, err = asserts.ParseValidationSetKey(key)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return accountID, name, sequence, nil
+}
+
+func validationSetStatus(res *client.ValidationSetResult) string {
+	if res.Valid {
+		return i18n.G("valid")
+	}
+	return i18n.G("invalid")
+}
+
+func (x *cmdValidate) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	n := 0
+	mode := ""
+	if x.Monitor {
+		n++
+		mode = "monitor"
+	}
+	if x.Enforce {
+		n++
+		mode = "enforce"
+	}
+	if x.Forget {
+		n++
+	}
+	if n > 1 {
+		return fmt.Errorf(i18n.G("cannot use more than one of --monitor, --enforce and --forget"))
+	}
+
+	if x.Positional.ValidationSet == "" {
+		if n > 0 {
+			return fmt.Errorf(i18n.G("missing validation set argument"))
+		}
+		return x.list()
+	}
+
+	accountID, name, sequence, err := parseValidationSet(x.Positional.ValidationSet)
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	var res *client.ValidationSetResult
+	switch {
+	case x.Forget:
+		if sequence != 0 {
+			return fmt.Errorf(i18n.G("cannot specify a sequence with --forget"))
+		}
+		return cli.ForgetValidationSet(accountID, name)
+	case mode != "":
+		res, err = cli.ApplyValidationSet(accountID, name, mode, sequence)
+	default:
+		if sequence != 0 {
+			return fmt.Errorf(i18n.G("cannot specify a sequence without --monitor or --enforce"))
+		}
+		res, err = cli.ValidationSet(accountID, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(Stdout, validationSetStatus(res))
+	for _, problem := range res.Problems {
+		fmt.Fprintf(Stdout, "- %s\n", problem)
+	}
+	return nil
+}
+
+func (x *cmdValidate) list() error {
+	vsets, err := Client().ValidationSets()
+	if err != nil {
+		return err
+	}
+	if len(vsets) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No validation sets are being tracked."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Validation\tMode\tSeq\tStatus"))
+	for _, res := range vsets {
+		seq := strconv.Itoa(res.Sequence)
+		if res.Pinned {
+			seq += "*"
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\t%s\n", res.AccountID, res.Name, res.Mode, seq, validationSetStatus(res))
+	}
+	return nil
+}
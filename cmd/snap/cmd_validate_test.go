@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestValidateApply(c *check.C) {
+	for _, mode := range []string{"monitor", "enforce"} {
+		s.stdout.Reset()
+		n := 0
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			n++
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/acc-id/base-set")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":   "apply",
+				"mode":     mode,
+				"sequence": json.Number("3"),
+			})
+			fmt.Fprint(w, `{"type": "sync", "status-code": 200, "result": {"account-id": "acc-id", "name": "base-set", "mode": "`+mode+`", "sequence": 3, "pinned": true, "valid": true}}`)
+		})
+
+		rest, err := main.Parser().ParseArgs([]string{"validate", "--" + mode, "acc-id/base-set=3"})
+		c.Assert(err, check.IsNil)
+		c.Check(rest, check.HasLen, 0)
+		c.Check(n, check.Equals, 1)
+		c.Check(s.Stdout(), check.Equals, "valid\n")
+	}
+}
+
+func (s *SnapSuite) TestValidateApplyError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "apply",
+			"mode":   "enforce",
+		})
+		w.WriteHeader(400)
+		fmt.Fprint(w, `{"type": "error", "status-code": 400, "result": {"message": "cannot enforce validation set \"acc-id/base-set\":\n- snap \"foo\" is required but not installed"}}`)
+	})
+
+	_, err := main.Parser().ParseArgs([]string{"validate", "--enforce", "acc-id/base-set"})
+	c.Check(err, check.ErrorMatches, `cannot enforce validation set "acc-id/base-set":\n- snap "foo" is required but not installed`)
+}
+
+func (s *SnapSuite) TestValidateForget(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/acc-id/base-set")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "forget",
+		})
+		fmt.Fprint(w, `{"type": "sync", "status-code": 200, "result": null}`)
+	})
+
+	rest, err := main.Parser().ParseArgs([]string{"validate", "--forget", "acc-id/base-set"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestValidateShow(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/acc-id/base-set")
+		fmt.Fprint(w, `{"type": "sync", "status-code": 200, "result": {"account-id": "acc-id", "name": "base-set", "mode": "monitor", "sequence": 3, "valid": false, "problems": ["snap \"foo\" is required but not installed", "snap \"bar\" is invalid but installed"]}}`)
+	})
+
+	rest, err := main.Parser().ParseArgs([]string{"validate", "acc-id/base-set"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `invalid
+- snap "foo" is required but not installed
+- snap "bar" is invalid but installed
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestValidateList(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets")
+		fmt.Fprint(w, `{"type": "sync", "status-code": 200, "result": [
+			{"account-id": "acc-id", "name": "base-set", "mode": "enforce", "sequence": 3, "pinned": true, "valid": true},
+			{"account-id": "acc-id", "name": "extra", "mode": "monitor", "sequence": 12, "valid": false}
+		]}`)
+	})
+
+	rest, err := main.Parser().ParseArgs([]string{"validate"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `Validation       Mode     Seq  Status
+acc-id/base-set  enforce  3*   valid
+acc-id/extra     monitor  12   invalid
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestValidateListNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := main.Parser().ParseArgs([]string{"validate"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No validation sets are being tracked.\n")
+}
+
+func (s *SnapSuite) TestValidateInvalidArgs(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"validate", "--monitor", "--enforce", "acc-id/base-set"}, `cannot use more than one of --monitor, --enforce and --forget`},
+		{[]string{"validate", "--monitor"}, `missing validation set argument`},
+		{[]string{"validate", "acc-id"}, `invalid validation set key "acc-id", expected <account-id>/<name>`},
+		{[]string{"validate", "acc-id/Base"}, `invalid validation set name "Base"`},
+		{[]string{"validate", "--monitor", "acc-id/base-set=x"}, `invalid sequence in validation set "acc-id/base-set=x"`},
+		{[]string{"validate", "--forget", "acc-id/base-set=2"}, `cannot specify a sequence with --forget`},
+		{[]string{"validate", "acc-id/base-set=2"}, `cannot specify a sequence without --monitor or --enforce`},
+	}
+	for _, t := range tests {
+		_, err := main.Parser().ParseArgs(t.args)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%v", t.args))
+	}
+}
@@ -61,6 +61,7 @@ type Notes struct {
 	Disabled         bool
 	Broken           bool
 	IgnoreValidation bool
+	Health           string
 }
 
 func NotesFromChannelSnapInfo(ref *snap.ChannelSnapInfo) *Notes {
@@ -85,7 +86,7 @@ func NotesFromRemote(snp *client.Snap, resInfo *client.ResultInfo) *Notes {
 }
 
 func NotesFromLocal(snp *client.Snap) *Notes {
-	return &Notes{
+	notes := &Notes{
 		SnapType:         snap.Type(snp.Type),
 		Private:          snp.Private,
 		DevMode:          snp.DevMode,
@@ -96,6 +97,11 @@ func NotesFromLocal(snp *client.Snap) *Notes {
 		Broken:           snp.Broken != "",
 		IgnoreValidation: snp.IgnoreValidation,
 	}
+	if snp.Health != nil && snp.Health.Status != "okay" {
+		notes.Health = snp.Health.Status
+	}
+
+	return notes
 }
 
 func NotesFromInfo(info *snap.Info) *Notes {
@@ -161,6 +167,10 @@ func (n *Notes) String() string {
 		ns = append(ns, i18n.G("ignore-validation"))
 	}
 
+	if n.Health != "" {
+		ns = append(ns, n.Health)
+	}
+
 	if len(ns) == 0 {
 		return "-"
 	}
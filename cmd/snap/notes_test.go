@@ -88,6 +88,12 @@ func (notesSuite) TestNotesIgnoreValidation(c *check.C) {
 	}).String(), check.Equals, "ignore-validation")
 }
 
+func (notesSuite) TestNotesHealth(c *check.C) {
+	c.Check((&snap.Notes{
+		Health: "blocked",
+	}).String(), check.Equals, "blocked")
+}
+
 func (notesSuite) TestNotesNothing(c *check.C) {
 	c.Check((&snap.Notes{}).String(), check.Equals, "-")
 }
@@ -104,4 +110,7 @@ func (notesSuite) TestNotesFromLocal(c *check.C) {
 	c.Check(snap.NotesFromLocal(&client.Snap{DevMode: true}).DevMode, check.Equals, true)
 	c.Check(snap.NotesFromLocal(&client.Snap{Confinement: client.DevModeConfinement}).DevMode, check.Equals, false)
 	c.Check(snap.NotesFromLocal(&client.Snap{IgnoreValidation: true}).IgnoreValidation, check.Equals, true)
+	// Only health that is not okay is noteworthy.
+	c.Check(snap.NotesFromLocal(&client.Snap{Health: &client.SnapHealth{Status: "okay"}}).Health, check.Equals, "")
+	c.Check(snap.NotesFromLocal(&client.Snap{Health: &client.SnapHealth{Status: "error"}}).Health, check.Equals, "error")
 }
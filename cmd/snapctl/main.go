@@ -50,6 +50,11 @@ func main() {
 	// no internal command, route via snapd
 	stdout, stderr, err := run()
 	if err != nil {
+		if e, ok := err.(*client.UnsuccessfulError); ok {
+			os.Stdout.Write(stdout)
+			os.Stderr.Write(stderr)
+			os.Exit(e.ExitCode)
+		}
 		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
 	}
@@ -55,8 +55,10 @@ import (
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/history"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/queuestate"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -93,6 +95,11 @@ var api = []*Command{
 	appsCmd,
 	logsCmd,
 	debugCmd,
+	snapshotCmd,
+	quotaGroupsCmd,
+	quotaGroupInfoCmd,
+	validationSetsCmd,
+	validationSetCmd,
 }
 
 var (
@@ -205,9 +212,48 @@ var (
 
 	debugCmd = &Command{
 		Path: "/v2/debug",
+		GET:  getDebug,
 		POST: postDebug,
 	}
 
+	snapshotCmd = &Command{
+		// see api_snapshots.go
+		Path:   "/v2/snapshots",
+		UserOK: true,
+		GET:    listSnapshots,
+		POST:   changeSnapshots,
+	}
+
+	quotaGroupsCmd = &Command{
+		// see api_quotas.go
+		Path:   "/v2/quotas",
+		UserOK: true,
+		GET:    getQuotaGroups,
+		POST:   postQuotaGroup,
+	}
+
+	quotaGroupInfoCmd = &Command{
+		// see api_quotas.go
+		Path:   "/v2/quotas/{group}",
+		UserOK: true,
+		GET:    getQuotaGroupInfo,
+	}
+
+	validationSetsCmd = &Command{
+		// see api_validation_sets.go
+		Path:   "/v2/validation-sets",
+		UserOK: true,
+		GET:    getValidationSets,
+	}
+
+	validationSetCmd = &Command{
+		// see api_validation_sets.go
+		Path:   "/v2/validation-sets/{account}/{name}",
+		UserOK: true,
+		GET:    getValidationSet,
+		POST:   postValidationSet,
+	}
+
 	createUserCmd = &Command{
 		Path:   "/v2/create-user",
 		UserOK: false,
@@ -274,6 +320,13 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return InternalError("cannot get refresh schedule: %s", err)
 	}
+	refreshHold, err := snapMgr.EffectiveRefreshHold()
+	if err != nil {
+		return InternalError("cannot get refresh hold: %s", err)
+	}
+	if !refreshHold.After(time.Now()) {
+		refreshHold = time.Time{}
+	}
 	users, err := auth.Users(st)
 	if err != nil && err != state.ErrNoState {
 		return InternalError("cannot get user auth data: %s", err)
@@ -281,6 +334,7 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	refreshInfo := client.RefreshInfo{
 		Last: formatRefreshTime(lastRefresh),
+		Hold: formatRefreshTime(refreshHold),
 		Next: formatRefreshTime(nextRefresh),
 	}
 	if !legacySchedule {
@@ -839,16 +893,46 @@ type snapInstruction struct {
 	Classic          bool          `json:"classic"`
 	IgnoreValidation bool          `json:"ignore-validation"`
 	Unaliased        bool          `json:"unaliased"`
+	Purge            bool          `json:"purge"`
+	// Queue asks for the operation to wait for conflicting changes
+	// to finish instead of failing.
+	Queue bool `json:"queue"`
+	// Transaction is how failures are handled by multi-snap
+	// operations, see snapstate.TransactionType.
+	Transaction snapstate.TransactionType `json:"transaction"`
 	// dropping support temporarely until flag confusion is sorted,
 	// this isn't supported by client atm anyway
 	LeaveOld bool         `json:"temp-dropped-leave-old"`
 	License  *licenseData `json:"license"`
 	Snaps    []string     `json:"snaps"`
+	Users    []string     `json:"users"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
 }
 
+func (inst *snapInstruction) transaction() snapstate.TransactionType {
+	if inst.Transaction == "" {
+		return snapstate.TransactionPerSnap
+	}
+	return inst.Transaction
+}
+
+// validateTransaction checks that the transaction type is known and
+// supported by the action.
+func (inst *snapInstruction) validateTransaction() error {
+	switch inst.Transaction {
+	case "", snapstate.TransactionPerSnap:
+	case snapstate.TransactionAllSnaps:
+		if inst.Action != "install" && inst.Action != "refresh" {
+			return fmt.Errorf("transaction type is unsupported for %q actions", inst.Action)
+		}
+	default:
+		return fmt.Errorf("invalid value for transaction type: %s", inst.Transaction)
+	}
+	return nil
+}
+
 func (inst *snapInstruction) modeFlags() (snapstate.Flags, error) {
 	return modeFlags(inst.DevMode, inst.JailMode, inst.Classic)
 }
@@ -868,6 +952,7 @@ type snapInstructionResult struct {
 	summary  string
 	affected []string
 	tasksets []*state.TaskSet
+	result   map[string]interface{}
 }
 
 var (
@@ -876,8 +961,8 @@ var (
 	snapstateRefreshCandidates = snapstate.RefreshCandidates
 	snapstateTryPath           = snapstate.TryPath
 	snapstateUpdate            = snapstate.Update
-	snapstateUpdateMany        = snapstate.UpdateMany
-	snapstateInstallMany       = snapstate.InstallMany
+	snapstateUpdateMany        = snapstate.UpdateManyWithTransaction
+	snapstateInstallMany       = snapstate.InstallManyWithTransaction
 	snapstateRemoveMany        = snapstate.RemoveMany
 	snapstateRevert            = snapstate.Revert
 	snapstateRevertToRevision  = snapstate.RevertToRevision
@@ -924,7 +1009,7 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 		return nil, err
 	}
 
-	updated, tasksets, err := snapstateUpdateMany(st, inst.Snaps, inst.userID)
+	updated, tasksets, err := snapstateUpdateMany(st, inst.Snaps, inst.userID, inst.transaction())
 	if err != nil {
 		return nil, err
 	}
@@ -969,7 +1054,7 @@ func verifySnapInstructions(inst *snapInstruction) error {
 }
 
 func snapInstallMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	installed, tasksets, err := snapstateInstallMany(st, inst.Snaps, inst.userID)
+	installed, tasksets, err := snapstateInstallMany(st, inst.Snaps, inst.userID, inst.transaction())
 	if err != nil {
 		return nil, err
 	}
@@ -1045,7 +1130,7 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 }
 
 func snapRemoveMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	removed, tasksets, err := snapstateRemoveMany(st, inst.Snaps)
+	removed, tasksets, err := snapstateRemoveMany(st, inst.Snaps, &snapstate.RemoveFlags{Purge: inst.Purge})
 	if err != nil {
 		return nil, err
 	}
@@ -1070,7 +1155,7 @@ func snapRemoveMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 }
 
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
-	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision)
+	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision, &snapstate.RemoveFlags{Purge: inst.Purge})
 	if err != nil {
 		return "", nil, err
 	}
@@ -1247,6 +1332,9 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err := verifySnapInstructions(&inst); err != nil {
 		return BadRequest("%s", err)
 	}
+	if err := inst.validateTransaction(); err != nil {
+		return BadRequest("%v", err)
+	}
 
 	impl := inst.dispatch()
 	if impl == nil {
@@ -1255,6 +1343,12 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	msg, tsets, err := impl(&inst, state)
 	if err != nil {
+		if inst.Queue {
+			if chg, err := inst.queue(state, err, false); err == nil {
+				ensureStateSoon(state)
+				return AsyncResponse(nil, &Meta{Change: chg.ID()})
+			}
+		}
 		return inst.errToResponse(err)
 	}
 
@@ -1348,6 +1442,9 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 	if inst.Channel != "" || !inst.Revision.Unset() || inst.DevMode || inst.JailMode {
 		return BadRequest("unsupported option provided for multi-snap operation")
 	}
+	if err := inst.validateTransaction(); err != nil {
+		return BadRequest("%v", err)
+	}
 
 	st := c.d.overlord.State()
 	st.Lock()
@@ -1357,20 +1454,18 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 		inst.userID = user.ID
 	}
 
-	var op func(*snapInstruction, *state.State) (*snapInstructionResult, error)
-
-	switch inst.Action {
-	case "refresh":
-		op = snapUpdateMany
-	case "install":
-		op = snapInstallMany
-	case "remove":
-		op = snapRemoveMany
-	default:
+	op := inst.dispatchForMany()
+	if op == nil {
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
 	res, err := op(&inst, st)
 	if err != nil {
+		if inst.Queue {
+			if chg, err := inst.queue(st, err, true); err == nil {
+				ensureStateSoon(st)
+				return AsyncResponse(nil, &Meta{Change: chg.ID()})
+			}
+		}
 		return inst.errToResponse(err)
 	}
 
@@ -1385,7 +1480,91 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	chg.Set("api-data", map[string]interface{}{"snap-names": res.affected})
 
-	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+	return AsyncResponse(res.result, &Meta{Change: chg.ID()})
+}
+
+type snapManyActionFunc func(*snapInstruction, *state.State) (*snapInstructionResult, error)
+
+func (inst *snapInstruction) dispatchForMany() snapManyActionFunc {
+	switch inst.Action {
+	case "refresh":
+		return snapUpdateMany
+	case "install":
+		return snapInstallMany
+	case "remove":
+		return snapRemoveMany
+	case "snapshot":
+		// see api_snapshots.go
+		return snapshotMany
+	}
+	return nil
+}
+
+func init() {
+	queuestate.RegisterOp("snap-instruction", runQueuedSnapInstruction)
+}
+
+// queuedSnapInstruction is a snap instruction waiting for conflicting
+// changes to finish.
+type queuedSnapInstruction struct {
+	Instruction *snapInstruction `json:"instruction"`
+	UserID      int              `json:"user-id,omitempty"`
+	Many        bool             `json:"many,omitempty"`
+}
+
+// queue sets up a change that performs the instruction once the
+// change that conflict reports is ready. The conflict error is
+// returned if it cannot be waited for.
+func (inst *snapInstruction) queue(st *state.State, conflict error, many bool) (*state.Change, error) {
+	summary := fmt.Sprintf(i18n.G("Queued %s of %s"), inst.Action, strutil.Quoted(inst.Snaps))
+	queued := &queuedSnapInstruction{
+		Instruction: inst,
+		UserID:      inst.userID,
+		Many:        many,
+	}
+	chg, err := queuestate.Queue(st, inst.Action+"-snap", summary, "snap-instruction", queued, conflict)
+	if err != nil {
+		return nil, err
+	}
+	chg.Set("snap-names", inst.Snaps)
+	if many {
+		chg.Set("api-data", map[string]interface{}{"snap-names": inst.Snaps})
+	}
+	return chg, nil
+}
+
+func runQueuedSnapInstruction(st *state.State, data *json.RawMessage) ([]*state.TaskSet, error) {
+	var queued queuedSnapInstruction
+	if err := json.Unmarshal(*data, &queued); err != nil {
+		return nil, fmt.Errorf("cannot decode queued snap instruction: %v", err)
+	}
+	inst := queued.Instruction
+	if inst == nil {
+		return nil, fmt.Errorf("internal error: queued snap instruction is missing")
+	}
+	inst.userID = queued.UserID
+
+	if queued.Many {
+		op := inst.dispatchForMany()
+		if op == nil {
+			return nil, fmt.Errorf("unsupported multi-snap operation %q", inst.Action)
+		}
+		res, err := op(inst, st)
+		if err != nil {
+			return nil, err
+		}
+		return res.tasksets, nil
+	}
+
+	if len(inst.Snaps) != 1 {
+		return nil, fmt.Errorf("internal error: queued %s of %d snaps", inst.Action, len(inst.Snaps))
+	}
+	impl := inst.dispatch()
+	if impl == nil {
+		return nil, fmt.Errorf("unknown action %s", inst.Action)
+	}
+	_, tsets, err := impl(inst, st)
+	return tsets, err
 }
 
 func postSnaps(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -1427,6 +1606,15 @@ func postSnaps(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 		return trySnap(c, r, user, form.Value["snap-path"][0], flags)
 	}
+	if fheaders := form.File["bundle"]; len(fheaders) > 0 {
+		defer form.RemoveAll()
+		if dangerousOK {
+			return BadRequest("cannot install a bundle in dangerous mode")
+		}
+		// see api_bundle.go
+		return sideloadBundle(c, fheaders[0], flags)
+	}
+
 	flags.RemoveSnapPath = true
 
 	// find the file for the "snap" form field
@@ -1593,7 +1781,11 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	snapName := vars["name"]
 
-	keys := splitQS(r.URL.Query().Get("keys"))
+	query := r.URL.Query()
+	keys := splitQS(query.Get("keys"))
+	if query.Get("schema") == "true" {
+		return getSnapConfSchema(c, snapName, keys)
+	}
 
 	s := c.d.overlord.State()
 	s.Lock()
@@ -1632,10 +1824,43 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(currentConfValues, nil)
 }
 
+// getSnapConfSchema returns the declarations of the configuration
+// options of the snap, either all of them or only the requested ones.
+func getSnapConfSchema(c *Command, snapName string, keys []string) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	info, err := snapstate.CurrentInfo(st, snapName)
+	st.Unlock()
+	if err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		return InternalError("%v", err)
+	}
+
+	schema := make(map[string]*snap.ConfigOption)
+	if len(keys) == 0 {
+		for name, option := range info.Config {
+			schema[name] = option
+		}
+		return SyncResponse(schema, nil)
+	}
+	for _, key := range keys {
+		option := info.ConfigOption(key)
+		if option == nil {
+			return BadRequest("snap %q does not declare a %q configuration option", snapName, key)
+		}
+		schema[key] = option
+	}
+
+	return SyncResponse(schema, nil)
+}
+
 func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	snapName := vars["name"]
 
+	// a null value unsets the respective option
 	var patchValues map[string]interface{}
 	if err := jsonutil.DecodeWithNumber(r.Body, &patchValues); err != nil {
 		return BadRequest("cannot decode request body into patch values: %v", err)
@@ -1910,6 +2135,9 @@ func assertsFindMany(c *Command, r *http.Request, user *auth.UserState) Response
 	headers := map[string]string{}
 	q := r.URL.Query()
 	for k := range q {
+		if k == "revoked" {
+			continue
+		}
 		headers[k] = q.Get(k)
 	}
 
@@ -1918,7 +2146,11 @@ func assertsFindMany(c *Command, r *http.Request, user *auth.UserState) Response
 	db := assertstate.DB(state)
 	state.Unlock()
 
-	assertions, err := db.FindMany(assertType, headers)
+	findMany := db.FindMany
+	if q.Get("revoked") == "true" {
+		findMany = db.FindManyRevoked
+	}
+	assertions, err := findMany(assertType, headers)
 	if asserts.IsNotFound(err) {
 		return AssertResponse(nil, true)
 	} else if err != nil {
@@ -1950,10 +2182,18 @@ type taskInfo struct {
 	Log      []string         `json:"log,omitempty"`
 	Progress taskInfoProgress `json:"progress"`
 
+	// StatusChanges is only set for archived changes
+	StatusChanges []taskInfoStatusChange `json:"status-changes,omitempty"`
+
 	SpawnTime time.Time  `json:"spawn-time,omitempty"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
 }
 
+type taskInfoStatusChange struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
 type taskInfoProgress struct {
 	Label string `json:"label"`
 	Done  int    `json:"done"`
@@ -2032,6 +2272,14 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	if qselect == "" {
 		qselect = "in-progress"
 	}
+	var since time.Time
+	if qsince := query.Get("since"); qsince != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, qsince)
+		if err != nil {
+			return BadRequest("cannot parse since: %v", err)
+		}
+	}
 	var filter func(*state.Change) bool
 	switch qselect {
 	case "all":
@@ -2040,8 +2288,17 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 		filter = func(chg *state.Change) bool { return !chg.Status().Ready() }
 	case "ready":
 		filter = func(chg *state.Change) bool { return chg.Status().Ready() }
+	case "archived":
+		return getArchivedChanges(since, query.Get("for"))
 	default:
-		return BadRequest("select should be one of: all,in-progress,ready")
+		return BadRequest("select should be one of: all,in-progress,ready,archived")
+	}
+
+	if !since.IsZero() {
+		outerFilter := filter
+		filter = func(chg *state.Change) bool {
+			return outerFilter(chg) && !chg.SpawnTime().Before(since)
+		}
 	}
 
 	if wantedName := query.Get("for"); wantedName != "" {
@@ -2081,6 +2338,78 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(chgInfos, nil)
 }
 
+// getArchivedChanges returns the changes pruned from the state that
+// were archived, optionally only those spawned no earlier than since
+// and involving the given snap.
+func getArchivedChanges(since time.Time, wantedName string) Response {
+	chgs, err := history.Changes(since)
+	if err != nil {
+		return InternalError("cannot read changes archive: %v", err)
+	}
+	chgInfos := make([]*changeInfo, 0, len(chgs))
+	for _, chg := range chgs {
+		if wantedName != "" {
+			var snapNames []string
+			if raw := chg.Data["snap-names"]; raw != nil {
+				if err := json.Unmarshal(*raw, &snapNames); err != nil {
+					logger.Noticef("Cannot get snap-name for archived change %v", chg.ID)
+				}
+			}
+			if !strutil.ListContains(snapNames, wantedName) {
+				continue
+			}
+		}
+		chgInfos = append(chgInfos, archivedChange2changeInfo(chg))
+	}
+	return SyncResponse(chgInfos, nil)
+}
+
+func archivedChange2changeInfo(chg *state.ArchivedChange) *changeInfo {
+	readyTime := chg.ReadyTime
+	chgInfo := &changeInfo{
+		ID:      chg.ID,
+		Kind:    chg.Kind,
+		Summary: chg.Summary,
+		Status:  chg.Status,
+		Ready:   true,
+		Err:     chg.Err,
+
+		SpawnTime: chg.SpawnTime,
+		ReadyTime: &readyTime,
+	}
+	var data map[string]*json.RawMessage
+	if raw := chg.Data["api-data"]; raw != nil && json.Unmarshal(*raw, &data) == nil {
+		chgInfo.Data = data
+	}
+
+	taskInfos := make([]*taskInfo, len(chg.Tasks))
+	for j, t := range chg.Tasks {
+		taskInfo := &taskInfo{
+			ID:      t.ID,
+			Kind:    t.Kind,
+			Summary: t.Summary,
+			Status:  t.Status,
+			Log:     t.Log,
+
+			SpawnTime: t.SpawnTime,
+		}
+		if !t.ReadyTime.IsZero() {
+			readyTime := t.ReadyTime
+			taskInfo.ReadyTime = &readyTime
+		}
+		for _, sc := range t.StatusChanges {
+			taskInfo.StatusChanges = append(taskInfo.StatusChanges, taskInfoStatusChange{
+				Status: sc.Status,
+				Time:   sc.Time,
+			})
+		}
+		taskInfos[j] = taskInfo
+	}
+	chgInfo.Tasks = taskInfos
+
+	return chgInfo
+}
+
 func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
@@ -2451,6 +2780,18 @@ func postDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 	}
 }
 
+func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
+	aspect := r.URL.Query().Get("aspect")
+	switch aspect {
+	case "task-metrics":
+		return SyncResponse(c.d.overlord.State().Metrics().Snapshot(), nil)
+	case "":
+		return BadRequest("missing debug aspect")
+	default:
+		return BadRequest("unknown debug aspect %q", aspect)
+	}
+}
+
 func postBuy(c *Command, r *http.Request, user *auth.UserState) Response {
 	var opts store.BuyOptions
 
@@ -2498,6 +2839,20 @@ func runSnapctl(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
 			stdout = []byte(e.Error())
+		} else if e, ok := err.(*ctlcmd.UnsuccessfulError); ok {
+			return &resp{
+				Type: ResponseTypeError,
+				Result: &errorResult{
+					Message: e.Error(),
+					Kind:    errorKindUnsuccessful,
+					Value: map[string]interface{}{
+						"stdout":    string(stdout),
+						"stderr":    string(stderr),
+						"exit-code": e.ExitCode,
+					},
+				},
+				Status: 200,
+			}
 		} else {
 			return BadRequest("error running snapctl: %s", err)
 		}
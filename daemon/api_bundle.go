@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/bundle"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// sideloadBundle adds the assertions of the uploaded offline bundle
+// to the system database and installs all its snaps in one change,
+// in a single lane so that either all of them get installed or none.
+// Nothing is added to the system if any of the snaps cannot be set up
+// for installation.
+func sideloadBundle(c *Command, fheader *multipart.FileHeader, flags snapstate.Flags) Response {
+	f, err := fheader.Open()
+	if err != nil {
+		return BadRequest(`cannot open uploaded "bundle" file: %v`, err)
+	}
+	defer f.Close()
+
+	// if you change this prefix, look for it in the tests
+	dir, err := ioutil.TempDir("", "snapd-sideload-bundle-")
+	if err != nil {
+		return InternalError("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := bundle.Extract(f, dir)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	batch := assertstate.NewBatch()
+	af, err := os.Open(b.AssertionsPath())
+	if err != nil {
+		return InternalError("cannot open bundle assertions: %v", err)
+	}
+	defer af.Close()
+	if _, err := batch.AddStream(af); err != nil {
+		return BadRequest("cannot decode bundle assertions: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// the snaps are checked against the assertions of the bundle
+	// before any of them is added to the system database
+	finder := batch.Finder(st)
+	names := make([]string, len(b.Snaps))
+	sideInfos := make([]*snap.SideInfo, len(b.Snaps))
+	for i, s := range b.Snaps {
+		si, err := snapasserts.DeriveSideInfo(b.SnapPath(s), finder)
+		if asserts.IsNotFound(err) {
+			return BadRequest("cannot find signatures with metadata for snap %q in bundle", s.Name)
+		}
+		if err != nil {
+			return BadRequest("%v", err)
+		}
+		if si.RealName != s.Name || si.Revision != s.Revision {
+			return BadRequest("snap file %q in bundle is revision %s of snap %q, not revision %s of snap %q", s.File, si.Revision, si.RealName, s.Revision, s.Name)
+		}
+		names[i] = s.Name
+		sideInfos[i] = si
+	}
+
+	// we are in charge of the snap files life cycle until we hand
+	// them off to the change
+	var tempPaths []string
+	changeTriggered := false
+	defer func() {
+		if !changeTriggered {
+			for _, p := range tempPaths {
+				os.Remove(p)
+			}
+		}
+	}()
+
+	// the task sets are not linked to any change until all of them
+	// are set up, so on error they are never run and get pruned
+	flags.RemoveSnapPath = true
+	tsets := make([]*state.TaskSet, len(b.Snaps))
+	for i, s := range b.Snaps {
+		// move the snap out of the directory removed on return
+		tmpf, err := ioutil.TempFile("", "snapd-sideload-pkg-")
+		if err != nil {
+			return InternalError("cannot create temporary file: %v", err)
+		}
+		tmpf.Close()
+		tempPaths = append(tempPaths, tmpf.Name())
+		if err := os.Rename(b.SnapPath(s), tmpf.Name()); err != nil {
+			return InternalError("cannot move snap %q out of bundle: %v", s.Name, err)
+		}
+
+		tset, err := snapstateInstallPath(st, sideInfos[i], tmpf.Name(), "", flags)
+		if err != nil {
+			return InternalError("cannot install snap %q from bundle: %v", s.Name, err)
+		}
+		tsets[i] = tset
+	}
+
+	if err := batch.Commit(st); err != nil {
+		return BadRequest("cannot add bundle assertions: %v", err)
+	}
+
+	lane := st.NewLane()
+	for _, tset := range tsets {
+		tset.JoinLane(lane)
+	}
+
+	// TRANSLATORS: the first %s is a comma-separated list of quoted snap names
+	msg := fmt.Sprintf(i18n.G("Install snaps %s from bundle %q"), strutil.Quoted(names), fheader.Filename)
+	chg := newChange(st, "install-snap", msg, tsets, names)
+	chg.Set("api-data", map[string]interface{}{"snap-names": names})
+
+	ensureStateSoon(st)
+
+	changeTriggered = true
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
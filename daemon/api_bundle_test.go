@@ -0,0 +1,269 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/bundle"
+)
+
+var _ = check.Suite(&bundleSuite{})
+
+type bundleSuite struct {
+	apiBaseSuite
+}
+
+type bundledSnap struct {
+	name     string
+	content  string
+	revision snap.Revision
+	// asserted is the revision in the snap-revision assertion, if any
+	asserted snap.Revision
+}
+
+func (s *bundleSuite) mockBundle(c *check.C, snaps ...bundledSnap) []byte {
+	var assertBuf bytes.Buffer
+	enc := asserts.NewEncoder(&assertBuf)
+	c.Assert(enc.Encode(s.storeSigning.StoreAccountKey("")), check.IsNil)
+	devAcct := assertstest.NewAccount(s.storeSigning, "devel1", nil, "")
+	c.Assert(enc.Encode(devAcct), check.IsNil)
+
+	dir := c.MkDir()
+	var buf bytes.Buffer
+	w := bundle.NewWriter(&buf)
+	for _, sn := range snaps {
+		snapDecl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+			"series":       "16",
+			"snap-id":      sn.name + "-id",
+			"snap-name":    sn.name,
+			"publisher-id": devAcct.AccountID(),
+			"timestamp":    time.Now().Format(time.RFC3339),
+		}, nil, "")
+		c.Assert(err, check.IsNil)
+		c.Assert(enc.Encode(snapDecl), check.IsNil)
+
+		if !sn.asserted.Unset() {
+			h := sha3.Sum384([]byte(sn.content))
+			dgst, err := asserts.EncodeDigest(crypto.SHA3_384, h[:])
+			c.Assert(err, check.IsNil)
+			snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+				"snap-sha3-384": string(dgst),
+				"snap-size":     "5",
+				"snap-id":       sn.name + "-id",
+				"snap-revision": sn.asserted.String(),
+				"developer-id":  devAcct.AccountID(),
+				"timestamp":     time.Now().Format(time.RFC3339),
+			}, nil, "")
+			c.Assert(err, check.IsNil)
+			c.Assert(enc.Encode(snapRev), check.IsNil)
+		}
+
+		snapPath := filepath.Join(dir, sn.name+".snap")
+		c.Assert(ioutil.WriteFile(snapPath, []byte(sn.content), 0644), check.IsNil)
+		err = w.AddSnap(&bundle.Snap{Name: sn.name, SnapID: sn.name + "-id", Revision: sn.revision}, snapPath)
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(w.AddAssertions(assertBuf.Bytes()), check.IsNil)
+	c.Assert(w.Close(), check.IsNil)
+	return buf.Bytes()
+}
+
+func (s *bundleSuite) postBundle(c *check.C, data []byte, dangerous bool) *resp {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if dangerous {
+		c.Assert(mw.WriteField("dangerous", "true"), check.IsNil)
+	}
+	fw, err := mw.CreateFormFile("bundle", "offline.bundle")
+	c.Assert(err, check.IsNil)
+	_, err = fw.Write(data)
+	c.Assert(err, check.IsNil)
+	c.Assert(mw.Close(), check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/snaps", &body)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return postSnaps(snapsCmd, req, nil).(*resp)
+}
+
+func (s *bundleSuite) tempFiles() int {
+	pkgs, _ := filepath.Glob(filepath.Join(os.TempDir(), "snapd-sideload-pkg-*"))
+	dirs, _ := filepath.Glob(filepath.Join(os.TempDir(), "snapd-sideload-bundle-*"))
+	return len(pkgs) + len(dirs)
+}
+
+func (s *bundleSuite) TestSideloadBundle(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	st := d.overlord.State()
+
+	data := s.mockBundle(c,
+		bundledSnap{name: "x", content: "xyzzy", revision: snap.R(41), asserted: snap.R(41)},
+		bundledSnap{name: "y", content: "yzzyx", revision: snap.R(7), asserted: snap.R(7)},
+	)
+
+	var installed []*snap.SideInfo
+	var paths []string
+	snapstateInstallPath = func(s *state.State, si *snap.SideInfo, path, channel string, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Check(flags, check.Equals, snapstate.Flags{RemoveSnapPath: true})
+		installed = append(installed, si)
+		paths = append(paths, path)
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	rsp := s.postBundle(c, data, false)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf("%v", rsp.Result))
+
+	c.Check(installed, check.DeepEquals, []*snap.SideInfo{
+		{RealName: "x", SnapID: "x-id", Revision: snap.R(41)},
+		{RealName: "y", SnapID: "y-id", Revision: snap.R(7)},
+	})
+	// the snap files were handed over to the change
+	c.Assert(paths, check.HasLen, 2)
+	for i, content := range []string{"xyzzy", "yzzyx"} {
+		b, err := ioutil.ReadFile(paths[i])
+		c.Assert(err, check.IsNil)
+		c.Check(string(b), check.Equals, content)
+		os.Remove(paths[i])
+	}
+	dirs, _ := filepath.Glob(filepath.Join(os.TempDir(), "snapd-sideload-bundle-*"))
+	c.Check(dirs, check.HasLen, 0)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Install snaps "x", "y" from bundle "offline.bundle"`)
+	var apiData map[string]interface{}
+	err := chg.Get("api-data", &apiData)
+	c.Assert(err, check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{
+		"snap-names": []interface{}{"x", "y"},
+	})
+
+	// all the snaps are installed in the same lane
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Lanes(), check.HasLen, 1)
+	c.Check(tasks[1].Lanes(), check.DeepEquals, tasks[0].Lanes())
+
+	// the assertions were added
+	_, err = assertstate.DB(st).Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": "y-id",
+	})
+	c.Check(err, check.IsNil)
+}
+
+func (s *bundleSuite) TestSideloadBundleErrors(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	snapstateInstallPath = func(s *state.State, si *snap.SideInfo, path, channel string, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Fatalf("unexpected install")
+		return nil, nil
+	}
+
+	tests := []struct {
+		data      []byte
+		dangerous bool
+		err       string
+	}{{
+		data:      s.mockBundle(c, bundledSnap{name: "x", content: "xyzzy", revision: snap.R(41), asserted: snap.R(41)}),
+		dangerous: true,
+		err:       `cannot install a bundle in dangerous mode`,
+	}, {
+		data: s.mockBundle(c,
+			bundledSnap{name: "x", content: "xyzzy", revision: snap.R(41), asserted: snap.R(41)},
+			bundledSnap{name: "y", content: "yzzyx", revision: snap.R(7)},
+		),
+		err: `cannot find signatures with metadata for snap "y" in bundle`,
+	}, {
+		data: s.mockBundle(c, bundledSnap{name: "x", content: "xyzzy", revision: snap.R(40), asserted: snap.R(41)}),
+		err:  `snap file "x.snap" in bundle is revision 41 of snap "x", not revision 40 of snap "x"`,
+	}, {
+		data: []byte("not a bundle"),
+		err:  `cannot read bundle: .*`,
+	}}
+	for _, t := range tests {
+		before := s.tempFiles()
+		rsp := s.postBundle(c, t.data, t.dangerous)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+		c.Check(s.tempFiles(), check.Equals, before)
+	}
+}
+
+func (s *bundleSuite) TestSideloadBundleInstallErrorPartway(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	st := d.overlord.State()
+
+	data := s.mockBundle(c,
+		bundledSnap{name: "x", content: "xyzzy", revision: snap.R(41), asserted: snap.R(41)},
+		bundledSnap{name: "y", content: "yzzyx", revision: snap.R(7), asserted: snap.R(7)},
+	)
+
+	var installed []string
+	snapstateInstallPath = func(s *state.State, si *snap.SideInfo, path, channel string, flags snapstate.Flags) (*state.TaskSet, error) {
+		installed = append(installed, si.RealName)
+		if si.RealName == "y" {
+			return nil, errors.New("boom")
+		}
+		t := s.NewTask("fake-install-snap", "Doing a fake install")
+		return state.NewTaskSet(t), nil
+	}
+
+	before := s.tempFiles()
+	rsp := s.postBundle(c, data, false)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot install snap "y" from bundle: boom`)
+	c.Check(installed, check.DeepEquals, []string{"x", "y"})
+	c.Check(s.tempFiles(), check.Equals, before)
+
+	st.Lock()
+	defer st.Unlock()
+	// no change and nothing to run
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.Tasks(), check.HasLen, 0)
+	// the assertions were not added
+	_, err := assertstate.DB(st).Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": "x-id",
+	})
+	c.Check(asserts.IsNotFound(err), check.Equals, true)
+}
@@ -0,0 +1,147 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+// A postQuotaGroupData is used to request an operation on a quota group
+// keep this in sync with client/quota.go's postQuotaGroupData
+type postQuotaGroupData struct {
+	Action    string   `json:"action"`
+	GroupName string   `json:"group-name"`
+	Parent    string   `json:"parent,omitempty"`
+	Snaps     []string `json:"snaps,omitempty"`
+	MaxMemory int64    `json:"max-memory,omitempty"`
+	CPUQuota  int      `json:"cpu-quota,omitempty"`
+}
+
+var (
+	servicestateEnsureQuota = servicestate.EnsureQuota
+	servicestateRemoveQuota = servicestate.RemoveQuota
+)
+
+func clientQuotaGroupResult(grp *quota.Group) *client.QuotaGroupResult {
+	return &client.QuotaGroupResult{
+		GroupName: grp.Name,
+		Parent:    grp.ParentGroup,
+		Subgroups: grp.SubGroups,
+		Snaps:     grp.Snaps,
+		MaxMemory: grp.MemoryLimit,
+		CPUQuota:  grp.CPUQuota,
+	}
+}
+
+// getQuotaGroups returns all the quota groups, sorted by name.
+func getQuotaGroups(c *Command, r *http.Request, _ *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	quotas, err := servicestate.AllQuotas(st)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	names := make([]string, 0, len(quotas))
+	for name := range quotas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	results := make([]*client.QuotaGroupResult, len(names))
+	for i, name := range names {
+		results[i] = clientQuotaGroupResult(quotas[name])
+	}
+	return SyncResponse(results, nil)
+}
+
+// getQuotaGroupInfo returns the quota group with the given name.
+func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response {
+	name := muxVars(r)["group"]
+	if err := quota.ValidateGroupName(name); err != nil {
+		return BadRequest("%v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	quotas, err := servicestate.AllQuotas(st)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	grp := quotas[name]
+	if grp == nil {
+		return NotFound("cannot find quota group %q", name)
+	}
+	return SyncResponse(clientQuotaGroupResult(grp), nil)
+}
+
+// postQuotaGroup creates, updates or removes a quota group.
+func postQuotaGroup(c *Command, r *http.Request, _ *auth.UserState) Response {
+	var data postQuotaGroupData
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		return BadRequest("cannot decode quota action from request body: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found in request body")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var err error
+	switch data.Action {
+	case "ensure":
+		limits := servicestate.QuotaLimits{
+			MemoryLimit: data.MaxMemory,
+			CPUQuota:    data.CPUQuota,
+		}
+		err = servicestateEnsureQuota(st, data.GroupName, data.Parent, data.Snaps, limits)
+	case "remove":
+		err = servicestateRemoveQuota(st, data.GroupName)
+	default:
+		return BadRequest("unknown quota action %q", data.Action)
+	}
+	switch err.(type) {
+	case nil:
+		return SyncResponse(nil, nil)
+	case *servicestate.QuotaGroupNotFoundError:
+		return NotFound("%v", err)
+	case *snapstate.ChangeConflictError:
+		return Conflict("%v", err)
+	case *servicestate.QuotaUnitsError:
+		return InternalError("%v", err)
+	default:
+		return BadRequest("%v", err)
+	}
+}
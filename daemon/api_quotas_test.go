@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+var _ = check.Suite(&quotaSuite{})
+
+type quotaSuite struct {
+	apiBaseSuite
+}
+
+func (s *quotaSuite) TearDownTest(c *check.C) {
+	s.apiBaseSuite.TearDownTest(c)
+
+	servicestateEnsureQuota = servicestate.EnsureQuota
+	servicestateRemoveQuota = servicestate.RemoveQuota
+}
+
+func (s *quotaSuite) mockQuotas(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	st.Set("quotas", map[string]*quota.Group{
+		"foo": {Name: "foo", MemoryLimit: 1000, SubGroups: []string{"bar"}, Snaps: []string{"snap-a"}},
+		"bar": {Name: "bar", CPUQuota: 20, ParentGroup: "foo", Snaps: []string{"snap-b"}},
+	})
+}
+
+func (s *quotaSuite) postQuota(c *check.C, body string) *resp {
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	rsp, ok := postQuotaGroup(quotaGroupsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	return rsp
+}
+
+func (s *quotaSuite) TestPostEnsureQuota(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	called := 0
+	servicestateEnsureQuota = func(st *state.State, name, parentName string, snapNames []string, limits servicestate.QuotaLimits) error {
+		called++
+		c.Check(name, check.Equals, "foo")
+		c.Check(parentName, check.Equals, "bar")
+		c.Check(snapNames, check.DeepEquals, []string{"snap-a", "snap-b"})
+		c.Check(limits, check.Equals, servicestate.QuotaLimits{MemoryLimit: 1000, CPUQuota: 50})
+		return nil
+	}
+
+	rsp := s.postQuota(c, `{"action": "ensure", "group-name": "foo", "parent": "bar", "snaps": ["snap-a", "snap-b"], "max-memory": 1000, "cpu-quota": 50}`)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(called, check.Equals, 1)
+}
+
+func (s *quotaSuite) TestPostRemoveQuota(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	called := 0
+	servicestateRemoveQuota = func(st *state.State, name string) error {
+		called++
+		c.Check(name, check.Equals, "foo")
+		return nil
+	}
+
+	rsp := s.postQuota(c, `{"action": "remove", "group-name": "foo"}`)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(called, check.Equals, 1)
+}
+
+func (s *quotaSuite) TestPostQuotaErrors(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	rsp := s.postQuota(c, `{"action": "frobnicate", "group-name": "foo"}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `unknown quota action "frobnicate"`)
+
+	rsp = s.postQuota(c, `{"action": "remove"} {}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `extra content found in request body`)
+
+	servicestateRemoveQuota = func(st *state.State, name string) error {
+		return &servicestate.QuotaGroupNotFoundError{Name: name}
+	}
+	rsp = s.postQuota(c, `{"action": "remove", "group-name": "foo"}`)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot find quota group "foo"`)
+
+	servicestateEnsureQuota = func(st *state.State, name, parentName string, snapNames []string, limits servicestate.QuotaLimits) error {
+		return &snapstate.ChangeConflictError{Snap: "snap-a", ChangeKind: "refresh"}
+	}
+	rsp = s.postQuota(c, `{"action": "ensure", "group-name": "foo", "snaps": ["snap-a"]}`)
+	c.Check(rsp.Status, check.Equals, 409)
+
+	servicestateEnsureQuota = func(st *state.State, name, parentName string, snapNames []string, limits servicestate.QuotaLimits) error {
+		return &servicestate.QuotaUnitsError{Err: errors.New("boom")}
+	}
+	rsp = s.postQuota(c, `{"action": "ensure", "group-name": "foo", "snaps": ["snap-a"]}`)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot update quota group units: boom`)
+
+	servicestateEnsureQuota = servicestate.EnsureQuota
+	rsp = s.postQuota(c, `{"action": "ensure", "group-name": "foo"}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `quota group "foo" must have a memory limit or a CPU quota`)
+}
+
+func (s *quotaSuite) TestGetQuotaGroups(c *check.C) {
+	s.daemonWithOverlordMock(c)
+	s.mockQuotas(c)
+
+	req, err := http.NewRequest("GET", "/v2/quotas", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getQuotaGroups(quotaGroupsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []*client.QuotaGroupResult{
+		{GroupName: "bar", Parent: "foo", Snaps: []string{"snap-b"}, CPUQuota: 20},
+		{GroupName: "foo", Subgroups: []string{"bar"}, Snaps: []string{"snap-a"}, MaxMemory: 1000},
+	})
+}
+
+func (s *quotaSuite) TestGetQuotaGroupInfo(c *check.C) {
+	s.daemonWithOverlordMock(c)
+	s.mockQuotas(c)
+
+	req, err := http.NewRequest("GET", "/v2/quotas/bar", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"group": "bar"}
+	rsp := getQuotaGroupInfo(quotaGroupInfoCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, &client.QuotaGroupResult{
+		GroupName: "bar", Parent: "foo", Snaps: []string{"snap-b"}, CPUQuota: 20,
+	})
+
+	// the result marshals as expected
+	b, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, `{"group-name":"bar","parent":"foo","snaps":["snap-b"],"cpu-quota":20}`)
+
+	s.vars = map[string]string{"group": "missing"}
+	rsp = getQuotaGroupInfo(quotaGroupInfoCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+
+	s.vars = map[string]string{"group": "Bad_Name"}
+	rsp = getQuotaGroupInfo(quotaGroupInfoCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+}
@@ -0,0 +1,278 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&snapshotSuite{})
+
+type snapshotSuite struct {
+	apiBaseSuite
+}
+
+func (s *snapshotSuite) TearDownTest(c *check.C) {
+	s.apiBaseSuite.TearDownTest(c)
+
+	snapshotList = snapshotstate.List
+	snapshotCheck = snapshotstate.Check
+	snapshotForget = snapshotstate.Forget
+	snapshotRestore = snapshotstate.Restore
+	snapshotSave = snapshotstate.Save
+}
+
+func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
+	snapshotSave = func(st *state.State, snapNames []string, users []string) (uint64, []string, *state.TaskSet, error) {
+		c.Check(snapNames, check.HasLen, 0)
+		c.Check(users, check.HasLen, 0)
+		t := st.NewTask("fake-snapshot-2", "Snapshot two")
+		return 1, []string{"a", "b"}, state.NewTaskSet(t), nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	inst := &snapInstruction{Action: "snapshot"}
+	st := d.overlord.State()
+	st.Lock()
+	res, err := snapshotMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(res.summary, check.Equals, "Snapshot all snaps")
+	c.Check(res.affected, check.DeepEquals, []string{"a", "b"})
+	c.Check(res.result, check.DeepEquals, map[string]interface{}{"set-id": uint64(1)})
+}
+
+func (s *snapshotSuite) TestSnapshotManyViaPostSnaps(c *check.C) {
+	snapshotSave = func(st *state.State, snapNames []string, users []string) (uint64, []string, *state.TaskSet, error) {
+		c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+		c.Check(users, check.DeepEquals, []string{"alice"})
+		t := st.NewTask("fake-snapshot-2", "Snapshot two")
+		return 42, []string{"foo", "bar"}, state.NewTaskSet(t), nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "snapshot", "snaps": ["foo", "bar"], "users": ["alice"]}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"set-id": uint64(42)})
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Check(chg.Kind(), check.Equals, "snapshot-snap")
+	c.Check(chg.Summary(), check.Equals, `Snapshot snaps "foo", "bar"`)
+}
+
+func (s *snapshotSuite) TestSnapshotManyError(c *check.C) {
+	snapshotSave = func(*state.State, []string, []string) (uint64, []string, *state.TaskSet, error) {
+		return 0, nil, nil, errors.New("bzzt")
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	inst := &snapInstruction{Action: "snapshot"}
+	st := d.overlord.State()
+	st.Lock()
+	_, err := snapshotMany(inst, st)
+	st.Unlock()
+	c.Check(err, check.ErrorMatches, "bzzt")
+}
+
+func (s *snapshotSuite) TestListSnapshots(c *check.C) {
+	snapshots := []client.SnapshotSet{{ID: 1}, {ID: 42}}
+
+	snapshotList = func(context.Context, uint64, []string) ([]client.SnapshotSet, error) {
+		return snapshots, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, snapshots)
+}
+
+func (s *snapshotSuite) TestListSnapshotsFiltering(c *check.C) {
+	snapshots := []client.SnapshotSet{{ID: 1}, {ID: 42}}
+
+	snapshotList = func(_ context.Context, setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+		return snapshots[1:], nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots?set=42&snaps=foo,bar", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []client.SnapshotSet{{ID: 42}})
+}
+
+func (s *snapshotSuite) TestListSnapshotsBadFiltering(c *check.C) {
+	snapshotList = func(context.Context, uint64, []string) ([]client.SnapshotSet, error) {
+		c.Fatal("snapshotList should not be reached (should have been blocked by validation!)")
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots?set=no", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `'set', if given, must be a positive base 10 number; got "no"`)
+}
+
+func (s *snapshotSuite) TestListSnapshotsListError(c *check.C) {
+	snapshotList = func(context.Context, uint64, []string) ([]client.SnapshotSet, error) {
+		return nil, errors.New("no")
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "no")
+}
+
+func (s *snapshotSuite) TestFormatSnapshotAction(c *check.C) {
+	type table struct {
+		action   string
+		expected string
+	}
+	tests := []table{
+		{
+			`{"set": 2, "action": "verb"}`,
+			`Verb of snapshot set #2`,
+		}, {
+			`{"set": 2, "action": "verb", "snaps": ["foo"]}`,
+			`Verb of snapshot set #2 of snaps "foo"`,
+		}, {
+			`{"set": 2, "action": "verb", "snaps": ["foo", "bar"], "users": ["meep"]}`,
+			`Verb of snapshot set #2 of snaps "foo", "bar" for users "meep"`,
+		},
+	}
+
+	for _, test := range tests {
+		comm := check.Commentf(test.action)
+		var action snapshotAction
+		c.Assert(json.Unmarshal([]byte(test.action), &action), check.IsNil, comm)
+		c.Check(action.String(), check.Equals, test.expected, comm)
+	}
+}
+
+func (s *snapshotSuite) TestChangeSnapshots(c *check.C) {
+	var called string
+	snapshotCheck = func(st *state.State, setID uint64, snapNames []string, users []string) ([]string, *state.TaskSet, error) {
+		called = "check"
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapNames, check.DeepEquals, []string{"foo"})
+		c.Check(users, check.DeepEquals, []string{"alice"})
+		return []string{"foo"}, state.NewTaskSet(st.NewTask("fake-check", "...")), nil
+	}
+	snapshotRestore = func(st *state.State, setID uint64, snapNames []string, users []string) ([]string, *state.TaskSet, error) {
+		called = "restore"
+		return []string{"foo"}, state.NewTaskSet(st.NewTask("fake-restore", "...")), nil
+	}
+	snapshotForget = func(st *state.State, setID uint64, snapNames []string) ([]string, *state.TaskSet, error) {
+		called = "forget"
+		return []string{"foo"}, state.NewTaskSet(st.NewTask("fake-forget", "...")), nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+	st := d.overlord.State()
+
+	for _, action := range []string{"check", "restore", "forget"} {
+		users := `, "users": ["alice"]`
+		if action == "forget" {
+			users = ""
+		}
+		buf := bytes.NewBufferString(`{"set": 42, "action": "` + action + `", "snaps": ["foo"]` + users + `}`)
+		req, err := http.NewRequest("POST", "/v2/snapshots", buf)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp := changeSnapshots(snapshotCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf(action))
+		c.Check(called, check.Equals, action)
+
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		c.Check(chg.Kind(), check.Equals, action+"-snapshot")
+		st.Unlock()
+	}
+}
+
+func (s *snapshotSuite) TestChangeSnapshotsErrors(c *check.C) {
+	snapshotCheck = func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		return nil, nil, client.ErrSnapshotSetNotFound
+	}
+
+	s.daemonWithOverlordMock(c)
+
+	for body, msg := range map[string]string{
+		`{"action": "check"}`:          "snapshot operation requires snapshot set ID",
+		`{"set": 42}`:                  "snapshot operation requires action",
+		`{"set": 42, "action": "meh"}`: `unknown snapshot operation "meh"`,
+		`{"set": 42, "action": "forget", "users": ["alice"]}`:   `snapshot "forget" operation cannot specify users`,
+		`{"set": 42, "action": "check"}{"set": 42}`:             "extra content found after snapshot operation",
+		`{"set": 42, "action": "check", "snaps": "not-a-list"}`: "cannot decode request body into snapshot operation: .*",
+	} {
+		req, err := http.NewRequest("POST", "/v2/snapshots", bytes.NewBufferString(body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp := changeSnapshots(snapshotCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError, check.Commentf(body))
+		c.Check(rsp.Status, check.Equals, 400, check.Commentf(body))
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, msg, check.Commentf(body))
+	}
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", bytes.NewBufferString(`{"set": 42, "action": "check"}`))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	rsp := changeSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, client.ErrSnapshotSetNotFound.Error())
+}
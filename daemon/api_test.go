@@ -56,11 +56,13 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/builtin"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/healthstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -257,7 +259,7 @@ func (s *apiBaseSuite) TearDownTest(c *check.C) {
 
 	assertstateRefreshSnapDeclarations = assertstate.RefreshSnapDeclarations
 	snapstateInstall = snapstate.Install
-	snapstateInstallMany = snapstate.InstallMany
+	snapstateInstallMany = snapstate.InstallManyWithTransaction
 	snapstateInstallPath = snapstate.InstallPath
 	snapstateRefreshCandidates = snapstate.RefreshCandidates
 	snapstateRemoveMany = snapstate.RemoveMany
@@ -265,7 +267,7 @@ func (s *apiBaseSuite) TearDownTest(c *check.C) {
 	snapstateRevertToRevision = snapstate.RevertToRevision
 	snapstateTryPath = snapstate.TryPath
 	snapstateUpdate = snapstate.Update
-	snapstateUpdateMany = snapstate.UpdateMany
+	snapstateUpdateMany = snapstate.UpdateManyWithTransaction
 }
 
 func (s *apiBaseSuite) daemon(c *check.C) *Daemon {
@@ -879,6 +881,53 @@ func (s *apiSuite) TestSysInfoLegacyRefresh(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
+func (s *apiSuite) TestSysInfoRefreshHold(c *check.C) {
+	rec := httptest.NewRecorder()
+
+	d := s.daemon(c)
+
+	holdTime := time.Now().Add(48 * time.Hour).Truncate(time.Minute)
+
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("last-refresh", time.Now())
+	tr := config.NewTransaction(st)
+	tr.Set("core", "refresh.hold", holdTime.Format(time.RFC3339))
+	tr.Commit()
+	st.Unlock()
+
+	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var rsp struct {
+		Result client.SysInfo `json:"result"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	c.Check(rsp.Result.Refresh.Hold, check.Equals, holdTime.Format(time.RFC3339))
+}
+
+func (s *apiSuite) TestSysInfoRefreshHoldInThePast(c *check.C) {
+	rec := httptest.NewRecorder()
+
+	d := s.daemon(c)
+
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "refresh.hold", time.Now().Add(-time.Hour).Format(time.RFC3339))
+	tr.Commit()
+	st.Unlock()
+
+	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var rsp struct {
+		Result client.SysInfo `json:"result"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	c.Check(rsp.Result.Refresh.Hold, check.Equals, "")
+}
+
 func (s *apiSuite) makeDeveloperAPIServer(statusCode int, data string) *httptest.Server {
 	mockDeveloperAPIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(statusCode)
@@ -1469,6 +1518,60 @@ func (s *apiSuite) checkSnapInfoOnePerIntegration(c *check.C, all bool, names []
 	}
 }
 
+func (s *apiSuite) TestSnapsInfoHealth(c *check.C) {
+	d := s.daemon(c)
+
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(5), true, "")
+	s.mkInstalledInState(c, d, "baz", "qux", "v2", snap.R(7), true, "")
+
+	timestamp := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	st := d.overlord.State()
+	st.Lock()
+	err := healthstate.Set(st, "foo", &healthstate.HealthState{
+		Revision:  snap.R(5),
+		Timestamp: timestamp,
+		Status:    healthstate.BlockedStatus,
+		Message:   "cannot reach the database",
+		Code:      "db-down",
+	})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	expected := &client.SnapHealth{
+		Revision:  snap.R(5),
+		Timestamp: timestamp,
+		Status:    "blocked",
+		Message:   "cannot reach the database",
+		Code:      "db-down",
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snaps", nil)
+	c.Assert(err, check.IsNil)
+	rsp, ok := getSnapsInfo(snapsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(rsp.Status, check.Equals, 200)
+	raws, ok := rsp.Result.([]*json.RawMessage)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(raws, check.HasLen, 2)
+	for _, raw := range raws {
+		var snp client.Snap
+		c.Assert(json.Unmarshal(*raw, &snp), check.IsNil)
+		if snp.Name == "foo" {
+			c.Check(snp.Health, check.DeepEquals, expected)
+		} else {
+			c.Check(snp.Health, check.IsNil)
+		}
+	}
+
+	s.vars = map[string]string{"name": "foo"}
+	req, err = http.NewRequest("GET", "/v2/snaps/foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp, ok = getSnapInfo(snapCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(rsp.Result, check.FitsTypeOf, &client.Snap{})
+	c.Check(rsp.Result.(*client.Snap).Health, check.DeepEquals, expected)
+}
+
 func (s *apiSuite) TestSnapsInfoOnlyLocal(c *check.C) {
 	d := s.daemon(c)
 
@@ -2049,6 +2152,71 @@ func (s *apiSuite) TestPostSnap(c *check.C) {
 	c.Check(soon, check.Equals, 1)
 }
 
+func (s *apiSuite) TestPostSnapQueue(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	ensureStateSoon = func(st *state.State) {}
+
+	st := d.overlord.State()
+	st.Lock()
+	other := st.NewChange("install-snap", "...")
+	other.AddTask(st.NewTask("busy", "..."))
+	st.Unlock()
+
+	var conflict error = &snapstate.ChangeConflictError{Snap: "foo", ChangeKind: "install-snap", ChangeID: other.ID()}
+	var calls []int
+	snapInstructionDispTable["remove"] = func(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+		calls = append(calls, inst.userID)
+		if conflict != nil {
+			return "", nil, conflict
+		}
+		return "Remove foo", []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-remove", "..."))}, nil
+	}
+	defer func() {
+		snapInstructionDispTable["remove"] = snapRemove
+	}()
+
+	s.vars = map[string]string{"name": "foo"}
+
+	// without queue the conflict is reported
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", bytes.NewBufferString(`{"action": "remove"}`))
+	c.Assert(err, check.IsNil)
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot remove "foo": snap "foo" has "install-snap" change in progress`)
+
+	req, err = http.NewRequest("POST", "/v2/snaps/foo", bytes.NewBufferString(`{"action": "remove", "queue": true}`))
+	c.Assert(err, check.IsNil)
+	rsp = postSnap(snapCmd, req, &auth.UserState{ID: 42}).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "remove-snap")
+	c.Check(chg.Summary(), check.Equals, `Queued remove of "foo"`)
+	c.Check(chg.WaitChanges(), check.DeepEquals, []*state.Change{other})
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"foo"})
+	// like for the unqueued single snap operations
+	var apiData map[string]interface{}
+	c.Check(chg.Get("api-data", &apiData), check.Equals, state.ErrNoState)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "run-queued-op")
+
+	// the queued instruction is performed once the conflict is gone
+	conflict = nil
+	var data *json.RawMessage
+	c.Assert(tasks[0].Get("op-data", &data), check.IsNil)
+	tsets, err := runQueuedSnapInstruction(st, data)
+	c.Assert(err, check.IsNil)
+	c.Assert(tsets, check.HasLen, 1)
+	c.Check(tsets[0].Tasks()[0].Kind(), check.Equals, "fake-remove")
+	c.Check(calls, check.DeepEquals, []int{0, 42, 42})
+}
+
 func (s *apiSuite) TestPostSnapVerfySnapInstruction(c *check.C) {
 	s.daemonWithOverlordMock(c)
 
@@ -2645,6 +2813,63 @@ func (s *apiSuite) TestGetRootDocument(c *check.C) {
 	c.Check(result, check.DeepEquals, map[string]interface{}{"test-key1": "test-value1", "test-key2": "test-value2"})
 }
 
+func (s *apiSuite) runGetConfSchema(c *check.C, snapName string, keys []string, statusCode int) map[string]interface{} {
+	s.vars = map[string]string{"name": snapName}
+	req, err := http.NewRequest("GET", "/v2/snaps/"+snapName+"/conf?schema=true&keys="+strings.Join(keys, ","), nil)
+	c.Check(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	snapConfCmd.GET(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, statusCode)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	return body["result"].(map[string]interface{})
+}
+
+func (s *apiSuite) TestGetConfSchema(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, `
+name: schema-snap
+version: 1
+config:
+    port:
+        type: integer
+        default: 8080
+    server:
+        type: object
+        properties:
+            host:
+                type: string
+`)
+
+	result := s.runGetConfSchema(c, "schema-snap", nil, 200)
+	c.Check(result, check.DeepEquals, map[string]interface{}{
+		"port": map[string]interface{}{"type": "integer", "default": 8080.},
+		"server": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"host": map[string]interface{}{"type": "string"},
+			},
+		},
+	})
+
+	result = s.runGetConfSchema(c, "schema-snap", []string{"server.host"}, 200)
+	c.Check(result, check.DeepEquals, map[string]interface{}{
+		"server.host": map[string]interface{}{"type": "string"},
+	})
+
+	result = s.runGetConfSchema(c, "schema-snap", []string{"other"}, 400)
+	c.Check(result["message"], check.Equals, `snap "schema-snap" does not declare a "other" configuration option`)
+}
+
+func (s *apiSuite) TestGetConfSchemaNoSnap(c *check.C) {
+	s.daemon(c)
+
+	result := s.runGetConfSchema(c, "no-snap", nil, 404)
+	c.Check(result["message"], check.Equals, `snap "no-snap" is not installed`)
+}
+
 func (s *apiSuite) TestGetConfBadKey(c *check.C) {
 	// TODO: this one in particular should really be a 400 also
 	result := s.runGetConf(c, []string{"."}, 500)
@@ -2744,6 +2969,62 @@ func (s *apiSuite) TestSetConfNumber(c *check.C) {
 	c.Assert(result, check.DeepEquals, json.Number("1234567890"))
 }
 
+func (s *apiSuite) TestSetConfUnset(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	// Mock the hook runner
+	hookRunner := testutil.MockCommand(c, "snap", "")
+	defer hookRunner.Restore()
+
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("config-snap", "key", "value")
+	tr.Set("config-snap", "nested", map[string]interface{}{"one": 1, "two": 2})
+	tr.Commit()
+	st.Unlock()
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	text, err := json.Marshal(map[string]interface{}{"key": nil, "nested.one": nil})
+	c.Assert(err, check.IsNil)
+
+	buffer := bytes.NewBuffer(text)
+	req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf", buffer)
+	c.Assert(err, check.IsNil)
+
+	s.vars = map[string]string{"name": "config-snap"}
+
+	rec := httptest.NewRecorder()
+	snapConfCmd.PUT(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 202)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	id := body["change"].(string)
+
+	st.Lock()
+	chg := st.Change(id)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Err(), check.IsNil)
+
+	tr = config.NewTransaction(st)
+	var result interface{}
+	c.Check(config.IsNoOption(tr.Get("config-snap", "key", &result)), check.Equals, true)
+	c.Check(config.IsNoOption(tr.Get("config-snap", "nested.one", &result)), check.Equals, true)
+	c.Assert(tr.Get("config-snap", "nested", &result), check.IsNil)
+	c.Check(result, check.DeepEquals, map[string]interface{}{"two": json.Number("2")})
+}
+
 func (s *apiSuite) TestSetConfBadSnap(c *check.C) {
 	s.daemonWithOverlordMock(c)
 
@@ -3073,7 +3354,7 @@ func (s *apiSuite) TestRefreshIgnoreValidation(c *check.C) {
 
 func (s *apiSuite) TestPostSnapsOp(c *check.C) {
 	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, transaction snapstate.TransactionType) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 0)
 		t := s.NewTask("fake-refresh-all", "Refreshing everything")
 		return []string{"fake1", "fake2"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -3100,6 +3381,68 @@ func (s *apiSuite) TestPostSnapsOp(c *check.C) {
 	c.Check(apiData["snap-names"], check.DeepEquals, []interface{}{"fake1", "fake2"})
 }
 
+func (s *apiSuite) TestPostSnapsOpTransaction(c *check.C) {
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, transaction snapstate.TransactionType) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.DeepEquals, []string{"foo", "bar"})
+		c.Check(transaction, check.Equals, snapstate.TransactionAllSnaps)
+		t := s.NewTask("fake-refresh-2", "Refreshing two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "snaps": ["foo", "bar"], "transaction": "all-snaps"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+}
+
+func (s *apiSuite) TestPostSnapsOpBadTransaction(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "refresh", "transaction": "some-snaps"}`, `invalid value for transaction type: some-snaps`},
+		{`{"action": "remove", "snaps": ["foo"], "transaction": "all-snaps"}`, `transaction type is unsupported for "remove" actions`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp := postSnaps(snapsCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
+}
+
+func (s *apiSuite) TestPostSnapBadTransaction(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "refresh", "transaction": "some-snaps"}`, `invalid value for transaction type: some-snaps`},
+		{`{"action": "remove", "transaction": "all-snaps"}`, `transaction type is unsupported for "remove" actions`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps/foo", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		s.vars = map[string]string{"name": "foo"}
+
+		rsp := postSnap(snapCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
+}
+
 func (s *apiSuite) TestRefreshAll(c *check.C) {
 	refreshSnapDecls := false
 	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
@@ -3118,7 +3461,7 @@ func (s *apiSuite) TestRefreshAll(c *check.C) {
 	} {
 		refreshSnapDecls = false
 
-		snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+		snapstateUpdateMany = func(s *state.State, names []string, userID int, transaction snapstate.TransactionType) ([]string, []*state.TaskSet, error) {
 			c.Check(names, check.HasLen, 0)
 			t := s.NewTask("fake-refresh-all", "Refreshing everything")
 			return tst.snaps, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -3142,7 +3485,7 @@ func (s *apiSuite) TestRefreshAllNoChanges(c *check.C) {
 		return assertstate.RefreshSnapDeclarations(s, userID)
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, transaction snapstate.TransactionType) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 0)
 		return nil, nil, nil
 	}
@@ -3165,7 +3508,7 @@ func (s *apiSuite) TestRefreshMany(c *check.C) {
 		return nil
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, transaction snapstate.TransactionType) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
 		t := s.NewTask("fake-refresh-2", "Refreshing two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -3190,7 +3533,7 @@ func (s *apiSuite) TestRefreshMany1(c *check.C) {
 		return nil
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, transaction snapstate.TransactionType) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 1)
 		t := s.NewTask("fake-refresh-1", "Refreshing one")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -3209,8 +3552,9 @@ func (s *apiSuite) TestRefreshMany1(c *check.C) {
 }
 
 func (s *apiSuite) TestInstallMany(c *check.C) {
-	snapstateInstallMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateInstallMany = func(s *state.State, names []string, userID int, transaction snapstate.TransactionType) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
+		c.Check(transaction, check.Equals, snapstate.TransactionPerSnap)
 		t := s.NewTask("fake-install-2", "Install two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
 	}
@@ -3227,8 +3571,9 @@ func (s *apiSuite) TestInstallMany(c *check.C) {
 }
 
 func (s *apiSuite) TestRemoveMany(c *check.C) {
-	snapstateRemoveMany = func(s *state.State, names []string) ([]string, []*state.TaskSet, error) {
+	snapstateRemoveMany = func(s *state.State, names []string, flags *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
+		c.Check(flags, check.DeepEquals, &snapstate.RemoveFlags{})
 		t := s.NewTask("fake-remove-2", "Remove two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
 	}
@@ -3244,6 +3589,81 @@ func (s *apiSuite) TestRemoveMany(c *check.C) {
 	c.Check(res.affected, check.DeepEquals, inst.Snaps)
 }
 
+func (s *apiSuite) TestPostSnapsOpQueue(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+	ensureStateSoon = func(st *state.State) {}
+
+	st := d.overlord.State()
+	st.Lock()
+	other := st.NewChange("install-snap", "...")
+	other.AddTask(st.NewTask("busy", "..."))
+	st.Unlock()
+
+	var conflict error = &snapstate.ChangeConflictError{Snap: "foo", ChangeKind: "install-snap", ChangeID: other.ID()}
+	snapstateRemoveMany = func(s *state.State, names []string, flags *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
+		if conflict != nil {
+			return nil, nil, conflict
+		}
+		t := s.NewTask("fake-remove-2", "Remove two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	buf := bytes.NewBufferString(`{"action": "remove", "snaps": ["foo", "bar"], "queue": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Queued remove of "foo", "bar"`)
+	c.Check(chg.WaitChanges(), check.DeepEquals, []*state.Change{other})
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{
+		"snap-names": []interface{}{"foo", "bar"},
+	})
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"foo", "bar"})
+
+	conflict = nil
+	var data *json.RawMessage
+	c.Assert(chg.Tasks()[0].Get("op-data", &data), check.IsNil)
+	tsets, err := runQueuedSnapInstruction(st, data)
+	c.Assert(err, check.IsNil)
+	c.Assert(tsets, check.HasLen, 1)
+	c.Check(tsets[0].Tasks()[0].Kind(), check.Equals, "fake-remove-2")
+}
+
+func (s *apiSuite) TestRemoveManyPurge(c *check.C) {
+	snapstateRemoveMany = func(s *state.State, names []string, flags *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
+		c.Check(flags, check.DeepEquals, &snapstate.RemoveFlags{Purge: true})
+		t := s.NewTask("fake-remove-2", "Remove two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "remove", "snaps": ["foo", "bar"], "purge": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Check(chg.Summary(), check.Equals, `Remove snaps "foo", "bar"`)
+}
+
 func (s *apiSuite) TestInstallFails(c *check.C) {
 	snapstateInstall = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
 		t := s.NewTask("fake-install-snap-error", "Install task")
@@ -4234,6 +4654,43 @@ func (s *apiSuite) TestAssertsFindManyNoResults(c *check.C) {
 	c.Check(err, check.Equals, io.EOF)
 }
 
+func (s *apiSuite) TestAssertsFindManyRevoked(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	st := d.overlord.State()
+	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", map[string]interface{}{
+		"account-id": "developer1-id",
+	}, "")
+	assertAdd(st, acct)
+	devPrivKey, _ := assertstest.GenerateKey(752)
+	devKey := assertstest.NewAccountKey(s.storeSigning, acct, nil, devPrivKey.PublicKey(), "")
+	assertAdd(st, devKey)
+	rev, err := s.storeSigning.Sign(asserts.RevocationType, map[string]interface{}{
+		"account-id":   "developer1-id",
+		"revoked-keys": []interface{}{devKey.PublicKeyID()},
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	assertAdd(st, rev)
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/assertions/account-key?revoked=true", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"assertType": "account-key"}
+	rec := httptest.NewRecorder()
+	assertsFindManyCmd.GET(assertsFindManyCmd, req, nil).ServeHTTP(rec, req)
+	// Verify
+	c.Check(rec.Code, check.Equals, 200, check.Commentf("body %q", rec.Body))
+	c.Check(rec.HeaderMap.Get("X-Ubuntu-Assertions-Count"), check.Equals, "1")
+	dec := asserts.NewDecoder(rec.Body)
+	a1, err := dec.Decode()
+	c.Assert(err, check.IsNil)
+	c.Check(a1.(*asserts.AccountKey).PublicKeyID(), check.Equals, devKey.PublicKeyID())
+	_, err = dec.Decode()
+	c.Check(err, check.Equals, io.EOF)
+}
+
 func (s *apiSuite) TestAssertsInvalidType(c *check.C) {
 	// Execute
 	req, err := http.NewRequest("POST", "/v2/assertions/foo", nil)
@@ -4399,6 +4856,93 @@ func (s *apiSuite) TestStateChangesForSnapName(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *apiSuite) TestStateChangesSince(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes?select=all&since=2016-04-21T01:02:03Z", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.HasLen, 2)
+
+	req, err = http.NewRequest("GET", "/v2/changes?select=all&since=2016-04-21T01:02:04Z", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.HasLen, 0)
+}
+
+func (s *apiSuite) TestStateChangesBadSince(c *check.C) {
+	newTestDaemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/changes?since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot parse since: .*`)
+}
+
+func (s *apiSuite) TestStateChangesArchived(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	chg1 := st.Change(ids[0])
+	for _, t := range chg1.Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "changes.archive", true), check.IsNil)
+	tr.Commit()
+	// archives both changes
+	st.Prune(0, time.Hour, 100)
+	c.Assert(st.Changes(), check.HasLen, 0)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes?select=archived", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	// Verify
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 2)
+
+	res, err := rsp.MarshalJSON()
+	c.Assert(err, check.IsNil)
+
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Done","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Done","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":0},"status-changes":\[{"status":"Done","time":"2016-04-21T01:02:03Z"}\],"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*],"ready":true,"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*`)
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"\w+","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],.*"ready":true,"err":"[^"]+".*`)
+
+	// filtered by snap
+	req, err = http.NewRequest("GET", "/v2/changes?select=archived&for=funky-snap-name", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 1)
+	c.Check(rsp.Result.([]*changeInfo)[0].Kind, check.Equals, "install")
+
+	// and by time
+	req, err = http.NewRequest("GET", "/v2/changes?select=archived&since=2016-04-22T00:00:00Z", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.HasLen, 0)
+}
+
 func (s *apiSuite) TestStateChange(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
@@ -5841,6 +6385,54 @@ func (s *postDebugSuite) TestPostDebugGetBaseDeclaration(c *check.C) {
 		testutil.Contains, "type: base-declaration")
 }
 
+func (s *postDebugSuite) TestGetDebugTaskMetrics(c *check.C) {
+	d := s.daemon(c)
+
+	st := d.overlord.State()
+	r := state.NewTaskRunner(st)
+	r.AddHandler("foo", func(t *state.Task, _ *tomb.Tomb) error { return nil }, nil)
+	st.Lock()
+	chg := st.NewChange("foo", "...")
+	chg.AddTask(st.NewTask("foo", "..."))
+	st.Unlock()
+	r.Ensure()
+	r.Wait()
+	r.Stop()
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=task-metrics", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Assert(rsp.Result, check.FitsTypeOf, []*metrics.Metric(nil))
+	found := false
+	for _, m := range rsp.Result.([]*metrics.Metric) {
+		if m.Name != "snapd_task_handler_seconds" {
+			continue
+		}
+		found = true
+		c.Assert(m.Series, check.HasLen, 1)
+		c.Check(m.Series[0].LabelValue, check.Equals, "foo")
+		c.Check(m.Series[0].Count, check.Equals, uint64(1))
+	}
+	c.Check(found, check.Equals, true)
+}
+
+func (s *postDebugSuite) TestGetDebugBadAspect(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct{ query, err string }{
+		{"", `missing debug aspect`},
+		{"?aspect=foo", `unknown debug aspect "foo"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/debug"+t.query, nil)
+		c.Assert(err, check.IsNil)
+		rsp := getDebug(debugCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
+}
+
 type appSuite struct {
 	apiBaseSuite
 	cmd *testutil.MockCmd
@@ -5999,6 +6591,48 @@ UnitFileState=enabled
 	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
 }
 
+func (s *appSuite) TestGetAppsInfoServiceWithTimer(c *check.C) {
+	s.mkInstalledInState(c, s.d, "snap-e", "dev", "v1", snap.R(1), true, "apps: {svc4: {daemon: oneshot, timer: \"mon,10:00\"}}")
+
+	s.sysctlBufs = [][]byte{
+		[]byte(`
+Id=snap.snap-e.svc4.service
+Type=oneshot
+ActiveState=inactive
+UnitFileState=disabled
+`[1:]),
+		[]byte(`
+Id=snap.snap-e.svc4.timer
+ActiveState=active
+UnitFileState=enabled
+NextElapseUSecRealtime=Mon 2018-04-23 10:00:00 UTC
+`[1:]),
+	}
+
+	req, err := http.NewRequest("GET", "/v2/apps?names=snap-e", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Assert(rsp.Result, check.FitsTypeOf, []client.AppInfo{})
+	c.Check(rsp.Result.([]client.AppInfo), check.DeepEquals, []client.AppInfo{{
+		Snap:   "snap-e",
+		Name:   "svc4",
+		Daemon: "oneshot",
+		Timer: &client.AppTimer{
+			Schedule: "mon,10:00",
+			Enabled:  true,
+			Active:   true,
+			Next:     time.Date(2018, 4, 23, 10, 0, 0, 0, time.UTC),
+		},
+	}})
+	c.Check(s.sysctlArgses, check.DeepEquals, [][]string{
+		{"show", "--property=Id,Type,ActiveState,UnitFileState", "snap.snap-e.svc4.service"},
+		{"show", "--property=Id,ActiveState,UnitFileState,NextElapseUSecRealtime", "snap.snap-e.svc4.timer"},
+	})
+}
+
 func (s *appSuite) TestGetAppsInfoBadSelect(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/apps?select=potato", nil)
 	c.Assert(err, check.IsNil)
@@ -6026,6 +6660,23 @@ func (s *appSuite) TestAppInfosForOne(c *check.C) {
 	c.Check(appInfos[0].Name, check.Equals, "svc1")
 }
 
+func (s *appSuite) TestAppInfosForResourceOverrides(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "resources.snap-a", map[string]interface{}{
+		"svc2": map[string]interface{}{"memory": "64M"},
+	}), check.IsNil)
+	tr.Commit()
+	st.Unlock()
+
+	appInfos, rsp := appInfosFor(st, []string{"snap-a"}, appInfoOptions{service: true})
+	c.Assert(rsp, check.IsNil)
+	c.Assert(appInfos, check.HasLen, 2)
+	c.Check(appInfos[0].Resources, check.IsNil)
+	c.Check(appInfos[1].Resources, check.DeepEquals, &snap.ResourceLimits{Memory: 64 << 20})
+}
+
 func (s *appSuite) TestAppInfosForAll(c *check.C) {
 	type T struct {
 		opts  appInfoOptions
@@ -6416,7 +7067,7 @@ func (s *appSuite) TestPostAppsConflict(c *check.C) {
 		}
 	}()
 
-	ts, err := snapstate.Remove(st, "snap-a", snap.R(0))
+	ts, err := snapstate.Remove(st, "snap-a", snap.R(0), nil)
 	c.Assert(err, check.IsNil)
 	// need a change to make the tasks visible
 	st.NewChange("enable", "...").AddAll(ts)
@@ -0,0 +1,176 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// A postValidationSetData is used to apply or forget a validation set
+// keep this in sync with client/validation_sets.go's postValidationSetData
+type postValidationSetData struct {
+	Action   string `json:"action"`
+	Mode     string `json:"mode,omitempty"`
+	Sequence int    `json:"sequence,omitempty"`
+}
+
+var assertstateApplyValidationSet = assertstate.ApplyValidationSet
+
+func clientValidationSetResult(st *state.State, vst *assertstate.ValidationSetTracking) (*client.ValidationSetResult, error) {
+	vs, err := assertstate.ValidationSetAssertion(st, vst)
+	if err != nil {
+		return nil, err
+	}
+	problems, err := assertstate.ValidationSetProblems(st, vs)
+	if err != nil {
+		return nil, err
+	}
+	return &client.ValidationSetResult{
+		AccountID: vst.AccountID,
+		Name:      vst.Name,
+		Mode:      string(vst.Mode),
+		Sequence:  vst.Sequence,
+		Pinned:    vst.Pinned,
+		Valid:     len(problems) == 0,
+		Problems:  problems,
+	}, nil
+}
+
+// getValidationSets returns all the tracked validation sets, sorted by
+// account-id and name.
+func getValidationSets(c *Command, r *http.Request, _ *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	vsets, err := assertstate.ValidationSets(st)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	keys := make([]string, 0, len(vsets))
+	for key := range vsets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	results := make([]*client.ValidationSetResult, len(keys))
+	for i, key := range keys {
+		res, err := clientValidationSetResult(st, vsets[key])
+		if err != nil {
+			return InternalError("cannot check validation set %q: %v", key, err)
+		}
+		results[i] = res
+	}
+	return SyncResponse(results, nil)
+}
+
+func validationSetVars(r *http.Request) (accountID, name string, rsp Response) {
+	vars := muxVars(r)
+	accountID, name, err := asserts.ParseValidationSetKey(vars["account"] + "/" + vars["name"])
+	if err != nil {
+		return "", "", BadRequest("%v", err)
+	}
+	return accountID, name, nil
+}
+
+// getValidationSet returns the given tracked validation set.
+func getValidationSet(c *Command, r *http.Request, _ *auth.UserState) Response {
+	accountID, name, rsp := validationSetVars(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	vst, err := assertstate.GetValidationSet(st, accountID, name)
+	if err == state.ErrNoState {
+		return NotFound("validation set %q is not being tracked", assertstate.ValidationSetKey(accountID, name))
+	}
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	res, err := clientValidationSetResult(st, vst)
+	if err != nil {
+		return InternalError("cannot check validation set %q: %v", vst.Key(), err)
+	}
+	return SyncResponse(res, nil)
+}
+
+// postValidationSet applies or forgets the given validation set.
+func postValidationSet(c *Command, r *http.Request, user *auth.UserState) Response {
+	accountID, name, rsp := validationSetVars(r)
+	if rsp != nil {
+		return rsp
+	}
+
+	var data postValidationSetData
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&data); err != nil {
+		return BadRequest("cannot decode validation set action from request body: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found in request body")
+	}
+	if data.Sequence < 0 {
+		return BadRequest("invalid sequence %d", data.Sequence)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	switch data.Action {
+	case "apply":
+		mode := assertstate.ValidationSetMode(data.Mode)
+		if mode != assertstate.MonitorMode && mode != assertstate.EnforceMode {
+			return BadRequest("invalid validation set mode %q", data.Mode)
+		}
+		userID := 0
+		if user != nil {
+			userID = user.ID
+		}
+		vst, err := assertstateApplyValidationSet(st, accountID, name, data.Sequence, mode, userID)
+		if err != nil {
+			return BadRequest("%v", err)
+		}
+		res, err := clientValidationSetResult(st, vst)
+		if err != nil {
+			return InternalError("cannot check validation set %q: %v", vst.Key(), err)
+		}
+		return SyncResponse(res, nil)
+	case "forget":
+		if err := assertstate.ForgetValidationSet(st, accountID, name); err != nil {
+			return NotFound("%v", err)
+		}
+		return SyncResponse(nil, nil)
+	default:
+		return BadRequest("unknown validation set action %q", data.Action)
+	}
+}
@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&validationSetsSuite{})
+
+type validationSetsSuite struct {
+	apiBaseSuite
+}
+
+func (s *validationSetsSuite) TearDownTest(c *check.C) {
+	s.apiBaseSuite.TearDownTest(c)
+
+	assertstateApplyValidationSet = assertstate.ApplyValidationSet
+}
+
+func (s *validationSetsSuite) mockValidationSet(c *check.C) {
+	st := s.d.overlord.State()
+	assertAdd(st, s.storeSigning.StoreAccountKey(""))
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", map[string]interface{}{
+		"account-id": "developer1-id",
+	}, "")
+	assertAdd(st, acct)
+	devPrivKey, _ := assertstest.GenerateKey(752)
+	assertAdd(st, assertstest.NewAccountKey(s.storeSigning, acct, nil, devPrivKey.PublicKey(), ""))
+	devSigning := assertstest.NewSigningDB("developer1-id", devPrivKey)
+
+	vs, err := devSigning.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"series":     "16",
+		"account-id": "developer1-id",
+		"name":       "base-set",
+		"sequence":   "2",
+		"snaps": []interface{}{
+			map[string]interface{}{"name": "foo", "revision": "7"},
+			map[string]interface{}{"name": "bar", "presence": "optional"},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	assertAdd(st, vs)
+
+	st.Lock()
+	defer st.Unlock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(5)}},
+		Current:  snap.R(5),
+	})
+}
+
+func (s *validationSetsSuite) postValidationSet(c *check.C, body string) *resp {
+	req, err := http.NewRequest("POST", "/v2/validation-sets/developer1-id/base-set", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"account": "developer1-id", "name": "base-set"}
+	rsp, ok := postValidationSet(validationSetCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	return rsp
+}
+
+func (s *validationSetsSuite) TestApplyAndGetValidationSets(c *check.C) {
+	s.daemon(c)
+	s.mockValidationSet(c)
+
+	expected := &client.ValidationSetResult{
+		AccountID: "developer1-id",
+		Name:      "base-set",
+		Mode:      "monitor",
+		Sequence:  2,
+		Pinned:    true,
+		Valid:     false,
+		Problems:  []string{`snap "foo" is at revision 5 but revision 7 is required`},
+	}
+
+	rsp := s.postValidationSet(c, `{"action": "apply", "mode": "monitor", "sequence": 2}`)
+	c.Assert(rsp.Status, check.Equals, 200, check.Commentf("%v", rsp.Result))
+	c.Check(rsp.Result, check.DeepEquals, expected)
+
+	req, err := http.NewRequest("GET", "/v2/validation-sets", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getValidationSets(validationSetsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []*client.ValidationSetResult{expected})
+
+	req, err = http.NewRequest("GET", "/v2/validation-sets/developer1-id/base-set", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getValidationSet(validationSetCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, expected)
+
+	// the result marshals as expected
+	b, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, `{"account-id":"developer1-id","name":"base-set","mode":"monitor","sequence":2,"pinned":true,"valid":false,"problems":["snap \"foo\" is at revision 5 but revision 7 is required"]}`)
+
+	// enforcing fails as the system does not comply
+	rsp = s.postValidationSet(c, `{"action": "apply", "mode": "enforce"}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `cannot enforce validation set "developer1-id/base-set":\n.*`)
+
+	rsp = s.postValidationSet(c, `{"action": "forget"}`)
+	c.Check(rsp.Status, check.Equals, 200)
+
+	rsp = getValidationSet(validationSetCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `validation set "developer1-id/base-set" is not being tracked`)
+}
+
+func (s *validationSetsSuite) TestApplyValidationSetUser(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	called := 0
+	assertstateApplyValidationSet = func(st *state.State, accountID, name string, sequence int, mode assertstate.ValidationSetMode, userID int) (*assertstate.ValidationSetTracking, error) {
+		called++
+		c.Check(accountID, check.Equals, "developer1-id")
+		c.Check(name, check.Equals, "base-set")
+		c.Check(sequence, check.Equals, 0)
+		c.Check(mode, check.Equals, assertstate.EnforceMode)
+		c.Check(userID, check.Equals, 0)
+		return nil, &asserts.NotFoundError{Type: asserts.ValidationSetType}
+	}
+
+	rsp := s.postValidationSet(c, `{"action": "apply", "mode": "enforce"}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(called, check.Equals, 1)
+}
+
+func (s *validationSetsSuite) TestPostValidationSetErrors(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	rsp := s.postValidationSet(c, `{"action": "frobnicate"}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `unknown validation set action "frobnicate"`)
+
+	rsp = s.postValidationSet(c, `{"action": "apply", "mode": "bogus"}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `invalid validation set mode "bogus"`)
+
+	rsp = s.postValidationSet(c, `{"action": "apply", "mode": "monitor", "sequence": -1}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `invalid sequence -1`)
+
+	rsp = s.postValidationSet(c, `{"action": "forget"} {}`)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `extra content found in request body`)
+
+	rsp = s.postValidationSet(c, `{"action": "forget"}`)
+	c.Check(rsp.Status, check.Equals, 404)
+
+	req, err := http.NewRequest("GET", "/v2/validation-sets/developer1-id/Bad_Name", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"account": "developer1-id", "name": "Bad_Name"}
+	rsp = getValidationSet(validationSetCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `invalid validation set name "Bad_Name"`)
+}
@@ -58,6 +58,9 @@ type Daemon struct {
 	snapServe     *shutdownServer
 	tomb          tomb.Tomb
 	router        *mux.Router
+	// metricsListener is only set if the metrics socket is enabled
+	metricsListener net.Listener
+	metricsServe    *shutdownServer
 	// enableInternalInterfaceActions controls if adding and removing slots and plugs is allowed.
 	enableInternalInterfaceActions bool
 }
@@ -242,6 +245,19 @@ func getListener(socketPath string, listenerMap map[string]net.Listener) (net.Li
 		return listener, nil
 	}
 
+	listener, err := listenUnix(socketPath, 0111)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debugf("socket %q was not activated; listening", socketPath)
+
+	return listener, nil
+}
+
+// listenUnix listens on the given socket path, creating the socket with
+// the given umask, unless the socket is already in use.
+func listenUnix(socketPath string, mask int) (net.Listener, error) {
 	if c, err := net.Dial("unix", socketPath); err == nil {
 		c.Close()
 		return nil, fmt.Errorf("socket %q already in use", socketPath)
@@ -257,19 +273,22 @@ func getListener(socketPath string, listenerMap map[string]net.Listener) (net.Li
 	}
 
 	runtime.LockOSThread()
-	oldmask := unix.Umask(0111)
+	oldmask := unix.Umask(mask)
 	listener, err := net.ListenUnix("unix", address)
 	unix.Umask(oldmask)
 	runtime.UnlockOSThread()
 	if err != nil {
 		return nil, err
 	}
-
-	logger.Debugf("socket %q was not activated; listening", socketPath)
-
 	return listener, nil
 }
 
+// getMetricsListener listens on the metrics socket, which unlike the
+// snapd socket is only accessible to root and is never socket-activated.
+func getMetricsListener() (net.Listener, error) {
+	return listenUnix(dirs.SnapdMetricsSocket, 0177)
+}
+
 // Init sets up the Daemon's internal workings.
 // Don't call more than once.
 func (d *Daemon) Init() error {
@@ -301,6 +320,16 @@ func (d *Daemon) Init() error {
 		logger.Debugf("cannot get listener for %q: %v", dirs.SnapSocket, err)
 	}
 
+	// The metrics socket is optional, used if asked for through the
+	// environment.
+	if osutil.GetenvBool("SNAPD_METRICS_SOCKET") {
+		if listener, err := getMetricsListener(); err == nil {
+			d.metricsListener = listener
+		} else {
+			logger.Noticef("cannot get listener for %q: %v", dirs.SnapdMetricsSocket, err)
+		}
+	}
+
 	d.addRoutes()
 
 	logger.Noticef("started %v.", httputil.UserAgent())
@@ -321,6 +350,23 @@ func (d *Daemon) addRoutes() {
 	d.router.NotFoundHandler = NotFound("not found")
 }
 
+// metricsHandler serves the task metrics in the Prometheus text
+// exposition format.
+type metricsHandler struct {
+	d *Daemon
+}
+
+func (h metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := h.d.overlord.State().Metrics().WritePrometheus(w); err != nil {
+		logger.Debugf("cannot write metrics: %v", err)
+	}
+}
+
 var (
 	shutdownTimeout = 5 * time.Second
 )
@@ -424,6 +470,9 @@ func (d *Daemon) Start() {
 		d.snapServe = newShutdownServer(d.snapListener, logit(d.router))
 	}
 	d.snapdServe = newShutdownServer(d.snapdListener, logit(d.router))
+	if d.metricsListener != nil {
+		d.metricsServe = newShutdownServer(d.metricsListener, metricsHandler{d})
+	}
 
 	// the loop runs in its own goroutine
 	d.overlord.Loop()
@@ -439,6 +488,16 @@ func (d *Daemon) Start() {
 			})
 		}
 
+		if d.metricsListener != nil {
+			d.tomb.Go(func() error {
+				if err := d.metricsServe.Serve(); err != nil && d.tomb.Err() == tomb.ErrStillAlive {
+					return err
+				}
+
+				return nil
+			})
+		}
+
 		if err := d.snapdServe.Serve(); err != nil && d.tomb.Err() == tomb.ErrStillAlive {
 			return err
 		}
@@ -454,11 +513,17 @@ func (d *Daemon) Stop() error {
 	if d.snapListener != nil {
 		d.snapListener.Close()
 	}
+	if d.metricsListener != nil {
+		d.metricsListener.Close()
+	}
 
 	d.tomb.Kill(d.snapdServe.finishShutdown())
 	if d.snapListener != nil {
 		d.tomb.Kill(d.snapServe.finishShutdown())
 	}
+	if d.metricsListener != nil {
+		d.tomb.Kill(d.metricsServe.finishShutdown())
+	}
 
 	d.overlord.Stop()
 
@@ -390,6 +390,52 @@ func (s *daemonSuite) TestStartStop(c *check.C) {
 	c.Check(err, check.IsNil)
 }
 
+func (s *daemonSuite) TestMetricsSocket(c *check.C) {
+	d := newTestDaemon(c)
+	// mark as already seeded
+	s.markSeeded(d)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	metricsL, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.metricsListener = metricsL
+
+	d.Start()
+
+	rsp, err := http.Get(fmt.Sprintf("http://%s/metrics", metricsL.Addr()))
+	c.Assert(err, check.IsNil)
+	body, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	c.Assert(err, check.IsNil)
+	c.Check(rsp.StatusCode, check.Equals, 200)
+	c.Check(rsp.Header.Get("Content-Type"), check.Equals, "text/plain; version=0.0.4")
+	c.Check(string(body), testutil.Contains, "# TYPE snapd_task_handler_seconds histogram\n")
+
+	rsp, err = http.Post(fmt.Sprintf("http://%s/metrics", metricsL.Addr()), "text/plain", nil)
+	c.Assert(err, check.IsNil)
+	rsp.Body.Close()
+	c.Check(rsp.StatusCode, check.Equals, 405)
+
+	err = d.Stop()
+	c.Check(err, check.IsNil)
+}
+
+func (s *daemonSuite) TestMetricsSocketPermissions(c *check.C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapdMetricsSocket), 0755), check.IsNil)
+
+	l, err := getMetricsListener()
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+
+	st, err := os.Stat(dirs.SnapdMetricsSocket)
+	c.Assert(err, check.IsNil)
+	c.Check(st.Mode()&os.ModeSocket, check.Equals, os.ModeSocket)
+	c.Check(st.Mode().Perm(), check.Equals, os.FileMode(0600))
+}
+
 func (s *daemonSuite) TestRestartWiring(c *check.C) {
 	d := newTestDaemon(c)
 	// mark as already seeded
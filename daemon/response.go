@@ -146,6 +146,8 @@ const (
 	errorKindBadQuery = errorKind("bad-query")
 
 	errorKindNetworkTimeout = errorKind("network-timeout")
+
+	errorKindUnsuccessful = errorKind("unsuccessful")
 )
 
 type errorValue interface{}
@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/healthstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/progress"
@@ -79,6 +80,20 @@ type aboutSnap struct {
 	info      *snap.Info
 	snapst    *snapstate.SnapState
 	publisher string
+	health    *client.SnapHealth
+}
+
+func clientHealthFromHealthstate(h *healthstate.HealthState) *client.SnapHealth {
+	if h == nil {
+		return nil
+	}
+	return &client.SnapHealth{
+		Revision:  h.Revision,
+		Timestamp: h.Timestamp,
+		Status:    h.Status.String(),
+		Message:   h.Message,
+		Code:      h.Code,
+	}
 }
 
 // localSnapInfo returns the information about the current snap for the given name plus the SnapState with the active flag and other snap revisions.
@@ -99,16 +114,25 @@ func localSnapInfo(st *state.State, name string) (aboutSnap, error) {
 	if err != nil {
 		return aboutSnap{}, fmt.Errorf("cannot read snap details: %v", err)
 	}
+	if err := snapstate.ApplyResourceOverrides(st, info); err != nil {
+		return aboutSnap{}, err
+	}
 
 	publisher, err := publisherName(st, info)
 	if err != nil {
 		return aboutSnap{}, err
 	}
 
+	health, err := healthstate.Get(st, name)
+	if err != nil {
+		return aboutSnap{}, fmt.Errorf("cannot get health of snap %q: %v", name, err)
+	}
+
 	return aboutSnap{
 		info:      info,
 		snapst:    &snapst,
 		publisher: publisher,
+		health:    clientHealthFromHealthstate(health),
 	}, nil
 }
 
@@ -121,6 +145,10 @@ func allLocalSnapInfos(st *state.State, all bool, wanted map[string]bool) ([]abo
 	if err != nil {
 		return nil, err
 	}
+	healths, err := healthstate.All(st)
+	if err != nil {
+		return nil, err
+	}
 	about := make([]aboutSnap, 0, len(snapStates))
 
 	var firstErr error
@@ -128,6 +156,7 @@ func allLocalSnapInfos(st *state.State, all bool, wanted map[string]bool) ([]abo
 		if len(wanted) > 0 && !wanted[name] {
 			continue
 		}
+		health := clientHealthFromHealthstate(healths[name])
 		var aboutThis []aboutSnap
 		var info *snap.Info
 		var publisher string
@@ -138,15 +167,21 @@ func allLocalSnapInfos(st *state.State, all bool, wanted map[string]bool) ([]abo
 				if err != nil {
 					break
 				}
+				if err = snapstate.ApplyResourceOverrides(st, info); err != nil {
+					break
+				}
 				publisher, err = publisherName(st, info)
-				aboutThis = append(aboutThis, aboutSnap{info, snapst, publisher})
+				aboutThis = append(aboutThis, aboutSnap{info, snapst, publisher, health})
 			}
 		} else {
 			info, err = snapst.CurrentInfo()
+			if err == nil {
+				err = snapstate.ApplyResourceOverrides(st, info)
+			}
 			if err == nil {
 				var publisher string
 				publisher, err = publisherName(st, info)
-				aboutThis = append(aboutThis, aboutSnap{info, snapst, publisher})
+				aboutThis = append(aboutThis, aboutSnap{info, snapst, publisher, health})
 			}
 		}
 
@@ -301,6 +336,22 @@ func clientAppInfosFromSnapAppInfos(apps []*snap.AppInfo) []client.AppInfo {
 				out[i].Enabled = sts[0].Enabled
 				out[i].Active = sts[0].Active
 			}
+			out[i].Resources = app.Resources
+		}
+
+		if app.Timer != nil {
+			timer := &client.AppTimer{Schedule: app.Timer.Timer}
+			timerName := filepath.Base(app.Timer.File())
+			if sts, err := sysd.TimerStatus(timerName); err != nil {
+				logger.Noticef("cannot get status of timer %q: %v", timerName, err)
+			} else if len(sts) != 1 {
+				logger.Noticef("cannot get status of timer %q: expected 1 result, got %d", timerName, len(sts))
+			} else {
+				timer.Enabled = sts[0].Enabled
+				timer.Active = sts[0].Active
+				timer.Next = sts[0].Next
+			}
+			out[i].Timer = timer
 		}
 	}
 
@@ -350,6 +401,7 @@ func mapLocal(about aboutSnap) *client.Snap {
 		Contact:          localSnap.Contact,
 		Title:            localSnap.Title(),
 		License:          localSnap.License,
+		Health:           about.health,
 	}
 
 	return result
@@ -52,6 +52,7 @@ var (
 	SnapMetaDir               string
 	SnapdSocket               string
 	SnapSocket                string
+	SnapdMetricsSocket        string
 	SnapRunDir                string
 	SnapRunNsDir              string
 	SnapRunLockDir            string
@@ -64,7 +65,11 @@ var (
 	SnapTrustedAccountKey string
 	SnapAssertsSpoolDir   string
 
-	SnapStateFile string
+	SnapStateFile        string
+	SnapStateJournalFile string
+	SnapshotsDir         string
+
+	SnapChangesArchiveFile string
 
 	SnapRepairDir        string
 	SnapRepairStateFile  string
@@ -196,12 +201,16 @@ func SetRootDir(rootdir string) {
 	// keep in sync with the debian/snapd.socket file:
 	SnapdSocket = filepath.Join(rootdir, "/run/snapd.socket")
 	SnapSocket = filepath.Join(rootdir, "/run/snapd-snap.socket")
+	SnapdMetricsSocket = filepath.Join(rootdir, "/run/snapd-metrics.socket")
 
 	SnapAssertsDBDir = filepath.Join(rootdir, snappyDir, "assertions")
 	SnapCookieDir = filepath.Join(rootdir, snappyDir, "cookie")
 	SnapAssertsSpoolDir = filepath.Join(rootdir, "run/snapd/auto-import")
 
 	SnapStateFile = filepath.Join(rootdir, snappyDir, "state.json")
+	SnapStateJournalFile = filepath.Join(rootdir, snappyDir, "state.journal")
+	SnapshotsDir = filepath.Join(rootdir, snappyDir, "snapshots")
+	SnapChangesArchiveFile = filepath.Join(rootdir, snappyDir, "changes.archive")
 
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
 	SnapNamesFile = filepath.Join(SnapCacheDir, "names")
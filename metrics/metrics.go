@@ -0,0 +1,264 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package metrics implements simple in-memory counters and histograms,
+// partitioned by the value of a single label, that can be inspected as
+// data or written out in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram bucket upper bounds, meant
+// for durations measured in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600}
+
+const (
+	counterType   = "counter"
+	histogramType = "histogram"
+)
+
+// Registry holds a set of metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// NewRegistry returns a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	label   string
+	buckets []float64
+
+	series map[string]*series
+}
+
+type series struct {
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+func (r *Registry) add(m *metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.metrics {
+		if other.name == m.name {
+			panic(fmt.Sprintf("internal error: metric %q registered twice", m.name))
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+func (m *metric) seriesFor(value string) *series {
+	s := m.series[value]
+	if s == nil {
+		s = &series{}
+		if m.typ == histogramType {
+			s.buckets = make([]uint64, len(m.buckets))
+		}
+		m.series[value] = s
+	}
+	return s
+}
+
+// A CounterVec is a set of counters partitioned by the value of a label.
+type CounterVec struct {
+	r *Registry
+	m *metric
+}
+
+// NewCounterVec registers a new set of counters partitioned by label.
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	m := &metric{
+		name:   name,
+		help:   help,
+		typ:    counterType,
+		label:  label,
+		series: make(map[string]*series),
+	}
+	r.add(m)
+	return &CounterVec{r: r, m: m}
+}
+
+// Inc increments by one the counter for the given label value.
+func (cv *CounterVec) Inc(value string) {
+	cv.Add(value, 1)
+}
+
+// Add adds n to the counter for the given label value.
+func (cv *CounterVec) Add(value string, n uint64) {
+	cv.r.mu.Lock()
+	defer cv.r.mu.Unlock()
+	cv.m.seriesFor(value).count += n
+}
+
+// A HistogramVec is a set of histograms partitioned by the value of a label.
+type HistogramVec struct {
+	r *Registry
+	m *metric
+}
+
+// NewHistogramVec registers a new set of histograms partitioned by
+// label, with the given bucket upper bounds (DefaultBuckets if nil).
+func (r *Registry) NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("internal error: buckets of histogram %q are not sorted", name))
+	}
+	m := &metric{
+		name:    name,
+		help:    help,
+		typ:     histogramType,
+		label:   label,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.add(m)
+	return &HistogramVec{r: r, m: m}
+}
+
+// Observe records v in the histogram for the given label value.
+func (hv *HistogramVec) Observe(value string, v float64) {
+	hv.r.mu.Lock()
+	defer hv.r.mu.Unlock()
+	s := hv.m.seriesFor(value)
+	s.count++
+	s.sum += v
+	for i, le := range hv.m.buckets {
+		if v <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+// Metric is a snapshot of the values of a metric.
+type Metric struct {
+	Name   string    `json:"name"`
+	Help   string    `json:"help"`
+	Type   string    `json:"type"`
+	Label  string    `json:"label"`
+	Series []*Series `json:"series"`
+}
+
+// Series is a snapshot of the values of a metric for one label value.
+// Sum and Buckets are only set for histograms.
+type Series struct {
+	LabelValue string    `json:"label-value"`
+	Count      uint64    `json:"count"`
+	Sum        float64   `json:"sum,omitempty"`
+	Buckets    []*Bucket `json:"buckets,omitempty"`
+}
+
+// Bucket holds the number of observations no larger than an upper bound.
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// Snapshot returns the current values of all the metrics in the
+// registry, with the series of each sorted by label value.
+func (r *Registry) Snapshot() []*Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]*Metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		snap := &Metric{
+			Name:   m.name,
+			Help:   m.help,
+			Type:   m.typ,
+			Label:  m.label,
+			Series: make([]*Series, 0, len(m.series)),
+		}
+		for value, s := range m.series {
+			ser := &Series{
+				LabelValue: value,
+				Count:      s.count,
+			}
+			if m.typ == histogramType {
+				ser.Sum = s.sum
+				ser.Buckets = make([]*Bucket, len(m.buckets))
+				for i, le := range m.buckets {
+					ser.Buckets[i] = &Bucket{UpperBound: le, Count: s.buckets[i]}
+				}
+			}
+			snap.Series = append(snap.Series, ser)
+		}
+		sort.Sort(byLabelValue(snap.Series))
+		res = append(res, snap)
+	}
+	return res
+}
+
+type byLabelValue []*Series
+
+func (s byLabelValue) Len() int           { return len(s) }
+func (s byLabelValue) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLabelValue) Less(i, j int) bool { return s[i].LabelValue < s[j].LabelValue }
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// WritePrometheus writes out all the metrics in the registry in the
+// Prometheus text exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, m := range r.Snapshot() {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", m.Name, m.Help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", m.Name, m.Type)
+		for _, s := range m.Series {
+			label := fmt.Sprintf(`%s="%s"`, m.Label, labelValueReplacer.Replace(s.LabelValue))
+			if m.Type == counterType {
+				fmt.Fprintf(&buf, "%s{%s} %d\n", m.Name, label, s.Count)
+				continue
+			}
+			for _, b := range s.Buckets {
+				fmt.Fprintf(&buf, "%s_bucket{%s,le=\"%s\"} %d\n", m.Name, label, formatFloat(b.UpperBound), b.Count)
+			}
+			fmt.Fprintf(&buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", m.Name, label, s.Count)
+			fmt.Fprintf(&buf, "%s_sum{%s} %s\n", m.Name, label, formatFloat(s.Sum))
+			fmt.Fprintf(&buf, "%s_count{%s} %d\n", m.Name, label, s.Count)
+		}
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/metrics"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type metricsSuite struct{}
+
+var _ = Suite(&metricsSuite{})
+
+func (s *metricsSuite) TestCounterVec(c *C) {
+	r := metrics.NewRegistry()
+	cv := r.NewCounterVec("retries_total", "Number of retries.", "kind")
+	cv.Inc("foo")
+	cv.Inc("foo")
+	cv.Add("bar", 3)
+
+	c.Check(r.Snapshot(), DeepEquals, []*metrics.Metric{{
+		Name:  "retries_total",
+		Help:  "Number of retries.",
+		Type:  "counter",
+		Label: "kind",
+		Series: []*metrics.Series{
+			{LabelValue: "bar", Count: 3},
+			{LabelValue: "foo", Count: 2},
+		},
+	}})
+}
+
+func (s *metricsSuite) TestHistogramVec(c *C) {
+	r := metrics.NewRegistry()
+	hv := r.NewHistogramVec("latency_seconds", "Latency.", "kind", []float64{1, 10})
+	hv.Observe("foo", 0.5)
+	hv.Observe("foo", 5)
+	hv.Observe("foo", 50)
+
+	c.Check(r.Snapshot(), DeepEquals, []*metrics.Metric{{
+		Name:  "latency_seconds",
+		Help:  "Latency.",
+		Type:  "histogram",
+		Label: "kind",
+		Series: []*metrics.Series{{
+			LabelValue: "foo",
+			Count:      3,
+			Sum:        55.5,
+			Buckets: []*metrics.Bucket{
+				{UpperBound: 1, Count: 1},
+				{UpperBound: 10, Count: 2},
+			},
+		}},
+	}})
+}
+
+func (s *metricsSuite) TestDefaultBuckets(c *C) {
+	r := metrics.NewRegistry()
+	hv := r.NewHistogramVec("latency_seconds", "Latency.", "kind", nil)
+	hv.Observe("foo", 0.5)
+	snap := r.Snapshot()
+	c.Assert(snap, HasLen, 1)
+	c.Assert(snap[0].Series, HasLen, 1)
+	c.Check(snap[0].Series[0].Buckets, HasLen, len(metrics.DefaultBuckets))
+}
+
+func (s *metricsSuite) TestRegisterTwicePanics(c *C) {
+	r := metrics.NewRegistry()
+	r.NewCounterVec("foo", "Foo.", "kind")
+	c.Check(func() { r.NewHistogramVec("foo", "Foo.", "kind", nil) }, PanicMatches, `internal error: metric "foo" registered twice`)
+}
+
+func (s *metricsSuite) TestUnsortedBucketsPanics(c *C) {
+	r := metrics.NewRegistry()
+	c.Check(func() { r.NewHistogramVec("foo", "Foo.", "kind", []float64{2, 1}) }, PanicMatches, `internal error: buckets of histogram "foo" are not sorted`)
+}
+
+func (s *metricsSuite) TestWritePrometheus(c *C) {
+	r := metrics.NewRegistry()
+	cv := r.NewCounterVec("retries_total", "Number of retries.", "kind")
+	hv := r.NewHistogramVec("latency_seconds", "Latency.", "kind", []float64{0.5, 1})
+	cv.Inc(`a"b`)
+	hv.Observe("foo", 0.25)
+	hv.Observe("foo", 2)
+
+	var buf bytes.Buffer
+	c.Assert(r.WritePrometheus(&buf), IsNil)
+	c.Check(buf.String(), Equals, `# HELP retries_total Number of retries.
+# TYPE retries_total counter
+retries_total{kind="a\"b"} 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{kind="foo",le="0.5"} 1
+latency_seconds_bucket{kind="foo",le="1"} 1
+latency_seconds_bucket{kind="foo",le="+Inf"} 2
+latency_seconds_sum{kind="foo"} 2.25
+latency_seconds_count{kind="foo"} 2
+`)
+}
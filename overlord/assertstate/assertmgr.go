@@ -36,7 +36,8 @@ import (
 // nothing in it violates existing assertions, or misses required
 // ones.
 type AssertManager struct {
-	runner *state.TaskRunner
+	runner      *state.TaskRunner
+	autoRefresh *autoRefresh
 }
 
 // Manager returns a new assertion manager.
@@ -45,7 +46,10 @@ func Manager(s *state.State) (*AssertManager, error) {
 
 	runner := state.NewTaskRunner(s)
 
+	autoRefresh := newAutoRefresh(s)
+
 	runner.AddHandler("validate-snap", doValidateSnap, nil)
+	runner.AddHandler("refresh-assertions", autoRefresh.doRefreshAssertions, nil)
 
 	db, err := sysdb.Open()
 	if err != nil {
@@ -56,7 +60,10 @@ func Manager(s *state.State) (*AssertManager, error) {
 	ReplaceDB(s, db)
 	s.Unlock()
 
-	return &AssertManager{runner: runner}, nil
+	return &AssertManager{
+		runner:      runner,
+		autoRefresh: autoRefresh,
+	}, nil
 }
 
 func (m *AssertManager) KnownTaskKinds() []string {
@@ -65,8 +72,9 @@ func (m *AssertManager) KnownTaskKinds() []string {
 
 // Ensure implements StateManager.Ensure.
 func (m *AssertManager) Ensure() error {
+	err := m.autoRefresh.Ensure()
 	m.runner.Ensure()
-	return nil
+	return err
 }
 
 // Wait implements StateManager.Wait.
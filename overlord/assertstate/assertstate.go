@@ -26,9 +26,11 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
@@ -128,6 +130,44 @@ func (b *Batch) Commit(st *state.State) error {
 	return f.commit()
 }
 
+// Finder returns a finder looking up assertions both in the batch
+// and in the system assertion database, preferring the most recent
+// revision found, which is what the database will hold after
+// Commit. The assertions coming from the batch are only verified by
+// Commit.
+func (b *Batch) Finder(st *state.State) snapasserts.Finder {
+	return &batchFinder{bs: b.bs, db: cachedDB(st)}
+}
+
+type batchFinder struct {
+	bs asserts.Backstore
+	db asserts.RODatabase
+}
+
+func (f *batchFinder) Find(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+	var found asserts.Assertion
+	err := f.bs.Search(assertType, headers, func(a asserts.Assertion) {
+		found = a
+	}, assertType.MaxSupportedFormat())
+	if err != nil {
+		return nil, err
+	}
+	a, err := f.db.Find(assertType, headers)
+	if asserts.IsNotFound(err) {
+		if found == nil {
+			return nil, err
+		}
+		return found, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if found != nil && found.Revision() > a.Revision() {
+		return found, nil
+	}
+	return a, nil
+}
+
 func findError(format string, ref *asserts.Ref, err error) error {
 	if asserts.IsNotFound(err) {
 		return fmt.Errorf(format, ref)
@@ -160,6 +200,43 @@ func RefreshSnapDeclarations(s *state.State, userID int) error {
 	return doFetch(s, userID, fetching)
 }
 
+// RefreshRevocations refetches the revocations issued by all the
+// known accounts, if any, making the system database stop trusting
+// the keys and assertions they revoke. Revocations that cannot be
+// refreshed are only logged, so that they do not prevent the refresh
+// of the other assertions.
+func RefreshRevocations(s *state.State, userID int) error {
+	accounts, err := cachedDB(s).FindMany(asserts.AccountType, nil)
+	if asserts.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fetching := func(f asserts.Fetcher) error {
+		for _, a := range accounts {
+			accountID := a.(*asserts.Account).AccountID()
+			ref := &asserts.Ref{
+				Type:       asserts.RevocationType,
+				PrimaryKey: []string{accountID},
+			}
+			err := f.Fetch(ref)
+			if asserts.IsNotFound(err) {
+				// nothing revoked by this account
+				continue
+			}
+			if err != nil {
+				logger.Noticef("cannot refresh revocation for %q: %v", accountID, err)
+			}
+		}
+		return nil
+	}
+	if err := doFetch(s, userID, fetching); err != nil {
+		logger.Noticef("cannot refresh revocations: %v", err)
+	}
+	return nil
+}
+
 type refreshControlError struct {
 	errs []error
 }
@@ -378,9 +455,20 @@ func delayedCrossMgrInit() {
 	snapstate.AutoRefreshAssertions = AutoRefreshAssertions
 	// hook retrieving auto-aliases into snapstate logic
 	snapstate.AutoAliases = AutoAliases
+	// hook enforcement of validation sets into snapstate logic
+	snapstate.CheckValidationSets = checkEnforcedValidationSets
 }
 
-// AutoRefreshAssertions tries to refresh all assertions
+// AutoRefreshAssertions tries to refresh all the account-key,
+// snap-declaration and validation assertions, followed by the
+// revocations of all the known accounts.
 func AutoRefreshAssertions(s *state.State, userID int) error {
-	return RefreshSnapDeclarations(s, userID)
+	if err := refreshAssertions(s, userID); err != nil {
+		return err
+	}
+	if err := RefreshRevocations(s, userID); err != nil {
+		return err
+	}
+	s.Set("last-assertions-refresh", time.Now())
+	return nil
 }
@@ -22,6 +22,7 @@ package assertstate_test
 import (
 	"bytes"
 	"crypto"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -37,6 +38,7 @@ import (
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -67,6 +69,9 @@ type fakeStore struct {
 	storetest.Store
 	state *state.State
 	db    asserts.RODatabase
+
+	assertionErr   error
+	assertionCalls int
 }
 
 func (sto *fakeStore) pokeStateLock() {
@@ -78,6 +83,10 @@ func (sto *fakeStore) pokeStateLock() {
 
 func (sto *fakeStore) Assertion(assertType *asserts.AssertionType, key []string, _ *auth.UserState) (asserts.Assertion, error) {
 	sto.pokeStateLock()
+	sto.assertionCalls++
+	if sto.assertionErr != nil {
+		return nil, sto.assertionErr
+	}
 	ref := &asserts.Ref{Type: assertType, PrimaryKey: key}
 	return ref.Resolve(sto.db.Find)
 }
@@ -130,7 +139,7 @@ func (s *assertMgrSuite) TestDB(c *C) {
 func (s *assertMgrSuite) TestKnownTaskKinds(c *C) {
 	kinds := s.mgr.KnownTaskKinds()
 	sort.Strings(kinds)
-	c.Assert(kinds, DeepEquals, []string{"validate-snap"})
+	c.Assert(kinds, DeepEquals, []string{"refresh-assertions", "validate-snap"})
 }
 
 func (s *assertMgrSuite) TestAdd(c *C) {
@@ -287,6 +296,65 @@ func (s *assertMgrSuite) TestBatchCommitRefusesSelfSignedKey(c *C) {
 	c.Assert(err, ErrorMatches, `circular assertions are not expected:.*`)
 }
 
+func (s *assertMgrSuite) TestBatchFinder(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+
+	headers := s.dev1Acct.Headers()
+	headers["display-name"] = "Dev 1 edited display-name"
+	headers["revision"] = "1"
+	dev1Acct1, err := s.storeSigning.Sign(asserts.AccountType, headers, nil, "")
+	c.Assert(err, IsNil)
+
+	batch := assertstate.NewBatch()
+	finder := batch.Finder(s.state)
+
+	// only in the system database
+	acctHeaders := map[string]string{"account-id": s.dev1Acct.AccountID()}
+	a, err := finder.Find(asserts.AccountType, acctHeaders)
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 0)
+
+	// the most recent revision is preferred
+	err = batch.Add(dev1Acct1)
+	c.Assert(err, IsNil)
+	a, err = finder.Find(asserts.AccountType, acctHeaders)
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 1)
+
+	// only in the batch
+	err = batch.Add(s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	snapDecl := s.snapDecl(c, "foo", nil)
+	err = batch.Add(snapDecl)
+	c.Assert(err, IsNil)
+	a, err = finder.Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": "foo-id",
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapDeclaration).SnapName(), Equals, "foo")
+
+	// nowhere
+	_, err = finder.Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": "bar-id",
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	// nothing was added to the system database
+	_, err = assertstate.DB(s.state).Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": "foo-id",
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+}
+
 func (s *assertMgrSuite) TestBatchAddUnsupported(c *C) {
 	restore := asserts.MockMaxSupportedFormat(asserts.SnapDeclarationType, 111)
 	defer restore()
@@ -711,6 +779,296 @@ func (s *assertMgrSuite) TestRefreshSnapDeclarations(c *C) {
 	c.Check(a.(*asserts.SnapDeclaration).Revision(), Equals, 1)
 }
 
+func (s *assertMgrSuite) TestRefreshRevocations(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	dev1AcctKey, err := s.storeSigning.Find(asserts.AccountKeyType, map[string]string{
+		"public-key-sha3-384": s.dev1Signing.KeyID,
+	})
+	c.Assert(err, IsNil)
+
+	// previous state
+	err = assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, dev1AcctKey)
+	c.Assert(err, IsNil)
+
+	// nothing revoked yet
+	err = assertstate.RefreshRevocations(s.state, 0)
+	c.Assert(err, IsNil)
+
+	rev, err := s.storeSigning.Sign(asserts.RevocationType, map[string]interface{}{
+		"account-id":   s.dev1Acct.AccountID(),
+		"revoked-keys": []interface{}{s.dev1Signing.KeyID},
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	err = s.storeSigning.Add(rev)
+	c.Assert(err, IsNil)
+
+	err = assertstate.RefreshRevocations(s.state, 0)
+	c.Assert(err, IsNil)
+
+	db := assertstate.DB(s.state)
+	_, err = db.Find(asserts.RevocationType, map[string]string{
+		"account-id": s.dev1Acct.AccountID(),
+	})
+	c.Assert(err, IsNil)
+	_, err = db.Find(asserts.AccountKeyType, map[string]string{
+		"public-key-sha3-384": s.dev1Signing.KeyID,
+	})
+	c.Check(asserts.IsNotFound(err), Equals, true)
+}
+
+func (s *assertMgrSuite) TestRefreshRevocationsStoreErrors(c *C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+
+	snapstate.ReplaceStore(s.state, &fakeStore{
+		state:        s.state,
+		db:           s.storeSigning,
+		assertionErr: errors.New("store is down"),
+	})
+
+	// the errors are logged, not returned, so that the refresh of
+	// the other assertions is not prevented
+	err = assertstate.RefreshRevocations(s.state, 0)
+	c.Assert(err, IsNil)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot refresh revocation for ".*": .*store is down.*`)
+}
+
+func (s *assertMgrSuite) TestAutoRefreshAssertions(c *C) {
+	restore := assertstate.MockRefreshBatchSize(1)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapDeclFoo := s.snapDecl(c, "foo", nil)
+	snapDeclBar := s.snapDecl(c, "bar", nil)
+
+	// previous state
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, snapDeclFoo)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, snapDeclBar)
+	c.Assert(err, IsNil)
+
+	// changed assertions, even of snaps that are not installed
+	headers := map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "foo-id",
+		"snap-name":    "fo-o",
+		"publisher-id": s.dev1Acct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+		"revision":     "1",
+	}
+	snapDeclFoo1, err := s.storeSigning.Sign(asserts.SnapDeclarationType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = s.storeSigning.Add(snapDeclFoo1)
+	c.Assert(err, IsNil)
+
+	err = assertstate.AutoRefreshAssertions(s.state, 0)
+	c.Assert(err, IsNil)
+
+	db := assertstate.DB(s.state)
+	a, err := db.Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": "foo-id",
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapDeclaration).SnapName(), Equals, "fo-o")
+
+	var lastRefresh time.Time
+	err = s.state.Get("last-assertions-refresh", &lastRefresh)
+	c.Assert(err, IsNil)
+	c.Check(time.Since(lastRefresh) < time.Minute, Equals, true)
+}
+
+func (s *assertMgrSuite) TestAutoRefreshAssertionsError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+
+	snapstate.ReplaceStore(s.state, &fakeStore{
+		state:        s.state,
+		db:           s.storeSigning,
+		assertionErr: errors.New("store is down"),
+	})
+
+	err = assertstate.AutoRefreshAssertions(s.state, 0)
+	c.Check(err, ErrorMatches, `cannot refresh account-key \(.*\): store is down`)
+
+	var lastRefresh time.Time
+	err = s.state.Get("last-assertions-refresh", &lastRefresh)
+	c.Check(err, Equals, state.ErrNoState)
+}
+
+func (s *assertMgrSuite) TestAutoRefreshEnsure(c *C) {
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+	defer func() { snapstate.CanAutoRefresh = nil }()
+
+	s.state.Lock()
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	sto := &fakeStore{
+		state: s.state,
+		db:    s.storeSigning,
+	}
+	snapstate.ReplaceStore(s.state, sto)
+	s.state.Unlock()
+
+	// the first time around the refresh is only scheduled
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 0)
+	var lastRefresh time.Time
+	err = s.state.Get("last-assertions-refresh", &lastRefresh)
+	c.Assert(err, IsNil)
+	c.Check(lastRefresh.IsZero(), Equals, false)
+
+	// pretend a refresh is long overdue
+	s.state.Set("last-assertions-refresh", time.Now().Add(-48*time.Hour))
+	s.state.Unlock()
+
+	// Ensure only sets up a change, the store is not hit yet
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(sto.assertionCalls, Equals, 0)
+
+	// no further change while one is in flight
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	changes := s.state.Changes()
+	c.Assert(changes, HasLen, 1)
+	chg := changes[0]
+	c.Check(chg.Kind(), Equals, "refresh-assertions")
+	c.Check(chg.Tasks(), HasLen, 1)
+
+	s.state.Unlock()
+	defer s.mgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(sto.assertionCalls, Not(Equals), 0)
+	err = s.state.Get("last-assertions-refresh", &lastRefresh)
+	c.Assert(err, IsNil)
+	c.Check(time.Since(lastRefresh) < time.Minute, Equals, true)
+	s.state.Unlock()
+
+	// no further refresh until the next scheduled one
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+	s.state.Unlock()
+}
+
+func (s *assertMgrSuite) TestAutoRefreshEnsureReschedulesAfterOtherRefresh(c *C) {
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+	defer func() { snapstate.CanAutoRefresh = nil }()
+
+	s.state.Lock()
+	s.state.Set("last-assertions-refresh", time.Now().Add(-48*time.Hour))
+	s.state.Unlock()
+
+	af := assertstate.NewAutoRefresh(s.state)
+	err := af.Ensure()
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	c.Assert(s.state.Changes(), HasLen, 1)
+	s.state.Changes()[0].SetStatus(state.DoneStatus)
+	// the assertions were refreshed meanwhile by a snap auto-refresh
+	err = assertstate.AutoRefreshAssertions(s.state, 0)
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+
+	err = af.Ensure()
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *assertMgrSuite) TestAutoRefreshBackoff(c *C) {
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+	defer func() { snapstate.CanAutoRefresh = nil }()
+
+	s.state.Lock()
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	sto := &fakeStore{
+		state:        s.state,
+		db:           s.storeSigning,
+		assertionErr: errors.New("store is down"),
+	}
+	snapstate.ReplaceStore(s.state, sto)
+	s.state.Set("last-assertions-refresh", time.Now().Add(-48*time.Hour))
+	s.state.Unlock()
+
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+
+	defer s.mgr.Stop()
+	s.settle(c)
+
+	s.state.Lock()
+	changes := s.state.Changes()
+	c.Assert(changes, HasLen, 1)
+	c.Check(changes[0].Err(), ErrorMatches, `(?s).*cannot refresh .*: store is down.*`)
+	s.state.Unlock()
+	c.Check(sto.assertionCalls, Equals, 1)
+
+	// no retry right away
+	err = s.mgr.Ensure()
+	c.Check(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 1)
+	c.Check(sto.assertionCalls, Equals, 1)
+}
+
+func (s *assertMgrSuite) TestAutoRefreshNotWhenCannot(c *C) {
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return false, nil }
+	defer func() { snapstate.CanAutoRefresh = nil }()
+
+	s.state.Lock()
+	s.state.Set("last-assertions-refresh", time.Now().Add(-48*time.Hour))
+	s.state.Unlock()
+
+	err := s.mgr.Ensure()
+	c.Check(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
 func (s *assertMgrSuite) TestValidateRefreshesNothing(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -1163,3 +1521,174 @@ func (s *assertMgrSuite) TestStore(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(store.Store(), Equals, "foo")
 }
+
+func (s *assertMgrSuite) validationSet(c *C, name string, sequence int, snaps ...interface{}) *asserts.ValidationSet {
+	headers := map[string]interface{}{
+		"series":       "16",
+		"account-id":   s.dev1Acct.AccountID(),
+		"authority-id": s.dev1Acct.AccountID(),
+		"name":         name,
+		"sequence":     fmt.Sprintf("%d", sequence),
+		"snaps":        snaps,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	vs, err := s.dev1Signing.Sign(asserts.ValidationSetType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = s.storeSigning.Add(vs)
+	c.Assert(err, IsNil)
+	return vs.(*asserts.ValidationSet)
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetMonitor(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+
+	s.validationSet(c, "base-set", 1,
+		map[string]interface{}{"name": "foo", "revision": "7"},
+		map[string]interface{}{"name": "bar", "presence": "invalid"},
+	)
+	snapstate.Set(s.state, "bar", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "bar", Revision: snap.R(3)}},
+		Current:  snap.R(3),
+	})
+
+	vst, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 1, assertstate.MonitorMode, 0)
+	c.Assert(err, IsNil)
+	c.Check(vst, DeepEquals, &assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "base-set",
+		Mode:      assertstate.MonitorMode,
+		Sequence:  1,
+		Pinned:    true,
+	})
+
+	vsets, err := assertstate.ValidationSets(s.state)
+	c.Assert(err, IsNil)
+	c.Check(vsets, DeepEquals, map[string]*assertstate.ValidationSetTracking{
+		vst.Key(): vst,
+	})
+
+	vs, err := assertstate.ValidationSetAssertion(s.state, vst)
+	c.Assert(err, IsNil)
+	problems, err := assertstate.ValidationSetProblems(s.state, vs)
+	c.Assert(err, IsNil)
+	c.Check(problems, DeepEquals, []string{
+		`snap "foo" is required but not installed`,
+		`snap "bar" is invalid but installed`,
+	})
+
+	// monitoring does not interfere with snap operations
+	err = snapstate.CheckValidationSets(s.state, "bar", snap.R(4))
+	c.Check(err, IsNil)
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetEnforce(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+
+	s.validationSet(c, "base-set", 1,
+		map[string]interface{}{"name": "foo", "revision": "7"},
+		map[string]interface{}{"name": "bar", "presence": "optional", "min-revision": "3"},
+		map[string]interface{}{"name": "baz", "presence": "invalid"},
+	)
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(5)}},
+		Current:  snap.R(5),
+	})
+
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 1, assertstate.EnforceMode, 0)
+	c.Assert(err, ErrorMatches, `(?s)cannot enforce validation set ".*/base-set":\n- snap "foo" is at revision 5 but revision 7 is required`)
+	vsets, err := assertstate.ValidationSets(s.state)
+	c.Assert(err, IsNil)
+	c.Check(vsets, HasLen, 0)
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+	})
+	vst, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 1, assertstate.EnforceMode, 0)
+	c.Assert(err, IsNil)
+	c.Check(vst.Mode, Equals, assertstate.EnforceMode)
+
+	tests := []struct {
+		name string
+		rev  snap.Revision
+		err  string
+	}{
+		{"foo", snap.R(7), ""},
+		{"foo", snap.R(8), `cannot install snap "foo" at revision 8: validation set ".*/base-set" requires revision 7`},
+		{"foo", snap.R(0), `cannot remove snap "foo": it is required by validation set ".*/base-set"`},
+		{"bar", snap.R(3), ""},
+		{"bar", snap.R(0), ""},
+		{"bar", snap.R(2), `cannot install snap "bar" at revision 2: validation set ".*/base-set" requires at least revision 3`},
+		{"baz", snap.R(1), `cannot install snap "baz": it is invalid in validation set ".*/base-set"`},
+		{"other", snap.R(1), ""},
+	}
+	for _, t := range tests {
+		err := snapstate.CheckValidationSets(s.state, t.name, t.rev)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%s:%s", t.name, t.rev))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%s:%s", t.name, t.rev))
+		}
+	}
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetLatest(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, assertstate.MonitorMode, 0)
+	c.Assert(err, ErrorMatches, `cannot find validation set ".*/base-set" locally, a sequence must be given`)
+
+	s.validationSet(c, "base-set", 1, map[string]interface{}{"name": "foo", "presence": "optional"})
+	s.validationSet(c, "base-set", 2, map[string]interface{}{"name": "foo", "presence": "invalid"})
+
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 3, assertstate.MonitorMode, 0)
+	c.Assert(err, ErrorMatches, `cannot find validation-set.*`)
+
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 1, assertstate.MonitorMode, 0)
+	c.Assert(err, IsNil)
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 2, assertstate.MonitorMode, 0)
+	c.Assert(err, IsNil)
+
+	vst, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, assertstate.EnforceMode, 0)
+	c.Assert(err, IsNil)
+	c.Check(vst.Sequence, Equals, 2)
+	c.Check(vst.Pinned, Equals, false)
+	c.Check(vst.Mode, Equals, assertstate.EnforceMode)
+}
+
+func (s *assertMgrSuite) TestForgetValidationSet(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	s.validationSet(c, "base-set", 1, map[string]interface{}{"name": "foo"})
+
+	err = assertstate.ForgetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set")
+	c.Assert(err, ErrorMatches, `validation set ".*/base-set" is not being tracked`)
+
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 1, assertstate.MonitorMode, 0)
+	c.Assert(err, IsNil)
+	_, err = assertstate.GetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set")
+	c.Assert(err, IsNil)
+
+	err = assertstate.ForgetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set")
+	c.Assert(err, IsNil)
+	_, err = assertstate.GetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set")
+	c.Check(err, Equals, state.ErrNoState)
+}
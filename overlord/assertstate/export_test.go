@@ -23,3 +23,13 @@ package assertstate
 var (
 	DoFetch = doFetch
 )
+
+var NewAutoRefresh = newAutoRefresh
+
+func MockRefreshBatchSize(n int) (restore func()) {
+	old := refreshBatchSize
+	refreshBatchSize = n
+	return func() {
+		refreshBatchSize = old
+	}
+}
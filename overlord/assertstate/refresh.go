@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assertstate
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// refreshBatchSize is how many assertions are refetched and committed
+// to the system database in one go by refreshAssertions
+var refreshBatchSize = 50
+
+// refreshAssertionTypes are the types of assertions that get refreshed,
+// the ones carrying policies that can change over time
+var refreshAssertionTypes = []*asserts.AssertionType{
+	asserts.AccountKeyType,
+	asserts.SnapDeclarationType,
+	asserts.ValidationType,
+}
+
+// refreshRetryDelay is the delay before retrying a failed refresh of
+// the assertions, it doubles with each further failure up to
+// maxRefreshRetryDelay
+var (
+	refreshRetryDelay    = 10 * time.Minute
+	maxRefreshRetryDelay = 8 * time.Hour
+)
+
+// autoRefresh will ensure that the assertions in the system database
+// are refreshed periodically, following the snap refresh schedule,
+// even if no snap operation triggers it.
+type autoRefresh struct {
+	state *state.State
+
+	lastRefreshSchedule string
+	scheduledFrom       time.Time
+	nextRefresh         time.Time
+	lastRefreshAttempt  time.Time
+	failures            int
+}
+
+func newAutoRefresh(st *state.State) *autoRefresh {
+	return &autoRefresh{
+		state: st,
+	}
+}
+
+// lastRefresh returns when the last refresh of the assertions
+// happened, or initializes it to now if there was none.
+func (m *autoRefresh) lastRefresh() (time.Time, error) {
+	var lastRefresh time.Time
+	err := m.state.Get("last-assertions-refresh", &lastRefresh)
+	if err == state.ErrNoState {
+		// assertions were just fetched while seeding or
+		// installing, no need to refresh them right away
+		lastRefresh = time.Now()
+		m.state.Set("last-assertions-refresh", lastRefresh)
+		return lastRefresh, nil
+	}
+	return lastRefresh, err
+}
+
+func (m *autoRefresh) retryDelay() time.Duration {
+	delay := refreshRetryDelay
+	for i := 1; i < m.failures && delay < maxRefreshRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRefreshRetryDelay {
+		delay = maxRefreshRetryDelay
+	}
+	return delay
+}
+
+func refreshAssertionsInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "refresh-assertions" && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure ensures that a change refreshing the assertions is created
+// periodically.
+func (m *autoRefresh) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	// see if it even makes sense to try to refresh
+	if snapstate.CanAutoRefresh == nil {
+		return nil
+	}
+	if ok, err := snapstate.CanAutoRefresh(m.state); err != nil || !ok {
+		return err
+	}
+
+	if refreshAssertionsInFlight(m.state) {
+		return nil
+	}
+
+	// back off after failures to not hammer the store
+	if m.failures > 0 && m.lastRefreshAttempt.Add(m.retryDelay()).After(time.Now()) {
+		return nil
+	}
+
+	refreshSchedule, refreshScheduleStr, err := snapstate.EffectiveRefreshSchedule(m.state)
+	if err != nil {
+		return err
+	}
+	if len(refreshSchedule) == 0 {
+		// managed refreshes refresh assertions along with the snaps
+		m.nextRefresh = time.Time{}
+		return nil
+	}
+	if m.lastRefreshSchedule != refreshScheduleStr {
+		m.nextRefresh = time.Time{}
+	}
+	m.lastRefreshSchedule = refreshScheduleStr
+
+	lastRefresh, err := m.lastRefresh()
+	if err != nil {
+		return err
+	}
+	if !lastRefresh.Equal(m.scheduledFrom) {
+		// refreshed meanwhile, e.g. by a snap auto-refresh
+		m.nextRefresh = time.Time{}
+	}
+	if m.nextRefresh.IsZero() {
+		m.scheduledFrom = lastRefresh
+		m.nextRefresh = time.Now().Add(timeutil.Next(refreshSchedule, lastRefresh))
+		logger.Debugf("Next assertions refresh scheduled for %s.", m.nextRefresh)
+	}
+	if m.nextRefresh.After(time.Now()) {
+		return nil
+	}
+
+	m.lastRefreshAttempt = time.Now()
+	t := m.state.NewTask("refresh-assertions", i18n.G("Refresh assertions from the store"))
+	chg := m.state.NewChange("refresh-assertions", i18n.G("Refresh assertions from the store"))
+	chg.AddTask(t)
+	m.state.EnsureBefore(0)
+	m.nextRefresh = time.Time{}
+	return nil
+}
+
+// doRefreshAssertions refreshes the assertions on behalf of a change
+// created by Ensure.
+func (m *autoRefresh) doRefreshAssertions(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := AutoRefreshAssertions(st, 0); err != nil {
+		m.failures++
+		logger.Noticef("Cannot refresh assertions: %v", err)
+		return err
+	}
+	m.failures = 0
+	return nil
+}
+
+// refreshAssertions refetches, in batches, the account-key,
+// snap-declaration and validation assertions in the system database
+// that are not predefined.
+func refreshAssertions(s *state.State, userID int) error {
+	db := cachedDB(s)
+
+	var refs []*asserts.Ref
+	for _, assertType := range refreshAssertionTypes {
+		as, err := db.FindMany(assertType, nil)
+		if asserts.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, a := range as {
+			ref := a.Ref()
+			if _, err := ref.Resolve(db.FindPredefined); err == nil {
+				continue
+			}
+			refs = append(refs, ref)
+		}
+	}
+
+	for len(refs) > 0 {
+		n := refreshBatchSize
+		if n > len(refs) {
+			n = len(refs)
+		}
+		batch := refs[:n]
+		refs = refs[n:]
+		fetching := func(f asserts.Fetcher) error {
+			for _, ref := range batch {
+				err := f.Fetch(ref)
+				if asserts.IsNotFound(err) {
+					// not known to the store, keep what we have
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("cannot refresh %v: %v", ref, err)
+				}
+			}
+			return nil
+		}
+		if err := doFetch(s, userID, fetching); err != nil {
+			return err
+		}
+	}
+	return nil
+}
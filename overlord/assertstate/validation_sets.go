@@ -0,0 +1,291 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assertstate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ValidationSetMode is the mode in which a validation set is tracked.
+type ValidationSetMode string
+
+const (
+	// MonitorMode only reports whether the system complies with the
+	// validation set.
+	MonitorMode ValidationSetMode = "monitor"
+	// EnforceMode makes snapstate refuse operations that would
+	// violate the validation set.
+	EnforceMode ValidationSetMode = "enforce"
+)
+
+// ValidationSetTracking holds the tracking state of a validation set.
+type ValidationSetTracking struct {
+	AccountID string            `json:"account-id"`
+	Name      string            `json:"name"`
+	Mode      ValidationSetMode `json:"mode"`
+	// Sequence is the sequence of the validation set being tracked.
+	Sequence int `json:"sequence"`
+	// Pinned is set if the sequence was explicitly requested.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// Key returns the account-id/name key of the tracked validation set.
+func (vst *ValidationSetTracking) Key() string {
+	return ValidationSetKey(vst.AccountID, vst.Name)
+}
+
+// ValidationSetKey returns the key used to track the validation set
+// with the given account-id and name.
+func ValidationSetKey(accountID, name string) string {
+	return fmt.Sprintf("%s/%s", accountID, name)
+}
+
+// ValidationSets returns the tracking state of all the validation sets
+// applied to the system, indexed by their account-id/name key.
+func ValidationSets(st *state.State) (map[string]*ValidationSetTracking, error) {
+	var vsets map[string]*ValidationSetTracking
+	err := st.Get("validation-sets", &vsets)
+	if err == state.ErrNoState {
+		return map[string]*ValidationSetTracking{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vsets, nil
+}
+
+// GetValidationSet retrieves the tracking state of the given
+// validation set. It returns state.ErrNoState if it is not tracked.
+func GetValidationSet(st *state.State, accountID, name string) (*ValidationSetTracking, error) {
+	vsets, err := ValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	vst, ok := vsets[ValidationSetKey(accountID, name)]
+	if !ok {
+		return nil, state.ErrNoState
+	}
+	return vst, nil
+}
+
+func setValidationSets(st *state.State, vsets map[string]*ValidationSetTracking) {
+	if len(vsets) == 0 {
+		st.Set("validation-sets", nil)
+		return
+	}
+	st.Set("validation-sets", vsets)
+}
+
+// ForgetValidationSet stops tracking the given validation set.
+func ForgetValidationSet(st *state.State, accountID, name string) error {
+	vsets, err := ValidationSets(st)
+	if err != nil {
+		return err
+	}
+	key := ValidationSetKey(accountID, name)
+	if _, ok := vsets[key]; !ok {
+		return fmt.Errorf("validation set %q is not being tracked", key)
+	}
+	delete(vsets, key)
+	setValidationSets(st, vsets)
+	return nil
+}
+
+func latestValidationSet(db asserts.RODatabase, accountID, name string) (*asserts.ValidationSet, error) {
+	as, err := db.FindMany(asserts.ValidationSetType, map[string]string{
+		"series":     release.Series,
+		"account-id": accountID,
+		"name":       name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var latest *asserts.ValidationSet
+	for _, a := range as {
+		vs := a.(*asserts.ValidationSet)
+		if latest == nil || vs.Sequence() > latest.Sequence() {
+			latest = vs
+		}
+	}
+	return latest, nil
+}
+
+// ValidationSetAssertion returns the validation set assertion for the
+// given tracking state.
+func ValidationSetAssertion(st *state.State, vst *ValidationSetTracking) (*asserts.ValidationSet, error) {
+	a, err := DB(st).Find(asserts.ValidationSetType, map[string]string{
+		"series":     release.Series,
+		"account-id": vst.AccountID,
+		"name":       vst.Name,
+		"sequence":   strconv.Itoa(vst.Sequence),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.(*asserts.ValidationSet), nil
+}
+
+func fetchValidationSet(st *state.State, accountID, name string, sequence int, userID int) (*asserts.ValidationSet, error) {
+	db := DB(st)
+	if sequence == 0 {
+		vs, err := latestValidationSet(db, accountID, name)
+		if asserts.IsNotFound(err) {
+			return nil, fmt.Errorf("cannot find validation set %q locally, a sequence must be given", ValidationSetKey(accountID, name))
+		}
+		return vs, err
+	}
+
+	ref := &asserts.Ref{
+		Type:       asserts.ValidationSetType,
+		PrimaryKey: []string{release.Series, accountID, name, strconv.Itoa(sequence)},
+	}
+	if _, err := ref.Resolve(db.Find); asserts.IsNotFound(err) {
+		fetching := func(f asserts.Fetcher) error {
+			return f.Fetch(ref)
+		}
+		if err := doFetch(st, userID, fetching); err != nil {
+			return nil, findError("cannot find %s", ref, err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	a, err := ref.Resolve(db.Find)
+	if err != nil {
+		return nil, findError("cannot find %s", ref, err)
+	}
+	return a.(*asserts.ValidationSet), nil
+}
+
+// ApplyValidationSet starts tracking the given validation set in the
+// given mode. A sequence of 0 means the latest sequence known to the
+// system, otherwise the given sequence is fetched if needed.
+// Enforcing a validation set the system does not comply with fails.
+func ApplyValidationSet(st *state.State, accountID, name string, sequence int, mode ValidationSetMode, userID int) (*ValidationSetTracking, error) {
+	if mode != MonitorMode && mode != EnforceMode {
+		return nil, fmt.Errorf("invalid validation set mode %q", mode)
+	}
+	vs, err := fetchValidationSet(st, accountID, name, sequence, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == EnforceMode {
+		problems, err := ValidationSetProblems(st, vs)
+		if err != nil {
+			return nil, err
+		}
+		if len(problems) > 0 {
+			return nil, fmt.Errorf("cannot enforce validation set %q:\n- %s", ValidationSetKey(accountID, name), strings.Join(problems, "\n- "))
+		}
+	}
+
+	vsets, err := ValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	vst := &ValidationSetTracking{
+		AccountID: accountID,
+		Name:      name,
+		Mode:      mode,
+		Sequence:  vs.Sequence(),
+		Pinned:    sequence != 0,
+	}
+	vsets[vst.Key()] = vst
+	setValidationSets(st, vsets)
+	return vst, nil
+}
+
+// ValidationSetProblems returns a description of each way in which
+// the installed snaps do not comply with the given validation set.
+func ValidationSetProblems(st *state.State, vs *asserts.ValidationSet) ([]string, error) {
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, vsnap := range vs.Snaps() {
+		snapst, installed := snapStates[vsnap.Name]
+		switch {
+		case !installed && vsnap.Presence == asserts.PresenceRequired:
+			problems = append(problems, fmt.Sprintf("snap %q is required but not installed", vsnap.Name))
+		case !installed:
+			continue
+		case vsnap.Presence == asserts.PresenceInvalid:
+			problems = append(problems, fmt.Sprintf("snap %q is invalid but installed", vsnap.Name))
+		case vsnap.Revision != 0 && snapst.Current.N != vsnap.Revision:
+			problems = append(problems, fmt.Sprintf("snap %q is at revision %s but revision %d is required", vsnap.Name, snapst.Current, vsnap.Revision))
+		case vsnap.MinRevision != 0 && snapst.Current.N < vsnap.MinRevision:
+			problems = append(problems, fmt.Sprintf("snap %q is at revision %s but at least revision %d is required", vsnap.Name, snapst.Current, vsnap.MinRevision))
+		}
+	}
+	return problems, nil
+}
+
+// checkEnforcedValidationSets checks that installing the given
+// revision of the snap, or removing it if the revision is unset, does
+// not violate any of the enforced validation sets.
+func checkEnforcedValidationSets(st *state.State, snapName string, rev snap.Revision) error {
+	vsets, err := ValidationSets(st)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(vsets))
+	for key, vst := range vsets {
+		if vst.Mode == EnforceMode {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		vs, err := ValidationSetAssertion(st, vsets[key])
+		if err != nil {
+			return fmt.Errorf("cannot find enforced validation set %q: %v", key, err)
+		}
+		vsnap := vs.Snap(snapName)
+		if vsnap == nil {
+			continue
+		}
+		if rev.Unset() {
+			if vsnap.Presence == asserts.PresenceRequired {
+				return fmt.Errorf("cannot remove snap %q: it is required by validation set %q", snapName, key)
+			}
+			continue
+		}
+		switch {
+		case vsnap.Presence == asserts.PresenceInvalid:
+			return fmt.Errorf("cannot install snap %q: it is invalid in validation set %q", snapName, key)
+		case vsnap.Revision != 0 && rev.N != vsnap.Revision:
+			return fmt.Errorf("cannot install snap %q at revision %s: validation set %q requires revision %d", snapName, rev, key, vsnap.Revision)
+		case vsnap.MinRevision != 0 && rev.N < vsnap.MinRevision:
+			return fmt.Errorf("cannot install snap %q at revision %s: validation set %q requires at least revision %d", snapName, rev, key, vsnap.MinRevision)
+		}
+	}
+	return nil
+}
@@ -1,7 +1,7 @@
 // -*- Mode: Go; indent-tabs-mode: t -*-
 
 /*
- * Copyright (C) 2016 Canonical Ltd
+ * Copyright (C) 2016-2018 Canonical Ltd
  *
  * This program is free software: you can redistribute it and/or modify
  * it under the terms of the GNU General Public License version 3 as
@@ -20,20 +20,134 @@
 package overlord
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
 	"time"
 
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/history"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
+// maxStateJournalEntries is the number of journal entries appended
+// to the state journal after which the state is checkpointed again.
+var maxStateJournalEntries = 1000
+
+// overlordStateBackend checkpoints the state to the state file and
+// journals the modifications since the checkpoint to a separate
+// journal file. The journal starts with a line holding the digest of
+// the checkpoint it amends, followed by one entry per line, and is
+// removed once folded into a new checkpoint.
 type overlordStateBackend struct {
 	path           string
+	journalPath    string
 	ensureBefore   func(d time.Duration)
 	requestRestart func(t state.RestartType)
+	// archiveEnabled tells whether pruned changes should be archived,
+	// it is called with the state locked
+	archiveEnabled func() bool
+
+	checkpointSize int64
+	checkpointSum  string
+	journalSize    int64
+	journalEntries int
+}
+
+func checkpointSum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
 }
 
 func (osb *overlordStateBackend) Checkpoint(data []byte) error {
-	return osutil.AtomicWriteFile(osb.path, data, 0600, 0)
+	if err := osutil.AtomicWriteFile(osb.path, data, 0600, 0); err != nil {
+		return err
+	}
+	osb.checkpointSize = int64(len(data))
+	osb.checkpointSum = checkpointSum(data)
+	osb.journalSize = 0
+	osb.journalEntries = 0
+	// the checkpoint includes what was journaled, a journal left
+	// behind does not match it and is ignored or overwritten anyway
+	if err := os.Remove(osb.journalPath); err != nil && !os.IsNotExist(err) {
+		logger.Noticef("cannot remove state journal: %v", err)
+	}
+	return nil
+}
+
+// Journal appends the entry to the state journal, starting a new one
+// for the last checkpoint if needed.
+func (osb *overlordStateBackend) Journal(entry []byte) error {
+	flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+	buf := make([]byte, 0, len(osb.checkpointSum)+len(entry)+2)
+	if osb.journalSize == 0 {
+		flags |= os.O_TRUNC
+		buf = append(buf, osb.checkpointSum...)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, entry...)
+	buf = append(buf, '\n')
+	f, err := os.OpenFile(osb.journalPath, flags, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(buf); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	osb.journalSize += int64(len(buf))
+	osb.journalEntries++
+	return nil
+}
+
+// hasJournal returns whether anything was journaled since the last
+// checkpoint.
+func (osb *overlordStateBackend) hasJournal() bool {
+	return osb.journalEntries > 0
+}
+
+// load takes note of the checkpoint read from the state file and
+// returns the entries of the journal amending it, if any.
+func (osb *overlordStateBackend) load(checkpoint []byte) ([]byte, error) {
+	osb.checkpointSize = int64(len(checkpoint))
+	osb.checkpointSum = checkpointSum(checkpoint)
+	data, err := ioutil.ReadFile(osb.journalPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sum []byte
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		sum, data = data[:i], data[i+1:]
+	}
+	if string(sum) != osb.checkpointSum {
+		logger.Noticef("ignoring state journal not amending the current state file")
+		return nil, nil
+	}
+	return data, nil
+}
+
+// NeedsCompaction returns whether the journal grew enough that the
+// state should be checkpointed again instead.
+func (osb *overlordStateBackend) NeedsCompaction() bool {
+	return osb.journalEntries >= maxStateJournalEntries || osb.journalSize > osb.checkpointSize
+}
+
+// ArchiveChanges keeps a record of the given pruned changes in the
+// changes archive, if archiving is enabled.
+func (osb *overlordStateBackend) ArchiveChanges(chgs []*state.ArchivedChange) error {
+	if osb.archiveEnabled == nil || !osb.archiveEnabled() {
+		return nil
+	}
+	return history.Append(chgs)
 }
 
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
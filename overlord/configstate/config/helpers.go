@@ -59,6 +59,16 @@ func PatchConfig(snapName string, subkeys []string, pos int, config interface{},
 		return configm, nil
 
 	case *json.RawMessage:
+		if config == nil {
+			// Removed, the new map replaces pristine on commit.
+			configm := make(map[string]interface{})
+			_, err := PatchConfig(snapName, subkeys, pos, configm, value)
+			if err != nil {
+				return nil, err
+			}
+			dropRemoved(configm)
+			return jsonRaw(configm), nil
+		}
 		// Raw replaces pristine on commit. Unpack, update, and repack.
 		var configm map[string]interface{}
 
@@ -69,6 +79,7 @@ func PatchConfig(snapName string, subkeys []string, pos int, config interface{},
 		if err != nil {
 			return nil, err
 		}
+		dropRemoved(configm)
 		return jsonRaw(configm), nil
 
 	case map[string]interface{}:
@@ -88,6 +99,21 @@ func PatchConfig(snapName string, subkeys []string, pos int, config interface{},
 	panic(fmt.Errorf("internal error: unexpected configuration type %T", config))
 }
 
+// dropRemoved deletes the keys marked as removed from a map that
+// replaces the pristine configuration as a whole.
+func dropRemoved(config map[string]interface{}) {
+	for k, v := range config {
+		switch v := v.(type) {
+		case *json.RawMessage:
+			if v == nil {
+				delete(config, k)
+			}
+		case map[string]interface{}:
+			dropRemoved(v)
+		}
+	}
+}
+
 // Get unmarshals into result the value of the provided snap's configuration key.
 // If the key does not exist, an error of type *NoOptionError is returned.
 // The provided key may be formed as a dotted key path through nested maps.
@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
@@ -75,6 +76,27 @@ func (t *Transaction) State() *state.State {
 // The provided value must marshal properly by encoding/json.
 // Changes are not persisted until Commit is called.
 func (t *Transaction) Set(snapName, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cannot marshal snap %q option %q: %s", snapName, key, err)
+	}
+	raw := json.RawMessage(data)
+	return t.patch(snapName, key, &raw)
+}
+
+// Unset removes the provided snap's configuration key.
+// The provided key may be formed as a dotted key path through nested maps,
+// in which case only the innermost key is removed from its parent map.
+// Unsetting a key that does not exist is not an error.
+//
+// Changes are not persisted until Commit is called.
+func (t *Transaction) Unset(snapName, key string) error {
+	return t.patch(snapName, key, nil)
+}
+
+// patch records value as the change to key, with a nil value
+// meaning the key is removed.
+func (t *Transaction) patch(snapName, key string, value *json.RawMessage) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -83,27 +105,24 @@ func (t *Transaction) Set(snapName, key string, value interface{}) error {
 		config = make(map[string]interface{})
 	}
 
-	data, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("cannot marshal snap %q option %q: %s", snapName, key, err)
-	}
-	raw := json.RawMessage(data)
-
 	subkeys, err := ParseKey(key)
 	if err != nil {
 		return err
 	}
+	if len(subkeys) == 0 {
+		return fmt.Errorf("cannot change snap %q root document", snapName)
+	}
 
 	// Check whether it's trying to traverse a non-map from pristine. This
 	// would go unperceived by the configuration patching below.
 	if len(subkeys) > 1 {
 		var result interface{}
-		err = getFromPristine(snapName, subkeys, 0, t.pristine[snapName], &result)
+		err = getFromPristine(snapName, subkeys, 0, t.current(snapName), &result)
 		if err != nil && !IsNoOption(err) {
 			return err
 		}
 	}
-	_, err = PatchConfig(snapName, subkeys, 0, config, &raw)
+	_, err = PatchConfig(snapName, subkeys, 0, config, value)
 	if err != nil {
 		return err
 	}
@@ -112,6 +131,31 @@ func (t *Transaction) Set(snapName, key string, value interface{}) error {
 	return nil
 }
 
+// Changes returns the dotted keys of the options of the given snap that
+// were set or unset in the transaction, in sorted order.
+func (t *Transaction) Changes(snapName string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var keys []string
+	changedKeys("", t.changes[snapName], &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+func changedKeys(prefix string, config map[string]interface{}, keys *[]string) {
+	for key, value := range config {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if configm, ok := value.(map[string]interface{}); ok {
+			changedKeys(key, configm, keys)
+			continue
+		}
+		*keys = append(*keys, key)
+	}
+}
+
 // Get unmarshals into result the cached value of the provided snap's configuration key.
 // If the key does not exist, an error of type *NoOptionError is returned.
 // The provided key may be formed as a dotted key path through nested maps.
@@ -127,12 +171,21 @@ func (t *Transaction) Get(snapName, key string, result interface{}) error {
 		return err
 	}
 
-	err = GetFromChange(snapName, subkeys, 0, t.changes[snapName], result)
-	if IsNoOption(err) {
-		err = getFromPristine(snapName, subkeys, 0, t.pristine[snapName], result)
-	}
+	return getFromPristine(snapName, subkeys, 0, t.current(snapName), result)
+}
 
-	return err
+// current returns the configuration of the snap as seen by the
+// transaction, that is with the pending changes applied onto a copy
+// of the pristine configuration.
+func (t *Transaction) current(snapName string) map[string]*json.RawMessage {
+	config := make(map[string]*json.RawMessage, len(t.pristine[snapName]))
+	for k, v := range t.pristine[snapName] {
+		config[k] = v
+	}
+	for k, v := range t.changes[snapName] {
+		applyChange(config, k, v)
+	}
+	return config
 }
 
 // GetMaybe unmarshals into result the cached value of the provided snap's configuration key.
@@ -214,7 +267,7 @@ func (t *Transaction) Commit() {
 			config = make(map[string]*json.RawMessage)
 		}
 		for k, v := range snapChanges {
-			config[k] = commitChange(config[k], v)
+			applyChange(config, k, v)
 		}
 		t.pristine[snapName] = config
 	}
@@ -234,21 +287,33 @@ func jsonRaw(v interface{}) *json.RawMessage {
 	return &raw
 }
 
+// applyChange applies the change to the key of the config map,
+// removing it if the change is a removal.
+func applyChange(config map[string]*json.RawMessage, key string, change interface{}) {
+	if raw, ok := change.(*json.RawMessage); ok && raw == nil {
+		delete(config, key)
+		return
+	}
+	config[key] = commitChange(config[key], change)
+}
+
 func commitChange(pristine *json.RawMessage, change interface{}) *json.RawMessage {
 	switch change := change.(type) {
 	case *json.RawMessage:
 		return change
 	case map[string]interface{}:
-		if pristine == nil {
-			return jsonRaw(change)
-		}
 		var pristinem map[string]*json.RawMessage
-		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*pristine), &pristinem); err != nil {
-			// Not a map. Overwrite with the change.
-			return jsonRaw(change)
+		if pristine != nil {
+			if err := jsonutil.DecodeWithNumber(bytes.NewReader(*pristine), &pristinem); err != nil {
+				// Not a map. Overwrite with the change.
+				pristinem = nil
+			}
+		}
+		if pristinem == nil {
+			pristinem = make(map[string]*json.RawMessage)
 		}
 		for k, v := range change {
-			pristinem[k] = commitChange(pristinem[k], v)
+			applyChange(pristinem, k, v)
 		}
 		return jsonRaw(pristinem)
 	}
@@ -72,6 +72,17 @@ func (op setGetOp) args() map[string]interface{} {
 	return m
 }
 
+func (op setGetOp) keys() []string {
+	var keys []string
+	for _, key := range strings.Fields(string(op))[1:] {
+		if key == "=>" {
+			break
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
 func (op setGetOp) error() string {
 	if i := strings.Index(string(op), " => "); i >= 0 {
 		return string(op[i+4:])
@@ -153,6 +164,61 @@ var setGetTests = [][]setGetOp{{
 	`set one.two.three=3`,
 	`commit`,
 	`getunder one={"two":{"three":3}}`,
+}, {
+	// Unset.
+	`set one=1 two=2 three=3 four=4`,
+	`commit`,
+	`unset one three`,
+	`get one=- two=2 three=- four=4`,
+	`getunder one=1 two=2 three=3 four=4`,
+	`commit`,
+	`get one=- two=2 three=- four=4`,
+	`getunder one=- two=2 three=- four=4`,
+	`unset five`,
+	`commit`,
+	`getunder two=2 four=4`,
+}, {
+	// Nested unset.
+	`set one={"two":{"three":3,"four":4},"five":5}`,
+	`commit`,
+	`unset one.two.three`,
+	`get one={"two":{"four":4},"five":5}`,
+	`get one.two.three=-`,
+	`set one.six=6`,
+	`unset one.five`,
+	`get one={"two":{"four":4},"six":6}`,
+	`commit`,
+	`getunder one={"two":{"four":4},"six":6}`,
+}, {
+	// Set after unset replaces the removed value.
+	`set one={"two":2,"three":3}`,
+	`commit`,
+	`unset one`,
+	`set one.four=4`,
+	`get one={"four":4}`,
+	`commit`,
+	`getunder one={"four":4}`,
+}, {
+	// Unset within a replaced value.
+	`set one={"two":2,"three":3}`,
+	`unset one.two`,
+	`get one={"three":3}`,
+	`commit`,
+	`getunder one={"three":3}`,
+}, {
+	// Removed scalars may be traversed.
+	`set one=1`,
+	`commit`,
+	`unset one`,
+	`set one.two=2`,
+	`get one={"two":2}`,
+	`commit`,
+	`getunder one={"two":2}`,
+}, {
+	// Cannot unset through known scalar nor the root document.
+	`set one=1`,
+	`unset one.two => snap "core" option "one" is not a map`,
+	`unset BAD => invalid option name: "BAD"`,
 }, {
 	// Invalid option names.
 	`set BAD=1 => invalid option name: "BAD"`,
@@ -216,6 +282,16 @@ func (s *transactionSuite) TestSetGet(c *C) {
 					c.Assert(obtained, DeepEquals, expected)
 				}
 
+			case "unset":
+				for _, k := range op.keys() {
+					err := t.Unset(snap, k)
+					if op.fails() {
+						c.Assert(err, ErrorMatches, op.error())
+					} else {
+						c.Assert(err, IsNil)
+					}
+				}
+
 			case "commit":
 				t.Commit()
 
@@ -326,3 +402,18 @@ func (s *transactionSuite) TestState(c *C) {
 	tr := config.NewTransaction(s.state)
 	c.Check(tr.State(), DeepEquals, s.state)
 }
+
+func (s *transactionSuite) TestChanges(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("some-snap", "foo", "bar"), IsNil)
+	c.Assert(tr.Set("some-snap", "nested.one", 1), IsNil)
+	c.Assert(tr.Unset("some-snap", "nested.two"), IsNil)
+	c.Assert(tr.Set("other-snap", "baz", true), IsNil)
+
+	c.Check(tr.Changes("some-snap"), DeepEquals, []string{"foo", "nested.one", "nested.two"})
+	c.Check(tr.Changes("other-snap"), DeepEquals, []string{"baz"})
+	c.Check(tr.Changes("unknown-snap"), HasLen, 0)
+}
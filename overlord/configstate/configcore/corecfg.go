@@ -57,12 +57,29 @@ func Run(tr Conf) error {
 	if err := validateRefreshSchedule(tr); err != nil {
 		return err
 	}
+	if err := validateAutomaticSnapshotsExpiration(tr); err != nil {
+		return err
+	}
+	if err := validateResources(tr); err != nil {
+		return err
+	}
+	if err := validateHooksOutputToJournal(tr); err != nil {
+		return err
+	}
+	if err := validateChangesArchive(tr); err != nil {
+		return err
+	}
 
 	// capture cloud information
 	if err := setCloudInfoWhenSeeding(tr); err != nil {
 		return err
 	}
 
+	// resources.<snap>.<app>.<resource>
+	if err := handleResourcesConfiguration(tr); err != nil {
+		return err
+	}
+
 	// see if it makes sense to run at all
 	if release.OnClassic {
 		// nothing to do
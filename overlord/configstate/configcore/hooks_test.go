@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type hooksSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&hooksSuite{})
+
+func (s *hooksSuite) TestConfigureHooksOutputToJournalHappy(c *C) {
+	for _, value := range []interface{}{"true", "false", true, false} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"hooks.output-to-journal": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%v", value))
+	}
+}
+
+func (s *hooksSuite) TestConfigureHooksOutputToJournalInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"hooks.output-to-journal": "yes",
+		},
+	})
+	c.Assert(err, ErrorMatches, `hooks.output-to-journal can only be set to 'true' or 'false'`)
+}
@@ -21,6 +21,7 @@ package configcore
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/timeutil"
@@ -39,6 +40,30 @@ func validateRefreshSchedule(tr Conf) error {
 		}
 	}
 
+	refreshHoldStr, err := coreCfg(tr, "refresh.hold")
+	if err != nil {
+		return err
+	}
+	if refreshHoldStr != "" {
+		if _, err := time.Parse(time.RFC3339, refreshHoldStr); err != nil {
+			return fmt.Errorf("refresh.hold cannot be parsed: %v", err)
+		}
+	}
+
+	refreshWatchdogStr, err := coreCfg(tr, "refresh.watchdog")
+	if err != nil {
+		return err
+	}
+	if refreshWatchdogStr != "" {
+		d, err := time.ParseDuration(refreshWatchdogStr)
+		if err != nil {
+			return fmt.Errorf("refresh.watchdog cannot be parsed: %v", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("refresh.watchdog cannot be negative")
+		}
+	}
+
 	refreshScheduleStr, err := coreCfg(tr, "refresh.schedule")
 	if err != nil {
 		return err
@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+func resourceOverrides(tr Conf, snapName string) (map[string]map[string]interface{}, error) {
+	var overrides map[string]map[string]interface{}
+	if err := tr.Get("core", "resources."+snapName, &overrides); err != nil && !config.IsNoOption(err) {
+		return nil, fmt.Errorf("cannot get resources of snap %q: %v", snapName, err)
+	}
+	return overrides, nil
+}
+
+func resourceSnapNames(tr Conf) ([]string, error) {
+	var resources map[string]interface{}
+	if err := tr.Get("core", "resources", &resources); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	names := make([]string, 0, len(resources))
+	for snapName := range resources {
+		names = append(names, snapName)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func validateResources(tr Conf) error {
+	names, err := resourceSnapNames(tr)
+	if err != nil {
+		return err
+	}
+	for _, snapName := range names {
+		overrides, err := resourceOverrides(tr, snapName)
+		if err != nil {
+			return err
+		}
+		for appName, values := range overrides {
+			if err := snap.SetResourceLimits(&snap.ResourceLimits{}, values); err != nil {
+				return fmt.Errorf("cannot set resources of %s.%s: %v", snapName, appName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleResourcesConfiguration rewrites the service units of the
+// snaps whose resources.<snap> options are set or were set before,
+// so that they carry the effective resource limits. The new limits
+// are picked up by the services the next time they are started.
+func handleResourcesConfiguration(tr Conf) error {
+	names, err := resourceSnapNames(tr)
+	if err != nil {
+		return err
+	}
+
+	st := tr.State()
+	st.Lock()
+	// the committed configuration tells which snaps had overrides
+	// that might have been unset now
+	committed, err := resourceSnapNames(config.NewTransaction(st))
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(names))
+	for _, snapName := range names {
+		seen[snapName] = true
+	}
+	for _, snapName := range committed {
+		if !seen[snapName] {
+			names = append(names, snapName)
+		}
+	}
+
+	for _, snapName := range names {
+		st.Lock()
+		info, err := snapstate.CurrentInfo(st, snapName)
+		if err == nil {
+			// keep the services in the quota group of the snap
+			info.QuotaSlice, err = snapstate.QuotaGroupSlice(st, snapName)
+		}
+		st.Unlock()
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			// applied when the snap gets installed
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// the overrides being set, not yet the committed ones
+		// snapstate.ApplyResourceOverrides would use
+		overrides, err := resourceOverrides(tr, snapName)
+		if err != nil {
+			return err
+		}
+		if err := snap.ApplyResourceOverrides(info, overrides); err != nil {
+			return err
+		}
+		if err := wrappers.UpdateSnapServiceUnits(info, progress.Null); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type resourcesSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&resourcesSuite{})
+
+const resourcesSnapYaml = `name: foo
+version: 1.0
+apps:
+  svc:
+    command: bin/svc
+    daemon: simple
+    resources:
+      tasks: 32
+`
+
+func (s *resourcesSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.systemctlArgs = nil
+}
+
+func (s *resourcesSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (s *resourcesSuite) runWithResources(c *C, value interface{}) error {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "resources.foo", value), IsNil)
+	s.state.Unlock()
+
+	if err := configcore.Run(tr); err != nil {
+		return err
+	}
+	s.state.Lock()
+	tr.Commit()
+	s.state.Unlock()
+	return nil
+}
+
+func (s *resourcesSuite) TestConfigureResourcesRejected(c *C) {
+	for _, t := range []struct {
+		value interface{}
+		err   string
+	}{
+		{map[string]interface{}{"svc": map[string]interface{}{"memory": "lots"}}, `cannot set resources of foo.svc: invalid memory limit "lots"`},
+		{map[string]interface{}{"svc": map[string]interface{}{"cpu-quota": "-1%"}}, `cannot set resources of foo.svc: invalid CPU quota "-1%"`},
+		{map[string]interface{}{"svc": map[string]interface{}{"threads": 10}}, `cannot set resources of foo.svc: unknown resource "threads"`},
+		{"memory=1G", `cannot get resources of snap "foo": .*`},
+	} {
+		err := s.runWithResources(c, t.value)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.value))
+	}
+}
+
+func (s *resourcesSuite) TestConfigureResourcesNotInstalled(c *C) {
+	err := s.runWithResources(c, map[string]interface{}{
+		"svc": map[string]interface{}{"memory": "64M"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *resourcesSuite) TestConfigureResourcesRewritesUnits(c *C) {
+	restore := snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {})
+	defer restore()
+
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(3)}
+	info := snaptest.MockSnap(c, resourcesSnapYaml, si)
+	c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+	s.state.Lock()
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+	s.state.Unlock()
+	svcFile := filepath.Join(dirs.SnapServicesDir, "snap.foo.svc.service")
+
+	s.systemctlArgs = nil
+	err := s.runWithResources(c, map[string]interface{}{
+		"svc": map[string]interface{}{"memory": "64M"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{{"daemon-reload"}})
+	content, err := ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, "(?ms).*^MemoryLimit=67108864\nTasksMax=32$.*")
+
+	// unsetting the overrides brings back the limits of the snap
+	s.systemctlArgs = nil
+	err = s.runWithResources(c, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{{"daemon-reload"}})
+	content, err = ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Not(Matches), "(?ms).*^MemoryLimit=.*")
+	c.Check(string(content), Matches, "(?ms).*^TasksMax=32$.*")
+}
+
+func (s *resourcesSuite) TestConfigureResourcesKeepsQuotaGroup(c *C) {
+	restore := snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {})
+	defer restore()
+
+	oldQuotaGroupSlice := snapstate.QuotaGroupSlice
+	snapstate.QuotaGroupSlice = func(st *state.State, snapName string) (string, error) {
+		c.Check(snapName, Equals, "foo")
+		return "snap.grp.slice", nil
+	}
+	defer func() { snapstate.QuotaGroupSlice = oldQuotaGroupSlice }()
+
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(3)}
+	info := snaptest.MockSnap(c, resourcesSnapYaml, si)
+	info.QuotaSlice = "snap.grp.slice"
+	c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+	s.state.Lock()
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+	s.state.Unlock()
+	svcFile := filepath.Join(dirs.SnapServicesDir, "snap.foo.svc.service")
+
+	err := s.runWithResources(c, map[string]interface{}{
+		"svc": map[string]interface{}{"memory": "64M"},
+	})
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, "(?ms).*^MemoryLimit=67108864$.*")
+	// the service is still in the slice of the quota group
+	c.Check(string(content), Matches, "(?ms).*^Slice=snap.grp.slice$.*")
+}
@@ -20,6 +20,7 @@
 package configstate_test
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -88,6 +90,59 @@ func (s *configureHandlerSuite) TestBeforeInitializesTransaction(c *C) {
 	c.Check(value, Equals, "bar")
 }
 
+func (s *configureHandlerSuite) TestBeforeUnsetsNullValues(c *C) {
+	s.context.Lock()
+	tr := config.NewTransaction(s.context.State())
+	tr.Set("test-snap", "foo", "bar")
+	tr.Set("test-snap", "baz", map[string]interface{}{"one": 1, "two": 2})
+	tr.Commit()
+	s.context.Set("patch", map[string]interface{}{
+		"foo":     nil,
+		"baz.one": nil,
+	})
+	s.context.Unlock()
+
+	c.Check(s.handler.Before(), IsNil)
+
+	s.context.Lock()
+	tr = configstate.ContextTransaction(s.context)
+	s.context.Unlock()
+
+	var value interface{}
+	c.Check(tr.Get("test-snap", "foo", &value), ErrorMatches, `snap "test-snap" has no "foo" configuration option`)
+	c.Check(tr.Get("test-snap", "baz.one", &value), ErrorMatches, `snap "test-snap" has no "baz.one" configuration option`)
+	c.Check(tr.Get("test-snap", "baz.two", &value), IsNil)
+}
+
+func (s *configureHandlerSuite) TestBeforeUnsetsNestedNullValues(c *C) {
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"foo": nil,
+		"baz": map[string]interface{}{
+			"one": nil,
+			"two": map[string]interface{}{"three": nil, "four": 4},
+		},
+	})
+	s.context.Unlock()
+
+	c.Check(s.handler.Before(), IsNil)
+
+	s.context.Lock()
+	defer s.context.Unlock()
+	tr := configstate.ContextTransaction(s.context)
+
+	var value interface{}
+	c.Check(tr.Get("test-snap", "baz", &value), IsNil)
+	c.Check(value, DeepEquals, map[string]interface{}{
+		"two": map[string]interface{}{"four": json.Number("4")},
+	})
+
+	// the hook can tell which options were removed
+	var removed []string
+	c.Assert(s.context.Get("removed-options", &removed), IsNil)
+	c.Check(removed, DeepEquals, []string{"baz.one", "baz.two.three", "foo"})
+}
+
 func (s *configureHandlerSuite) TestBeforeInitializesTransactionUseDefaults(c *C) {
 	r := release.MockOnClassic(false)
 	defer r()
@@ -212,3 +267,263 @@ volumes:
 	err = s.handler.Before()
 	c.Check(err, ErrorMatches, `cannot apply gadget config defaults for snap "test-snap", no configure hook`)
 }
+
+const mockConfigSchemaSnapYaml = `
+name: test-snap
+config:
+    port:
+        type: integer
+        default: 8080
+        maximum: 65535
+    server:
+        type: object
+        properties:
+            host:
+                type: string
+                default: localhost
+            name:
+                type: string
+                default: test
+`
+
+func (s *configureHandlerSuite) mockConfigSchemaSnap(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	snaptest.MockSnap(c, mockConfigSchemaSnapYaml, &snap.SideInfo{Revision: snap.R(11)})
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "test-snap", Revision: snap.R(11)},
+		},
+		Current:  snap.R(11),
+		SnapType: "app",
+	})
+}
+
+func (s *configureHandlerSuite) TestBeforeAppliesConfigSchemaDefaults(c *C) {
+	s.mockConfigSchemaSnap(c)
+	defer dirs.SetRootDir("/")
+
+	s.context.Lock()
+	tr := config.NewTransaction(s.context.State())
+	tr.Set("test-snap", "server.host", "example.com")
+	tr.Commit()
+	s.context.Set("use-defaults", true)
+	s.context.Unlock()
+
+	c.Check(s.handler.Before(), IsNil)
+
+	s.context.Lock()
+	tr = configstate.ContextTransaction(s.context)
+	s.context.Unlock()
+
+	var port int
+	c.Check(tr.Get("test-snap", "port", &port), IsNil)
+	c.Check(port, Equals, 8080)
+	var server map[string]interface{}
+	c.Check(tr.Get("test-snap", "server", &server), IsNil)
+	c.Check(server, DeepEquals, map[string]interface{}{
+		"host": "example.com",
+		"name": "test",
+	})
+}
+
+func (s *configureHandlerSuite) TestBeforeConfigSchemaDefaultsOnlyOnInstall(c *C) {
+	s.mockConfigSchemaSnap(c)
+	defer dirs.SetRootDir("/")
+
+	c.Check(s.handler.Before(), IsNil)
+
+	s.context.Lock()
+	tr := configstate.ContextTransaction(s.context)
+	s.context.Unlock()
+
+	var port int
+	c.Check(tr.Get("test-snap", "port", &port), ErrorMatches, `snap "test-snap" has no "port" configuration option`)
+}
+
+func (s *configureHandlerSuite) TestBeforeValidatesConfigSchema(c *C) {
+	s.mockConfigSchemaSnap(c)
+	defer dirs.SetRootDir("/")
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"port": 80000,
+	})
+	s.context.Unlock()
+	c.Check(s.handler.Before(), ErrorMatches, `invalid value for option "port": must be at most 65535`)
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"port":        80,
+		"server.host": 1,
+	})
+	s.context.Unlock()
+	c.Check(s.handler.Before(), ErrorMatches, `invalid value for option "server.host": must be a string`)
+}
+
+func (s *configureHandlerSuite) TestBeforeValidatesOnlyChangedOptions(c *C) {
+	s.mockConfigSchemaSnap(c)
+	defer dirs.SetRootDir("/")
+
+	// stored before the snap declared its options
+	s.context.Lock()
+	tr := config.NewTransaction(s.context.State())
+	tr.Set("test-snap", "port", 80000)
+	tr.Set("test-snap", "server", map[string]interface{}{"host": 1})
+	tr.Commit()
+	s.context.Set("patch", map[string]interface{}{
+		"server.name": "foo",
+	})
+	s.context.Unlock()
+	c.Check(s.handler.Before(), IsNil)
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"server.name": 2,
+	})
+	s.context.Unlock()
+	c.Check(s.handler.Before(), ErrorMatches, `invalid value for option "server.name": must be a string`)
+}
+
+type ephemeralConfigureSuite struct {
+	state   *state.State
+	context *hookstate.Context
+	restore func()
+}
+
+var _ = Suite(&ephemeralConfigureSuite{})
+
+func (s *ephemeralConfigureSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	s.restore = snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {})
+
+	var err error
+	s.context, err = hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap"}, nil, "cookie")
+	c.Assert(err, IsNil)
+}
+
+func (s *ephemeralConfigureSuite) TearDownTest(c *C) {
+	s.restore()
+	dirs.SetRootDir("/")
+}
+
+func (s *ephemeralConfigureSuite) mockSnap(c *C, snapYaml string) {
+	snaptest.MockSnap(c, snapYaml, &snap.SideInfo{Revision: snap.R(1)})
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "test-snap", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+}
+
+func (s *ephemeralConfigureSuite) TestRunsConfigureHook(c *C) {
+	s.mockSnap(c, "name: test-snap\nhooks:\n    configure:\n")
+
+	s.context.Lock()
+	defer s.context.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("test-snap", "baz", "old")
+	tr.Commit()
+
+	tr = configstate.ContextTransaction(s.context)
+	c.Assert(tr.Set("test-snap", "foo", "bar"), IsNil)
+	c.Assert(tr.Unset("test-snap", "baz"), IsNil)
+	c.Assert(s.context.Done(), IsNil)
+
+	// nothing is committed until the configure hook succeeds
+	var value string
+	tr = config.NewTransaction(s.state)
+	c.Check(tr.Get("test-snap", "foo", &value), ErrorMatches, `snap "test-snap" has no "foo" configuration option`)
+	c.Check(tr.Get("test-snap", "baz", &value), IsNil)
+	c.Check(value, Equals, "old")
+
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Kind(), Equals, "configure-snap")
+	c.Check(chgs[0].Summary(), Equals, `Change configuration of "test-snap" snap`)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "run-hook")
+
+	var hookContext map[string]interface{}
+	c.Assert(tasks[0].Get("hook-context", &hookContext), IsNil)
+	c.Check(hookContext["patch"], DeepEquals, map[string]interface{}{
+		"foo": "bar",
+		"baz": nil,
+	})
+}
+
+func (s *ephemeralConfigureSuite) TestNoChangesNoConfigureHook(c *C) {
+	s.mockSnap(c, "name: test-snap\nhooks:\n    configure:\n")
+
+	s.context.Lock()
+	defer s.context.Unlock()
+
+	configstate.ContextTransaction(s.context)
+	c.Assert(s.context.Done(), IsNil)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *ephemeralConfigureSuite) TestCommitsWithoutConfigureHook(c *C) {
+	s.mockSnap(c, mockConfigSchemaSnapYaml)
+
+	s.context.Lock()
+	defer s.context.Unlock()
+
+	tr := configstate.ContextTransaction(s.context)
+	c.Assert(tr.Set("test-snap", "port", 8000), IsNil)
+	c.Assert(s.context.Done(), IsNil)
+	c.Check(s.state.Changes(), HasLen, 0)
+
+	var port int
+	tr = config.NewTransaction(s.state)
+	c.Check(tr.Get("test-snap", "port", &port), IsNil)
+	c.Check(port, Equals, 8000)
+}
+
+func (s *ephemeralConfigureSuite) TestNestedNullsWithoutConfigureHook(c *C) {
+	s.mockSnap(c, "name: test-snap\n")
+
+	s.context.Lock()
+	defer s.context.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("test-snap", "foo", "bar")
+	tr.Commit()
+
+	tr = configstate.ContextTransaction(s.context)
+	c.Assert(tr.Set("test-snap", "foo", nil), IsNil)
+	c.Assert(tr.Set("test-snap", "baz", map[string]interface{}{"one": nil, "two": 2}), IsNil)
+	c.Assert(s.context.Done(), IsNil)
+
+	var value interface{}
+	tr = config.NewTransaction(s.state)
+	c.Check(tr.Get("test-snap", "foo", &value), ErrorMatches, `snap "test-snap" has no "foo" configuration option`)
+	c.Check(tr.Get("test-snap", "baz", &value), IsNil)
+	c.Check(value, DeepEquals, map[string]interface{}{"two": json.Number("2")})
+}
+
+func (s *ephemeralConfigureSuite) TestValidatesWithoutConfigureHook(c *C) {
+	s.mockSnap(c, mockConfigSchemaSnapYaml)
+
+	s.context.Lock()
+	defer s.context.Unlock()
+
+	tr := configstate.ContextTransaction(s.context)
+	c.Assert(tr.Set("test-snap", "port", 80000), IsNil)
+	c.Check(s.context.Done(), ErrorMatches, `invalid value for option "port": must be at most 65535`)
+
+	var port int
+	tr = config.NewTransaction(s.state)
+	c.Check(tr.Get("test-snap", "port", &port), ErrorMatches, `snap "test-snap" has no "port" configuration option`)
+}
@@ -21,11 +21,15 @@ package configstate
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 // configureHandler is the handler for the configure hook.
@@ -49,15 +53,108 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 	// It wasn't already cached, so create and cache a new one
 	tr = config.NewTransaction(context.State())
 
-	context.OnDone(func() error {
-		tr.Commit()
-		return nil
-	})
+	if context.IsEphemeral() {
+		// outside of hooks the changes are handed to the configure
+		// hook of the snap instead of being committed directly
+		context.OnDone(func() error {
+			return configureFromEphemeral(context.State(), context.SnapName(), tr)
+		})
+	} else {
+		context.OnDone(func() error {
+			tr.Commit()
+			return nil
+		})
+	}
 
 	context.Cache(cachedTransaction{}, tr)
 	return tr
 }
 
+// configureFromEphemeral applies the configuration changes made outside of
+// hooks, e.g. by "snapctl set" run from an app of the snap. If the snap has
+// a configure hook the changes are passed to it as a patch in a new change
+// that runs asynchronously, so they only take effect if the hook accepts
+// them. Otherwise they are validated and committed right away.
+func configureFromEphemeral(st *state.State, snapName string, tr *config.Transaction) error {
+	keys := tr.Changes(snapName)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	patch := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		// a null value in the patch removes the option
+		var value interface{}
+		if err := tr.Get(snapName, key, &value); err != nil && !config.IsNoOption(err) {
+			return err
+		}
+		patch[key] = value
+	}
+
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return err
+	}
+	if info.Hooks["configure"] == nil {
+		// apply the patch like the configure handler would
+		tr := config.NewTransaction(st)
+		if _, err := applyPatch(tr, snapName, patch); err != nil {
+			return err
+		}
+		if err := validateConfig(tr, info); err != nil {
+			return err
+		}
+		tr.Commit()
+		return nil
+	}
+
+	chg := st.NewChange("configure-snap", fmt.Sprintf(i18n.G("Change configuration of %q snap"), snapName))
+	chg.AddAll(Configure(st, snapName, patch, 0))
+	chg.Set("snap-names", []string{snapName})
+	st.EnsureBefore(0)
+	return nil
+}
+
+// applyPatch applies the configuration patch to the transaction. A null
+// value, either at the top level or nested in a map, means the option
+// is to be removed. The dotted keys of the removed options are returned
+// in sorted order.
+func applyPatch(tr *config.Transaction, snapName string, patch map[string]interface{}) (removed []string, err error) {
+	for key, value := range patch {
+		if value == nil {
+			if err := tr.Unset(snapName, key); err != nil {
+				return nil, err
+			}
+			removed = append(removed, key)
+			continue
+		}
+		if err := tr.Set(snapName, key, withoutNulls(key, value, &removed)); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// withoutNulls returns a copy of value without the nulls nested in its
+// maps, appending the dotted keys of the dropped entries to removed.
+func withoutNulls(key string, value interface{}, removed *[]string) interface{} {
+	valuem, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	result := make(map[string]interface{}, len(valuem))
+	for k, v := range valuem {
+		subkey := key + "." + k
+		if v == nil {
+			*removed = append(*removed, subkey)
+			continue
+		}
+		result[k] = withoutNulls(subkey, v, removed)
+	}
+	return result
+}
+
 func newConfigureHandler(context *hookstate.Context) hookstate.Handler {
 	return &configureHandler{context: context}
 }
@@ -102,12 +199,87 @@ func (h *configureHandler) Before() error {
 		}
 	}
 
-	for key, value := range patch {
+	removed, err := applyPatch(tr, snapName, patch)
+	if err != nil {
+		return err
+	}
+	if len(removed) != 0 {
+		// let the hook see the removed options via "snapctl get --removed"
+		h.context.Set("removed-options", removed)
+	}
+
+	// Apply the defaults and validate the result against the config
+	// section of snap.yaml, if the snap declares one.
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if _, ok := err.(*snap.NotInstalledError); ok {
+		// e.g. core can be configured before being installed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(info.Config) == 0 {
+		return nil
+	}
+	if useDefaults {
+		if err := applyConfigDefaults(tr, info); err != nil {
+			return err
+		}
+	}
+	return validateConfig(tr, info)
+}
+
+// applyConfigDefaults sets the defaults declared by the snap for the
+// options that are not set yet.
+func applyConfigDefaults(tr *config.Transaction, info *snap.Info) error {
+	snapName := info.Name()
+	for key, value := range info.ConfigDefaults() {
+		var current interface{}
+		err := tr.Get(snapName, key, &current)
+		if err == nil {
+			continue
+		}
+		if !config.IsNoOption(err) {
+			return err
+		}
 		if err := tr.Set(snapName, key, value); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// validateConfig checks the options changed in the transaction against
+// their declarations by the snap. Only the changed options are checked so
+// that configuration stored before the declarations were introduced or
+// tightened does not prevent the snap from being configured.
+func validateConfig(tr *config.Transaction, info *snap.Info) error {
+	snapName := info.Name()
+	checked := make(map[string]bool)
+	for _, key := range tr.Changes(snapName) {
+		// check the innermost declared option the key belongs to
+		subkeys := strings.Split(key, ".")
+		for len(subkeys) > 0 && info.ConfigOption(strings.Join(subkeys, ".")) == nil {
+			subkeys = subkeys[:len(subkeys)-1]
+		}
+		name := strings.Join(subkeys, ".")
+		if name == "" || checked[name] {
+			continue
+		}
+		checked[name] = true
 
+		var value interface{}
+		err := tr.Get(snapName, name, &value)
+		if config.IsNoOption(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := info.ConfigOption(name).Validate(name, value); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -50,6 +50,14 @@ func MockPruneInterval(prunei, prunew, abortw time.Duration) (restore func()) {
 	}
 }
 
+// MockMaxStateJournalEntries sets the number of state journal entries
+// after which the state is checkpointed again, for tests.
+func MockMaxStateJournalEntries(n int) (restore func()) {
+	old := maxStateJournalEntries
+	maxStateJournalEntries = n
+	return func() { maxStateJournalEntries = old }
+}
+
 // MockEnsureNext sets o.ensureNext for tests.
 func MockEnsureNext(o *Overlord, t time.Time) {
 	o.ensureNext = t
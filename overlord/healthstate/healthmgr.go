@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// ensureInterval is how often the snaps are considered for checking
+// their health.
+var ensureInterval = time.Minute
+
+// HealthManager runs the check-health hook of the snaps that have one
+// once per installed, refreshed or reverted revision. Later changes
+// of their health are reported by the snaps themselves via snapctl
+// set-health.
+type HealthManager struct {
+	state      *state.State
+	lastEnsure time.Time
+}
+
+// Manager returns a new HealthManager.
+func Manager(st *state.State, hookManager *hookstate.HookManager) *HealthManager {
+	setupHooks(hookManager)
+	return &HealthManager{state: st}
+}
+
+func (m *HealthManager) KnownTaskKinds() []string {
+	return nil
+}
+
+// Ensure is part of the overlord.StateManager interface.
+func (m *HealthManager) Ensure() error {
+	now := timeNow()
+	if !m.lastEnsure.IsZero() && now.Sub(m.lastEnsure) < ensureInterval {
+		return nil
+	}
+	m.lastEnsure = now
+
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	return m.checkHealth()
+}
+
+// Wait is part of the overlord.StateManager interface.
+func (m *HealthManager) Wait() {
+}
+
+// Stop is part of the overlord.StateManager interface.
+func (m *HealthManager) Stop() {
+}
+
+func notReady(t *state.Task) bool {
+	return !t.Status().Ready()
+}
+
+// checking returns the names of the snaps that have a check of their
+// health in progress.
+func checking(st *state.State) map[string]bool {
+	names := make(map[string]bool)
+	for _, chg := range st.Changes() {
+		if chg.Kind() != "check-health" || chg.Status().Ready() {
+			continue
+		}
+		var snapNames []string
+		if err := chg.Get("snap-names", &snapNames); err != nil {
+			continue
+		}
+		for _, name := range snapNames {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func (m *HealthManager) checkHealth() error {
+	st := m.state
+
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return err
+	}
+	healths, err := All(st)
+	if err != nil {
+		return err
+	}
+
+	// forget about the health of snaps that are gone
+	for name := range healths {
+		if _, ok := snapStates[name]; !ok {
+			if err := Set(st, name, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	inProgress := checking(st)
+	names := make([]string, 0, len(snapStates))
+	for name := range snapStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		snapst := snapStates[name]
+		if !snapst.Active || inProgress[name] {
+			continue
+		}
+		// only revisions that have not reported their health yet
+		// are checked, instead of creating a change for every snap
+		// over and over again
+		if health := healths[name]; health != nil && health.Revision == snapst.Current {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			logger.Noticef("cannot check health of snap %q: %v", name, err)
+			continue
+		}
+		if info.Hooks["check-health"] == nil {
+			continue
+		}
+		// the tasks already done by a change do not matter, so that
+		// the health of refreshed snaps can be checked while they
+		// are being watched
+		if err := snapstate.CheckChangeConflict(st, name, notReady, nil); err != nil {
+			// try again once the snap is left alone
+			continue
+		}
+
+		hooksup := &hookstate.HookSetup{
+			Snap:     name,
+			Revision: snapst.Current,
+			Hook:     "check-health",
+			Optional: true,
+		}
+		summary := fmt.Sprintf(i18n.G("Run health check of %q snap"), name)
+		chg := st.NewChange("check-health", summary)
+		chg.Set("snap-names", []string{name})
+		chg.AddTask(hookstate.HookTask(st, summary, hooksup, nil))
+		st.EnsureBefore(0)
+	}
+
+	return nil
+}
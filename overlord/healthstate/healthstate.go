@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package healthstate implements the manager and state aspects
+// responsible for the health reported by snaps.
+package healthstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// HealthStatus is the status of the health of a snap.
+type HealthStatus int
+
+const (
+	// UnknownStatus means the snap did not report its health.
+	UnknownStatus = HealthStatus(iota)
+	// OkayStatus means the snap is working as expected.
+	OkayStatus
+	// WaitingStatus means the snap is waiting for an external event,
+	// and is expected to become okay without intervention.
+	WaitingStatus
+	// BlockedStatus means the snap needs manual intervention to
+	// become okay.
+	BlockedStatus
+	// ErrorStatus means the snap is broken and will not work.
+	ErrorStatus
+)
+
+var knownStatuses = []string{"unknown", "okay", "waiting", "blocked", "error"}
+
+// StatusLookup returns the HealthStatus with the given name.
+func StatusLookup(str string) (HealthStatus, error) {
+	for i, k := range knownStatuses {
+		if k == str {
+			return HealthStatus(i), nil
+		}
+	}
+	return -1, fmt.Errorf("invalid health status %q", str)
+}
+
+func (s HealthStatus) String() string {
+	if s < 0 || s >= HealthStatus(len(knownStatuses)) {
+		return fmt.Sprintf("invalid (%d)", s)
+	}
+	return knownStatuses[s]
+}
+
+func (s HealthStatus) MarshalJSON() ([]byte, error) {
+	if s < 0 || s >= HealthStatus(len(knownStatuses)) {
+		return nil, fmt.Errorf("cannot marshal invalid health status %d", s)
+	}
+	return json.Marshal(s.String())
+}
+
+func (s *HealthStatus) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	status, err := StatusLookup(str)
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// HealthState is the health of a snap as last reported by it.
+type HealthState struct {
+	Revision  snap.Revision `json:"revision"`
+	Timestamp time.Time     `json:"timestamp"`
+	Status    HealthStatus  `json:"status"`
+	Message   string        `json:"message,omitempty"`
+	Code      string        `json:"code,omitempty"`
+}
+
+var validCode = regexp.MustCompile(`^[a-z](?:-?[a-z0-9])+$`)
+
+// Validate checks that the status, message and code of the health
+// are acceptable for reporting.
+func (h *HealthState) Validate() error {
+	if h.Status < UnknownStatus || h.Status > ErrorStatus {
+		return fmt.Errorf("invalid health status %d", h.Status)
+	}
+	if h.Status != OkayStatus && h.Status != UnknownStatus && h.Message == "" {
+		return fmt.Errorf("health status %q requires a message", h.Status)
+	}
+	if len(h.Message) > 70 {
+		return fmt.Errorf("health message must be at most 70 characters long")
+	}
+	if h.Code != "" && (len(h.Code) > 30 || !validCode.MatchString(h.Code)) {
+		return fmt.Errorf("invalid health code %q", h.Code)
+	}
+	return nil
+}
+
+// All returns the health of all the snaps that reported it.
+func All(st *state.State) (map[string]*HealthState, error) {
+	var healths map[string]*HealthState
+	if err := st.Get("health", &healths); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return healths, nil
+}
+
+// Get returns the health last reported by the given snap, or nil if
+// it never reported any.
+func Get(st *state.State, snapName string) (*HealthState, error) {
+	healths, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	return healths[snapName], nil
+}
+
+// Set stores the health of the given snap. A nil health forgets any
+// previously reported one.
+func Set(st *state.State, snapName string, health *HealthState) error {
+	healths, err := All(st)
+	if err != nil {
+		return err
+	}
+	if health == nil {
+		delete(healths, snapName)
+	} else {
+		if healths == nil {
+			healths = make(map[string]*HealthState)
+		}
+		healths[snapName] = health
+	}
+	st.Set("health", healths)
+	return nil
+}
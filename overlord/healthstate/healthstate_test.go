@@ -0,0 +1,416 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+// hook up gocheck to testing
+func TestHealthState(t *testing.T) { TestingT(t) }
+
+type healthSuite struct {
+	state   *state.State
+	manager *healthstate.HealthManager
+	now     time.Time
+
+	restore []func()
+}
+
+var _ = Suite(&healthSuite{})
+
+const healthySnapYaml = `name: healthy
+version: 1
+hooks:
+ check-health:
+`
+
+const plainSnapYaml = `name: plain
+version: 1
+`
+
+func (s *healthSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.now = time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	s.restore = []func(){
+		healthstate.MockTimeNow(func() time.Time { return s.now }),
+		snap.MockSanitizePlugsSlots(func(*snap.Info) {}),
+	}
+
+	s.state = state.New(nil)
+	hookMgr, err := hookstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.manager = healthstate.Manager(s.state, hookMgr)
+}
+
+func (s *healthSuite) TearDownTest(c *C) {
+	for _, f := range s.restore {
+		f()
+	}
+	dirs.SetRootDir("")
+}
+
+func (s *healthSuite) mockSnap(c *C, yaml string, rev int, active bool) {
+	si := &snap.SideInfo{RealName: snaptest.MockInfo(c, yaml, nil).Name(), Revision: snap.R(rev)}
+	snaptest.MockSnap(c, yaml, si)
+	snapstate.Set(s.state, si.RealName, &snapstate.SnapState{
+		Active:   active,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+}
+
+func (s *healthSuite) ensure(c *C) {
+	s.state.Unlock()
+	defer s.state.Lock()
+	c.Assert(s.manager.Ensure(), IsNil)
+}
+
+func (s *healthSuite) checkChanges(c *C) []string {
+	var snaps []string
+	for _, chg := range s.state.Changes() {
+		c.Assert(chg.Kind(), Equals, "check-health")
+		c.Assert(chg.Tasks(), HasLen, 1)
+		t := chg.Tasks()[0]
+		c.Check(t.Kind(), Equals, "run-hook")
+		var hooksup hookstate.HookSetup
+		c.Assert(t.Get("hook-setup", &hooksup), IsNil)
+		c.Check(hooksup.Hook, Equals, "check-health")
+		c.Check(hooksup.Optional, Equals, true)
+		snaps = append(snaps, hooksup.Snap)
+	}
+	return snaps
+}
+
+func (s *healthSuite) TestStatus(c *C) {
+	for i, str := range []string{"unknown", "okay", "waiting", "blocked", "error"} {
+		status, err := healthstate.StatusLookup(str)
+		c.Assert(err, IsNil)
+		c.Check(status, Equals, healthstate.HealthStatus(i))
+		c.Check(status.String(), Equals, str)
+
+		data, err := json.Marshal(status)
+		c.Assert(err, IsNil)
+		c.Check(string(data), Equals, `"`+str+`"`)
+		var back healthstate.HealthStatus
+		c.Assert(json.Unmarshal(data, &back), IsNil)
+		c.Check(back, Equals, status)
+	}
+
+	_, err := healthstate.StatusLookup("meh")
+	c.Check(err, ErrorMatches, `invalid health status "meh"`)
+	c.Check(healthstate.HealthStatus(42).String(), Equals, "invalid (42)")
+	_, err = json.Marshal(healthstate.HealthStatus(42))
+	c.Check(err, ErrorMatches, ".*cannot marshal invalid health status 42")
+}
+
+func (s *healthSuite) TestValidate(c *C) {
+	for _, t := range []struct {
+		health healthstate.HealthState
+		err    string
+	}{
+		{healthstate.HealthState{Status: healthstate.OkayStatus}, ""},
+		{healthstate.HealthState{Status: healthstate.WaitingStatus, Message: "soon", Code: "db-sync-2"}, ""},
+		{healthstate.HealthState{Status: healthstate.BlockedStatus}, `health status "blocked" requires a message`},
+		{healthstate.HealthState{Status: healthstate.OkayStatus, Code: "x"}, `invalid health code "x"`},
+		{healthstate.HealthState{Status: healthstate.OkayStatus, Code: "no--way"}, `invalid health code "no--way"`},
+		{healthstate.HealthState{Status: healthstate.HealthStatus(7)}, `invalid health status 7`},
+	} {
+		err := t.health.Validate()
+		if t.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (s *healthSuite) TestSetGetAll(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	health, err := healthstate.Get(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(health, IsNil)
+
+	foo := &healthstate.HealthState{Revision: snap.R(3), Timestamp: s.now, Status: healthstate.OkayStatus}
+	bar := &healthstate.HealthState{Revision: snap.R(1), Timestamp: s.now, Status: healthstate.ErrorStatus, Message: "boom", Code: "kaboom"}
+	c.Assert(healthstate.Set(s.state, "foo", foo), IsNil)
+	c.Assert(healthstate.Set(s.state, "bar", bar), IsNil)
+
+	health, err = healthstate.Get(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(health, DeepEquals, foo)
+
+	all, err := healthstate.All(s.state)
+	c.Assert(err, IsNil)
+	c.Check(all, DeepEquals, map[string]*healthstate.HealthState{"foo": foo, "bar": bar})
+
+	c.Assert(healthstate.Set(s.state, "foo", nil), IsNil)
+	all, err = healthstate.All(s.state)
+	c.Assert(err, IsNil)
+	c.Check(all, DeepEquals, map[string]*healthstate.HealthState{"bar": bar})
+}
+
+func (s *healthSuite) TestKnownTaskKinds(c *C) {
+	c.Check(s.manager.KnownTaskKinds(), HasLen, 0)
+}
+
+func (s *healthSuite) TestEnsureChecksSnapsWithHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockSnap(c, healthySnapYaml, 1, true)
+	s.mockSnap(c, plainSnapYaml, 1, true)
+
+	s.ensure(c)
+	c.Check(s.checkChanges(c), DeepEquals, []string{"healthy"})
+
+	// no new check while one is in progress
+	s.now = s.now.Add(time.Hour)
+	s.ensure(c)
+	c.Check(s.checkChanges(c), HasLen, 1)
+}
+
+func (s *healthSuite) TestEnsureSkipsInactiveSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockSnap(c, healthySnapYaml, 1, false)
+
+	s.ensure(c)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *healthSuite) TestEnsureChecksAgain(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockSnap(c, healthySnapYaml, 1, true)
+	c.Assert(healthstate.Set(s.state, "healthy", &healthstate.HealthState{
+		Revision:  snap.R(1),
+		Timestamp: s.now,
+		Status:    healthstate.OkayStatus,
+	}), IsNil)
+
+	// recently checked
+	s.ensure(c)
+	c.Check(s.state.Changes(), HasLen, 0)
+
+	// considered again only after a while
+	s.now = s.now.Add(30 * time.Second)
+	s.mockSnap(c, healthySnapYaml, 2, true)
+	s.ensure(c)
+	c.Check(s.state.Changes(), HasLen, 0)
+
+	// checked again since the revision changed
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	c.Check(s.checkChanges(c), DeepEquals, []string{"healthy"})
+}
+
+func (s *healthSuite) TestEnsureDoesNotCheckSameRevisionAgain(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockSnap(c, healthySnapYaml, 1, true)
+	c.Assert(healthstate.Set(s.state, "healthy", &healthstate.HealthState{
+		Revision:  snap.R(1),
+		Timestamp: s.now.Add(-24 * time.Hour),
+		Status:    healthstate.OkayStatus,
+	}), IsNil)
+
+	s.ensure(c)
+	s.now = s.now.Add(time.Hour)
+	s.ensure(c)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *healthSuite) TestEnsureForgetsRemovedSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(healthstate.Set(s.state, "gone", &healthstate.HealthState{
+		Revision:  snap.R(1),
+		Timestamp: s.now,
+		Status:    healthstate.OkayStatus,
+	}), IsNil)
+
+	s.ensure(c)
+	all, err := healthstate.All(s.state)
+	c.Assert(err, IsNil)
+	c.Check(all, HasLen, 0)
+}
+
+func (s *healthSuite) hookContext(c *C) *hookstate.Context {
+	task := s.state.NewTask("run-hook", "...")
+	setup := &hookstate.HookSetup{Snap: "healthy", Revision: snap.R(2), Hook: "check-health"}
+	context, err := hookstate.NewContext(task, s.state, setup, nil, "")
+	c.Assert(err, IsNil)
+	return context
+}
+
+func (s *healthSuite) TestHookDone(c *C) {
+	s.state.Lock()
+	context := s.hookContext(c)
+	s.state.Unlock()
+	health := &healthstate.HealthState{Revision: snap.R(2), Timestamp: s.now, Status: healthstate.WaitingStatus, Message: "syncing"}
+	context.Lock()
+	context.Set("health", health)
+	context.Unlock()
+
+	handler := healthstate.NewCheckHealthHandler(context)
+	c.Assert(handler.Before(), IsNil)
+	c.Assert(handler.Done(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	stored, err := healthstate.Get(s.state, "healthy")
+	c.Assert(err, IsNil)
+	c.Check(stored, DeepEquals, health)
+}
+
+func (s *healthSuite) TestHookDoneWithoutHealth(c *C) {
+	s.state.Lock()
+	context := s.hookContext(c)
+	s.state.Unlock()
+
+	c.Assert(healthstate.NewCheckHealthHandler(context).Done(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	stored, err := healthstate.Get(s.state, "healthy")
+	c.Assert(err, IsNil)
+	c.Check(stored, DeepEquals, &healthstate.HealthState{
+		Revision:  snap.R(2),
+		Timestamp: s.now,
+		Status:    healthstate.UnknownStatus,
+		Message:   "hook did not call set-health",
+		Code:      "snapd-hook-no-health-set",
+	})
+}
+
+func (s *healthSuite) TestHookError(c *C) {
+	s.state.Lock()
+	context := s.hookContext(c)
+	s.state.Unlock()
+
+	c.Assert(healthstate.NewCheckHealthHandler(context).Error(errors.New("boom")), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	stored, err := healthstate.Get(s.state, "healthy")
+	c.Assert(err, IsNil)
+	c.Check(stored, DeepEquals, &healthstate.HealthState{
+		Revision:  snap.R(2),
+		Timestamp: s.now,
+		Status:    healthstate.ErrorStatus,
+		Message:   "hook failed",
+		Code:      "snapd-hook-failed",
+	})
+}
+
+func (s *healthSuite) TestCheckSnapHealth(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	since := s.now.Add(-time.Minute)
+	check := func() error {
+		return snapstate.CheckSnapHealth(s.state, "healthy", snap.R(2), since)
+	}
+	set := func(rev int, timestamp time.Time, status healthstate.HealthStatus) {
+		c.Assert(healthstate.Set(s.state, "healthy", &healthstate.HealthState{
+			Revision:  snap.R(rev),
+			Timestamp: timestamp,
+			Status:    status,
+			Message:   "cannot reach the database",
+		}), IsNil)
+	}
+
+	// nothing reported
+	c.Check(check(), IsNil)
+	// reported by another revision, or before watching started
+	set(1, s.now, healthstate.ErrorStatus)
+	c.Check(check(), IsNil)
+	set(2, since.Add(-time.Second), healthstate.ErrorStatus)
+	c.Check(check(), IsNil)
+	// only errors are unhealthy
+	set(2, s.now, healthstate.BlockedStatus)
+	c.Check(check(), IsNil)
+	set(2, s.now, healthstate.ErrorStatus)
+	c.Check(check(), ErrorMatches, "snap reported error status: cannot reach the database")
+}
+
+func (s *healthSuite) TestEnsureChecksWatchedSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockSnap(c, healthySnapYaml, 2, true)
+
+	// a refresh that is done but for watching the snap
+	chg := s.state.NewChange("refresh-snap", "...")
+	link := s.state.NewTask("link-snap", "...")
+	link.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "healthy", Revision: snap.R(2)}})
+	link.SetStatus(state.DoneStatus)
+	chg.AddTask(link)
+	watch := s.state.NewTask("watch-snap", "...")
+	watch.WaitFor(link)
+	chg.AddTask(watch)
+
+	s.ensure(c)
+	var snaps []string
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "check-health" {
+			snaps = append(snaps, chg.Tasks()[0].Summary())
+		}
+	}
+	c.Check(snaps, DeepEquals, []string{`Run health check of "healthy" snap`})
+
+	// but not while the snap is being changed
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "check-health" {
+			chg.Abort()
+			chg.Tasks()[0].SetStatus(state.HoldStatus)
+		}
+	}
+	link.SetStatus(state.DoStatus)
+	s.now = s.now.Add(time.Hour)
+	s.ensure(c)
+	n := 0
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "check-health" {
+			n++
+		}
+	}
+	c.Check(n, Equals, 1)
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var timeNow = time.Now
+
+func init() {
+	snapstate.CheckSnapHealth = checkSnapHealth
+}
+
+// checkSnapHealth returns an error if the given revision of the snap
+// reported an error status since the given time.
+func checkSnapHealth(st *state.State, snapName string, rev snap.Revision, since time.Time) error {
+	health, err := Get(st, snapName)
+	if err != nil {
+		return err
+	}
+	if health == nil || health.Revision != rev || health.Timestamp.Before(since) {
+		return nil
+	}
+	if health.Status == ErrorStatus {
+		return fmt.Errorf("snap reported %s status: %s", health.Status, health.Message)
+	}
+	return nil
+}
+
+// checkHealthHandler is the handler for the check-health hook.
+type checkHealthHandler struct {
+	context *hookstate.Context
+}
+
+func newCheckHealthHandler(context *hookstate.Context) hookstate.Handler {
+	return &checkHealthHandler{context: context}
+}
+
+// Before is called by the HookManager before the check-health hook is run.
+func (h *checkHealthHandler) Before() error {
+	return nil
+}
+
+// Done is called by the HookManager after the check-health hook has
+// run successfully. It stores the health set by the hook via snapctl
+// set-health, or an unknown status if the hook did not set any.
+func (h *checkHealthHandler) Done() error {
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	var health HealthState
+	err := h.context.Get("health", &health)
+	if err == state.ErrNoState {
+		health = HealthState{
+			Revision:  h.context.SnapRevision(),
+			Timestamp: timeNow(),
+			Status:    UnknownStatus,
+			Message:   "hook did not call set-health",
+			Code:      "snapd-hook-no-health-set",
+		}
+	} else if err != nil {
+		return err
+	}
+	return Set(h.context.State(), h.context.SnapName(), &health)
+}
+
+// Error is called by the HookManager if the check-health hook fails.
+func (h *checkHealthHandler) Error(err error) error {
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	return Set(h.context.State(), h.context.SnapName(), &HealthState{
+		Revision:  h.context.SnapRevision(),
+		Timestamp: timeNow(),
+		Status:    ErrorStatus,
+		Message:   "hook failed",
+		Code:      "snapd-hook-failed",
+	})
+}
+
+func setupHooks(hookManager *hookstate.HookManager) {
+	hookManager.Register(regexp.MustCompile("^check-health$"), newCheckHealthHandler)
+}
@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package history keeps an on-disk archive of the changes pruned from
+// the state, so that what happened on the system can still be looked
+// at after the fact.
+//
+// The archive is a file with one JSON-encoded change per line. Once it
+// grows past a limit it is rotated, keeping a few older archives around.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	// maxArchiveSize is the size after which the archive is rotated
+	maxArchiveSize int64 = 1024 * 1024
+	// maxRotatedArchives is the number of rotated archives that are kept
+	maxRotatedArchives = 4
+
+	// mu serializes appending to and rotating the archives with
+	// reading them
+	mu sync.Mutex
+)
+
+func rotatedName(i int) string {
+	return fmt.Sprintf("%s.%d", dirs.SnapChangesArchiveFile, i)
+}
+
+// rotate moves the current archive out of the way, discarding the
+// oldest one if needed.
+func rotate() error {
+	if err := os.Remove(rotatedName(maxRotatedArchives)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := maxRotatedArchives - 1; i > 0; i-- {
+		if err := os.Rename(rotatedName(i), rotatedName(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if maxRotatedArchives == 0 {
+		return os.Remove(dirs.SnapChangesArchiveFile)
+	}
+	return os.Rename(dirs.SnapChangesArchiveFile, rotatedName(1))
+}
+
+// Append adds the given changes to the archive.
+func Append(chgs []*state.ArchivedChange) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if st, err := os.Stat(dirs.SnapChangesArchiveFile); err == nil && st.Size() >= maxArchiveSize {
+		if err := rotate(); err != nil {
+			return fmt.Errorf("cannot rotate changes archive: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(dirs.SnapChangesArchiveFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, chg := range chgs {
+		if err := enc.Encode(chg); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+type bySpawnTime []*state.ArchivedChange
+
+func (a bySpawnTime) Len() int           { return len(a) }
+func (a bySpawnTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a bySpawnTime) Less(i, j int) bool { return a[i].SpawnTime.Before(a[j].SpawnTime) }
+
+func readArchive(fn string, since time.Time, chgs []*state.ArchivedChange) ([]*state.ArchivedChange, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return chgs, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var chg state.ArchivedChange
+		if err := dec.Decode(&chg); err != nil {
+			// the last change was not completely written
+			logger.Noticef("cannot read changes archive %q, ignoring the rest of it: %v", fn, err)
+			break
+		}
+		if chg.SpawnTime.Before(since) {
+			continue
+		}
+		chgs = append(chgs, &chg)
+	}
+	return chgs, nil
+}
+
+// Changes returns the archived changes that were spawned no earlier
+// than since, sorted by spawn time.
+func Changes(since time.Time) ([]*state.ArchivedChange, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var chgs []*state.ArchivedChange
+	var err error
+	for i := maxRotatedArchives; i > 0; i-- {
+		chgs, err = readArchive(rotatedName(i), since, chgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	chgs, err = readArchive(dirs.SnapChangesArchiveFile, since, chgs)
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(bySpawnTime(chgs))
+	return chgs, nil
+}
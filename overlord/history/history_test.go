@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package history_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/history"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type historySuite struct{}
+
+var _ = Suite(&historySuite{})
+
+func (s *historySuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapChangesArchiveFile), 0755), IsNil)
+}
+
+func (s *historySuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+var t0 = time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func archivedChange(id int) *state.ArchivedChange {
+	spawnTime := t0.Add(time.Duration(id) * time.Hour)
+	return &state.ArchivedChange{
+		ID:      strconv.Itoa(id),
+		Kind:    "install-snap",
+		Summary: "...",
+		Status:  "Done",
+		Tasks: []*state.ArchivedTask{{
+			ID:      strconv.Itoa(id),
+			Kind:    "download-snap",
+			Summary: "...",
+			Status:  "Done",
+			Log:     []string{"2018-05-01T10:00:00Z INFO hello"},
+			StatusChanges: []state.ArchivedStatusChange{
+				{Status: "Doing", Time: spawnTime},
+				{Status: "Done", Time: spawnTime.Add(time.Minute)},
+			},
+			SpawnTime: spawnTime,
+			ReadyTime: spawnTime.Add(time.Minute),
+		}},
+		SpawnTime: spawnTime,
+		ReadyTime: spawnTime.Add(time.Minute),
+	}
+}
+
+func (s *historySuite) TestNoArchive(c *C) {
+	chgs, err := history.Changes(time.Time{})
+	c.Assert(err, IsNil)
+	c.Check(chgs, HasLen, 0)
+}
+
+func (s *historySuite) TestAppendAndChanges(c *C) {
+	err := history.Append([]*state.ArchivedChange{archivedChange(2), archivedChange(1)})
+	c.Assert(err, IsNil)
+	err = history.Append([]*state.ArchivedChange{archivedChange(3)})
+	c.Assert(err, IsNil)
+
+	chgs, err := history.Changes(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 3)
+	for i, chg := range chgs {
+		c.Check(chg, DeepEquals, archivedChange(i+1))
+	}
+	c.Check(chgs[0].Duration(), Equals, time.Minute)
+	c.Check(chgs[0].Tasks[0].Duration(), Equals, time.Minute)
+
+	chgs, err = history.Changes(t0.Add(2 * time.Hour))
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 2)
+	c.Check(chgs[0].ID, Equals, "2")
+	c.Check(chgs[1].ID, Equals, "3")
+}
+
+func (s *historySuite) TestRotation(c *C) {
+	restore := history.MockArchiveLimits(1, 2)
+	defer restore()
+
+	// every append after the first one rotates
+	for i := 1; i <= 4; i++ {
+		err := history.Append([]*state.ArchivedChange{archivedChange(i)})
+		c.Assert(err, IsNil)
+	}
+
+	c.Check(osutil.FileExists(dirs.SnapChangesArchiveFile+".1"), Equals, true)
+	c.Check(osutil.FileExists(dirs.SnapChangesArchiveFile+".2"), Equals, true)
+	c.Check(osutil.FileExists(dirs.SnapChangesArchiveFile+".3"), Equals, false)
+
+	// the oldest change was discarded
+	chgs, err := history.Changes(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 3)
+	c.Check(chgs[0].ID, Equals, "2")
+	c.Check(chgs[1].ID, Equals, "3")
+	c.Check(chgs[2].ID, Equals, "4")
+}
+
+func (s *historySuite) TestTornArchive(c *C) {
+	err := history.Append([]*state.ArchivedChange{archivedChange(1)})
+	c.Assert(err, IsNil)
+	f, err := os.OpenFile(dirs.SnapChangesArchiveFile, os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString(`{"id":"2","kind":"ins`)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	chgs, err := history.Changes(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].ID, Equals, "1")
+}
+
+func (s *historySuite) TestArchivePermissions(c *C) {
+	err := history.Append([]*state.ArchivedChange{archivedChange(1)})
+	c.Assert(err, IsNil)
+	st, err := os.Stat(dirs.SnapChangesArchiveFile)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+	content, err := ioutil.ReadFile(dirs.SnapChangesArchiveFile)
+	c.Assert(err, IsNil)
+	c.Check(content[len(content)-1], Equals, byte('\n'))
+}
+
+func (s *historySuite) TestChangesWhileRotating(c *C) {
+	restore := history.MockArchiveLimits(1, 100)
+	defer restore()
+
+	done := make(chan error)
+	go func() {
+		for i := 1; i <= 50; i++ {
+			if err := history.Append([]*state.ArchivedChange{archivedChange(i)}); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	// the changes read are always all the ones archived so far
+	for finished := false; !finished; {
+		select {
+		case err := <-done:
+			c.Assert(err, IsNil)
+			finished = true
+		default:
+		}
+		chgs, err := history.Changes(time.Time{})
+		c.Assert(err, IsNil)
+		for i, chg := range chgs {
+			c.Assert(chg.ID, Equals, strconv.Itoa(i+1))
+		}
+	}
+	chgs, err := history.Changes(time.Time{})
+	c.Assert(err, IsNil)
+	c.Check(chgs, HasLen, 50)
+}
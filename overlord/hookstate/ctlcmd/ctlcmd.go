@@ -18,13 +18,28 @@
  */
 
 // Package ctlcmd contains the various snapctl subcommands.
+//
+// The commands run either within a hook, or from an app of the snap with an
+// ephemeral context authenticated by the snap cookie. Outside of hooks:
+//
+//   - get, set and unset operate on the configuration of the snap only;
+//     plug and slot attributes are available to interface hooks alone.
+//   - changes made by set and unset are applied by running the configure
+//     hook of the snap asynchronously, if it has one.
+//   - services, start, stop and restart act on the services of the snap
+//     in a change of their own, instead of joining the change of a hook.
+//   - is-connected, refresh and set-health behave as they do in hooks.
+//
+// Any other command is rejected outside of hooks.
 package ctlcmd
 
 import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/hookstate"
 
@@ -75,6 +90,15 @@ type command interface {
 	Execute(args []string) error
 }
 
+// UnsuccessfulError carries a specific exit code to be returned to the client.
+type UnsuccessfulError struct {
+	ExitCode int
+}
+
+func (e *UnsuccessfulError) Error() string {
+	return fmt.Sprintf("unsuccessful with exit code: %d", e.ExitCode)
+}
+
 type commandInfo struct {
 	shortHelp string
 	longHelp  string
@@ -83,6 +107,22 @@ type commandInfo struct {
 
 var commands = make(map[string]*commandInfo)
 
+// allowedOutsideHooks holds the commands that may be run with an
+// ephemeral context, that is from the apps of the snap. Holding or
+// releasing the refresh of the snap is only meaningful from the
+// gate-auto-refresh hook, so refresh is not among them.
+var allowedOutsideHooks = map[string]bool{
+	"get":          true,
+	"set":          true,
+	"unset":        true,
+	"services":     true,
+	"start":        true,
+	"stop":         true,
+	"restart":      true,
+	"is-connected": true,
+	"set-health":   true,
+}
+
 func addCommand(name, shortHelp, longHelp string, generator func() command) {
 	commands[name] = &commandInfo{
 		shortHelp: shortHelp,
@@ -91,8 +131,28 @@ func addCommand(name, shortHelp, longHelp string, generator func() command) {
 	}
 }
 
+// commandName returns the name of the command requested by args, if any.
+func commandName(args []string) string {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
 // Run runs the requested command.
 func Run(context *hookstate.Context, args []string) (stdout, stderr []byte, err error) {
+	if context != nil && context.IsEphemeral() {
+		name := commandName(args)
+		if commands[name] != nil && !allowedOutsideHooks[name] {
+			return nil, nil, fmt.Errorf(i18n.G("cannot use %q outside of hooks"), "snapctl "+name)
+		}
+	}
+
 	parser := flags.NewParser(nil, flags.PassDoubleDash|flags.HelpFlag)
 
 	// Create stdout/stderr buffers, and make sure commands use them.
@@ -74,3 +74,36 @@ func (s *ctlcmdSuite) TestCommandOutput(c *C) {
 	c.Check(string(stderr), Equals, "test stderr")
 	c.Check(mockCommand.Args, DeepEquals, []string{"foo"})
 }
+
+func (s *ctlcmdSuite) TestCommandOutsideHooks(c *C) {
+	st := s.mockContext.State()
+	context, err := hookstate.NewContext(nil, st, &hookstate.HookSetup{Snap: "test-snap"}, nil, "cookie")
+	c.Assert(err, IsNil)
+
+	// refresh can only be used from hooks
+	for _, args := range [][]string{{"refresh", "--hold"}, {"refresh", "--proceed"}, {"--help", "refresh"}} {
+		_, _, err := ctlcmd.Run(context, args)
+		c.Check(err, ErrorMatches, `cannot use "snapctl refresh" outside of hooks`, Commentf("%v", args))
+	}
+
+	// nothing was held
+	st.Lock()
+	var holds map[string]interface{}
+	c.Check(st.Get("refresh-holds", &holds), Equals, state.ErrNoState)
+	st.Unlock()
+
+	// but from hooks it can
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"refresh", "--bad-flag"})
+	c.Check(err, ErrorMatches, ".*unknown flag.*")
+}
+
+func (s *ctlcmdSuite) TestCommandsAllowedOutsideHooks(c *C) {
+	st := s.mockContext.State()
+	context, err := hookstate.NewContext(nil, st, &hookstate.HookSetup{Snap: "test-snap"}, nil, "cookie")
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"get", "set", "unset", "services", "start", "stop", "restart", "is-connected", "set-health"} {
+		_, _, err := ctlcmd.Run(context, []string{name, "--bad-flag"})
+		c.Check(err, ErrorMatches, ".*unknown flag.*", Commentf(name))
+	}
+}
@@ -46,6 +46,7 @@ type getCommand struct {
 
 	Document bool `short:"d" description:"always return document, even with single key"`
 	Typed    bool `short:"t" description:"strict typing with nulls and quoted strings"`
+	Removed  bool `long:"removed" description:"list the options being removed by the configuration change"`
 }
 
 var shortGetHelp = i18n.G("The get command prints configuration and interface connection settings.")
@@ -80,6 +81,12 @@ by explicitly requesting that via the --plug and --slot command line options:
     $ snapctl get :myplug --slot usb-vendor
 
 This requests the "usb-vendor" setting from the slot that is connected to "myplug".
+
+From the configure hook, the options that the configuration change being
+applied removes may be listed, one per line, with:
+
+    $ snapctl get --removed
+    author.email
 `)
 
 func init() {
@@ -131,6 +138,10 @@ func (c *getCommand) printValues(getByKey func(string) (interface{}, bool, error
 }
 
 func (c *getCommand) Execute(args []string) error {
+	if c.Removed {
+		return c.printRemoved()
+	}
+
 	if c.Positional.PlugOrSlotSpec == "" && len(c.Positional.Keys) == 0 {
 		return fmt.Errorf(i18n.G("get which option?"))
 	}
@@ -164,6 +175,35 @@ func (c *getCommand) Execute(args []string) error {
 	return c.getConfigSetting(context)
 }
 
+// printRemoved prints the options removed by the configuration change
+// handed to the configure hook.
+func (c *getCommand) printRemoved() error {
+	if c.Positional.PlugOrSlotSpec != "" || len(c.Positional.Keys) != 0 {
+		return fmt.Errorf(i18n.G("cannot use --removed with option keys"))
+	}
+
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot get without a context")
+	}
+	if context.IsEphemeral() || context.HookName() != "configure" {
+		return fmt.Errorf(i18n.G("cannot use --removed outside of the configure hook"))
+	}
+
+	context.Lock()
+	var removed []string
+	err := context.Get("removed-options", &removed)
+	context.Unlock()
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	for _, key := range removed {
+		c.printf("%s\n", key)
+	}
+	return nil
+}
+
 func (c *getCommand) getConfigSetting(context *hookstate.Context) error {
 	if c.ForcePlugSide || c.ForceSlotSide {
 		return fmt.Errorf("cannot use --plug or --slot without <snap>:<plug|slot> argument")
@@ -196,6 +236,8 @@ const (
 	prepareSlotHook
 	connectPlugHook
 	connectSlotHook
+	disconnectPlugHook
+	disconnectSlotHook
 	unknownHook
 )
 
@@ -208,6 +250,10 @@ func interfaceHookType(hookName string) (ifaceHookType, error) {
 		return prepareSlotHook, nil
 	} else if strings.HasPrefix(hookName, "connect-slot-") {
 		return connectSlotHook, nil
+	} else if strings.HasPrefix(hookName, "disconnect-plug-") {
+		return disconnectPlugHook, nil
+	} else if strings.HasPrefix(hookName, "disconnect-slot-") {
+		return disconnectSlotHook, nil
 	}
 	return unknownHook, fmt.Errorf("unknown hook type")
 }
@@ -275,7 +321,7 @@ func (c *getCommand) getInterfaceSetting(context *hookstate.Context, plugOrSlot
 		return fmt.Errorf("cannot use --plug and --slot together")
 	}
 
-	isPlugSide := (hookType == preparePlugHook || hookType == connectPlugHook)
+	isPlugSide := (hookType == preparePlugHook || hookType == connectPlugHook || hookType == disconnectPlugHook)
 	if err = validatePlugOrSlot(attrsTask, isPlugSide, plugOrSlot); err != nil {
 		return err
 	}
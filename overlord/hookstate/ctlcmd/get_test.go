@@ -146,6 +146,31 @@ func (s *getSuite) TestGetTests(c *C) {
 	}
 }
 
+func (s *getSuite) TestGetRemoved(c *C) {
+	st := s.mockContext.State()
+	st.Lock()
+	task := st.NewTask("run-hook", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, st, setup, s.mockHandler, "")
+	st.Unlock()
+	c.Assert(err, IsNil)
+	context.Lock()
+	context.Set("removed-options", []string{"author.email", "foo"})
+	context.Unlock()
+
+	stdout, stderr, err := ctlcmd.Run(context, []string{"get", "--removed"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "author.email\nfoo\n")
+	c.Check(string(stderr), Equals, "")
+
+	_, _, err = ctlcmd.Run(context, []string{"get", "--removed", "foo"})
+	c.Check(err, ErrorMatches, "cannot use --removed with option keys")
+
+	// only the configure hook has a configuration change to look at
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"get", "--removed"})
+	c.Check(err, ErrorMatches, "cannot use --removed outside of the configure hook")
+}
+
 func (s *getSuite) TestCommandWithoutContext(c *C) {
 	_, _, err := ctlcmd.Run(nil, []string{"get", "foo"})
 	c.Check(err, ErrorMatches, ".*cannot get without a context.*")
@@ -317,3 +342,35 @@ func (s *setSuite) TestNull(c *C) {
 	c.Assert(tr.Get("test-snap", "bar", &value), IsNil)
 	c.Assert(value, DeepEquals, []interface{}{nil})
 }
+
+func (s *getAttrSuite) TestGetAttributesInDisconnectHooks(c *C) {
+	var attrsTaskID string
+	s.mockPlugHookContext.Lock()
+	c.Assert(s.mockPlugHookContext.Get("attrs-task", &attrsTaskID), IsNil)
+	s.mockPlugHookContext.Unlock()
+
+	st := s.mockPlugHookContext.State()
+	for _, test := range []struct {
+		hook, plugOrSlot string
+	}{
+		{"disconnect-plug-aplug", ":aplug"},
+		{"disconnect-slot-bslot", ":bslot"},
+	} {
+		st.Lock()
+		task := st.NewTask("run-hook", "my test task")
+		st.Unlock()
+		setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: test.hook}
+		context, err := hookstate.NewContext(task, st, setup, s.mockHandler, "")
+		c.Assert(err, IsNil)
+		context.Lock()
+		context.Set("attrs-task", attrsTaskID)
+		context.Unlock()
+
+		stdout, _, err := ctlcmd.Run(context, []string{"get", "--plug", test.plugOrSlot, "aattr"})
+		c.Check(err, IsNil, Commentf(test.hook))
+		c.Check(string(stdout), Equals, "foo\n")
+		stdout, _, err = ctlcmd.Run(context, []string{"get", "--slot", test.plugOrSlot, "battr"})
+		c.Check(err, IsNil, Commentf(test.hook))
+		c.Check(string(stdout), Equals, "bar\n")
+	}
+}
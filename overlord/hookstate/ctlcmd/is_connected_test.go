@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type isConnectedSuite struct {
+	st          *state.State
+	mockContext *hookstate.Context
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&isConnectedSuite{})
+
+const isConnectedPlugSnapYaml = `name: plug-snap
+version: 1.0
+plugs:
+ plug1:
+  interface: test
+ plug2:
+  interface: test
+`
+
+const isConnectedSlotSnapYaml = `name: slot-snap
+version: 1.0
+slots:
+ slot1:
+  interface: test
+ slot2:
+  interface: test
+`
+
+func (s *isConnectedSuite) SetUpTest(c *C) {
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	repo := interfaces.NewRepository()
+	c.Assert(repo.AddInterface(&ifacetest.TestInterface{InterfaceName: "test"}), IsNil)
+	plugSnap := snaptest.MockInfo(c, isConnectedPlugSnapYaml, nil)
+	slotSnap := snaptest.MockInfo(c, isConnectedSlotSnapYaml, nil)
+	c.Assert(repo.AddSnap(plugSnap), IsNil)
+	c.Assert(repo.AddSnap(slotSnap), IsNil)
+	err := repo.Connect(interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "plug-snap", Name: "plug1"},
+		SlotRef: interfaces.SlotRef{Snap: "slot-snap", Name: "slot1"},
+	})
+	c.Assert(err, IsNil)
+	ifacerepo.Replace(s.st, repo)
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "plug-snap", Revision: snap.R(1), Hook: "test-hook"}
+
+	s.mockContext, err = hookstate.NewContext(task, task.State(), setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+}
+
+func (s *isConnectedSuite) TestConnected(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "plug1"})
+	c.Check(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *isConnectedSuite) TestNotConnected(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "plug2"})
+	c.Check(err, DeepEquals, &ctlcmd.UnsuccessfulError{ExitCode: 1})
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *isConnectedSuite) TestOtherSnap(c *C) {
+	// only the plugs and slots of the calling snap can be queried
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "slot1"})
+	c.Check(err, ErrorMatches, `snap "plug-snap" has no plug or slot named "slot1"`)
+}
+
+func (s *isConnectedSuite) TestSlotSide(c *C) {
+	s.st.Lock()
+	task := s.st.NewTask("test-task", "my test task")
+	s.st.Unlock()
+	setup := &hookstate.HookSetup{Snap: "slot-snap", Revision: snap.R(1), Hook: "test-hook"}
+	context, err := hookstate.NewContext(task, task.State(), setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"is-connected", "slot1"})
+	c.Check(err, IsNil)
+	_, _, err = ctlcmd.Run(context, []string{"is-connected", "slot2"})
+	c.Check(err, DeepEquals, &ctlcmd.UnsuccessfulError{ExitCode: 1})
+}
+
+func (s *isConnectedSuite) TestMissingArgument(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected"})
+	c.Check(err, ErrorMatches, "the required argument `<plug|slot>` was not provided")
+}
+
+func (s *isConnectedSuite) TestWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"is-connected", "plug1"})
+	c.Check(err, ErrorMatches, "cannot check connection without a context")
+}
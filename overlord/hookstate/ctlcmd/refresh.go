@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+)
+
+var (
+	shortRefreshHelp = i18n.G("Hold or proceed with the refresh of the snap")
+	longRefreshHelp  = i18n.G(`
+The refresh command lets a snap control its own automatic refreshes.
+
+With --hold the automatic refresh of the snap is postponed, for example
+while the snap is busy with work that must not be interrupted. Each hold
+lasts a day and can be extended by holding again, up to a maximum
+postponement after which the snap is refreshed anyway.
+
+With --proceed a previous hold is released, and the snap is refreshed
+with the next automatic refresh.`)
+)
+
+func init() {
+	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() command { return &refreshCommand{} })
+}
+
+type refreshCommand struct {
+	baseCommand
+
+	Hold    bool `long:"hold" description:"Postpone the automatic refresh of the snap"`
+	Proceed bool `long:"proceed" description:"Release a previous hold on the automatic refresh of the snap"`
+}
+
+func (c *refreshCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot refresh without a context")
+	}
+
+	if c.Hold == c.Proceed {
+		return fmt.Errorf(i18n.G("exactly one of --hold or --proceed is required"))
+	}
+
+	context.Lock()
+	defer context.Unlock()
+
+	if c.Proceed {
+		return snapstate.ProceedWithRefresh(context.State(), context.SnapName())
+	}
+
+	holdUntil, err := snapstate.HoldRefresh(context.State(), context.SnapName())
+	if err != nil {
+		return err
+	}
+	c.printf("%s\n", holdUntil.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type refreshSuite struct {
+	st          *state.State
+	mockContext *hookstate.Context
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&refreshSuite{})
+
+func (s *refreshSuite) SetUpTest(c *C) {
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "test-hook"}
+
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, task.State(), setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+}
+
+func (s *refreshSuite) refreshHolds(c *C) map[string]map[string]time.Time {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	var holds map[string]map[string]time.Time
+	c.Assert(s.st.Get("refresh-holds", &holds), IsNil)
+	return holds
+}
+
+func (s *refreshSuite) TestRefreshHold(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"refresh", "--hold"})
+	c.Assert(err, IsNil)
+	c.Check(string(stderr), Equals, "")
+
+	holds := s.refreshHolds(c)
+	c.Assert(holds["test-snap"], NotNil)
+	holdUntil := holds["test-snap"]["hold-until"]
+	c.Check(holdUntil.After(time.Now()), Equals, true)
+	c.Check(string(stdout), Equals, holdUntil.Format(time.RFC3339)+"\n")
+}
+
+func (s *refreshSuite) TestRefreshProceed(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"refresh", "--hold"})
+	c.Assert(err, IsNil)
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"refresh", "--proceed"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	holds := s.refreshHolds(c)
+	c.Check(holds["test-snap"]["hold-until"].After(time.Now()), Equals, false)
+}
+
+func (s *refreshSuite) TestRefreshNeedsExactlyOneFlag(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"refresh"})
+	c.Check(err, ErrorMatches, "exactly one of --hold or --proceed is required")
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"refresh", "--hold", "--proceed"})
+	c.Check(err, ErrorMatches, "exactly one of --hold or --proceed is required")
+}
+
+func (s *refreshSuite) TestRefreshWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"refresh", "--hold"})
+	c.Check(err, ErrorMatches, "cannot refresh without a context")
+}
@@ -0,0 +1,111 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	shortServicesHelp = i18n.G("Query the status of services")
+	longServicesHelp  = i18n.G(`
+The services command lists the services of the snap along with whether they
+are enabled to start at boot and whether they are currently active. If no
+services are given, all the services of the snap are listed.`)
+)
+
+func init() {
+	addCommand("services", shortServicesHelp, longServicesHelp, func() command { return &servicesCommand{} })
+}
+
+type servicesCommand struct {
+	baseCommand
+	Positional struct {
+		ServiceNames []string `positional-arg-name:"<service>"`
+	} `positional-args:"yes"`
+}
+
+type byAppName []*snap.AppInfo
+
+func (a byAppName) Len() int           { return len(a) }
+func (a byAppName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAppName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func (c *servicesCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot query services without a context"))
+	}
+
+	snapName := context.SnapName()
+	serviceNames := c.Positional.ServiceNames
+	if len(serviceNames) == 0 {
+		serviceNames = []string{snapName}
+	}
+
+	svcs, err := getServiceInfos(context.State(), snapName, serviceNames)
+	if err != nil {
+		return err
+	}
+	if len(svcs) == 0 {
+		return fmt.Errorf(i18n.G("snap %q has no services"), snapName)
+	}
+	sort.Sort(byAppName(svcs))
+
+	unitNames := make([]string, len(svcs))
+	for i, svc := range svcs {
+		unitNames[i] = svc.ServiceName()
+	}
+	sysd := systemd.New(dirs.GlobalRootDir, progress.Null)
+	sts, err := sysd.Status(unitNames...)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot get status of services: %v"), err)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 5, 3, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Service\tStartup\tCurrent\tNotes"))
+	for i, svc := range svcs {
+		startup := i18n.G("disabled")
+		if sts[i].Enabled {
+			startup = i18n.G("enabled")
+		}
+		current := i18n.G("inactive")
+		if sts[i].Active {
+			current = i18n.G("active")
+		}
+		notes := "-"
+		if svc.Timer != nil {
+			notes = i18n.G("timer-activated")
+		}
+		fmt.Fprintf(w, "%s.%s\t%s\t%s\t%s\n", snapName, svc.Name, startup, current, notes)
+	}
+
+	return nil
+}
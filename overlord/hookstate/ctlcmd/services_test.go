@@ -38,6 +38,7 @@ import (
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -347,8 +348,8 @@ func (s *servicectlSuite) TestQueuedCommandsUpdateMany(c *C) {
 	sort.Strings(installed)
 	c.Check(installed, DeepEquals, []string{"other-snap", "test-snap"})
 	c.Assert(tts, HasLen, 2)
-	c.Assert(tts[0].Tasks(), HasLen, 17)
-	c.Assert(tts[1].Tasks(), HasLen, 17)
+	c.Assert(tts[0].Tasks(), HasLen, 18)
+	c.Assert(tts[1].Tasks(), HasLen, 18)
 	chg.AddAll(tts[0])
 	chg.AddAll(tts[1])
 
@@ -376,11 +377,11 @@ func (s *servicectlSuite) TestQueuedCommandsUpdateMany(c *C) {
 
 	for i := 1; i <= 2; i++ {
 		laneTasks := chg.LaneTasks(i)
-		c.Assert(laneTasks, HasLen, 20)
-		c.Check(laneTasks[16].Summary(), Matches, `Run configure hook of .* snap if present`)
-		c.Check(laneTasks[17].Summary(), Equals, "stop of [test-snap.test-service]")
-		c.Check(laneTasks[18].Summary(), Equals, "start of [test-snap.test-service]")
-		c.Check(laneTasks[19].Summary(), Equals, "restart of [test-snap.test-service]")
+		c.Assert(laneTasks, HasLen, 21)
+		c.Check(laneTasks[17].Summary(), Matches, `Run configure hook of .* snap if present`)
+		c.Check(laneTasks[18].Summary(), Equals, "stop of [test-snap.test-service]")
+		c.Check(laneTasks[19].Summary(), Equals, "start of [test-snap.test-service]")
+		c.Check(laneTasks[20].Summary(), Equals, "restart of [test-snap.test-service]")
 	}
 }
 
@@ -420,3 +421,49 @@ func (s *servicectlSuite) TestQueuedCommandsSingleLane(c *C) {
 	c.Check(laneTasks[13].Summary(), Equals, "start of [test-snap.test-service]")
 	c.Check(laneTasks[14].Summary(), Equals, "restart of [test-snap.test-service]")
 }
+
+func (s *servicectlSuite) TestServicesCommand(c *C) {
+	var sysctlArgs [][]string
+	restore := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		sysctlArgs = append(sysctlArgs, args)
+		return []byte(`Type=simple
+Id=snap.test-snap.test-service.service
+ActiveState=active
+UnitFileState=enabled
+`), nil
+	})
+	defer restore()
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"services"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `
+Service                 Startup  Current  Notes
+test-snap.test-service  enabled  active   -
+`[1:])
+	c.Check(string(stderr), Equals, "")
+	c.Check(sysctlArgs, DeepEquals, [][]string{
+		{"show", "--property=Id,Type,ActiveState,UnitFileState", "snap.test-snap.test-service.service"},
+	})
+
+	sysctlArgs = nil
+	stdout, _, err = ctlcmd.Run(s.mockContext, []string{"services", "test-snap.test-service"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Matches, `(?s).*test-snap.test-service +enabled +active +-\n`)
+	c.Check(sysctlArgs, HasLen, 1)
+}
+
+func (s *servicectlSuite) TestServicesCommandFailsOnOtherSnap(c *C) {
+	restore := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		c.Fatalf("systemctl should not be called")
+		return nil, nil
+	})
+	defer restore()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"services", "other-snap.test-service"})
+	c.Assert(err, ErrorMatches, `unknown service: "other-snap.test-service"`)
+}
+
+func (s *servicectlSuite) TestServicesCommandWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"services"})
+	c.Assert(err, ErrorMatches, "cannot query services without a context")
+}
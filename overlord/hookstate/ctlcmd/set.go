@@ -45,7 +45,9 @@ The set command changes the provided configuration options as requested.
     $ snapctl set username=frank password=$PASSWORD
 
 All configuration changes are persisted at once, and only after the hook
-returns successfully.
+returns successfully. When run from an app of the snap instead of a hook, the
+changes are passed to the configure hook of the snap, which runs in the
+background, and only take effect if it succeeds.
 
 Nested values may be modified via a dotted path:
 
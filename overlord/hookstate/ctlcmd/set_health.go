@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+)
+
+var (
+	shortSetHealthHelp = i18n.G("Report the health status of the snap")
+	longSetHealthHelp  = i18n.G(`
+The set-health command reports the health of the snap, as one of okay,
+waiting, blocked or error. All statuses but okay require a message of at most
+70 characters explaining it; a code can be given to identify the status for
+tools monitoring the snap.
+
+    $ snapctl set-health blocked "cannot reach the database" --code=db-down
+
+When called from the check-health hook the health is stored once the hook
+returns successfully.
+`)
+)
+
+func init() {
+	addCommand("set-health", shortSetHealthHelp, longSetHealthHelp, func() command { return &setHealthCommand{} })
+}
+
+type setHealthCommand struct {
+	baseCommand
+	Code       string `long:"code" value-name:"<code>"`
+	Positional struct {
+		Status  string `positional-arg-name:"<status>" required:"yes"`
+		Message string `positional-arg-name:"<message>"`
+	} `positional-args:"yes"`
+}
+
+func (c *setHealthCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot set health without a context"))
+	}
+
+	status, err := healthstate.StatusLookup(c.Positional.Status)
+	if err != nil || status == healthstate.UnknownStatus {
+		return fmt.Errorf(i18n.G("invalid status %q (expected okay, waiting, blocked or error)"), c.Positional.Status)
+	}
+
+	health := &healthstate.HealthState{
+		Revision:  context.SnapRevision(),
+		Timestamp: time.Now(),
+		Status:    status,
+		Message:   c.Positional.Message,
+		Code:      c.Code,
+	}
+	if err := health.Validate(); err != nil {
+		return err
+	}
+
+	context.Lock()
+	defer context.Unlock()
+
+	if context.IsEphemeral() {
+		// reported by an app, for the revision currently installed
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(context.State(), context.SnapName(), &snapst); err != nil {
+			return fmt.Errorf(i18n.G("cannot find current revision of snap %q: %v"), context.SnapName(), err)
+		}
+		health.Revision = snapst.Current
+	}
+
+	if !context.IsEphemeral() && context.HookName() == "check-health" {
+		// stored by the hook handler once the hook is done
+		context.Set("health", health)
+		return nil
+	}
+	return healthstate.Set(context.State(), context.SnapName(), health)
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type setHealthSuite struct {
+	st          *state.State
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&setHealthSuite{})
+
+func (s *setHealthSuite) SetUpTest(c *C) {
+	s.mockHandler = hooktest.NewMockHandler()
+	s.st = state.New(nil)
+}
+
+func (s *setHealthSuite) context(c *C, hook string) *hookstate.Context {
+	s.st.Lock()
+	task := s.st.NewTask("test-task", "my test task")
+	s.st.Unlock()
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: hook}
+	context, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	return context
+}
+
+func (s *setHealthSuite) TestSetHealth(c *C) {
+	context := s.context(c, "configure")
+
+	stdout, stderr, err := ctlcmd.Run(context, []string{"set-health", "blocked", "cannot reach the database", "--code=db-down"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	health, err := healthstate.Get(s.st, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(health, NotNil)
+	c.Check(health.Revision, Equals, snap.R(1))
+	c.Check(health.Status, Equals, healthstate.BlockedStatus)
+	c.Check(health.Message, Equals, "cannot reach the database")
+	c.Check(health.Code, Equals, "db-down")
+	c.Check(health.Timestamp.IsZero(), Equals, false)
+}
+
+func (s *setHealthSuite) TestSetHealthCheckHealthHook(c *C) {
+	context := s.context(c, "check-health")
+
+	_, _, err := ctlcmd.Run(context, []string{"set-health", "okay"})
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+	// nothing is stored until the hook is done
+	health, err := healthstate.Get(s.st, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(health, IsNil)
+
+	var pending healthstate.HealthState
+	c.Assert(context.Get("health", &pending), IsNil)
+	c.Check(pending.Status, Equals, healthstate.OkayStatus)
+}
+
+func (s *setHealthSuite) TestSetHealthFromApp(c *C) {
+	s.st.Lock()
+	snapstate.Set(s.st, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "test-snap", Revision: snap.R(1)}, {RealName: "test-snap", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+	})
+	s.st.Unlock()
+	context, err := hookstate.NewContext(nil, s.st, &hookstate.HookSetup{Snap: "test-snap"}, nil, "cookie")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"set-health", "error", "cannot start"})
+	c.Assert(err, IsNil)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	health, err := healthstate.Get(s.st, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(health, NotNil)
+	// reported for the current revision
+	c.Check(health.Revision, Equals, snap.R(7))
+	c.Check(health.Status, Equals, healthstate.ErrorStatus)
+	c.Check(health.Message, Equals, "cannot start")
+}
+
+func (s *setHealthSuite) TestSetHealthFromAppNotInstalled(c *C) {
+	context, err := hookstate.NewContext(nil, s.st, &hookstate.HookSetup{Snap: "test-snap"}, nil, "cookie")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"set-health", "okay"})
+	c.Check(err, ErrorMatches, `cannot find current revision of snap "test-snap": .*`)
+}
+
+func (s *setHealthSuite) TestSetHealthErrors(c *C) {
+	context := s.context(c, "configure")
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"set-health", "bad"}, `invalid status "bad" \(expected okay, waiting, blocked or error\)`},
+		{[]string{"set-health", "unknown"}, `invalid status "unknown" .*`},
+		{[]string{"set-health", "waiting"}, `health status "waiting" requires a message`},
+		{[]string{"set-health", "okay", "--code=Bad"}, `invalid health code "Bad"`},
+		{[]string{"set-health", "error", "this message is far too long to be shown in a single line of snap info, or so we think"}, `health message must be at most 70 characters long`},
+	} {
+		_, _, err := ctlcmd.Run(context, t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	health, err := healthstate.Get(s.st, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(health, IsNil)
+}
+
+func (s *setHealthSuite) TestSetHealthNoContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"set-health", "okay"})
+	c.Check(err, ErrorMatches, "cannot set health without a context")
+}
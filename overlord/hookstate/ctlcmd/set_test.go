@@ -22,13 +22,16 @@ package ctlcmd_test
 import (
 	"encoding/json"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
 
 	. "gopkg.in/check.v1"
 )
@@ -170,6 +173,46 @@ func (s *setSuite) TestCommandWithoutContext(c *C) {
 	c.Check(err, ErrorMatches, ".*cannot set without a context.*")
 }
 
+func (s *setSuite) TestCommandEphemeralRunsConfigureHook(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+	restore := snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {})
+	defer restore()
+
+	st := s.mockContext.State()
+	st.Lock()
+	sideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, "name: test-snap\nhooks:\n    configure:\n", sideInfo)
+	snapstate.Set(st, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{sideInfo},
+		Current:  snap.R(1),
+	})
+	st.Unlock()
+
+	ephemeral, err := hookstate.NewContext(nil, st, &hookstate.HookSetup{Snap: "test-snap"}, nil, "cookie")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(ephemeral, []string{"set", ":foo", "bar=baz"})
+	c.Check(err, ErrorMatches, ".*interface attributes can only be set during the execution of prepare hooks.*")
+
+	_, _, err = ctlcmd.Run(ephemeral, []string{"set", "foo=bar"})
+	c.Assert(err, IsNil)
+
+	ephemeral.Lock()
+	defer ephemeral.Unlock()
+	c.Check(ephemeral.Done(), IsNil)
+
+	// the configure hook of the snap gets to see the change first
+	var value string
+	tr := config.NewTransaction(st)
+	c.Check(tr.Get("test-snap", "foo", &value), ErrorMatches, ".*snap.*has no.*configuration.*")
+
+	chgs := st.Changes()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Kind(), Equals, "configure-snap")
+}
+
 func (s *setAttrSuite) SetUpTest(c *C) {
 	s.mockHandler = hooktest.NewMockHandler()
 	state := state.New(nil)
@@ -44,3 +44,15 @@ func MockErrtrackerReport(mock func(string, string, string, map[string]string) (
 	errtrackerReport = mock
 	return func() { errtrackerReport = prev }
 }
+
+func MockSystemdSendToJournal(mock func(message string, fields map[string]string) error) (restore func()) {
+	prev := systemdSendToJournal
+	systemdSendToJournal = mock
+	return func() { systemdSendToJournal = prev }
+}
+
+func MockMaxHookOutputLog(size int) (restore func()) {
+	prev := maxHookOutputLog
+	maxHookOutputLog = size
+	return func() { maxHookOutputLog = prev }
+}
@@ -20,6 +20,7 @@
 package hookstate
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -34,9 +35,11 @@ import (
 	"github.com/snapcore/snapd/errtracker"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
 )
 
 type hijackFunc func(ctx *Context) error
@@ -243,10 +246,15 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 			return fmt.Errorf("cannot read %q snap details: %v", hooksup.Snap, err)
 		}
 
-		hookExists = info.Hooks[hooksup.Hook] != nil
+		hookInfo := info.Hooks[hooksup.Hook]
+		hookExists = hookInfo != nil
 		if !hookExists && !hooksup.Optional {
 			return fmt.Errorf("snap %q has no %q hook", hooksup.Snap, hooksup.Hook)
 		}
+		// a timeout declared by the snap itself takes precedence
+		if hookExists && hookInfo.Timeout != 0 {
+			hooksup.Timeout = time.Duration(hookInfo.Timeout)
+		}
 	}
 
 	context, err := NewContext(task, task.State(), hooksup, nil, "")
@@ -294,6 +302,7 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 		err = f(context)
 	} else if hookExists {
 		output, err = runHook(context, tomb)
+		m.logHookOutput(task, hooksup, output, err == nil)
 	}
 	if err != nil {
 		if hooksup.TrackError {
@@ -326,6 +335,58 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 	return nil
 }
 
+// maxHookOutputLog is the maximum amount of hook output, counted from its
+// end, that is kept in the task log.
+var maxHookOutputLog = 16 * 1024
+
+var systemdSendToJournal = systemd.SendToJournal
+
+// logHookOutput records the output of a hook in the task log and, if
+// enabled via the core "hooks.output-to-journal" option, in the journal.
+// When the hook failed its output is already part of the task error, so
+// only the journal gets it.
+func (m *HookManager) logHookOutput(task *state.Task, hooksup *HookSetup, output []byte, toTaskLog bool) {
+	if len(bytes.TrimSpace(output)) == 0 {
+		return
+	}
+
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if toTaskLog {
+		logged := bytes.TrimRight(output, "\n")
+		if len(logged) > maxHookOutputLog {
+			logged = append([]byte("[...] "), logged[len(logged)-maxHookOutputLog:]...)
+		}
+		task.Logf("output of hook %q:\n%s", hooksup.Hook, logged)
+	}
+
+	if !hookOutputToJournal(st) {
+		return
+	}
+	fields := map[string]string{
+		"SYSLOG_IDENTIFIER": fmt.Sprintf("snap.%s.hook.%s", hooksup.Snap, hooksup.Hook),
+		"SNAP_NAME":         hooksup.Snap,
+		"SNAP_HOOK":         hooksup.Hook,
+		"SNAP_REVISION":     hooksup.Revision.String(),
+	}
+	if err := systemdSendToJournal(string(output), fields); err != nil {
+		logger.Noticef("cannot send output of hook %q of snap %q to the journal: %v", hooksup.Hook, hooksup.Snap, err)
+	}
+}
+
+func hookOutputToJournal(st *state.State) bool {
+	var enabled interface{}
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "hooks.output-to-journal", &enabled); err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot read hooks.output-to-journal option: %v", err)
+		return false
+	}
+	// the option may have been set as a string or as a boolean
+	return fmt.Sprintf("%v", enabled) == "true"
+}
+
 func runHookImpl(c *Context, tomb *tomb.Tomb) ([]byte, error) {
 	return runHookAndWait(c.SnapName(), c.SnapRevision(), c.HookName(), c.ID(), c.Timeout(), tomb)
 }
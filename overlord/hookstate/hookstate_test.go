@@ -31,9 +31,11 @@ import (
 	"time"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -341,6 +343,108 @@ func (s *hookManagerSuite) TestHookTaskHandlesHookError(c *C) {
 	checkTaskLogContains(c, s.task, ".*failed at user request.*")
 }
 
+func (s *hookManagerSuite) TestHookTaskLogsOutput(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "echo 'hello from the hook'")
+	defer cmd.Restore()
+
+	var journaled int
+	restore := hookstate.MockSystemdSendToJournal(func(string, map[string]string) error {
+		journaled++
+		return nil
+	})
+	defer restore()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	checkTaskLogContains(c, s.task, `(?s).*output of hook "configure":\nhello from the hook`)
+	// not sent to the journal unless asked to
+	c.Check(journaled, Equals, 0)
+}
+
+func (s *hookManagerSuite) TestHookTaskLogsOutputTruncated(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "echo 'some long output'")
+	defer cmd.Restore()
+	restore := hookstate.MockMaxHookOutputLog(6)
+	defer restore()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	checkTaskLogContains(c, s.task, `(?s).*output of hook "configure":\n\[\.\.\.\] output`)
+}
+
+func (s *hookManagerSuite) TestHookTaskOutputToJournal(c *C) {
+	cmd := testutil.MockCommand(c, "snap", ">&2 echo 'hook failed at user request'; exit 1")
+	defer cmd.Restore()
+
+	var message string
+	var fields map[string]string
+	restore := hookstate.MockSystemdSendToJournal(func(msg string, f map[string]string) error {
+		message = msg
+		fields = f
+		return nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "hooks.output-to-journal", true)
+	tr.Commit()
+	s.state.Unlock()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	c.Check(message, Equals, "hook failed at user request\n")
+	c.Check(fields, DeepEquals, map[string]string{
+		"SYSLOG_IDENTIFIER": "snap.test-snap.hook.configure",
+		"SNAP_NAME":         "test-snap",
+		"SNAP_HOOK":         "configure",
+		"SNAP_REVISION":     "1",
+	})
+}
+
+func (s *hookManagerSuite) TestHookTaskUsesDeclaredTimeout(c *C) {
+	s.state.Lock()
+	sideInfo := &snap.SideInfo{RealName: "test-snap", SnapID: "some-snap-id", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `
+name: test-snap
+version: 1.0
+hooks:
+    configure:
+        timeout: 42s
+`, sideInfo)
+	s.state.Unlock()
+
+	var timeout time.Duration
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		timeout = ctx.Timeout()
+		return nil, nil
+	})
+	defer restore()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	c.Check(timeout, Equals, 42*time.Second)
+}
+
 func (s *hookManagerSuite) TestHookTaskHandleIgnoreErrorWorks(c *C) {
 	s.state.Lock()
 	var hooksup hookstate.HookSetup
@@ -479,12 +479,164 @@ func (m *InterfaceManager) doDisconnect(task *state.Task, _ *tomb.Tomb) error {
 	}
 
 	conn := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	if old, ok := conns[conn.ID()]; ok {
+		// remember the connection so that it can be restored on undo
+		task.Set("old-conn", old)
+	}
 	delete(conns, conn.ID())
 
 	setConns(st, conns)
 	return nil
 }
 
+func (m *InterfaceManager) undoDisconnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var old connState
+	err := task.Get("old-conn", &old)
+	if err == state.ErrNoState {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	connRef := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	if err := m.repo.Connect(connRef); err != nil {
+		return err
+	}
+	for _, snapName := range []string{plugRef.Snap, slotRef.Snap} {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, snapName, &snapst); err != nil {
+			return err
+		}
+		snapInfo, err := snapst.CurrentInfo()
+		if err != nil {
+			return err
+		}
+		opts := confinementOptions(snapst.Flags)
+		if err := m.setupSnapSecurity(task, snapInfo, opts); err != nil {
+			return err
+		}
+	}
+
+	conns[connRef.ID()] = old
+	setConns(st, conns)
+	return nil
+}
+
+// doAutoDisconnect adds to the change the tasks needed to disconnect all
+// the connections of a snap that is being removed, or the connections
+// that the revision a snap is being refreshed to no longer supports, so
+// that the disconnect hooks of the snaps on both sides of each connection
+// get to run.
+func (m *InterfaceManager) doAutoDisconnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, err := snapstate.TaskSnapSetup(task)
+	if err != nil {
+		return err
+	}
+	snapName := snapsup.Name()
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil {
+		return err
+	}
+	// when switching to another revision only the plugs and slots
+	// missing from it lose their connections
+	var newInfo *snap.Info
+	if snapsup.Revision() != snapst.Current {
+		newInfo, err = snap.ReadInfo(snapName, snapsup.SideInfo)
+		if err != nil {
+			return err
+		}
+		addImplicitSlots(newInfo)
+	}
+	dropped := func(connRef interfaces.ConnRef) bool {
+		if newInfo == nil {
+			return true
+		}
+		if connRef.PlugRef.Snap == snapName && newInfo.Plugs[connRef.PlugRef.Name] == nil {
+			return true
+		}
+		return connRef.SlotRef.Snap == snapName && newInfo.Slots[connRef.SlotRef.Name] == nil
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	disconnectAll := state.NewTaskSet()
+	var prev *state.TaskSet
+	for _, id := range ids {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		if connRef.PlugRef.Snap != snapName && connRef.SlotRef.Snap != snapName {
+			continue
+		}
+		if !dropped(connRef) {
+			continue
+		}
+		ts, err := disconnectTasks(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if err != nil {
+			task.Logf("skipping disconnect hooks for connection %s: %v", id, err)
+			continue
+		}
+		if prev != nil {
+			ts.WaitAll(prev)
+		}
+		disconnectAll.AddAll(ts)
+		prev = ts
+	}
+
+	if len(disconnectAll.Tasks()) > 0 {
+		injectTasks(task, disconnectAll)
+	}
+	return nil
+}
+
+// injectTasks adds the given tasks to the change of mainTask, making
+// them run after mainTask but before any of the tasks waiting for it.
+func injectTasks(mainTask *state.Task, extraTasks *state.TaskSet) {
+	lanes := mainTask.Lanes()
+	if len(lanes) == 1 && lanes[0] == 0 {
+		lanes = nil
+	}
+	for _, l := range lanes {
+		extraTasks.JoinLane(l)
+	}
+
+	for _, t := range mainTask.HaltTasks() {
+		t.WaitAll(extraTasks)
+	}
+	extraTasks.WaitFor(mainTask)
+	mainTask.Change().AddAll(extraTasks)
+}
+
 // transitionConnectionsCoreMigration will transition all connections
 // from oldName to newName. Note that this is only useful when you
 // know that newName supports everything that oldName supports,
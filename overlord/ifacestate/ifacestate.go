@@ -156,7 +156,7 @@ func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName strin
 	return nil
 }
 
-// Disconnect returns a set of tasks for  disconnecting an interface.
+// Disconnect returns a set of tasks for disconnecting an interface.
 func Disconnect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
 	if err := snapstate.CheckChangeConflict(st, plugSnap, noConflictOnConnectTasks, nil); err != nil {
 		return nil, err
@@ -165,12 +165,51 @@ func Disconnect(st *state.State, plugSnap, plugName, slotSnap, slotName string)
 		return nil, err
 	}
 
+	return disconnectTasks(st, plugSnap, plugName, slotSnap, slotName)
+}
+
+func disconnectTasks(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
+	// Create a series of tasks:
+	//  - disconnect-slot-<slot> hook
+	//  - disconnect-plug-<plug> hook
+	//  - disconnect task
+	// The tasks run in sequence (are serialized by WaitFor).
+	// The hooks run while the connection is still in place, and can
+	// read the attributes of both sides via 'snapctl get'.
 	summary := fmt.Sprintf(i18n.G("Disconnect %s:%s from %s:%s"),
 		plugSnap, plugName, slotSnap, slotName)
-	task := st.NewTask("disconnect", summary)
-	task.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
-	task.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
-	return state.NewTaskSet(task), nil
+	disconnectInterface := st.NewTask("disconnect", summary)
+	disconnectInterface.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
+	disconnectInterface.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
+	if err := setInitialConnectAttributes(disconnectInterface, plugSnap, plugName, slotSnap, slotName); err != nil {
+		return nil, err
+	}
+
+	initialContext := make(map[string]interface{})
+	initialContext["attrs-task"] = disconnectInterface.ID()
+
+	disconnectSlotHookSetup := &hookstate.HookSetup{
+		Snap:     slotSnap,
+		Hook:     "disconnect-slot-" + slotName,
+		Optional: true,
+	}
+
+	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), disconnectSlotHookSetup.Hook, disconnectSlotHookSetup.Snap)
+	disconnectSlot := hookstate.HookTask(st, summary, disconnectSlotHookSetup, initialContext)
+
+	disconnectPlugHookSetup := &hookstate.HookSetup{
+		Snap:     plugSnap,
+		Hook:     "disconnect-plug-" + plugName,
+		Optional: true,
+	}
+
+	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), disconnectPlugHookSetup.Hook, disconnectPlugHookSetup.Snap)
+	disconnectPlug := hookstate.HookTask(st, summary, disconnectPlugHookSetup, initialContext)
+	disconnectPlug.WaitFor(disconnectSlot)
+
+	disconnectInterface.WaitFor(disconnectPlug)
+
+	return state.NewTaskSet(disconnectSlot, disconnectPlug, disconnectInterface), nil
 }
 
 // CheckInterfaces checks whether plugs and slots of snap are allowed for installation.
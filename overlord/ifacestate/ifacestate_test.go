@@ -147,6 +147,7 @@ func (s *interfaceManagerSuite) TestKnownTaskKinds(c *C) {
 	kinds := mgr.KnownTaskKinds()
 	sort.Strings(kinds)
 	c.Assert(kinds, DeepEquals, []string{
+		"auto-disconnect",
 		"connect",
 		"discard-conns",
 		"disconnect",
@@ -481,14 +482,33 @@ slots:
 }
 
 func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
 	s.state.Lock()
 	defer s.state.Unlock()
 
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
 	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 3)
 
+	var hs hookstate.HookSetup
 	task := ts.Tasks()[0]
+	c.Check(task.Kind(), Equals, "run-hook")
+	c.Assert(task.Get("hook-setup", &hs), IsNil)
+	c.Check(hs, Equals, hookstate.HookSetup{Snap: "producer", Hook: "disconnect-slot-slot", Optional: true})
+
+	task = ts.Tasks()[1]
+	c.Check(task.Kind(), Equals, "run-hook")
+	c.Assert(task.Get("hook-setup", &hs), IsNil)
+	c.Check(hs, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "disconnect-plug-plug", Optional: true})
+	c.Check(task.WaitTasks(), DeepEquals, []*state.Task{ts.Tasks()[0]})
+
+	task = ts.Tasks()[2]
 	c.Assert(task.Kind(), Equals, "disconnect")
+	c.Check(task.WaitTasks(), DeepEquals, []*state.Task{ts.Tasks()[1]})
 	var plug interfaces.PlugRef
 	err = task.Get("plug", &plug)
 	c.Assert(err, IsNil)
@@ -499,6 +519,18 @@ func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(slot.Snap, Equals, "producer")
 	c.Assert(slot.Name, Equals, "slot")
+
+	// the hooks can read the attributes of the connection
+	var attrs map[string]interface{}
+	c.Assert(task.Get("plug-attrs", &attrs), IsNil)
+	c.Check(attrs["attr1"], Equals, "value1")
+	c.Assert(task.Get("slot-attrs", &attrs), IsNil)
+	c.Check(attrs["attr2"], Equals, "value2")
+	for _, hookTask := range ts.Tasks()[:2] {
+		var hookContext map[string]interface{}
+		c.Assert(hookTask.Get("hook-context", &hookContext), IsNil)
+		c.Check(hookContext["attrs-task"], Equals, task.ID())
+	}
 }
 
 // Disconnect works when both plug and slot are specified
@@ -537,15 +569,14 @@ func (s *interfaceManagerSuite) testDisconnect(c *C, plugSnap, plugName, slotSna
 	c.Assert(err, IsNil)
 	change.AddAll(ts)
 	s.state.Unlock()
-	mgr.Ensure()
-	mgr.Wait()
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
 
 	// Ensure that the task succeeded.
 	c.Assert(change.Err(), IsNil)
-	task := change.Tasks()[0]
+	task := change.Tasks()[2]
 	c.Check(task.Kind(), Equals, "disconnect")
 	c.Check(task.Status(), Equals, state.DoneStatus)
 
@@ -1627,8 +1658,7 @@ func (s *interfaceManagerSuite) TestDisconnectSetsUpSecurity(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
+	s.settle(c)
 	mgr.Stop()
 
 	s.state.Lock()
@@ -1671,8 +1701,7 @@ func (s *interfaceManagerSuite) TestDisconnectTracksConnectionsInState(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
+	s.settle(c)
 	mgr.Stop()
 
 	s.state.Lock()
@@ -2119,3 +2148,163 @@ func (s *interfaceManagerSuite) TestAutoConnectDuringCoreTransition(c *C) {
 	c.Assert(ifaces.Connections, HasLen, 1)
 	c.Check(ifaces.Connections, DeepEquals, []*interfaces.ConnRef{{interfaces.PlugRef{Snap: "snap", Name: "network"}, interfaces.SlotRef{Snap: "core", Name: "network"}}})
 }
+
+func (s *interfaceManagerSuite) TestUndoDisconnect(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("disconnect", "")
+	change.AddAll(ts)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(ts.Tasks()[2].Kind(), Equals, "disconnect")
+	c.Check(ts.Tasks()[2].Status(), Equals, state.UndoneStatus)
+
+	// the connection is back in place
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	c.Check(mgr.Repository().Interfaces().Connections, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestAutoDisconnect(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("remove", "")
+	autoDisconnect := s.state.NewTask("auto-disconnect", "")
+	autoDisconnect.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "producer",
+			Revision: snap.R(1),
+		},
+	})
+	change.AddTask(autoDisconnect)
+	// a dummy task to stand for the rest of the removal
+	dummy := s.state.NewTask("dummy", "")
+	dummy.WaitFor(autoDisconnect)
+	change.AddTask(dummy)
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(autoDisconnect.Status(), Equals, state.DoneStatus)
+	tasks := change.Tasks()
+	c.Assert(tasks, HasLen, 5)
+
+	var hs hookstate.HookSetup
+	c.Check(tasks[2].Kind(), Equals, "run-hook")
+	c.Assert(tasks[2].Get("hook-setup", &hs), IsNil)
+	c.Check(hs.Hook, Equals, "disconnect-slot-slot")
+	c.Check(tasks[2].WaitTasks(), DeepEquals, []*state.Task{autoDisconnect})
+	c.Check(tasks[3].Kind(), Equals, "run-hook")
+	c.Assert(tasks[3].Get("hook-setup", &hs), IsNil)
+	c.Check(hs.Hook, Equals, "disconnect-plug-plug")
+	disconnect := tasks[4]
+	c.Check(disconnect.Kind(), Equals, "disconnect")
+
+	// the rest of the removal waits for the disconnection
+	c.Check(dummy.WaitTasks(), DeepEquals, []*state.Task{autoDisconnect, tasks[2], tasks[3], disconnect})
+}
+
+func (s *interfaceManagerSuite) TestAutoDisconnectOnRefresh(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, `name: producer
+version: 1
+slots:
+ slot:
+  interface: test
+ slot2:
+  interface: test2
+`)
+	// the new revision drops one of the slots
+	snaptest.MockSnap(c, `name: producer
+version: 2
+slots:
+ slot2:
+  interface: test2
+`, &snap.SideInfo{Revision: snap.R(2)})
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot":       map[string]interface{}{"interface": "test"},
+		"consumer:otherplug producer:slot2": map[string]interface{}{"interface": "test2"},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("refresh", "")
+	autoDisconnect := s.state.NewTask("auto-disconnect", "")
+	autoDisconnect.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "producer",
+			Revision: snap.R(2),
+		},
+	})
+	change.AddTask(autoDisconnect)
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(autoDisconnect.Status(), Equals, state.DoneStatus)
+	tasks := change.Tasks()
+	c.Assert(tasks, HasLen, 4)
+
+	// only the connection of the dropped slot goes away, running
+	// the disconnect hooks of both sides
+	var hs hookstate.HookSetup
+	c.Assert(tasks[1].Get("hook-setup", &hs), IsNil)
+	c.Check(hs.Hook, Equals, "disconnect-slot-slot")
+	c.Assert(tasks[2].Get("hook-setup", &hs), IsNil)
+	c.Check(hs.Hook, Equals, "disconnect-plug-plug")
+	var slot interfaces.SlotRef
+	c.Assert(tasks[3].Get("slot", &slot), IsNil)
+	c.Check(slot, Equals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
+}
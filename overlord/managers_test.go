@@ -285,7 +285,7 @@ apps:
 `
 	snapInfo := ms.installLocalTestSnap(c, snapYamlContent+"version: 1.0")
 
-	ts, err := snapstate.Remove(st, "foo", snap.R(0))
+	ts, err := snapstate.Remove(st, "foo", snap.R(0), nil)
 	c.Assert(err, IsNil)
 	chg := st.NewChange("remove-snap", "...")
 	chg.AddAll(ts)
@@ -1022,7 +1022,7 @@ func (ms *mgrsSuite) installLocalTestSnap(c *C, snapYamlContent string) *snap.In
 func (ms *mgrsSuite) removeSnap(c *C, name string) {
 	st := ms.o.State()
 
-	ts, err := snapstate.Remove(st, name, snap.R(0))
+	ts, err := snapstate.Remove(st, name, snap.R(0), nil)
 	c.Assert(err, IsNil)
 	chg := st.NewChange("remove-snap", "...")
 	chg.AddAll(ts)
@@ -21,8 +21,9 @@
 package overlord
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"io/ioutil"
 	"path/filepath"
 	"sync"
 	"time"
@@ -37,10 +38,14 @@ import (
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/healthstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/queuestate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/store"
@@ -62,7 +67,8 @@ var (
 // Overlord is the central manager of a snappy system, keeping
 // track of all available state managers and related helpers.
 type Overlord struct {
-	stateEng *StateEngine
+	stateEng     *StateEngine
+	stateBackend *overlordStateBackend
 	// ensure loop
 	loopTomb    *tomb.Tomb
 	ensureLock  sync.Mutex
@@ -79,6 +85,9 @@ type Overlord struct {
 	hookMgr    *hookstate.HookManager
 	deviceMgr  *devicestate.DeviceManager
 	cmdMgr     *cmdstate.CommandManager
+	shotMgr    *snapshotstate.SnapshotManager
+	queueMgr   *queuestate.QueueManager
+	healthMgr  *healthstate.HealthManager
 	unknownMgr *UnknownTaskManager
 }
 
@@ -93,13 +102,16 @@ func New() (*Overlord, error) {
 
 	backend := &overlordStateBackend{
 		path:           dirs.SnapStateFile,
+		journalPath:    dirs.SnapStateJournalFile,
 		ensureBefore:   o.ensureBefore,
 		requestRestart: o.requestRestart,
+		archiveEnabled: o.changesArchiveEnabled,
 	}
 	s, err := loadState(backend)
 	if err != nil {
 		return nil, err
 	}
+	o.stateBackend = backend
 
 	o.stateEng = NewStateEngine(s)
 	o.unknownMgr = NewUnknownTaskManager(s)
@@ -136,6 +148,9 @@ func New() (*Overlord, error) {
 	o.addManager(deviceMgr)
 
 	o.addManager(cmdstate.Manager(s))
+	o.addManager(snapshotstate.Manager(s))
+	o.addManager(queuestate.Manager(s))
+	o.addManager(healthstate.Manager(s, hookMgr))
 
 	configstateInit(hookMgr)
 
@@ -169,12 +184,18 @@ func (o *Overlord) addManager(mgr StateManager) {
 		o.deviceMgr = x
 	case *cmdstate.CommandManager:
 		o.cmdMgr = x
+	case *snapshotstate.SnapshotManager:
+		o.shotMgr = x
+	case *queuestate.QueueManager:
+		o.queueMgr = x
+	case *healthstate.HealthManager:
+		o.healthMgr = x
 	}
 	o.stateEng.AddManager(mgr)
 	o.unknownMgr.Ignore(mgr.KnownTaskKinds())
 }
 
-func loadState(backend state.Backend) (*state.State, error) {
+func loadState(backend *overlordStateBackend) (*state.State, error) {
 	if !osutil.FileExists(dirs.SnapStateFile) {
 		// fail fast, mostly interesting for tests, this dir is setup
 		// by the snapd package
@@ -187,13 +208,16 @@ func loadState(backend state.Backend) (*state.State, error) {
 		return s, nil
 	}
 
-	r, err := os.Open(dirs.SnapStateFile)
+	checkpoint, err := ioutil.ReadFile(dirs.SnapStateFile)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read the state file: %s", err)
 	}
-	defer r.Close()
+	journal, err := backend.load(checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the state journal: %s", err)
+	}
 
-	s, err := state.ReadState(backend, r)
+	s, err := state.ReadStateWithJournal(backend, bytes.NewReader(checkpoint), bytes.NewReader(journal))
 	if err != nil {
 		return nil, err
 	}
@@ -250,6 +274,19 @@ func (o *Overlord) SetRestartHandler(handleRestart func(t state.RestartType)) {
 	o.restartHandler = handleRestart
 }
 
+// changesArchiveEnabled returns whether the core "changes.archive"
+// option asks for pruned changes to be archived. The state must be
+// locked.
+func (o *Overlord) changesArchiveEnabled() bool {
+	tr := config.NewTransaction(o.State())
+	var enabled bool
+	if err := tr.Get("core", "changes.archive", &enabled); err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot read changes.archive option: %v", err)
+		return false
+	}
+	return enabled
+}
+
 // Loop runs a loop in a goroutine to ensure the current state regularly through StateEngine Ensure.
 func (o *Overlord) Loop() {
 	o.ensureTimerSetup()
@@ -278,6 +315,15 @@ func (o *Overlord) Stop() error {
 	o.loopTomb.Kill(nil)
 	err1 := o.loopTomb.Wait()
 	o.stateEng.Stop()
+	if o.stateBackend != nil {
+		// fold the state journal into the state file
+		st := o.stateEng.State()
+		st.Lock()
+		if o.stateBackend.hasJournal() {
+			st.Compact()
+		}
+		st.Unlock()
+	}
 	return err1
 }
 
@@ -387,6 +433,23 @@ func (o *Overlord) CommandManager() *cmdstate.CommandManager {
 	return o.cmdMgr
 }
 
+// SnapshotManager returns the manager responsible for snapshots.
+func (o *Overlord) SnapshotManager() *snapshotstate.SnapshotManager {
+	return o.shotMgr
+}
+
+// QueueManager returns the manager responsible for operations queued
+// behind other changes.
+func (o *Overlord) QueueManager() *queuestate.QueueManager {
+	return o.queueMgr
+}
+
+// HealthManager returns the manager responsible for checking the
+// health of snaps.
+func (o *Overlord) HealthManager() *healthstate.HealthManager {
+	return o.healthMgr
+}
+
 // UnknownTaskManager returns the manager responsible for handling of
 // unknown tasks.
 func (o *Overlord) UnknownTaskManager() *UnknownTaskManager {
@@ -20,11 +20,15 @@
 package overlord_test
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -33,8 +37,11 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/history"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/patch"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -79,6 +86,9 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.HookManager(), NotNil)
 	c.Check(o.DeviceManager(), NotNil)
 	c.Check(o.CommandManager(), NotNil)
+	c.Check(o.QueueManager(), NotNil)
+	c.Check(o.HealthManager(), NotNil)
+	c.Check(o.SnapshotManager(), NotNil)
 	c.Check(configstateInitCalled, Equals, true)
 
 	s := o.State()
@@ -479,9 +489,253 @@ func (ovs *overlordSuite) TestCheckpoint(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(st.Mode(), Equals, os.FileMode(0600))
 
+	// the modification was journaled
+	st, err = os.Stat(dirs.SnapStateJournalFile)
+	c.Assert(err, IsNil)
+	c.Assert(st.Mode(), Equals, os.FileMode(0600))
+	c.Check(readFile(c, dirs.SnapStateJournalFile), testutil.Contains, `"mark":1`)
+
+	o.Loop()
+	err = o.Stop()
+	c.Assert(err, IsNil)
+
+	c.Check(readFile(c, dirs.SnapStateFile), testutil.Contains, `"mark":1`)
+}
+
+func (ovs *overlordSuite) TestPruneArchivesChangesWhenEnabled(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("foo", "...")
+	chg.SetStatus(state.DoneStatus)
+	st.Prune(0, time.Hour, 100)
+	c.Check(st.Change(chg.ID()), IsNil)
+
+	// not archived by default
+	chgs, err := history.Changes(time.Time{})
+	c.Assert(err, IsNil)
+	c.Check(chgs, HasLen, 0)
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "changes.archive", true), IsNil)
+	tr.Commit()
+
+	chg = st.NewChange("bar", "...")
+	chg.SetStatus(state.DoneStatus)
+	st.Prune(0, time.Hour, 100)
+
+	chgs, err = history.Changes(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Kind, Equals, "bar")
+}
+
+func readFile(c *C, path string) string {
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (ovs *overlordSuite) TestCheckpointJournal(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("big", strings.Repeat("x", 1000))
+	s.Set("mark", 1)
+	s.Unlock()
+
+	// the journal is now bigger than the checkpoint, this compacts it
+	s.Lock()
+	s.Set("mark", 2)
+	s.Unlock()
+
+	s.Lock()
+	s.Set("mark", 3)
+	s.Unlock()
+
+	// the state file holds just the checkpoint
+	content, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	var checkpoint map[string]interface{}
+	c.Assert(json.Unmarshal(content, &checkpoint), IsNil)
+	c.Check(checkpoint["data"].(map[string]interface{})["mark"], Equals, 2.0)
+
+	// the journal amends it
+	journal, err := ioutil.ReadFile(dirs.SnapStateJournalFile)
+	c.Assert(err, IsNil)
+	lines := strings.Split(string(journal), "\n")
+	c.Assert(lines, HasLen, 3)
+	sum := sha256.Sum256(content)
+	c.Check(lines[0], Equals, hex.EncodeToString(sum[:]))
+	c.Check(lines[1], Equals, `{"data":{"mark":3},"last-change-id":0,"last-task-id":0,"last-lane-id":0}`)
+	c.Check(lines[2], Equals, "")
+
+	// the journal is replayed on load and folded into the state file
+	o2, err := overlord.New()
+	c.Assert(err, IsNil)
+	s2 := o2.State()
+	s2.Lock()
+	defer s2.Unlock()
+	var mark int
+	c.Assert(s2.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 3)
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+	c.Check(readFile(c, dirs.SnapStateFile), testutil.Contains, `"mark":3`)
+}
+
+func (ovs *overlordSuite) TestCheckpointJournalCompaction(c *C) {
+	restore := overlord.MockMaxStateJournalEntries(2)
+	defer restore()
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("big", strings.Repeat("x", 1000))
+	s.Unlock()
+
+	// the journal is now bigger than the checkpoint, this compacts it
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+
+	for i := 2; i <= 3; i++ {
+		s.Lock()
+		s.Set("mark", i)
+		s.Unlock()
+	}
+	journal, err := ioutil.ReadFile(dirs.SnapStateJournalFile)
+	c.Assert(err, IsNil)
+	c.Check(strings.Count(string(journal), "\n"), Equals, 3)
+
+	// two entries got journaled, the state is checkpointed again
+	s.Lock()
+	s.Set("mark", 4)
+	s.Unlock()
+
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+	c.Check(readFile(c, dirs.SnapStateFile), testutil.Contains, `"mark":4`)
+}
+
+func (ovs *overlordSuite) TestStopFoldsJournal(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("big", strings.Repeat("x", 1000))
+	s.Unlock()
+
+	// the journal is now bigger than the checkpoint, this compacts it
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+
+	s.Lock()
+	s.Set("mark", 2)
+	s.Unlock()
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, true)
+
+	o.Loop()
+	err = o.Stop()
+	c.Assert(err, IsNil)
+
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+	content, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	var checkpoint map[string]interface{}
+	c.Assert(json.Unmarshal(content, &checkpoint), IsNil)
+	c.Check(checkpoint["data"].(map[string]interface{})["mark"], Equals, 2.0)
+}
+
+func (ovs *overlordSuite) TestLoadTornJournal(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("big", strings.Repeat("x", 1000))
+	s.Unlock()
+
+	for i := 1; i <= 2; i++ {
+		s.Lock()
+		s.Set("mark", i)
+		s.Unlock()
+	}
+
+	// a further entry was only partially written
+	f, err := os.OpenFile(dirs.SnapStateJournalFile, os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.WriteString(`{"data":{"mark":3},"last-ch`)
+	c.Assert(err, IsNil)
+	f.Close()
+
+	o2, err := overlord.New()
+	c.Assert(err, IsNil)
+	s2 := o2.State()
+	s2.Lock()
+	defer s2.Unlock()
+	var mark int
+	c.Assert(s2.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 2)
+	// the torn journal was folded into the state file
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+	c.Check(readFile(c, dirs.SnapStateFile), testutil.Contains, `"mark":2`)
+}
+
+func (ovs *overlordSuite) TestLoadIgnoresStaleJournal(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	s.Set("big", strings.Repeat("x", 1000))
+	s.Unlock()
+
+	// the journal is now bigger than the checkpoint, this compacts it
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+
+	s.Lock()
+	s.Set("mark", 2)
+	s.Unlock()
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, true)
+
+	// the state file is rewritten behind our back
 	content, err := ioutil.ReadFile(dirs.SnapStateFile)
 	c.Assert(err, IsNil)
-	c.Check(string(content), testutil.Contains, `"mark":1`)
+	content = bytes.Replace(content, []byte(`"mark":1`), []byte(`"mark":10`), 1)
+	err = ioutil.WriteFile(dirs.SnapStateFile, content, 0600)
+	c.Assert(err, IsNil)
+
+	o2, err := overlord.New()
+	c.Assert(err, IsNil)
+	s2 := o2.State()
+	s2.Lock()
+	var mark int
+	c.Assert(s2.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 10)
+
+	// the stale journal is replaced by a new one
+	s2.Set("mark", 11)
+	s2.Unlock()
+
+	o3, err := overlord.New()
+	c.Assert(err, IsNil)
+	s3 := o3.State()
+	s3.Lock()
+	defer s3.Unlock()
+	c.Assert(s3.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 11)
 }
 
 type runnerManager struct {
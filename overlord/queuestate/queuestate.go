@@ -0,0 +1,155 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package queuestate implements the manager and state aspects
+// responsible for operations that were queued behind other changes
+// instead of failing due to a conflict.
+package queuestate
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Op computes the task sets of a queued operation from the data it
+// was queued with. It is called with the state locked, once the
+// changes the operation was queued behind are ready.
+type Op func(st *state.State, data *json.RawMessage) ([]*state.TaskSet, error)
+
+var ops = make(map[string]Op)
+
+// RegisterOp registers the function computing the tasks of queued
+// operations of the given kind.
+func RegisterOp(kind string, op Op) {
+	if _, ok := ops[kind]; ok {
+		panic(fmt.Sprintf("internal error: queued operation %q registered twice", kind))
+	}
+	ops[kind] = op
+}
+
+// QueueManager runs operations that were queued behind other changes.
+type QueueManager struct {
+	runner *state.TaskRunner
+}
+
+// Manager returns a new QueueManager.
+func Manager(st *state.State) *QueueManager {
+	runner := state.NewTaskRunner(st)
+	runner.AddHandler("run-queued-op", doRunQueuedOp, nil)
+	return &QueueManager{runner: runner}
+}
+
+func (m *QueueManager) KnownTaskKinds() []string {
+	return m.runner.KnownTaskKinds()
+}
+
+// Ensure is part of the overlord.StateManager interface.
+func (m *QueueManager) Ensure() error {
+	m.runner.Ensure()
+	return nil
+}
+
+// Wait is part of the overlord.StateManager interface.
+func (m *QueueManager) Wait() {
+	m.runner.Wait()
+}
+
+// Stop is part of the overlord.StateManager interface.
+func (m *QueueManager) Stop() {
+	m.runner.Stop()
+}
+
+// conflictingChange returns the change that err reports a conflict
+// with, if it is known and still in progress.
+func conflictingChange(st *state.State, err error) *state.Change {
+	conflict, ok := err.(*snapstate.ChangeConflictError)
+	if !ok || conflict.ChangeID == "" {
+		return nil
+	}
+	chg := st.Change(conflict.ChangeID)
+	if chg == nil || chg.Status().Ready() {
+		return nil
+	}
+	return chg
+}
+
+// Queue returns a new change of the given kind that will perform the
+// operation of the given op kind once the change that conflict
+// reports is ready. If conflict does not report a conflict with a
+// change in progress, it is returned unchanged and no change is
+// created.
+func Queue(st *state.State, kind, summary, opKind string, opData interface{}, conflict error) (*state.Change, error) {
+	if _, ok := ops[opKind]; !ok {
+		return nil, fmt.Errorf("internal error: unknown queued operation %q", opKind)
+	}
+	other := conflictingChange(st, conflict)
+	if other == nil {
+		return nil, conflict
+	}
+
+	t := st.NewTask("run-queued-op", fmt.Sprintf("Wait for change %s to finish", other.ID()))
+	t.Set("op-kind", opKind)
+	t.Set("op-data", opData)
+
+	chg := st.NewChange(kind, summary)
+	chg.AddTask(t)
+	chg.WaitFor(other)
+	return chg, nil
+}
+
+func doRunQueuedOp(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var opKind string
+	var opData *json.RawMessage
+	if err := t.Get("op-kind", &opKind); err != nil {
+		return err
+	}
+	if err := t.Get("op-data", &opData); err != nil {
+		return err
+	}
+	op := ops[opKind]
+	if op == nil {
+		return fmt.Errorf("internal error: unknown queued operation %q", opKind)
+	}
+
+	chg := t.Change()
+	tss, err := op(st, opData)
+	if other := conflictingChange(st, err); other != nil && other != chg {
+		// yet another change got in the way, wait for it as well
+		t.Logf("Waiting for change %s to finish", other.ID())
+		chg.WaitFor(other)
+		return &state.Retry{}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package queuestate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/queuestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// hook up gocheck to testing
+func TestQueueState(t *testing.T) { TestingT(t) }
+
+type queueSuite struct {
+	state   *state.State
+	manager *queuestate.QueueManager
+
+	opCalls []string
+	opFunc  func() ([]*state.TaskSet, error)
+
+	restore func()
+}
+
+var _ = Suite(&queueSuite{})
+
+func (s *queueSuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+	s.manager = queuestate.Manager(s.state)
+	s.opCalls = nil
+	s.opFunc = nil
+	s.restore = queuestate.MockOp("test-op", func(st *state.State, data *json.RawMessage) ([]*state.TaskSet, error) {
+		var what string
+		c.Assert(json.Unmarshal(*data, &what), IsNil)
+		s.opCalls = append(s.opCalls, what)
+		if s.opFunc != nil {
+			return s.opFunc()
+		}
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("nop", "..."))}, nil
+	})
+}
+
+func (s *queueSuite) TearDownTest(c *C) {
+	s.manager.Stop()
+	s.restore()
+}
+
+func (s *queueSuite) settle() {
+	s.state.Unlock()
+	defer s.state.Lock()
+	for i := 0; i < 5; i++ {
+		s.manager.Ensure()
+		s.manager.Wait()
+	}
+}
+
+// inProgress returns a change in progress and a conflict error for it.
+func (s *queueSuite) inProgress(kind string) (*state.Change, error) {
+	chg := s.state.NewChange(kind, "...")
+	chg.AddTask(s.state.NewTask("busy", "..."))
+	return chg, &snapstate.ChangeConflictError{
+		Snap:       "some-snap",
+		ChangeKind: kind,
+		ChangeID:   chg.ID(),
+	}
+}
+
+func (s *queueSuite) TestKnownTaskKinds(c *C) {
+	c.Check(s.manager.KnownTaskKinds(), DeepEquals, []string{"run-queued-op"})
+}
+
+func (s *queueSuite) TestQueue(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	other, conflict := s.inProgress("install-snap")
+
+	chg, err := queuestate.Queue(s.state, "remove-snap", "Remove snap", "test-op", "remove", conflict)
+	c.Assert(err, IsNil)
+	c.Check(chg.Kind(), Equals, "remove-snap")
+	c.Check(chg.Summary(), Equals, "Remove snap")
+	c.Check(chg.WaitChanges(), DeepEquals, []*state.Change{other})
+	c.Assert(chg.Tasks(), HasLen, 1)
+	c.Check(chg.Tasks()[0].Kind(), Equals, "run-queued-op")
+
+	// nothing happens while the other change is in progress
+	s.settle()
+	c.Check(s.opCalls, HasLen, 0)
+	c.Check(chg.Status(), Equals, state.DoStatus)
+
+	other.Tasks()[0].SetStatus(state.ErrorStatus)
+	s.settle()
+	c.Check(s.opCalls, DeepEquals, []string{"remove"})
+	c.Check(chg.Tasks()[0].Status(), Equals, state.DoneStatus)
+	c.Assert(chg.Tasks(), HasLen, 2)
+	c.Check(chg.Tasks()[1].Kind(), Equals, "nop")
+}
+
+func (s *queueSuite) TestQueueWaitsForNewConflicts(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	first, conflict := s.inProgress("install-snap")
+	chg, err := queuestate.Queue(s.state, "remove-snap", "Remove snap", "test-op", "remove", conflict)
+	c.Assert(err, IsNil)
+
+	second, conflict := s.inProgress("refresh-snap")
+	s.opFunc = func() ([]*state.TaskSet, error) {
+		s.opFunc = nil
+		return nil, conflict
+	}
+
+	first.Tasks()[0].SetStatus(state.DoneStatus)
+	s.settle()
+	c.Check(s.opCalls, DeepEquals, []string{"remove"})
+	c.Check(chg.WaitChanges(), DeepEquals, []*state.Change{first, second})
+	c.Check(chg.Status(), Equals, state.DoingStatus)
+	c.Check(strings.Join(chg.Tasks()[0].Log(), ""), Matches, ".*Waiting for change "+second.ID()+" to finish")
+
+	second.Tasks()[0].SetStatus(state.DoneStatus)
+	s.settle()
+	c.Check(s.opCalls, DeepEquals, []string{"remove", "remove"})
+	c.Check(chg.Tasks(), HasLen, 2)
+}
+
+func (s *queueSuite) TestQueueOpError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	other, conflict := s.inProgress("install-snap")
+	chg, err := queuestate.Queue(s.state, "remove-snap", "Remove snap", "test-op", "remove", conflict)
+	c.Assert(err, IsNil)
+
+	s.opFunc = func() ([]*state.TaskSet, error) {
+		return nil, errors.New("snap is not installed")
+	}
+
+	other.Tasks()[0].SetStatus(state.DoneStatus)
+	s.settle()
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*snap is not installed.*`)
+}
+
+func (s *queueSuite) TestQueueNotAConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := queuestate.Queue(s.state, "remove-snap", "...", "test-op", "remove", errors.New("boom"))
+	c.Check(err, ErrorMatches, "boom")
+
+	// a conflict with a change that is gone or done cannot be queued
+	conflict := &snapstate.ChangeConflictError{Snap: "some-snap", ChangeID: "42"}
+	_, err = queuestate.Queue(s.state, "remove-snap", "...", "test-op", "remove", conflict)
+	c.Check(err, Equals, conflict)
+
+	other, conflict2 := s.inProgress("install-snap")
+	other.SetStatus(state.DoneStatus)
+	_, err = queuestate.Queue(s.state, "remove-snap", "...", "test-op", "remove", conflict2)
+	c.Check(err, Equals, conflict2)
+
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *queueSuite) TestQueueUnknownOp(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, conflict := s.inProgress("install-snap")
+	_, err := queuestate.Queue(s.state, "remove-snap", "...", "unknown-op", nil, conflict)
+	c.Check(err, ErrorMatches, `internal error: unknown queued operation "unknown-op"`)
+}
+
+func (s *queueSuite) TestRegisterOpTwice(c *C) {
+	c.Check(func() { queuestate.RegisterOp("test-op", nil) }, PanicMatches, `internal error: queued operation "test-op" registered twice`)
+}
@@ -0,0 +1,266 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+func init() {
+	snapstate.QuotaGroupSlice = quotaGroupSlice
+	snapstate.RemoveSnapFromQuotaGroup = removeSnapFromQuotaGroup
+}
+
+// AllQuotas returns all the quota groups, keyed by name, with their
+// cross references resolved.
+func AllQuotas(st *state.State) (map[string]*quota.Group, error) {
+	var quotas map[string]*quota.Group
+	if err := st.Get("quotas", &quotas); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if quotas == nil {
+		quotas = make(map[string]*quota.Group)
+	}
+	if err := quota.ResolveCrossReferences(quotas); err != nil {
+		return nil, fmt.Errorf("cannot resolve quota groups: %v", err)
+	}
+	return quotas, nil
+}
+
+// QuotaLimits are the limits of a quota group; zero values mean no
+// change when updating a group.
+type QuotaLimits struct {
+	MemoryLimit int64
+	CPUQuota    int
+}
+
+// EnsureQuota creates the quota group with the given parent group (if
+// any), limits and snaps, or, if it exists already, adds the snaps to
+// it and updates its non-zero limits. The slices of the quota groups
+// and the service units of the affected snaps are updated; the
+// services move to the slice the next time they are started.
+func EnsureQuota(st *state.State, name, parentName string, snapNames []string, limits QuotaLimits) error {
+	if err := quota.ValidateGroupName(name); err != nil {
+		return err
+	}
+	quotas, err := AllQuotas(st)
+	if err != nil {
+		return err
+	}
+
+	grp := quotas[name]
+	if grp == nil {
+		if parentName != "" {
+			parent := quotas[parentName]
+			if parent == nil {
+				return fmt.Errorf("cannot find parent quota group %q", parentName)
+			}
+			parent.SubGroups = append(parent.SubGroups, name)
+		}
+		grp = &quota.Group{Name: name, ParentGroup: parentName}
+		quotas[name] = grp
+	} else if parentName != "" && parentName != grp.ParentGroup {
+		return fmt.Errorf("cannot change the parent group of quota group %q", name)
+	}
+	if limits.MemoryLimit != 0 {
+		grp.MemoryLimit = limits.MemoryLimit
+	}
+	if limits.CPUQuota != 0 {
+		grp.CPUQuota = limits.CPUQuota
+	}
+
+	for _, snapName := range snapNames {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, snapName, &snapst); err == state.ErrNoState {
+			return fmt.Errorf("cannot add snap %q to quota group %q: snap not installed", snapName, name)
+		} else if err != nil {
+			return err
+		}
+		if !strutil.ListContains(grp.Snaps, snapName) {
+			grp.Snaps = append(grp.Snaps, snapName)
+		}
+	}
+	if err := snapstate.CheckChangeConflictMany(st, snapNames, nil); err != nil {
+		return err
+	}
+
+	if err := quota.ResolveCrossReferences(quotas); err != nil {
+		return err
+	}
+
+	return updateQuotas(st, quotas, snapNames)
+}
+
+// RemoveQuota removes the quota group, which cannot have sub-groups.
+// The snaps of the group are no longer limited by it the next time
+// their services are started.
+func RemoveQuota(st *state.State, name string) error {
+	quotas, err := AllQuotas(st)
+	if err != nil {
+		return err
+	}
+	grp := quotas[name]
+	if grp == nil {
+		return &QuotaGroupNotFoundError{Name: name}
+	}
+	if len(grp.SubGroups) > 0 {
+		return fmt.Errorf("cannot remove quota group %q with sub-groups %s", name, strutil.Quoted(grp.SubGroups))
+	}
+	if err := snapstate.CheckChangeConflictMany(st, grp.Snaps, nil); err != nil {
+		return err
+	}
+
+	if parent := grp.Parent(); parent != nil {
+		subGroups := make([]string, 0, len(parent.SubGroups))
+		for _, sub := range parent.SubGroups {
+			if sub != name {
+				subGroups = append(subGroups, sub)
+			}
+		}
+		parent.SubGroups = subGroups
+	}
+	delete(quotas, name)
+	if err := quota.ResolveCrossReferences(quotas); err != nil {
+		return err
+	}
+
+	return updateQuotas(st, quotas, grp.Snaps)
+}
+
+// QuotaGroupNotFoundError is returned when a quota group does not exist.
+type QuotaGroupNotFoundError struct {
+	Name string
+}
+
+func (e *QuotaGroupNotFoundError) Error() string {
+	return fmt.Sprintf("cannot find quota group %q", e.Name)
+}
+
+// QuotaUnitsError is returned when the slices of the quota groups or
+// the service units of their snaps cannot be updated.
+type QuotaUnitsError struct {
+	Err error
+}
+
+func (e *QuotaUnitsError) Error() string {
+	return fmt.Sprintf("cannot update quota group units: %v", e.Err)
+}
+
+// updateQuotas writes the units for the new quota groups and only then
+// saves the groups in the state, so that the state never describes
+// units that were not written. On failure the units of the current
+// groups are restored.
+func updateQuotas(st *state.State, quotas map[string]*quota.Group, snapNames []string) error {
+	if err := ensureQuotaUnits(st, quotas, snapNames); err != nil {
+		if current, rerr := AllQuotas(st); rerr != nil {
+			logger.Noticef("cannot restore quota group units: %v", rerr)
+		} else if rerr := ensureQuotaUnits(st, current, snapNames); rerr != nil {
+			logger.Noticef("cannot restore quota group units: %v", rerr)
+		}
+		return &QuotaUnitsError{Err: err}
+	}
+	st.Set("quotas", quotas)
+	return nil
+}
+
+// ensureQuotaUnits writes the slices of the given quota groups and the
+// service units of the given snaps, placing them in the slices of the
+// given groups rather than of the ones saved in the state.
+func ensureQuotaUnits(st *state.State, quotas map[string]*quota.Group, snapNames []string) error {
+	names := make([]string, 0, len(quotas))
+	for name := range quotas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	groups := make([]*quota.Group, len(names))
+	for i, name := range names {
+		groups[i] = quotas[name]
+	}
+	if err := wrappers.EnsureQuotaSlices(groups, progress.Null); err != nil {
+		return err
+	}
+
+	for _, snapName := range snapNames {
+		info, err := snapstate.CurrentInfo(st, snapName)
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := snapstate.ApplyResourceOverrides(st, info); err != nil {
+			return err
+		}
+		info.QuotaSlice = sliceOfSnap(quotas, snapName)
+		if err := wrappers.UpdateSnapServiceUnits(info, progress.Null); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sliceOfSnap(quotas map[string]*quota.Group, snapName string) string {
+	for _, grp := range quotas {
+		if strutil.ListContains(grp.Snaps, snapName) {
+			return grp.SliceName()
+		}
+	}
+	return ""
+}
+
+func quotaGroupSlice(st *state.State, snapName string) (string, error) {
+	quotas, err := AllQuotas(st)
+	if err != nil {
+		return "", err
+	}
+	return sliceOfSnap(quotas, snapName), nil
+}
+
+func removeSnapFromQuotaGroup(st *state.State, snapName string) error {
+	quotas, err := AllQuotas(st)
+	if err != nil {
+		return err
+	}
+	for _, grp := range quotas {
+		if !strutil.ListContains(grp.Snaps, snapName) {
+			continue
+		}
+		snaps := make([]string, 0, len(grp.Snaps))
+		for _, name := range grp.Snaps {
+			if name != snapName {
+				snaps = append(snaps, name)
+			}
+		}
+		grp.Snaps = snaps
+		st.Set("quotas", quotas)
+		break
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type quotaControlSuite struct {
+	testutil.BaseTest
+	state   *state.State
+	sysdLog [][]string
+}
+
+var _ = Suite(&quotaControlSuite{})
+
+func (s *quotaControlSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.sysdLog = nil
+	s.AddCleanup(systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}))
+
+	s.state = state.New(nil)
+}
+
+func (s *quotaControlSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *quotaControlSuite) installSnap(c *C, name string) {
+	si := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+	info := snaptest.MockSnap(c, `name: `+name+`
+version: 1
+apps:
+  svc:
+    command: bin/svc
+    daemon: simple
+`, si)
+	c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+	snapstate.Set(s.state, name, &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+}
+
+func (s *quotaControlSuite) serviceFile(c *C, name string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dirs.SnapServicesDir, "snap."+name+".svc.service"))
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *quotaControlSuite) TestEnsureQuotaCreatesGroups(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installSnap(c, "foo")
+	s.installSnap(c, "bar")
+
+	err := servicestate.EnsureQuota(s.state, "telemetry", "", []string{"foo"}, servicestate.QuotaLimits{MemoryLimit: 1 << 30})
+	c.Assert(err, IsNil)
+	err = servicestate.EnsureQuota(s.state, "sub", "telemetry", []string{"bar"}, servicestate.QuotaLimits{CPUQuota: 50})
+	c.Assert(err, IsNil)
+
+	quotas, err := servicestate.AllQuotas(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(quotas, HasLen, 2)
+	c.Check(quotas["telemetry"].SubGroups, DeepEquals, []string{"sub"})
+	c.Check(quotas["telemetry"].Snaps, DeepEquals, []string{"foo"})
+	c.Check(quotas["sub"].Parent(), Equals, quotas["telemetry"])
+	c.Check(quotas["sub"].CPUQuota, Equals, 50)
+
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "snap.telemetry.slice")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "snap.telemetry-sub.slice")), Equals, true)
+	c.Check(s.serviceFile(c, "foo"), Matches, "(?ms).*^Slice=snap.telemetry.slice$.*")
+	c.Check(s.serviceFile(c, "bar"), Matches, "(?ms).*^Slice=snap.telemetry-sub.slice$.*")
+
+	// the snap is placed in the slice when linked again
+	info, err := snapstate.CurrentInfo(s.state, "bar")
+	c.Assert(err, IsNil)
+	c.Assert(snapstate.ApplyResourceOverrides(s.state, info), IsNil)
+	c.Check(info.QuotaSlice, Equals, "snap.telemetry-sub.slice")
+}
+
+func (s *quotaControlSuite) TestEnsureQuotaUpdatesGroup(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installSnap(c, "foo")
+	s.installSnap(c, "bar")
+
+	err := servicestate.EnsureQuota(s.state, "telemetry", "", []string{"foo"}, servicestate.QuotaLimits{MemoryLimit: 1 << 30})
+	c.Assert(err, IsNil)
+	err = servicestate.EnsureQuota(s.state, "telemetry", "", []string{"bar", "foo"}, servicestate.QuotaLimits{CPUQuota: 150})
+	c.Assert(err, IsNil)
+
+	quotas, err := servicestate.AllQuotas(s.state)
+	c.Assert(err, IsNil)
+	grp := quotas["telemetry"]
+	c.Check(grp.Snaps, DeepEquals, []string{"foo", "bar"})
+	c.Check(grp.MemoryLimit, Equals, int64(1<<30))
+	c.Check(grp.CPUQuota, Equals, 150)
+	c.Check(s.serviceFile(c, "bar"), Matches, "(?ms).*^Slice=snap.telemetry.slice$.*")
+}
+
+func (s *quotaControlSuite) TestEnsureQuotaErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installSnap(c, "foo")
+
+	err := servicestate.EnsureQuota(s.state, "top", "", []string{"foo"}, servicestate.QuotaLimits{MemoryLimit: 1 << 30})
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		name, parent string
+		snaps        []string
+		limits       servicestate.QuotaLimits
+		err          string
+	}{
+		{"Top", "", nil, servicestate.QuotaLimits{CPUQuota: 1}, `invalid quota group name "Top"`},
+		{"other", "", nil, servicestate.QuotaLimits{}, `quota group "other" must have a memory limit or a CPU quota`},
+		{"other", "missing", nil, servicestate.QuotaLimits{CPUQuota: 1}, `cannot find parent quota group "missing"`},
+		{"other", "", []string{"baz"}, servicestate.QuotaLimits{CPUQuota: 1}, `cannot add snap "baz" to quota group "other": snap not installed`},
+		{"other", "", []string{"foo"}, servicestate.QuotaLimits{CPUQuota: 1}, `snap "foo" cannot be in both quota groups "other" and "top"`},
+		{"sub", "top", nil, servicestate.QuotaLimits{MemoryLimit: 2 << 30}, `memory limit of quota group "sub" is larger than the limit of its parent group "top"`},
+	} {
+		err := servicestate.EnsureQuota(s.state, t.name, t.parent, t.snaps, t.limits)
+		c.Check(err, ErrorMatches, t.err)
+	}
+
+	err = servicestate.EnsureQuota(s.state, "sub", "top", nil, servicestate.QuotaLimits{MemoryLimit: 1 << 20})
+	c.Assert(err, IsNil)
+	err = servicestate.EnsureQuota(s.state, "sub", "other", nil, servicestate.QuotaLimits{})
+	c.Check(err, ErrorMatches, `cannot change the parent group of quota group "sub"`)
+
+	// nothing was stored by the failed attempts
+	quotas, err := servicestate.AllQuotas(s.state)
+	c.Assert(err, IsNil)
+	c.Check(quotas, HasLen, 2)
+}
+
+func (s *quotaControlSuite) TestEnsureQuotaUnitsError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installSnap(c, "foo")
+
+	restore := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		if cmd[0] == "daemon-reload" {
+			return nil, errors.New("boom")
+		}
+		return []byte("ActiveState=inactive\n"), nil
+	})
+	defer restore()
+
+	err := servicestate.EnsureQuota(s.state, "top", "", []string{"foo"}, servicestate.QuotaLimits{CPUQuota: 10})
+	c.Assert(err, FitsTypeOf, &servicestate.QuotaUnitsError{})
+	c.Check(err, ErrorMatches, `cannot update quota group units: .*boom.*`)
+
+	// the group was not saved and its slice was removed again
+	quotas, err := servicestate.AllQuotas(s.state)
+	c.Assert(err, IsNil)
+	c.Check(quotas, HasLen, 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "snap.top.slice")), Equals, false)
+	c.Check(s.serviceFile(c, "foo"), Not(Matches), "(?ms).*^Slice=.*")
+}
+
+func (s *quotaControlSuite) TestRemoveQuota(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installSnap(c, "foo")
+
+	err := servicestate.EnsureQuota(s.state, "top", "", nil, servicestate.QuotaLimits{MemoryLimit: 1 << 30})
+	c.Assert(err, IsNil)
+	err = servicestate.EnsureQuota(s.state, "sub", "top", []string{"foo"}, servicestate.QuotaLimits{MemoryLimit: 1 << 20})
+	c.Assert(err, IsNil)
+
+	err = servicestate.RemoveQuota(s.state, "top")
+	c.Check(err, ErrorMatches, `cannot remove quota group "top" with sub-groups "sub"`)
+	err = servicestate.RemoveQuota(s.state, "missing")
+	c.Check(err, ErrorMatches, `cannot find quota group "missing"`)
+	c.Check(err, FitsTypeOf, &servicestate.QuotaGroupNotFoundError{})
+
+	err = servicestate.RemoveQuota(s.state, "sub")
+	c.Assert(err, IsNil)
+	quotas, err := servicestate.AllQuotas(s.state)
+	c.Assert(err, IsNil)
+	c.Check(quotas, HasLen, 1)
+	c.Check(quotas["top"].SubGroups, HasLen, 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "snap.top-sub.slice")), Equals, false)
+	c.Check(s.serviceFile(c, "foo"), Not(Matches), "(?ms).*^Slice=.*")
+
+	err = servicestate.RemoveQuota(s.state, "top")
+	c.Assert(err, IsNil)
+	quotas, err = servicestate.AllQuotas(s.state)
+	c.Assert(err, IsNil)
+	c.Check(quotas, HasLen, 0)
+}
+
+func (s *quotaControlSuite) TestRemoveSnapFromQuotaGroup(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installSnap(c, "foo")
+	s.installSnap(c, "bar")
+
+	err := servicestate.EnsureQuota(s.state, "top", "", []string{"foo", "bar"}, servicestate.QuotaLimits{CPUQuota: 10})
+	c.Assert(err, IsNil)
+
+	c.Assert(snapstate.RemoveSnapFromQuotaGroup(s.state, "foo"), IsNil)
+	quotas, err := servicestate.AllQuotas(s.state)
+	c.Assert(err, IsNil)
+	c.Check(quotas["top"].Snaps, DeepEquals, []string{"bar"})
+}
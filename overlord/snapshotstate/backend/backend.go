@@ -0,0 +1,365 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package backend implements the low-level primitives to manage the
+// snapshots of snap data: saving it into compressed, checksummed
+// archives, listing them, checking them, and restoring them.
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	_ "golang.org/x/crypto/sha3"
+	"golang.org/x/net/context"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+const (
+	archiveName  = "archive.tgz"
+	metadataName = "meta.json"
+	metaHashName = "meta.sha3_384"
+
+	userArchivePrefix = "user/"
+	userArchiveSuffix = ".tgz"
+)
+
+var (
+	osOpen      = os.Open
+	dirNames    = (*os.File).Readdirnames
+	backendOpen = Open
+)
+
+// Iter loops over all snapshots in the snapshots directory, applying the
+// given function to each. The snapshot will be closed after the function
+// returns. If the function returns an error, iteration is stopped (and if
+// the error isn't Stop, it's returned as the error of the iterator).
+func Iter(ctx context.Context, f func(*Reader) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dir, err := osOpen(dirs.SnapshotsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// no dir -> no snapshots
+			return nil
+		}
+		return fmt.Errorf("cannot open snapshots directory: %v", err)
+	}
+	defer dir.Close()
+
+	var names []string
+	var readErr error
+	for readErr == nil && err == nil {
+		names, readErr = dirNames(dir, 100)
+		// note os.Readdirnames can return a non-empty names and a non-nil err
+		for _, name := range names {
+			if err = ctx.Err(); err != nil {
+				break
+			}
+
+			filename := filepath.Join(dirs.SnapshotsDir, name)
+			reader, openError := backendOpen(filename)
+			// reader can be non-nil even when openError is not nil (in
+			// which case reader.Broken will have a reason). f can
+			// check and either ignore or return an error when
+			// finding a broken snapshot.
+			if reader != nil {
+				err = f(reader)
+			} else {
+				// TODO: use warnings instead
+				logger.Noticef("Cannot open snapshot %q: %v.", name, openError)
+			}
+			if openError == nil {
+				// if openError was nil the snapshot was opened and needs closing
+				if closeError := reader.Close(); err == nil {
+					err = closeError
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	if readErr != nil && readErr != io.EOF {
+		return readErr
+	}
+
+	if err == Stop {
+		err = nil
+	}
+
+	return err
+}
+
+// List valid snapshots sets.
+func List(ctx context.Context, setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+	setshots := map[uint64][]*client.Snapshot{}
+	err := Iter(ctx, func(reader *Reader) error {
+		if setID == 0 || reader.SetID == setID {
+			if len(snapNames) == 0 || strutil.ListContains(snapNames, reader.Snap) {
+				setshots[reader.SetID] = append(setshots[reader.SetID], &reader.Snapshot)
+			}
+		}
+		return nil
+	})
+
+	sets := make([]client.SnapshotSet, 0, len(setshots))
+	for id, shots := range setshots {
+		sort.Sort(bySnap(shots))
+		sets = append(sets, client.SnapshotSet{ID: id, Snapshots: shots})
+	}
+
+	sort.Sort(byID(sets))
+
+	return sets, err
+}
+
+// Filename of the given client.Snapshot in this backend.
+func Filename(snapshot *client.Snapshot) string {
+	// this _needs_ the snap name and version to be valid
+	return filepath.Join(dirs.SnapshotsDir, fmt.Sprintf("%d_%s_%s_%s.zip", snapshot.SetID, snapshot.Snap, snapshot.Version, snapshot.Revision))
+}
+
+// LastSnapshotSetID returns the highest set id number for the snapshots stored
+// in snapshots directory; set ids are inferred from the filenames.
+func LastSnapshotSetID() (uint64, error) {
+	dir, err := osOpen(dirs.SnapshotsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// no dir -> no snapshots
+			return 0, nil
+		}
+		return 0, fmt.Errorf("cannot open snapshots directory: %v", err)
+	}
+	defer dir.Close()
+
+	var maxSetID uint64
+
+	var readErr error
+	for readErr == nil {
+		var names []string
+		// note os.Readdirnames can return a non-empty names and a non-nil err
+		names, readErr = dirNames(dir, 100)
+		for _, name := range names {
+			if ok, setID := isSnapshotFilename(name); ok {
+				if setID > maxSetID {
+					maxSetID = setID
+				}
+			}
+		}
+	}
+	if readErr != nil && readErr != io.EOF {
+		return 0, readErr
+	}
+	return maxSetID, nil
+}
+
+// Flags encompasses extra flags for snapshots backend Save.
+type Flags struct {
+	// Auto marks the snapshot as taken automatically, e.g. on
+	// snap removal, so that it is subject to expiration.
+	Auto bool
+}
+
+// Save a snapshot
+func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *Flags) (*client.Snapshot, error) {
+	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
+		return nil, err
+	}
+
+	snapshot := &client.Snapshot{
+		SetID:    id,
+		Snap:     si.Name(),
+		SnapID:   si.SnapID,
+		Revision: si.Revision,
+		Version:  si.Version,
+		Epoch:    si.Epoch,
+		Summary:  si.Summary(),
+		Time:     time.Now(),
+		SHA3_384: make(map[string]string),
+		Conf:     cfg,
+	}
+	if flags != nil {
+		snapshot.Auto = flags.Auto
+	}
+
+	aw, err := osutil.NewAtomicFile(Filename(snapshot), 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return nil, err
+	}
+	// if things worked, we'll commit (and Cancel becomes a NOP)
+	defer aw.Cancel()
+
+	w := zip.NewWriter(aw)
+	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
+	if err := addDirToZip(ctx, snapshot, w, archiveName, si.DataDir()); err != nil {
+		return nil, err
+	}
+
+	users, err := usersForUsernames(usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, usr := range users {
+		if err := addDirToZip(ctx, snapshot, w, userArchiveName(usr), si.UserDataDir(usr.HomeDir)); err != nil {
+			return nil, err
+		}
+	}
+
+	metaWriter, err := w.Create(metadataName)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := crypto.SHA3_384.New()
+	enc := json.NewEncoder(io.MultiWriter(metaWriter, hasher))
+	if err := enc.Encode(snapshot); err != nil {
+		return nil, err
+	}
+
+	hashWriter, err := w.Create(metaHashName)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(hashWriter, "%x\n", hasher.Sum(nil))
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := aw.Commit(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, entry, dir string) error {
+	parent, revdir := filepath.Split(dir)
+	exists, isDir, err := osutil.DirExists(parent)
+	if err != nil {
+		return err
+	}
+	if exists && !isDir {
+		logger.Noticef("Not saving directories under %q in snapshot #%d of %q as it is not a directory.", parent, snapshot.SetID, snapshot.Snap)
+		return nil
+	}
+	if !exists {
+		logger.Debugf("Not saving directories under %q in snapshot #%d of %q as it is does not exist.", parent, snapshot.SetID, snapshot.Snap)
+		return nil
+	}
+	tarArgs := []string{
+		"--create",
+		"--sparse", "--gzip",
+		"--directory", parent,
+	}
+
+	noRev, noCommon := true, true
+
+	exists, isDir, err = osutil.DirExists(dir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case exists && isDir:
+		tarArgs = append(tarArgs, revdir)
+		noRev = false
+	case exists && !isDir:
+		logger.Noticef("Not saving %q in snapshot #%d of %q as it is not a directory.", dir, snapshot.SetID, snapshot.Snap)
+	case !exists:
+		logger.Debugf("Not saving %q in snapshot #%d of %q as it is does not exist.", dir, snapshot.SetID, snapshot.Snap)
+	}
+
+	common := filepath.Join(parent, "common")
+	exists, isDir, err = osutil.DirExists(common)
+	if err != nil {
+		return err
+	}
+	switch {
+	case exists && isDir:
+		tarArgs = append(tarArgs, "common")
+		noCommon = false
+	case exists && !isDir:
+		logger.Noticef("Not saving %q in snapshot #%d of %q as it is not a directory.", common, snapshot.SetID, snapshot.Snap)
+	case !exists:
+		logger.Debugf("Not saving %q in snapshot #%d of %q as it is does not exist.", common, snapshot.SetID, snapshot.Snap)
+	}
+
+	if noCommon && noRev {
+		return nil
+	}
+
+	// the archive is already compressed, so just store it
+	archiveWriter, err := w.CreateHeader(&zip.FileHeader{
+		Name:   entry,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+
+	var sz sizer
+	hasher := crypto.SHA3_384.New()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "tar", tarArgs...)
+	cmd.Env = []string{"LANG=C"}
+	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return tarError("create", err, stderr.Bytes())
+	}
+
+	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += sz.size
+
+	return nil
+}
+
+type sizer struct {
+	size int64
+}
+
+func (sz *sizer) Write(data []byte) (n int, err error) {
+	n = len(data)
+	sz.size += int64(n)
+	return
+}
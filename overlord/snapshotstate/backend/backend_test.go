@@ -0,0 +1,391 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type snapshotSuite struct {
+	root    string
+	home    string
+	restore []func()
+}
+
+var _ = Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	dirs.SetRootDir(s.root)
+
+	s.home = filepath.Join(s.root, "home", "snapuser")
+	c.Assert(os.MkdirAll(s.home, 0755), IsNil)
+
+	cur, err := user.Current()
+	c.Assert(err, IsNil)
+
+	snapuser := &user.User{
+		Uid:      cur.Uid,
+		Gid:      cur.Gid,
+		Username: "snapuser",
+		HomeDir:  s.home,
+	}
+	root := &user.User{
+		Uid:      "0",
+		Gid:      "0",
+		Username: "root",
+		HomeDir:  filepath.Join(s.root, "root"),
+	}
+
+	s.restore = []func(){
+		backend.MockUserLookup(func(username string) (*user.User, error) {
+			switch username {
+			case "snapuser":
+				return snapuser, nil
+			case "root":
+				return root, nil
+			}
+			return nil, user.UnknownUserError(username)
+		}),
+		backend.MockUserLookupId(func(uid string) (*user.User, error) {
+			if uid == "0" {
+				return root, nil
+			}
+			if uid == cur.Uid {
+				return snapuser, nil
+			}
+			return nil, user.UnknownUserIdError(42)
+		}),
+	}
+}
+
+func (s *snapshotSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	for _, restore := range s.restore {
+		restore()
+	}
+}
+
+func (s *snapshotSuite) mkSnapData(c *C, info *snap.Info, what string) {
+	for _, dir := range []string{
+		info.DataDir(),
+		info.CommonDataDir(),
+		info.UserDataDir(s.home),
+		info.UserCommonDataDir(s.home),
+	} {
+		c.Assert(os.MkdirAll(dir, 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, "canary.txt"), []byte(what), 0644), IsNil)
+	}
+}
+
+func (s *snapshotSuite) checkSnapData(c *C, info *snap.Info, what string) {
+	for _, dir := range []string{
+		info.DataDir(),
+		info.CommonDataDir(),
+		info.UserDataDir(s.home),
+		info.UserCommonDataDir(s.home),
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "canary.txt"))
+		c.Assert(err, IsNil, Commentf("%s", dir))
+		c.Check(string(content), Equals, what, Commentf("%s", dir))
+	}
+}
+
+func mkInfo(name string, rev int) *snap.Info {
+	return &snap.Info{
+		SideInfo: snap.SideInfo{RealName: name, Revision: snap.R(rev)},
+		Version:  "v1.33",
+	}
+}
+
+func (s *snapshotSuite) TestIsSnapshotFilename(c *C) {
+	tests := []struct {
+		name  string
+		valid bool
+		setID uint64
+	}{
+		{"1_foo.zip", true, 1},
+		{"14_hello-world_6.4_29.zip", true, 14},
+		{"1_.zip", true, 1},
+		{"1_foo.zip.bak", false, 0},
+		{"foo_1_foo.zip", false, 0},
+		{"foo_bar_baz.zip", false, 0},
+		{"", false, 0},
+		{"1_", false, 0},
+	}
+
+	for _, t := range tests {
+		ok, setID := backend.IsSnapshotFilename(t.name)
+		c.Check(ok, Equals, t.valid, Commentf("fn: %q", t.name))
+		c.Check(setID, Equals, t.setID, Commentf("fn: %q", t.name))
+	}
+}
+
+func (s *snapshotSuite) TestNothingToList(c *C) {
+	sets, err := backend.List(context.Background(), 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+
+	setID, err := backend.LastSnapshotSetID()
+	c.Assert(err, IsNil)
+	c.Check(setID, Equals, uint64(0))
+}
+
+func (s *snapshotSuite) TestUsersForUsernames(c *C) {
+	users, err := backend.UsersForUsernames([]string{"snapuser", "potato"})
+	c.Assert(err, IsNil)
+	c.Assert(users, HasLen, 1)
+	c.Check(users[0].Username, Equals, "snapuser")
+}
+
+func (s *snapshotSuite) TestAllUsers(c *C) {
+	if os.Geteuid() == 0 {
+		c.Skip("the snap user and root are the same user when running as root")
+	}
+	info := mkInfo("hello-snap", 42)
+	s.mkSnapData(c, info, "hello")
+
+	users, err := backend.UsersForUsernames(nil)
+	c.Assert(err, IsNil)
+	c.Assert(users, HasLen, 2)
+	c.Check(users[0].Username, Equals, "root")
+	c.Check(users[1].Username, Equals, "snapuser")
+}
+
+func (s *snapshotSuite) TestSaveListCheckRestore(c *C) {
+	ctx := context.Background()
+	info := mkInfo("hello-snap", 42)
+	s.mkSnapData(c, info, "hello")
+
+	cfg := map[string]interface{}{"some-setting": false}
+	shot, err := backend.Save(ctx, 12, info, cfg, []string{"snapuser"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(shot.SetID, Equals, uint64(12))
+	c.Check(shot.Snap, Equals, "hello-snap")
+	c.Check(shot.Revision, Equals, snap.R(42))
+	c.Check(shot.Version, Equals, "v1.33")
+	c.Check(shot.Conf, DeepEquals, cfg)
+	c.Check(shot.SHA3_384, HasLen, 2)
+	c.Check(shot.SHA3_384["archive.tgz"], Not(Equals), "")
+	c.Check(shot.SHA3_384["user/snapuser.tgz"], Not(Equals), "")
+	c.Check(shot.Size > 0, Equals, true)
+
+	c.Check(backend.Filename(shot), Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
+	c.Check(osutil.FileExists(backend.Filename(shot)), Equals, true)
+
+	setID, err := backend.LastSnapshotSetID()
+	c.Assert(err, IsNil)
+	c.Check(setID, Equals, uint64(12))
+
+	sets, err := backend.List(ctx, 0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sets, HasLen, 1)
+	c.Check(sets[0].ID, Equals, uint64(12))
+	c.Assert(sets[0].Snapshots, HasLen, 1)
+	c.Check(sets[0].Snapshots[0].Snap, Equals, "hello-snap")
+	c.Check(sets[0].Snapshots[0].SHA3_384, DeepEquals, shot.SHA3_384)
+	c.Check(sets[0].Snapshots[0].Conf, DeepEquals, map[string]interface{}{"some-setting": false})
+
+	sets, err = backend.List(ctx, 13, nil)
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+	sets, err = backend.List(ctx, 0, []string{"other-snap"})
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+
+	reader, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	c.Check(reader.Check(ctx, nil), IsNil)
+
+	// now mess with the data
+	s.mkSnapData(c, info, "goodbye")
+
+	var logs []string
+	logf := func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+	rs, err := reader.Restore(ctx, snap.R(0), nil, logf)
+	c.Assert(err, IsNil)
+	c.Check(logs, HasLen, 0)
+	s.checkSnapData(c, info, "hello")
+	// the old data is kept aside until cleanup
+	c.Check(rs.Moved, HasLen, 4)
+	for _, dir := range rs.Moved {
+		c.Check(osutil.FileExists(dir), Equals, true)
+	}
+	rs.Cleanup()
+	for _, dir := range rs.Moved {
+		c.Check(osutil.FileExists(dir), Equals, false)
+	}
+	s.checkSnapData(c, info, "hello")
+}
+
+func (s *snapshotSuite) TestRestoreRevert(c *C) {
+	ctx := context.Background()
+	info := mkInfo("hello-snap", 42)
+	s.mkSnapData(c, info, "hello")
+
+	shot, err := backend.Save(ctx, 1, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, IsNil)
+
+	s.mkSnapData(c, info, "goodbye")
+
+	reader, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	rs, err := reader.Restore(ctx, snap.R(0), nil, c.Logf)
+	c.Assert(err, IsNil)
+	s.checkSnapData(c, info, "hello")
+
+	rs.Revert()
+	s.checkSnapData(c, info, "goodbye")
+
+	// doing it again does nothing
+	rs.Revert()
+	s.checkSnapData(c, info, "goodbye")
+}
+
+func (s *snapshotSuite) TestRestoreToNewRevision(c *C) {
+	ctx := context.Background()
+	info := mkInfo("hello-snap", 42)
+	s.mkSnapData(c, info, "hello")
+
+	shot, err := backend.Save(ctx, 1, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, IsNil)
+
+	// the snap got refreshed, and the data removed
+	c.Assert(os.RemoveAll(filepath.Join(dirs.SnapDataDir, "hello-snap")), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.home, "snap")), IsNil)
+
+	reader, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	rs, err := reader.Restore(ctx, snap.R(43), nil, c.Logf)
+	c.Assert(err, IsNil)
+	s.checkSnapData(c, mkInfo("hello-snap", 43), "hello")
+	c.Check(osutil.FileExists(info.DataDir()), Equals, false)
+	c.Check(rs.Moved, HasLen, 0)
+
+	// reverting removes everything that was created
+	rs.Revert()
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDataDir, "hello-snap")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(s.home, "snap")), Equals, false)
+}
+
+func (s *snapshotSuite) TestSaveAuto(c *C) {
+	ctx := context.Background()
+	info := mkInfo("hello-snap", 42)
+	s.mkSnapData(c, info, "hello")
+
+	shot, err := backend.Save(ctx, 1, info, nil, nil, &backend.Flags{Auto: true})
+	c.Assert(err, IsNil)
+	c.Check(shot.Auto, Equals, true)
+
+	reader, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer reader.Close()
+	c.Check(reader.Auto, Equals, true)
+	c.Check(reader.Check(ctx, nil), IsNil)
+}
+
+func (s *snapshotSuite) TestCheckDetectsMismatch(c *C) {
+	ctx := context.Background()
+	info := mkInfo("hello-snap", 42)
+	s.mkSnapData(c, info, "hello")
+
+	shot, err := backend.Save(ctx, 1, info, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	reader, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	reader.SHA3_384["archive.tgz"] = "potato"
+	c.Check(reader.Check(ctx, nil), ErrorMatches, `snapshot "1_hello-snap_v1.33_42.zip" failed integrity check: checksum mismatch on "archive.tgz": expected potato, got .*`)
+
+	// restore also checks
+	_, err = reader.Restore(ctx, snap.R(0), nil, c.Logf)
+	c.Check(err, ErrorMatches, `snapshot "1_hello-snap_v1.33_42.zip" failed integrity check on "archive.tgz": expected potato, got .*`)
+	// and nothing was changed
+	s.checkSnapData(c, info, "hello")
+}
+
+func (s *snapshotSuite) TestOpenNotASnapshot(c *C) {
+	fn := filepath.Join(c.MkDir(), "1_foo.zip")
+	c.Assert(ioutil.WriteFile(fn, []byte("hello"), 0644), IsNil)
+
+	reader, err := backend.Open(fn)
+	c.Check(err, ErrorMatches, `cannot read snapshot ".*/1_foo.zip": zip: not a valid zip file`)
+	c.Check(reader, IsNil)
+}
+
+func (s *snapshotSuite) TestIterCancelled(c *C) {
+	info := mkInfo("hello-snap", 42)
+	s.mkSnapData(c, info, "hello")
+	_, err := backend.Save(context.Background(), 1, info, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	err = backend.Iter(ctx, func(*backend.Reader) error {
+		called = true
+		return nil
+	})
+	c.Check(err, Equals, context.Canceled)
+	c.Check(called, Equals, false)
+}
+
+func (s *snapshotSuite) TestIterStop(c *C) {
+	for _, name := range []string{"snap-a", "snap-b"} {
+		info := mkInfo(name, 1)
+		s.mkSnapData(c, info, "hello")
+		_, err := backend.Save(context.Background(), 1, info, nil, nil, nil)
+		c.Assert(err, IsNil)
+	}
+
+	calls := 0
+	err := backend.Iter(context.Background(), func(*backend.Reader) error {
+		calls++
+		return backend.Stop
+	})
+	c.Check(err, IsNil)
+	c.Check(calls, Equals, 1)
+}
@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+)
+
+// Stop is used to ask Iter to stop iteration, without it being an error.
+var Stop = errors.New("stop iteration")
+
+var (
+	userLookup   = user.Lookup
+	userLookupId = user.LookupId
+)
+
+// bySnap sorts snapshots by snap name.
+type bySnap []*client.Snapshot
+
+func (a bySnap) Len() int           { return len(a) }
+func (a bySnap) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a bySnap) Less(i, j int) bool { return a[i].Snap < a[j].Snap }
+
+// byID sorts snapshot sets by their id.
+type byID []client.SnapshotSet
+
+func (a byID) Len() int           { return len(a) }
+func (a byID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byID) Less(i, j int) bool { return a[i].ID < a[j].ID }
+
+// isSnapshotFilename checks if the given filename is a snapshot file
+// name, i.e. if it starts with a number followed by an underscore
+// and ends in ".zip". It returns the set id of the snapshot if it is.
+func isSnapshotFilename(filename string) (ok bool, setID uint64) {
+	if filepath.Ext(filename) != ".zip" {
+		return false, 0
+	}
+	idx := strings.IndexByte(filename, '_')
+	if idx < 1 {
+		return false, 0
+	}
+	setID, err := strconv.ParseUint(filename[:idx], 10, 64)
+	if err != nil {
+		return false, 0
+	}
+	return true, setID
+}
+
+func userArchiveName(usr *user.User) string {
+	return userArchivePrefix + usr.Username + userArchiveSuffix
+}
+
+func isUserArchive(entry string) bool {
+	return strings.HasPrefix(entry, userArchivePrefix) && strings.HasSuffix(entry, userArchiveSuffix)
+}
+
+func entryUsername(entry string) string {
+	// this _will_ panic if !isUserArchive(entry)
+	return entry[len(userArchivePrefix) : len(entry)-len(userArchiveSuffix)]
+}
+
+// usersForUsernames returns the users for the given usernames, or
+// all the users that have a home directory with snap data if no
+// usernames are given.
+func usersForUsernames(usernames []string) ([]*user.User, error) {
+	if len(usernames) == 0 {
+		return allUsers()
+	}
+	users := make([]*user.User, 0, len(usernames))
+	for _, username := range usernames {
+		usr, err := userLookup(username)
+		if err != nil {
+			if _, ok := err.(user.UnknownUserError); ok {
+				logger.Noticef("Cannot find user %q.", username)
+				continue
+			}
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+
+	return users, nil
+}
+
+// allUsers returns root plus all the users that own a snap directory
+// in the homes glob.
+func allUsers() ([]*user.User, error) {
+	ds, err := filepath.Glob(dirs.SnapDataHomeGlob)
+	if err != nil {
+		// can't happen?
+		return nil, err
+	}
+
+	users := make([]*user.User, 1, len(ds)+1)
+	root, err := userLookupId("0")
+	if err != nil {
+		return nil, err
+	}
+	users[0] = root
+	seen := make(map[uint32]bool, len(ds)+1)
+	seen[0] = true
+	var st syscall.Stat_t
+	for _, d := range ds {
+		err := syscall.Stat(d, &st)
+		if err != nil {
+			continue
+		}
+		if seen[st.Uid] {
+			continue
+		}
+		seen[st.Uid] = true
+		usr, err := userLookupId(strconv.FormatUint(uint64(st.Uid), 10))
+		if err != nil {
+			// Treat all non-nil errors as user.Unknown{User,Group}Error's, as
+			// currently Go's handling of returned errno from get{pw,gr}nam_r
+			// in the cgo implementation of user.Lookup is lacking, and thus
+			// user.Unknown{User,Group}Error is returned only when errno is 0
+			// and the list of users/groups is empty.
+			continue
+		}
+		users = append(users, usr)
+	}
+
+	return users, nil
+}
+
+// tarError builds an error out of the given tar failure, using the
+// first line of what tar wrote to stderr when there is one.
+func tarError(action string, err error, stderr []byte) error {
+	if idx := bytes.IndexByte(stderr, '\n'); idx > -1 {
+		stderr = stderr[:idx]
+	}
+	if len(stderr) > 0 {
+		return fmt.Errorf("cannot %s archive: %s", action, stderr)
+	}
+	return fmt.Errorf("tar failed: %v", err)
+}
@@ -0,0 +1,371 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// A Reader is a snapshot that's been opened for reading.
+type Reader struct {
+	*os.File
+	client.Snapshot
+
+	zip *zip.Reader
+}
+
+// Open a Snapshot given its full filename.
+//
+// If the returned error is nil, the caller must close the reader (or
+// its file) when done with it.
+//
+// If the returned error is non-nil, the returned Reader will be nil,
+// *or* have a non-empty Broken; in the latter case its file will be
+// closed.
+func Open(fn string) (reader *Reader, e error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e != nil {
+			f.Close()
+		}
+	}()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	z, err := zip.NewReader(f, st.Size())
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot %q: %v", fn, err)
+	}
+
+	var metaFile, metaHashFile *zip.File
+	for _, zf := range z.File {
+		switch zf.Name {
+		case metadataName:
+			metaFile = zf
+		case metaHashName:
+			metaHashFile = zf
+		}
+	}
+	if metaFile == nil {
+		return nil, fmt.Errorf("cannot read snapshot %q: no metadata", fn)
+	}
+
+	reader = &Reader{
+		File: f,
+		zip:  z,
+	}
+
+	hasher := crypto.SHA3_384.New()
+	metaReader, err := metaFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer metaReader.Close()
+	tee := io.TeeReader(metaReader, hasher)
+	if err := jsonutil.DecodeWithNumber(tee, &reader.Snapshot); err != nil {
+		return nil, fmt.Errorf("cannot read snapshot %q metadata: %v", fn, err)
+	}
+	// consume any trailing bytes (e.g. the encoder's newline) so they
+	// get hashed as well
+	if _, err := io.Copy(ioutil.Discard, tee); err != nil {
+		return nil, err
+	}
+
+	if !reader.IsValid() {
+		reader.Broken = "invalid snapshot"
+		return reader, errors.New(reader.Broken)
+	}
+
+	if metaHashFile == nil {
+		reader.Broken = "no metadata checksum"
+		return reader, errors.New(reader.Broken)
+	}
+	metaHashReader, err := metaHashFile.Open()
+	if err != nil {
+		reader.Broken = err.Error()
+		return reader, err
+	}
+	defer metaHashReader.Close()
+	expectedMetaHash, err := ioutil.ReadAll(metaHashReader)
+	if err != nil {
+		reader.Broken = err.Error()
+		return reader, err
+	}
+	if actual := fmt.Sprintf("%x", hasher.Sum(nil)); actual != string(bytes.TrimSpace(expectedMetaHash)) {
+		reader.Broken = fmt.Sprintf("checksum mismatch on metadata: expected %s, got %s", bytes.TrimSpace(expectedMetaHash), actual)
+		return reader, errors.New(reader.Broken)
+	}
+
+	return reader, nil
+}
+
+func (r *Reader) checkOne(ctx context.Context, zf *zip.File) error {
+	expectedHash, ok := r.SHA3_384[zf.Name]
+	if !ok {
+		return fmt.Errorf("unexpected entry %q", zf.Name)
+	}
+
+	body, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	hasher := crypto.SHA3_384.New()
+	if _, err := io.Copy(hasher, &ctxReader{ctx: ctx, r: body}); err != nil {
+		return err
+	}
+
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
+		return fmt.Errorf("checksum mismatch on %q: expected %s, got %s", zf.Name, expectedHash, actualHash)
+	}
+
+	return nil
+}
+
+// Check that the data contained in the snapshot matches its hashsums.
+//
+// If usernames is not empty only the archives of those users (and the
+// system-wide archive) are checked.
+func (r *Reader) Check(ctx context.Context, usernames []string) error {
+	seen := make(map[string]bool, len(r.SHA3_384))
+	var problems []string
+	for _, zf := range r.zip.File {
+		if zf.Name == metadataName || zf.Name == metaHashName {
+			continue
+		}
+		seen[zf.Name] = true
+		if len(usernames) > 0 && isUserArchive(zf.Name) && !strutil.ListContains(usernames, entryUsername(zf.Name)) {
+			continue
+		}
+		if err := r.checkOne(ctx, zf); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			problems = append(problems, err.Error())
+		}
+	}
+	for name := range r.SHA3_384 {
+		if !seen[name] {
+			problems = append(problems, fmt.Sprintf("missing entry %q", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("snapshot %q failed integrity check: %s", filepath.Base(r.Name()), strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// Logf is the type implemented by logging functions.
+type Logf func(format string, args ...interface{})
+
+// Restore the data from the snapshot.
+//
+// If successful this will replace the existing data (for the given
+// revision, or the one in the snapshot) with that contained in the
+// snapshot. It keeps track of the old data in the returned
+// RestoreState so it can be reverted (or cleaned up).
+//
+// If usernames is not empty only the archives of those users (and the
+// system-wide archive) are restored.
+func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames []string, logf Logf) (rs *RestoreState, e error) {
+	rs = &RestoreState{}
+	defer func() {
+		if e != nil {
+			logger.Noticef("Restore of snapshot %q failed (%v); undoing.", filepath.Base(r.Name()), e)
+			rs.Revert()
+			rs = nil
+		}
+	}()
+
+	if current.Unset() {
+		current = r.Revision
+	}
+
+	for _, zf := range r.zip.File {
+		if err := ctx.Err(); err != nil {
+			return rs, err
+		}
+
+		var dest string
+		uid, gid := sys.UserID(osutil.NoChown), sys.GroupID(osutil.NoChown)
+		switch {
+		case zf.Name == archiveName:
+			dest = filepath.Join(dirs.SnapDataDir, r.Snap)
+		case isUserArchive(zf.Name):
+			username := entryUsername(zf.Name)
+			if len(usernames) > 0 && !strutil.ListContains(usernames, username) {
+				continue
+			}
+			usr, err := userLookup(username)
+			if err != nil {
+				logf("Skipping restore of user %q: %v.", username, err)
+				continue
+			}
+			if n, err := strconv.ParseUint(usr.Uid, 10, 32); err == nil {
+				uid = sys.UserID(n)
+			}
+			if n, err := strconv.ParseUint(usr.Gid, 10, 32); err == nil {
+				gid = sys.GroupID(n)
+			}
+			dest = filepath.Join(usr.HomeDir, "snap", r.Snap)
+		default:
+			// the metadata, or something we don't know about
+			continue
+		}
+
+		if err := r.restoreOne(ctx, zf, dest, current, uid, gid, rs); err != nil {
+			return rs, err
+		}
+	}
+
+	return rs, nil
+}
+
+func (r *Reader) restoreOne(ctx context.Context, zf *zip.File, dest string, current snap.Revision, uid sys.UserID, gid sys.GroupID, rs *RestoreState) error {
+	// create the destination (and its parent) as needed, keeping
+	// track of what was created so it can be undone
+	for _, dir := range []string{filepath.Dir(dest), dest} {
+		exists, isDir, err := osutil.DirExists(dir)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if !isDir {
+				return fmt.Errorf("cannot restore snapshot into %q: not a directory", dir)
+			}
+			continue
+		}
+		if err := osutil.MkdirAllChown(dir, 0755, uid, gid); err != nil {
+			return err
+		}
+		rs.Created = append(rs.Created, dir)
+	}
+
+	tempdir, err := ioutil.TempDir(dest, ".snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	body, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	hasher := crypto.SHA3_384.New()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "tar",
+		"--extract",
+		"--preserve-permissions", "--preserve-order", "--gunzip",
+		"--directory", tempdir)
+	cmd.Env = []string{"LANG=C"}
+	cmd.Stdin = io.TeeReader(body, hasher)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return tarError("extract", err, stderr.Bytes())
+	}
+
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != r.SHA3_384[zf.Name] {
+		return fmt.Errorf("snapshot %q failed integrity check on %q: expected %s, got %s", filepath.Base(r.Name()), zf.Name, r.SHA3_384[zf.Name], actualHash)
+	}
+
+	for _, name := range []string{r.Revision.String(), "common"} {
+		source := filepath.Join(tempdir, name)
+		exists, _, err := osutil.DirExists(source)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		target := filepath.Join(dest, name)
+		if name == r.Revision.String() {
+			// restore the revision's data into the current revision
+			target = filepath.Join(dest, current.String())
+		}
+
+		exists, _, err = osutil.DirExists(target)
+		if err != nil {
+			return err
+		}
+		if exists {
+			aside := target + ".~" + strutil.MakeRandomString(12) + "~"
+			if err := os.Rename(target, aside); err != nil {
+				return err
+			}
+			rs.Moved = append(rs.Moved, aside)
+		}
+
+		if err := os.Rename(source, target); err != nil {
+			return err
+		}
+		rs.Created = append(rs.Created, target)
+	}
+
+	return nil
+}
+
+// ctxReader is an io.Reader that stops reading when its context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// RestoreState stores information that can be used to cleanly revert
+// (or finish cleaning up) a snapshot Restore.
+//
+// This is useful when a Restore is part of a chain of operations, and
+// a later one failing necessitates undoing the Restore.
+type RestoreState struct {
+	Done    bool     `json:"done,omitempty"`
+	Created []string `json:"created,omitempty"`
+	Moved   []string `json:"moved,omitempty"`
+	// Config is here for convenience; this package doesn't touch it
+	Config *json.RawMessage `json:"config,omitempty"`
+}
+
+// movedOriginal returns the original path of something that was
+// moved aside during a restore.
+func movedOriginal(aside string) string {
+	idx := strings.LastIndex(aside, ".~")
+	if idx < 0 {
+		return ""
+	}
+	return aside[:idx]
+}
+
+// Cleanup the backed up data from disk.
+func (rs *RestoreState) Cleanup() {
+	if rs.Done {
+		return
+	}
+
+	for _, dir := range rs.Moved {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Noticef("Cannot remove directory tree rooted at %q: %v.", dir, err)
+		}
+	}
+	rs.Done = true
+}
+
+// Revert the backed up data: remove what was added, move back what was moved aside.
+func (rs *RestoreState) Revert() {
+	if rs.Done {
+		return
+	}
+
+	for i := len(rs.Created) - 1; i >= 0; i-- {
+		dir := rs.Created[i]
+		logger.Debugf("Removing %q.", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Noticef("While undoing changes because of a previous error: cannot remove %q: %v.", dir, err)
+		}
+	}
+	for _, aside := range rs.Moved {
+		orig := movedOriginal(aside)
+		if orig == "" {
+			// dead code (foot protection)
+			continue
+		}
+		logger.Debugf("Restoring %q.", orig)
+		if err := os.Rename(aside, orig); err != nil {
+			logger.Noticef("While undoing changes because of a previous error: cannot restore %q to %q: %v.", aside, orig, err)
+		}
+	}
+	rs.Done = true
+}
@@ -0,0 +1,350 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/net/context"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	osRemove             = os.Remove
+	snapstateCurrentInfo = snapstate.CurrentInfo
+	configGetSnapConfig  = config.GetSnapConfig
+	configSetSnapConfig  = config.SetSnapConfig
+	backendOpen          = backend.Open
+	backendSave          = backend.Save
+	backendFilename      = backend.Filename
+	timeNow              = time.Now
+
+	// how often to look for expired automatic snapshots
+	snapshotExpirationLoopInterval = 24 * time.Hour
+)
+
+// SnapshotManager takes snapshots of active snaps
+type SnapshotManager struct {
+	state  *state.State
+	runner *state.TaskRunner
+}
+
+// Manager returns a new SnapshotManager
+func Manager(st *state.State) *SnapshotManager {
+	runner := state.NewTaskRunner(st)
+	runner.AddHandler("save-snapshot", doSave, doForget)
+	runner.AddHandler("forget-snapshot", doForget, nil)
+	runner.AddHandler("check-snapshot", doCheck, nil)
+	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
+	runner.AddCleanup("restore-snapshot", cleanupRestore)
+
+	for _, kind := range []string{"save-snapshot", "forget-snapshot", "check-snapshot", "restore-snapshot"} {
+		snapstate.AddAffectedSnapsByKind(kind, affectedSnaps)
+	}
+
+	return &SnapshotManager{
+		state:  st,
+		runner: runner,
+	}
+}
+
+func (m *SnapshotManager) KnownTaskKinds() []string {
+	return m.runner.KnownTaskKinds()
+}
+
+// Ensure is part of the overlord.StateManager interface.
+func (m *SnapshotManager) Ensure() error {
+	m.runner.Ensure()
+	return m.forgetExpiredSnapshots()
+}
+
+// forgetExpiredSnapshots removes the automatic snapshots that are
+// older than the configured retention period. It does so at most once
+// every snapshotExpirationLoopInterval.
+func (m *SnapshotManager) forgetExpiredSnapshots() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	now := timeNow()
+	var last time.Time
+	if err := st.Get("last-forget-expired-snapshot-time", &last); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !last.IsZero() && now.Sub(last) < snapshotExpirationLoopInterval {
+		return nil
+	}
+
+	expiration, err := AutomaticSnapshotExpiration(st)
+	if err != nil {
+		return err
+	}
+	if expiration == 0 {
+		// automatic snapshots are disabled; keep the ones we have
+		st.Set("last-forget-expired-snapshot-time", now)
+		return nil
+	}
+
+	var expired []string
+	err = backendIter(context.TODO(), func(r *backend.Reader) error {
+		if !r.Auto || r.Time.Add(expiration).After(now) {
+			return nil
+		}
+		// leave alone snapshots that are being checked or restored
+		if err := checkSnapshotTaskConflict(st, r.SetID, "check-snapshot", "restore-snapshot"); err != nil {
+			logger.Debugf("Not forgetting expired snapshot %q: %v.", r.Name(), err)
+			return nil
+		}
+		expired = append(expired, r.Name())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot list snapshots: %v", err)
+	}
+
+	for _, filename := range expired {
+		if err := osRemove(filename); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove expired snapshot: %v", err)
+		}
+	}
+
+	st.Set("last-forget-expired-snapshot-time", now)
+
+	return nil
+}
+
+// Wait is part of the overlord.StateManager interface.
+func (m *SnapshotManager) Wait() {
+	m.runner.Wait()
+}
+
+// Stop is part of the overlord.StateManager interface.
+func (m *SnapshotManager) Stop() {
+	m.runner.Stop()
+}
+
+func doSave(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	snapshot, err := taskSnapshotSetup(task)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	cur, err := snapstateCurrentInfo(st, snapshot.Snap)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	rawCfg, err := configGetSnapConfig(st, snapshot.Snap)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var cfg map[string]interface{}
+	if rawCfg != nil {
+		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*rawCfg), &cfg); err != nil {
+			return fmt.Errorf("internal error: invalid configuration for snap %q: %v", snapshot.Snap, err)
+		}
+	}
+
+	saved, err := backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, &backend.Flags{Auto: snapshot.Auto})
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	// remember the filename, so undo can forget it
+	snapshot.Filename = backendFilename(saved)
+	task.Set("snapshot-setup", snapshot)
+
+	return nil
+}
+
+// prepareRestore fetches the snapshot setup and the restore state of
+// a restore task, for undoing or cleaning up after it.
+func prepareRestore(task *state.Task) (snapshot *snapshotSetup, restoreState *backend.RestoreState, err error) {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapshot, err = taskSnapshotSetup(task)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rs backend.RestoreState
+	if err := task.Get("restore-state", &rs); err != nil {
+		return nil, nil, err
+	}
+
+	return snapshot, &rs, nil
+}
+
+func doRestore(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	snapshot, err := taskSnapshotSetup(task)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	oldCfg, err := configGetSnapConfig(st, snapshot.Snap)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	reader, err := backendOpen(snapshot.Filename)
+	if err != nil {
+		return fmt.Errorf("cannot open snapshot: %v", err)
+	}
+	// note given the Open succeeded, caller needs to close it when done
+	defer reader.Close()
+
+	var newCfg *json.RawMessage
+	if reader.Conf != nil {
+		buf, err := json.Marshal(reader.Conf)
+		if err != nil {
+			return fmt.Errorf("cannot marshal saved configuration: %v", err)
+		}
+		raw := json.RawMessage(buf)
+		newCfg = &raw
+	}
+
+	logf := func(format string, args ...interface{}) {
+		st.Lock()
+		defer st.Unlock()
+		task.Logf(format, args...)
+	}
+
+	restoreState, err := reader.Restore(tomb.Context(nil), snapshot.Current, snapshot.Users, logf)
+	if err != nil {
+		return err
+	}
+	// keep the old config around so undo can put it back
+	restoreState.Config = oldCfg
+
+	st.Lock()
+	defer st.Unlock()
+
+	if err := configSetSnapConfig(st, snapshot.Snap, newCfg); err != nil {
+		restoreState.Revert()
+		return fmt.Errorf("cannot set snap config: %v", err)
+	}
+
+	task.Set("restore-state", restoreState)
+
+	return nil
+}
+
+func undoRestore(task *state.Task, _ *tomb.Tomb) error {
+	snapshot, restoreState, err := prepareRestore(task)
+	if err != nil {
+		return err
+	}
+
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := configSetSnapConfig(st, snapshot.Snap, restoreState.Config); err != nil {
+		return fmt.Errorf("cannot restore saved config: %v", err)
+	}
+
+	restoreState.Revert()
+	task.Set("restore-state", restoreState)
+
+	return nil
+}
+
+func cleanupRestore(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	status := task.Status()
+	st.Unlock()
+	if status != state.DoneStatus {
+		// only need to clean up restores that worked
+		return nil
+	}
+
+	_, restoreState, err := prepareRestore(task)
+	if err != nil {
+		return err
+	}
+
+	restoreState.Cleanup()
+
+	return nil
+}
+
+func doCheck(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	snapshot, err := taskSnapshotSetup(task)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	reader, err := backendOpen(snapshot.Filename)
+	if err != nil {
+		return fmt.Errorf("cannot open snapshot: %v", err)
+	}
+	// note given the Open succeeded, caller needs to close it when done
+	defer reader.Close()
+
+	return reader.Check(tomb.Context(nil), snapshot.Users)
+}
+
+func doForget(task *state.Task, _ *tomb.Tomb) error {
+	// note this is also undoSave
+	st := task.State()
+	st.Lock()
+	snapshot, err := taskSnapshotSetup(task)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if snapshot.Filename == "" {
+		return fmt.Errorf("internal error: task %s (%s) snapshot info is missing the filename", task.ID(), task.Kind())
+	}
+
+	// in case it's a save that failed, this might well not exist
+	if err := osRemove(snapshot.Filename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove snapshot: %v", err)
+	}
+
+	return nil
+}
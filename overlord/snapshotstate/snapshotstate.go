@@ -0,0 +1,399 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package snapshotstate implements the manager and state aspects
+// responsible for saving, checking, restoring and forgetting snapshots
+// of snap data.
+package snapshotstate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	snapstateAll                     = snapstate.All
+	snapstateCheckChangeConflictMany = snapstate.CheckChangeConflictMany
+	backendIter                      = backend.Iter
+)
+
+func init() {
+	snapstate.AutomaticSnapshot = AutomaticSnapshot
+}
+
+// defaultAutomaticSnapshotExpiration is how long automatic snapshots
+// are kept for when snapshots.automatic.retention is not set.
+const defaultAutomaticSnapshotExpiration = 31 * 24 * time.Hour
+
+// snapshotSetup is what the snapshot tasks carry to know what to do.
+type snapshotSetup struct {
+	SetID    uint64        `json:"set-id"`
+	Snap     string        `json:"snap"`
+	Users    []string      `json:"users,omitempty"`
+	Filename string        `json:"filename,omitempty"`
+	Current  snap.Revision `json:"current"`
+	Auto     bool          `json:"auto,omitempty"`
+}
+
+func taskSnapshotSetup(task *state.Task) (*snapshotSetup, error) {
+	var snapshot snapshotSetup
+
+	if err := task.Get("snapshot-setup", &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// affectedSnaps returns the snap a snapshot task is about, for
+// conflict detection.
+func affectedSnaps(t *state.Task) ([]string, error) {
+	snapshot, err := taskSnapshotSetup(t)
+	if err != nil {
+		return nil, err
+	}
+	return []string{snapshot.Snap}, nil
+}
+
+func newSnapshotSetID(st *state.State) (uint64, error) {
+	var lastStateSetID uint64
+	if err := st.Get("last-snapshot-set-id", &lastStateSetID); err != nil && err != state.ErrNoState {
+		return 0, err
+	}
+
+	lastDiskSetID, err := backend.LastSnapshotSetID()
+	if err != nil {
+		return 0, fmt.Errorf("cannot determine last snapshot set id: %v", err)
+	}
+
+	// take the higher of the two, so snapshots left over from a
+	// previous state (or restored from elsewhere) are not clobbered
+	setID := lastStateSetID
+	if lastDiskSetID > setID {
+		setID = lastDiskSetID
+	}
+	setID++
+	st.Set("last-snapshot-set-id", setID)
+
+	return setID, nil
+}
+
+func allActiveSnapNames(st *state.State) ([]string, error) {
+	all, err := snapstateAll(st)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(all))
+	for name, snapst := range all {
+		if snapst.Active {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+type snapshotSnapSummary struct {
+	snap     string
+	filename string
+}
+
+type snapshotSnapSummaries []*snapshotSnapSummary
+
+func (summaries snapshotSnapSummaries) snapNames() []string {
+	names := make([]string, len(summaries))
+	for i, summary := range summaries {
+		names[i] = summary.snap
+	}
+	return names
+}
+
+// snapSummariesInSnapshotSet returns the snaps (and their snapshot
+// filenames) in the given snapshot set, restricted to the requested
+// snaps if any are given.
+func snapSummariesInSnapshotSet(setID uint64, requested []string) (snapshotSnapSummaries, error) {
+	var summaries snapshotSnapSummaries
+	sawSet := false
+	err := backendIter(context.TODO(), func(r *backend.Reader) error {
+		if r.SetID == setID {
+			sawSet = true
+			if len(requested) == 0 || strutil.ListContains(requested, r.Snap) {
+				summaries = append(summaries, &snapshotSnapSummary{
+					snap:     r.Snap,
+					filename: r.Name(),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !sawSet {
+		return nil, client.ErrSnapshotSetNotFound
+	}
+	if len(summaries) == 0 {
+		return nil, client.ErrSnapshotSnapsNotFound
+	}
+
+	return summaries, nil
+}
+
+// checkSnapshotTaskConflict checks that no task of the given kinds is
+// in progress for the given snapshot set.
+func checkSnapshotTaskConflict(st *state.State, setID uint64, conflictingKinds ...string) error {
+	for _, task := range st.Tasks() {
+		chg := task.Change()
+		if chg != nil && chg.Status().Ready() {
+			continue
+		}
+		if !strutil.ListContains(conflictingKinds, task.Kind()) {
+			continue
+		}
+
+		snapshot, err := taskSnapshotSetup(task)
+		if err != nil {
+			return fmt.Errorf("internal error: cannot obtain snapshot setup from task: %s", task.Summary())
+		}
+
+		if snapshot.SetID == setID {
+			if chg == nil {
+				return fmt.Errorf("cannot operate on snapshot set #%d while task %q is in progress", setID, task.ID())
+			}
+			return fmt.Errorf("cannot operate on snapshot set #%d while change %q is in progress", setID, chg.ID())
+		}
+	}
+
+	return nil
+}
+
+// List valid snapshots.
+var List = backend.List
+
+// Save creates a taskset for taking snapshots of snaps' data.
+// Note that the state must be locked by the caller.
+func Save(st *state.State, snapNames []string, users []string) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	if len(snapNames) == 0 {
+		snapNames, err = allActiveSnapNames(st)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	if err := snapstateCheckChangeConflictMany(st, snapNames, nil); err != nil {
+		return 0, nil, nil, err
+	}
+
+	setID, err = newSnapshotSetID(st)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	ts = state.NewTaskSet()
+
+	for _, name := range snapNames {
+		desc := fmt.Sprintf("Save data of snap %q in snapshot set #%d", name, setID)
+		task := st.NewTask("save-snapshot", desc)
+		snapshot := snapshotSetup{
+			SetID: setID,
+			Snap:  name,
+			Users: users,
+		}
+		task.Set("snapshot-setup", &snapshot)
+		// Each snapshot in a set is of a single snap, so
+		// the tasks can run in parallel.
+		ts.AddTask(task)
+	}
+
+	return setID, snapNames, ts, nil
+}
+
+// AutomaticSnapshotExpiration returns for how long automatic
+// snapshots are kept, as set via snapshots.automatic.retention. A zero
+// duration means automatic snapshots are disabled.
+// Note that the state must be locked by the caller.
+func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
+	var expirationStr string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.automatic.retention", &expirationStr); err != nil && !config.IsNoOption(err) {
+		return 0, err
+	}
+
+	switch expirationStr {
+	case "":
+		return defaultAutomaticSnapshotExpiration, nil
+	case "no":
+		return 0, nil
+	}
+
+	expiration, err := time.ParseDuration(expirationStr)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse snapshots.automatic.retention: %v", err)
+	}
+	return expiration, nil
+}
+
+// AutomaticSnapshot creates a taskset for taking a snapshot of the
+// data of a snap that is about to be removed. It returns
+// snapstate.ErrNothingToDo if automatic snapshots are disabled.
+// Note that the state must be locked by the caller.
+func AutomaticSnapshot(st *state.State, snapName string) (*state.TaskSet, error) {
+	expiration, err := AutomaticSnapshotExpiration(st)
+	if err != nil {
+		return nil, err
+	}
+	if expiration == 0 {
+		return nil, snapstate.ErrNothingToDo
+	}
+
+	setID, err := newSnapshotSetID(st)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := fmt.Sprintf("Save data of snap %q in automatic snapshot set #%d", snapName, setID)
+	task := st.NewTask("save-snapshot", desc)
+	snapshot := snapshotSetup{
+		SetID: setID,
+		Snap:  snapName,
+		Auto:  true,
+	}
+	task.Set("snapshot-setup", &snapshot)
+
+	return state.NewTaskSet(task), nil
+}
+
+// Restore creates a taskset for restoring a snapshot's data.
+// Note that the state must be locked by the caller.
+func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := snapstateAll(st)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapsFound = summaries.snapNames()
+	if err := snapstateCheckChangeConflictMany(st, snapsFound, nil); err != nil {
+		return nil, nil, err
+	}
+
+	// restore needs to conflict with forget of itself
+	if err := checkSnapshotTaskConflict(st, setID, "forget-snapshot"); err != nil {
+		return nil, nil, err
+	}
+
+	ts = state.NewTaskSet()
+
+	for _, summary := range summaries {
+		var current snap.Revision
+		if snapst, ok := all[summary.snap]; ok {
+			current = snapst.Current
+		}
+
+		desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d", summary.snap, setID)
+		task := st.NewTask("restore-snapshot", desc)
+		snapshot := snapshotSetup{
+			SetID:    setID,
+			Snap:     summary.snap,
+			Users:    users,
+			Filename: summary.filename,
+			Current:  current,
+		}
+		task.Set("snapshot-setup", &snapshot)
+		// see the note about parallel tasks in Save
+		ts.AddTask(task)
+	}
+
+	return snapsFound, ts, nil
+}
+
+// Check creates a taskset for checking a snapshot's data.
+// Note that the state must be locked by the caller.
+func Check(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	// check needs to conflict with forget of itself
+	if err := checkSnapshotTaskConflict(st, setID, "forget-snapshot"); err != nil {
+		return nil, nil, err
+	}
+
+	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ts = state.NewTaskSet()
+
+	for _, summary := range summaries {
+		desc := fmt.Sprintf("Check data of snap %q in snapshot set #%d", summary.snap, setID)
+		task := st.NewTask("check-snapshot", desc)
+		snapshot := snapshotSetup{
+			SetID:    setID,
+			Snap:     summary.snap,
+			Users:    users,
+			Filename: summary.filename,
+		}
+		task.Set("snapshot-setup", &snapshot)
+		ts.AddTask(task)
+	}
+
+	return summaries.snapNames(), ts, nil
+}
+
+// Forget creates a taskset for deleting a snapshot.
+// Note that the state must be locked by the caller.
+func Forget(st *state.State, setID uint64, snapNames []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	// forget needs to conflict with check and restore
+	if err := checkSnapshotTaskConflict(st, setID, "check-snapshot", "restore-snapshot"); err != nil {
+		return nil, nil, err
+	}
+
+	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ts = state.NewTaskSet()
+	for _, summary := range summaries {
+		desc := fmt.Sprintf("Drop data of snap %q from snapshot set #%d", summary.snap, setID)
+		task := st.NewTask("forget-snapshot", desc)
+		snapshot := snapshotSetup{
+			SetID:    setID,
+			Snap:     summary.snap,
+			Filename: summary.filename,
+		}
+		task.Set("snapshot-setup", &snapshot)
+		ts.AddTask(task)
+	}
+
+	return summaries.snapNames(), ts, nil
+}
@@ -0,0 +1,523 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func TestSnapshot(t *testing.T) { check.TestingT(t) }
+
+type snapshotSuite struct {
+	state    *state.State
+	restores []func()
+}
+
+var _ = check.Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	// the manager registers the snapshot tasks for conflict detection
+	snapshotstate.Manager(s.state)
+
+	s.restores = []func(){
+		snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, name string) (*snap.Info, error) {
+			return mkInfo(name, 1), nil
+		}),
+	}
+}
+
+func (s *snapshotSuite) TearDownTest(c *check.C) {
+	for _, restore := range s.restores {
+		restore()
+	}
+	dirs.SetRootDir("")
+}
+
+func mkInfo(name string, rev int) *snap.Info {
+	return &snap.Info{
+		SideInfo: snap.SideInfo{RealName: name, Revision: snap.R(rev)},
+		Version:  "v1.0",
+	}
+}
+
+func mkSnapData(c *check.C, info *snap.Info, what string) {
+	for _, dir := range []string{info.DataDir(), info.CommonDataDir()} {
+		c.Assert(os.MkdirAll(dir, 0755), check.IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, "canary.txt"), []byte(what), 0644), check.IsNil)
+	}
+}
+
+func checkSnapData(c *check.C, info *snap.Info, what string) {
+	for _, dir := range []string{info.DataDir(), info.CommonDataDir()} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "canary.txt"))
+		c.Assert(err, check.IsNil)
+		c.Check(string(content), check.Equals, what)
+	}
+}
+
+// save a snapshot of the given snap, for the nonexistent root user only
+func (s *snapshotSuite) save(c *check.C, setID uint64, name string, what string) *client.Snapshot {
+	return s.saveWithFlags(c, setID, name, what, nil)
+}
+
+func (s *snapshotSuite) saveWithFlags(c *check.C, setID uint64, name string, what string, flags *backend.Flags) *client.Snapshot {
+	info := mkInfo(name, 1)
+	mkSnapData(c, info, what)
+	shot, err := backend.Save(context.Background(), setID, info, map[string]interface{}{"what": what}, []string{"not-a-user"}, flags)
+	c.Assert(err, check.IsNil)
+	return shot
+}
+
+func (s *snapshotSuite) TestNewSnapshotSetID(c *check.C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	setID, err := snapshotstate.NewSnapshotSetID(st)
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(1))
+
+	setID, err = snapshotstate.NewSnapshotSetID(st)
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(2))
+
+	// snapshots on disk are taken into account
+	c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0700), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapshotsDir, "9_foo_1.0_1.zip"), nil, 0600), check.IsNil)
+
+	setID, err = snapshotstate.NewSnapshotSetID(st)
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(10))
+}
+
+func (s *snapshotSuite) TestAllActiveSnapNames(c *check.C) {
+	restore := snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"b-snap": {Active: true},
+			"a-snap": {Active: true},
+			"c-snap": {Active: false},
+		}, nil
+	})
+	defer restore()
+
+	names, err := snapshotstate.AllActiveSnapNames(s.state)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"a-snap", "b-snap"})
+}
+
+func (s *snapshotSuite) TestSaveAllSnaps(c *check.C) {
+	restore := snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {Active: true},
+		}, nil
+	})
+	defer restore()
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	setID, saved, ts, err := snapshotstate.Save(st, nil, []string{"a-user"})
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(1))
+	c.Check(saved, check.DeepEquals, []string{"a-snap", "b-snap"})
+
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	for i, task := range tasks {
+		c.Check(task.Kind(), check.Equals, "save-snapshot")
+		var setup map[string]interface{}
+		c.Assert(task.Get("snapshot-setup", &setup), check.IsNil)
+		c.Check(setup, check.DeepEquals, map[string]interface{}{
+			"set-id":  1.,
+			"snap":    saved[i],
+			"users":   []interface{}{"a-user"},
+			"current": "unset",
+		})
+	}
+}
+
+func (s *snapshotSuite) TestSaveConflicts(c *check.C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, ts, err := snapshotstate.Save(st, []string{"a-snap"}, nil)
+	c.Assert(err, check.IsNil)
+	chg := st.NewChange("save-snapshot", "...")
+	chg.AddAll(ts)
+
+	_, _, _, err = snapshotstate.Save(st, []string{"a-snap"}, nil)
+	c.Check(err, check.ErrorMatches, `snap "a-snap" has "save-snapshot" change in progress`)
+
+	// and it conflicts with other snap operations too
+	err = snapstate.CheckChangeConflict(st, "a-snap", nil, nil)
+	c.Check(err, check.ErrorMatches, `snap "a-snap" has "save-snapshot" change in progress`)
+
+	// but not with other snaps
+	_, _, _, err = snapshotstate.Save(st, []string{"b-snap"}, nil)
+	c.Check(err, check.IsNil)
+}
+
+func (s *snapshotSuite) setRetention(c *check.C, value string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "snapshots.automatic.retention", value), check.IsNil)
+	tr.Commit()
+}
+
+func (s *snapshotSuite) TestAutomaticSnapshotExpiration(c *check.C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	expiration, err := snapshotstate.AutomaticSnapshotExpiration(st)
+	c.Assert(err, check.IsNil)
+	c.Check(expiration, check.Equals, 31*24*time.Hour)
+
+	s.setRetention(c, "48h")
+	expiration, err = snapshotstate.AutomaticSnapshotExpiration(st)
+	c.Assert(err, check.IsNil)
+	c.Check(expiration, check.Equals, 48*time.Hour)
+
+	s.setRetention(c, "no")
+	expiration, err = snapshotstate.AutomaticSnapshotExpiration(st)
+	c.Assert(err, check.IsNil)
+	c.Check(expiration, check.Equals, time.Duration(0))
+
+	s.setRetention(c, "bogus")
+	_, err = snapshotstate.AutomaticSnapshotExpiration(st)
+	c.Check(err, check.ErrorMatches, `cannot parse snapshots.automatic.retention: .*`)
+}
+
+func (s *snapshotSuite) TestAutomaticSnapshot(c *check.C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	ts, err := snapstate.AutomaticSnapshot(st, "a-snap")
+	c.Assert(err, check.IsNil)
+
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "save-snapshot")
+	c.Check(tasks[0].Summary(), check.Equals, `Save data of snap "a-snap" in automatic snapshot set #1`)
+	var setup map[string]interface{}
+	c.Assert(tasks[0].Get("snapshot-setup", &setup), check.IsNil)
+	c.Check(setup, check.DeepEquals, map[string]interface{}{
+		"set-id":  1.,
+		"snap":    "a-snap",
+		"auto":    true,
+		"current": "unset",
+	})
+}
+
+func (s *snapshotSuite) TestAutomaticSnapshotDisabled(c *check.C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	s.setRetention(c, "no")
+
+	_, err := snapshotstate.AutomaticSnapshot(st, "a-snap")
+	c.Check(err, check.Equals, snapstate.ErrNothingToDo)
+}
+
+func (s *snapshotSuite) TestForgetExpiredSnapshots(c *check.C) {
+	restore := snapshotstate.MockTimeNow(func() time.Time {
+		return time.Now().Add(32 * 24 * time.Hour)
+	})
+	defer restore()
+
+	auto := s.saveWithFlags(c, 1, "a-snap", "hello", &backend.Flags{Auto: true})
+	manual := s.save(c, 2, "b-snap", "hello")
+
+	mgr := snapshotstate.Manager(s.state)
+	c.Assert(mgr.ForgetExpiredSnapshots(), check.IsNil)
+
+	// only the expired automatic snapshot is gone
+	c.Check(osutil.FileExists(backend.Filename(auto)), check.Equals, false)
+	c.Check(osutil.FileExists(backend.Filename(manual)), check.Equals, true)
+
+	// and it is not done again until the next interval
+	auto = s.saveWithFlags(c, 3, "a-snap", "hello", &backend.Flags{Auto: true})
+	c.Assert(mgr.ForgetExpiredSnapshots(), check.IsNil)
+	c.Check(osutil.FileExists(backend.Filename(auto)), check.Equals, true)
+
+	restore = snapshotstate.MockSnapshotExpirationLoopInterval(0)
+	defer restore()
+	c.Assert(mgr.ForgetExpiredSnapshots(), check.IsNil)
+	c.Check(osutil.FileExists(backend.Filename(auto)), check.Equals, false)
+}
+
+func (s *snapshotSuite) TestForgetExpiredSnapshotsNotYetExpired(c *check.C) {
+	auto := s.saveWithFlags(c, 1, "a-snap", "hello", &backend.Flags{Auto: true})
+
+	mgr := snapshotstate.Manager(s.state)
+	c.Assert(mgr.ForgetExpiredSnapshots(), check.IsNil)
+	c.Check(osutil.FileExists(backend.Filename(auto)), check.Equals, true)
+}
+
+func (s *snapshotSuite) TestForgetExpiredSnapshotsDisabled(c *check.C) {
+	restore := snapshotstate.MockTimeNow(func() time.Time {
+		return time.Now().Add(32 * 24 * time.Hour)
+	})
+	defer restore()
+
+	auto := s.saveWithFlags(c, 1, "a-snap", "hello", &backend.Flags{Auto: true})
+
+	s.state.Lock()
+	s.setRetention(c, "no")
+	s.state.Unlock()
+
+	mgr := snapshotstate.Manager(s.state)
+	c.Assert(mgr.ForgetExpiredSnapshots(), check.IsNil)
+	c.Check(osutil.FileExists(backend.Filename(auto)), check.Equals, true)
+}
+
+func (s *snapshotSuite) TestForgetExpiredSnapshotsSkipsInProgressRestore(c *check.C) {
+	restore := snapshotstate.MockTimeNow(func() time.Time {
+		return time.Now().Add(32 * 24 * time.Hour)
+	})
+	defer restore()
+
+	auto := s.saveWithFlags(c, 1, "a-snap", "hello", &backend.Flags{Auto: true})
+
+	st := s.state
+	st.Lock()
+	_, ts, err := snapshotstate.Restore(st, 1, nil, nil)
+	c.Assert(err, check.IsNil)
+	chg := st.NewChange("restore-snapshot", "...")
+	chg.AddAll(ts)
+	st.Unlock()
+
+	mgr := snapshotstate.Manager(s.state)
+	c.Assert(mgr.ForgetExpiredSnapshots(), check.IsNil)
+	c.Check(osutil.FileExists(backend.Filename(auto)), check.Equals, true)
+}
+
+func (s *snapshotSuite) TestSetNotFound(c *check.C) {
+	s.save(c, 1, "a-snap", "hello")
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	c.Check(err, check.Equals, client.ErrSnapshotSetNotFound)
+	_, _, err = snapshotstate.Check(st, 42, nil, nil)
+	c.Check(err, check.Equals, client.ErrSnapshotSetNotFound)
+	_, _, err = snapshotstate.Forget(st, 42, nil)
+	c.Check(err, check.Equals, client.ErrSnapshotSetNotFound)
+
+	_, _, err = snapshotstate.Forget(st, 1, []string{"b-snap"})
+	c.Check(err, check.Equals, client.ErrSnapshotSnapsNotFound)
+}
+
+func (s *snapshotSuite) TestForgetConflictsWithCheck(c *check.C) {
+	s.save(c, 1, "a-snap", "hello")
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	found, ts, err := snapshotstate.Check(st, 1, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap"})
+	chg := st.NewChange("check-snapshot", "...")
+	chg.AddAll(ts)
+
+	_, _, err = snapshotstate.Forget(st, 1, nil)
+	c.Check(err, check.ErrorMatches, `cannot operate on snapshot set #1 while change "\d+" is in progress`)
+}
+
+func (s *snapshotSuite) TestRestoreSetsCurrent(c *check.C) {
+	s.save(c, 1, "a-snap", "hello")
+	restore := snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true, Current: snap.R(5)},
+		}, nil
+	})
+	defer restore()
+
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	found, ts, err := snapshotstate.Restore(st, 1, nil, []string{"a-user"})
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap"})
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "restore-snapshot")
+	var setup map[string]interface{}
+	c.Assert(tasks[0].Get("snapshot-setup", &setup), check.IsNil)
+	c.Check(setup, check.DeepEquals, map[string]interface{}{
+		"set-id":   1.,
+		"snap":     "a-snap",
+		"users":    []interface{}{"a-user"},
+		"filename": filepath.Join(dirs.SnapshotsDir, "1_a-snap_v1.0_1.zip"),
+		"current":  "5",
+	})
+}
+
+func (s *snapshotSuite) TestDoSaveForget(c *check.C) {
+	info := mkInfo("a-snap", 1)
+	mkSnapData(c, info, "hello")
+
+	st := s.state
+	st.Lock()
+	config.SetSnapConfig(st, "a-snap", rawJSON(`{"hello":"there"}`))
+	_, _, ts, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"not-a-user"})
+	c.Assert(err, check.IsNil)
+	task := ts.Tasks()[0]
+	st.Unlock()
+
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	st.Lock()
+	var setup map[string]interface{}
+	c.Assert(task.Get("snapshot-setup", &setup), check.IsNil)
+	st.Unlock()
+	fn, _ := setup["filename"].(string)
+	c.Check(fn, check.Equals, filepath.Join(dirs.SnapshotsDir, "1_a-snap_v1.0_1.zip"))
+
+	reader, err := backend.Open(fn)
+	c.Assert(err, check.IsNil)
+	c.Check(reader.Conf, check.DeepEquals, map[string]interface{}{"hello": "there"})
+	c.Check(reader.Auto, check.Equals, false)
+	reader.Close()
+
+	// forget (which is also save's undo) removes the file
+	c.Assert(snapshotstate.DoForget(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(osutil.FileExists(fn), check.Equals, false)
+	// and doing it again is fine
+	c.Check(snapshotstate.DoForget(task, &tomb.Tomb{}), check.IsNil)
+}
+
+func (s *snapshotSuite) TestDoForgetError(c *check.C) {
+	restore := snapshotstate.MockOsRemove(func(string) error { return errors.New("bzzt") })
+	defer restore()
+
+	st := s.state
+	st.Lock()
+	task := st.NewTask("forget-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 1, "snap": "a-snap", "filename": "/some/file"})
+	st.Unlock()
+
+	c.Check(snapshotstate.DoForget(task, &tomb.Tomb{}), check.ErrorMatches, "cannot remove snapshot: bzzt")
+}
+
+func (s *snapshotSuite) TestDoCheck(c *check.C) {
+	shot := s.save(c, 1, "a-snap", "hello")
+
+	st := s.state
+	st.Lock()
+	task := st.NewTask("check-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 1, "snap": "a-snap", "filename": backend.Filename(shot)})
+	st.Unlock()
+
+	c.Check(snapshotstate.DoCheck(task, &tomb.Tomb{}), check.IsNil)
+}
+
+func (s *snapshotSuite) TestDoRestoreUndo(c *check.C) {
+	info := mkInfo("a-snap", 1)
+	shot := s.save(c, 1, "a-snap", "hello")
+	mkSnapData(c, info, "goodbye")
+
+	st := s.state
+	st.Lock()
+	config.SetSnapConfig(st, "a-snap", rawJSON(`{"what":"goodbye"}`))
+	task := st.NewTask("restore-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 1, "snap": "a-snap", "filename": backend.Filename(shot), "current": "1"})
+	st.Unlock()
+
+	c.Assert(snapshotstate.DoRestore(task, &tomb.Tomb{}), check.IsNil)
+	checkSnapData(c, info, "hello")
+
+	st.Lock()
+	cfg, err := config.GetSnapConfig(st, "a-snap")
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(string(*cfg), check.Equals, `{"what":"hello"}`)
+
+	c.Assert(snapshotstate.UndoRestore(task, &tomb.Tomb{}), check.IsNil)
+	checkSnapData(c, info, "goodbye")
+
+	st.Lock()
+	cfg, err = config.GetSnapConfig(st, "a-snap")
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(string(*cfg), check.Equals, `{"what":"goodbye"}`)
+}
+
+func (s *snapshotSuite) TestDoRestoreCleanup(c *check.C) {
+	info := mkInfo("a-snap", 1)
+	shot := s.save(c, 1, "a-snap", "hello")
+	mkSnapData(c, info, "goodbye")
+
+	st := s.state
+	st.Lock()
+	task := st.NewTask("restore-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{"set-id": 1, "snap": "a-snap", "filename": backend.Filename(shot), "current": "1"})
+	st.Unlock()
+
+	c.Assert(snapshotstate.DoRestore(task, &tomb.Tomb{}), check.IsNil)
+
+	st.Lock()
+	var rs backend.RestoreState
+	c.Assert(task.Get("restore-state", &rs), check.IsNil)
+	c.Assert(rs.Moved, check.HasLen, 2)
+	task.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	c.Assert(snapshotstate.CleanupRestore(task, &tomb.Tomb{}), check.IsNil)
+	for _, moved := range rs.Moved {
+		c.Check(osutil.FileExists(moved), check.Equals, false)
+	}
+	checkSnapData(c, info, "hello")
+}
+
+func rawJSON(s string) *json.RawMessage {
+	raw := json.RawMessage(s)
+	return &raw
+}
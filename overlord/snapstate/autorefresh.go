@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timeutil"
 )
@@ -43,6 +44,14 @@ var (
 // refreshRetryDelay specified the minimum time to retry failed refreshes
 var refreshRetryDelay = 10 * time.Minute
 
+// maxPostponement is the maximum time automatic refreshes can be postponed,
+// either by the refresh.hold setting or by a snap holding its own refresh
+var maxPostponement = 60 * 24 * time.Hour
+
+// snapRefreshHoldDuration is how long a single hold request from a snap
+// postpones its automatic refresh
+var snapRefreshHoldDuration = 24 * time.Hour
+
 // autoRefresh will ensure that snaps are refreshed automatically
 // according to the refresh schedule.
 type autoRefresh struct {
@@ -82,6 +91,45 @@ func (m *autoRefresh) LastRefresh() (time.Time, error) {
 	return lastRefresh, nil
 }
 
+// EffectiveRefreshHold returns the time until which automatic refreshes
+// are held, as set by refresh.hold but capped to at most maxPostponement
+// after the last refresh, or after the hold was first seen if there was
+// no refresh yet.
+func (m *autoRefresh) EffectiveRefreshHold() (time.Time, error) {
+	var holdTime time.Time
+
+	tr := config.NewTransaction(m.state)
+	err := tr.Get("core", "refresh.hold", &holdTime)
+	if err != nil && !config.IsNoOption(err) {
+		return time.Time{}, err
+	}
+	if holdTime.IsZero() {
+		return time.Time{}, nil
+	}
+
+	lastRefresh, err := m.LastRefresh()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if lastRefresh.IsZero() {
+		// never refreshed, cap from when the hold was first seen
+		err := m.state.Get("refresh-hold-first-seen", &lastRefresh)
+		if err == state.ErrNoState {
+			lastRefresh = time.Now()
+			m.state.Set("refresh-hold-first-seen", lastRefresh)
+		} else if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	// cannot hold beyond the maximum postponement
+	limitTime := lastRefresh.Add(maxPostponement)
+	if holdTime.After(limitTime) {
+		return limitTime, nil
+	}
+	return holdTime, nil
+}
+
 // Ensure ensures that we refresh all installed snaps periodically
 func (m *autoRefresh) Ensure() error {
 	m.state.Lock()
@@ -146,6 +194,16 @@ func (m *autoRefresh) Ensure() error {
 
 	// do refresh attempt (if needed)
 	if !m.nextRefresh.After(time.Now()) {
+		var holdTime time.Time
+		holdTime, err = m.EffectiveRefreshHold()
+		if err != nil {
+			return err
+		}
+		if holdTime.After(time.Now()) {
+			// refreshes are on hold, try again once the hold is over
+			return nil
+		}
+
 		err = m.launchAutoRefresh()
 		// clear nextRefresh only if the refresh worked. There is
 		// still the lastRefreshAttempt rate limit so things will
@@ -245,6 +303,19 @@ func (m *autoRefresh) launchAutoRefresh() error {
 	return nil
 }
 
+// EffectiveRefreshSchedule returns the schedule of the automatic
+// refreshes as set by refresh.timer or the legacy refresh.schedule,
+// falling back to the default. It returns no schedule if the refreshes
+// are managed via the snapd-control interface.
+func EffectiveRefreshSchedule(st *state.State) (ts []*timeutil.Schedule, scheduleStr string, err error) {
+	if refreshScheduleManaged(st) {
+		return nil, "managed", nil
+	}
+	m := autoRefresh{state: st}
+	ts, scheduleStr, _, err = m.refreshScheduleWithDefaultsFallback()
+	return ts, scheduleStr, err
+}
+
 func refreshScheduleDefault() (ts []*timeutil.Schedule, scheduleStr string, legacy bool, err error) {
 	refreshSchedule, err := timeutil.ParseSchedule(defaultRefreshSchedule)
 	if err != nil {
@@ -284,3 +355,130 @@ func refreshScheduleManaged(st *state.State) bool {
 
 	return CanManageRefreshes(st)
 }
+
+// snapRefreshHold records a snap holding its own automatic refresh.
+type snapRefreshHold struct {
+	// FirstHeld is when the snap started holding the refresh
+	FirstHeld time.Time `json:"first-held"`
+	// HoldUntil is when the hold is over
+	HoldUntil time.Time `json:"hold-until"`
+}
+
+func snapRefreshHolds(st *state.State) (map[string]*snapRefreshHold, error) {
+	var holds map[string]*snapRefreshHold
+	err := st.Get("refresh-holds", &holds)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if holds == nil {
+		holds = make(map[string]*snapRefreshHold)
+	}
+	return holds, nil
+}
+
+// HoldRefresh postpones the automatic refresh of the given snap, and
+// returns the time until which the refresh is held. Repeated holds extend
+// the hold, but a snap cannot hold its refresh for more than the maximum
+// postponement in total.
+func HoldRefresh(st *state.State, snapName string) (time.Time, error) {
+	holds, err := snapRefreshHolds(st)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now()
+	hold := holds[snapName]
+	if hold == nil {
+		hold = &snapRefreshHold{FirstHeld: now}
+	}
+
+	holdUntil := now.Add(snapRefreshHoldDuration)
+	if limitTime := hold.FirstHeld.Add(maxPostponement); holdUntil.After(limitTime) {
+		holdUntil = limitTime
+	}
+	if !holdUntil.After(now) {
+		return time.Time{}, fmt.Errorf("cannot hold refresh of snap %q any longer", snapName)
+	}
+	hold.HoldUntil = holdUntil
+
+	holds[snapName] = hold
+	st.Set("refresh-holds", holds)
+	return holdUntil, nil
+}
+
+// ProceedWithRefresh releases the hold of the given snap on its automatic
+// refresh, if any.
+func ProceedWithRefresh(st *state.State, snapName string) error {
+	holds, err := snapRefreshHolds(st)
+	if err != nil {
+		return err
+	}
+
+	hold := holds[snapName]
+	if hold == nil {
+		return nil
+	}
+	// the start of the hold is kept so that the snap cannot
+	// postpone beyond the maximum by holding again
+	hold.HoldUntil = time.Now()
+
+	st.Set("refresh-holds", holds)
+	return nil
+}
+
+// filterHeldSnaps drops the updates of snaps currently holding their
+// automatic refresh. Holds are kept until the snap gets refreshed, so
+// that the maximum postponement is counted from the first hold even if
+// the update goes away meanwhile.
+func filterHeldSnaps(st *state.State, updates []*snap.Info) ([]*snap.Info, error) {
+	holds, err := snapRefreshHolds(st)
+	if err != nil {
+		return nil, err
+	}
+	if len(holds) == 0 {
+		return updates, nil
+	}
+
+	now := time.Now()
+	filtered := make([]*snap.Info, 0, len(updates))
+	for _, update := range updates {
+		snapName := update.Name()
+		if hold := holds[snapName]; hold != nil && hold.HoldUntil.After(now) {
+			logger.Noticef("auto-refresh: snap %q is holding its refresh until %s", snapName, hold.HoldUntil.Format(time.RFC3339))
+			continue
+		}
+		filtered = append(filtered, update)
+	}
+
+	return filtered, nil
+}
+
+// clearRefreshHold forgets the hold of the given snap on its automatic
+// refresh, returning it, as done once the snap is refreshed.
+func clearRefreshHold(st *state.State, snapName string) (*snapRefreshHold, error) {
+	holds, err := snapRefreshHolds(st)
+	if err != nil {
+		return nil, err
+	}
+	hold := holds[snapName]
+	if hold == nil {
+		return nil, nil
+	}
+	delete(holds, snapName)
+	st.Set("refresh-holds", holds)
+	return hold, nil
+}
+
+// restoreRefreshHold puts back a hold forgotten by clearRefreshHold.
+func restoreRefreshHold(st *state.State, snapName string, hold *snapRefreshHold) error {
+	if hold == nil {
+		return nil
+	}
+	holds, err := snapRefreshHolds(st)
+	if err != nil {
+		return err
+	}
+	holds[snapName] = hold
+	st.Set("refresh-holds", holds)
+	return nil
+}
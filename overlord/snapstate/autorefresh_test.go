@@ -126,6 +126,39 @@ func (s *autoRefreshTestSuite) TestLastRefreshRefreshManaged(c *C) {
 	c.Check(af.NextRefresh(), DeepEquals, time.Time{})
 }
 
+func (s *autoRefreshTestSuite) TestEffectiveRefreshSchedule(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, scheduleStr, err := snapstate.EffectiveRefreshSchedule(s.state)
+	c.Assert(err, IsNil)
+	c.Check(scheduleStr, Equals, "00:00-24:00/4")
+	c.Check(ts, Not(HasLen), 0)
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "mon,10:00-12:00")
+	tr.Commit()
+
+	ts, scheduleStr, err = snapstate.EffectiveRefreshSchedule(s.state)
+	c.Assert(err, IsNil)
+	c.Check(scheduleStr, Equals, "mon,10:00-12:00")
+	c.Check(ts, HasLen, 1)
+
+	snapstate.CanManageRefreshes = func(st *state.State) bool {
+		return true
+	}
+	defer func() { snapstate.CanManageRefreshes = nil }()
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "")
+	tr.Set("core", "refresh.schedule", "managed")
+	tr.Commit()
+
+	ts, scheduleStr, err = snapstate.EffectiveRefreshSchedule(s.state)
+	c.Assert(err, IsNil)
+	c.Check(scheduleStr, Equals, "managed")
+	c.Check(ts, HasLen, 0)
+}
+
 func (s *autoRefreshTestSuite) TestLastRefreshNoRefreshNeeded(c *C) {
 	s.state.Lock()
 	s.state.Set("last-refresh", time.Now())
@@ -160,3 +193,167 @@ func (s *autoRefreshTestSuite) TestRefreshBackoff(c *C) {
 	c.Check(err, ErrorMatches, "random store error")
 	c.Check(s.store.ops, HasLen, 2)
 }
+
+func (s *autoRefreshTestSuite) TestRefreshHold(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.hold", time.Now().Add(24*time.Hour).Format(time.RFC3339))
+	tr.Commit()
+	s.state.Unlock()
+
+	af := snapstate.NewAutoRefresh(s.state)
+	err := af.Ensure()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+
+	// the next refresh is still computed
+	c.Check(af.NextRefresh().IsZero(), Equals, false)
+}
+
+func (s *autoRefreshTestSuite) TestRefreshHoldInThePast(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.hold", time.Now().Add(-time.Hour).Format(time.RFC3339))
+	tr.Commit()
+	s.state.Unlock()
+
+	af := snapstate.NewAutoRefresh(s.state)
+	err := af.Ensure()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+}
+
+func (s *autoRefreshTestSuite) TestEffectiveRefreshHold(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	af := snapstate.NewAutoRefresh(s.state)
+
+	// unset
+	holdTime, err := af.EffectiveRefreshHold()
+	c.Assert(err, IsNil)
+	c.Check(holdTime.IsZero(), Equals, true)
+
+	lastRefresh := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	s.state.Set("last-refresh", lastRefresh)
+
+	// within the maximum postponement
+	hold := lastRefresh.Add(10 * 24 * time.Hour)
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.hold", hold.Format(time.RFC3339))
+	tr.Commit()
+
+	holdTime, err = af.EffectiveRefreshHold()
+	c.Assert(err, IsNil)
+	c.Check(holdTime.Equal(hold), Equals, true)
+
+	// beyond the maximum postponement
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "refresh.hold", lastRefresh.Add(365*24*time.Hour).Format(time.RFC3339))
+	tr.Commit()
+
+	holdTime, err = af.EffectiveRefreshHold()
+	c.Assert(err, IsNil)
+	c.Check(holdTime.Equal(lastRefresh.Add(60*24*time.Hour)), Equals, true)
+}
+
+func (s *autoRefreshTestSuite) TestEffectiveRefreshHoldNeverRefreshed(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	af := snapstate.NewAutoRefresh(s.state)
+
+	// the device never refreshed and the hold is far in the future
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.hold", time.Now().Add(365*24*time.Hour).Format(time.RFC3339))
+	tr.Commit()
+
+	before := time.Now()
+	holdTime, err := af.EffectiveRefreshHold()
+	c.Assert(err, IsNil)
+	c.Check(holdTime.Before(before.Add(60*24*time.Hour)), Equals, false)
+	c.Check(holdTime.After(time.Now().Add(60*24*time.Hour)), Equals, false)
+
+	// the cap does not move forward with time
+	var firstSeen time.Time
+	c.Assert(s.state.Get("refresh-hold-first-seen", &firstSeen), IsNil)
+	s.state.Set("refresh-hold-first-seen", firstSeen.Add(-59*24*time.Hour))
+
+	holdTime, err = af.EffectiveRefreshHold()
+	c.Assert(err, IsNil)
+	c.Check(holdTime.Equal(firstSeen.Add(24*time.Hour)), Equals, true)
+	c.Check(holdTime.Before(time.Now().Add(2*24*time.Hour)), Equals, true)
+}
+
+func (s *autoRefreshTestSuite) TestHoldRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	before := time.Now()
+	holdUntil, err := snapstate.HoldRefresh(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(holdUntil.After(before.Add(24*time.Hour-time.Second)), Equals, true)
+	c.Check(holdUntil.Before(time.Now().Add(24*time.Hour+time.Second)), Equals, true)
+
+	// holding again extends the hold
+	again, err := snapstate.HoldRefresh(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(again.Before(holdUntil), Equals, false)
+
+	err = snapstate.ProceedWithRefresh(s.state, "some-snap")
+	c.Assert(err, IsNil)
+
+	updates := []*snap.Info{{SideInfo: snap.SideInfo{RealName: "some-snap"}}}
+	filtered, err := snapstate.FilterHeldSnaps(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(filtered, DeepEquals, updates)
+}
+
+func (s *autoRefreshTestSuite) TestHoldRefreshMaxPostponement(c *C) {
+	restore := snapstate.MockMaxPostponement(time.Hour)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	before := time.Now()
+	holdUntil, err := snapstate.HoldRefresh(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(holdUntil.Before(before.Add(time.Hour+time.Second)), Equals, true)
+
+	// proceeding and holding again does not restart the postponement
+	err = snapstate.ProceedWithRefresh(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	again, err := snapstate.HoldRefresh(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(again.Equal(holdUntil), Equals, true)
+
+	restore = snapstate.MockMaxPostponement(0)
+	defer restore()
+	_, err = snapstate.HoldRefresh(s.state, "some-snap")
+	c.Assert(err, ErrorMatches, `cannot hold refresh of snap "some-snap" any longer`)
+}
+
+func (s *autoRefreshTestSuite) TestFilterHeldSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.HoldRefresh(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	_, err = snapstate.HoldRefresh(s.state, "other-snap")
+	c.Assert(err, IsNil)
+
+	someSnap := &snap.Info{SideInfo: snap.SideInfo{RealName: "some-snap"}}
+	thirdSnap := &snap.Info{SideInfo: snap.SideInfo{RealName: "third-snap"}}
+
+	filtered, err := snapstate.FilterHeldSnaps(s.state, []*snap.Info{someSnap, thirdSnap})
+	c.Assert(err, IsNil)
+	c.Check(filtered, DeepEquals, []*snap.Info{thirdSnap})
+
+	// the hold of other-snap which has no pending update is kept
+	var holds map[string]interface{}
+	c.Assert(s.state.Get("refresh-holds", &holds), IsNil)
+	c.Check(holds, HasLen, 2)
+	c.Check(holds["some-snap"], NotNil)
+	c.Check(holds["other-snap"], NotNil)
+}
@@ -317,6 +317,8 @@ type fakeSnappyBackend struct {
 	linkSnapFailTrigger     string
 	copySnapDataFailTrigger string
 	emptyContainer          snap.Container
+
+	linked []*snap.Info
 }
 
 func (f *fakeSnappyBackend) OpenSnapFile(snapFilePath string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
@@ -456,6 +458,7 @@ func (f *fakeSnappyBackend) LinkSnap(info *snap.Info) error {
 		op:   "link-snap",
 		name: info.MountDir(),
 	})
+	f.linked = append(f.linked, info)
 	return nil
 }
 
@@ -59,6 +59,7 @@ func (m *SnapManager) AddForeignTaskHandlers(tracker ForeignTaskTracker) {
 	m.runner.AddHandler("setup-profiles", fakeHandler, fakeHandler)
 	m.runner.AddHandler("remove-profiles", fakeHandler, fakeHandler)
 	m.runner.AddHandler("discard-conns", fakeHandler, fakeHandler)
+	m.runner.AddHandler("auto-disconnect", fakeHandler, nil)
 	m.runner.AddHandler("validate-snap", fakeHandler, nil)
 	m.runner.AddHandler("transition-ubuntu-core", fakeHandler, nil)
 
@@ -141,12 +142,45 @@ var (
 	NewAutoRefresh    = newAutoRefresh
 	NewRefreshHints   = newRefreshHints
 	NewCatalogRefresh = newCatalogRefresh
+	FilterHeldSnaps   = filterHeldSnaps
+	FilterBusySnaps   = filterBusySnaps
 )
 
+// refresh app awareness
+var (
+	PidsOfSnap                        = pidsOfSnap
+	SoftCheckNothingRunningForRefresh = softCheckNothingRunningForRefresh
+	HardCheckNothingRunningForRefresh = hardCheckNothingRunningForRefresh
+)
+
+func MockPidsOfSnap(f func(snapName string) (map[string][]int, error)) func() {
+	old := pidsOfSnap
+	pidsOfSnap = f
+	return func() {
+		pidsOfSnap = old
+	}
+}
+
+func MockMaxInhibition(d time.Duration) func() {
+	old := maxInhibition
+	maxInhibition = d
+	return func() {
+		maxInhibition = old
+	}
+}
+
 func MockCatalogRefreshNextRefresh(cr *catalogRefresh, when time.Time) {
 	cr.nextCatalogRefresh = when
 }
 
+func MockMaxPostponement(d time.Duration) func() {
+	origMaxPostponement := maxPostponement
+	maxPostponement = d
+	return func() {
+		maxPostponement = origMaxPostponement
+	}
+}
+
 func MockRefreshRetryDelay(d time.Duration) func() {
 	origRefreshRetryDelay := refreshRetryDelay
 	refreshRetryDelay = d
@@ -154,3 +188,15 @@ func MockRefreshRetryDelay(d time.Duration) func() {
 		refreshRetryDelay = origRefreshRetryDelay
 	}
 }
+
+func MockQuotaGroupSlice(f func(st *state.State, snapName string) (string, error)) (restore func()) {
+	old := QuotaGroupSlice
+	QuotaGroupSlice = f
+	return func() { QuotaGroupSlice = old }
+}
+
+func MockRemoveSnapFromQuotaGroup(f func(st *state.State, snapName string) error) (restore func()) {
+	old := RemoveSnapFromQuotaGroup
+	RemoveSnapFromQuotaGroup = f
+	return func() { RemoveSnapFromQuotaGroup = old }
+}
@@ -192,7 +192,7 @@ func (m *SnapManager) doPrerequisites(t *state.Task, _ *tomb.Tomb) error {
 	if _, ok := err.(changeDuringInstallError); ok {
 		return &state.Retry{After: prerequisitesRetryTimeout}
 	}
-	if _, ok := err.(changeConflictError); ok {
+	if _, ok := err.(*ChangeConflictError); ok {
 		return &state.Retry{After: prerequisitesRetryTimeout}
 	}
 	if err != nil {
@@ -445,6 +445,20 @@ func (m *SnapManager) doUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	// automatic refreshes are postponed while the apps of the snap are
+	// running, within limits
+	if chg := t.Change(); chg != nil && chg.Kind() == "auto-refresh" {
+		now := time.Now()
+		if !refreshInhibitionExpired(snapst, now) {
+			if err := hardCheckNothingRunningForRefresh(oldInfo); err != nil {
+				if _, ok := err.(*BusySnapError); ok {
+					inhibitRefresh(st, snapsup.Name(), snapst, now)
+				}
+				return err
+			}
+		}
+	}
+
 	// Make a copy of configuration of given snap revision
 	if err = config.SaveRevisionConfig(st, snapsup.Name(), snapst.Current); err != nil {
 		return err
@@ -478,6 +492,10 @@ func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	if err := ApplyResourceOverrides(st, oldInfo); err != nil {
+		return err
+	}
+
 	snapst.Active = true
 	err = m.backend.LinkSnap(oldInfo)
 	if err != nil {
@@ -603,6 +621,9 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	snapst.JailMode = snapsup.JailMode
 	oldClassic := snapst.Classic
 	snapst.Classic = snapsup.Classic
+	// the snap is being refreshed, it is no longer inhibited
+	oldRefreshInhibitedTime := snapst.RefreshInhibitedTime
+	snapst.RefreshInhibitedTime = nil
 	if snapsup.Required { // set only on install and left alone on refresh
 		snapst.Required = true
 	}
@@ -632,6 +653,10 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	// record type
 	snapst.SetType(newInfo.Type)
 
+	if err := ApplyResourceOverrides(st, newInfo); err != nil {
+		return err
+	}
+
 	// XXX: this block is slightly ugly, find a pattern when we have more examples
 	err = m.backend.LinkSnap(newInfo)
 	if err != nil {
@@ -663,10 +688,17 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	t.Set("old-devmode", oldDevMode)
 	t.Set("old-jailmode", oldJailMode)
 	t.Set("old-classic", oldClassic)
+	t.Set("old-refresh-inhibited-time", oldRefreshInhibitedTime)
 	t.Set("old-ignore-validation", oldIgnoreValidation)
 	t.Set("old-channel", oldChannel)
 	t.Set("old-current", oldCurrent)
 	t.Set("old-candidate-index", oldCandidateIndex)
+	// the snap is refreshed, its hold on the refresh is over
+	oldRefreshHold, err := clearRefreshHold(st, snapsup.Name())
+	if err != nil {
+		return err
+	}
+	t.Set("old-refresh-hold", oldRefreshHold)
 	// Do at the end so we only preserve the new state if it worked.
 	Set(st, snapsup.Name(), snapst)
 	// Make sure if state commits and snapst is mutated we won't be rerun
@@ -733,6 +765,11 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	var oldRefreshInhibitedTime *time.Time
+	err = t.Get("old-refresh-inhibited-time", &oldRefreshInhibitedTime)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
 	var oldCurrent snap.Revision
 	err = t.Get("old-current", &oldCurrent)
 	if err != nil {
@@ -742,6 +779,11 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	if err := t.Get("old-candidate-index", &oldCandidateIndex); err != nil {
 		return err
 	}
+	var oldRefreshHold *snapRefreshHold
+	err = t.Get("old-refresh-hold", &oldRefreshHold)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
 
 	if len(snapst.Sequence) == 1 {
 		if err := m.removeSnapCookie(st, snapsup.Name()); err != nil {
@@ -772,6 +814,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	snapst.DevMode = oldDevMode
 	snapst.JailMode = oldJailMode
 	snapst.Classic = oldClassic
+	snapst.RefreshInhibitedTime = oldRefreshInhibitedTime
 
 	newInfo, err := readInfo(snapsup.Name(), snapsup.SideInfo)
 	if err != nil {
@@ -789,6 +832,10 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	if err := restoreRefreshHold(st, snapsup.Name(), oldRefreshHold); err != nil {
+		return err
+	}
+
 	// mark as inactive
 	Set(st, snapsup.Name(), snapst)
 	// Make sure if state commits and snapst is mutated we won't be rerun
@@ -1027,6 +1074,9 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		if err := m.removeSnapCookie(st, snapsup.Name()); err != nil {
 			return fmt.Errorf("cannot remove snap cookie: %v", err)
 		}
+		if err := RemoveSnapFromQuotaGroup(st, snapsup.Name()); err != nil {
+			return err
+		}
 	}
 	if err = config.DiscardRevisionConfig(st, snapsup.Name(), snapsup.Revision()); err != nil {
 		return err
@@ -278,6 +278,70 @@ func (s *linkSnapSuite) TestDoUndoLinkSnap(c *C) {
 	c.Check(t.Status(), Equals, state.UndoneStatus)
 }
 
+func (s *linkSnapSuite) TestDoLinkSnapClearsRefreshHold(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	_, err := snapstate.HoldRefresh(s.state, "foo")
+	c.Assert(err, IsNil)
+	_, err = snapstate.HoldRefresh(s.state, "bar")
+	c.Assert(err, IsNil)
+
+	si := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(33),
+	}
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+	})
+	s.state.NewChange("dummy", "...").AddTask(t)
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+	s.state.Lock()
+
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	var holds map[string]interface{}
+	c.Assert(s.state.Get("refresh-holds", &holds), IsNil)
+	c.Check(holds, HasLen, 1)
+	c.Check(holds["bar"], NotNil)
+}
+
+func (s *linkSnapSuite) TestDoUndoLinkSnapRestoresRefreshHold(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	_, err := snapstate.HoldRefresh(s.state, "foo")
+	c.Assert(err, IsNil)
+
+	si := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(33),
+	}
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+	})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	for i := 0; i < 3; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+	s.state.Lock()
+
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	var holds map[string]interface{}
+	c.Assert(s.state.Get("refresh-holds", &holds), IsNil)
+	c.Check(holds["foo"], NotNil)
+}
+
 func (s *linkSnapSuite) TestDoLinkSnapTryToCleanupOnError(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -447,6 +511,94 @@ func (s *linkSnapSuite) TestDoUndoLinkSnapSequenceHadCandidate(c *C) {
 	c.Check(t.Status(), Equals, state.UndoneStatus)
 }
 
+func (s *linkSnapSuite) TestDoUnlinkCurrentSnapBusyAutoRefresh(c *C) {
+	restore := snapstate.MockPidsOfSnap(func(snapName string) (map[string][]int, error) {
+		c.Check(snapName, Equals, "foo")
+		return map[string][]int{"": {42}}, nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(1),
+	}
+	si2 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(2),
+	}
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si1},
+		Current:  si1.Revision,
+		Active:   true,
+	})
+	t := s.state.NewTask("unlink-current-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si2,
+	})
+	chg := s.state.NewChange("auto-refresh", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	for i := 0; i < 3; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+
+	s.state.Lock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*snap "foo" has running processes.*`)
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "foo", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.RefreshInhibitedTime, NotNil)
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+}
+
+func (s *linkSnapSuite) TestDoUnlinkCurrentSnapBusyManualRefresh(c *C) {
+	restore := snapstate.MockPidsOfSnap(func(snapName string) (map[string][]int, error) {
+		return map[string][]int{"": {42}}, nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si1 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(1),
+	}
+	si2 := &snap.SideInfo{
+		RealName: "foo",
+		Revision: snap.R(2),
+	}
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si1},
+		Current:  si1.Revision,
+		Active:   true,
+	})
+	t := s.state.NewTask("unlink-current-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si2,
+	})
+	chg := s.state.NewChange("refresh-snap", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	for i := 0; i < 3; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+
+	s.state.Lock()
+	// only automatic refreshes are inhibited
+	c.Check(t.Status(), Equals, state.DoneStatus)
+}
+
 func (s *linkSnapSuite) TestDoUndoUnlinkCurrentSnapCore(c *C) {
 	restore := release.MockOnClassic(true)
 	defer restore()
@@ -0,0 +1,245 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// maxInhibition is the maximum time the automatic refresh of a snap can be
+// postponed because some of its apps are running
+var maxInhibition = 7 * 24 * time.Hour
+
+// BusySnapError indicates that a snap cannot be refreshed because some of
+// its apps are running.
+type BusySnapError struct {
+	SnapName string
+	// AppNames are the names of the running apps, if known
+	AppNames []string
+}
+
+func (err *BusySnapError) Error() string {
+	switch len(err.AppNames) {
+	case 0:
+		return fmt.Sprintf("snap %q has running processes", err.SnapName)
+	case 1:
+		return fmt.Sprintf("snap %q has running apps (%s)", err.SnapName, err.AppNames[0])
+	default:
+		return fmt.Sprintf("snap %q has running apps (%s)", err.SnapName, strings.Join(err.AppNames, ", "))
+	}
+}
+
+// pidsOfSnap returns the ids of the running processes of the given snap,
+// grouped by the security tag of the app or hook they belong to. Processes
+// that cannot be attributed are listed under the empty tag.
+var pidsOfSnap = func(snapName string) (map[string][]int, error) {
+	fname := filepath.Join(dirs.FreezerCgroupDir, fmt.Sprintf("snap.%s", snapName), "cgroup.procs")
+	data, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		// no freezer cgroup means no process of the snap has
+		// been started yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read processes of snap %q: %v", snapName, err)
+	}
+
+	pids := make(map[string][]int)
+	for _, field := range strings.Fields(string(data)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse pid %q of snap %q", field, snapName)
+		}
+		tag := securityTagOfPid(pid)
+		pids[tag] = append(pids[tag], pid)
+	}
+	return pids, nil
+}
+
+// securityTagOfPid returns the security tag of the given process of a snap,
+// taken from its systemd unit for services and from its AppArmor label
+// otherwise. The empty string is returned if it cannot be determined.
+func securityTagOfPid(pid int) string {
+	if tag := securityTagFromSystemdCgroup(pid); tag != "" {
+		return tag
+	}
+
+	data, err := ioutil.ReadFile(fmt.Sprintf("%s/proc/%d/attr/current", dirs.GlobalRootDir, pid))
+	if err != nil {
+		return ""
+	}
+	// eg. "snap.foo.app (enforce)"
+	label := strings.Fields(string(data))
+	if len(label) == 0 || !strings.HasPrefix(label[0], "snap.") {
+		return ""
+	}
+	return label[0]
+}
+
+func securityTagFromSystemdCgroup(pid int) string {
+	f, err := os.Open(fmt.Sprintf("%s/proc/%d/cgroup", dirs.GlobalRootDir, pid))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// we need to find a string like:
+		//   1:name=systemd:/system.slice/snap.foo.svc.service
+		// See cgroup(7) for details about the /proc/[pid]/cgroup
+		// format.
+		l := strings.SplitN(scanner.Text(), ":", 3)
+		if len(l) < 3 || l[1] != "name=systemd" {
+			continue
+		}
+		unit := filepath.Base(l[2])
+		if strings.HasPrefix(unit, "snap.") && strings.HasSuffix(unit, ".service") {
+			return strings.TrimSuffix(unit, ".service")
+		}
+		return ""
+	}
+	return ""
+}
+
+// genericRefreshCheck returns a BusySnapError if any of the processes of the
+// snap is running. Processes of apps for which ignoreApp returns true are
+// not considered, and neither, when ignoreUnknown is true, are processes
+// not belonging to any app of the snap.
+func genericRefreshCheck(info *snap.Info, ignoreApp func(app *snap.AppInfo) bool, ignoreUnknown bool) error {
+	pids, err := pidsOfSnap(info.Name())
+	if err != nil {
+		return err
+	}
+
+	appsByTag := make(map[string]*snap.AppInfo, len(info.Apps))
+	for _, app := range info.Apps {
+		appsByTag[app.SecurityTag()] = app
+	}
+
+	busy := false
+	var busyAppNames []string
+	for tag, tagPids := range pids {
+		if len(tagPids) == 0 {
+			continue
+		}
+		app := appsByTag[tag]
+		if app == nil {
+			if !ignoreUnknown {
+				busy = true
+			}
+			continue
+		}
+		if ignoreApp(app) {
+			continue
+		}
+		busy = true
+		busyAppNames = append(busyAppNames, app.Name)
+	}
+	if !busy {
+		return nil
+	}
+
+	sort.Strings(busyAppNames)
+	return &BusySnapError{SnapName: info.Name(), AppNames: busyAppNames}
+}
+
+// softCheckNothingRunningForRefresh checks that none of the apps of the snap
+// are running before a refresh is attempted. Services are not considered as
+// the refresh stops and restarts them.
+func softCheckNothingRunningForRefresh(info *snap.Info) error {
+	return genericRefreshCheck(info, func(app *snap.AppInfo) bool {
+		return app.IsService()
+	}, true)
+}
+
+// hardCheckNothingRunningForRefresh checks that no process of the snap is
+// running right before the snap is unlinked, at which point its services
+// have been stopped already.
+func hardCheckNothingRunningForRefresh(info *snap.Info) error {
+	return genericRefreshCheck(info, func(app *snap.AppInfo) bool {
+		return false
+	}, false)
+}
+
+// refreshInhibitionExpired returns whether the refresh of the snap has
+// been inhibited by its running apps for longer than maxInhibition, in
+// which case it goes ahead regardless.
+func refreshInhibitionExpired(snapst *SnapState, now time.Time) bool {
+	if snapst.RefreshInhibitedTime == nil {
+		return false
+	}
+	return now.Sub(*snapst.RefreshInhibitedTime) > maxInhibition
+}
+
+// inhibitRefresh records that the refresh of the snap is being postponed
+// because of running apps, keeping the time of the first postponement.
+func inhibitRefresh(st *state.State, snapName string, snapst *SnapState, now time.Time) {
+	if snapst.RefreshInhibitedTime == nil {
+		snapst.RefreshInhibitedTime = &now
+		Set(st, snapName, snapst)
+	}
+}
+
+// filterBusySnaps drops the updates of snaps that have apps running,
+// unless their refresh has been postponed for too long already.
+func filterBusySnaps(st *state.State, updates []*snap.Info) ([]*snap.Info, error) {
+	now := time.Now()
+	filtered := make([]*snap.Info, 0, len(updates))
+	for _, update := range updates {
+		snapName := update.Name()
+		var snapst SnapState
+		if err := Get(st, snapName, &snapst); err != nil {
+			return nil, err
+		}
+		if refreshInhibitionExpired(&snapst, now) {
+			filtered = append(filtered, update)
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return nil, err
+		}
+		if err := softCheckNothingRunningForRefresh(info); err != nil {
+			if _, ok := err.(*BusySnapError); !ok {
+				return nil, err
+			}
+			logger.Noticef("auto-refresh: postponing refresh of snap %q: %v", snapName, err)
+			inhibitRefresh(st, snapName, &snapst, now)
+			continue
+		}
+		filtered = append(filtered, update)
+	}
+
+	return filtered, nil
+}
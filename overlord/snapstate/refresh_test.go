@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type refreshSuite struct {
+	state *state.State
+	info  *snap.Info
+	pids  map[string][]int
+}
+
+var _ = Suite(&refreshSuite{})
+
+const refreshTestSnapYaml = `name: pkg
+version: 1
+apps:
+  app:
+    command: bin/app
+  daemon:
+    command: bin/daemon
+    daemon: simple
+`
+
+func (s *refreshSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	s.info = snaptest.MockSnap(c, refreshTestSnapYaml, &snap.SideInfo{Revision: snap.R(5)})
+	s.pids = nil
+}
+
+func (s *refreshSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *refreshSuite) mockPidsOfSnap() func() {
+	return snapstate.MockPidsOfSnap(func(snapName string) (map[string][]int, error) {
+		if snapName != s.info.Name() {
+			return nil, fmt.Errorf("unexpected snap %q", snapName)
+		}
+		return s.pids, nil
+	})
+}
+
+func writeFile(c *C, path, content string) {
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+}
+
+func (s *refreshSuite) TestPidsOfSnap(c *C) {
+	writeFile(c, filepath.Join(dirs.FreezerCgroupDir, "snap.pkg", "cgroup.procs"), "101\n102\n103\n")
+	// a service
+	writeFile(c, filepath.Join(dirs.GlobalRootDir, "/proc/101/cgroup"), "7:freezer:/snap.pkg\n1:name=systemd:/system.slice/snap.pkg.daemon.service\n")
+	// an app
+	writeFile(c, filepath.Join(dirs.GlobalRootDir, "/proc/102/cgroup"), "7:freezer:/snap.pkg\n1:name=systemd:/user.slice/user-1000.slice/session-2.scope\n")
+	writeFile(c, filepath.Join(dirs.GlobalRootDir, "/proc/102/attr/current"), "snap.pkg.app (enforce)\n")
+	// unknown
+	writeFile(c, filepath.Join(dirs.GlobalRootDir, "/proc/103/cgroup"), "7:freezer:/snap.pkg\n")
+
+	pids, err := snapstate.PidsOfSnap("pkg")
+	c.Assert(err, IsNil)
+	c.Check(pids, DeepEquals, map[string][]int{
+		"snap.pkg.daemon": {101},
+		"snap.pkg.app":    {102},
+		"":                {103},
+	})
+}
+
+func (s *refreshSuite) TestPidsOfSnapNoCgroup(c *C) {
+	pids, err := snapstate.PidsOfSnap("pkg")
+	c.Assert(err, IsNil)
+	c.Check(pids, HasLen, 0)
+}
+
+func (s *refreshSuite) TestPidsOfSnapBadPid(c *C) {
+	writeFile(c, filepath.Join(dirs.FreezerCgroupDir, "snap.pkg", "cgroup.procs"), "potato\n")
+
+	_, err := snapstate.PidsOfSnap("pkg")
+	c.Assert(err, ErrorMatches, `cannot parse pid "potato" of snap "pkg"`)
+}
+
+func (s *refreshSuite) TestSoftCheckNothingRunning(c *C) {
+	defer s.mockPidsOfSnap()()
+
+	// nothing running
+	c.Check(snapstate.SoftCheckNothingRunningForRefresh(s.info), IsNil)
+
+	// services and unknown processes are ignored
+	s.pids = map[string][]int{
+		"snap.pkg.daemon": {100},
+		"":                {101},
+	}
+	c.Check(snapstate.SoftCheckNothingRunningForRefresh(s.info), IsNil)
+
+	// apps are not
+	s.pids["snap.pkg.app"] = []int{102}
+	err := snapstate.SoftCheckNothingRunningForRefresh(s.info)
+	c.Assert(err, FitsTypeOf, &snapstate.BusySnapError{})
+	c.Check(err, ErrorMatches, `snap "pkg" has running apps \(app\)`)
+}
+
+func (s *refreshSuite) TestHardCheckNothingRunning(c *C) {
+	defer s.mockPidsOfSnap()()
+
+	// nothing running
+	c.Check(snapstate.HardCheckNothingRunningForRefresh(s.info), IsNil)
+
+	s.pids = map[string][]int{
+		"": {101},
+	}
+	err := snapstate.HardCheckNothingRunningForRefresh(s.info)
+	c.Check(err, ErrorMatches, `snap "pkg" has running processes`)
+
+	s.pids = map[string][]int{
+		"snap.pkg.daemon": {100},
+		"snap.pkg.app":    {102},
+	}
+	err = snapstate.HardCheckNothingRunningForRefresh(s.info)
+	c.Check(err, ErrorMatches, `snap "pkg" has running apps \(app, daemon\)`)
+}
+
+func (s *refreshSuite) TestFilterBusySnaps(c *C) {
+	defer s.mockPidsOfSnap()()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "pkg", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "pkg", Revision: snap.R(5)}},
+		Current:  snap.R(5),
+	})
+	updates := []*snap.Info{{SideInfo: snap.SideInfo{RealName: "pkg", Revision: snap.R(6)}}}
+
+	// not busy
+	filtered, err := snapstate.FilterBusySnaps(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(filtered, DeepEquals, updates)
+
+	// busy, the refresh is postponed
+	s.pids = map[string][]int{"snap.pkg.app": {102}}
+	filtered, err = snapstate.FilterBusySnaps(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(filtered, HasLen, 0)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "pkg", &snapst), IsNil)
+	c.Assert(snapst.RefreshInhibitedTime, NotNil)
+	inhibited := *snapst.RefreshInhibitedTime
+
+	// postponing again keeps the original time
+	filtered, err = snapstate.FilterBusySnaps(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(filtered, HasLen, 0)
+	c.Assert(snapstate.Get(s.state, "pkg", &snapst), IsNil)
+	c.Check(snapst.RefreshInhibitedTime.Equal(inhibited), Equals, true)
+
+	// but not forever
+	restore := snapstate.MockMaxInhibition(time.Nanosecond)
+	defer restore()
+	filtered, err = snapstate.FilterBusySnaps(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(filtered, DeepEquals, updates)
+}
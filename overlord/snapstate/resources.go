@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// QuotaGroupSlice returns the systemd slice of the quota group the snap
+// belongs to, or "" if it is in none. It is set by servicestate.
+var QuotaGroupSlice = func(st *state.State, snapName string) (string, error) {
+	return "", nil
+}
+
+// RemoveSnapFromQuotaGroup drops a snap that is being removed from the
+// quota group it belongs to, if any. It is set by servicestate.
+var RemoveSnapFromQuotaGroup = func(st *state.State, snapName string) error {
+	return nil
+}
+
+// ApplyResourceOverrides overrides the resource limits of the services
+// of the snap as set by administrators through the
+// resources.<snap>.<app>.<resource> core options, and places them in
+// the slice of the quota group of the snap.
+func ApplyResourceOverrides(st *state.State, info *snap.Info) error {
+	var overrides map[string]map[string]interface{}
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "resources."+info.Name(), &overrides)
+	if err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if err := snap.ApplyResourceOverrides(info, overrides); err != nil {
+		return err
+	}
+	info.QuotaSlice, err = QuotaGroupSlice(st, info.Name())
+	return err
+}
@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) setResourceOverrides(c *C, snapName string, overrides map[string]interface{}) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "resources."+snapName, overrides), IsNil)
+	tr.Commit()
+}
+
+func (s *snapmgrTestSuite) TestApplyResourceOverrides(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: foo
+version: 1.0
+apps:
+  svc:
+    daemon: simple
+    resources:
+      memory: 128M
+`))
+	c.Assert(err, IsNil)
+
+	// no overrides
+	c.Assert(snapstate.ApplyResourceOverrides(s.state, info), IsNil)
+	c.Check(info.Apps["svc"].Resources, DeepEquals, &snap.ResourceLimits{Memory: 128 << 20})
+
+	s.setResourceOverrides(c, "foo", map[string]interface{}{
+		"svc": map[string]interface{}{"memory": "0", "tasks": 8},
+	})
+	c.Assert(snapstate.ApplyResourceOverrides(s.state, info), IsNil)
+	c.Check(info.Apps["svc"].Resources, DeepEquals, &snap.ResourceLimits{Tasks: 8})
+}
+
+func (s *snapmgrTestSuite) TestUpdateLinksWithResourceOverrides(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setResourceOverrides(c, "services-snap", map[string]interface{}{
+		"svc1": map[string]interface{}{"cpu-quota": "20%"},
+	})
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "services-snap", SnapID: "services-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "services-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(s.fakeBackend.linked, HasLen, 1)
+	info := s.fakeBackend.linked[0]
+	c.Check(info.Revision, Equals, snap.R(11))
+	c.Check(info.Apps["svc1"].Resources, DeepEquals, &snap.ResourceLimits{CPUQuota: 20})
+	c.Check(info.Apps["svc2"].Resources, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestUpdateLinksInQuotaGroupSlice(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := snapstate.MockQuotaGroupSlice(func(st *state.State, snapName string) (string, error) {
+		if snapName == "services-snap" {
+			return "snap.telemetry.slice", nil
+		}
+		return "", nil
+	})
+	defer restore()
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "services-snap", SnapID: "services-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "services-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(s.fakeBackend.linked, HasLen, 1)
+	c.Check(s.fakeBackend.linked[0].QuotaSlice, Equals, "snap.telemetry.slice")
+}
+
+func (s *snapmgrTestSuite) TestRemoveDropsSnapFromQuotaGroup(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var removed []string
+	restore := snapstate.MockRemoveSnapFromQuotaGroup(func(st *state.State, snapName string) error {
+		removed = append(removed, snapName)
+		return nil
+	})
+	defer restore()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(3)},
+			{RealName: "some-snap", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	// removing an old revision keeps the snap in its group
+	chg := s.state.NewChange("remove", "remove a revision")
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(3), nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+	c.Assert(chg.Err(), IsNil)
+	c.Check(removed, HasLen, 0)
+
+	chg = s.state.NewChange("remove", "remove the snap")
+	ts, err = snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+	c.Assert(chg.Err(), IsNil)
+	c.Check(removed, DeepEquals, []string{"some-snap"})
+}
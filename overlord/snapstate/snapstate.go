@@ -22,6 +22,7 @@ package snapstate
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 
@@ -81,6 +82,12 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 		return nil, err
 	}
 
+	if CheckValidationSets != nil {
+		if err := CheckValidationSets(st, snapsup.Name(), snapsup.Revision()); err != nil {
+			return nil, err
+		}
+	}
+
 	// ensure core gets installed. if it is already installed return
 	// an empty task set
 	ts := state.NewTaskSet()
@@ -140,6 +147,12 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	}
 
 	if snapst.IsInstalled() {
+		// run the disconnect hooks of the connections that the new
+		// revision drops, while the current one is still active
+		autoDisconnect := st.NewTask("auto-disconnect", fmt.Sprintf(i18n.G("Disconnect interfaces of snap %q dropped by revision %s"), snapsup.Name(), targetRevision))
+		addTask(autoDisconnect)
+		prev = autoDisconnect
+
 		// unlink-current-snap (will stop services for copy-data)
 		stop := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q services"), snapsup.Name()))
 		addTask(stop)
@@ -206,6 +219,21 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	addTask(startSnapServices)
 	prev = startSnapServices
 
+	// watch refreshed snaps for a while if asked to, reverting them
+	// if they turn out to be unhealthy
+	if runRefreshHooks {
+		gracePeriod, err := watchdogGracePeriod(st)
+		if err != nil {
+			return nil, err
+		}
+		if gracePeriod > 0 {
+			watch := st.NewTask("watch-snap", fmt.Sprintf(i18n.G("Watch snap %q%s for %s"), snapsup.Name(), revisionStr, gracePeriod))
+			watch.Set("grace-period", gracePeriod)
+			addTask(watch)
+			prev = watch
+		}
+	}
+
 	// Do not do that if we are reverting to a local revision
 	if snapst.IsInstalled() && !snapsup.Flags.Revert {
 		seq := snapst.Sequence
@@ -262,9 +290,9 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	}
 
 	var confFlags int
-	if !snapst.IsInstalled() && snapsup.SideInfo != nil && snapsup.SideInfo.SnapID != "" {
+	if !snapst.IsInstalled() {
 		// installation, run configure using the gadget defaults
-		// if available
+		// if available and the defaults declared by the snap
 		confFlags |= UseConfigDefaults
 	}
 
@@ -307,6 +335,14 @@ var SetupRemoveHook = func(st *state.State, snapName string) *state.Task {
 	panic("internal error: snapstate.SetupRemoveHook is unset")
 }
 
+// ErrNothingToDo is returned by AutomaticSnapshot when no snapshot
+// needs to be taken, e.g. because automatic snapshots are disabled.
+var ErrNothingToDo = errors.New("nothing to do")
+
+var AutomaticSnapshot = func(st *state.State, snapName string) (*state.TaskSet, error) {
+	panic("internal error: snapstate.AutomaticSnapshot is unset")
+}
+
 // snapTopicalTasks are tasks that characterize changes on a snap that
 // cannot be run concurrently and should conflict with each other.
 var snapTopicalTasks = map[string]bool{
@@ -325,6 +361,19 @@ var snapTopicalTasks = map[string]bool{
 	"disconnect":          true,
 }
 
+// AffectedSnapsFunc returns the names of the snaps a task of a
+// given kind operates on, for conflict detection purposes.
+type AffectedSnapsFunc func(*state.Task) ([]string, error)
+
+var affectedSnapsByKind = make(map[string]AffectedSnapsFunc)
+
+// AddAffectedSnapsByKind registers an AffectedSnapsFunc for returning
+// the affected snaps for tasks of the given kind, so that they are
+// considered by the conflict detection.
+func AddAffectedSnapsByKind(kind string, f AffectedSnapsFunc) {
+	affectedSnapsByKind[kind] = f
+}
+
 func getPlugAndSlotRefs(task *state.Task) (*interfaces.PlugRef, *interfaces.SlotRef, error) {
 	var plugRef interfaces.PlugRef
 	var slotRef interfaces.SlotRef
@@ -337,16 +386,31 @@ func getPlugAndSlotRefs(task *state.Task) (*interfaces.PlugRef, *interfaces.Slot
 	return &plugRef, &slotRef, nil
 }
 
-type changeConflictError struct {
-	snapName   string
-	changeKind string
+// ChangeConflictError is returned when an operation on a snap cannot
+// proceed because another change affecting the snap is in progress.
+type ChangeConflictError struct {
+	Snap       string
+	ChangeKind string
+	// ChangeID is the id of the conflicting change, if known.
+	ChangeID string
 }
 
-func (e changeConflictError) Error() string {
-	if e.changeKind != "" {
-		return fmt.Sprintf("snap %q has %q change in progress", e.snapName, e.changeKind)
+func (e *ChangeConflictError) Error() string {
+	if e.ChangeKind != "" {
+		return fmt.Sprintf("snap %q has %q change in progress", e.Snap, e.ChangeKind)
+	}
+	return fmt.Sprintf("snap %q has changes in progress", e.Snap)
+}
+
+func newChangeConflictError(snapName string, chg *state.Change) error {
+	if chg == nil {
+		return &ChangeConflictError{Snap: snapName}
+	}
+	return &ChangeConflictError{
+		Snap:       snapName,
+		ChangeKind: chg.Kind(),
+		ChangeID:   chg.ID(),
 	}
-	return fmt.Sprintf("snap %q has changes in progress", e.snapName)
 }
 
 // CheckChangeConflictMany ensures that for the given snapNames no other
@@ -386,7 +450,7 @@ func CheckChangeConflictMany(st *state.State, snapNames []string, checkConflictP
 					} else {
 						snapName = slotRef.Snap
 					}
-					return changeConflictError{snapName, chg.Kind()}
+					return newChangeConflictError(snapName, chg)
 				}
 			} else {
 				snapsup, err := TaskSnapSetup(task)
@@ -395,7 +459,17 @@ func CheckChangeConflictMany(st *state.State, snapNames []string, checkConflictP
 				}
 				snapName := snapsup.Name()
 				if (snapMap[snapName]) && (checkConflictPredicate == nil || checkConflictPredicate(task)) {
-					return changeConflictError{snapName, chg.Kind()}
+					return newChangeConflictError(snapName, chg)
+				}
+			}
+		} else if f := affectedSnapsByKind[k]; f != nil && (chg == nil || !chg.Status().Ready()) {
+			affectedSnaps, err := f(task)
+			if err != nil {
+				return fmt.Errorf("internal error: cannot obtain affected snaps from task: %s", task.Summary())
+			}
+			for _, snapName := range affectedSnaps {
+				if snapMap[snapName] && (checkConflictPredicate == nil || checkConflictPredicate(task)) {
+					return newChangeConflictError(snapName, chg)
 				}
 			}
 		}
@@ -539,9 +613,45 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 	return doInstall(st, &snapst, snapsup, needsMaybeCore(info.Type))
 }
 
+// TransactionType specifies how failures are handled when operating
+// on multiple snaps at once.
+type TransactionType string
+
+const (
+	// TransactionPerSnap undoes only the snap whose operation failed,
+	// the other snaps are left with the operation applied.
+	TransactionPerSnap TransactionType = "per-snap"
+	// TransactionAllSnaps undoes the operation on all the snaps
+	// involved if it fails for any of them.
+	TransactionAllSnaps TransactionType = "all-snaps"
+)
+
+// laneMaker returns a function that provides the lane the task set
+// of each snap should join according to the given transaction type.
+func laneMaker(st *state.State, transaction TransactionType) func() int {
+	if transaction != TransactionAllSnaps {
+		return st.NewLane
+	}
+	lane := 0
+	return func() int {
+		if lane == 0 {
+			lane = st.NewLane()
+		}
+		return lane
+	}
+}
+
 // InstallMany installs everything from the given list of names.
 // Note that the state must be locked by the caller.
 func InstallMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	return InstallManyWithTransaction(st, names, userID, TransactionPerSnap)
+}
+
+// InstallManyWithTransaction is like InstallMany but lets the caller
+// choose how failures in the installation of one snap affect the
+// others.
+func InstallManyWithTransaction(st *state.State, names []string, userID int, transaction TransactionType) ([]string, []*state.TaskSet, error) {
+	newLane := laneMaker(st, transaction)
 	installed := make([]string, 0, len(names))
 	tasksets := make([]*state.TaskSet, 0, len(names))
 	for _, name := range names {
@@ -554,7 +664,7 @@ func InstallMany(st *state.State, names []string, userID int) ([]string, []*stat
 			return nil, nil, err
 		}
 		installed = append(installed, name)
-		ts.JoinLane(st.NewLane())
+		ts.JoinLane(newLane())
 		tasksets = append(tasksets, ts)
 	}
 
@@ -568,6 +678,11 @@ func RefreshCandidates(st *state.State, user *auth.UserState) ([]*snap.Info, err
 	return updates, err
 }
 
+// CheckValidationSets allows to hook checking that installing or
+// refreshing a snap to the given revision, or removing it if the
+// revision is unset, does not violate the enforced validation sets.
+var CheckValidationSets func(st *state.State, snapName string, rev snap.Revision) error
+
 // ValidateRefreshes allows to hook validation into the handling of refresh candidates.
 var ValidateRefreshes func(st *state.State, refreshes []*snap.Info, ignoreValidation map[string]bool, userID int) (validated []*snap.Info, err error)
 
@@ -575,6 +690,19 @@ var ValidateRefreshes func(st *state.State, refreshes []*snap.Info, ignoreValida
 // store says is updateable. If the list is empty, update everything.
 // Note that the state must be locked by the caller.
 func UpdateMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	return updateManyFiltered(st, names, userID, nil, TransactionPerSnap)
+}
+
+// UpdateManyWithTransaction is like UpdateMany but lets the caller
+// choose how failures in the update of one snap affect the others.
+func UpdateManyWithTransaction(st *state.State, names []string, userID int, transaction TransactionType) ([]string, []*state.TaskSet, error) {
+	return updateManyFiltered(st, names, userID, nil, transaction)
+}
+
+// updateFilter can drop some of the updates found for a refresh.
+type updateFilter func(st *state.State, updates []*snap.Info) ([]*snap.Info, error)
+
+func updateManyFiltered(st *state.State, names []string, userID int, filter updateFilter, transaction TransactionType) ([]string, []*state.TaskSet, error) {
 	user, err := userFromUserID(st, userID)
 	if err != nil {
 		return nil, nil, err
@@ -597,17 +725,25 @@ func UpdateMany(st *state.State, names []string, userID int) ([]string, []*state
 		}
 	}
 
+	if filter != nil {
+		updates, err = filter(st, updates)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	params := func(update *snap.Info) (string, Flags, *SnapState) {
 		snapst := stateByID[update.SnapID]
 		return snapst.Channel, snapst.Flags, snapst
 
 	}
 
-	return doUpdate(st, names, updates, params, userID)
+	return doUpdate(st, names, updates, params, userID, transaction)
 }
 
-func doUpdate(st *state.State, names []string, updates []*snap.Info, params func(*snap.Info) (channel string, flags Flags, snapst *SnapState), userID int) ([]string, []*state.TaskSet, error) {
+func doUpdate(st *state.State, names []string, updates []*snap.Info, params func(*snap.Info) (channel string, flags Flags, snapst *SnapState), userID int, transaction TransactionType) ([]string, []*state.TaskSet, error) {
 	tasksets := make([]*state.TaskSet, 0, len(updates))
+	newLane := laneMaker(st, transaction)
 
 	refreshAll := len(names) == 0
 	var nameSet map[string]bool
@@ -636,6 +772,9 @@ func doUpdate(st *state.State, names []string, updates []*snap.Info, params func
 		if err != nil {
 			return nil, nil, err
 		}
+		if transaction == TransactionAllSnaps {
+			pruningAutoAliasesTs.JoinLane(newLane())
+		}
 		tasksets = append(tasksets, pruningAutoAliasesTs)
 	}
 
@@ -680,7 +819,7 @@ func doUpdate(st *state.State, names []string, updates []*snap.Info, params func
 			}
 			return nil, nil, err
 		}
-		ts.JoinLane(st.NewLane())
+		ts.JoinLane(newLane())
 
 		scheduleUpdate(update.Name(), ts)
 		tasksets = append(tasksets, ts)
@@ -691,6 +830,9 @@ func doUpdate(st *state.State, names []string, updates []*snap.Info, params func
 		if err != nil {
 			return nil, nil, err
 		}
+		if transaction == TransactionAllSnaps {
+			addAutoAliasesTs.JoinLane(newLane())
+		}
 		tasksets = append(tasksets, addAutoAliasesTs)
 	}
 
@@ -887,7 +1029,7 @@ func Update(st *state.State, name, channel string, revision snap.Revision, userI
 		return channel, flags, &snapst
 	}
 
-	_, tts, err := doUpdate(st, []string{name}, updates, params, userID)
+	_, tts, err := doUpdate(st, []string{name}, updates, params, userID, TransactionPerSnap)
 	if err != nil {
 		return nil, err
 	}
@@ -1000,7 +1142,17 @@ func AutoRefresh(st *state.State) ([]string, []*state.TaskSet, error) {
 		}
 	}
 
-	return UpdateMany(st, nil, userID)
+	return updateManyFiltered(st, nil, userID, autoRefreshFilter, TransactionPerSnap)
+}
+
+// autoRefreshFilter drops the updates of snaps holding their refresh or
+// busy running apps.
+func autoRefreshFilter(st *state.State, updates []*snap.Info) ([]*snap.Info, error) {
+	updates, err := filterHeldSnaps(st, updates)
+	if err != nil {
+		return nil, err
+	}
+	return filterBusySnaps(st, updates)
 }
 
 // Enable sets a snap to the active state
@@ -1163,9 +1315,15 @@ func canRemove(si *snap.Info, snapst *SnapState, removeAll bool) bool {
 	return true
 }
 
+// RemoveFlags are used to pass additional flags to the Remove operation.
+type RemoveFlags struct {
+	// Purge removes the snap without saving a snapshot of its data
+	Purge bool
+}
+
 // Remove returns a set of tasks for removing snap.
 // Note that the state must be locked by the caller.
-func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSet, error) {
+func Remove(st *state.State, name string, revision snap.Revision, flags *RemoveFlags) (*state.TaskSet, error) {
 	var snapst SnapState
 	err := Get(st, name, &snapst)
 	if err != nil && err != state.ErrNoState {
@@ -1214,6 +1372,12 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		return nil, fmt.Errorf("snap %q is not removable", name)
 	}
 
+	if removeAll && CheckValidationSets != nil {
+		if err := CheckValidationSets(st, name, snap.Revision{}); err != nil {
+			return nil, err
+		}
+	}
+
 	// main/current SnapSetup
 	snapsup := SnapSetup{
 		SideInfo: &snap.SideInfo{
@@ -1249,6 +1413,17 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		prev = stopSnapServices
 
 		tasks := []*state.Task{stopSnapServices}
+
+		// run the disconnect hooks of all the connections of the snap
+		// while it is still available, and before its remove hook
+		if removeAll {
+			autoDisconnect := st.NewTask("auto-disconnect", fmt.Sprintf(i18n.G("Disconnect interfaces of snap %q"), name))
+			autoDisconnect.Set("snap-setup-task", stopSnapServices.ID())
+			autoDisconnect.WaitFor(prev)
+			tasks = append(tasks, autoDisconnect)
+			prev = autoDisconnect
+		}
+
 		if removeHook != nil {
 			tasks = append(tasks, removeHook)
 			removeHook.WaitFor(prev)
@@ -1273,6 +1448,20 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		addNext(state.NewTaskSet(removeHook))
 	}
 
+	// keep a copy of the data of apps that are being removed
+	// completely, unless asked to purge it
+	if removeAll && info.Type == snap.TypeApp && (flags == nil || !flags.Purge) {
+		ts, err := AutomaticSnapshot(st, name)
+		switch err {
+		case nil:
+			addNext(ts)
+		case ErrNothingToDo:
+			// automatic snapshots are disabled
+		default:
+			return nil, err
+		}
+	}
+
 	if removeAll {
 		seq := snapst.Sequence
 		for i := len(seq) - 1; i >= 0; i-- {
@@ -1314,11 +1503,11 @@ func removeInactiveRevision(st *state.State, name string, revision snap.Revision
 
 // RemoveMany removes everything from the given list of names.
 // Note that the state must be locked by the caller.
-func RemoveMany(st *state.State, names []string) ([]string, []*state.TaskSet, error) {
+func RemoveMany(st *state.State, names []string, flags *RemoveFlags) ([]string, []*state.TaskSet, error) {
 	removed := make([]string, 0, len(names))
 	tasksets := make([]*state.TaskSet, 0, len(names))
 	for _, name := range names {
-		ts, err := Remove(st, name, snap.R(0))
+		ts, err := Remove(st, name, snap.R(0), flags)
 		// FIXME: is this expected behavior?
 		if _, ok := err.(*snap.NotInstalledError); ok {
 			continue
@@ -1455,7 +1644,7 @@ func TransitionCore(st *state.State, oldName, newName string) ([]*state.TaskSet,
 	})
 
 	// then remove the old snap
-	tsRm, err := Remove(st, oldName, snap.R(0))
+	tsRm, err := Remove(st, oldName, snap.R(0), nil)
 	if err != nil {
 		return nil, err
 	}
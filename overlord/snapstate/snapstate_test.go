@@ -97,10 +97,15 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 	oldSetupPreRefreshHook := snapstate.SetupPreRefreshHook
 	oldSetupPostRefreshHook := snapstate.SetupPostRefreshHook
 	oldSetupRemoveHook := snapstate.SetupRemoveHook
+	oldAutomaticSnapshot := snapstate.AutomaticSnapshot
 	snapstate.SetupInstallHook = hookstate.SetupInstallHook
 	snapstate.SetupPreRefreshHook = hookstate.SetupPreRefreshHook
 	snapstate.SetupPostRefreshHook = hookstate.SetupPostRefreshHook
 	snapstate.SetupRemoveHook = hookstate.SetupRemoveHook
+	// by default, no automatic snapshots are taken on remove
+	snapstate.AutomaticSnapshot = func(*state.State, string) (*state.TaskSet, error) {
+		return nil, snapstate.ErrNothingToDo
+	}
 
 	var err error
 	s.snapmgr, err = snapstate.Manager(s.state)
@@ -119,6 +124,7 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 		snapstate.SetupPreRefreshHook = oldSetupPreRefreshHook
 		snapstate.SetupPostRefreshHook = oldSetupPostRefreshHook
 		snapstate.SetupRemoveHook = oldSetupRemoveHook
+		snapstate.AutomaticSnapshot = oldAutomaticSnapshot
 
 		dirs.SetRootDir("/")
 	})
@@ -157,6 +163,7 @@ func (s *snapmgrTestSuite) TestKnownTaskKinds(c *C) {
 	sort.Strings(kinds)
 	c.Assert(kinds, DeepEquals, []string{
 		"alias",
+		"auto-disconnect",
 		"cleanup",
 		"clear-aliases",
 		"clear-snap",
@@ -190,7 +197,8 @@ func (s *snapmgrTestSuite) TestKnownTaskKinds(c *C) {
 		"unalias",
 		"unlink-current-snap",
 		"unlink-snap",
-		"validate-snap"})
+		"validate-snap",
+		"watch-snap"})
 }
 
 func (s *snapmgrTestSuite) TestStore(c *C) {
@@ -318,6 +326,7 @@ func verifyUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *state.St
 	expected = append(expected, "run-hook[pre-refresh]")
 	if opts&unlinkBefore != 0 {
 		expected = append(expected,
+			"auto-disconnect",
 			"stop-snap-services",
 		)
 	}
@@ -363,6 +372,7 @@ func verifyUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *state.St
 func verifyRemoveTasks(c *C, ts *state.TaskSet) {
 	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 		"stop-snap-services",
+		"auto-disconnect",
 		"run-hook[remove]",
 		"remove-aliases",
 		"unlink-snap",
@@ -447,6 +457,23 @@ func (s *snapmgrTestSuite) TestInstallTasks(c *C) {
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 }
 
+func (s *snapmgrTestSuite) TestInstallCheckValidationSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var checked []string
+	snapstate.CheckValidationSets = func(st *state.State, snapName string, rev snap.Revision) error {
+		checked = append(checked, fmt.Sprintf("%s:%s", snapName, rev))
+		return fmt.Errorf("cannot install snap %q: not allowed", snapName)
+	}
+	defer func() { snapstate.CheckValidationSets = nil }()
+
+	_, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot install snap "some-snap": not allowed`)
+	c.Check(checked, DeepEquals, []string{"some-snap:11"})
+	c.Check(s.state.TaskCount(), Equals, 0)
+}
+
 func (s *snapmgrTestSuite) TestInstallHookNotRunForInstalledSnap(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -520,6 +547,7 @@ func (s *snapmgrTestSuite) testRevertTasksFullFlags(flags fullFlags, c *C) {
 	c.Assert(taskKinds(tasks), DeepEquals, []string{
 		"prerequisites",
 		"prepare-snap",
+		"auto-disconnect",
 		"stop-snap-services",
 		"remove-aliases",
 		"unlink-current-snap",
@@ -698,6 +726,40 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 }
 
+func (s *snapmgrTestSuite) TestUpdateManyTransactionAllSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(5)},
+		},
+		Current:  snap.R(5),
+		SnapType: "app",
+	})
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "services-snap", SnapID: "services-snap-id", Revision: snap.R(2)},
+		},
+		Current:  snap.R(2),
+		SnapType: "app",
+	})
+
+	updates, tts, err := snapstate.UpdateManyWithTransaction(s.state, []string{"some-snap", "services-snap"}, 0, snapstate.TransactionAllSnaps)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	c.Check(updates, HasLen, 2)
+
+	// check that all tasks share a single lane
+	for _, ts := range tts {
+		for _, t := range ts.Tasks() {
+			c.Assert(t.Lanes(), DeepEquals, []int{1})
+		}
+	}
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyDevModeConfinementFiltering(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -894,6 +956,7 @@ func (s *snapmgrTestSuite) TestRevertCreatesNoGCTasks(c *C) {
 	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 		"prerequisites",
 		"prepare-snap",
+		"auto-disconnect",
 		"stop-snap-services",
 		"remove-aliases",
 		"unlink-current-snap",
@@ -1018,10 +1081,16 @@ func (s *snapmgrTestSuite) TestEnableConflict(c *C) {
 	ts, err := snapstate.Enable(s.state, "some-snap")
 	c.Assert(err, IsNil)
 	// need a change to make the tasks visible
-	s.state.NewChange("enable", "...").AddAll(ts)
+	chg := s.state.NewChange("enable", "...")
+	chg.AddAll(ts)
 
 	_, err = snapstate.Enable(s.state, "some-snap")
 	c.Assert(err, ErrorMatches, `snap "some-snap" has "enable" change in progress`)
+	c.Check(err, DeepEquals, &snapstate.ChangeConflictError{
+		Snap:       "some-snap",
+		ChangeKind: "enable",
+		ChangeID:   chg.ID(),
+	})
 }
 
 func (s *snapmgrTestSuite) TestDisableConflict(c *C) {
@@ -1500,13 +1569,162 @@ func (s *snapmgrTestSuite) TestRemoveTasks(c *C) {
 		Current: snap.R(11),
 	})
 
-	ts, err := snapstate.Remove(s.state, "foo", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0), nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
+	verifyRemoveTasks(c, ts)
+}
+
+func (s *snapmgrTestSuite) TestRemoveCheckValidationSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(7)},
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	var checked []string
+	snapstate.CheckValidationSets = func(st *state.State, snapName string, rev snap.Revision) error {
+		checked = append(checked, fmt.Sprintf("%s:%s", snapName, rev))
+		return fmt.Errorf("cannot remove snap %q: required", snapName)
+	}
+	defer func() { snapstate.CheckValidationSets = nil }()
+
+	// removing an old revision is fine
+	_, err := snapstate.Remove(s.state, "foo", snap.R(7), nil)
+	c.Assert(err, IsNil)
+	c.Check(checked, HasLen, 0)
+
+	_, err = snapstate.Remove(s.state, "foo", snap.R(0), nil)
+	c.Assert(err, ErrorMatches, `cannot remove snap "foo": required`)
+	c.Check(checked, DeepEquals, []string{"foo:unset"})
+}
+
+func (s *snapmgrTestSuite) mockAutomaticSnapshot(c *C) (calls *int) {
+	var n int
+	snapstate.AutomaticSnapshot = func(st *state.State, snapName string) (*state.TaskSet, error) {
+		n++
+		c.Check(snapName, Equals, "foo")
+		task := st.NewTask("save-snapshot", "...")
+		return state.NewTaskSet(task), nil
+	}
+	return &n
+}
+
+func (s *snapmgrTestSuite) TestRemoveTasksAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	calls := s.mockAutomaticSnapshot(c)
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0), nil)
 	c.Assert(err, IsNil)
+	c.Check(*calls, Equals, 1)
 
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
+	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"stop-snap-services",
+		"auto-disconnect",
+		"run-hook[remove]",
+		"remove-aliases",
+		"unlink-snap",
+		"remove-profiles",
+		"save-snapshot",
+		"clear-snap",
+		"discard-snap",
+		"discard-conns",
+	})
+
+	// the snapshot is taken after the snap is unlinked, and before
+	// its data is removed
+	snapshot := tasksWithKind(ts, "save-snapshot")[0]
+	c.Check(taskKinds(snapshot.WaitTasks()), DeepEquals, []string{
+		"stop-snap-services",
+		"auto-disconnect",
+		"run-hook[remove]",
+		"remove-aliases",
+		"unlink-snap",
+		"remove-profiles",
+	})
+	clearSnap := tasksWithKind(ts, "clear-snap")[0]
+	c.Check(clearSnap.WaitTasks(), DeepEquals, []*state.Task{snapshot})
+}
+
+func (s *snapmgrTestSuite) TestRemoveTasksAutomaticSnapshotPurge(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	calls := s.mockAutomaticSnapshot(c)
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0), &snapstate.RemoveFlags{Purge: true})
+	c.Assert(err, IsNil)
+	c.Check(*calls, Equals, 0)
 	verifyRemoveTasks(c, ts)
 }
 
+func (s *snapmgrTestSuite) TestRemoveTasksAutomaticSnapshotOnlyWhenRemovingAll(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	calls := s.mockAutomaticSnapshot(c)
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+			{RealName: "foo", Revision: snap.R(12)},
+		},
+		Current: snap.R(12),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(11), nil)
+	c.Assert(err, IsNil)
+	c.Check(*calls, Equals, 0)
+	c.Check(tasksWithKind(ts, "save-snapshot"), HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestRemoveTasksAutomaticSnapshotError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.AutomaticSnapshot = func(*state.State, string) (*state.TaskSet, error) {
+		return nil, errors.New("boom")
+	}
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	_, err := snapstate.Remove(s.state, "foo", snap.R(0), nil)
+	c.Assert(err, ErrorMatches, "boom")
+}
+
 func (s *snapmgrTestSuite) TestRemoveHookNotExecutedIfNotLastRevison(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -1520,7 +1738,7 @@ func (s *snapmgrTestSuite) TestRemoveHookNotExecutedIfNotLastRevison(c *C) {
 		Current: snap.R(12),
 	})
 
-	ts, err := snapstate.Remove(s.state, "foo", snap.R(11))
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(11), nil)
 	c.Assert(err, IsNil)
 
 	runHooks := tasksWithKind(ts, "run-hook")
@@ -1538,12 +1756,12 @@ func (s *snapmgrTestSuite) TestRemoveConflict(c *C) {
 		Current:  snap.R(11),
 	})
 
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
 	c.Assert(err, IsNil)
 	// need a change to make the tasks visible
 	s.state.NewChange("remove", "...").AddAll(ts)
 
-	_, err = snapstate.Remove(s.state, "some-snap", snap.R(0))
+	_, err = snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
 	c.Assert(err, ErrorMatches, `snap "some-snap" has "remove" change in progress`)
 }
 
@@ -1772,6 +1990,11 @@ func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 			name:  filepath.Join(dirs.SnapBlobDir, "services-snap_11.snap"),
 			revno: snap.R(11),
 		},
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "services-snap",
+			revno: snap.R(11),
+		},
 		{
 			op:   "stop-snap-services",
 			name: filepath.Join(dirs.SnapMountDir, "services-snap/7"),
@@ -1858,7 +2081,7 @@ func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 	})
 
 	// check post-refresh hook
-	task = ts.Tasks()[13]
+	task = ts.Tasks()[14]
 	c.Assert(task.Kind(), Equals, "run-hook")
 	c.Assert(task.Summary(), Matches, `Run post-refresh hook of "services-snap" snap if present`)
 
@@ -2217,6 +2440,11 @@ func (s *snapmgrTestSuite) TestUpdateUndoRunThrough(c *C) {
 			name:  filepath.Join(dirs.SnapBlobDir, "some-snap_11.snap"),
 			revno: snap.R(11),
 		},
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(11),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -2377,6 +2605,11 @@ func (s *snapmgrTestSuite) TestUpdateTotalUndoRunThrough(c *C) {
 			name:  filepath.Join(dirs.SnapBlobDir, "some-snap_11.snap"),
 			revno: snap.R(11),
 		},
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(11),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -3365,7 +3598,7 @@ func (s *snapmgrTestSuite) TestUpdateOneAutoAliasesScenarios(c *C) {
 		}
 		if scenario.update {
 			first := tasks[j]
-			j += 17
+			j += 18
 			c.Check(first.Kind(), Equals, "prerequisites")
 			wait := false
 			if expectedPruned["other-snap"]["aliasA"] {
@@ -3823,7 +4056,7 @@ func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -3833,6 +4066,11 @@ func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(7),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -3933,7 +4171,7 @@ func (s *snapmgrTestSuite) TestRemoveWithManyRevisionsRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -3943,6 +4181,11 @@ func (s *snapmgrTestSuite) TestRemoveWithManyRevisionsRunThrough(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(7),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -4067,7 +4310,7 @@ func (s *snapmgrTestSuite) TestRemoveOneRevisionRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(3))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(3), nil)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -4132,7 +4375,7 @@ func (s *snapmgrTestSuite) TestRemoveLastRevisionRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(2))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(2), nil)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -4212,7 +4455,7 @@ func (s *snapmgrTestSuite) TestRemoveCurrentActiveRevisionRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2))
+	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2), nil)
 
 	c.Check(err, ErrorMatches, `cannot remove active revision 2 of snap "some-snap"`)
 }
@@ -4233,7 +4476,7 @@ func (s *snapmgrTestSuite) TestRemoveCurrentRevisionOfSeveralRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2))
+	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2), nil)
 	c.Assert(err, NotNil)
 	c.Check(err.Error(), Equals, `cannot remove active revision 2 of snap "some-snap" (revert first?)`)
 }
@@ -4254,7 +4497,7 @@ func (s *snapmgrTestSuite) TestRemoveMissingRevisionRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "some-snap", snap.R(1))
+	_, err := snapstate.Remove(s.state, "some-snap", snap.R(1), nil)
 
 	c.Check(err, ErrorMatches, `revision 1 of snap "some-snap" is not installed`)
 }
@@ -4275,7 +4518,7 @@ func (s *snapmgrTestSuite) TestRemoveRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "gadget", snap.R(0))
+	_, err := snapstate.Remove(s.state, "gadget", snap.R(0), nil)
 
 	c.Check(err, ErrorMatches, `snap "gadget" is not removable`)
 }
@@ -4296,7 +4539,7 @@ func (s *snapmgrTestSuite) TestRemoveRefusedLastRevision(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "gadget", snap.R(7))
+	_, err := snapstate.Remove(s.state, "gadget", snap.R(7), nil)
 
 	c.Check(err, ErrorMatches, `snap "gadget" is not removable`)
 }
@@ -4339,7 +4582,7 @@ func (s *snapmgrTestSuite) TestRemoveDeletesConfigOnLastRevision(c *C) {
 	c.Assert(tr.Get("another-snap", "bar", &res), IsNil)
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), nil)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -4392,7 +4635,7 @@ func (s *snapmgrTestSuite) TestRemoveDoesntDeleteConfigIfNotLastRevision(c *C) {
 	c.Assert(tr.Get("some-snap", "foo", &res), IsNil)
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", si1.Revision)
+	ts, err := snapstate.Remove(s.state, "some-snap", si1.Revision, nil)
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -4693,6 +4936,11 @@ func (s *snapmgrTestSuite) TestRevertRunThrough(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(2),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -4776,7 +5024,7 @@ func (s *snapmgrTestSuite) TestRevertWithLocalRevisionRunThrough(c *C) {
 	s.settle(c)
 	s.state.Lock()
 
-	c.Assert(s.fakeBackend.ops.Ops(), HasLen, 6)
+	c.Assert(s.fakeBackend.ops.Ops(), HasLen, 7)
 
 	// verify that LocalRevision is still -7
 	var snapst snapstate.SnapState
@@ -4821,6 +5069,11 @@ func (s *snapmgrTestSuite) TestRevertToRevisionNewVersion(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(7),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -4902,6 +5155,11 @@ func (s *snapmgrTestSuite) TestRevertTotalUndoRunThrough(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(1),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -4998,6 +5256,11 @@ func (s *snapmgrTestSuite) TestRevertUndoRunThrough(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(1),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -6849,6 +7112,11 @@ func (s *snapmgrTestSuite) TestUpdateCanDoBackwards(c *C) {
 	s.settle(c)
 	s.state.Lock()
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(7),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -6940,6 +7208,64 @@ func (s *snapmgrTestSuite) TestInstallMany(c *C) {
 	}
 }
 
+func (s *snapmgrTestSuite) TestInstallManyTransactionAllSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	installed, tts, err := snapstate.InstallManyWithTransaction(s.state, []string{"one", "two"}, 0, snapstate.TransactionAllSnaps)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	c.Check(installed, DeepEquals, []string{"one", "two"})
+
+	for _, ts := range tts {
+		verifyInstallTasks(c, 0, 0, ts, s.state)
+		// check that tasksets share a single lane
+		for _, t := range ts.Tasks() {
+			c.Assert(t.Lanes(), DeepEquals, []int{1})
+		}
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallManyTransactionAllSnapsUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install two snaps")
+	_, tts, err := snapstate.InstallManyWithTransaction(s.state, []string{"one", "two"}, 0, snapstate.TransactionAllSnaps)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	for _, ts := range tts {
+		chg.AddAll(ts)
+	}
+
+	// make the installation of "two" fail
+	tasks := tts[1].Tasks()
+	last := tasks[len(tasks)-1]
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(last)
+	terr.JoinLane(last.Lanes()[0])
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	for _, ts := range tts {
+		for _, t := range ts.Tasks() {
+			if t.Kind() == "link-snap" {
+				c.Check(t.Status(), Equals, state.UndoneStatus)
+			}
+		}
+	}
+	for _, name := range []string{"one", "two"} {
+		var snapst snapstate.SnapState
+		err := snapstate.Get(s.state, name, &snapst)
+		c.Check(err, Equals, state.ErrNoState, Commentf(name))
+	}
+}
+
 func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -6959,15 +7285,16 @@ func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 		Current: snap.R(1),
 	})
 
-	removed, tts, err := snapstate.RemoveMany(s.state, []string{"one", "two"})
+	removed, tts, err := snapstate.RemoveMany(s.state, []string{"one", "two"}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 2)
 	c.Check(removed, DeepEquals, []string{"one", "two"})
 
-	c.Assert(s.state.TaskCount(), Equals, 8*2)
+	c.Assert(s.state.TaskCount(), Equals, 9*2)
 	for _, ts := range tts {
 		c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 			"stop-snap-services",
+			"auto-disconnect",
 			"run-hook[remove]",
 			"remove-aliases",
 			"unlink-snap",
@@ -7376,6 +7703,11 @@ func (s *snapmgrTestSuite) TestTransitionCoreRunThrough(c *C) {
 			op:   "transition-ubuntu-core:Doing",
 			name: "ubuntu-core",
 		},
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "ubuntu-core",
+			revno: snap.R(1),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "ubuntu-core",
@@ -7463,6 +7795,11 @@ func (s *snapmgrTestSuite) TestTransitionCoreRunThroughWithCore(c *C) {
 			op:   "transition-ubuntu-core:Doing",
 			name: "ubuntu-core",
 		},
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "ubuntu-core",
+			revno: snap.R(1),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "ubuntu-core",
@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	timeNow = time.Now
+
+	// watchdogInterval is how often a refreshed snap is checked
+	// while it is being watched.
+	watchdogInterval = 10 * time.Second
+)
+
+// CheckSnapHealth returns an error if the given revision of the snap
+// reported being unhealthy since the given time.
+var CheckSnapHealth = func(st *state.State, snapName string, rev snap.Revision, since time.Time) error {
+	return nil
+}
+
+// watchdogGracePeriod returns for how long refreshed snaps are
+// watched before their refresh is considered successful, as set by
+// refresh.watchdog. Zero means refreshed snaps are not watched.
+func watchdogGracePeriod(st *state.State) (time.Duration, error) {
+	var gracePeriodStr string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "refresh.watchdog", &gracePeriodStr)
+	if err != nil && !config.IsNoOption(err) {
+		return 0, err
+	}
+	if gracePeriodStr == "" {
+		return 0, nil
+	}
+	gracePeriod, err := time.ParseDuration(gracePeriodStr)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse refresh.watchdog: %v", err)
+	}
+	return gracePeriod, nil
+}
+
+// failingService returns the name of an enabled service of the snap
+// that is not running, or an empty string if they all are. Services
+// that are not expected to be running all the time are ignored.
+func failingService(info *snap.Info) (string, error) {
+	var svcs []*snap.AppInfo
+	for _, app := range info.Services() {
+		if app.Daemon == "oneshot" || app.Timer != nil || len(app.Sockets) > 0 {
+			continue
+		}
+		svcs = append(svcs, app)
+	}
+	if len(svcs) == 0 {
+		return "", nil
+	}
+
+	names := make([]string, len(svcs))
+	for i, app := range svcs {
+		names[i] = app.ServiceName()
+	}
+	sysd := systemd.New(dirs.GlobalRootDir, progress.Null)
+	sts, err := sysd.Status(names...)
+	if err != nil {
+		return "", err
+	}
+	for i, st := range sts {
+		if st.Enabled && !st.Active {
+			return svcs[i].Snap.Name() + "." + svcs[i].Name, nil
+		}
+	}
+	return "", nil
+}
+
+// doWatchSnap watches a refreshed snap for the grace period set on
+// the task, failing as soon as the snap reports being unhealthy or
+// one of its services stops running. Failing undoes the refresh,
+// reverting the snap to its previous revision.
+func (m *SnapManager) doWatchSnap(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var gracePeriod time.Duration
+	if err := t.Get("grace-period", &gracePeriod); err != nil {
+		return err
+	}
+	var since time.Time
+	err = t.Get("watch-since", &since)
+	if err == state.ErrNoState {
+		since = timeNow()
+		t.Set("watch-since", since)
+	} else if err != nil {
+		return err
+	}
+
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+
+	var problem error
+	if err := CheckSnapHealth(st, snapsup.Name(), info.Revision, since); err != nil {
+		problem = err
+	} else {
+		st.Unlock()
+		svc, err := failingService(info)
+		st.Lock()
+		if err != nil {
+			return fmt.Errorf("cannot get status of services: %v", err)
+		}
+		if svc != "" {
+			problem = fmt.Errorf("service %q is not running", svc)
+		}
+	}
+	if problem != nil {
+		t.Logf("Reverting snap %q to the previous revision", snapsup.Name())
+		return fmt.Errorf("snap %q revision %s failed its health check: %v", snapsup.Name(), info.Revision, problem)
+	}
+
+	left := since.Add(gracePeriod).Sub(timeNow())
+	if left > 0 {
+		if left > watchdogInterval {
+			left = watchdogInterval
+		}
+		return &state.Retry{After: left}
+	}
+
+	t.Logf("Snap %q revision %s stayed healthy for %s", snapsup.Name(), info.Revision, gracePeriod)
+	return nil
+}
@@ -0,0 +1,192 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func (s *snapmgrTestSuite) setWatchdog(c *C, gracePeriod string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.watchdog", gracePeriod), IsNil)
+	tr.Commit()
+}
+
+func (s *snapmgrTestSuite) mockServices(c *C, activeStates map[string]string) {
+	restore := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		c.Assert(args[0], Equals, "show")
+		var out []string
+		for _, arg := range args[2:] {
+			name := strings.TrimSuffix(strings.TrimPrefix(arg, "snap.services-snap."), ".service")
+			out = append(out, fmt.Sprintf("Id=%s\nType=simple\nActiveState=%s\nUnitFileState=enabled\n", arg, activeStates[name]))
+		}
+		return []byte(strings.Join(out, "\n")), nil
+	})
+	s.BaseTest.AddCleanup(restore)
+}
+
+func (s *snapmgrTestSuite) refreshWatched(c *C, name string) *state.Change {
+	snapstate.Set(s.state, name, &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: name, SnapID: name + "-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, name, "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	return chg
+}
+
+func (s *snapmgrTestSuite) TestUpdateTasksWithWatchdog(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setWatchdog(c, "5m")
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	var watch *state.Task
+	for i, t := range ts.Tasks() {
+		if t.Kind() == "watch-snap" {
+			watch = t
+			c.Check(ts.Tasks()[i-1].Kind(), Equals, "start-snap-services")
+		}
+	}
+	c.Assert(watch, NotNil)
+	c.Check(watch.Summary(), Equals, `Watch snap "some-snap" (11) for 5m0s`)
+	var gracePeriod time.Duration
+	c.Assert(watch.Get("grace-period", &gracePeriod), IsNil)
+	c.Check(gracePeriod, Equals, 5*time.Minute)
+}
+
+func (s *snapmgrTestSuite) TestUpdateTasksWithoutWatchdog(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	for _, t := range ts.Tasks() {
+		c.Check(t.Kind(), Not(Equals), "watch-snap")
+	}
+}
+
+func (s *snapmgrTestSuite) TestUpdateWatchdogHealthy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setWatchdog(c, "1ms")
+	s.mockServices(c, map[string]string{"svc1": "active", "svc2": "active"})
+
+	chg := s.refreshWatched(c, "services-snap")
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "services-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(11))
+
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "watch-snap" {
+			c.Check(strings.Join(t.Log(), "\n"), Matches, `.* Snap "services-snap" revision 11 stayed healthy for 1ms`)
+		}
+	}
+}
+
+func (s *snapmgrTestSuite) TestUpdateWatchdogFailingServiceReverts(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setWatchdog(c, "1h")
+	s.mockServices(c, map[string]string{"svc1": "active", "svc2": "activating"})
+
+	chg := s.refreshWatched(c, "services-snap")
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*snap "services-snap" revision 11 failed its health check: service "services-snap.svc2" is not running.*`)
+
+	// the refresh was undone
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "services-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(7))
+	c.Check(snapst.Active, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestUpdateWatchdogUnhealthyReverts(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	oldCheckSnapHealth := snapstate.CheckSnapHealth
+	defer func() { snapstate.CheckSnapHealth = oldCheckSnapHealth }()
+	var checked []string
+	snapstate.CheckSnapHealth = func(st *state.State, snapName string, rev snap.Revision, since time.Time) error {
+		checked = append(checked, fmt.Sprintf("%s:%s", snapName, rev))
+		return errors.New("snap reported error status: cannot reach the database")
+	}
+
+	s.setWatchdog(c, "1h")
+
+	chg := s.refreshWatched(c, "some-snap")
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*snap "some-snap" revision 11 failed its health check: snap reported error status: cannot reach the database.*`)
+	c.Check(checked, DeepEquals, []string{"some-snap:11"})
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(7))
+
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "watch-snap" {
+			c.Check(strings.Join(t.Log(), "\n"), Matches, `(?s).* Reverting snap "some-snap" to the previous revision.*`)
+		}
+	}
+}
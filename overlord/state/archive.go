@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A ChangeArchiver is a Backend that also keeps a record of the
+// ready changes that get pruned from the state.
+type ChangeArchiver interface {
+	Backend
+	ArchiveChanges(chgs []*ArchivedChange) error
+}
+
+// ArchivedChange is the record kept of a change after it was pruned.
+type ArchivedChange struct {
+	ID      string                      `json:"id"`
+	Kind    string                      `json:"kind"`
+	Summary string                      `json:"summary"`
+	Status  string                      `json:"status"`
+	Err     string                      `json:"err,omitempty"`
+	Data    map[string]*json.RawMessage `json:"data,omitempty"`
+	Tasks   []*ArchivedTask             `json:"tasks,omitempty"`
+
+	SpawnTime time.Time `json:"spawn-time"`
+	ReadyTime time.Time `json:"ready-time"`
+}
+
+// ArchivedTask is the record kept of a task of an archived change.
+type ArchivedTask struct {
+	ID            string                 `json:"id"`
+	Kind          string                 `json:"kind"`
+	Summary       string                 `json:"summary"`
+	Status        string                 `json:"status"`
+	Log           []string               `json:"log,omitempty"`
+	StatusChanges []ArchivedStatusChange `json:"status-changes,omitempty"`
+
+	SpawnTime time.Time `json:"spawn-time"`
+	ReadyTime time.Time `json:"ready-time"`
+}
+
+// ArchivedStatusChange records when a task was set to a given status.
+type ArchivedStatusChange struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// Duration returns how long the change took to become ready.
+func (chg *ArchivedChange) Duration() time.Duration {
+	return chg.ReadyTime.Sub(chg.SpawnTime)
+}
+
+// Duration returns how long the task took to become ready.
+func (t *ArchivedTask) Duration() time.Duration {
+	return t.ReadyTime.Sub(t.SpawnTime)
+}
+
+// archived returns the record to keep of the change once pruned.
+func (c *Change) archived() *ArchivedChange {
+	chg := &ArchivedChange{
+		ID:      c.id,
+		Kind:    c.kind,
+		Summary: c.summary,
+		Status:  c.Status().String(),
+		Data:    c.data,
+
+		SpawnTime: c.spawnTime,
+		ReadyTime: c.readyTime,
+	}
+	if err := c.Err(); err != nil {
+		chg.Err = err.Error()
+	}
+	for _, t := range c.Tasks() {
+		at := &ArchivedTask{
+			ID:      t.id,
+			Kind:    t.kind,
+			Summary: t.summary,
+			Status:  t.Status().String(),
+			Log:     t.log,
+
+			SpawnTime: t.spawnTime,
+			ReadyTime: t.readyTime,
+		}
+		for _, sc := range t.statusChanges {
+			at.StatusChanges = append(at.StatusChanges, ArchivedStatusChange{
+				Status: sc.Status.String(),
+				Time:   sc.Time,
+			})
+		}
+		chg.Tasks = append(chg.Tasks, at)
+	}
+	return chg
+}
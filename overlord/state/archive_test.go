@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type archiveSuite struct{}
+
+var _ = Suite(&archiveSuite{})
+
+type fakeArchiverBackend struct {
+	fakeStateBackend
+	archived     []*state.ArchivedChange
+	archiveError error
+}
+
+func (b *fakeArchiverBackend) ArchiveChanges(chgs []*state.ArchivedChange) error {
+	b.archived = append(b.archived, chgs...)
+	return b.archiveError
+}
+
+func (as *archiveSuite) TestPruneArchives(c *C) {
+	t0 := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	restore := state.MockTime(t0)
+	defer restore()
+
+	b := &fakeArchiverBackend{}
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "install...")
+	chg.Set("snap-names", []string{"foo"})
+	t1 := st.NewTask("download", "1...")
+	t2 := st.NewTask("link", "2...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	t1.SetStatus(state.DoingStatus)
+	t1.Logf("hello")
+	state.MockTime(t0.Add(time.Minute))
+	t1.SetStatus(state.DoneStatus)
+	t2.SetStatus(state.ErrorStatus)
+	t2.Errorf("boom")
+
+	// not ready yet, not archived
+	unready := st.NewChange("remove", "remove...")
+	unready.AddTask(st.NewTask("unlink", "3..."))
+
+	st.Prune(0, time.Hour, 100)
+	c.Check(st.Change(chg.ID()), IsNil)
+	c.Check(st.Change(unready.ID()), NotNil)
+	c.Assert(b.archived, HasLen, 1)
+	archived := b.archived[0]
+	c.Check(archived.ID, Equals, chg.ID())
+	c.Check(archived.Kind, Equals, "install")
+	c.Check(archived.Summary, Equals, "install...")
+	c.Check(archived.Status, Equals, "Error")
+	c.Check(archived.Err, Matches, `(?s)cannot perform the following tasks:.*boom.*`)
+	c.Check(archived.SpawnTime.Equal(t0), Equals, true)
+	c.Check(archived.Duration(), Equals, time.Minute)
+	c.Check(string(*archived.Data["snap-names"]), Equals, `["foo"]`)
+	c.Assert(archived.Tasks, HasLen, 2)
+	c.Check(archived.Tasks[0], DeepEquals, &state.ArchivedTask{
+		ID:      t1.ID(),
+		Kind:    "download",
+		Summary: "1...",
+		Status:  "Done",
+		Log:     []string{"2018-05-01T10:00:00Z INFO hello"},
+		StatusChanges: []state.ArchivedStatusChange{
+			{Status: "Doing", Time: t0},
+			{Status: "Done", Time: t0.Add(time.Minute)},
+		},
+		SpawnTime: t0,
+		ReadyTime: t0.Add(time.Minute),
+	})
+	c.Check(archived.Tasks[1].Status, Equals, "Error")
+}
+
+func (as *archiveSuite) TestPruneArchiveErrorStillPrunes(c *C) {
+	b := &fakeArchiverBackend{archiveError: errors.New("boom")}
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "install...")
+	t1 := st.NewTask("download", "1...")
+	chg.AddTask(t1)
+	t1.SetStatus(state.DoneStatus)
+
+	st.Prune(0, time.Hour, 100)
+	c.Check(b.archived, HasLen, 1)
+	c.Check(st.Changes(), HasLen, 0)
+}
+
+func (as *archiveSuite) TestStatusChangesCheckpointed(c *C) {
+	b := new(fakeStateBackend)
+	st := state.New(b)
+	st.Lock()
+	chg := st.NewChange("install", "install...")
+	t1 := st.NewTask("download", "1...")
+	chg.AddTask(t1)
+	t1.SetStatus(state.DoingStatus)
+	// setting the same status again is not a change
+	t1.SetStatus(state.DoingStatus)
+	t1.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	b2 := &fakeArchiverBackend{}
+	st2, err := state.ReadState(b2, bytes.NewBuffer(b.checkpoints[len(b.checkpoints)-1]))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+
+	st2.Prune(0, time.Hour, 100)
+	c.Assert(b2.archived, HasLen, 1)
+	c.Assert(b2.archived[0].Tasks, HasLen, 1)
+	statusChanges := b2.archived[0].Tasks[0].StatusChanges
+	c.Assert(statusChanges, HasLen, 2)
+	c.Check(statusChanges[0].Status, Equals, "Doing")
+	c.Check(statusChanges[1].Status, Equals, "Done")
+}
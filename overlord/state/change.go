@@ -126,6 +126,8 @@ type Change struct {
 	lanes   int
 	ready   chan struct{}
 
+	waitChanges []string
+
 	spawnTime time.Time
 	readyTime time.Time
 }
@@ -159,6 +161,8 @@ type marshalledChange struct {
 	TaskIDs []string                    `json:"task-ids,omitempty"`
 	Lanes   int                         `json:"lanes,omitempty"`
 
+	WaitChanges []string `json:"wait-changes,omitempty"`
+
 	SpawnTime time.Time  `json:"spawn-time"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
 }
@@ -180,6 +184,8 @@ func (c *Change) MarshalJSON() ([]byte, error) {
 		TaskIDs: c.taskIDs,
 		Lanes:   c.lanes,
 
+		WaitChanges: c.waitChanges,
+
 		SpawnTime: c.spawnTime,
 		ReadyTime: readyTime,
 	})
@@ -207,6 +213,7 @@ func (c *Change) UnmarshalJSON(data []byte) error {
 	c.data = custData
 	c.taskIDs = unmarshalled.TaskIDs
 	c.lanes = unmarshalled.Lanes
+	c.waitChanges = unmarshalled.WaitChanges
 	c.ready = make(chan struct{})
 	c.spawnTime = unmarshalled.SpawnTime
 	if unmarshalled.ReadyTime != nil {
@@ -218,7 +225,12 @@ func (c *Change) UnmarshalJSON(data []byte) error {
 // finishUnmarshal is called after the state and tasks are accessible.
 func (c *Change) finishUnmarshal() {
 	if c.Status().Ready() {
-		close(c.ready)
+		select {
+		case <-c.ready:
+			// already closed
+		default:
+			close(c.ready)
+		}
 	}
 }
 
@@ -240,7 +252,7 @@ func (c *Change) Summary() string {
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (c *Change) Set(key string, value interface{}) {
-	c.state.writing()
+	c.state.writingChange(c)
 	c.data.set(key, value)
 }
 
@@ -300,7 +312,7 @@ func (c *Change) Status() Status {
 
 // SetStatus sets the change status, overriding the default behavior (see Status method).
 func (c *Change) SetStatus(s Status) {
-	c.state.writing()
+	c.state.writingChange(c)
 	c.status = s
 	if s.Ready() {
 		c.markReady()
@@ -454,18 +466,19 @@ func (c *Change) State() *State {
 // AddTask registers a task as required for the state change to
 // be accomplished.
 func (c *Change) AddTask(t *Task) {
-	c.state.writing()
+	c.state.writingChange(c)
 	if t.change != "" {
 		panic(fmt.Sprintf("internal error: cannot add one %q task to multiple changes", t.Kind()))
 	}
 	t.change = c.id
+	c.state.writingTask(t)
 	c.taskIDs = addOnce(c.taskIDs, t.ID())
 }
 
 // AddAll registers all tasks in the set as required for the state
 // change to be accomplished.
 func (c *Change) AddAll(ts *TaskSet) {
-	c.state.writing()
+	c.state.writingChange(c)
 	for _, t := range ts.tasks {
 		c.AddTask(t)
 	}
@@ -477,6 +490,50 @@ func (c *Change) Tasks() []*Task {
 	return c.state.tasksIn(c.taskIDs)
 }
 
+// WaitFor registers another change as a requirement for c to make
+// progress. None of the tasks of c will be run before another is
+// ready, independently of whether it succeeded or not.
+func (c *Change) WaitFor(another *Change) {
+	c.state.writingChange(c)
+	c.waitChanges = addOnce(c.waitChanges, another.id)
+}
+
+// WaitChanges returns the list of changes c is waiting for. Changes
+// that were pruned meanwhile are omitted.
+func (c *Change) WaitChanges() []*Change {
+	c.state.reading()
+	res := make([]*Change, 0, len(c.waitChanges))
+	for _, id := range c.waitChanges {
+		if other := c.state.changes[id]; other != nil {
+			res = append(res, other)
+		}
+	}
+	return res
+}
+
+// mustWait returns whether the tasks of c must wait for other changes
+// to become ready.
+func (c *Change) mustWait() bool {
+	for _, other := range c.WaitChanges() {
+		if !other.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// isAwaited returns whether some other change is waiting for c.
+func (c *Change) isAwaited() bool {
+	for _, other := range c.state.changes {
+		for _, id := range other.waitChanges {
+			if id == c.id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // LaneTasks returns all tasks from given lanes the state change depends on.
 func (c *Change) LaneTasks(lanes ...int) []*Task {
 	laneLookup := make(map[int]bool)
@@ -504,7 +561,7 @@ func (c *Change) LaneTasks(lanes ...int) []*Task {
 // Abort flags the change for cancellation, whether in progress or not.
 // Cancellation will proceed at the next ensure pass.
 func (c *Change) Abort() {
-	c.state.writing()
+	c.state.writingChange(c)
 	tasks := make([]*Task, len(c.taskIDs))
 	for i, tid := range c.taskIDs {
 		tasks[i] = c.state.tasks[tid]
@@ -516,7 +573,7 @@ func (c *Change) Abort() {
 // except for tasks that are also in a healthy lane (not aborted, and not waiting
 // on aborted).
 func (c *Change) AbortLanes(lanes []int) {
-	c.state.writing()
+	c.state.writingChange(c)
 	c.abortLanes(lanes, make(map[int]bool), make(map[string]bool))
 }
 
@@ -128,6 +128,29 @@ func (cs *changeSuite) TestAddAll(c *C) {
 	c.Check(t2.Change(), Equals, chg)
 }
 
+func (cs *changeSuite) TestWaitFor(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg1 := st.NewChange("install", "...")
+	chg1.AddTask(st.NewTask("download", "..."))
+	chg2 := st.NewChange("remove", "...")
+	chg3 := st.NewChange("refresh", "...")
+
+	chg3.WaitFor(chg1)
+	chg3.WaitFor(chg2)
+	chg3.WaitFor(chg1)
+
+	c.Check(chg3.WaitChanges(), DeepEquals, []*state.Change{chg1, chg2})
+	c.Check(chg1.WaitChanges(), HasLen, 0)
+
+	// pruned changes are no longer waited for
+	chg2.SetStatus(state.DoneStatus)
+	st.Prune(0, time.Hour, 100)
+	c.Check(chg3.WaitChanges(), DeepEquals, []*state.Change{chg1})
+}
+
 func (cs *changeSuite) TestStatusExplicitlyDefined(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -393,6 +416,7 @@ func (cs *changeSuite) TestMethodEntrance(c *C) {
 		func() { chg.SetStatus(state.DoStatus) },
 		func() { chg.AddTask(nil) },
 		func() { chg.AddAll(nil) },
+		func() { chg.WaitFor(chg) },
 		func() { chg.UnmarshalJSON(nil) },
 	}
 
@@ -405,6 +429,7 @@ func (cs *changeSuite) TestMethodEntrance(c *C) {
 		func() { chg.MarshalJSON() },
 		func() { chg.SpawnTime() },
 		func() { chg.ReadyTime() },
+		func() { chg.WaitChanges() },
 	}
 
 	for i, f := range reads {
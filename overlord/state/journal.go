@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"encoding/json"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// journal tracks which data entries, changes and tasks were modified
+// since the state was last persisted. When full is set the state as a
+// whole needs to be persisted.
+type journal struct {
+	full    bool
+	data    map[string]bool
+	changes map[string]bool
+	tasks   map[string]bool
+}
+
+func (j *journal) addData(key string) {
+	if j.data == nil {
+		j.data = make(map[string]bool)
+	}
+	j.data[key] = true
+}
+
+func (j *journal) addChange(id string) {
+	if j.changes == nil {
+		j.changes = make(map[string]bool)
+	}
+	j.changes[id] = true
+}
+
+func (j *journal) addTask(id string) {
+	if j.tasks == nil {
+		j.tasks = make(map[string]bool)
+	}
+	j.tasks[id] = true
+}
+
+func (j *journal) reset() {
+	*j = journal{}
+}
+
+// marshalledJournalEntry holds the data entries, changes and tasks
+// modified since the previous entry (or the checkpoint); removed
+// ones are recorded as null.
+type marshalledJournalEntry struct {
+	Data    map[string]*json.RawMessage `json:"data,omitempty"`
+	Changes map[string]*Change          `json:"changes,omitempty"`
+	Tasks   map[string]*Task            `json:"tasks,omitempty"`
+
+	LastChangeId int `json:"last-change-id"`
+	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id"`
+}
+
+func (s *State) journalEntryData() []byte {
+	s.reading()
+	entry := marshalledJournalEntry{
+		LastTaskId:   s.lastTaskId,
+		LastChangeId: s.lastChangeId,
+		LastLaneId:   s.lastLaneId,
+	}
+	if len(s.journal.data) > 0 {
+		entry.Data = make(map[string]*json.RawMessage, len(s.journal.data))
+		for key := range s.journal.data {
+			entry.Data[key] = s.data[key]
+		}
+	}
+	if len(s.journal.changes) > 0 {
+		entry.Changes = make(map[string]*Change, len(s.journal.changes))
+		for id := range s.journal.changes {
+			entry.Changes[id] = s.changes[id]
+		}
+	}
+	if len(s.journal.tasks) > 0 {
+		entry.Tasks = make(map[string]*Task, len(s.journal.tasks))
+		for id := range s.journal.tasks {
+			entry.Tasks[id] = s.tasks[id]
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		// this shouldn't happen, because the actual delicate serializing happens at various Set()s
+		logger.Panicf("internal error: could not marshal state journal entry: %v", err)
+	}
+	return data
+}
+
+// replay applies the journal entry on top of the state.
+func (s *State) replay(entry *marshalledJournalEntry) {
+	if s.data == nil {
+		s.data = make(customData)
+	}
+	if s.changes == nil {
+		s.changes = make(map[string]*Change)
+	}
+	if s.tasks == nil {
+		s.tasks = make(map[string]*Task)
+	}
+	for key, value := range entry.Data {
+		if value == nil {
+			delete(s.data, key)
+			continue
+		}
+		s.data[key] = value
+	}
+	for id, chg := range entry.Changes {
+		if chg == nil {
+			delete(s.changes, id)
+			continue
+		}
+		chg.state = s
+		s.changes[id] = chg
+	}
+	for id, t := range entry.Tasks {
+		if t == nil {
+			delete(s.tasks, id)
+			continue
+		}
+		t.state = s
+		s.tasks[id] = t
+	}
+	s.lastChangeId = entry.LastChangeId
+	s.lastTaskId = entry.LastTaskId
+	s.lastLaneId = entry.LastLaneId
+}
@@ -0,0 +1,331 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type journalSuite struct{}
+
+var _ = Suite(&journalSuite{})
+
+type fakeJournalBackend struct {
+	fakeStateBackend
+	entries         [][]byte
+	journalError    error
+	needsCompaction bool
+}
+
+func (b *fakeJournalBackend) Journal(entry []byte) error {
+	if b.journalError != nil {
+		return b.journalError
+	}
+	b.entries = append(b.entries, entry)
+	return nil
+}
+
+func (b *fakeJournalBackend) NeedsCompaction() bool {
+	return b.needsCompaction
+}
+
+// lastCheckpoint returns the last checkpoint.
+func (b *fakeJournalBackend) lastCheckpoint() *bytes.Buffer {
+	return bytes.NewBuffer(b.checkpoints[len(b.checkpoints)-1])
+}
+
+// journal returns the journal entries appended after the last
+// checkpoint, one per line.
+func (b *fakeJournalBackend) journal() *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	for _, entry := range b.entries {
+		buf.Write(entry)
+		buf.WriteByte('\n')
+	}
+	return buf
+}
+
+func (js *journalSuite) TestJournalModifiedOnly(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Set("baz", 1)
+	// a new state is checkpointed in full
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.entries, HasLen, 0)
+
+	st.Lock()
+	st.Set("baz", 2)
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.entries, HasLen, 1)
+
+	var entry map[string]interface{}
+	err := json.Unmarshal(b.entries[0], &entry)
+	c.Assert(err, IsNil)
+	c.Check(entry["data"], DeepEquals, map[string]interface{}{"baz": 2.0})
+	c.Check(entry["changes"], IsNil)
+	c.Check(entry["tasks"], IsNil)
+
+	// nothing modified, nothing journaled
+	st.Lock()
+	st.Unlock()
+	c.Assert(b.entries, HasLen, 1)
+}
+
+func (js *journalSuite) TestJournalChangesAndTasks(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Unlock()
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.entries, HasLen, 1)
+
+	st.Lock()
+	t1.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	c.Assert(b.entries, HasLen, 2)
+	var entry struct {
+		Changes map[string]json.RawMessage `json:"changes"`
+		Tasks   map[string]json.RawMessage `json:"tasks"`
+	}
+	err := json.Unmarshal(b.entries[1], &entry)
+	c.Assert(err, IsNil)
+	c.Check(entry.Changes, HasLen, 1)
+	c.Check(entry.Changes[chg.ID()], NotNil)
+	c.Check(entry.Tasks, HasLen, 1)
+	c.Check(entry.Tasks[t1.ID()], NotNil)
+}
+
+func (js *journalSuite) TestJournalCompaction(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Unlock()
+
+	b.needsCompaction = true
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 2)
+	c.Assert(b.entries, HasLen, 0)
+}
+
+func (js *journalSuite) TestJournalErrorCheckpoints(c *C) {
+	b := &fakeJournalBackend{journalError: errors.New("boom")}
+	st := state.New(b)
+	st.Lock()
+	st.Unlock()
+
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 2)
+	c.Assert(b.entries, HasLen, 0)
+	c.Check(string(b.checkpoints[1]), Matches, `.*"foo":"bar".*`)
+}
+
+func (js *journalSuite) TestReadStateReplaysJournal(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Set("gone", 1)
+	chg1 := st.NewChange("old", "...")
+	t0 := st.NewTask("old-task", "...")
+	chg1.AddTask(t0)
+	t0.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Set("gone", nil)
+	chg2 := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	chg2.AddTask(t1)
+	st.NewLane()
+	st.Unlock()
+
+	st.Lock()
+	t1.SetStatus(state.DoneStatus)
+	t1.Set("k", "v")
+	// prune the older change
+	st.Prune(time.Hour, time.Hour, 1)
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.entries, HasLen, 2)
+
+	st2, err := state.ReadStateWithJournal(nil, b.lastCheckpoint(), b.journal())
+	c.Assert(err, IsNil)
+	// the journal gets folded into a checkpoint at the next unlock
+	c.Check(st2.Modified(), Equals, true)
+
+	st2.Lock()
+	defer st2.Unlock()
+
+	var foo string
+	c.Assert(st2.Get("foo", &foo), IsNil)
+	c.Check(foo, Equals, "baz")
+	var gone int
+	c.Check(st2.Get("gone", &gone), Equals, state.ErrNoState)
+
+	c.Check(st2.Change(chg1.ID()), IsNil)
+	c.Check(st2.Task(t0.ID()), IsNil)
+
+	chg := st2.Change(chg2.ID())
+	c.Assert(chg, NotNil)
+	c.Check(chg.Kind(), Equals, "install")
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	select {
+	case <-chg.Ready():
+	default:
+		c.Errorf("Change didn't become ready")
+	}
+	c.Assert(chg.Tasks(), HasLen, 1)
+	t := chg.Tasks()[0]
+	c.Check(t.ID(), Equals, t1.ID())
+	var v string
+	c.Assert(t.Get("k", &v), IsNil)
+	c.Check(v, Equals, "v")
+
+	// last ids were journaled too
+	c.Check(st2.NewChange("other", "...").ID(), Equals, "3")
+	c.Check(st2.NewTask("other", "...").ID(), Equals, "3")
+	c.Check(st2.NewLane(), Equals, 2)
+}
+
+func (js *journalSuite) TestReadStateTornJournal(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", 1)
+	st.Unlock()
+
+	st.Lock()
+	st.Set("foo", 2)
+	st.Unlock()
+
+	st.Lock()
+	st.Set("foo", 3)
+	st.Unlock()
+
+	c.Assert(b.entries, HasLen, 2)
+	// the last entry was only partially written
+	b.entries[1] = b.entries[1][:len(b.entries[1])/2]
+
+	b2 := &fakeJournalBackend{}
+	st2, err := state.ReadStateWithJournal(b2, b.lastCheckpoint(), b.journal())
+	c.Assert(err, IsNil)
+	c.Check(st2.Modified(), Equals, true)
+
+	st2.Lock()
+	var foo int
+	c.Assert(st2.Get("foo", &foo), IsNil)
+	c.Check(foo, Equals, 2)
+	// the torn journal is replaced by a checkpoint
+	st2.Unlock()
+
+	c.Assert(b2.checkpoints, HasLen, 1)
+	c.Check(b2.entries, HasLen, 0)
+	c.Check(string(b2.checkpoints[0]), Matches, `.*"foo":2.*`)
+}
+
+func (js *journalSuite) TestReadStateWithEmptyJournal(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", 1)
+	st.Unlock()
+
+	st2, err := state.ReadStateWithJournal(nil, b.lastCheckpoint(), &bytes.Buffer{})
+	c.Assert(err, IsNil)
+	c.Check(st2.Modified(), Equals, false)
+}
+
+func (js *journalSuite) TestCompact(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", 1)
+	st.Unlock()
+
+	st.Lock()
+	st.Set("foo", 2)
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.entries, HasLen, 1)
+
+	st.Lock()
+	st.Compact()
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 2)
+	c.Check(string(b.checkpoints[1]), Matches, `.*"foo":2.*`)
+}
+
+func (js *journalSuite) TestReadStateWithoutJournal(c *C) {
+	// state files written before journaling was introduced are
+	// just a checkpoint
+	buf := bytes.NewBufferString(`{"data":{"foo":"bar"},"changes":{},"tasks":{},"last-change-id":0,"last-task-id":0,"last-lane-id":0}
+`)
+	b := &fakeJournalBackend{}
+	st, err := state.ReadState(b, buf)
+	c.Assert(err, IsNil)
+	c.Check(st.Modified(), Equals, false)
+
+	st.Lock()
+	var foo string
+	c.Assert(st.Get("foo", &foo), IsNil)
+	c.Check(foo, Equals, "bar")
+	st.Set("foo", "baz")
+	st.Unlock()
+
+	// loaded states are journaled right away
+	c.Check(b.checkpoints, HasLen, 0)
+	c.Check(b.entries, HasLen, 1)
+}
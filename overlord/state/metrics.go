@@ -0,0 +1,95 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/metrics"
+)
+
+// taskMetrics are the metrics task runners record about the tasks
+// they run, per task kind.
+type taskMetrics struct {
+	registry *metrics.Registry
+
+	queueSeconds   *metrics.HistogramVec
+	handlerSeconds *metrics.HistogramVec
+	retries        *metrics.CounterVec
+	errors         *metrics.CounterVec
+}
+
+func newTaskMetrics() *taskMetrics {
+	r := metrics.NewRegistry()
+	return &taskMetrics{
+		registry: r,
+		queueSeconds: r.NewHistogramVec("snapd_task_queue_seconds",
+			"Time tasks waited to be run after they became runnable.", "kind", nil),
+		handlerSeconds: r.NewHistogramVec("snapd_task_handler_seconds",
+			"Time spent running task handlers, including handlers asking for a retry.", "kind", nil),
+		retries: r.NewCounterVec("snapd_task_retries_total",
+			"Number of times task handlers asked to be retried.", "kind"),
+		errors: r.NewCounterVec("snapd_task_errors_total",
+			"Number of times task handlers failed.", "kind"),
+	}
+}
+
+// Metrics returns the registry with the metrics recorded by the task
+// runners about the tasks in the state. Unlike most State methods it
+// can be used without holding the state lock.
+func (s *State) Metrics() *metrics.Registry {
+	return s.metrics.registry
+}
+
+// lastStatusChange returns the last time the status of the task was
+// set, or its spawn time if it never was.
+func (t *Task) lastStatusChange() time.Time {
+	if n := len(t.statusChanges); n > 0 {
+		return t.statusChanges[n-1].Time
+	}
+	return t.spawnTime
+}
+
+// runnableSince returns since when the task could have been run, given
+// the tasks it had to wait for.
+func (t *Task) runnableSince(deps []*Task) time.Time {
+	since := t.lastStatusChange()
+	for _, dep := range deps {
+		if depSince := dep.lastStatusChange(); depSince.After(since) {
+			since = depSince
+		}
+	}
+	return since
+}
+
+func (m *taskMetrics) taskQueued(t *Task, deps []*Task) {
+	m.queueSeconds.Observe(t.kind, timeNow().Sub(t.runnableSince(deps)).Seconds())
+}
+
+func (m *taskMetrics) handlerDone(t *Task, elapsed time.Duration, err error) {
+	m.handlerSeconds.Observe(t.kind, elapsed.Seconds())
+	switch err.(type) {
+	case nil:
+	case *Retry:
+		m.retries.Inc(t.kind)
+	default:
+		m.errors.Inc(t.kind)
+	}
+}
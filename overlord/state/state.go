@@ -43,6 +43,17 @@ type Backend interface {
 	RequestRestart(t RestartType)
 }
 
+// A JournalBackend is a Backend that is also able to persist only
+// the parts of the state that were modified since the last time the
+// state was persisted, by appending them to a journal that amends the
+// last checkpoint. A full checkpoint is still used when the backend
+// reports that its journal needs compacting.
+type JournalBackend interface {
+	Backend
+	Journal(entry []byte) error
+	NeedsCompaction() bool
+}
+
 type customData map[string]*json.RawMessage
 
 func (data customData) get(key string, value interface{}) error {
@@ -100,9 +111,12 @@ type State struct {
 	tasks   map[string]*Task
 
 	modified bool
+	journal  journal
 
 	cache map[interface{}]interface{}
 
+	metrics *taskMetrics
+
 	restarting bool
 	restartLck sync.Mutex
 }
@@ -115,7 +129,9 @@ func New(backend Backend) *State {
 		changes:  make(map[string]*Change),
 		tasks:    make(map[string]*Task),
 		modified: true,
+		journal:  journal{full: true},
 		cache:    make(map[interface{}]interface{}),
+		metrics:  newTaskMetrics(),
 	}
 }
 
@@ -124,6 +140,12 @@ func (s *State) Modified() bool {
 	return s.modified
 }
 
+// Compact makes the next Unlock checkpoint the state as a whole,
+// folding into it anything journaled since the last checkpoint.
+func (s *State) Compact() {
+	s.writing()
+}
+
 // Lock acquires the state lock.
 func (s *State) Lock() {
 	s.mu.Lock()
@@ -136,13 +158,47 @@ func (s *State) reading() {
 	}
 }
 
+// writing flags the whole state as modified.
 func (s *State) writing() {
 	s.modified = true
+	s.journal.full = true
 	if atomic.LoadInt32(&s.muC) != 1 {
 		panic("internal error: accessing state without lock")
 	}
 }
 
+// writingPart flags the state as modified without flagging any of
+// its data entries, changes or tasks as such, as it's the case when
+// only the id counters change.
+func (s *State) writingPart() {
+	s.modified = true
+	if atomic.LoadInt32(&s.muC) != 1 {
+		panic("internal error: accessing state without lock")
+	}
+}
+
+// writingData flags the data entry with the given key as modified.
+func (s *State) writingData(key string) {
+	s.writingPart()
+	s.journal.addData(key)
+}
+
+// writingChange flags the given change as modified.
+func (s *State) writingChange(chg *Change) {
+	s.writingPart()
+	s.journal.addChange(chg.id)
+}
+
+// writingTask flags the given task, and the change it belongs to if
+// any, as modified.
+func (s *State) writingTask(t *Task) {
+	s.writingPart()
+	s.journal.addTask(t.id)
+	if t.change != "" {
+		s.journal.addChange(t.change)
+	}
+}
+
 func (s *State) unlock() {
 	atomic.AddInt32(&s.muC, -1)
 	s.mu.Unlock()
@@ -213,6 +269,9 @@ var (
 )
 
 // Unlock releases the state lock and checkpoints the state.
+// If the backend is a JournalBackend only the modified parts of the
+// state are journaled, unless the journal needs compacting or
+// journaling fails.
 // It does not return until the state is correctly checkpointed.
 // After too many unsuccessful checkpoint attempts, it panics.
 func (s *State) Unlock() {
@@ -222,12 +281,23 @@ func (s *State) Unlock() {
 		return
 	}
 
+	if jb, ok := s.backend.(JournalBackend); ok && !s.journal.full && !jb.NeedsCompaction() {
+		err := jb.Journal(s.journalEntryData())
+		if err == nil {
+			s.modified = false
+			s.journal.reset()
+			return
+		}
+		logger.Noticef("cannot journal state changes, checkpointing instead: %v", err)
+	}
+
 	data := s.checkpointData()
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
 		if err = s.backend.Checkpoint(data); err == nil {
 			s.modified = false
+			s.journal.reset()
 			return
 		}
 		time.Sleep(unlockCheckpointRetryInterval)
@@ -281,7 +351,7 @@ func (s *State) Get(key string, value interface{}) error {
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (s *State) Set(key string, value interface{}) {
-	s.writing()
+	s.writingData(key)
 	s.data.set(key, value)
 }
 
@@ -305,17 +375,18 @@ func (s *State) Cache(key, value interface{}) {
 
 // NewChange adds a new change to the state.
 func (s *State) NewChange(kind, summary string) *Change {
-	s.writing()
+	s.writingPart()
 	s.lastChangeId++
 	id := strconv.Itoa(s.lastChangeId)
 	chg := newChange(s, id, kind, summary)
 	s.changes[id] = chg
+	s.journal.addChange(id)
 	return chg
 }
 
 // NewLane creates a new lane in the state.
 func (s *State) NewLane() int {
-	s.writing()
+	s.writingPart()
 	s.lastLaneId++
 	return s.lastLaneId
 }
@@ -340,11 +411,12 @@ func (s *State) Change(id string) *Change {
 // It usually will be registered with a Change using AddTask or
 // through a TaskSet.
 func (s *State) NewTask(kind, summary string) *Task {
-	s.writing()
+	s.writingPart()
 	s.lastTaskId++
 	id := strconv.Itoa(s.lastTaskId)
 	t := newTask(s, id, kind, summary)
 	s.tasks[id] = t
+	s.journal.addTask(id)
 	return t
 }
 
@@ -391,7 +463,8 @@ func (s *State) tasksIn(tids []string) []*Task {
 // It also removes tasks unlinked to changes after pruneWait. When
 // there are more changes than the limit set via "maxReadyChanges"
 // those changes in ready state will also removed even if they are below
-// the pruneWait duration.
+// the pruneWait duration. Pruned ready changes are handed over for
+// archiving if the backend is a ChangeArchiver.
 func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	now := time.Now()
 	pruneLimit := now.Add(-pruneWait)
@@ -401,6 +474,9 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	changes := s.Changes()
 	sort.Sort(byReadyTime(changes))
 
+	archiver, _ := s.backend.(ChangeArchiver)
+	var archived []*ArchivedChange
+
 	readyChangesCount := 0
 	for i := range changes {
 		// changes are sorted (not-ready sorts first)
@@ -419,6 +495,7 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 		if readyTime.IsZero() {
 			if spawnTime.Before(pruneLimit) && len(chg.Tasks()) == 0 {
 				chg.Abort()
+				s.writingChange(chg)
 				delete(s.changes, chg.ID())
 			} else if spawnTime.Before(abortLimit) {
 				chg.Abort()
@@ -427,19 +504,29 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 		}
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
-			s.writing()
+			if archiver != nil {
+				archived = append(archived, chg.archived())
+			}
 			for _, t := range chg.Tasks() {
+				s.writingTask(t)
 				delete(s.tasks, t.ID())
 			}
+			s.writingChange(chg)
 			delete(s.changes, chg.ID())
 			readyChangesCount--
 		}
 	}
 
+	if len(archived) > 0 {
+		if err := archiver.ArchiveChanges(archived); err != nil {
+			logger.Noticef("cannot archive pruned changes: %v", err)
+		}
+	}
+
 	for tid, t := range s.tasks {
 		// TODO: this could be done more aggressively
 		if t.Change() == nil && t.SpawnTime().Before(pruneLimit) {
-			s.writing()
+			s.writingTask(t)
 			delete(s.tasks, tid)
 		}
 	}
@@ -447,6 +534,13 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 
 // ReadState returns the state deserialized from r.
 func ReadState(backend Backend, r io.Reader) (*State, error) {
+	return ReadStateWithJournal(backend, r, nil)
+}
+
+// ReadStateWithJournal returns the state deserialized from r, with
+// the entries read from journal, if any, replayed on top of it. The
+// replayed entries are folded into a checkpoint at the next Unlock.
+func ReadStateWithJournal(backend Backend, r, journal io.Reader) (*State, error) {
 	s := new(State)
 	s.Lock()
 	defer s.unlock()
@@ -455,8 +549,35 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	if err != nil {
 		return nil, err
 	}
+	replayed := false
+	if journal != nil {
+		d := json.NewDecoder(journal)
+		for {
+			var entry marshalledJournalEntry
+			err := d.Decode(&entry)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				// most likely the last entry was not completely
+				// written, what was successfully journaled before
+				// it is still good
+				logger.Noticef("cannot read state journal entry, ignoring the rest of the journal: %v", err)
+				replayed = true
+				break
+			}
+			s.replay(&entry)
+			replayed = true
+		}
+	}
+	for _, chg := range s.changes {
+		chg.finishUnmarshal()
+	}
 	s.backend = backend
-	s.modified = false
+	s.journal.reset()
+	s.modified = replayed
+	s.journal.full = replayed
 	s.cache = make(map[interface{}]interface{})
-	return s, err
+	s.metrics = newTaskMetrics()
+	return s, nil
 }
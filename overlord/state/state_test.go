@@ -627,6 +627,32 @@ func (ss *stateSuite) TestCheckpointPreserveCleanStatus(c *C) {
 	c.Assert(t2.IsClean(), Equals, true)
 }
 
+func (ss *stateSuite) TestCheckpointPreserveWaitChanges(c *C) {
+	b := new(fakeStateBackend)
+	st := state.New(b)
+	st.Lock()
+
+	chg1 := st.NewChange("install", "...")
+	chg2 := st.NewChange("remove", "...")
+	chg2.WaitFor(chg1)
+
+	// implicit checkpoint
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+
+	buf := bytes.NewBuffer(b.checkpoints[0])
+
+	st2, err := state.ReadState(nil, buf)
+	c.Assert(err, IsNil)
+
+	st2.Lock()
+	defer st2.Unlock()
+
+	c.Assert(st2.Change(chg2.ID()).WaitChanges(), DeepEquals, []*state.Change{st2.Change(chg1.ID())})
+	c.Assert(st2.Change(chg1.ID()).WaitChanges(), HasLen, 0)
+}
+
 func (ss *stateSuite) TestNewTaskAndTasks(c *C) {
 	st := state.New(nil)
 	st.Lock()
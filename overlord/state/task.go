@@ -27,6 +27,12 @@ import (
 	"time"
 )
 
+// statusChange records when a task was set to a given status.
+type statusChange struct {
+	Status Status    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
 type progress struct {
 	Label string `json:"label"`
 	Done  int    `json:"done"`
@@ -52,6 +58,8 @@ type Task struct {
 	log       []string
 	change    string
 
+	statusChanges []statusChange
+
 	spawnTime time.Time
 	readyTime time.Time
 
@@ -84,6 +92,8 @@ type marshalledTask struct {
 	Log       []string                    `json:"log,omitempty"`
 	Change    string                      `json:"change"`
 
+	StatusChanges []statusChange `json:"status-changes,omitempty"`
+
 	SpawnTime time.Time  `json:"spawn-time"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
 
@@ -115,6 +125,8 @@ func (t *Task) MarshalJSON() ([]byte, error) {
 		Log:       t.log,
 		Change:    t.change,
 
+		StatusChanges: t.statusChanges,
+
 		SpawnTime: t.spawnTime,
 		ReadyTime: readyTime,
 
@@ -148,6 +160,7 @@ func (t *Task) UnmarshalJSON(data []byte) error {
 	t.lanes = unmarshalled.Lanes
 	t.log = unmarshalled.Log
 	t.change = unmarshalled.Change
+	t.statusChanges = unmarshalled.StatusChanges
 	t.spawnTime = unmarshalled.SpawnTime
 	if unmarshalled.ReadyTime != nil {
 		t.readyTime = *unmarshalled.ReadyTime
@@ -184,11 +197,15 @@ func (t *Task) Status() Status {
 
 // SetStatus sets the task status, overriding the default behavior (see Status method).
 func (t *Task) SetStatus(new Status) {
-	t.state.writing()
+	t.state.writingTask(t)
 	old := t.status
 	t.status = new
+	now := timeNow()
+	if old != new {
+		t.statusChanges = append(t.statusChanges, statusChange{Status: new, Time: now})
+	}
 	if !old.Ready() && new.Ready() {
-		t.readyTime = timeNow()
+		t.readyTime = now
 	}
 	chg := t.Change()
 	if chg != nil {
@@ -206,7 +223,7 @@ func (t *Task) IsClean() bool {
 //
 // Cleaning a task must only be done after the change is ready.
 func (t *Task) SetClean() {
-	t.state.writing()
+	t.state.writingTask(t)
 	if t.clean {
 		return
 	}
@@ -246,9 +263,12 @@ func (t *Task) Progress() (label string, done, total int) {
 func (t *Task) SetProgress(label string, done, total int) {
 	// Only mark state for checkpointing if progress is final.
 	if total > 0 && done == total {
-		t.state.writing()
+		t.state.writingTask(t)
 	} else {
 		t.state.reading()
+		// still have the progress go along with the task whenever
+		// the state is next persisted
+		t.state.journal.addTask(t.id)
 	}
 	if total <= 0 || done > total {
 		// Doing math wrong is easy. Be conservative.
@@ -321,20 +341,20 @@ func (t *Task) Log() []string {
 
 // Logf logs information about the progress of the task.
 func (t *Task) Logf(format string, args ...interface{}) {
-	t.state.writing()
+	t.state.writingTask(t)
 	t.addLog(LogInfo, format, args)
 }
 
 // Errorf logs error information about the progress of the task.
 func (t *Task) Errorf(format string, args ...interface{}) {
-	t.state.writing()
+	t.state.writingTask(t)
 	t.addLog(LogError, format, args)
 }
 
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (t *Task) Set(key string, value interface{}) {
-	t.state.writing()
+	t.state.writingTask(t)
 	t.data.set(key, value)
 }
 
@@ -347,7 +367,7 @@ func (t *Task) Get(key string, value interface{}) error {
 
 // Clear disassociates the value from key.
 func (t *Task) Clear(key string) {
-	t.state.writing()
+	t.state.writingTask(t)
 	delete(t.data, key)
 }
 
@@ -362,7 +382,8 @@ func addOnce(set []string, s string) []string {
 
 // WaitFor registers another task as a requirement for t to make progress.
 func (t *Task) WaitFor(another *Task) {
-	t.state.writing()
+	t.state.writingTask(t)
+	t.state.writingTask(another)
 	t.waitTasks = addOnce(t.waitTasks, another.id)
 	another.haltTasks = addOnce(another.haltTasks, t.id)
 }
@@ -399,13 +420,13 @@ func (t *Task) Lanes() []int {
 // JoinLane registers the task in the provided lane. Tasks in different lanes
 // abort independently on errors. See Change.AbortLane for details.
 func (t *Task) JoinLane(lane int) {
-	t.state.writing()
+	t.state.writingTask(t)
 	t.lanes = append(t.lanes, lane)
 }
 
 // At schedules the task, if it's not ready, to happen no earlier than when, if when is the zero time any previous special scheduling is suppressed.
 func (t *Task) At(when time.Time) {
-	t.state.writing()
+	t.state.writingTask(t)
 	iszero := when.IsZero()
 	if t.Status().Ready() && !iszero {
 		return
@@ -151,12 +151,14 @@ func (r *TaskRunner) run(t *Task) {
 	var handler HandlerFunc
 	switch t.Status() {
 	case DoStatus:
+		r.state.metrics.taskQueued(t, t.WaitTasks())
 		t.SetStatus(DoingStatus)
 		fallthrough
 	case DoingStatus:
 		handler = r.handlerPair(t).do
 
 	case UndoStatus:
+		r.state.metrics.taskQueued(t, t.HaltTasks())
 		t.SetStatus(UndoingStatus)
 		fallthrough
 	case UndoingStatus:
@@ -176,7 +178,9 @@ func (r *TaskRunner) run(t *Task) {
 		// Capture the error result with tomb.Kill so we can
 		// use tomb.Err uniformily to consider both it or a
 		// overriding previous Kill reason.
+		start := timeNow()
 		tomb.Kill(handler(t, tomb))
+		elapsed := timeNow().Sub(start)
 
 		// Locks must be acquired in the same order everywhere.
 		r.mu.Lock()
@@ -205,6 +209,7 @@ func (r *TaskRunner) run(t *Task) {
 				err = &Retry{}
 			}
 		}
+		r.state.metrics.handlerDone(t, elapsed, err)
 
 		switch x := err.(type) {
 		case *Retry:
@@ -243,6 +248,11 @@ func (r *TaskRunner) run(t *Task) {
 			t.Errorf("%s", err)
 		}
 
+		// changes queued behind this one may now proceed
+		if chg := t.Change(); chg != nil && chg.IsReady() && chg.isAwaited() {
+			r.state.EnsureBefore(0)
+		}
+
 		return nil
 	})
 }
@@ -375,6 +385,13 @@ func (r *TaskRunner) Ensure() {
 			continue
 		}
 
+		if status == DoStatus || status == DoingStatus {
+			if chg := t.Change(); chg != nil && chg.mustWait() {
+				// Change is queued behind other changes.
+				continue
+			}
+		}
+
 		if status == UndoStatus && handlers.undo == nil {
 			// Although this has no dependencies itself, it must have waited
 			// above too since follow up tasks may have handlers again.
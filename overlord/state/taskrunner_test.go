@@ -31,6 +31,7 @@ import (
 	. "gopkg.in/check.v1"
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -667,6 +668,55 @@ func (ts *taskRunnerSuite) TestPrematureChangeReady(c *C) {
 	c.Assert(chg.Err(), IsNil)
 }
 
+func (ts *taskRunnerSuite) TestChangeWaitFor(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var order []string
+	ch := make(chan bool)
+	r.AddHandler("blocking", func(t *state.Task, tb *tomb.Tomb) error {
+		<-ch
+		order = append(order, t.Summary())
+		return errors.New("BAM")
+	}, nil)
+	r.AddHandler("noop", func(t *state.Task, tb *tomb.Tomb) error {
+		order = append(order, t.Summary())
+		return nil
+	}, nil)
+
+	st.Lock()
+	chg1 := st.NewChange("install", "...")
+	chg1.AddTask(st.NewTask("blocking", "first"))
+	chg2 := st.NewChange("remove", "...")
+	chg2.AddTask(st.NewTask("noop", "second"))
+	chg2.WaitFor(chg1)
+	st.Unlock()
+
+	r.Ensure()
+	r.Ensure()
+
+	st.Lock()
+	c.Check(chg2.Status(), Equals, state.DoStatus)
+	st.Unlock()
+
+	sb.ensureBefore = time.Hour
+	ch <- true
+	r.Wait()
+	// the first change becoming ready asks for another ensure
+	c.Check(sb.ensureBefore, Equals, time.Duration(0))
+
+	// a failed change still releases the queued one
+	ensureChange(c, r, sb, chg2)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg1.Status(), Equals, state.ErrorStatus)
+	c.Check(chg2.Status(), Equals, state.DoneStatus)
+	c.Check(order, DeepEquals, []string{"first", "second"})
+}
+
 func (ts *taskRunnerSuite) TestOptionalHandler(c *C) {
 	sb := &stateBackend{}
 	st := state.New(sb)
@@ -836,3 +886,96 @@ func (ts *taskRunnerSuite) TestCleanup(c *C) {
 	c.Assert(chgIsClean(), Equals, true)
 	c.Assert(called, Equals, 2)
 }
+
+func (ts *taskRunnerSuite) TestMetrics(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+
+	retried := false
+	r.AddHandler("download", func(t *state.Task, tomb *tomb.Tomb) error {
+		if !retried {
+			retried = true
+			return &state.Retry{}
+		}
+		return nil
+	}, func(t *state.Task, tomb *tomb.Tomb) error {
+		return nil
+	})
+	r.AddHandler("link", func(t *state.Task, tomb *tomb.Tomb) error {
+		return errors.New("boom")
+	}, func(t *state.Task, tomb *tomb.Tomb) error {
+		return nil
+	})
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+
+	// download asks to be retried at the next ensure
+	r.Ensure()
+	r.Wait()
+	ensureChange(c, r, sb, chg)
+	r.Stop()
+
+	series := make(map[string]map[string]uint64)
+	for _, m := range st.Metrics().Snapshot() {
+		series[m.Name] = make(map[string]uint64)
+		for _, s := range m.Series {
+			series[m.Name][s.LabelValue] = s.Count
+		}
+	}
+	c.Check(series, DeepEquals, map[string]map[string]uint64{
+		// the download task is queued once to do and once to undo
+		"snapd_task_queue_seconds": {"download": 2, "link": 1},
+		// and runs twice to do, then once to undo
+		"snapd_task_handler_seconds": {"download": 3, "link": 1},
+		"snapd_task_retries_total":   {"download": 1},
+		"snapd_task_errors_total":    {"link": 1},
+	})
+}
+
+func (ts *taskRunnerSuite) TestMetricsQueueTime(c *C) {
+	t0 := time.Now()
+	restore := state.MockTime(t0)
+	defer restore()
+
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+	r.AddHandler("foo", func(t *state.Task, tomb *tomb.Tomb) error { return nil }, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("foo", "...")
+	t2 := st.NewTask("bar", "...")
+	t1.WaitFor(t2)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	state.MockTime(t0.Add(10 * time.Second))
+	t2.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	// t1 could run as soon as t2 was done
+	state.MockTime(t0.Add(15 * time.Second))
+	r.Ensure()
+	r.Wait()
+
+	var queue *metrics.Metric
+	for _, m := range st.Metrics().Snapshot() {
+		if m.Name == "snapd_task_queue_seconds" {
+			queue = m
+		}
+	}
+	c.Assert(queue, NotNil)
+	c.Assert(queue.Series, HasLen, 1)
+	c.Check(queue.Series[0].LabelValue, Equals, "foo")
+	c.Check(queue.Series[0].Count, Equals, uint64(1))
+	c.Check(queue.Series[0].Sum, Equals, 5.0)
+}
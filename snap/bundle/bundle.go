@@ -0,0 +1,267 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package bundle implements the offline bundle format, a tar archive
+// carrying a set of snaps together with all the assertions needed to
+// install them on a device that cannot reach the store.
+//
+// A bundle holds a manifest.json describing it, an assertions file
+// with the assertion stream, in prerequisite order, and the snap files
+// under snaps/.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// Format is the version of the bundle format written by Writer.
+const Format = 1
+
+const (
+	manifestName   = "manifest.json"
+	assertionsName = "assertions"
+	snapsDir       = "snaps"
+)
+
+// Snap describes a snap carried by a bundle.
+type Snap struct {
+	Name     string        `json:"name"`
+	SnapID   string        `json:"snap-id,omitempty"`
+	Revision snap.Revision `json:"revision"`
+	// File is the name of the snap file in the bundle.
+	File string `json:"file"`
+}
+
+// Manifest describes the content of a bundle.
+type Manifest struct {
+	Format int     `json:"format"`
+	Snaps  []*Snap `json:"snaps"`
+}
+
+// Writer writes a bundle.
+type Writer struct {
+	tw            *tar.Writer
+	manifest      Manifest
+	hasAssertions bool
+}
+
+// NewWriter returns a Writer writing a bundle to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		tw:       tar.NewWriter(w),
+		manifest: Manifest{Format: Format},
+	}
+}
+
+func (w *Writer) add(name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsRune(name, '/')
+}
+
+// AddSnap adds the snap file at the given path to the bundle, under
+// the file name of s, which defaults to the base name of the path.
+func (w *Writer) AddSnap(s *Snap, snapPath string) error {
+	if s.File == "" {
+		s.File = filepath.Base(snapPath)
+	}
+	if !validFileName(s.File) {
+		return fmt.Errorf("invalid snap file name %q", s.File)
+	}
+	for _, other := range w.manifest.Snaps {
+		if other.Name == s.Name {
+			return fmt.Errorf("snap %q is already in the bundle", s.Name)
+		}
+		if other.File == s.File {
+			return fmt.Errorf("snap file %q is already in the bundle", s.File)
+		}
+	}
+
+	f, err := os.Open(snapPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := w.add(snapsDir+"/"+s.File, fi.Size(), f); err != nil {
+		return fmt.Errorf("cannot add snap %q to bundle: %v", s.Name, err)
+	}
+	w.manifest.Snaps = append(w.manifest.Snaps, s)
+	return nil
+}
+
+// AddAssertions adds the given stream of encoded assertions to the
+// bundle. It can be called only once.
+func (w *Writer) AddAssertions(data []byte) error {
+	if w.hasAssertions {
+		return fmt.Errorf("assertions are already in the bundle")
+	}
+	if err := w.add(assertionsName, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("cannot add assertions to bundle: %v", err)
+	}
+	w.hasAssertions = true
+	return nil
+}
+
+// Close writes the manifest and finishes the bundle.
+func (w *Writer) Close() error {
+	if !w.hasAssertions {
+		return fmt.Errorf("cannot write bundle without assertions")
+	}
+	if len(w.manifest.Snaps) == 0 {
+		return fmt.Errorf("cannot write bundle without snaps")
+	}
+	b, err := json.Marshal(&w.manifest)
+	if err != nil {
+		return err
+	}
+	if err := w.add(manifestName, int64(len(b)), bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("cannot add manifest to bundle: %v", err)
+	}
+	return w.tw.Close()
+}
+
+// Bundle is a bundle extracted to a directory.
+type Bundle struct {
+	Manifest
+	dir string
+}
+
+// AssertionsPath returns the path of the extracted assertion stream.
+func (b *Bundle) AssertionsPath() string {
+	return filepath.Join(b.dir, assertionsName)
+}
+
+// SnapPath returns the path of the extracted file of the given snap.
+func (b *Bundle) SnapPath(s *Snap) string {
+	return filepath.Join(b.dir, snapsDir, s.File)
+}
+
+func extractFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Extract extracts the bundle read from r into the given existing
+// directory, checking that it is well formed.
+func Extract(r io.Reader, dir string) (*Bundle, error) {
+	if err := os.Mkdir(filepath.Join(dir, snapsDir), 0700); err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{dir: dir}
+	hasManifest := false
+	hasAssertions := false
+	files := make(map[string]bool)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read bundle: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			return nil, fmt.Errorf("unexpected non-regular entry %q in bundle", hdr.Name)
+		}
+
+		switch {
+		case hdr.Name == manifestName && !hasManifest:
+			if err := json.NewDecoder(tr).Decode(&b.Manifest); err != nil {
+				return nil, fmt.Errorf("cannot decode bundle manifest: %v", err)
+			}
+			hasManifest = true
+		case hdr.Name == assertionsName && !hasAssertions:
+			if err := extractFile(b.AssertionsPath(), tr); err != nil {
+				return nil, fmt.Errorf("cannot extract bundle assertions: %v", err)
+			}
+			hasAssertions = true
+		case strings.HasPrefix(hdr.Name, snapsDir+"/") && validFileName(hdr.Name[len(snapsDir)+1:]):
+			name := hdr.Name[len(snapsDir)+1:]
+			if files[name] {
+				return nil, fmt.Errorf("duplicate entry %q in bundle", hdr.Name)
+			}
+			if err := extractFile(filepath.Join(dir, snapsDir, name), tr); err != nil {
+				return nil, fmt.Errorf("cannot extract %q from bundle: %v", hdr.Name, err)
+			}
+			files[name] = true
+		default:
+			return nil, fmt.Errorf("unexpected entry %q in bundle", hdr.Name)
+		}
+	}
+
+	if !hasManifest {
+		return nil, fmt.Errorf("cannot find manifest in bundle")
+	}
+	if b.Format != Format {
+		return nil, fmt.Errorf("unsupported bundle format %d", b.Format)
+	}
+	if !hasAssertions {
+		return nil, fmt.Errorf("cannot find assertions in bundle")
+	}
+	if len(b.Snaps) == 0 {
+		return nil, fmt.Errorf("bundle has no snaps")
+	}
+	names := make(map[string]bool, len(b.Snaps))
+	for _, s := range b.Snaps {
+		if err := snap.ValidateName(s.Name); err != nil {
+			return nil, fmt.Errorf("invalid bundle manifest: %v", err)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("invalid bundle manifest: snap %q is listed more than once", s.Name)
+		}
+		names[s.Name] = true
+		if !validFileName(s.File) || !files[s.File] {
+			return nil, fmt.Errorf("cannot find file %q of snap %q in bundle", s.File, s.Name)
+		}
+	}
+	return b, nil
+}
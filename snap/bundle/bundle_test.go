@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bundle_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/bundle"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type bundleSuite struct{}
+
+var _ = Suite(&bundleSuite{})
+
+func (s *bundleSuite) writeBundle(c *C) []byte {
+	dir := c.MkDir()
+	fooPath := filepath.Join(dir, "foo_7.snap")
+	c.Assert(ioutil.WriteFile(fooPath, []byte("foo-content"), 0644), IsNil)
+	barPath := filepath.Join(dir, "bar_3.snap")
+	c.Assert(ioutil.WriteFile(barPath, []byte("bar-content"), 0644), IsNil)
+
+	var buf bytes.Buffer
+	w := bundle.NewWriter(&buf)
+	c.Assert(w.AddSnap(&bundle.Snap{Name: "foo", SnapID: "foo-id", Revision: snap.R(7)}, fooPath), IsNil)
+	c.Assert(w.AddSnap(&bundle.Snap{Name: "bar", Revision: snap.R(3), File: "bar.snap"}, barPath), IsNil)
+	c.Assert(w.AddAssertions([]byte("assertions-stream")), IsNil)
+	c.Assert(w.Close(), IsNil)
+	return buf.Bytes()
+}
+
+func (s *bundleSuite) TestRoundTrip(c *C) {
+	data := s.writeBundle(c)
+
+	dir := c.MkDir()
+	b, err := bundle.Extract(bytes.NewReader(data), dir)
+	c.Assert(err, IsNil)
+	c.Check(b.Format, Equals, bundle.Format)
+	c.Check(b.Snaps, DeepEquals, []*bundle.Snap{
+		{Name: "foo", SnapID: "foo-id", Revision: snap.R(7), File: "foo_7.snap"},
+		{Name: "bar", Revision: snap.R(3), File: "bar.snap"},
+	})
+
+	c.Check(b.AssertionsPath(), Equals, filepath.Join(dir, "assertions"))
+	content, err := ioutil.ReadFile(b.AssertionsPath())
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "assertions-stream")
+
+	c.Check(b.SnapPath(b.Snaps[0]), Equals, filepath.Join(dir, "snaps", "foo_7.snap"))
+	content, err = ioutil.ReadFile(b.SnapPath(b.Snaps[0]))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "foo-content")
+	content, err = ioutil.ReadFile(b.SnapPath(b.Snaps[1]))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "bar-content")
+}
+
+func (s *bundleSuite) TestWriterErrors(c *C) {
+	dir := c.MkDir()
+	fooPath := filepath.Join(dir, "foo.snap")
+	c.Assert(ioutil.WriteFile(fooPath, []byte("foo"), 0644), IsNil)
+
+	w := bundle.NewWriter(ioutil.Discard)
+	c.Check(w.Close(), ErrorMatches, `cannot write bundle without assertions`)
+	c.Assert(w.AddAssertions(nil), IsNil)
+	c.Check(w.AddAssertions(nil), ErrorMatches, `assertions are already in the bundle`)
+	c.Check(w.Close(), ErrorMatches, `cannot write bundle without snaps`)
+
+	c.Check(w.AddSnap(&bundle.Snap{Name: "foo", File: "../foo.snap"}, fooPath), ErrorMatches, `invalid snap file name "../foo.snap"`)
+	c.Check(w.AddSnap(&bundle.Snap{Name: "foo"}, filepath.Join(dir, "missing.snap")), ErrorMatches, `open .*/missing.snap: no such file or directory`)
+	c.Assert(w.AddSnap(&bundle.Snap{Name: "foo"}, fooPath), IsNil)
+	c.Check(w.AddSnap(&bundle.Snap{Name: "foo", File: "other.snap"}, fooPath), ErrorMatches, `snap "foo" is already in the bundle`)
+	c.Check(w.AddSnap(&bundle.Snap{Name: "bar"}, fooPath), ErrorMatches, `snap file "foo.snap" is already in the bundle`)
+}
+
+type entry struct {
+	name     string
+	content  string
+	typeflag byte
+}
+
+func makeTar(c *C, entries ...entry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		err := tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Mode:     0644,
+			Size:     int64(len(e.content)),
+			Typeflag: typeflag,
+		})
+		c.Assert(err, IsNil)
+		_, err = tw.Write([]byte(e.content))
+		c.Assert(err, IsNil)
+	}
+	c.Assert(tw.Close(), IsNil)
+	return buf.Bytes()
+}
+
+func (s *bundleSuite) TestExtractErrors(c *C) {
+	manifest := entry{name: "manifest.json", content: `{"format": 1, "snaps": [{"name": "foo", "revision": "1", "file": "foo.snap"}]}`}
+	assertions := entry{name: "assertions", content: "..."}
+	fooSnap := entry{name: "snaps/foo.snap", content: "foo"}
+
+	tests := []struct {
+		entries []entry
+		err     string
+	}{
+		{[]entry{assertions, fooSnap}, `cannot find manifest in bundle`},
+		{[]entry{manifest, fooSnap}, `cannot find assertions in bundle`},
+		{[]entry{manifest, assertions}, `cannot find file "foo.snap" of snap "foo" in bundle`},
+		{[]entry{{name: "manifest.json", content: `{"format": 2}`}}, `unsupported bundle format 2`},
+		{[]entry{{name: "manifest.json", content: `{`}}, `cannot decode bundle manifest: .*`},
+		{[]entry{{name: "manifest.json", content: `{"format": 1}`}, assertions}, `bundle has no snaps`},
+		{[]entry{{name: "manifest.json", content: `{"format": 1, "snaps": [{"name": "Foo", "file": "foo.snap"}]}`}, assertions, fooSnap}, `invalid bundle manifest: invalid snap name: "Foo"`},
+		{[]entry{{name: "manifest.json", content: `{"format": 1, "snaps": [{"name": "foo", "file": "foo.snap"}, {"name": "foo", "file": "foo.snap"}]}`}, assertions, fooSnap}, `invalid bundle manifest: snap "foo" is listed more than once`},
+		{[]entry{manifest, assertions, fooSnap, fooSnap}, `duplicate entry "snaps/foo.snap" in bundle`},
+		{[]entry{manifest, manifest}, `unexpected entry "manifest.json" in bundle`},
+		{[]entry{{name: "snaps/../../etc/passwd", content: "x"}}, `unexpected entry "snaps/../../etc/passwd" in bundle`},
+		{[]entry{{name: "other", content: "x"}}, `unexpected entry "other" in bundle`},
+		{[]entry{{name: "snaps/foo.snap", typeflag: tar.TypeSymlink}}, `unexpected non-regular entry "snaps/foo.snap" in bundle`},
+	}
+	for _, t := range tests {
+		_, err := bundle.Extract(bytes.NewReader(makeTar(c, t.entries...)), c.MkDir())
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.entries))
+	}
+
+	_, err := bundle.Extract(bytes.NewReader([]byte("not a tar")), c.MkDir())
+	c.Check(err, ErrorMatches, `cannot read bundle: .*`)
+}
@@ -0,0 +1,239 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConfigOption describes a single configuration option declared in the
+// config section of snap.yaml.
+type ConfigOption struct {
+	Type        string                   `json:"type"`
+	Description string                   `json:"description,omitempty"`
+	Default     interface{}              `json:"default,omitempty"`
+	Enum        []interface{}            `json:"enum,omitempty"`
+	Minimum     *float64                 `json:"minimum,omitempty"`
+	Maximum     *float64                 `json:"maximum,omitempty"`
+	Properties  map[string]*ConfigOption `json:"properties,omitempty"`
+}
+
+// ConfigOption returns the declaration of the option at the given
+// dotted key path, or nil if the snap declares no such option.
+func (s *Info) ConfigOption(key string) *ConfigOption {
+	options := s.Config
+	var option *ConfigOption
+	for _, subkey := range strings.Split(key, ".") {
+		option = options[subkey]
+		if option == nil {
+			return nil
+		}
+		options = option.Properties
+	}
+	return option
+}
+
+// ConfigDefaults returns the defaults declared for the options of the
+// snap, keyed by their dotted key paths. Defaults of nested options are
+// only returned when their parent option has no default of its own.
+func (s *Info) ConfigDefaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+	collectConfigDefaults("", s.Config, defaults)
+	return defaults
+}
+
+func collectConfigDefaults(prefix string, options map[string]*ConfigOption, defaults map[string]interface{}) {
+	for name, option := range options {
+		key := prefix + name
+		if option.Default != nil {
+			defaults[key] = option.Default
+			continue
+		}
+		collectConfigDefaults(key+".", option.Properties, defaults)
+	}
+}
+
+var validConfigKey = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+
+var validConfigTypes = map[string]bool{
+	"string":  true,
+	"integer": true,
+	"number":  true,
+	"boolean": true,
+	"array":   true,
+	"object":  true,
+}
+
+// ValidateConfig checks that the declarations of the config section are
+// consistent, including their defaults and enumerated values.
+func ValidateConfig(options map[string]*ConfigOption) error {
+	return validateConfigOptions("", options)
+}
+
+func validateConfigOptions(prefix string, options map[string]*ConfigOption) error {
+	for name, option := range options {
+		key := prefix + name
+		if !validConfigKey.MatchString(name) {
+			return fmt.Errorf("invalid config option name: %q", key)
+		}
+		if err := validateConfigOption(key, option); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateConfigOption(key string, option *ConfigOption) error {
+	if !validConfigTypes[option.Type] {
+		return fmt.Errorf("config option %q has invalid type %q", key, option.Type)
+	}
+	if option.Minimum != nil || option.Maximum != nil {
+		if option.Type != "integer" && option.Type != "number" {
+			return fmt.Errorf("config option %q of type %s cannot have a minimum or maximum", key, option.Type)
+		}
+		if option.Minimum != nil && option.Maximum != nil && *option.Minimum > *option.Maximum {
+			return fmt.Errorf("config option %q has a minimum greater than its maximum", key)
+		}
+	}
+	if len(option.Properties) > 0 {
+		if option.Type != "object" {
+			return fmt.Errorf("config option %q of type %s cannot have properties", key, option.Type)
+		}
+		if err := validateConfigOptions(key+".", option.Properties); err != nil {
+			return err
+		}
+	}
+	if len(option.Enum) > 0 {
+		if option.Type == "array" || option.Type == "object" {
+			return fmt.Errorf("config option %q of type %s cannot have an enum", key, option.Type)
+		}
+		// the enumeration itself is checked without it
+		plain := *option
+		plain.Enum = nil
+		for _, value := range option.Enum {
+			if err := plain.Validate(key, value); err != nil {
+				return fmt.Errorf("config option %q has invalid enum value: %v", key, err)
+			}
+		}
+	}
+	if option.Default != nil {
+		if err := option.Validate(key, option.Default); err != nil {
+			return fmt.Errorf("config option %q has invalid default: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the value of the option at the given dotted key path
+// against the declaration of the option.
+func (o *ConfigOption) Validate(key string, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch o.Type {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("invalid value for option %q: must be a string", key)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("invalid value for option %q: must be a boolean", key)
+		}
+	case "integer", "number":
+		n, ok := configNumber(value)
+		if !ok {
+			return fmt.Errorf("invalid value for option %q: must be a number", key)
+		}
+		if o.Type == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("invalid value for option %q: must be an integer", key)
+		}
+		if o.Minimum != nil && n < *o.Minimum {
+			return fmt.Errorf("invalid value for option %q: must be at least %v", key, *o.Minimum)
+		}
+		if o.Maximum != nil && n > *o.Maximum {
+			return fmt.Errorf("invalid value for option %q: must be at most %v", key, *o.Maximum)
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Errorf("invalid value for option %q: must be an array", key)
+		}
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid value for option %q: must be an object", key)
+		}
+		for name, property := range o.Properties {
+			if err := property.Validate(key+"."+name, m[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(o.Enum) > 0 && !configEnumContains(o.Enum, value) {
+		choices := make([]string, len(o.Enum))
+		for i, choice := range o.Enum {
+			choices[i] = fmt.Sprintf("%q", fmt.Sprint(choice))
+		}
+		sort.Strings(choices)
+		return fmt.Errorf("invalid value for option %q: must be one of %s", key, strings.Join(choices, ", "))
+	}
+
+	return nil
+}
+
+// configNumber returns the value as a float64 if it is a number, be it
+// decoded from JSON or from YAML.
+func configNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func configEnumContains(enum []interface{}, value interface{}) bool {
+	n, isNumber := configNumber(value)
+	for _, choice := range enum {
+		switch value.(type) {
+		case string, bool:
+			if choice == value {
+				return true
+			}
+		default:
+			if m, ok := configNumber(choice); isNumber && ok && m == n {
+				return true
+			}
+		}
+	}
+	return false
+}
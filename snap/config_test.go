@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type configSuite struct{}
+
+var _ = Suite(&configSuite{})
+
+const configSnapYaml = `
+name: foo
+version: 1.0
+config:
+  port:
+    type: integer
+    default: 8080
+    minimum: 1
+    maximum: 65535
+  ratio:
+    type: number
+    maximum: 1
+  mode:
+    type: string
+    enum: [fast, slow]
+  debug:
+    type: boolean
+  hosts:
+    type: array
+  server:
+    type: object
+    properties:
+      host:
+        type: string
+        default: localhost
+      retries:
+        type: integer
+        enum: [1, 3, 5]
+`
+
+func (s *configSuite) TestConfigOption(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(configSnapYaml))
+	c.Assert(err, IsNil)
+
+	c.Check(info.ConfigOption("port"), Equals, info.Config["port"])
+	c.Check(info.ConfigOption("server.host"), Equals, info.Config["server"].Properties["host"])
+	c.Check(info.ConfigOption("server.other"), IsNil)
+	c.Check(info.ConfigOption("port.other"), IsNil)
+	c.Check(info.ConfigOption("other"), IsNil)
+}
+
+func (s *configSuite) TestConfigDefaults(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(configSnapYaml))
+	c.Assert(err, IsNil)
+
+	c.Check(info.ConfigDefaults(), DeepEquals, map[string]interface{}{
+		"port":        int64(8080),
+		"server.host": "localhost",
+	})
+}
+
+func (s *configSuite) TestValidateValues(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(configSnapYaml))
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"port", json.Number("80"), ""},
+		{"port", int64(80), ""},
+		{"port", json.Number("80.5"), `invalid value for option "port": must be an integer`},
+		{"port", "80", `invalid value for option "port": must be a number`},
+		{"port", json.Number("0"), `invalid value for option "port": must be at least 1`},
+		{"port", json.Number("65536"), `invalid value for option "port": must be at most 65535`},
+		{"ratio", json.Number("0.5"), ""},
+		{"ratio", json.Number("1.5"), `invalid value for option "ratio": must be at most 1`},
+		{"mode", "fast", ""},
+		{"mode", "medium", `invalid value for option "mode": must be one of "fast", "slow"`},
+		{"mode", true, `invalid value for option "mode": must be a string`},
+		{"debug", true, ""},
+		{"debug", "yes", `invalid value for option "debug": must be a boolean`},
+		{"hosts", []interface{}{"a", "b"}, ""},
+		{"hosts", "a", `invalid value for option "hosts": must be an array`},
+		{"server", map[string]interface{}{"host": "example.com", "other": 1}, ""},
+		{"server", "example.com", `invalid value for option "server": must be an object`},
+		{"server", map[string]interface{}{"host": json.Number("1")}, `invalid value for option "server.host": must be a string`},
+		{"server.retries", json.Number("3"), ""},
+		{"server.retries", json.Number("2"), `invalid value for option "server.retries": must be one of "1", "3", "5"`},
+		{"server.retries", map[string]interface{}{}, `invalid value for option "server.retries": must be a number`},
+	} {
+		err := info.ConfigOption(t.key).Validate(t.key, t.value)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%s: %v", t.key, t.value))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%s: %v", t.key, t.value))
+		}
+	}
+}
+
+func (s *configSuite) TestValidateConfig(c *C) {
+	_, err := snap.InfoFromSnapYaml([]byte(configSnapYaml))
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"Bad:\n  type: string", `invalid config option name: "Bad"`},
+		{"foo:\n  type: potato", `config option "foo" has invalid type "potato"`},
+		{"foo:\n  type: string\n  minimum: 1", `config option "foo" of type string cannot have a minimum or maximum`},
+		{"foo:\n  type: integer\n  minimum: 2\n  maximum: 1", `config option "foo" has a minimum greater than its maximum`},
+		{"foo:\n  type: string\n  properties:\n    bar:\n      type: string", `config option "foo" of type string cannot have properties`},
+		{"foo:\n  type: object\n  properties:\n    bar:\n      type: potato", `config option "foo.bar" has invalid type "potato"`},
+		{"foo:\n  type: object\n  properties:\n    Bar:\n      type: string", `invalid config option name: "foo.Bar"`},
+		{"foo:\n  type: array\n  enum: [1]", `config option "foo" of type array cannot have an enum`},
+		{"foo:\n  type: string\n  enum: [a, 1]", `config option "foo" has invalid enum value: invalid value for option "foo": must be a string`},
+		{"foo:\n  type: string\n  enum: [a]\n  default: b", `config option "foo" has invalid default: invalid value for option "foo": must be one of "a"`},
+		{"foo:\n  type: integer\n  maximum: 1\n  default: 2", `config option "foo" has invalid default: invalid value for option "foo": must be at most 1`},
+	} {
+		info, err := snap.InfoFromSnapYaml([]byte("name: foo\nversion: 1.0\nconfig:\n  " + indent(t.yaml)))
+		c.Assert(err, IsNil, Commentf(t.yaml))
+		c.Check(snap.Validate(info), ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
+
+func indent(s string) string {
+	out := []byte{}
+	for _, b := range []byte(s) {
+		out = append(out, b)
+		if b == '\n' {
+			out = append(out, ' ', ' ')
+		}
+	}
+	return string(out)
+}
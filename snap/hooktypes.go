@@ -30,8 +30,10 @@ var supportedHooks = []*HookType{
 	newHookType(regexp.MustCompile("^pre-refresh$")),
 	newHookType(regexp.MustCompile("^post-refresh$")),
 	newHookType(regexp.MustCompile("^remove$")),
+	newHookType(regexp.MustCompile("^check-health$")),
 	newHookType(regexp.MustCompile("^prepare-(?:plug|slot)-[-a-z0-9]+$")),
 	newHookType(regexp.MustCompile("^connect-(?:plug|slot)-[-a-z0-9]+$")),
+	newHookType(regexp.MustCompile("^disconnect-(?:plug|slot)-[-a-z0-9]+$")),
 }
 
 // HookType represents a pattern of supported hook names.
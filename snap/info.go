@@ -194,6 +194,14 @@ type Info struct {
 	Tracks []string
 
 	Layout map[string]*Layout
+
+	// Config holds the declarations of the configuration options of
+	// the snap, keyed by option name.
+	Config map[string]*ConfigOption
+
+	// QuotaSlice is the systemd slice of the quota group the snap
+	// belongs to, if any; the services of the snap are placed in it.
+	QuotaSlice string
 }
 
 // Layout describes a single element of the layout section.
@@ -500,6 +508,13 @@ type SocketInfo struct {
 	SocketMode   os.FileMode
 }
 
+// TimerInfo provides information on application timer.
+type TimerInfo struct {
+	App *AppInfo
+
+	Timer string
+}
+
 // AppInfo provides information about a app.
 type AppInfo struct {
 	Snap *Info
@@ -527,10 +542,18 @@ type AppInfo struct {
 
 	Environment strutil.OrderedMap
 
+	// Timer, if set, activates the service periodically according
+	// to its schedule
+	Timer *TimerInfo
+
 	// list of other service names that this service will start after or
 	// before
 	After  []string
 	Before []string
+
+	// Resources, if set, limits the system resources the service
+	// can use
+	Resources *ResourceLimits
 }
 
 // ScreenshotInfo provides information about a screenshot.
@@ -547,6 +570,10 @@ type HookInfo struct {
 	Name  string
 	Plugs map[string]*PlugInfo
 	Slots map[string]*SlotInfo
+
+	// Timeout, if set, is the time the hook is allowed to run
+	// instead of the default of snapd
+	Timeout timeout.Timeout
 }
 
 // File returns the path to the file
@@ -554,6 +581,11 @@ func (socket *SocketInfo) File() string {
 	return filepath.Join(dirs.SnapServicesDir, socket.App.SecurityTag()+"."+socket.Name+".socket")
 }
 
+// File returns the path to the *.timer file
+func (timer *TimerInfo) File() string {
+	return filepath.Join(dirs.SnapServicesDir, timer.App.SecurityTag()+".timer")
+}
+
 // SecurityTag returns application-specific security tag.
 //
 // Security tags are used by various security subsystems as "profile names" and
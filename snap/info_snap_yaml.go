@@ -53,6 +53,7 @@ type snapYaml struct {
 	Apps             map[string]appYaml     `yaml:"apps,omitempty"`
 	Hooks            map[string]hookYaml    `yaml:"hooks,omitempty"`
 	Layout           map[string]layoutYaml  `yaml:"layout,omitempty"`
+	Config           map[string]configYaml  `yaml:"config,omitempty"`
 }
 
 type appYaml struct {
@@ -80,11 +81,16 @@ type appYaml struct {
 
 	After  []string `yaml:"after,omitempty"`
 	Before []string `yaml:"before,omitempty"`
+
+	Timer string `yaml:"timer,omitempty"`
+
+	Resources map[string]interface{} `yaml:"resources,omitempty"`
 }
 
 type hookYaml struct {
-	PlugNames []string `yaml:"plugs,omitempty"`
-	SlotNames []string `yaml:"slots,omitempty"`
+	PlugNames []string        `yaml:"plugs,omitempty"`
+	SlotNames []string        `yaml:"slots,omitempty"`
+	Timeout   timeout.Timeout `yaml:"timeout,omitempty"`
 }
 
 type layoutYaml struct {
@@ -96,6 +102,16 @@ type layoutYaml struct {
 	Symlink string `yaml:"symlink,omitempty"`
 }
 
+type configYaml struct {
+	Type        string                `yaml:"type"`
+	Description string                `yaml:"description,omitempty"`
+	Default     interface{}           `yaml:"default,omitempty"`
+	Enum        []interface{}         `yaml:"enum,omitempty"`
+	Minimum     *float64              `yaml:"minimum,omitempty"`
+	Maximum     *float64              `yaml:"maximum,omitempty"`
+	Properties  map[string]configYaml `yaml:"properties,omitempty"`
+}
+
 type socketsYaml struct {
 	ListenStream string      `yaml:"listen-stream,omitempty"`
 	SocketMode   os.FileMode `yaml:"socket-mode,omitempty"`
@@ -172,6 +188,15 @@ func InfoFromSnapYaml(yamlData []byte) (*Info, error) {
 		}
 	}
 
+	// Collect config option declarations.
+	if y.Config != nil {
+		config, err := configFromSnapYaml("", y.Config)
+		if err != nil {
+			return nil, err
+		}
+		snap.Config = config
+	}
+
 	// Rename specific plugs on the core snap.
 	snap.renameClashingCorePlugs()
 
@@ -350,6 +375,18 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info) error {
 				SocketMode:   data.SocketMode,
 			}
 		}
+		if yApp.Timer != "" {
+			app.Timer = &TimerInfo{
+				App:   app,
+				Timer: yApp.Timer,
+			}
+		}
+		if len(yApp.Resources) > 0 {
+			app.Resources = &ResourceLimits{}
+			if err := SetResourceLimits(app.Resources, yApp.Resources); err != nil {
+				return fmt.Errorf("cannot parse resources of app %q: %v", appName, err)
+			}
+		}
 	}
 	return nil
 }
@@ -362,8 +399,9 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info) {
 
 		// Collect all hooks
 		hook := &HookInfo{
-			Snap: snap,
-			Name: hookName,
+			Snap:    snap,
+			Name:    hookName,
+			Timeout: yHook.Timeout,
 		}
 		if len(y.Plugs) > 0 || len(yHook.PlugNames) > 0 {
 			hook.Plugs = make(map[string]*PlugInfo)
@@ -567,3 +605,39 @@ func normalizeYamlValue(v interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("invalid scalar: %v", v)
 	}
 }
+
+func configFromSnapYaml(prefix string, options map[string]configYaml) (map[string]*ConfigOption, error) {
+	config := make(map[string]*ConfigOption, len(options))
+	for name, o := range options {
+		key := prefix + name
+		option := &ConfigOption{
+			Type:        o.Type,
+			Description: o.Description,
+			Minimum:     o.Minimum,
+			Maximum:     o.Maximum,
+		}
+		if o.Default != nil {
+			def, err := normalizeYamlValue(o.Default)
+			if err != nil {
+				return nil, fmt.Errorf("config option %q has invalid default: %v", key, err)
+			}
+			option.Default = def
+		}
+		for _, choice := range o.Enum {
+			value, err := normalizeYamlValue(choice)
+			if err != nil {
+				return nil, fmt.Errorf("config option %q has invalid enum value: %v", key, err)
+			}
+			option.Enum = append(option.Enum, value)
+		}
+		if o.Properties != nil {
+			properties, err := configFromSnapYaml(key+".", o.Properties)
+			if err != nil {
+				return nil, err
+			}
+			option.Properties = properties
+		}
+		config[name] = option
+	}
+	return config, nil
+}
@@ -884,6 +884,19 @@ hooks:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalHookWithTimeout(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+hooks:
+    test-hook:
+        timeout: 25m
+`))
+	c.Assert(err, IsNil)
+	c.Assert(info.Hooks["test-hook"], NotNil)
+	c.Check(info.Hooks["test-hook"].Timeout, Equals, timeout.Timeout(25*time.Minute))
+}
+
 func (s *YamlSuite) TestUnmarshalUnsupportedHook(c *C) {
 	s.restore()
 	hookType := snap.NewHookType(regexp.MustCompile("not-test-hook"))
@@ -1437,6 +1450,34 @@ apps:
 	c.Check(info.Apps, DeepEquals, map[string]*snap.AppInfo{"svc": &app})
 }
 
+func (s *YamlSuite) TestDaemonTimer(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ svc:
+   command: svc
+   daemon: oneshot
+   timer: mon,10:00-12:00
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+
+	app := snap.AppInfo{
+		Snap:    info,
+		Name:    "svc",
+		Command: "svc",
+		Daemon:  "oneshot",
+	}
+	app.Timer = &snap.TimerInfo{
+		App:   &app,
+		Timer: "mon,10:00-12:00",
+	}
+
+	c.Check(info.Apps, DeepEquals, map[string]*snap.AppInfo{
+		"svc": &app,
+	})
+}
+
 func (s *YamlSuite) TestDaemonListenStreamAsInteger(c *C) {
 	y := []byte(`name: wat
 version: 42
@@ -1628,3 +1669,100 @@ layout:
 		Mode:    0755,
 	})
 }
+
+func (s *YamlSuite) TestConfig(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+config:
+  port:
+    type: integer
+    description: the port to listen on
+    default: 8080
+    minimum: 1
+    maximum: 65535
+  mode:
+    type: string
+    enum: [fast, slow]
+  server:
+    type: object
+    default: {host: localhost}
+    properties:
+      host:
+        type: string
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	min, max := float64(1), float64(65535)
+	c.Check(info.Config, DeepEquals, map[string]*snap.ConfigOption{
+		"port": {
+			Type:        "integer",
+			Description: "the port to listen on",
+			Default:     int64(8080),
+			Minimum:     &min,
+			Maximum:     &max,
+		},
+		"mode": {
+			Type: "string",
+			Enum: []interface{}{"fast", "slow"},
+		},
+		"server": {
+			Type:    "object",
+			Default: map[string]interface{}{"host": "localhost"},
+			Properties: map[string]*snap.ConfigOption{
+				"host": {Type: "string"},
+			},
+		},
+	})
+}
+
+func (s *YamlSuite) TestConfigInvalidDefault(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+config:
+  port:
+    type: object
+    default: {1: 2}
+`)
+	_, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, ErrorMatches, `config option "port" has invalid default: non-string key: 1`)
+}
+
+func (s *YamlSuite) TestAppResources(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+  svc:
+    daemon: simple
+    resources:
+      memory: 1G
+      cpu-quota: 25%
+      tasks: 32
+  other:
+    daemon: simple
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Apps["svc"].Resources, DeepEquals, &snap.ResourceLimits{
+		Memory:   1 << 30,
+		CPUQuota: 25,
+		Tasks:    32,
+	})
+	c.Check(info.Apps["other"].Resources, IsNil)
+}
+
+func (s *YamlSuite) TestAppResourcesInvalid(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+  svc:
+    daemon: simple
+    resources:
+      memory: lots
+`)
+	_, err := snap.InfoFromSnapYaml(y)
+	c.Check(err, ErrorMatches, `cannot parse resources of app "svc": invalid memory limit "lots"`)
+}
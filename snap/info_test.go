@@ -774,6 +774,20 @@ apps:
 	c.Check(socket.File(), Equals, dirs.GlobalRootDir+"/etc/systemd/system/snap.pans.app1.sock1.socket")
 }
 
+func (s *infoSuite) TestTimerFile(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: pans
+apps:
+  app1:
+    daemon: true
+    timer: mon,10:00-12:00
+`))
+
+	c.Assert(err, IsNil)
+
+	app := info.Apps["app1"]
+	c.Check(app.Timer.File(), Equals, dirs.GlobalRootDir+"/etc/systemd/system/snap.pans.app1.timer")
+}
+
 func (s *infoSuite) TestLayoutParsing(c *C) {
 	info, err := snap.InfoFromSnapYaml([]byte(`name: layout-demo
 layout:
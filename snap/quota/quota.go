@@ -0,0 +1,206 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package quota defines the quota groups that limit the combined
+// resources used by the services of a set of snaps.
+package quota
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Group is a named set of snaps whose services share memory and CPU
+// limits. Groups can be nested, the limits of a sub-group applying
+// within the limits of its parent group.
+type Group struct {
+	Name string `json:"name"`
+
+	// MemoryLimit is the maximum amount of memory, in bytes, the
+	// services of the group can use together, or zero if unlimited.
+	MemoryLimit int64 `json:"memory-limit,omitempty"`
+	// CPUQuota is the percentage of the time of a single CPU the
+	// services of the group can use together, or zero if unlimited.
+	CPUQuota int `json:"cpu-quota,omitempty"`
+
+	ParentGroup string   `json:"parent-group,omitempty"`
+	SubGroups   []string `json:"sub-groups,omitempty"`
+	Snaps       []string `json:"snaps,omitempty"`
+
+	parentGroup *Group
+	subGroups   []*Group
+}
+
+var validGroupName = regexp.MustCompile("^[a-z0-9](?:-?[a-z0-9])*$")
+
+// ValidateGroupName checks that the name of a quota group is valid.
+func ValidateGroupName(name string) error {
+	if len(name) > 40 || !validGroupName.MatchString(name) {
+		return fmt.Errorf("invalid quota group name %q", name)
+	}
+	return nil
+}
+
+// Validate checks that the group has a valid name and sensible
+// limits, without considering the other groups.
+func (grp *Group) Validate() error {
+	if err := ValidateGroupName(grp.Name); err != nil {
+		return err
+	}
+	if grp.MemoryLimit < 0 || grp.CPUQuota < 0 {
+		return fmt.Errorf("quota group %q cannot have negative limits", grp.Name)
+	}
+	if grp.MemoryLimit == 0 && grp.CPUQuota == 0 {
+		return fmt.Errorf("quota group %q must have a memory limit or a CPU quota", grp.Name)
+	}
+	return nil
+}
+
+// SliceName returns the name of the systemd slice of the group. The
+// slices of sub-groups are nested in the slices of their parents,
+// following the naming scheme of systemd. The cross references of the
+// group must have been resolved.
+func (grp *Group) SliceName() string {
+	var names []string
+	for g := grp; g != nil; g = g.parentGroup {
+		// dashes separate the levels of the slice hierarchy
+		names = append([]string{strings.Replace(g.Name, "-", `\x2d`, -1)}, names...)
+	}
+	return "snap." + strings.Join(names, "-") + ".slice"
+}
+
+// Parent returns the parent group, if any. The cross references of
+// the group must have been resolved.
+func (grp *Group) Parent() *Group {
+	return grp.parentGroup
+}
+
+// AllSnaps returns the snaps of the group and of all its sub-groups.
+// The cross references of the group must have been resolved.
+func (grp *Group) AllSnaps() []string {
+	snaps := append([]string(nil), grp.Snaps...)
+	for _, sub := range grp.subGroups {
+		snaps = append(snaps, sub.AllSnaps()...)
+	}
+	sort.Strings(snaps)
+	return snaps
+}
+
+// ResolveCrossReferences links the groups, keyed by name, with their
+// parents and sub-groups and checks that together they form a valid
+// hierarchy: references must be consistent, a snap can be in only
+// one group, and the limits of sub-groups cannot add up to more than
+// the limits of their parent.
+func ResolveCrossReferences(groups map[string]*Group) error {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snapGroup := make(map[string]string)
+	for _, name := range names {
+		grp := groups[name]
+		if grp.Name != name {
+			return fmt.Errorf("quota group %q has inconsistent name %q", name, grp.Name)
+		}
+		if err := grp.Validate(); err != nil {
+			return err
+		}
+		for _, snapName := range grp.Snaps {
+			if other, ok := snapGroup[snapName]; ok {
+				return fmt.Errorf("snap %q cannot be in both quota groups %q and %q", snapName, other, name)
+			}
+			snapGroup[snapName] = name
+		}
+
+		grp.parentGroup = nil
+		if grp.ParentGroup != "" {
+			parent := groups[grp.ParentGroup]
+			if parent == nil {
+				return fmt.Errorf("quota group %q has missing parent group %q", name, grp.ParentGroup)
+			}
+			grp.parentGroup = parent
+		}
+		grp.subGroups = nil
+		for _, subName := range grp.SubGroups {
+			sub := groups[subName]
+			if sub == nil {
+				return fmt.Errorf("quota group %q has missing sub-group %q", name, subName)
+			}
+			if sub.ParentGroup != name {
+				return fmt.Errorf("quota group %q has sub-group %q with a different parent", name, subName)
+			}
+			grp.subGroups = append(grp.subGroups, sub)
+		}
+	}
+
+	for _, name := range names {
+		grp := groups[name]
+		if parent := grp.parentGroup; parent != nil && !hasSubGroup(parent, grp) {
+			return fmt.Errorf("quota group %q is not a sub-group of its parent group %q", name, parent.Name)
+		}
+		// with consistent references a cycle cannot reach the
+		// top, so every group must have a root among its ancestors
+		depth := 0
+		for g := grp; g.parentGroup != nil; g = g.parentGroup {
+			depth++
+			if depth > len(groups) {
+				return fmt.Errorf("quota group %q is nested in itself", name)
+			}
+		}
+		if err := validateSubGroupLimits(grp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasSubGroup(grp, sub *Group) bool {
+	for _, g := range grp.subGroups {
+		if g == sub {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSubGroupLimits(grp *Group) error {
+	var memory int64
+	var cpu int
+	for _, sub := range grp.subGroups {
+		if grp.MemoryLimit != 0 && sub.MemoryLimit > grp.MemoryLimit {
+			return fmt.Errorf("memory limit of quota group %q is larger than the limit of its parent group %q", sub.Name, grp.Name)
+		}
+		if grp.CPUQuota != 0 && sub.CPUQuota > grp.CPUQuota {
+			return fmt.Errorf("CPU quota of quota group %q is larger than the quota of its parent group %q", sub.Name, grp.Name)
+		}
+		memory += sub.MemoryLimit
+		cpu += sub.CPUQuota
+	}
+	if grp.MemoryLimit != 0 && memory > grp.MemoryLimit {
+		return fmt.Errorf("memory limits of the sub-groups of quota group %q add up to more than its own limit", grp.Name)
+	}
+	if grp.CPUQuota != 0 && cpu > grp.CPUQuota {
+		return fmt.Errorf("CPU quotas of the sub-groups of quota group %q add up to more than its own quota", grp.Name)
+	}
+	return nil
+}
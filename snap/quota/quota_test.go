@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package quota_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type quotaSuite struct{}
+
+var _ = Suite(&quotaSuite{})
+
+func (s *quotaSuite) TestValidateGroupName(c *C) {
+	for _, name := range []string{"a", "foo", "foo-bar", "telemetry2", "0-a"} {
+		c.Check(quota.ValidateGroupName(name), IsNil, Commentf(name))
+	}
+	for _, name := range []string{"", "-foo", "foo-", "foo--bar", "Foo", "foo_bar", "foo.bar", "a12345678901234567890123456789012345678901"} {
+		c.Check(quota.ValidateGroupName(name), ErrorMatches, `invalid quota group name ".*"`, Commentf(name))
+	}
+}
+
+func (s *quotaSuite) TestValidate(c *C) {
+	c.Check((&quota.Group{Name: "foo", MemoryLimit: 1024}).Validate(), IsNil)
+	c.Check((&quota.Group{Name: "foo", CPUQuota: 50}).Validate(), IsNil)
+	c.Check((&quota.Group{Name: "-"}).Validate(), ErrorMatches, `invalid quota group name "-"`)
+	c.Check((&quota.Group{Name: "foo"}).Validate(), ErrorMatches, `quota group "foo" must have a memory limit or a CPU quota`)
+	c.Check((&quota.Group{Name: "foo", MemoryLimit: -1}).Validate(), ErrorMatches, `quota group "foo" cannot have negative limits`)
+}
+
+func (s *quotaSuite) TestResolveCrossReferences(c *C) {
+	groups := map[string]*quota.Group{
+		"top":     {Name: "top", MemoryLimit: 1 << 30, SubGroups: []string{"sub-one", "sub2"}, Snaps: []string{"a"}},
+		"sub-one": {Name: "sub-one", MemoryLimit: 512 << 20, ParentGroup: "top", Snaps: []string{"b", "c"}},
+		"sub2":    {Name: "sub2", CPUQuota: 20, ParentGroup: "top", SubGroups: []string{"leaf"}},
+		"leaf":    {Name: "leaf", CPUQuota: 10, ParentGroup: "sub2", Snaps: []string{"d"}},
+		"other":   {Name: "other", CPUQuota: 200},
+	}
+	c.Assert(quota.ResolveCrossReferences(groups), IsNil)
+
+	c.Check(groups["top"].Parent(), IsNil)
+	c.Check(groups["leaf"].Parent(), Equals, groups["sub2"])
+	c.Check(groups["top"].SliceName(), Equals, "snap.top.slice")
+	c.Check(groups["sub-one"].SliceName(), Equals, `snap.top-sub\x2done.slice`)
+	c.Check(groups["leaf"].SliceName(), Equals, "snap.top-sub2-leaf.slice")
+	c.Check(groups["top"].AllSnaps(), DeepEquals, []string{"a", "b", "c", "d"})
+	c.Check(groups["other"].AllSnaps(), HasLen, 0)
+}
+
+func (s *quotaSuite) TestResolveCrossReferencesErrors(c *C) {
+	for _, t := range []struct {
+		groups map[string]*quota.Group
+		err    string
+	}{{
+		map[string]*quota.Group{"foo": {Name: "bar", CPUQuota: 1}},
+		`quota group "foo" has inconsistent name "bar"`,
+	}, {
+		map[string]*quota.Group{"foo": {Name: "foo"}},
+		`quota group "foo" must have a memory limit or a CPU quota`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", CPUQuota: 1, Snaps: []string{"a"}},
+			"bar": {Name: "bar", CPUQuota: 1, Snaps: []string{"a"}},
+		},
+		`snap "a" cannot be in both quota groups "bar" and "foo"`,
+	}, {
+		map[string]*quota.Group{"foo": {Name: "foo", CPUQuota: 1, ParentGroup: "bar"}},
+		`quota group "foo" has missing parent group "bar"`,
+	}, {
+		map[string]*quota.Group{"foo": {Name: "foo", CPUQuota: 1, SubGroups: []string{"bar"}}},
+		`quota group "foo" has missing sub-group "bar"`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", CPUQuota: 1, SubGroups: []string{"bar"}},
+			"bar": {Name: "bar", CPUQuota: 1},
+		},
+		`quota group "foo" has sub-group "bar" with a different parent`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", CPUQuota: 1},
+			"bar": {Name: "bar", CPUQuota: 1, ParentGroup: "foo"},
+		},
+		`quota group "bar" is not a sub-group of its parent group "foo"`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", CPUQuota: 1, ParentGroup: "bar", SubGroups: []string{"bar"}},
+			"bar": {Name: "bar", CPUQuota: 1, ParentGroup: "foo", SubGroups: []string{"foo"}},
+		},
+		`quota group "bar" is nested in itself`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", MemoryLimit: 1024, SubGroups: []string{"bar"}},
+			"bar": {Name: "bar", MemoryLimit: 2048, ParentGroup: "foo"},
+		},
+		`memory limit of quota group "bar" is larger than the limit of its parent group "foo"`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", CPUQuota: 50, SubGroups: []string{"bar"}},
+			"bar": {Name: "bar", CPUQuota: 60, ParentGroup: "foo"},
+		},
+		`CPU quota of quota group "bar" is larger than the quota of its parent group "foo"`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", MemoryLimit: 1024, SubGroups: []string{"bar", "baz"}},
+			"bar": {Name: "bar", MemoryLimit: 768, ParentGroup: "foo"},
+			"baz": {Name: "baz", MemoryLimit: 512, ParentGroup: "foo"},
+		},
+		`memory limits of the sub-groups of quota group "foo" add up to more than its own limit`,
+	}, {
+		map[string]*quota.Group{
+			"foo": {Name: "foo", CPUQuota: 50, SubGroups: []string{"bar", "baz"}},
+			"bar": {Name: "bar", CPUQuota: 30, ParentGroup: "foo"},
+			"baz": {Name: "baz", CPUQuota: 30, ParentGroup: "foo"},
+		},
+		`CPU quotas of the sub-groups of quota group "foo" add up to more than its own quota`,
+	}} {
+		c.Check(quota.ResolveCrossReferences(t.groups), ErrorMatches, t.err)
+	}
+}
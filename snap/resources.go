@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ResourceLimits holds the limits on the system resources a service
+// can use. Zero values mean no limit.
+type ResourceLimits struct {
+	// Memory is the maximum amount of memory the service can use,
+	// in bytes.
+	Memory int64 `json:"memory,omitempty"`
+	// CPUQuota is the maximum amount of CPU time the service can
+	// use, as a percentage of the time of a single CPU.
+	CPUQuota int `json:"cpu-quota,omitempty"`
+	// Tasks is the maximum number of tasks the service can create.
+	Tasks int `json:"tasks,omitempty"`
+}
+
+var memorySuffixes = []struct {
+	suffix string
+	factor int64
+}{
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// ParseMemoryLimit parses a memory limit in bytes, optionally followed
+// by one of the K, M or G suffixes for powers of 1024.
+func ParseMemoryLimit(s string) (int64, error) {
+	factor := int64(1)
+	digits := s
+	for _, m := range memorySuffixes {
+		if strings.HasSuffix(s, m.suffix) {
+			factor = m.factor
+			digits = s[:len(s)-len(m.suffix)]
+			break
+		}
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory limit %q", s)
+	}
+	return n * factor, nil
+}
+
+// FormatMemoryLimit formats a memory limit in bytes with the largest
+// suffix that represents it exactly.
+func FormatMemoryLimit(n int64) string {
+	for _, m := range memorySuffixes {
+		if n%m.factor == 0 {
+			return fmt.Sprintf("%d%s", n/m.factor, m.suffix)
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// ParseCPUQuota parses a CPU quota given as a percentage of the time
+// of a single CPU, like "50%". The percent sign is optional.
+func ParseCPUQuota(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid CPU quota %q", s)
+	}
+	return n, nil
+}
+
+// SetResourceLimits sets the limits given in values, keyed by
+// "memory", "cpu-quota" and "tasks", leaving the others untouched.
+func SetResourceLimits(limits *ResourceLimits, values map[string]interface{}) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := fmt.Sprintf("%v", values[key])
+		var err error
+		switch key {
+		case "memory":
+			limits.Memory, err = ParseMemoryLimit(value)
+		case "cpu-quota":
+			limits.CPUQuota, err = ParseCPUQuota(value)
+		case "tasks":
+			limits.Tasks, err = strconv.Atoi(value)
+			if err != nil || limits.Tasks < 0 {
+				err = fmt.Errorf("invalid tasks limit %q", value)
+			}
+		default:
+			err = fmt.Errorf("unknown resource %q", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the limits that are set, as a comma separated list
+// of resource=limit pairs.
+func (r *ResourceLimits) String() string {
+	var limits []string
+	if r.Memory > 0 {
+		limits = append(limits, "memory="+FormatMemoryLimit(r.Memory))
+	}
+	if r.CPUQuota > 0 {
+		limits = append(limits, fmt.Sprintf("cpu-quota=%d%%", r.CPUQuota))
+	}
+	if r.Tasks > 0 {
+		limits = append(limits, fmt.Sprintf("tasks=%d", r.Tasks))
+	}
+	return strings.Join(limits, ",")
+}
+
+// ApplyResourceOverrides overrides the resource limits of the
+// services of the snap with the given values, keyed by app name and
+// then by resource. Overrides of apps that are not services are
+// ignored.
+func ApplyResourceOverrides(info *Info, overrides map[string]map[string]interface{}) error {
+	for appName, values := range overrides {
+		app := info.Apps[appName]
+		if app == nil || !app.IsService() {
+			continue
+		}
+		limits := &ResourceLimits{}
+		if app.Resources != nil {
+			*limits = *app.Resources
+		}
+		if err := SetResourceLimits(limits, values); err != nil {
+			return fmt.Errorf("cannot override resources of %s.%s: %v", info.Name(), appName, err)
+		}
+		app.Resources = limits
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type resourcesSuite struct{}
+
+var _ = Suite(&resourcesSuite{})
+
+func (s *resourcesSuite) TestParseMemoryLimit(c *C) {
+	for _, t := range []struct {
+		in  string
+		out int64
+	}{
+		{"0", 0},
+		{"4096", 4096},
+		{"64K", 64 << 10},
+		{"128M", 128 << 20},
+		{"2G", 2 << 30},
+	} {
+		n, err := snap.ParseMemoryLimit(t.in)
+		c.Check(err, IsNil, Commentf(t.in))
+		c.Check(n, Equals, t.out, Commentf(t.in))
+	}
+
+	for _, in := range []string{"", "M", "-1M", "12T", "1.5G"} {
+		_, err := snap.ParseMemoryLimit(in)
+		c.Check(err, ErrorMatches, `invalid memory limit ".*"`, Commentf(in))
+	}
+}
+
+func (s *resourcesSuite) TestFormatMemoryLimit(c *C) {
+	c.Check(snap.FormatMemoryLimit(1000), Equals, "1000")
+	c.Check(snap.FormatMemoryLimit(64<<10), Equals, "64K")
+	c.Check(snap.FormatMemoryLimit(1536<<20), Equals, "1536M")
+	c.Check(snap.FormatMemoryLimit(2<<30), Equals, "2G")
+}
+
+func (s *resourcesSuite) TestParseCPUQuota(c *C) {
+	n, err := snap.ParseCPUQuota("50%")
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 50)
+	n, err = snap.ParseCPUQuota("200")
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 200)
+
+	for _, in := range []string{"", "%", "-5%", "half"} {
+		_, err := snap.ParseCPUQuota(in)
+		c.Check(err, ErrorMatches, `invalid CPU quota ".*"`, Commentf(in))
+	}
+}
+
+func (s *resourcesSuite) TestSetResourceLimits(c *C) {
+	limits := &snap.ResourceLimits{Memory: 1 << 20, Tasks: 10}
+	err := snap.SetResourceLimits(limits, map[string]interface{}{
+		"cpu-quota": "50%",
+		"tasks":     20,
+	})
+	c.Assert(err, IsNil)
+	c.Check(limits, DeepEquals, &snap.ResourceLimits{Memory: 1 << 20, CPUQuota: 50, Tasks: 20})
+
+	err = snap.SetResourceLimits(limits, map[string]interface{}{"tasks": "many"})
+	c.Check(err, ErrorMatches, `invalid tasks limit "many"`)
+	err = snap.SetResourceLimits(limits, map[string]interface{}{"disk": "1G"})
+	c.Check(err, ErrorMatches, `unknown resource "disk"`)
+}
+
+func (s *resourcesSuite) TestString(c *C) {
+	c.Check((&snap.ResourceLimits{}).String(), Equals, "")
+	c.Check((&snap.ResourceLimits{Memory: 128 << 20, CPUQuota: 50, Tasks: 64}).String(), Equals, "memory=128M,cpu-quota=50%,tasks=64")
+	c.Check((&snap.ResourceLimits{Memory: 1000}).String(), Equals, "memory=1000")
+	c.Check((&snap.ResourceLimits{Memory: 3 << 30}).String(), Equals, "memory=3G")
+}
+
+func (s *resourcesSuite) TestApplyResourceOverrides(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: foo
+version: 1.0
+apps:
+  svc1:
+    daemon: simple
+    resources:
+      memory: 128M
+      tasks: 64
+  svc2:
+    daemon: simple
+  cmd:
+`))
+	c.Assert(err, IsNil)
+
+	err = snap.ApplyResourceOverrides(info, map[string]map[string]interface{}{
+		"svc1":    {"memory": "256M"},
+		"svc2":    {"cpu-quota": "10%"},
+		"cmd":     {"tasks": 1},
+		"missing": {"tasks": 1},
+	})
+	c.Assert(err, IsNil)
+	c.Check(info.Apps["svc1"].Resources, DeepEquals, &snap.ResourceLimits{Memory: 256 << 20, Tasks: 64})
+	c.Check(info.Apps["svc2"].Resources, DeepEquals, &snap.ResourceLimits{CPUQuota: 10})
+	c.Check(info.Apps["cmd"].Resources, IsNil)
+
+	err = snap.ApplyResourceOverrides(info, map[string]map[string]interface{}{
+		"svc1": {"memory": "lots"},
+	})
+	c.Check(err, ErrorMatches, `cannot override resources of foo.svc1: invalid memory limit "lots"`)
+}
@@ -27,8 +27,11 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/spdx"
+	"github.com/snapcore/snapd/timeout"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 // Regular expressions describing correct identifiers.
@@ -56,12 +59,18 @@ func ValidateLicense(license string) error {
 	return nil
 }
 
+// MaxHookTimeout is the longest timeout a hook can declare.
+var MaxHookTimeout = timeout.Timeout(time.Hour)
+
 // ValidateHook validates the content of the given HookInfo
 func ValidateHook(hook *HookInfo) error {
 	valid := validHookName.MatchString(hook.Name)
 	if !valid {
 		return fmt.Errorf("invalid hook name: %q", hook.Name)
 	}
+	if hook.Timeout < 0 || hook.Timeout > MaxHookTimeout {
+		return fmt.Errorf("invalid timeout for hook %q: %s (must be between 0 and %s)", hook.Name, hook.Timeout, MaxHookTimeout)
+	}
 	return nil
 }
 
@@ -239,6 +248,10 @@ func Validate(info *Info) error {
 		}
 		blacklist = append(blacklist, info.ExpandSnapVariables(layout.Path))
 	}
+
+	if err := ValidateConfig(info.Config); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -271,6 +284,26 @@ func validateAppSocket(socket *SocketInfo) error {
 	return validateSocketAddr(socket, "listen-stream", socket.ListenStream)
 }
 
+func validateAppTimer(app *AppInfo) error {
+	if app.Timer == nil {
+		return nil
+	}
+
+	if !app.IsService() {
+		return fmt.Errorf("timer is only applicable to services")
+	}
+
+	if len(app.Sockets) > 0 {
+		return fmt.Errorf("cannot use both timer and sockets")
+	}
+
+	if _, err := timeutil.ParseSchedule(app.Timer.Timer); err != nil {
+		return fmt.Errorf("timer has invalid format: %v", err)
+	}
+
+	return nil
+}
+
 // validateAppOrderCycles checks for cycles in app ordering dependencies
 func validateAppOrderCycles(apps map[string]*AppInfo) error {
 	// list of successors of given app
@@ -407,6 +440,14 @@ func ValidateApp(app *AppInfo) error {
 		}
 	}
 
+	if err := validateAppTimer(app); err != nil {
+		return err
+	}
+
+	if app.Resources != nil && !app.IsService() {
+		return fmt.Errorf("resources are only applicable to services")
+	}
+
 	if err := validateAppOrderNames(app, app.Before); err != nil {
 		return err
 	}
@@ -22,10 +22,12 @@ package snap_test
 import (
 	"fmt"
 	"regexp"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	. "github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timeout"
 )
 
 type ValidateSuite struct{}
@@ -134,6 +136,15 @@ func (s *ValidateSuite) TestValidateHook(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestValidateHookTimeout(c *C) {
+	c.Check(ValidateHook(&HookInfo{Name: "a", Timeout: timeout.Timeout(30 * time.Minute)}), IsNil)
+	c.Check(ValidateHook(&HookInfo{Name: "a", Timeout: MaxHookTimeout}), IsNil)
+	c.Check(ValidateHook(&HookInfo{Name: "a", Timeout: timeout.Timeout(-time.Second)}), ErrorMatches,
+		`invalid timeout for hook "a": -1s \(must be between 0 and 1h0m0s\)`)
+	c.Check(ValidateHook(&HookInfo{Name: "a", Timeout: timeout.Timeout(2 * time.Hour)}), ErrorMatches,
+		`invalid timeout for hook "a": 2h0m0s \(must be between 0 and 1h0m0s\)`)
+}
+
 // ValidateApp
 
 func (s *ValidateSuite) TestValidateAppName(c *C) {
@@ -160,6 +171,54 @@ func (s *ValidateSuite) TestValidateAppSockets(c *C) {
 	c.Check(ValidateApp(app), IsNil)
 }
 
+func (s *ValidateSuite) TestValidateAppTimer(c *C) {
+	app := &AppInfo{
+		Snap:   &Info{SideInfo: SideInfo{RealName: "mysnap"}},
+		Name:   "foo",
+		Daemon: "oneshot",
+	}
+	app.Timer = &TimerInfo{App: app, Timer: "mon,10:00-12:00,,fri,13:00"}
+	c.Check(ValidateApp(app), IsNil)
+}
+
+func (s *ValidateSuite) TestValidateAppTimerInvalid(c *C) {
+	app := &AppInfo{
+		Snap:   &Info{SideInfo: SideInfo{RealName: "mysnap"}},
+		Name:   "foo",
+		Daemon: "oneshot",
+	}
+	app.Timer = &TimerInfo{App: app, Timer: "mon,10:00-12:00,,fri,invalid"}
+	c.Check(ValidateApp(app), ErrorMatches, `timer has invalid format: cannot parse "invalid": .*`)
+}
+
+func (s *ValidateSuite) TestValidateAppTimerNotService(c *C) {
+	app := &AppInfo{
+		Snap: &Info{SideInfo: SideInfo{RealName: "mysnap"}},
+		Name: "foo",
+	}
+	app.Timer = &TimerInfo{App: app, Timer: "mon,10:00"}
+	c.Check(ValidateApp(app), ErrorMatches, `timer is only applicable to services`)
+}
+
+func (s *ValidateSuite) TestValidateAppResourcesNotService(c *C) {
+	app := &AppInfo{
+		Snap:      &Info{SideInfo: SideInfo{RealName: "mysnap"}},
+		Name:      "foo",
+		Resources: &ResourceLimits{Tasks: 10},
+	}
+	c.Check(ValidateApp(app), ErrorMatches, `resources are only applicable to services`)
+
+	app.Daemon = "simple"
+	c.Check(ValidateApp(app), IsNil)
+}
+
+func (s *ValidateSuite) TestValidateAppTimerAndSockets(c *C) {
+	app := createSampleApp()
+	app.Daemon = "simple"
+	app.Timer = &TimerInfo{App: app, Timer: "mon,10:00"}
+	c.Check(ValidateApp(app), ErrorMatches, `cannot use both timer and sockets`)
+}
+
 func (s *ValidateSuite) TestValidateAppSocketsEmptyPermsOk(c *C) {
 	app := createSampleApp()
 	c.Check(ValidateApp(app), IsNil)
@@ -0,0 +1,505 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package localstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// risks lists the channel risk levels from the most to the least stable.
+var risks = []string{"stable", "candidate", "beta", "edge"}
+
+const defaultTrack = "latest"
+
+// indexFile describes the optional <dir>/index.json file mapping
+// snaps to the revisions released in their channels and to their
+// sections. Snaps not mentioned in it have their highest revision
+// released to latest/stable.
+type indexFile struct {
+	Snaps map[string]*indexSnap `json:"snaps"`
+}
+
+type indexSnap struct {
+	Channels map[string]int `json:"channels,omitempty"`
+	Sections []string       `json:"sections,omitempty"`
+}
+
+// parseChannel splits a channel into its track and risk, the
+// default track is assumed when only a risk is given.
+func parseChannel(channel string) (track, risk string, err error) {
+	if channel == "" {
+		return defaultTrack, "stable", nil
+	}
+	comps := strings.Split(channel, "/")
+	switch len(comps) {
+	case 1:
+		if riskLevel(comps[0]) >= 0 {
+			return defaultTrack, comps[0], nil
+		}
+		track, risk = comps[0], "stable"
+	case 2:
+		track, risk = comps[0], comps[1]
+	default:
+		return "", "", fmt.Errorf("invalid channel %q: branches are not supported", channel)
+	}
+	if track == "" || riskLevel(risk) < 0 {
+		return "", "", fmt.Errorf("invalid channel %q", channel)
+	}
+	return track, risk, nil
+}
+
+func riskLevel(risk string) int {
+	for i, r := range risks {
+		if r == risk {
+			return i
+		}
+	}
+	return -1
+}
+
+// channelName returns the name the store uses for the channel,
+// omitting the default track.
+func channelName(track, risk string) string {
+	if track == defaultTrack {
+		return risk
+	}
+	return track + "/" + risk
+}
+
+// displayChannel returns the store name of a "track/risk" channel.
+func displayChannel(ch string) string {
+	i := strings.IndexRune(ch, '/')
+	return channelName(ch[:i], ch[i+1:])
+}
+
+// Release records in the index.json of the store directory that the
+// given revision of the snap is released to channel.
+func Release(dir, name string, revision int, channel string) error {
+	track, risk, err := parseChannel(channel)
+	if err != nil {
+		return err
+	}
+	if revision <= 0 {
+		return fmt.Errorf("invalid revision %d", revision)
+	}
+	index, err := readIndex(dir)
+	if err != nil {
+		return err
+	}
+	if index.Snaps == nil {
+		index.Snaps = make(map[string]*indexSnap)
+	}
+	idx := index.Snaps[name]
+	if idx == nil {
+		idx = &indexSnap{}
+		index.Snaps[name] = idx
+	}
+	if idx.Channels == nil {
+		idx.Channels = make(map[string]int)
+	}
+	for ch := range idx.Channels {
+		t, r, err := parseChannel(ch)
+		if err == nil && t == track && r == risk {
+			delete(idx.Channels, ch)
+		}
+	}
+	idx.Channels[channelName(track, risk)] = revision
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(filepath.Join(dir, "index.json"), append(data, '\n'), 0644, 0)
+}
+
+// snapRevision is a snap file backed by a verified snap-revision assertion.
+type snapRevision struct {
+	name        string
+	snapID      string
+	revision    int
+	developerID string
+	developer   string
+
+	path   string
+	digest string
+	size   uint64
+	info   *snap.Info
+}
+
+// snapEntry holds the known revisions of a snap and their releases.
+type snapEntry struct {
+	name      string
+	snapID    string
+	revisions map[int]*snapRevision
+	// channels maps "track/risk" to the revision released there
+	channels map[string]int
+	sections []string
+}
+
+// resolve returns the revision served in the given channel and the
+// "track/risk" it was released to, falling back to more stable risks
+// of the same track like the store does.
+func (e *snapEntry) resolve(channel string) (*snapRevision, string, error) {
+	track, risk, err := parseChannel(channel)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := riskLevel(risk); i >= 0; i-- {
+		ch := track + "/" + risks[i]
+		if rev, ok := e.channels[ch]; ok {
+			return e.revisions[rev], ch, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// tracks returns the tracks with releases, the default one first.
+func (e *snapEntry) tracks() []string {
+	seen := make(map[string]bool)
+	var tracks []string
+	for ch := range e.channels {
+		track := ch[:strings.IndexRune(ch, '/')]
+		if track != defaultTrack && !seen[track] {
+			seen[track] = true
+			tracks = append(tracks, track)
+		}
+	}
+	sort.Strings(tracks)
+	return append([]string{defaultTrack}, tracks...)
+}
+
+// latest returns the highest revision released in any channel.
+func (e *snapEntry) latest() *snapRevision {
+	var latest *snapRevision
+	for _, rev := range e.channels {
+		if latest == nil || rev > latest.revision {
+			latest = e.revisions[rev]
+		}
+	}
+	return latest
+}
+
+// catalog is the view of the store directory the server answers from.
+type catalog struct {
+	db    *asserts.Database
+	snaps map[string]*snapEntry
+	byID  map[string]*snapEntry
+	// files maps the base names of the snap files to their revision
+	files map[string]*snapRevision
+}
+
+func (cat *catalog) sections() []string {
+	seen := make(map[string]bool)
+	var sections []string
+	for _, e := range cat.snaps {
+		for _, section := range e.sections {
+			if !seen[section] {
+				seen[section] = true
+				sections = append(sections, section)
+			}
+		}
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// fileInfo caches what was read from a snap file, keyed by its path,
+// size and modification time.
+type fileInfo struct {
+	digest string
+	size   uint64
+	info   *snap.Info
+}
+
+var readSnapInfo = func(path string) (*snap.Info, error) {
+	snapf, err := snap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return snap.ReadInfoFromSnapFile(snapf, nil)
+}
+
+func fileKey(path string, fi os.FileInfo) string {
+	return fmt.Sprintf("%s %d %d", path, fi.Size(), fi.ModTime().UnixNano())
+}
+
+// fingerprint summarizes the state of the store directory so that
+// changes to it can be detected cheaply.
+func (s *Server) fingerprint() (string, error) {
+	var keys []string
+	for _, pattern := range []string{"*.snap", "asserts/*", "index.json"} {
+		paths, err := filepath.Glob(filepath.Join(s.dir, pattern))
+		if err != nil {
+			return "", err
+		}
+		for _, path := range paths {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return "", err
+			}
+			keys = append(keys, fileKey(path, fi))
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n"), nil
+}
+
+// catalog returns the current catalog, reloading it if the store
+// directory changed since it was last loaded.
+func (s *Server) catalog() (*catalog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fp, err := s.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	if s.cat != nil && fp == s.fp {
+		return s.cat, nil
+	}
+	cat, err := s.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+	s.cat = cat
+	s.fp = fp
+	return cat, nil
+}
+
+// loadAssertions builds a database with the assertions under
+// <dir>/asserts that can be verified against the trusted ones.
+func (s *Server) loadAssertions() (*asserts.Database, error) {
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.trusted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pool := asserts.NewMemoryBackstore()
+	var loaded []asserts.Assertion
+	paths, err := filepath.Glob(filepath.Join(s.dir, "asserts", "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		dec := asserts.NewDecoder(f)
+		for {
+			a, err := dec.Decode()
+			if err != nil {
+				if err != io.EOF {
+					logger.Noticef("cannot decode assertions from %s: %v", path, err)
+				}
+				break
+			}
+			if err := pool.Put(a.Type(), a); err != nil {
+				if _, ok := err.(*asserts.RevisionError); !ok {
+					logger.Noticef("cannot load assertion %v from %s: %v", a.Ref(), path, err)
+				}
+				continue
+			}
+			loaded = append(loaded, a)
+		}
+		f.Close()
+	}
+
+	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
+		return pool.Get(ref.Type, ref.PrimaryKey, ref.Type.MaxSupportedFormat())
+	}
+	f := asserts.NewFetcher(db, retrieve, db.Add)
+	for _, a := range loaded {
+		if err := f.Fetch(a.Ref()); err != nil {
+			logger.Noticef("cannot verify assertion %v: %v", a.Ref(), err)
+		}
+	}
+	return db, nil
+}
+
+func readIndex(dir string) (*indexFile, error) {
+	var index indexFile
+	data, err := ioutil.ReadFile(filepath.Join(dir, "index.json"))
+	if os.IsNotExist(err) {
+		return &index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("cannot decode index.json: %v", err)
+	}
+	return &index, nil
+}
+
+func (s *Server) loadCatalog() (*catalog, error) {
+	db, err := s.loadAssertions()
+	if err != nil {
+		return nil, err
+	}
+	index, err := readIndex(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cat := &catalog{
+		db:    db,
+		snaps: make(map[string]*snapEntry),
+		byID:  make(map[string]*snapEntry),
+		files: make(map[string]*snapRevision),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.snap"))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*fileInfo, len(paths))
+	for _, path := range paths {
+		rev, err := s.snapRevision(db, path, files)
+		if err != nil {
+			logger.Noticef("cannot serve %s: %v", path, err)
+			continue
+		}
+		e := cat.snaps[rev.name]
+		if e == nil {
+			e = &snapEntry{
+				name:      rev.name,
+				snapID:    rev.snapID,
+				revisions: make(map[int]*snapRevision),
+			}
+			cat.snaps[rev.name] = e
+			cat.byID[rev.snapID] = e
+		}
+		e.revisions[rev.revision] = rev
+		cat.files[filepath.Base(path)] = rev
+	}
+	s.files = files
+
+	for name, e := range cat.snaps {
+		idx := index.Snaps[name]
+		if idx != nil {
+			e.sections = idx.Sections
+		}
+		e.channels = make(map[string]int)
+		if idx == nil || len(idx.Channels) == 0 {
+			for rev := range e.revisions {
+				if rev > e.channels[defaultTrack+"/stable"] {
+					e.channels[defaultTrack+"/stable"] = rev
+				}
+			}
+			continue
+		}
+		for channel, rev := range idx.Channels {
+			track, risk, err := parseChannel(channel)
+			if err != nil {
+				logger.Noticef("cannot release %q: %v", name, err)
+				continue
+			}
+			if e.revisions[rev] == nil {
+				logger.Noticef("cannot release %q revision %d to %q: revision not available", name, rev, channel)
+				continue
+			}
+			e.channels[track+"/"+risk] = rev
+		}
+	}
+
+	return cat, nil
+}
+
+// snapRevision reads the snap file at path and matches it with its
+// snap-revision, snap-declaration and developer account assertions.
+func (s *Server) snapRevision(db *asserts.Database, path string, files map[string]*fileInfo) (*snapRevision, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := fileKey(path, st)
+	fi := s.files[key]
+	if fi == nil {
+		info, err := readSnapInfo(path)
+		if err != nil {
+			return nil, err
+		}
+		digest, size, err := asserts.SnapFileSHA3_384(path)
+		if err != nil {
+			return nil, err
+		}
+		fi = &fileInfo{digest: digest, size: size, info: info}
+	}
+	files[key] = fi
+
+	a, err := db.Find(asserts.SnapRevisionType, map[string]string{
+		"snap-sha3-384": fi.digest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot find snap-revision: %v", err)
+	}
+	snapRev := a.(*asserts.SnapRevision)
+	if snapRev.SnapSize() != fi.size {
+		return nil, fmt.Errorf("snap size %d does not match snap-revision size %d", fi.size, snapRev.SnapSize())
+	}
+
+	a, err = db.Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  release.Series,
+		"snap-id": snapRev.SnapID(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot find snap-declaration: %v", err)
+	}
+	decl := a.(*asserts.SnapDeclaration)
+	if decl.SnapName() != fi.info.Name() {
+		return nil, fmt.Errorf("snap name %q does not match snap-declaration name %q", fi.info.Name(), decl.SnapName())
+	}
+
+	a, err = db.Find(asserts.AccountType, map[string]string{
+		"account-id": snapRev.DeveloperID(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot find developer account: %v", err)
+	}
+
+	return &snapRevision{
+		name:        decl.SnapName(),
+		snapID:      snapRev.SnapID(),
+		revision:    snapRev.SnapRevision(),
+		developerID: snapRev.DeveloperID(),
+		developer:   a.(*asserts.Account).Username(),
+		path:        path,
+		digest:      fi.digest,
+		size:        fi.size,
+		info:        fi.info,
+	}, nil
+}
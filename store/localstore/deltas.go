@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package localstore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+const deltaFormat = "xdelta3"
+
+type delta struct {
+	from, to int
+	path     string
+	digest   string
+	size     uint64
+}
+
+// acceptsDeltas returns whether the client asked for deltas in a
+// format the server can generate.
+func acceptsDeltas(formats string) bool {
+	for _, format := range strings.Split(formats, ",") {
+		if strings.TrimSpace(format) == deltaFormat {
+			return true
+		}
+	}
+	return false
+}
+
+// delta returns the delta between the two revisions, generating it
+// under <dir>/deltas the first time it is asked for.
+func (s *Server) delta(from, to *snapRevision) (*delta, error) {
+	s.deltaMu.Lock()
+	defer s.deltaMu.Unlock()
+
+	name := fmt.Sprintf("%s_%d_%d.%s", to.name, from.revision, to.revision, deltaFormat)
+	path := filepath.Join(s.dir, "deltas", name)
+	if d := s.deltas[path]; d != nil && osutil.FileExists(path) {
+		return d, nil
+	}
+
+	if !osutil.FileExists(path) {
+		if !osutil.ExecutableExists("xdelta3") {
+			return nil, fmt.Errorf("cannot find xdelta3 binary in PATH")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		tmp := path + ".partial"
+		output, err := exec.Command("xdelta3", "-f", "-e", "-s", from.path, to.path, tmp).CombinedOutput()
+		if err != nil {
+			os.Remove(tmp)
+			return nil, osutil.OutputErr(output, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return nil, err
+		}
+	}
+
+	digest, size, err := asserts.SnapFileSHA3_384(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &delta{
+		from:   from.revision,
+		to:     to.revision,
+		path:   path,
+		digest: digest,
+		size:   size,
+	}
+	s.deltas[path] = d
+	return d, nil
+}
+
+// deltaFrom returns the delta to rev from the given revision if both
+// are available, logging why it cannot be offered otherwise.
+func (s *Server) deltaFrom(e *snapEntry, fromRev int, rev *snapRevision) *delta {
+	from := e.revisions[fromRev]
+	if from == nil || fromRev == rev.revision {
+		return nil
+	}
+	d, err := s.delta(from, rev)
+	if err != nil {
+		logger.Noticef("cannot generate delta for %q from revision %d to %d: %v", e.name, fromRev, rev.revision, err)
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,608 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package localstore implements a store server for snaps kept in a
+// local directory, for use by devices that cannot reach the online
+// store. Devices are pointed at it through a proxy store assertion
+// or via SNAPPY_FORCE_API_URL.
+//
+// The directory holds the .snap files, their assertions (as
+// retrieved by "snap download") under asserts/ and an optional
+// index.json listing the channel releases and sections of the snaps.
+package localstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	halJSONContentType = "application/hal+json"
+
+	apiPrefix = "/api/v1/snaps/"
+)
+
+// Options control the behavior of a Server.
+type Options struct {
+	// BaseURL is used to build the download URLs handed to
+	// clients, by default the URL a request was received on is used.
+	BaseURL string
+	// RequireDeviceSession makes querying and downloading snaps
+	// require a device session obtained with a serial assertion.
+	RequireDeviceSession bool
+	// Trusted replaces the trusted assertions the ones in the
+	// store directory are checked against, sysdb.Trusted() by default.
+	Trusted []asserts.Assertion
+}
+
+// Server serves the snaps and assertions found in a directory with
+// the protocol of the snap store.
+type Server struct {
+	dir     string
+	opts    Options
+	trusted []asserts.Assertion
+	mux     *http.ServeMux
+
+	mu    sync.Mutex
+	cat   *catalog
+	fp    string
+	files map[string]*fileInfo
+
+	deltaMu sync.Mutex
+	deltas  map[string]*delta
+
+	sessions *sessions
+
+	l   net.Listener
+	srv *http.Server
+}
+
+// New returns a Server serving from dir.
+func New(dir string, opts *Options) *Server {
+	if opts == nil {
+		opts = &Options{}
+	}
+	s := &Server{
+		dir:      dir,
+		opts:     *opts,
+		trusted:  opts.Trusted,
+		mux:      http.NewServeMux(),
+		files:    make(map[string]*fileInfo),
+		deltas:   make(map[string]*delta),
+		sessions: newSessions(),
+	}
+	if s.trusted == nil {
+		s.trusted = sysdb.Trusted()
+	}
+
+	s.mux.HandleFunc(apiPrefix+"details/", s.detailsEndpoint)
+	s.mux.HandleFunc(apiPrefix+"search", s.searchEndpoint)
+	s.mux.HandleFunc(apiPrefix+"sections", s.sectionsEndpoint)
+	s.mux.HandleFunc(apiPrefix+"metadata", s.metadataEndpoint)
+	s.mux.HandleFunc(apiPrefix+"assertions/", s.assertionsEndpoint)
+	s.mux.HandleFunc(apiPrefix+"auth/nonces", s.nonceEndpoint)
+	s.mux.HandleFunc(apiPrefix+"auth/sessions", s.sessionEndpoint)
+	s.mux.HandleFunc("/download/", s.downloadEndpoint)
+
+	return s
+}
+
+// ServeHTTP makes Server an http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(w, req)
+}
+
+// Start starts serving on the given address.
+func (s *Server) Start(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.l = l
+	s.srv = &http.Server{Handler: s}
+	go s.srv.Serve(l)
+	return nil
+}
+
+// URL returns the base URL the server is listening on.
+func (s *Server) URL() string {
+	if s.l == nil {
+		return ""
+	}
+	return "http://" + s.l.Addr().String()
+}
+
+// Stop stops serving.
+func (s *Server) Stop() error {
+	if s.l == nil {
+		return nil
+	}
+	err := s.l.Close()
+	s.l = nil
+	return err
+}
+
+func (s *Server) baseURL(req *http.Request) string {
+	if s.opts.BaseURL != "" {
+		return strings.TrimSuffix(s.opts.BaseURL, "/")
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, req.Host)
+}
+
+func jsonResponse(w http.ResponseWriter, contentType string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot marshal response: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+type errorListEntry struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorResponse replies with an error in the format the store uses.
+func errorResponse(w http.ResponseWriter, status int, format string, v ...interface{}) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"error_list": []errorListEntry{{
+			Code:    strings.ToLower(strings.Replace(http.StatusText(status), " ", "-", -1)),
+			Message: fmt.Sprintf(format, v...),
+		}},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func hexify(digest string) string {
+	b, err := base64.RawURLEncoding.DecodeString(digest)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+type deltaJSON struct {
+	FromRevision    int    `json:"from_revision"`
+	ToRevision      int    `json:"to_revision"`
+	Format          string `json:"format"`
+	AnonDownloadURL string `json:"anon_download_url"`
+	DownloadURL     string `json:"download_url"`
+	Size            uint64 `json:"binary_filesize"`
+	Sha3_384        string `json:"download_sha3_384"`
+}
+
+type channelMapEntryJSON struct {
+	Revision     int        `json:"revision"`
+	Confinement  string     `json:"confinement"`
+	Version      string     `json:"version"`
+	Channel      string     `json:"channel"`
+	Epoch        snap.Epoch `json:"epoch"`
+	DownloadSize uint64     `json:"binary_filesize"`
+	Info         string     `json:"info"`
+}
+
+type channelMapJSON struct {
+	Track string                `json:"track"`
+	Map   []channelMapEntryJSON `json:"map"`
+}
+
+type detailsJSON struct {
+	Architectures   []string         `json:"architecture"`
+	SnapID          string           `json:"snap_id"`
+	Name            string           `json:"package_name"`
+	Developer       string           `json:"origin"`
+	DeveloperID     string           `json:"developer_id"`
+	Publisher       string           `json:"publisher"`
+	AnonDownloadURL string           `json:"anon_download_url"`
+	DownloadURL     string           `json:"download_url"`
+	DownloadSha3    string           `json:"download_sha3_384"`
+	DownloadSize    uint64           `json:"binary_filesize"`
+	Revision        int              `json:"revision"`
+	Version         string           `json:"version"`
+	Channel         string           `json:"channel,omitempty"`
+	Epoch           snap.Epoch       `json:"epoch"`
+	Confinement     string           `json:"confinement"`
+	Type            string           `json:"content,omitempty"`
+	Title           string           `json:"title"`
+	Summary         string           `json:"summary"`
+	Description     string           `json:"description"`
+	Base            string           `json:"base,omitempty"`
+	License         string           `json:"license,omitempty"`
+	Deltas          []deltaJSON      `json:"deltas,omitempty"`
+	ChannelMapList  []channelMapJSON `json:"channel_maps_list,omitempty"`
+}
+
+func (s *Server) details(baseURL string, rev *snapRevision, channel string) *detailsJSON {
+	info := rev.info
+	archs := info.Architectures
+	if len(archs) == 0 {
+		archs = []string{"all"}
+	}
+	downloadURL := fmt.Sprintf("%s/download/%s", baseURL, filepath.Base(rev.path))
+	return &detailsJSON{
+		Architectures:   archs,
+		SnapID:          rev.snapID,
+		Name:            rev.name,
+		Developer:       rev.developer,
+		DeveloperID:     rev.developerID,
+		Publisher:       rev.developer,
+		AnonDownloadURL: downloadURL,
+		DownloadURL:     downloadURL,
+		DownloadSha3:    hexify(rev.digest),
+		DownloadSize:    rev.size,
+		Revision:        rev.revision,
+		Version:         info.Version,
+		Channel:         channel,
+		Epoch:           info.Epoch,
+		Confinement:     string(info.Confinement),
+		Type:            string(info.Type),
+		Title:           info.Title(),
+		Summary:         info.Summary(),
+		Description:     info.Description(),
+		Base:            info.Base,
+		License:         info.License,
+	}
+}
+
+func (s *Server) channelMaps(e *snapEntry) []channelMapJSON {
+	var maps []channelMapJSON
+	for _, track := range e.tracks() {
+		cm := channelMapJSON{Track: track}
+		for _, risk := range risks {
+			entry := channelMapEntryJSON{Channel: channelName(track, risk)}
+			rev, ch, _ := e.resolve(track + "/" + risk)
+			if rev != nil {
+				entry.Revision = rev.revision
+				entry.Confinement = string(rev.info.Confinement)
+				entry.Version = rev.info.Version
+				entry.Epoch = rev.info.Epoch
+				entry.DownloadSize = rev.size
+				entry.Info = "tracking"
+				if ch == track+"/"+risk {
+					entry.Info = "released"
+				}
+			}
+			cm.Map = append(cm.Map, entry)
+		}
+		maps = append(maps, cm)
+	}
+	return maps
+}
+
+func (s *Server) detailsEndpoint(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(w, req) {
+		return
+	}
+	cat, err := s.catalog()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot load store: %v", err)
+		return
+	}
+
+	name := strings.TrimPrefix(req.URL.Path, apiPrefix+"details/")
+	e := cat.snaps[name]
+	if e == nil {
+		errorResponse(w, http.StatusNotFound, "snap %q not found", name)
+		return
+	}
+
+	q := req.URL.Query()
+	channel := q.Get("channel")
+	var rev *snapRevision
+	switch {
+	case q.Get("revision") != "":
+		n, err := strconv.Atoi(q.Get("revision"))
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "invalid revision %q", q.Get("revision"))
+			return
+		}
+		rev = e.revisions[n]
+	case channel == "":
+		rev = e.latest()
+	default:
+		var ch string
+		rev, ch, err = e.resolve(channel)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		if rev != nil {
+			channel = displayChannel(ch)
+		}
+	}
+	if rev == nil {
+		errorResponse(w, http.StatusNotFound, "snap %q not found", name)
+		return
+	}
+
+	details := s.details(s.baseURL(req), rev, channel)
+	details.ChannelMapList = s.channelMaps(e)
+	jsonResponse(w, halJSONContentType, details)
+}
+
+// matches returns whether the snap matches the search terms like the
+// store does, by name prefix when prefix is set or otherwise by a
+// substring of its name, title or summary.
+func matches(rev *snapRevision, term string, prefix bool) bool {
+	if term == "" {
+		return true
+	}
+	term = strings.ToLower(term)
+	if prefix {
+		return strings.HasPrefix(rev.name, term)
+	}
+	for _, s := range []string{rev.name, rev.info.Title(), rev.info.Summary()} {
+		if strings.Contains(strings.ToLower(s), term) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSection(e *snapEntry, section string) bool {
+	for _, s := range e.sections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+type byName []*detailsJSON
+
+func (ds byName) Len() int           { return len(ds) }
+func (ds byName) Swap(i, j int)      { ds[i], ds[j] = ds[j], ds[i] }
+func (ds byName) Less(i, j int) bool { return ds[i].Name < ds[j].Name }
+
+func (s *Server) searchEndpoint(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(w, req) {
+		return
+	}
+	cat, err := s.catalog()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot load store: %v", err)
+		return
+	}
+
+	q := req.URL.Query()
+	term, prefix := q.Get("q"), false
+	if q.Get("name") != "" {
+		term, prefix = q.Get("name"), true
+	}
+	section := q.Get("section")
+
+	baseURL := s.baseURL(req)
+	packages := []*detailsJSON{}
+	for _, e := range cat.snaps {
+		if section != "" && !hasSection(e, section) {
+			continue
+		}
+		rev, _, _ := e.resolve("stable")
+		if rev == nil || !matches(rev, term, prefix) {
+			continue
+		}
+		packages = append(packages, s.details(baseURL, rev, "stable"))
+	}
+	sort.Sort(byName(packages))
+
+	var results struct {
+		Payload struct {
+			Packages []*detailsJSON `json:"clickindex:package"`
+		} `json:"_embedded"`
+	}
+	results.Payload.Packages = packages
+	jsonResponse(w, halJSONContentType, &results)
+}
+
+func (s *Server) sectionsEndpoint(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(w, req) {
+		return
+	}
+	cat, err := s.catalog()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot load store: %v", err)
+		return
+	}
+
+	type sectionJSON struct {
+		Name string `json:"name"`
+	}
+	var results struct {
+		Payload struct {
+			Sections []sectionJSON `json:"clickindex:sections"`
+		} `json:"_embedded"`
+	}
+	results.Payload.Sections = []sectionJSON{}
+	for _, name := range cat.sections() {
+		results.Payload.Sections = append(results.Payload.Sections, sectionJSON{Name: name})
+	}
+	jsonResponse(w, halJSONContentType, &results)
+}
+
+type metadataRequest struct {
+	Snaps []struct {
+		SnapID   string `json:"snap_id"`
+		Channel  string `json:"channel"`
+		Revision int    `json:"revision"`
+	} `json:"snaps"`
+}
+
+func (s *Server) metadataEndpoint(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		errorResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !s.authorized(w, req) {
+		return
+	}
+	var mdReq metadataRequest
+	if err := json.NewDecoder(req.Body).Decode(&mdReq); err != nil {
+		errorResponse(w, http.StatusBadRequest, "cannot decode request body: %v", err)
+		return
+	}
+	cat, err := s.catalog()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot load store: %v", err)
+		return
+	}
+
+	wantDeltas := acceptsDeltas(req.Header.Get("X-Ubuntu-Delta-Formats"))
+	baseURL := s.baseURL(req)
+	packages := []*detailsJSON{}
+	for _, cur := range mdReq.Snaps {
+		e := cat.byID[cur.SnapID]
+		if e == nil {
+			continue
+		}
+		rev, ch, err := e.resolve(cur.Channel)
+		if err != nil || rev == nil {
+			continue
+		}
+		details := s.details(baseURL, rev, displayChannel(ch))
+		if wantDeltas {
+			if d := s.deltaFrom(e, cur.Revision, rev); d != nil {
+				deltaURL := fmt.Sprintf("%s/download/deltas/%s", baseURL, filepath.Base(d.path))
+				details.Deltas = []deltaJSON{{
+					FromRevision:    d.from,
+					ToRevision:      d.to,
+					Format:          deltaFormat,
+					AnonDownloadURL: deltaURL,
+					DownloadURL:     deltaURL,
+					Size:            d.size,
+					Sha3_384:        hexify(d.digest),
+				}}
+			}
+		}
+		packages = append(packages, details)
+	}
+
+	var results struct {
+		Payload struct {
+			Packages []*detailsJSON `json:"clickindex:package"`
+		} `json:"_embedded"`
+	}
+	results.Payload.Packages = packages
+	jsonResponse(w, halJSONContentType, &results)
+}
+
+func (s *Server) downloadEndpoint(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(w, req) {
+		return
+	}
+
+	var path string
+	name := strings.TrimPrefix(req.URL.Path, "/download/")
+	if strings.HasPrefix(name, "deltas/") {
+		name = strings.TrimPrefix(name, "deltas/")
+		if name != filepath.Base(name) || !strings.HasSuffix(name, "."+deltaFormat) {
+			errorResponse(w, http.StatusNotFound, "not found")
+			return
+		}
+		path = filepath.Join(s.dir, "deltas", name)
+	} else {
+		cat, err := s.catalog()
+		if err != nil {
+			errorResponse(w, http.StatusInternalServerError, "cannot load store: %v", err)
+			return
+		}
+		rev := cat.files[name]
+		if rev == nil {
+			errorResponse(w, http.StatusNotFound, "not found")
+			return
+		}
+		path = rev.path
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		errorResponse(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot open %s: %v", name, err)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot open %s: %v", name, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, req, name, st.ModTime(), f)
+}
+
+func (s *Server) assertionsEndpoint(w http.ResponseWriter, req *http.Request) {
+	comps := strings.Split(strings.TrimPrefix(req.URL.Path, apiPrefix+"assertions/"), "/")
+	typ := asserts.Type(comps[0])
+	if typ == nil {
+		errorResponse(w, http.StatusBadRequest, "unknown assertion type %q", comps[0])
+		return
+	}
+	if len(typ.PrimaryKey) != len(comps)-1 {
+		errorResponse(w, http.StatusBadRequest, "wrong primary key length: %v", comps)
+		return
+	}
+	cat, err := s.catalog()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot load store: %v", err)
+		return
+	}
+
+	ref := &asserts.Ref{Type: typ, PrimaryKey: comps[1:]}
+	a, err := ref.Resolve(cat.db.Find)
+	if asserts.IsNotFound(err) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404}`))
+		return
+	}
+	if err != nil {
+		logger.Noticef("cannot retrieve assertion %v: %v", ref, err)
+		errorResponse(w, http.StatusInternalServerError, "cannot retrieve assertion %v: %v", ref, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", asserts.MediaType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(asserts.Encode(a))
+}
@@ -0,0 +1,568 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package localstore_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/localstore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type localStoreSuite struct {
+	testutil.BaseTest
+
+	dir        string
+	storeStack *assertstest.StoreStack
+	devAcct    *asserts.Account
+
+	opts   *localstore.Options
+	server *httptest.Server
+}
+
+var _ = Suite(&localStoreSuite{})
+
+const (
+	fooSnapID = "foo-id-idididididididididididididid"
+	barSnapID = "bar-id-idididididididididididididid"
+)
+
+func (s *localStoreSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.dir = c.MkDir()
+	c.Assert(os.Mkdir(filepath.Join(s.dir, "asserts"), 0755), IsNil)
+
+	// snap files are made of their snap.yaml
+	s.AddCleanup(localstore.MockReadSnapInfo(func(path string) (*snap.Info, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return snap.InfoFromSnapYaml(data)
+	}))
+
+	s.storeStack = assertstest.NewStoreStack("canonical", nil)
+	s.devAcct = assertstest.NewAccount(s.storeStack, "developer1", nil, "")
+	s.writeAssertions(c, "base", s.storeStack.StoreAccountKey(""), s.devAcct)
+
+	s.opts = &localstore.Options{Trusted: s.storeStack.Trusted}
+	s.server = httptest.NewServer(localstore.New(s.dir, s.opts))
+	s.AddCleanup(s.server.Close)
+}
+
+func (s *localStoreSuite) writeAssertions(c *C, name string, as ...asserts.Assertion) {
+	var data []byte
+	for _, a := range as {
+		data = append(data, asserts.Encode(a)...)
+		data = append(data, '\n')
+	}
+	err := ioutil.WriteFile(filepath.Join(s.dir, "asserts", name+".assert"), data, 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *localStoreSuite) addSnapDeclaration(c *C, name, snapID string) {
+	decl, err := s.storeStack.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      snapID,
+		"snap-name":    name,
+		"publisher-id": s.devAcct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, name, decl)
+}
+
+// addSnap writes a snap file and, if snapID is set, its snap-revision.
+func (s *localStoreSuite) addSnap(c *C, name, snapID string, revision int, version string) string {
+	fn := filepath.Join(s.dir, fmt.Sprintf("%s_%d.snap", name, revision))
+	content := fmt.Sprintf("name: %s\nversion: %s\nsummary: %s summary\n# revision %d\n", name, version, name, revision)
+	c.Assert(ioutil.WriteFile(fn, []byte(content), 0644), IsNil)
+	if snapID == "" {
+		return fn
+	}
+
+	digest, size, err := asserts.SnapFileSHA3_384(fn)
+	c.Assert(err, IsNil)
+	rev, err := s.storeStack.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-id":       snapID,
+		"snap-revision": fmt.Sprintf("%d", revision),
+		"developer-id":  s.devAcct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, fmt.Sprintf("%s_%d", name, revision), rev)
+	return fn
+}
+
+func (s *localStoreSuite) writeIndex(c *C, index string) {
+	err := ioutil.WriteFile(filepath.Join(s.dir, "index.json"), []byte(index), 0644)
+	c.Assert(err, IsNil)
+	// make sure the change is noticed even on coarse mtimes
+	future := time.Now().Add(time.Minute)
+	c.Assert(os.Chtimes(filepath.Join(s.dir, "index.json"), future, future), IsNil)
+}
+
+func (s *localStoreSuite) addFixtures(c *C) {
+	s.addSnapDeclaration(c, "foo", fooSnapID)
+	s.addSnap(c, "foo", fooSnapID, 1, "1.0")
+	s.addSnap(c, "foo", fooSnapID, 2, "1.1")
+	s.addSnap(c, "foo", fooSnapID, 5, "2.0")
+	s.addSnapDeclaration(c, "bar", barSnapID)
+	s.addSnap(c, "bar", barSnapID, 7, "0.7")
+	s.writeIndex(c, `{"snaps": {
+ "foo": {"channels": {"stable": 1, "candidate": 2, "2.0/edge": 5}, "sections": ["games", "featured"]},
+ "bar": {"sections": ["utilities"]}
+}}`)
+}
+
+func (s *localStoreSuite) newStore(c *C, authContext auth.AuthContext) *store.Store {
+	u, err := url.Parse(s.server.URL + "/")
+	c.Assert(err, IsNil)
+	return store.New(&store.Config{StoreBaseURL: u}, authContext)
+}
+
+func (s *localStoreSuite) TestParseChannel(c *C) {
+	for _, t := range []struct {
+		channel, track, risk, err string
+	}{
+		{"", "latest", "stable", ""},
+		{"beta", "latest", "beta", ""},
+		{"2.0", "2.0", "stable", ""},
+		{"2.0/edge", "2.0", "edge", ""},
+		{"latest/candidate", "latest", "candidate", ""},
+		{"2.0/foo", "", "", `invalid channel "2.0/foo"`},
+		{"/edge", "", "", `invalid channel "/edge"`},
+		{"2.0/edge/fix", "", "", `invalid channel "2.0/edge/fix": branches are not supported`},
+	} {
+		track, risk, err := localstore.ParseChannel(t.channel)
+		if t.err != "" {
+			c.Check(err, ErrorMatches, t.err)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Check(track, Equals, t.track, Commentf(t.channel))
+		c.Check(risk, Equals, t.risk, Commentf(t.channel))
+	}
+}
+
+func (s *localStoreSuite) TestSnapInfo(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	info, err := sto.SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Name(), Equals, "foo")
+	c.Check(info.SnapID, Equals, fooSnapID)
+	c.Check(info.Revision, Equals, snap.R(1))
+	c.Check(info.Version, Equals, "1.0")
+	c.Check(info.Channel, Equals, "stable")
+	c.Check(info.Summary(), Equals, "foo summary")
+	c.Check(info.PublisherID, Equals, s.devAcct.AccountID())
+	c.Check(info.DownloadURL, Equals, s.server.URL+"/download/foo_1.snap")
+	c.Check(info.Tracks, DeepEquals, []string{"latest", "2.0"})
+	c.Check(info.Channels, HasLen, 5)
+	c.Check(info.Channels["latest/stable"].Revision, Equals, snap.R(1))
+	c.Check(info.Channels["latest/edge"].Revision, Equals, snap.R(2))
+	c.Check(info.Channels["2.0/edge"].Revision, Equals, snap.R(5))
+	c.Check(info.Channels["2.0/stable"], IsNil)
+
+	info, err = sto.SnapInfo(store.SnapSpec{Name: "foo", Channel: "beta"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(2))
+	c.Check(info.Channel, Equals, "candidate")
+
+	info, err = sto.SnapInfo(store.SnapSpec{Name: "foo", Channel: "2.0/edge"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(5))
+
+	info, err = sto.SnapInfo(store.SnapSpec{Name: "foo", Revision: snap.R(2)}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Version, Equals, "1.1")
+
+	info, err = sto.SnapInfo(store.SnapSpec{Name: "foo", AnyChannel: true}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(5))
+
+	// bar is not in the index with channels, its latest revision is stable
+	info, err = sto.SnapInfo(store.SnapSpec{Name: "bar"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(7))
+}
+
+func (s *localStoreSuite) TestSnapInfoNotFound(c *C) {
+	s.addFixtures(c)
+	// no snap-revision, not served
+	s.addSnap(c, "baz", "", 1, "1.0")
+	sto := s.newStore(c, nil)
+
+	for _, spec := range []store.SnapSpec{
+		{Name: "baz"},
+		{Name: "unknown"},
+		{Name: "foo", Channel: "2.0/stable"},
+		{Name: "foo", Revision: snap.R(3)},
+	} {
+		_, err := sto.SnapInfo(spec, nil)
+		c.Check(err, Equals, store.ErrSnapNotFound, Commentf("%v", spec))
+	}
+}
+
+func (s *localStoreSuite) TestSnapInfoUnverifiedAssertions(c *C) {
+	s.addSnapDeclaration(c, "foo", fooSnapID)
+	s.addSnap(c, "foo", fooSnapID, 1, "1.0")
+	// a store not trusting the signing root serves nothing
+	otherStack := assertstest.NewStoreStack("other", nil)
+	s.server.Config.Handler = localstore.New(s.dir, &localstore.Options{Trusted: otherStack.Trusted})
+	sto := s.newStore(c, nil)
+
+	_, err := sto.SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *localStoreSuite) TestReloadsOnChange(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	info, err := sto.SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(1))
+
+	s.writeIndex(c, `{"snaps": {"foo": {"channels": {"stable": 2}}}}`)
+
+	info, err = sto.SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(2))
+}
+
+func names(infos []*snap.Info) []string {
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func (s *localStoreSuite) TestFind(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	for _, t := range []struct {
+		search *store.Search
+		names  []string
+	}{
+		{&store.Search{Query: "summary"}, []string{"bar", "foo"}},
+		{&store.Search{Query: "FOO"}, []string{"foo"}},
+		{&store.Search{Query: "ba", Prefix: true}, []string{"bar"}},
+		{&store.Search{Query: "ar", Prefix: true}, nil},
+		{&store.Search{Section: "games"}, []string{"foo"}},
+		{&store.Search{Query: "bar", Section: "games"}, nil},
+	} {
+		infos, err := sto.Find(t.search, nil)
+		c.Assert(err, IsNil)
+		c.Check(names(infos), DeepEquals, t.names, Commentf("%+v", t.search))
+	}
+}
+
+func (s *localStoreSuite) TestSections(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	sections, err := sto.Sections(nil)
+	c.Assert(err, IsNil)
+	c.Check(sections, DeepEquals, []string{"featured", "games", "utilities"})
+}
+
+func (s *localStoreSuite) TestListRefresh(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	infos, err := sto.ListRefresh([]*store.RefreshCandidate{
+		{SnapID: fooSnapID, Revision: snap.R(1), Channel: "candidate"},
+		{SnapID: barSnapID, Revision: snap.R(7)},
+		{SnapID: "unknown-id", Revision: snap.R(1)},
+	}, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Check(infos[0].Name(), Equals, "foo")
+	c.Check(infos[0].Revision, Equals, snap.R(2))
+	c.Check(infos[0].Deltas, HasLen, 0)
+}
+
+func (s *localStoreSuite) TestListRefreshDeltas(c *C) {
+	os.Setenv("SNAPD_USE_DELTAS_EXPERIMENTAL", "1")
+	defer os.Unsetenv("SNAPD_USE_DELTAS_EXPERIMENTAL")
+	xdelta3 := testutil.MockCommand(c, "xdelta3", `echo delta > "$6"`)
+	defer xdelta3.Restore()
+
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	for i := 0; i < 2; i++ {
+		infos, err := sto.ListRefresh([]*store.RefreshCandidate{
+			{SnapID: fooSnapID, Revision: snap.R(1), Channel: "candidate"},
+		}, nil, nil)
+		c.Assert(err, IsNil)
+		c.Assert(infos, HasLen, 1)
+		c.Assert(infos[0].Deltas, HasLen, 1)
+		d := infos[0].Deltas[0]
+		c.Check(d.FromRevision, Equals, 1)
+		c.Check(d.ToRevision, Equals, 2)
+		c.Check(d.Format, Equals, "xdelta3")
+		c.Check(d.Size, Equals, int64(len("delta\n")))
+		c.Check(d.DownloadURL, Equals, s.server.URL+"/download/deltas/foo_1_2.xdelta3")
+	}
+
+	// the delta was generated only once
+	deltaPath := filepath.Join(s.dir, "deltas", "foo_1_2.xdelta3")
+	c.Check(xdelta3.Calls(), DeepEquals, [][]string{
+		{"xdelta3", "-f", "-e", "-s", filepath.Join(s.dir, "foo_1.snap"), filepath.Join(s.dir, "foo_2.snap"), deltaPath + ".partial"},
+	})
+	data, err := ioutil.ReadFile(deltaPath)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "delta\n")
+
+	resp, err := http.Get(s.server.URL + "/download/deltas/foo_1_2.xdelta3")
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 200)
+}
+
+func (s *localStoreSuite) TestDownload(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	info, err := sto.SnapInfo(store.SnapSpec{Name: "foo", Channel: "candidate"}, nil)
+	c.Assert(err, IsNil)
+
+	target := filepath.Join(c.MkDir(), "foo.snap")
+	err = sto.Download(context.TODO(), "foo", target, &info.DownloadInfo, nil, nil)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadFile(target)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, "(?s).*# revision 2.*")
+
+	// only snaps of the catalog are served
+	for _, path := range []string{"/download/index.json", "/download/asserts/base.assert", "/download/deltas/foo_1.snap"} {
+		resp, err := http.Get(s.server.URL + path)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Check(resp.StatusCode, Equals, 404, Commentf(path))
+	}
+}
+
+func (s *localStoreSuite) TestAssertion(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	a, err := sto.Assertion(asserts.SnapDeclarationType, []string{"16", fooSnapID}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapDeclaration).SnapName(), Equals, "foo")
+
+	a, err = sto.Assertion(asserts.AccountType, []string{"canonical"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.Account).AccountID(), Equals, "canonical")
+
+	_, err = sto.Assertion(asserts.SnapDeclarationType, []string{"16", "unknown-id"}, nil)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+}
+
+type testAuthContext struct {
+	device *auth.DeviceState
+	params func(nonce string) (*auth.DeviceSessionRequestParams, error)
+}
+
+func (ac *testAuthContext) Device() (*auth.DeviceState, error) {
+	device := *ac.device
+	return &device, nil
+}
+
+func (ac *testAuthContext) UpdateDeviceAuth(d *auth.DeviceState, sessionMacaroon string) (*auth.DeviceState, error) {
+	ac.device.SessionMacaroon = sessionMacaroon
+	device := *ac.device
+	return &device, nil
+}
+
+func (ac *testAuthContext) UpdateUserAuth(u *auth.UserState, discharges []string) (*auth.UserState, error) {
+	return u, nil
+}
+
+func (ac *testAuthContext) StoreID(fallback string) (string, error) {
+	return fallback, nil
+}
+
+func (ac *testAuthContext) DeviceSessionRequestParams(nonce string) (*auth.DeviceSessionRequestParams, error) {
+	return ac.params(nonce)
+}
+
+func (ac *testAuthContext) ProxyStoreParams(defaultURL *url.URL) (string, *url.URL, error) {
+	return "", defaultURL, nil
+}
+
+func (s *localStoreSuite) deviceAuthContext(c *C, serialNum string) *testAuthContext {
+	devKey, _ := assertstest.GenerateKey(752)
+	encDevKey, err := asserts.EncodePublicKey(devKey.PublicKey())
+	c.Assert(err, IsNil)
+
+	ts := time.Now().Format(time.RFC3339)
+	model, err := s.storeStack.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"brand-id":     "canonical",
+		"model":        "pc",
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+		"timestamp":    ts,
+	}, nil, "")
+	c.Assert(err, IsNil)
+	serial, err := s.storeStack.Sign(asserts.SerialType, map[string]interface{}{
+		"brand-id":            "canonical",
+		"model":               "pc",
+		"serial":              "9999",
+		"device-key":          string(encDevKey),
+		"device-key-sha3-384": devKey.PublicKey().ID(),
+		"timestamp":           ts,
+	}, nil, "")
+	c.Assert(err, IsNil)
+
+	return &testAuthContext{
+		device: &auth.DeviceState{Brand: "canonical", Model: "pc", Serial: "9999"},
+		params: func(nonce string) (*auth.DeviceSessionRequestParams, error) {
+			req, err := asserts.SignWithoutAuthority(asserts.DeviceSessionRequestType, map[string]interface{}{
+				"brand-id":  "canonical",
+				"model":     "pc",
+				"serial":    serialNum,
+				"nonce":     nonce,
+				"timestamp": time.Now().UTC().Format(time.RFC3339),
+			}, nil, devKey)
+			if err != nil {
+				return nil, err
+			}
+			return &auth.DeviceSessionRequestParams{
+				Request: req.(*asserts.DeviceSessionRequest),
+				Serial:  serial.(*asserts.Serial),
+				Model:   model.(*asserts.Model),
+			}, nil
+		},
+	}
+}
+
+func (s *localStoreSuite) TestDeviceSession(c *C) {
+	s.opts.RequireDeviceSession = true
+	s.server.Config.Handler = localstore.New(s.dir, s.opts)
+	s.addFixtures(c)
+
+	// without a device session
+	resp, err := http.Get(s.server.URL + "/api/v1/snaps/details/foo")
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 401)
+	c.Check(resp.Header.Get("WWW-Authenticate"), Equals, "Macaroon refresh_device_session=1")
+
+	ac := s.deviceAuthContext(c, "9999")
+	info, err := s.newStore(c, ac).SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(1))
+	c.Check(ac.device.SessionMacaroon, Not(Equals), "")
+
+	// an unknown session is refreshed
+	ac.device.SessionMacaroon = "unknown"
+	_, err = s.newStore(c, ac).SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(ac.device.SessionMacaroon, Not(Equals), "unknown")
+
+	// assertions do not need a session
+	_, err = s.newStore(c, nil).Assertion(asserts.SnapDeclarationType, []string{"16", fooSnapID}, nil)
+	c.Check(err, IsNil)
+}
+
+func (s *localStoreSuite) TestDeviceSessionExpires(c *C) {
+	s.opts.RequireDeviceSession = true
+	s.server.Config.Handler = localstore.New(s.dir, s.opts)
+	s.addFixtures(c)
+
+	ac := s.deviceAuthContext(c, "9999")
+	sto := s.newStore(c, ac)
+	_, err := sto.SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	session := ac.device.SessionMacaroon
+
+	restore := localstore.MockTimeNow(func() time.Time { return time.Now().Add(25 * time.Hour) })
+	defer restore()
+	_, err = sto.SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(ac.device.SessionMacaroon, Not(Equals), session)
+}
+
+func (s *localStoreSuite) TestDeviceSessionMismatchedSerial(c *C) {
+	s.opts.RequireDeviceSession = true
+	s.server.Config.Handler = localstore.New(s.dir, s.opts)
+	s.addFixtures(c)
+
+	ac := s.deviceAuthContext(c, "1111")
+	_, err := s.newStore(c, ac).SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Check(err, ErrorMatches, `cannot get device session from store: store server returned status 400 and body .*device-session-request does not match serial-assertion.*`)
+}
+
+func (s *localStoreSuite) TestRelease(c *C) {
+	s.addFixtures(c)
+	sto := s.newStore(c, nil)
+
+	err := localstore.Release(s.dir, "foo", 2, "latest/stable")
+	c.Assert(err, IsNil)
+	err = localstore.Release(s.dir, "bar", 7, "1.0/beta")
+	c.Assert(err, IsNil)
+	// make sure the change is noticed even on coarse mtimes
+	future := time.Now().Add(2 * time.Minute)
+	c.Assert(os.Chtimes(filepath.Join(s.dir, "index.json"), future, future), IsNil)
+
+	info, err := sto.SnapInfo(store.SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(2))
+	info, err = sto.SnapInfo(store.SnapSpec{Name: "bar", Channel: "1.0/edge"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(7))
+	// sections are kept
+	sections, err := sto.Sections(nil)
+	c.Assert(err, IsNil)
+	c.Check(sections, DeepEquals, []string{"featured", "games", "utilities"})
+
+	err = localstore.Release(s.dir, "foo", 2, "2.0/foo")
+	c.Check(err, ErrorMatches, `invalid channel "2.0/foo"`)
+}
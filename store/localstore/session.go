@@ -0,0 +1,261 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package localstore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+var (
+	nonceLifetime   = 5 * time.Minute
+	sessionLifetime = 24 * time.Hour
+
+	timeNow = time.Now
+)
+
+// deviceSession is a session handed out to a device that proved its
+// identity with a serial assertion and a signed session request.
+type deviceSession struct {
+	brandID string
+	model   string
+	serial  string
+	expires time.Time
+}
+
+type sessions struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	active map[string]*deviceSession
+}
+
+func newSessions() *sessions {
+	return &sessions{
+		nonces: make(map[string]time.Time),
+		active: make(map[string]*deviceSession),
+	}
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (ss *sessions) expire(now time.Time) {
+	for nonce, expires := range ss.nonces {
+		if now.After(expires) {
+			delete(ss.nonces, nonce)
+		}
+	}
+	for token, sess := range ss.active {
+		if now.After(sess.expires) {
+			delete(ss.active, token)
+		}
+	}
+}
+
+func (ss *sessions) newNonce() (string, error) {
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	now := timeNow()
+	ss.expire(now)
+	ss.nonces[nonce] = now.Add(nonceLifetime)
+	return nonce, nil
+}
+
+// useNonce consumes the nonce, reporting whether it was valid.
+func (ss *sessions) useNonce(nonce string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.expire(timeNow())
+	if _, ok := ss.nonces[nonce]; !ok {
+		return false
+	}
+	delete(ss.nonces, nonce)
+	return true
+}
+
+func (ss *sessions) start(serial *asserts.Serial, previous string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if previous != "" {
+		delete(ss.active, previous)
+	}
+	ss.active[token] = &deviceSession{
+		brandID: serial.BrandID(),
+		model:   serial.Model(),
+		serial:  serial.Serial(),
+		expires: timeNow().Add(sessionLifetime),
+	}
+	return token, nil
+}
+
+func (ss *sessions) lookup(token string) *deviceSession {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	sess := ss.active[token]
+	if sess == nil || timeNow().After(sess.expires) {
+		return nil
+	}
+	return sess
+}
+
+var deviceAuthRE = regexp.MustCompile(`^Macaroon root="([^"]*)"`)
+
+// deviceSessionToken extracts the session from the
+// X-Device-Authorization header of the request.
+func deviceSessionToken(req *http.Request) string {
+	m := deviceAuthRE.FindStringSubmatch(req.Header.Get("X-Device-Authorization"))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// authorized checks for a valid device session when those are
+// required, asking the client to refresh its session otherwise.
+func (s *Server) authorized(w http.ResponseWriter, req *http.Request) bool {
+	if !s.opts.RequireDeviceSession {
+		return true
+	}
+	if s.sessions.lookup(deviceSessionToken(req)) != nil {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", "Macaroon refresh_device_session=1")
+	errorResponse(w, http.StatusUnauthorized, "device authorization required")
+	return false
+}
+
+func (s *Server) nonceEndpoint(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		errorResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	nonce, err := s.sessions.newNonce()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot generate nonce: %v", err)
+		return
+	}
+	jsonResponse(w, "application/json", map[string]string{"nonce": nonce})
+}
+
+func decodeAssertion(encoded string, typ *asserts.AssertionType) (asserts.Assertion, error) {
+	a, err := asserts.Decode([]byte(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if a.Type() != typ {
+		return nil, fmt.Errorf("expected %s assertion, got %s", typ.Name, a.Type().Name)
+	}
+	return a, nil
+}
+
+func (s *Server) sessionEndpoint(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		errorResponse(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var data struct {
+		Request string `json:"device-session-request"`
+		Serial  string `json:"serial-assertion"`
+		Model   string `json:"model-assertion"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		errorResponse(w, http.StatusBadRequest, "cannot decode request body: %v", err)
+		return
+	}
+
+	a, err := decodeAssertion(data.Request, asserts.DeviceSessionRequestType)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid device-session-request: %v", err)
+		return
+	}
+	sessReq := a.(*asserts.DeviceSessionRequest)
+	a, err = decodeAssertion(data.Serial, asserts.SerialType)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid serial-assertion: %v", err)
+		return
+	}
+	serial := a.(*asserts.Serial)
+	a, err = decodeAssertion(data.Model, asserts.ModelType)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "invalid model-assertion: %v", err)
+		return
+	}
+	model := a.(*asserts.Model)
+
+	if !s.sessions.useNonce(sessReq.Nonce()) {
+		errorResponse(w, http.StatusBadRequest, "invalid or expired nonce")
+		return
+	}
+
+	cat, err := s.catalog()
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot load store: %v", err)
+		return
+	}
+	if err := cat.db.Check(model); err != nil {
+		errorResponse(w, http.StatusBadRequest, "cannot verify model-assertion: %v", err)
+		return
+	}
+	if err := cat.db.Check(serial); err != nil {
+		errorResponse(w, http.StatusBadRequest, "cannot verify serial-assertion: %v", err)
+		return
+	}
+	if serial.BrandID() != model.BrandID() || serial.Model() != model.Model() {
+		errorResponse(w, http.StatusBadRequest, "serial-assertion does not match model-assertion")
+		return
+	}
+	if sessReq.BrandID() != serial.BrandID() || sessReq.Model() != serial.Model() || sessReq.Serial() != serial.Serial() {
+		errorResponse(w, http.StatusBadRequest, "device-session-request does not match serial-assertion")
+		return
+	}
+	if err := asserts.SignatureCheck(sessReq, serial.DeviceKey()); err != nil {
+		errorResponse(w, http.StatusBadRequest, "cannot verify device-session-request: %v", err)
+		return
+	}
+
+	token, err := s.sessions.start(serial, deviceSessionToken(req))
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, "cannot start session: %v", err)
+		return
+	}
+	jsonResponse(w, "application/json", map[string]string{"macaroon": token})
+}
@@ -50,3 +50,9 @@ func MockOsutilStreamCommand(f func(string, ...string) (io.ReadCloser, error)) f
 	osutilStreamCommand = f
 	return func() { osutilStreamCommand = old }
 }
+
+func MockMaxJournalMessage(n int) func() {
+	old := maxJournalMessage
+	maxJournalMessage = n
+	return func() { maxJournalMessage = old }
+}
@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/snapcore/snapd/dirs"
+)
+
+var journalSocket = "/run/systemd/journal/socket"
+
+var validJournalField = regexp.MustCompile("^[A-Z0-9][A-Z0-9_]*$")
+
+// maxJournalMessage is the maximum size of the messages sent to the
+// journal, so that together with the fields they comfortably fit in a
+// single datagram. Longer messages are cut down to their end.
+var maxJournalMessage = 8 * 1024
+
+const journalTruncatedMark = "[...]"
+
+// truncateJournalMessage returns the end of the message if it is too
+// long to be sent, marked as truncated.
+func truncateJournalMessage(message string) string {
+	if len(message) <= maxJournalMessage {
+		return message
+	}
+	start := len(message) - maxJournalMessage + len(journalTruncatedMark)
+	// do not cut a character in half
+	for start < len(message) && !utf8.RuneStart(message[start]) {
+		start++
+	}
+	return journalTruncatedMark + message[start:]
+}
+
+// SendToJournal sends a message to journald along with the given
+// fields, which must be upper case, using its native protocol. The
+// message is truncated to its end if it is too long.
+//
+// inspired by libsystemd/sd-journal/journal-send.c from the systemd source
+func SendToJournal(message string, fields map[string]string) error {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if !validJournalField.MatchString(key) {
+			return fmt.Errorf("cannot use journal field %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", truncateJournalMessage(message))
+	for _, key := range keys {
+		writeJournalField(&buf, key, fields[key])
+	}
+
+	raddr := &net.UnixAddr{
+		Name: filepath.Join(dirs.GlobalRootDir, journalSocket),
+		Net:  "unixgram",
+	}
+	conn, err := net.DialUnix("unixgram", nil, raddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(buf.Bytes())
+	return err
+}
+
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", key, value)
+		return
+	}
+	// multi-line values are sent as the key, a newline, the
+	// length of the value as a little endian 64 bit integer, and
+	// the value itself
+	buf.WriteString(key)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
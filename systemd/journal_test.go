@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type journalTestSuite struct{}
+
+var _ = Suite(&journalTestSuite{})
+
+func (s *journalTestSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *journalTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *journalTestSuite) TestSendToJournal(c *C) {
+	sockPath := filepath.Join(dirs.GlobalRootDir, "/run/systemd/journal/socket")
+	c.Assert(os.MkdirAll(filepath.Dir(sockPath), 0755), IsNil)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: sockPath,
+		Net:  "unixgram",
+	})
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	err = systemd.SendToJournal("line one\nline two", map[string]string{
+		"SYSLOG_IDENTIFIER": "snapd",
+		"SNAP_NAME":         "foo",
+	})
+	c.Assert(err, IsNil)
+
+	var buf [1024]byte
+	n, err := conn.Read(buf[:])
+	c.Assert(err, IsNil)
+	c.Check(string(buf[:n]), Equals, "MESSAGE\n\x11\x00\x00\x00\x00\x00\x00\x00line one\nline two\n"+
+		"SNAP_NAME=foo\nSYSLOG_IDENTIFIER=snapd\n")
+}
+
+func (s *journalTestSuite) TestSendToJournalTruncates(c *C) {
+	restore := systemd.MockMaxJournalMessage(10)
+	defer restore()
+
+	sockPath := filepath.Join(dirs.GlobalRootDir, "/run/systemd/journal/socket")
+	c.Assert(os.MkdirAll(filepath.Dir(sockPath), 0755), IsNil)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: sockPath,
+		Net:  "unixgram",
+	})
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	var buf [1024]byte
+	for _, t := range []struct {
+		message, sent string
+	}{
+		{"0123456789", "MESSAGE=0123456789\n"},
+		{"0123456789abc", "MESSAGE=[...]89abc\n"},
+		// characters are not cut in half
+		{"0123456789aéxyzwv", "MESSAGE=[...]xyzwv\n"},
+	} {
+		c.Assert(systemd.SendToJournal(t.message, nil), IsNil)
+		n, err := conn.Read(buf[:])
+		c.Assert(err, IsNil)
+		c.Check(string(buf[:n]), Equals, t.sent)
+	}
+}
+
+func (s *journalTestSuite) TestSendToJournalInvalidField(c *C) {
+	err := systemd.SendToJournal("message", map[string]string{"snap_name": "foo"})
+	c.Check(err, ErrorMatches, `cannot use journal field "snap_name"`)
+}
+
+func (s *journalTestSuite) TestSendToJournalNoJournal(c *C) {
+	err := systemd.SendToJournal("message", nil)
+	c.Check(err, NotNil)
+}
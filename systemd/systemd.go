@@ -32,6 +32,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var (
@@ -108,6 +109,7 @@ type Systemd interface {
 	Kill(service, signal string) error
 	Restart(service string, timeout time.Duration) error
 	Status(services ...string) ([]*ServiceStatus, error)
+	TimerStatus(timers ...string) ([]*TimerStatus, error)
 	LogReader(services []string, n string, follow bool) (io.ReadCloser, error)
 	WriteMountUnitFile(name, what, where, fstype string) (string, error)
 	Mask(service string) error
@@ -126,6 +128,9 @@ const (
 
 	// the default target for systemd units that we generate
 	SocketsTarget = "sockets.target"
+
+	// the default target for systemd timer units that we generate
+	TimersTarget = "timers.target"
 )
 
 type reporter interface {
@@ -192,79 +197,140 @@ type ServiceStatus struct {
 	Active          bool
 }
 
-func (s *systemd) Status(serviceNames ...string) ([]*ServiceStatus, error) {
-	expected := []string{"Id", "Type", "ActiveState", "UnitFileState"}
-	cmd := make([]string, len(serviceNames)+2)
+// showProperties runs ‘systemctl show’ for the given properties of the
+// given units, and returns the values of those properties for each unit,
+// in order. Only the properties listed in canBeEmpty may have an empty
+// value.
+func showProperties(what string, unitNames []string, properties []string, canBeEmpty ...string) ([]map[string]string, error) {
+	cmd := make([]string, len(unitNames)+2)
 	cmd[0] = "show"
-	cmd[1] = "--property=" + strings.Join(expected, ",")
-	copy(cmd[2:], serviceNames)
+	cmd[1] = "--property=" + strings.Join(properties, ",")
+	copy(cmd[2:], unitNames)
 	bs, err := systemctlCmd(cmd...)
 	if err != nil {
 		return nil, err
 	}
 
-	sts := make([]*ServiceStatus, 0, len(serviceNames))
-	cur := &ServiceStatus{}
-	seen := map[string]bool{}
+	all := make([]map[string]string, 0, len(unitNames))
+	cur := map[string]string{}
 
 	for _, bs := range statusregex.FindAllSubmatch(bs, -1) {
 		if len(bs[0]) == 0 {
-			// systemctl separates data pertaining to particular services by an empty line
-			missing := make([]string, 0, len(expected))
-			for _, k := range expected {
-				if !seen[k] {
+			// systemctl separates data pertaining to particular units by an empty line
+			missing := make([]string, 0, len(properties))
+			for _, k := range properties {
+				if _, ok := cur[k]; !ok {
 					missing = append(missing, k)
 				}
 			}
 			if len(missing) > 0 {
-				return nil, fmt.Errorf("cannot get service status: missing %s in ‘systemctl show’ output", strings.Join(missing, ", "))
+				return nil, fmt.Errorf("cannot get %s status: missing %s in ‘systemctl show’ output", what, strings.Join(missing, ", "))
 
 			}
-			sts = append(sts, cur)
-			if len(sts) > len(serviceNames) {
+			all = append(all, cur)
+			if len(all) > len(unitNames) {
 				break // wut
 			}
-			if cur.ServiceFileName != serviceNames[len(sts)-1] {
-				return nil, fmt.Errorf("cannot get service status: queried status of %q but got status of %q", serviceNames[len(sts)-1], cur.ServiceFileName)
+			if cur["Id"] != unitNames[len(all)-1] {
+				return nil, fmt.Errorf("cannot get %s status: queried status of %q but got status of %q", what, unitNames[len(all)-1], cur["Id"])
 			}
 
-			cur = &ServiceStatus{}
-			seen = map[string]bool{}
+			cur = map[string]string{}
 			continue
 		}
 		if len(bs[3]) > 0 {
-			return nil, fmt.Errorf("cannot get service status: bad line %q in ‘systemctl show’ output", bs[3])
+			return nil, fmt.Errorf("cannot get %s status: bad line %q in ‘systemctl show’ output", what, bs[3])
 		}
 		k := string(bs[1])
 		v := string(bs[2])
 
-		if v == "" {
-			return nil, fmt.Errorf("cannot get service status: empty field %q in ‘systemctl show’ output", k)
+		if v == "" && !strutil.ListContains(canBeEmpty, k) {
+			return nil, fmt.Errorf("cannot get %s status: empty field %q in ‘systemctl show’ output", what, k)
+		}
+
+		if !strutil.ListContains(properties, k) {
+			return nil, fmt.Errorf("cannot get %s status: unexpected field %q in ‘systemctl show’ output", what, k)
 		}
 
-		switch k {
-		case "Id":
-			cur.ServiceFileName = v
-		case "Type":
-			cur.Daemon = v
-		case "ActiveState":
-			// made to match “systemctl is-active” behaviour, at least at systemd 229
-			cur.Active = v == "active" || v == "reloading"
-		case "UnitFileState":
-			// "static" means it can't be disabled
-			cur.Enabled = v == "enabled" || v == "static"
-		default:
-			return nil, fmt.Errorf("cannot get service status: unexpected field %q in ‘systemctl show’ output", k)
+		if _, ok := cur[k]; ok {
+			return nil, fmt.Errorf("cannot get %s status: duplicate field %q in ‘systemctl show’ output", what, k)
 		}
+		cur[k] = v
+	}
+
+	if len(all) != len(unitNames) {
+		return nil, fmt.Errorf("cannot get %s status: expected %d results, got %d", what, len(unitNames), len(all))
+	}
+
+	return all, nil
+}
+
+// isEnabled is made to match “systemctl is-enabled”; "static" means
+// the unit can't be disabled.
+func isEnabled(unitFileState string) bool {
+	return unitFileState == "enabled" || unitFileState == "static"
+}
+
+// isActive is made to match “systemctl is-active” behaviour, at least
+// at systemd 229.
+func isActive(activeState string) bool {
+	return activeState == "active" || activeState == "reloading"
+}
+
+func (s *systemd) Status(serviceNames ...string) ([]*ServiceStatus, error) {
+	all, err := showProperties("service", serviceNames, []string{"Id", "Type", "ActiveState", "UnitFileState"})
+	if err != nil {
+		return nil, err
+	}
 
-		if seen[k] {
-			return nil, fmt.Errorf("cannot get service status: duplicate field %q in ‘systemctl show’ output", k)
+	sts := make([]*ServiceStatus, len(all))
+	for i, props := range all {
+		sts[i] = &ServiceStatus{
+			ServiceFileName: props["Id"],
+			Daemon:          props["Type"],
+			Active:          isActive(props["ActiveState"]),
+			Enabled:         isEnabled(props["UnitFileState"]),
 		}
-		seen[k] = true
 	}
 
-	if len(sts) != len(serviceNames) {
-		return nil, fmt.Errorf("cannot get service status: expected %d results, got %d", len(serviceNames), len(sts))
+	return sts, nil
+}
+
+// TimerStatus is the status of a timer unit.
+type TimerStatus struct {
+	TimerFileName string
+	Enabled       bool
+	Active        bool
+	// Next is when the timer will next trigger its service; it is
+	// the zero time if the timer is not scheduled to trigger.
+	Next time.Time
+}
+
+// systemdTimestampLayout is how ‘systemctl show’ formats timestamps
+const systemdTimestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// TimerStatus returns the status of the given timers.
+func (s *systemd) TimerStatus(timerNames ...string) ([]*TimerStatus, error) {
+	all, err := showProperties("timer", timerNames, []string{"Id", "ActiveState", "UnitFileState", "NextElapseUSecRealtime"}, "NextElapseUSecRealtime")
+	if err != nil {
+		return nil, err
+	}
+
+	sts := make([]*TimerStatus, len(all))
+	for i, props := range all {
+		sts[i] = &TimerStatus{
+			TimerFileName: props["Id"],
+			Active:        isActive(props["ActiveState"]),
+			Enabled:       isEnabled(props["UnitFileState"]),
+		}
+		if next := props["NextElapseUSecRealtime"]; next != "" && next != "n/a" {
+			// systemd shows timestamps in the local time zone
+			t, err := time.ParseInLocation(systemdTimestampLayout, next, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("cannot get timer status: cannot parse next elapse time %q: %v", next, err)
+			}
+			sts[i].Next = t
+		}
 	}
 
 	return sts, nil
@@ -217,6 +217,74 @@ UnitFileState=disabled
 	c.Assert(s.argses, DeepEquals, [][]string{{"show", "--property=Id,Type,ActiveState,UnitFileState", "foo.service", "bar.service", "baz.service"}})
 }
 
+func (s *SystemdTestSuite) TestTimerStatus(c *C) {
+	s.outs = [][]byte{
+		[]byte(`
+Id=foo.timer
+ActiveState=active
+UnitFileState=enabled
+NextElapseUSecRealtime=Mon 2018-04-23 10:00:00 UTC
+
+Id=bar.timer
+ActiveState=inactive
+UnitFileState=disabled
+NextElapseUSecRealtime=
+`[1:]),
+	}
+	s.errors = []error{nil}
+	out, err := New("", s.rep).TimerStatus("foo.timer", "bar.timer")
+	c.Assert(err, IsNil)
+	c.Check(out, DeepEquals, []*TimerStatus{
+		{
+			TimerFileName: "foo.timer",
+			Active:        true,
+			Enabled:       true,
+			Next:          time.Date(2018, 4, 23, 10, 0, 0, 0, time.UTC),
+		}, {
+			TimerFileName: "bar.timer",
+			Active:        false,
+			Enabled:       false,
+		},
+	})
+	c.Check(s.rep.msgs, IsNil)
+	c.Assert(s.argses, DeepEquals, [][]string{{"show", "--property=Id,ActiveState,UnitFileState,NextElapseUSecRealtime", "foo.timer", "bar.timer"}})
+}
+
+func (s *SystemdTestSuite) TestTimerStatusLocalTime(c *C) {
+	oldLocal := time.Local
+	defer func() { time.Local = oldLocal }()
+	time.Local = time.FixedZone("CEST", 2*60*60)
+
+	s.outs = [][]byte{
+		[]byte(`
+Id=foo.timer
+ActiveState=active
+UnitFileState=enabled
+NextElapseUSecRealtime=Mon 2018-04-23 10:00:00 CEST
+`[1:]),
+	}
+	s.errors = []error{nil}
+	out, err := New("", s.rep).TimerStatus("foo.timer")
+	c.Assert(err, IsNil)
+	c.Assert(out, HasLen, 1)
+	c.Check(out[0].Next.Equal(time.Date(2018, 4, 23, 8, 0, 0, 0, time.UTC)), Equals, true, Commentf("%v", out[0].Next))
+}
+
+func (s *SystemdTestSuite) TestTimerStatusBadNextElapse(c *C) {
+	s.outs = [][]byte{
+		[]byte(`
+Id=foo.timer
+ActiveState=active
+UnitFileState=enabled
+NextElapseUSecRealtime=potato
+`[1:]),
+	}
+	s.errors = []error{nil}
+	out, err := New("", s.rep).TimerStatus("foo.timer")
+	c.Assert(err, ErrorMatches, `cannot get timer status: cannot parse next elapse time "potato": .*`)
+	c.Check(out, IsNil)
+}
+
 func (s *SystemdTestSuite) TestStatusBadNumberOfValues(c *C) {
 	s.outs = [][]byte{
 		[]byte(`
@@ -26,7 +26,9 @@ import (
 // some internal helper exposed for testing
 var (
 	// services
-	GenerateSnapServiceFile = generateSnapServiceFile
+	GenerateSnapServiceFile     = generateSnapServiceFile
+	GenerateSnapTimerFile       = generateSnapTimerFile
+	GenerateOnCalendarSchedules = generateOnCalendarSchedules
 
 	// desktop
 	SanitizeDesktopFile    = sanitizeDesktopFile
@@ -41,3 +43,11 @@ func MockKillWait(wait time.Duration) (restore func()) {
 		killWait = oldKillWait
 	}
 }
+
+func MockRandomDuration(f func(max time.Duration) time.Duration) (restore func()) {
+	oldRandomDuration := randomDuration
+	randomDuration = f
+	return func() {
+		randomDuration = oldRandomDuration
+	}
+}
@@ -22,6 +22,7 @@ package wrappers
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -34,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/timeout"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 type interacter interface {
@@ -63,10 +65,18 @@ func stopService(sysd systemd.Systemd, app *snap.AppInfo, inter interacter) erro
 	serviceName := app.ServiceName()
 	tout := serviceStopTimeout(app)
 
-	socketErrors := []error{}
+	extraServices := []string{}
 	for _, socket := range app.Sockets {
-		if err := sysd.Stop(filepath.Base(socket.File()), tout); err != nil {
-			socketErrors = append(socketErrors, err)
+		extraServices = append(extraServices, filepath.Base(socket.File()))
+	}
+	if app.Timer != nil {
+		extraServices = append(extraServices, filepath.Base(app.Timer.File()))
+	}
+
+	extraErrors := []error{}
+	for _, extra := range extraServices {
+		if err := sysd.Stop(extra, tout); err != nil {
+			extraErrors = append(extraErrors, err)
 		}
 	}
 
@@ -82,8 +92,8 @@ func stopService(sysd systemd.Systemd, app *snap.AppInfo, inter interacter) erro
 
 	}
 
-	if len(socketErrors) > 0 {
-		return socketErrors[0]
+	if len(extraErrors) > 0 {
+		return extraErrors[0]
 	}
 
 	return nil
@@ -100,7 +110,9 @@ func StartServices(apps []*snap.AppInfo, inter interacter) (err error) {
 			continue
 		}
 
-		if len(app.Sockets) == 0 {
+		// services activated by sockets or timers are not started
+		// directly, their activators are
+		if len(app.Sockets) == 0 && app.Timer == nil {
 			services = append(services, app.ServiceName())
 		}
 
@@ -116,6 +128,18 @@ func StartServices(apps []*snap.AppInfo, inter interacter) (err error) {
 			}
 		}
 
+		if app.Timer != nil {
+			timerService := filepath.Base(app.Timer.File())
+			// enable the timer
+			if err := sysd.Enable(timerService); err != nil {
+				return err
+			}
+
+			if err := sysd.Start(timerService); err != nil {
+				return err
+			}
+		}
+
 		defer func(app *snap.AppInfo) {
 			if err == nil {
 				return
@@ -136,6 +160,42 @@ func StartServices(apps []*snap.AppInfo, inter interacter) (err error) {
 	return nil
 }
 
+// UpdateSnapServiceUnits rewrites the service units of the snap that
+// are out of date, for instance because their resource limits
+// changed, and reloads systemd if any was. Whether the services are
+// enabled is left untouched.
+func UpdateSnapServiceUnits(s *snap.Info, inter interacter) error {
+	changed := false
+	for _, app := range s.Apps {
+		if !app.IsService() {
+			continue
+		}
+		svcFilePath := app.ServiceFile()
+		if !osutil.FileExists(svcFilePath) {
+			// not added (yet), nothing to update
+			continue
+		}
+		content, err := generateSnapServiceFile(app)
+		if err != nil {
+			return err
+		}
+		err = osutil.EnsureFileState(svcFilePath, &osutil.FileState{Content: content, Mode: 0644})
+		if err == osutil.ErrSameState {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if changed {
+		sysd := systemd.New(dirs.GlobalRootDir, inter)
+		return sysd.DaemonReload()
+	}
+	return nil
+}
+
 // AddSnapServices adds service units for the applications from the snap which are services.
 func AddSnapServices(s *snap.Info, inter interacter) (err error) {
 	sysd := systemd.New(dirs.GlobalRootDir, inter)
@@ -191,6 +251,20 @@ func AddSnapServices(s *snap.Info, inter interacter) (err error) {
 			written = append(written, path)
 		}
 
+		// Generate systemd .timer file if needed
+		if app.Timer != nil {
+			content, err := generateSnapTimerFile(app)
+			if err != nil {
+				return err
+			}
+			path := app.Timer.File()
+			os.MkdirAll(filepath.Dir(path), 0755)
+			if err := osutil.AtomicWriteFile(path, content, 0644, 0); err != nil {
+				return err
+			}
+			written = append(written, path)
+		}
+
 		svcName := app.ServiceName()
 		if err := sysd.Enable(svcName); err != nil {
 			return err
@@ -251,6 +325,18 @@ func RemoveSnapServices(s *snap.Info, inter interacter) error {
 			}
 		}
 
+		if app.Timer != nil {
+			path := app.Timer.File()
+			timerName := filepath.Base(path)
+			if err := sysd.Disable(timerName); err != nil {
+				return err
+			}
+
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logger.Noticef("Failed to remove timer file %q for %q: %v", path, serviceName, err)
+			}
+		}
+
 		if err := sysd.Disable(serviceName); err != nil {
 			return err
 		}
@@ -318,7 +404,21 @@ RemainAfterExit={{.Remain}}
 {{- if .App.BusName}}
 BusName={{.App.BusName}}
 {{- end}}
-{{- if not .App.Sockets}}
+{{- with .App.Resources}}
+{{- if .Memory}}
+MemoryLimit={{.Memory}}
+{{- end}}
+{{- if .CPUQuota}}
+CPUQuota={{.CPUQuota}}%
+{{- end}}
+{{- if .Tasks}}
+TasksMax={{.Tasks}}
+{{- end}}
+{{- end}}
+{{- if .App.Snap.QuotaSlice}}
+Slice={{.App.Snap.QuotaSlice}}
+{{- end}}
+{{- if not (or .App.Sockets .App.Timer)}}
 
 [Install]
 WantedBy={{.ServicesTarget}}
@@ -449,3 +549,150 @@ func renderListenStream(socket *snap.SocketInfo) string {
 	listenStream := strings.Replace(socket.ListenStream, "$SNAP_DATA", snap.DataDir(), -1)
 	return strings.Replace(listenStream, "$SNAP_COMMON", snap.CommonDataDir(), -1)
 }
+
+func genServiceTimerFile(appInfo *snap.AppInfo) []byte {
+	timerTemplate := `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Timer {{.TimerName}} for snap application {{.App.Snap.Name}}.{{.App.Name}}
+Requires={{.MountUnit}}
+After={{.MountUnit}}
+X-Snappy=yes
+
+[Timer]
+Unit={{.ServiceFileName}}
+{{ range .Schedules }}OnCalendar={{ . }}
+{{ end }}
+[Install]
+WantedBy={{.TimersTarget}}
+`
+	var templateOut bytes.Buffer
+	t := template.Must(template.New("timer-wrapper").Parse(timerTemplate))
+
+	timerSchedule, err := timeutil.ParseSchedule(appInfo.Timer.Timer)
+	if err != nil {
+		// this can never happen, the timer was validated already
+		logger.Panicf("Unable to parse timer schedule %q: %v", appInfo.Timer.Timer, err)
+	}
+
+	wrapperData := struct {
+		App             *snap.AppInfo
+		ServiceFileName string
+		TimersTarget    string
+		TimerName       string
+		MountUnit       string
+		Schedules       []string
+	}{
+		App:             appInfo,
+		ServiceFileName: filepath.Base(appInfo.ServiceFile()),
+		TimersTarget:    systemd.TimersTarget,
+		TimerName:       appInfo.Name,
+		MountUnit:       filepath.Base(systemd.MountUnitPath(appInfo.Snap.MountDir())),
+		Schedules:       generateOnCalendarSchedules(timerSchedule),
+	}
+
+	if err := t.Execute(&templateOut, wrapperData); err != nil {
+		// this can never happen, except we forget a variable
+		logger.Panicf("Unable to execute template: %v", err)
+	}
+
+	return templateOut.Bytes()
+}
+
+func generateSnapTimerFile(app *snap.AppInfo) ([]byte, error) {
+	if err := snap.ValidateApp(app); err != nil {
+		return nil, err
+	}
+
+	return genServiceTimerFile(app), nil
+}
+
+// randomDuration returns a random duration within [0, max), used to place
+// events inside spread (~) clock spans
+var randomDuration = func(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// weekdayNames are the weekday names as understood by systemd.time(7)
+var weekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// generateOnCalendarSchedules converts a schedule into OnCalendar=
+// event expressions as described in systemd.time(7)
+func generateOnCalendarSchedules(schedule []*timeutil.Schedule) []string {
+	var calendarEvents []string
+	for _, sched := range schedule {
+		days := make([]string, 0, len(sched.WeekSpans))
+		for _, week := range sched.WeekSpans {
+			days = append(days, onCalendarDays(week))
+		}
+		if len(days) == 0 {
+			days = append(days, "*-*-*")
+		}
+
+		times := []string{}
+		baseSpans := sched.ClockSpans
+		if len(baseSpans) == 0 {
+			baseSpans = []timeutil.ClockSpan{{}}
+		}
+		for _, span := range baseSpans {
+			for _, sub := range span.ClockSpans() {
+				start := sub.Start
+				if sub.Spread {
+					dur := sub.End.Sub(sub.Start)
+					if dur < 0 {
+						// eg. 23:00~01:00
+						dur += 24 * time.Hour
+					}
+					if dur > 5*time.Minute {
+						// same as timeutil, leave some room at the end
+						dur -= 5 * time.Minute
+					}
+					start = start.Add(randomDuration(dur))
+				}
+				// 24:00 is the same as 00:00 on the next day
+				start.Hour %= 24
+				times = append(times, start.String())
+			}
+		}
+
+		for _, day := range days {
+			for _, t := range times {
+				calendarEvents = append(calendarEvents, day+" "+t)
+			}
+		}
+	}
+	return calendarEvents
+}
+
+func weekdayRange(start, end time.Weekday) string {
+	if start == end {
+		return weekdayNames[start]
+	}
+	return weekdayNames[start] + ".." + weekdayNames[end]
+}
+
+// onCalendarDays converts a week span into the date part of an OnCalendar=
+// event, eg. mon-fri is "Mon..Fri *-*-*" and mon1 is "Mon *-*-01..07"
+func onCalendarDays(week timeutil.WeekSpan) string {
+	start, end := week.Start.Weekday, week.End.Weekday
+
+	var weekdays string
+	if start <= end {
+		weekdays = weekdayRange(start, end)
+	} else {
+		// wraps around the week end, eg. fri-mon
+		weekdays = weekdayRange(start, time.Saturday) + "," + weekdayRange(time.Sunday, end)
+	}
+
+	date := "*-*-*"
+	switch {
+	case week.Start.Pos == timeutil.LastWeek:
+		// last 7 days of the month
+		date = "*-*~07/1"
+	case week.Start.Pos != timeutil.EveryWeek:
+		date = fmt.Sprintf("*-*-%02d..%02d", (week.Start.Pos-1)*7+1, week.End.Pos*7)
+	}
+	return weekdays + " " + date
+}
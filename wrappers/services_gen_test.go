@@ -21,6 +21,7 @@ package wrappers_test
 
 import (
 	"fmt"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -30,6 +31,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/timeout"
+	"github.com/snapcore/snapd/timeutil"
 	"github.com/snapcore/snapd/wrappers"
 )
 
@@ -212,6 +214,63 @@ apps:
 	c.Assert(string(generatedWrapper), Equals, expectedDbusService)
 }
 
+func (s *servicesWrapperGenSuite) TestGenServiceFileWithResources(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        stop-command: bin/stop
+        reload-command: bin/reload
+        post-stop-command: bin/stop --post
+        stop-timeout: 10s
+        daemon: simple
+        resources:
+            memory: 128M
+            cpu-quota: 50%
+            tasks: 64
+`
+
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+	app := info.Apps["app"]
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(app)
+	c.Assert(err, IsNil)
+
+	expected := fmt.Sprintf(expectedServiceFmt, mountUnitPrefix, mountUnitPrefix, "on-failure", "simple\nMemoryLimit=134217728\nCPUQuota=50%\nTasksMax=64")
+	c.Assert(string(generatedWrapper), Equals, expected)
+}
+
+func (s *servicesWrapperGenSuite) TestGenServiceFileInQuotaSlice(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        stop-command: bin/stop
+        reload-command: bin/reload
+        post-stop-command: bin/stop --post
+        stop-timeout: 10s
+        daemon: simple
+`
+
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+	info.QuotaSlice = "snap.telemetry.slice"
+	app := info.Apps["app"]
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(app)
+	c.Assert(err, IsNil)
+
+	expected := fmt.Sprintf(expectedServiceFmt, mountUnitPrefix, mountUnitPrefix, "on-failure", "simple\nSlice=snap.telemetry.slice")
+	c.Assert(string(generatedWrapper), Equals, expected)
+}
+
 func (s *servicesWrapperGenSuite) TestGenOneshotServiceFile(c *C) {
 
 	info := snaptest.MockInfo(c, `
@@ -321,3 +380,147 @@ WantedBy=multi-user.target
 	c.Logf("service: \n%v\n", string(generatedWrapper))
 	c.Assert(string(generatedWrapper), Equals, expectedService)
 }
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapServiceWithTimer(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "xkcd-webserver",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:    "xkcd-webserver",
+		Command: "bin/foo start",
+		Daemon:  "simple",
+	}
+	service.Timer = &snap.TimerInfo{
+		App:   service,
+		Timer: "10:00-12:00",
+	}
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(service)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(generatedWrapper), "[Install]"), Equals, false)
+	c.Assert(strings.Contains(string(generatedWrapper), "WantedBy=multi-user.target"), Equals, false)
+}
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapTimerFile(c *C) {
+	const expectedTimerFmt = `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Timer app for snap application snap.app
+Requires=%s-snap-44.mount
+After=%s-snap-44.mount
+X-Snappy=yes
+
+[Timer]
+Unit=snap.snap.app.service
+OnCalendar=Mon..Fri *-*-* 10:00
+OnCalendar=Mon..Fri *-*-* 23:30
+OnCalendar=Sat,Sun *-*-* 09:00
+
+[Install]
+WantedBy=timers.target
+`
+
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:    "app",
+		Command: "bin/foo start",
+		Daemon:  "simple",
+	}
+	service.Timer = &snap.TimerInfo{
+		App:   service,
+		Timer: "mon-fri,10:00,23:30,,sat-sun,9:00",
+	}
+
+	generatedWrapper, err := wrappers.GenerateSnapTimerFile(service)
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Equals, fmt.Sprintf(expectedTimerFmt, mountUnitPrefix, mountUnitPrefix))
+}
+
+func (s *servicesWrapperGenSuite) TestGenerateSnapTimerFileInvalid(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:    "app",
+		Command: "bin/foo start",
+		Daemon:  "simple",
+	}
+	service.Timer = &snap.TimerInfo{
+		App:   service,
+		Timer: "mon-foo",
+	}
+
+	_, err := wrappers.GenerateSnapTimerFile(service)
+	c.Assert(err, ErrorMatches, `timer has invalid format: .*`)
+}
+
+func (s *servicesWrapperGenSuite) TestGenerateOnCalendarSchedules(c *C) {
+	restore := wrappers.MockRandomDuration(func(max time.Duration) time.Duration {
+		return max / 2
+	})
+	defer restore()
+
+	for _, t := range []struct {
+		in       string
+		expected []string
+	}{{
+		in:       "9:00",
+		expected: []string{"*-*-* 09:00"},
+	}, {
+		in:       "9:00,21:00",
+		expected: []string{"*-*-* 09:00", "*-*-* 21:00"},
+	}, {
+		in:       "9:00-11:00",
+		expected: []string{"*-*-* 09:00"},
+	}, {
+		in:       "9:00~11:10",
+		expected: []string{"*-*-* 10:02"},
+	}, {
+		in:       "00:00-24:00/4",
+		expected: []string{"*-*-* 00:00", "*-*-* 06:00", "*-*-* 12:00", "*-*-* 18:00"},
+	}, {
+		in:       "mon",
+		expected: []string{"Mon *-*-* 00:00"},
+	}, {
+		in:       "mon,10:00",
+		expected: []string{"Mon *-*-* 10:00"},
+	}, {
+		in:       "mon,fri,10:00",
+		expected: []string{"Mon *-*-* 10:00", "Fri *-*-* 10:00"},
+	}, {
+		in:       "mon-fri,10:00",
+		expected: []string{"Mon..Fri *-*-* 10:00"},
+	}, {
+		in:       "fri-mon,10:00",
+		expected: []string{"Fri..Sat,Sun..Mon *-*-* 10:00"},
+	}, {
+		in:       "sat-tue,10:00",
+		expected: []string{"Sat,Sun..Tue *-*-* 10:00"},
+	}, {
+		in:       "mon1,10:00",
+		expected: []string{"Mon *-*-01..07 10:00"},
+	}, {
+		in:       "mon2-fri3,10:00",
+		expected: []string{"Mon..Fri *-*-08..21 10:00"},
+	}, {
+		in:       "sun5,10:00",
+		expected: []string{"Sun *-*~07/1 10:00"},
+	}, {
+		in:       "24:00",
+		expected: []string{"*-*-* 00:00"},
+	}, {
+		in:       "mon,10:00,,fri,15:00",
+		expected: []string{"Mon *-*-* 10:00", "Fri *-*-* 15:00"},
+	}} {
+		schedule, err := timeutil.ParseSchedule(t.in)
+		c.Assert(err, IsNil, Commentf("%q", t.in))
+		c.Check(wrappers.GenerateOnCalendarSchedules(schedule), DeepEquals, t.expected, Commentf("%q", t.in))
+	}
+}
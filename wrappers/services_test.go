@@ -430,6 +430,60 @@ ListenStream=%s
 
 }
 
+func (s *servicesTestSuite) TestAddRemoveSnapWithTimer(c *C) {
+	var sysdLog [][]string
+	r := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	})
+	defer r()
+
+	info := snaptest.MockSnap(c, packageHello+`
+ svc2:
+  command: bin/hello
+  daemon: oneshot
+  timer: 10:00-12:00
+`, &snap.SideInfo{Revision: snap.R(12)})
+
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.service")
+	timerFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.timer")
+
+	err := wrappers.AddSnapServices(info, nil)
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(timerFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, "(?ms).*^Unit=snap.hello-snap.svc2.service$.*")
+	c.Check(string(content), Matches, "(?ms).*^OnCalendar=\\*-\\*-\\* 10:00$.*")
+
+	content, err = ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(strings.Contains(string(content), "[Install]"), Equals, false)
+
+	sysdLog = nil
+	err = wrappers.StartServices([]*snap.AppInfo{info.Apps["svc2"]}, nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", "snap.hello-snap.svc2.timer"},
+		{"start", "snap.hello-snap.svc2.timer"},
+	})
+
+	sysdLog = nil
+	err = wrappers.StopServices([]*snap.AppInfo{info.Apps["svc2"]}, &progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"stop", "snap.hello-snap.svc2.timer"},
+		{"show", "--property=ActiveState", "snap.hello-snap.svc2.timer"},
+		{"stop", "snap.hello-snap.svc2.service"},
+		{"show", "--property=ActiveState", "snap.hello-snap.svc2.service"},
+	})
+
+	err = wrappers.RemoveSnapServices(info, &progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(timerFile), Equals, false)
+	c.Check(osutil.FileExists(svcFile), Equals, false)
+}
+
 func (s *servicesTestSuite) TestStartSnapMultiServicesFailStartCleanup(c *C) {
 	var sysdLog [][]string
 	svc1Name := "snap.hello-snap.svc1.service"
@@ -546,3 +600,38 @@ func (s *servicesTestSuite) TestServiceAfterBefore(c *C) {
 	}
 
 }
+
+func (s *servicesTestSuite) TestUpdateSnapServiceUnits(c *C) {
+	var sysdLog [][]string
+	r := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	})
+	defer r()
+
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+
+	// nothing to update if the services were not added
+	err := wrappers.UpdateSnapServiceUnits(info, nil)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(svcFile), Equals, false)
+	c.Check(sysdLog, HasLen, 0)
+
+	c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+
+	// nothing changed
+	sysdLog = nil
+	err = wrappers.UpdateSnapServiceUnits(info, nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, HasLen, 0)
+
+	// the unit is rewritten, and not enabled again
+	info.Apps["svc1"].Resources = &snap.ResourceLimits{Memory: 1 << 20}
+	err = wrappers.UpdateSnapServiceUnits(info, nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+	content, err := ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, "(?ms).*^MemoryLimit=1048576$.*")
+}
@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"bytes"
+	"os"
+	"text/template"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func genSliceFile(grp *quota.Group) []byte {
+	sliceTemplate := `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Slice for snap quota group {{.Name}}
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+{{- if .MemoryLimit}}
+MemoryAccounting=true
+MemoryLimit={{.MemoryLimit}}
+{{- end}}
+{{- if .CPUQuota}}
+CPUAccounting=true
+CPUQuota={{.CPUQuota}}%
+{{- end}}
+`
+	var templateOut bytes.Buffer
+	t := template.Must(template.New("slice-wrapper").Parse(sliceTemplate))
+	if err := t.Execute(&templateOut, grp); err != nil {
+		// this can never happen, except we forget a variable
+		logger.Panicf("Unable to execute template: %v", err)
+	}
+	return templateOut.Bytes()
+}
+
+// EnsureQuotaSlices writes the systemd slice units of the given quota
+// groups, whose cross references must have been resolved, and removes
+// the slice units of groups that no longer exist.
+func EnsureQuotaSlices(groups []*quota.Group, inter interacter) error {
+	content := make(map[string]*osutil.FileState, len(groups))
+	for _, grp := range groups {
+		content[grp.SliceName()] = &osutil.FileState{Content: genSliceFile(grp), Mode: 0644}
+	}
+
+	if err := os.MkdirAll(dirs.SnapServicesDir, 0755); err != nil {
+		return err
+	}
+	changed, removed, err := osutil.EnsureDirState(dirs.SnapServicesDir, "snap.*.slice", content)
+	if err != nil {
+		return err
+	}
+
+	if len(changed) > 0 || len(removed) > 0 {
+		sysd := systemd.New(dirs.GlobalRootDir, inter)
+		return sysd.DaemonReload()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type slicesTestSuite struct {
+	tempdir string
+	sysdLog [][]string
+
+	restorer func()
+}
+
+var _ = Suite(&slicesTestSuite{})
+
+func (s *slicesTestSuite) SetUpTest(c *C) {
+	s.tempdir = c.MkDir()
+	dirs.SetRootDir(s.tempdir)
+
+	s.sysdLog = nil
+	s.restorer = systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		return nil, nil
+	})
+}
+
+func (s *slicesTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	s.restorer()
+}
+
+func (s *slicesTestSuite) TestEnsureQuotaSlices(c *C) {
+	groups := map[string]*quota.Group{
+		"top":     {Name: "top", MemoryLimit: 1 << 30, SubGroups: []string{"sub-one"}},
+		"sub-one": {Name: "sub-one", CPUQuota: 50, ParentGroup: "top"},
+	}
+	c.Assert(quota.ResolveCrossReferences(groups), IsNil)
+
+	err := wrappers.EnsureQuotaSlices([]*quota.Group{groups["top"], groups["sub-one"]}, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+
+	topSlice := filepath.Join(dirs.SnapServicesDir, "snap.top.slice")
+	content, err := ioutil.ReadFile(topSlice)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Slice for snap quota group top
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+MemoryAccounting=true
+MemoryLimit=1073741824
+`)
+	subSlice := filepath.Join(dirs.SnapServicesDir, `snap.top-sub\x2done.slice`)
+	content, err = ioutil.ReadFile(subSlice)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Slice for snap quota group sub-one
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+CPUAccounting=true
+CPUQuota=50%
+`)
+
+	// nothing changed
+	s.sysdLog = nil
+	err = wrappers.EnsureQuotaSlices([]*quota.Group{groups["top"], groups["sub-one"]}, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+
+	// the slice of the removed group goes away
+	delete(groups, "sub-one")
+	groups["top"].SubGroups = nil
+	c.Assert(quota.ResolveCrossReferences(groups), IsNil)
+	err = wrappers.EnsureQuotaSlices([]*quota.Group{groups["top"]}, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+	c.Check(osutil.FileExists(topSlice), Equals, true)
+	c.Check(osutil.FileExists(subSlice), Equals, false)
+}